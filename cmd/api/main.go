@@ -3,27 +3,46 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"divvydoo/backend"
 	"divvydoo/backend/internal/config"
 	"divvydoo/backend/internal/controllers"
+	"divvydoo/backend/internal/metrics"
 	"divvydoo/backend/internal/middleware"
 	"divvydoo/backend/internal/repositories"
 	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/worker"
 	"divvydoo/backend/pkg/auth"
+	"divvydoo/backend/pkg/backup"
+	"divvydoo/backend/pkg/cache"
+	"divvydoo/backend/pkg/clickhouse"
+	"divvydoo/backend/pkg/email"
+	"divvydoo/backend/pkg/fx"
+	"divvydoo/backend/pkg/payments"
+	"divvydoo/backend/pkg/storage"
+	"divvydoo/backend/pkg/tlscert"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
+	runtimeConfig, err := config.NewRuntime(config.EnvFile)
+	if err != nil {
+		log.Fatalf("Invalid runtime configuration: %v", err)
+	}
 
 	log.Printf("Using MongoDB URI: %s", cfg.MongoURI)
 
@@ -31,7 +50,7 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoURI))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoURI).SetMonitor(metrics.MongoMonitor()))
 	if err != nil {
 		log.Fatalf("Failed to connect to MongoDB: %v", err)
 	}
@@ -49,106 +68,481 @@ func main() {
 
 	db := client.Database(cfg.MongoDBName)
 
+	// Initialize Redis client
+	redisClient := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+	defer redisClient.Close()
+
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		log.Fatalf("Failed to ping Redis: %v", err)
+	}
+
 	// Initialize repositories
 	userRepo := repositories.NewUserRepository(db)
 	groupRepo := repositories.NewGroupRepository(db)
 	expenseRepo := repositories.NewExpenseRepository(db)
 	balanceRepo := repositories.NewBalanceRepository(db)
+	balanceSnapshotRepo := repositories.NewBalanceSnapshotRepository(db)
+	balanceTaskRepo := repositories.NewBalanceTaskRepository(db)
+	outboxRepo := repositories.NewOutboxRepository(db)
 	settlementRepo := repositories.NewSettlementRepository(db)
+	settlementAuthorizationRepo := repositories.NewSettlementAuthorizationRepository(db)
+	jobRepo := repositories.NewJobRepository(db)
+	maintenanceRepo := repositories.NewMaintenanceRepository(db)
+	clientErrorRepo := repositories.NewClientErrorRepository(db)
+	notificationRepo := repositories.NewNotificationRepository(db)
+	backupRepo := repositories.NewBackupRepository(db)
+	consistencyRepo := repositories.NewConsistencyRepository(client)
+	nettingRepo := repositories.NewNettingRepository(db)
+	statsRepo := repositories.NewStatsRepository(db)
+	ledgerRepo := repositories.NewLedgerRepository(db)
+	suggestionRepo := repositories.NewSuggestionRepository(db)
+	budgetRepo := repositories.NewBudgetRepository(db)
+	shareLinkRepo := repositories.NewShareLinkRepository(db)
+	apiKeyRepo := repositories.NewAPIKeyRepository(db)
+	expenseRevisionRepo := repositories.NewExpenseRevisionRepository(db)
+	groupWebhookRepo := repositories.NewGroupWebhookRepository(db)
+	friendshipRepo := repositories.NewFriendshipRepository(db)
+	paymentEventRepo := repositories.NewPaymentEventRepository(db)
+
+	// Groups flagged for the ledger rollout also record their balance updates in the ledger
+	balanceRepo = repositories.NewShadowLedgerBalanceRepository(balanceRepo, ledgerRepo, func(groupID string) bool {
+		return runtimeConfig.Current().FeatureEnabledFor(services.FeatureLedgerShadow, groupID)
+	})
+
+	// Membership and user lookups are cached per replica; changes are broadcast over Redis so
+	// every replica evicts them
+	cacheInvalidator := cache.NewRedisInvalidator(redisClient, cfg.CacheInvalidationChannel)
+	if cfg.CacheTTL > 0 {
+		userRepo = repositories.NewCachedUserRepository(userRepo, cacheInvalidator, cfg.CacheTTL)
+		groupRepo = repositories.NewCachedGroupRepository(groupRepo, cacheInvalidator, cfg.CacheTTL)
+	}
+
+	// The unique indexes back the repositories' duplicate-key handling, so don't start without them
+	for name, repo := range map[string]interface{ EnsureIndexes(context.Context) error }{
+		"user":          userRepo,
+		"group":         groupRepo,
+		"balance":       balanceRepo,
+		"snapshot":      balanceSnapshotRepo,
+		"balance task":  balanceTaskRepo,
+		"outbox":        outboxRepo,
+		"expense":       expenseRepo,
+		"settlement":    settlementRepo,
+		"netting":       nettingRepo,
+		"notification":  notificationRepo,
+		"ledger":        ledgerRepo,
+		"suggestion":    suggestionRepo,
+		"budget":        budgetRepo,
+		"share link":    shareLinkRepo,
+		"API key":       apiKeyRepo,
+		"revision":      expenseRevisionRepo,
+		"webhook":       groupWebhookRepo,
+		"friendship":    friendshipRepo,
+		"payment event": paymentEventRepo,
+		"job":           jobRepo,
+	} {
+		if err := repo.EnsureIndexes(ctx); err != nil {
+			log.Fatalf("Failed to ensure %s indexes: %v", name, err)
+		}
+	}
+
+	// Listings and reports go by expense date, so date the expenses stored before there was one
+	if dated, err := expenseRepo.BackfillDates(ctx); err != nil {
+		log.Fatalf("Failed to backfill expense dates: %v", err)
+	} else if dated > 0 {
+		log.Printf("Dated %d expenses by their creation time", dated)
+	}
 
 	// Initialize services
 	authService := auth.NewJWTService(cfg.JWTSecret, cfg.JWTExpiration)
-	userService := services.NewUserService(userRepo)
-	groupService := services.NewGroupService(groupRepo, userRepo)
-	expenseService := services.NewExpenseService(expenseRepo, balanceRepo, groupRepo, userRepo)
-	balanceService := services.NewBalanceService(balanceRepo, expenseRepo, userRepo)
-	settlementService := services.NewSettlementService(settlementRepo, balanceRepo, userRepo)
+	tokenDenylist := auth.NewRedisDenylist(redisClient)
+	fileStore := storage.NewLocalStore(cfg.StorageDir, cfg.StorageBaseURL)
+	notificationService := services.NewNotificationService(notificationRepo, userRepo)
+	notifier := notificationService
+	emailSender := newEmailSender(cfg)
+	eventBus := services.NewEventBus()
+	jobService := services.NewJobService(jobRepo)
+	// Services publish through the group webhooks, which pass every event on to the bus, and
+	// through the read model exporter when there is one
+	groupWebhookService := services.NewGroupWebhookService(groupWebhookRepo, groupRepo)
+	events := groupWebhookService.Publisher(eventBus)
+	var expenseAggregations repositories.ExpenseAggregations = expenseRepo
+	var readModelService *services.ReadModelService
+	if cfg.ReadModelURL != "" {
+		readModelRepo := repositories.NewReadModelRepository(clickhouse.NewClient(clickhouse.Config{
+			URL:      cfg.ReadModelURL,
+			Database: cfg.ReadModelDatabase,
+			Username: cfg.ReadModelUsername,
+			Password: cfg.ReadModelPassword,
+		}))
+		if err := readModelRepo.EnsureSchema(ctx); err != nil {
+			log.Fatalf("Failed to ensure read model schema: %v", err)
+		}
+		readModelService = services.NewReadModelService(readModelRepo, expenseRepo, settlementRepo, balanceRepo, jobService)
+		events = readModelService.Publisher(events)
+		if cfg.ReadModelReports {
+			expenseAggregations = readModelRepo
+		}
+	}
+	// Expenses and settlements record their events and notifications in the outbox, with the
+	// change behind them; the outbox relay delivers them once it commits
+	outboxService := services.NewOutboxService(outboxRepo, events, notifier)
+	// Balance summaries and member lists are cached in Redis, shared by every replica
+	var aggregateCache *services.AggregateCache
+	if cfg.AggregateCacheTTL > 0 {
+		aggregateCache = services.NewAggregateCache(cache.NewRedis(redisClient, "divvydoo:aggregates:", cfg.AggregateCacheTTL))
+	}
+	userService := services.NewUserService(userRepo, groupRepo, balanceRepo, expenseRepo, settlementRepo, friendshipRepo, notificationRepo, fileStore, emailSender, aggregateCache)
+	roundingMonitor := services.NewRoundingMonitor(cfg.RoundingDriftAlertThreshold)
+	friendService := services.NewFriendService(friendshipRepo, userRepo, notifier)
+	aggregationBudget := func() time.Duration {
+		return time.Duration(runtimeConfig.Current().AggregationTimeBudgetMs) * time.Millisecond
+	}
+	budgetService := services.NewBudgetService(budgetRepo, expenseRepo, groupRepo, notifier, aggregationBudget)
+	expenseService := services.NewExpenseService(expenseRepo, balanceRepo, groupRepo, userRepo, expenseRevisionRepo, friendshipRepo, outboxService, aggregateCache, roundingMonitor, cfg.ExpenseSoftLimits, budgetService, balanceTaskRepo, cfg.BalanceUpdatesAsync)
+	// Without an exchange rate source, totals in other currencies are listed unconverted
+	rateConverter := newRateConverter(cfg, redisClient)
+	var currencyConverter services.CurrencyConverter
+	if rateConverter != nil {
+		currencyConverter = rateConverter
+	}
+	groupService := services.NewGroupService(groupRepo, userRepo, balanceRepo, settlementRepo, currencyConverter, notifier, emailSender, aggregateCache)
+	balanceService := services.NewBalanceService(balanceRepo, expenseRepo, settlementRepo, userRepo, groupRepo, currencyConverter, aggregateCache)
+	settlementService := services.NewSettlementService(
+		settlementRepo,
+		settlementAuthorizationRepo,
+		balanceRepo,
+		userRepo,
+		groupRepo,
+		outboxService,
+		aggregateCache,
+		cfg.SettlementAutoConfirmAfter,
+	)
+	// Settlements paid through a provider complete or fail as its webhooks report the payment
+	paymentWebhookService := services.NewPaymentWebhookService(settlementService, paymentEventRepo, newPaymentProviders(cfg)...)
+	backOfficeService := services.NewBackOfficeService(userRepo, groupRepo, balanceRepo, settlementService, cfg.AdminUserIDs)
+	nettingService := services.NewNettingService(nettingRepo, balanceRepo, settlementRepo, groupRepo, userRepo, notifier, events, aggregateCache)
+	maintenanceService := services.NewMaintenanceService(maintenanceRepo, jobService)
+	statsService := services.NewStatsService(statsRepo, cfg.StatsCacheTTL)
+	ledgerService := services.NewLedgerService(ledgerRepo, balanceRepo, jobService)
+	balanceSnapshotService := services.NewBalanceSnapshotService(balanceRepo, balanceSnapshotRepo)
+	reconciliationService := services.NewBalanceReconciliationService(balanceRepo, expenseRepo, settlementRepo, groupRepo, balanceTaskRepo, events, aggregateCache)
+	categoryData := backend.DefaultCategories
+	if cfg.CategoriesFile != "" {
+		if categoryData, err = os.ReadFile(cfg.CategoriesFile); err != nil {
+			log.Fatalf("Failed to read categories file: %v", err)
+		}
+	}
+	categoryService, err := services.NewCategoryService(categoryData)
+	if err != nil {
+		log.Fatalf("Failed to load categories: %v", err)
+	}
+	avatarService := services.NewAvatarService(userRepo, groupRepo, fileStore)
+	exportService := services.NewExportService(userRepo, groupRepo, expenseRepo, settlementRepo, balanceRepo, jobService, cfg.ExportDir)
+	statementService := services.NewStatementService(expenseRepo, settlementRepo, balanceRepo, groupRepo, userRepo)
+	reportService := services.NewReportService(expenseAggregations)
+	importService := services.NewImportService(userRepo, groupRepo, expenseRepo, settlementRepo, balanceRepo, expenseRevisionRepo)
+	suggestionService := services.NewSuggestionService(expenseRepo, suggestionRepo, groupRepo, userRepo)
+	apiKeyService := services.NewAPIKeyService(apiKeyRepo, userRepo)
+	shareLinkService := services.NewShareLinkService(shareLinkRepo, groupRepo, balanceRepo, expenseRepo, aggregationBudget)
+	recategorizeService := services.NewRecategorizeService(expenseRepo, expenseRevisionRepo, groupRepo, jobService, budgetService)
+	fairnessService := services.NewFairnessService(expenseAggregations, groupRepo, aggregationBudget)
+	activityService := services.NewActivityService(expenseRepo, settlementRepo, groupRepo)
+	clientErrorService := services.NewClientErrorService(clientErrorRepo, cfg.ClientErrorSampleRate)
+	backupTool := backup.NewMongoTools(backup.MongoToolsConfig{
+		URI:         cfg.MongoURI,
+		Database:    cfg.MongoDBName,
+		DumpPath:    cfg.MongodumpPath,
+		RestorePath: cfg.MongorestorePath,
+	})
+	backupService := services.NewBackupService(backupRepo, consistencyRepo, backupTool, jobService, services.BackupConfig{
+		Dir:       cfg.BackupDir,
+		Retention: cfg.BackupRetention,
+		Database:  cfg.MongoDBName,
+	})
 
 	// Initialize controllers
-	authMiddleware := middleware.NewAuthMiddleware(authService)
-	userController := controllers.NewUserController(userService, authService)
-	groupController := controllers.NewGroupController(groupService)
-	expenseController := controllers.NewExpenseController(expenseService)
-	balanceController := controllers.NewBalanceController(balanceService)
-	settlementController := controllers.NewSettlementController(settlementService)
-	docsController := controllers.NewDocsController()
+	authMiddleware := middleware.NewAuthMiddleware(authService, tokenDenylist, apiKeyService)
+	docsController := controllers.NewDocsController(backend.OpenAPISpec)
+	routes := &apiRoutes{
+		cfg:            cfg,
+		runtimeConfig:  runtimeConfig,
+		redisClient:    redisClient,
+		authMiddleware: authMiddleware,
+		adminChecker:   backOfficeService,
+		readModel:      readModelService != nil,
+
+		userController:           controllers.NewUserController(userService, authService, tokenDenylist),
+		groupController:          controllers.NewGroupController(groupService),
+		friendController:         controllers.NewFriendController(friendService),
+		expenseController:        controllers.NewExpenseController(expenseService),
+		balanceController:        controllers.NewBalanceController(balanceService, balanceSnapshotService, eventBus),
+		settlementController:     controllers.NewSettlementController(settlementService),
+		paymentWebhookController: controllers.NewPaymentWebhookController(paymentWebhookService),
+		nettingController:        controllers.NewNettingController(nettingService),
+		backOfficeController:     controllers.NewBackOfficeController(backOfficeService, authService, tokenDenylist),
+		adminController:          controllers.NewAdminController(maintenanceService, jobService, statsService, runtimeConfig),
+		clientErrorController:    controllers.NewClientErrorController(clientErrorService),
+		statementController:      controllers.NewStatementController(statementService),
+		reportController:         controllers.NewReportController(reportService),
+		exportController:         controllers.NewExportController(exportService),
+		metaController:           controllers.NewMetaController(categoryService),
+		avatarController:         controllers.NewAvatarController(avatarService),
+		importController:         controllers.NewImportController(importService),
+		activityController:       controllers.NewActivityController(activityService),
+		suggestionController:     controllers.NewSuggestionController(suggestionService),
+		budgetController:         controllers.NewBudgetController(budgetService),
+		shareLinkController:      controllers.NewShareLinkController(shareLinkService),
+		apiKeyController:         controllers.NewAPIKeyController(apiKeyService),
+		groupWebhookController:   controllers.NewGroupWebhookController(groupWebhookService),
+		recategorizeController:   controllers.NewRecategorizeController(recategorizeService),
+		fairnessController:       controllers.NewFairnessController(fairnessService),
+		notificationController:   controllers.NewNotificationController(notificationService),
+		realtimeController:       controllers.NewRealtimeController(eventBus),
+		backupController:         controllers.NewBackupController(backupService),
+		ledgerController:         controllers.NewLedgerController(ledgerService),
+		reconciliationController: controllers.NewBalanceReconciliationController(reconciliationService),
+		readModelController:      controllers.NewReadModelController(readModelService),
+	}
 
 	// Set up Gin router
-	router := gin.Default()
+	router := gin.New()
 
 	// Middleware
-	router.Use(middleware.CORS())
+	router.Use(middleware.RequestID())
+	router.Use(middleware.RequestLogger())
+	router.Use(middleware.Metrics())
+	router.Use(middleware.Recovery())
+	router.Use(middleware.CORS(middleware.CORSPolicy{
+		AllowedOrigins:   cfg.CORSAllowedOrigins,
+		AllowedMethods:   cfg.CORSAllowedMethods,
+		AllowedHeaders:   cfg.CORSAllowedHeaders,
+		AllowCredentials: cfg.CORSAllowCredentials,
+		MaxAge:           int(cfg.CORSMaxAge.Seconds()),
+	}))
+	router.Use(middleware.Locale())
 	router.Use(middleware.RequestSizeLimit(cfg.MaxRequestSize))
-	router.Use(middleware.RateLimit(cfg.RateLimitPerSecond))
-
-	// Public routes
-	public := router.Group("/v1")
-	{
-		public.POST("/login", userController.Login)
-		public.POST("/users", userController.CreateUser)
-	}
-
-	// Docs endpoints (public)
-	router.GET("/docs", docsController.GetOpenAPISpec)
-	router.GET("/docs/openapi.yaml", docsController.GetOpenAPIYAML)
-
-	// Authenticated routes
-	private := router.Group("/v1")
-	private.Use(authMiddleware.Authenticate())
-	{
-		// User routes
-		private.GET("/user-lookup", userController.LookupUser)
-		private.GET("/users/:id", userController.GetUser)
-		private.PUT("/users/:id", userController.UpdateUser)
-
-		// Group routes
-		private.GET("/groups", groupController.GetUserGroups)
-		private.POST("/groups", groupController.CreateGroup)
-		private.GET("/groups/:id", groupController.GetGroup)
-		private.GET("/groups/:id/members", groupController.GetMembers)
-		private.POST("/groups/:id/members", groupController.AddMember)
-
-		// Expense routes
-		private.POST("/expenses", expenseController.CreateExpense)
-		private.GET("/expenses/:id", expenseController.GetExpense)
-		private.GET("/groups/:id/expenses", expenseController.ListGroupExpenses)
-		private.GET("/users/:id/expenses", expenseController.ListUserExpenses)
-
-		// Balance routes
-		private.GET("/users/:id/balances", balanceController.GetUserBalances)
-		private.GET("/groups/:id/balances", balanceController.GetGroupBalances)
-
-		// Settlement routes
-		private.POST("/settlements", settlementController.CreateSettlement)
-		private.GET("/settlements/:id", settlementController.GetSettlement)
+	router.Use(middleware.AnonymousRateLimit("anonymous", func() int { return runtimeConfig.Current().RateLimitPerSecond }))
+	router.Use(middleware.Maintenance(func() bool { return runtimeConfig.Current().MaintenanceMode }, "/v1/admin", "/admin/v1", "/v1/login"))
+
+	// Uploaded files are served from here unless STORAGE_BASE_URL points elsewhere, e.g. a CDN
+	if strings.HasPrefix(cfg.StorageBaseURL, "/") {
+		router.Static(cfg.StorageBaseURL, cfg.StorageDir)
+	}
+
+	router.GET("/health", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	router.GET("/metrics", middleware.RequireToken(cfg.MetricsToken), gin.WrapH(metrics.Handler()))
+
+	// Docs endpoints
+	if cfg.DocsAccess != config.DocsDisabled {
+		docs := router.Group("/docs")
+		if cfg.DocsAccess == config.DocsAuthenticated {
+			docs.Use(authMiddleware.Authenticate())
+		}
+		docs.GET("", docsController.GetOpenAPISpec)
+		docs.GET("/openapi.yaml", docsController.GetOpenAPIYAML)
+	}
+
+	routes.register(router)
+
+	// The spec is written by hand, so check it still describes the routes above
+	undocumented, unrouted, err := docsController.CheckRoutes(router.Routes())
+	if err != nil {
+		log.Fatalf("Failed to check the OpenAPI spec: %v", err)
+	}
+	for _, route := range undocumented {
+		log.Printf("OpenAPI spec is missing route %s", route)
+	}
+	for _, operation := range unrouted {
+		log.Printf("OpenAPI spec describes %s, which no route serves", operation)
+	}
+	if cfg.DocsStrict && len(undocumented)+len(unrouted) > 0 {
+		log.Fatalf("OpenAPI spec is out of date with the routes (DOCS_STRICT is set)")
+	}
+
+	// Start background workers
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	if cfg.SettlementAutoConfirmInterval > 0 {
+		settlementWorker := worker.NewSettlementWorker(settlementService, cfg.SettlementAutoConfirmInterval, cfg.SettlementVoidRetention)
+		go settlementWorker.Start(workerCtx)
 	}
 
+	if cfg.BackupInterval > 0 {
+		backupWorker := worker.NewBackupWorker(backupService, cfg.BackupInterval, cfg.BackupVerify)
+		go backupWorker.Start(workerCtx)
+	}
+
+	if cfg.GroupSuggestionInterval > 0 {
+		suggestionWorker := worker.NewSuggestionWorker(suggestionService, cfg.GroupSuggestionInterval)
+		go suggestionWorker.Start(workerCtx)
+	}
+
+	outboxRelay := worker.NewOutboxRelay(outboxService, cfg.OutboxPollInterval)
+	go outboxRelay.Start(workerCtx)
+
+	if rateConverter != nil {
+		rateWorker := worker.NewRateRefreshWorker(rateConverter, cfg.FXRefreshInterval)
+		go rateWorker.Start(workerCtx)
+	}
+
+	// Always started: balance updates queued before a switch back to sync mode still need applying
+	balanceWorker := worker.NewBalanceWorker(expenseService, balanceSnapshotService, cfg.BalanceQueueInterval, cfg.BalanceSnapshotInterval)
+	go balanceWorker.Start(workerCtx)
+
+	if cfg.BalanceReconcileInterval > 0 {
+		reconciliationWorker := worker.NewReconciliationWorker(reconciliationService, cfg.BalanceReconcileInterval, cfg.BalanceReconcileCorrect)
+		go reconciliationWorker.Start(workerCtx)
+	}
+
+	if cfg.CacheTTL > 0 {
+		go cacheInvalidator.Run(workerCtx)
+	}
+
+	go groupWebhookService.Run(workerCtx)
+
+	go jobService.Run(workerCtx)
+
+	if readModelService != nil {
+		go readModelService.Run(workerCtx)
+	}
+
+	// Reload runtime settings on SIGHUP
+	go runtimeConfig.WatchSignals(workerCtx)
+
 	// Start server
 	srv := &http.Server{
 		Addr:    ":" + cfg.ServerPort,
 		Handler: router,
 	}
 
+	var redirectSrv *http.Server
+	if cfg.EnableTLS {
+		certs, err := tlscert.NewReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			log.Fatalf("Failed to set up TLS: %v", err)
+		}
+		srv.TLSConfig = certs.TLSConfig()
+		// Renewed certificates are picked up on SIGHUP
+		go certs.WatchSignals(workerCtx)
+
+		if cfg.HTTPRedirectPort != "" {
+			redirectSrv = &http.Server{
+				Addr:    ":" + cfg.HTTPRedirectPort,
+				Handler: httpsRedirect(cfg.ServerPort),
+			}
+			go func() {
+				if err := redirectSrv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+					log.Fatalf("Failed to start HTTP redirect server: %v", err)
+				}
+			}()
+			log.Printf("Redirecting HTTP on port %s to HTTPS", cfg.HTTPRedirectPort)
+		}
+	}
+
 	// Graceful shutdown
 	go func() {
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.EnableTLS {
+			// The certificate comes from TLSConfig
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Failed to start server: %v", err)
 		}
 	}()
 
-	log.Printf("Server started on port %s", cfg.ServerPort)
+	log.Printf("Server started on port %s (TLS: %t)", cfg.ServerPort, cfg.EnableTLS)
 
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutting down server...")
+	stopWorkers()
 
 	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
+	if redirectSrv != nil {
+		if err := redirectSrv.Shutdown(ctx); err != nil {
+			log.Printf("HTTP redirect server forced to shutdown: %v", err)
+		}
+	}
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
 
 	log.Println("Server exited properly")
 }
+
+// httpsRedirect sends plain HTTP requests to the same URL over HTTPS on httpsPort. 308 keeps
+// the method and body, so a client that posted over HTTP by mistake can follow it.
+func httpsRedirect(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		target := url.URL{Scheme: "https", Host: host, Path: r.URL.Path, RawQuery: r.URL.RawQuery}
+		http.Redirect(w, r, target.String(), http.StatusPermanentRedirect)
+	})
+}
+
+// newRateConverter sets up the exchange rate source configured by FX_PROVIDER, or returns nil
+// for none
+func newRateConverter(cfg *config.Config, redisClient *redis.Client) *fx.Converter {
+	var provider fx.RateProvider
+	switch cfg.FXProvider {
+	case config.FXProviderECB:
+		provider = fx.NewECBProvider()
+	case config.FXProviderOpenExchangeRates:
+		provider = fx.Fallback(fx.NewOpenExchangeRatesProvider(cfg.OpenExchangeRatesAppID), fx.NewECBProvider())
+	default:
+		return nil
+	}
+	return fx.NewConverter(provider, fx.NewRedisStore(redisClient), cfg.FXMaxAge)
+}
+
+// newPaymentProviders returns the payment providers whose webhooks are accepted, those with a
+// signing secret configured
+func newPaymentProviders(cfg *config.Config) []payments.Provider {
+	var providers []payments.Provider
+	if cfg.StripeWebhookSecret != "" {
+		providers = append(providers, payments.NewStripeProvider(cfg.StripeWebhookSecret))
+	}
+	if cfg.RazorpayWebhookSecret != "" {
+		providers = append(providers, payments.NewRazorpayProvider(cfg.RazorpayWebhookSecret))
+	}
+	return providers
+}
+
+// newEmailSender picks the delivery channel configured by EMAIL_PROVIDER
+func newEmailSender(cfg *config.Config) email.EmailSender {
+	from := email.Address{Name: cfg.EmailFromName, Email: cfg.EmailFrom}
+
+	switch cfg.EmailProvider {
+	case config.EmailProviderSMTP:
+		return email.NewSMTPSender(email.SMTPConfig{
+			Host:     cfg.SMTPHost,
+			Port:     cfg.SMTPPort,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     from,
+		})
+	case config.EmailProviderSendGrid:
+		return email.NewSendGridSender(cfg.SendGridAPIKey, from)
+	default:
+		return email.NewLogSender()
+	}
+}
@@ -0,0 +1,251 @@
+package main
+
+import (
+	"expvar"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"divvydoo/backend/internal/config"
+	"divvydoo/backend/internal/controllers"
+	"divvydoo/backend/internal/middleware"
+)
+
+// apiRoutes holds what the routes under /v1 and /admin/v1, the ones openapi.yaml describes, are
+// served by. They are registered apart from main so tests can check them against the spec
+// without a database.
+type apiRoutes struct {
+	cfg            *config.Config
+	runtimeConfig  *config.Runtime
+	redisClient    *redis.Client
+	authMiddleware *middleware.AuthMiddleware
+	adminChecker   middleware.AdminChecker
+	// readModel is set when a read model is configured; its backfill route only exists then
+	readModel bool
+
+	userController           *controllers.UserController
+	groupController          *controllers.GroupController
+	friendController         *controllers.FriendController
+	expenseController        *controllers.ExpenseController
+	balanceController        *controllers.BalanceController
+	settlementController     *controllers.SettlementController
+	paymentWebhookController *controllers.PaymentWebhookController
+	nettingController        *controllers.NettingController
+	backOfficeController     *controllers.BackOfficeController
+	adminController          *controllers.AdminController
+	clientErrorController    *controllers.ClientErrorController
+	statementController      *controllers.StatementController
+	reportController         *controllers.ReportController
+	exportController         *controllers.ExportController
+	metaController           *controllers.MetaController
+	avatarController         *controllers.AvatarController
+	importController         *controllers.ImportController
+	activityController       *controllers.ActivityController
+	suggestionController     *controllers.SuggestionController
+	budgetController         *controllers.BudgetController
+	shareLinkController      *controllers.ShareLinkController
+	apiKeyController         *controllers.APIKeyController
+	groupWebhookController   *controllers.GroupWebhookController
+	recategorizeController   *controllers.RecategorizeController
+	fairnessController       *controllers.FairnessController
+	notificationController   *controllers.NotificationController
+	realtimeController       *controllers.RealtimeController
+	backupController         *controllers.BackupController
+	ledgerController         *controllers.LedgerController
+	reconciliationController *controllers.BalanceReconciliationController
+	readModelController      *controllers.ReadModelController
+}
+
+// register adds the API routes to the router
+func (r *apiRoutes) register(router *gin.Engine) {
+	// Public routes
+	public := router.Group("/v1")
+	{
+		public.POST("/login", r.userController.Login)
+		public.POST("/users", r.userController.CreateUser)
+		public.GET("/meta/categories", r.metaController.GetCategories)
+		public.GET("/public/groups/:token/summary", r.shareLinkController.GetPublicSummary)
+		public.POST("/payments/webhooks/:provider", r.paymentWebhookController.ReceiveWebhook)
+	}
+
+	// Authenticated routes
+	private := router.Group("/v1")
+	private.Use(r.authMiddleware.Authenticate())
+	private.Use(middleware.UserRateLimit("user",
+		func() int { return r.runtimeConfig.Current().UserReadRateLimitPerMin },
+		func() int { return r.runtimeConfig.Current().UserWriteRateLimitPerMin },
+	))
+	idempotent := middleware.Idempotency(r.redisClient, r.cfg.IdempotencyTTL)
+	{
+		// Auth routes
+		private.POST("/auth/logout", r.userController.Logout)
+
+		// User routes
+		private.GET("/user-lookup", r.userController.LookupUser)
+		private.GET("/users/:id", r.userController.GetUser)
+		private.PUT("/users/:id", middleware.RequireUserToken(), r.userController.UpdateUser)
+		private.DELETE("/users/:id", middleware.RequireUserToken(), r.userController.DeleteUser)
+		private.POST("/users/:id/placeholders/claim-code", middleware.RequireUserToken(), r.userController.RequestPlaceholderClaim)
+		private.POST("/users/:id/placeholders/claim", middleware.RequireUserToken(), r.userController.ClaimPlaceholders)
+		private.PUT("/users/:id/avatar", r.avatarController.UploadUserAvatar)
+		private.POST("/users/:id/avatar/crop", r.avatarController.CropUserAvatar)
+		private.DELETE("/users/:id/avatar", r.avatarController.DeleteUserAvatar)
+
+		// Friend routes
+		private.GET("/friends", r.friendController.ListFriends)
+		private.DELETE("/friends/:userId", r.friendController.RemoveFriend)
+		private.POST("/friends/requests", r.friendController.SendRequest)
+		private.GET("/friends/requests", r.friendController.ListRequests)
+		private.POST("/friends/requests/:id/accept", r.friendController.AcceptRequest)
+		private.POST("/friends/requests/:id/decline", r.friendController.DeclineRequest)
+
+		// Group routes
+		private.GET("/groups", r.groupController.GetUserGroups)
+		private.GET("/users/:id/groups", r.groupController.ListUserGroups)
+		private.GET("/users/:id/group-suggestions", r.suggestionController.GetGroupSuggestions)
+		private.POST("/groups", r.groupController.CreateGroup)
+		private.GET("/groups/:id", r.groupController.GetGroup)
+		private.PUT("/groups/:id", r.groupController.UpdateGroup)
+		private.DELETE("/groups/:id", r.groupController.ArchiveGroup)
+		private.POST("/groups/:id/archive", r.groupController.ArchiveGroup)
+		private.POST("/groups/:id/unarchive", r.groupController.UnarchiveGroup)
+		private.GET("/groups/:id/members", r.groupController.GetMembers)
+		private.POST("/groups/:id/members", r.groupController.AddMember)
+		private.DELETE("/groups/:id/members/:memberId", r.groupController.RemoveMember)
+		private.PATCH("/groups/:id/members/:memberId/role", r.groupController.UpdateMemberRole)
+		private.POST("/groups/:id/leave", r.groupController.LeaveGroup)
+		private.PATCH("/groups/:id/settings", r.groupController.UpdateSettings)
+		private.POST("/groups/:id/currency", r.groupController.ChangeCurrency)
+		private.GET("/groups/:id/budget", r.budgetController.GetBudget)
+		private.PUT("/groups/:id/budget", r.budgetController.SetBudget)
+		private.DELETE("/groups/:id/budget", r.budgetController.DeleteBudget)
+		private.GET("/groups/:id/fairness", r.fairnessController.GetFairness)
+		private.POST("/groups/:id/share-links", r.shareLinkController.CreateShareLink)
+		private.GET("/groups/:id/share-links", r.shareLinkController.ListShareLinks)
+		private.DELETE("/groups/:id/share-links/:linkId", r.shareLinkController.RevokeShareLink)
+		private.GET("/groups/:id/webhook", r.groupWebhookController.GetWebhook)
+		private.PUT("/groups/:id/webhook", r.groupWebhookController.SetWebhook)
+		private.DELETE("/groups/:id/webhook", r.groupWebhookController.DeleteWebhook)
+		private.POST("/groups/:id/webhook/test", r.groupWebhookController.TestWebhook)
+		private.PUT("/groups/:id/avatar", r.avatarController.UploadGroupAvatar)
+		private.POST("/groups/:id/avatar/crop", r.avatarController.CropGroupAvatar)
+		private.DELETE("/groups/:id/avatar", r.avatarController.DeleteGroupAvatar)
+
+		// Expense routes
+		private.POST("/expenses", idempotent, r.expenseController.CreateExpense)
+		private.GET("/expenses/search", r.expenseController.SearchExpenses)
+		private.POST("/expenses/batch-get", r.expenseController.BatchGetExpenses)
+		private.GET("/expenses/:id", r.expenseController.GetExpense)
+		private.PATCH("/expenses/:id", r.expenseController.UpdateExpense)
+		private.GET("/expenses/:id/history", r.expenseController.GetExpenseHistory)
+		private.POST("/expenses/:id/approve", r.expenseController.ApproveExpense)
+		private.POST("/expenses/:id/reject", r.expenseController.RejectExpense)
+		private.GET("/groups/:id/expenses", r.expenseController.ListGroupExpenses)
+		private.GET("/groups/:id/expenses/export", r.expenseController.ExportGroupExpenses)
+		private.POST("/groups/:id/expenses/import", r.expenseController.ImportGroupExpenses)
+		private.POST("/groups/:id/expenses/recategorize", r.recategorizeController.RecategorizeExpenses)
+		private.GET("/groups/:id/expenses/recategorize/:jobId", r.recategorizeController.GetRecategorization)
+		private.GET("/users/:id/expenses", r.expenseController.ListUserExpenses)
+
+		// Balance routes
+		private.GET("/users/:id/balances", r.balanceController.GetUserBalances)
+		private.GET("/users/:id/balances/stream", r.balanceController.StreamUserBalances)
+		private.GET("/users/:id/balance-history", r.balanceController.ListBalanceHistory)
+		private.GET("/users/:id/balances/history", r.balanceController.GetBalancesHistory)
+		private.GET("/users/:id/friends/:friendId/balance", r.balanceController.GetFriendBalance)
+		private.GET("/groups/:id/balances", r.balanceController.GetGroupBalances)
+
+		// Settlement routes
+		private.POST("/settlements", idempotent, r.settlementController.CreateSettlement)
+		private.POST("/settlements/settle-up", idempotent, r.settlementController.SettleUp)
+		private.GET("/settlements/pending", r.settlementController.GetPendingSettlements)
+		private.GET("/settlements/:id", r.settlementController.GetSettlement)
+		private.GET("/settlements/:id/receipt", r.settlementController.GetSettlementReceipt)
+		private.GET("/users/:id/settlements", r.settlementController.ListUserSettlements)
+		private.GET("/users/:id/settlements/export", r.settlementController.ExportUserSettlements)
+		private.GET("/groups/:id/settlements", r.settlementController.ListGroupSettlements)
+		private.POST("/groups/:id/settle-all", idempotent, r.settlementController.SettleAll)
+		private.POST("/settlements/:id/complete", r.settlementController.CompleteSettlement)
+		private.POST("/settlements/:id/confirm", r.settlementController.ConfirmSettlement)
+		private.POST("/settlements/:id/reject", r.settlementController.RejectSettlement)
+		private.POST("/settlements/:id/cancel", r.settlementController.CancelSettlement)
+		private.POST("/settlements/:id/void", r.settlementController.VoidSettlement)
+		private.GET("/settlements/authorizations", r.settlementController.GetPayerAuthorizations)
+		private.PUT("/settlements/authorizations/:payerId", r.settlementController.AuthorizePayer)
+		private.DELETE("/settlements/authorizations/:payerId", r.settlementController.RevokePayerAuthorization)
+
+		// Cross-group netting routes
+		private.POST("/nettings/preview", r.nettingController.PreviewNetting)
+		private.POST("/nettings", idempotent, r.nettingController.CreateNetting)
+		private.GET("/nettings", r.nettingController.ListNettings)
+
+		// Statement routes
+		private.GET("/groups/:id/statements/:month", r.statementController.GetGroupStatement)
+		private.GET("/users/:id/statements/:month", r.statementController.GetUserStatement)
+		private.GET("/users/:id/reports/monthly", r.reportController.GetMonthlyReport)
+
+		// Data export (takeout)
+		private.POST("/users/:id/export", r.exportController.StartExport)
+		private.GET("/users/:id/export/:jobId", r.exportController.GetExport)
+		private.GET("/users/:id/export/:jobId/download", r.exportController.DownloadExport)
+
+		// Activity routes
+		private.GET("/users/:id/activity", r.activityController.ListUserActivity)
+
+		// API key routes; keys can't manage keys
+		private.POST("/api-keys", middleware.RequireUserToken(), r.apiKeyController.CreateAPIKey)
+		private.GET("/api-keys", middleware.RequireUserToken(), r.apiKeyController.ListAPIKeys)
+		private.DELETE("/api-keys/:id", middleware.RequireUserToken(), r.apiKeyController.RevokeAPIKey)
+
+		// Notification routes
+		private.GET("/notifications", r.notificationController.ListNotifications)
+		private.POST("/notifications/:id/read", r.notificationController.MarkRead)
+
+		// Real-time updates
+		private.GET("/ws", r.realtimeController.Connect)
+
+		// Import routes
+		private.POST("/import/splitwise", r.importController.ImportSplitwise)
+
+		// Client error reporting
+		private.POST("/client-errors", middleware.UserRouteRateLimit("client_errors", func() int { return r.runtimeConfig.Current().ClientErrorRateLimitPerSec }), r.clientErrorController.ReportError)
+	}
+
+	// Admin routes
+	admin := private.Group("/admin")
+	admin.Use(middleware.RequireUserToken(), middleware.RequireAdmin(r.cfg.AdminUserIDs))
+	{
+		admin.POST("/maintenance/:operation", r.adminController.StartMaintenance)
+		admin.GET("/jobs", r.adminController.ListJobs)
+		admin.GET("/jobs/:id", r.adminController.GetJob)
+		admin.GET("/stats", r.adminController.GetStats)
+		admin.GET("/metrics", gin.WrapH(expvar.Handler()))
+		admin.GET("/config", r.adminController.GetRuntimeConfig)
+		admin.POST("/config/reload", r.adminController.ReloadRuntimeConfig)
+		admin.GET("/backups", r.backupController.ListBackups)
+		admin.POST("/backups", r.backupController.StartBackup)
+		admin.POST("/backups/:id/verify", r.backupController.VerifyBackup)
+		admin.POST("/ledger/compare", r.ledgerController.StartComparison)
+		admin.POST("/groups/:id/recompute-balances", r.reconciliationController.RecomputeBalances)
+		if r.readModel {
+			admin.POST("/read-model/backfill", r.readModelController.StartBackfill)
+		}
+	}
+
+	// Back office, for support staff with the admin role
+	backOffice := router.Group("/admin/v1")
+	backOffice.Use(r.authMiddleware.Authenticate(), middleware.RequireUserToken(), middleware.RequireAdminRole(r.adminChecker))
+	backOffice.Use(middleware.UserRateLimit("back_office",
+		func() int { return r.runtimeConfig.Current().UserReadRateLimitPerMin },
+		func() int { return r.runtimeConfig.Current().UserWriteRateLimitPerMin },
+	))
+	{
+		backOffice.GET("/users", r.backOfficeController.SearchUsers)
+		backOffice.GET("/users/:id", r.backOfficeController.GetUser)
+		backOffice.POST("/users/:id/disable", r.backOfficeController.DisableUser)
+		backOffice.POST("/users/:id/enable", r.backOfficeController.EnableUser)
+		backOffice.PUT("/users/:id/role", r.backOfficeController.SetUserRole)
+		backOffice.GET("/groups/:id/balances", r.backOfficeController.GetGroupBalances)
+		backOffice.POST("/settlements/:id/cancel", r.backOfficeController.ForceCancelSettlement)
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"testing"
+
+	"divvydoo/backend"
+	"divvydoo/backend/internal/config"
+	"divvydoo/backend/internal/controllers"
+	"divvydoo/backend/internal/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The spec is written by hand; this keeps a route change from merging without its spec change.
+// Handlers are only registered, never called, so the controllers can be left out.
+func TestOpenAPISpecMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	routes := &apiRoutes{cfg: &config.Config{}, readModel: true}
+	routes.register(router)
+
+	undocumented, unrouted, err := controllers.NewDocsController(backend.OpenAPISpec).CheckRoutes(router.Routes())
+	if err != nil {
+		t.Fatalf("CheckRoutes: %v", err)
+	}
+	for _, route := range undocumented {
+		t.Errorf("openapi.yaml is missing route %s", route)
+	}
+	for _, operation := range unrouted {
+		t.Errorf("openapi.yaml describes %s, which no route serves", operation)
+	}
+}
+
+// The read scope names its extra routes by path, so a moved route would quietly need write again
+func TestReadScopeRoutesExist(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	routes := &apiRoutes{cfg: &config.Config{}, readModel: true}
+	routes.register(router)
+
+	registered := make(map[string]bool)
+	for _, route := range router.Routes() {
+		registered[route.Method+" "+route.Path] = true
+	}
+	for _, route := range middleware.ReadScopeRoutes() {
+		if !registered[route] {
+			t.Errorf("read scope route %s is not registered", route)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+// Command doctor checks that the API can start in the current environment: configuration,
+// MongoDB (including transaction support and indexes), Redis and the JWT secret. It prints a
+// readiness report and exits non-zero if any check fails, so deploy pipelines can gate on it.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"divvydoo/backend/internal/config"
+	"divvydoo/backend/internal/repositories"
+)
+
+// minJWTSecretLength is 32 bytes, the HS256 key size
+const minJWTSecretLength = 32
+
+type status string
+
+const (
+	statusOK   status = "OK"
+	statusWarn status = "WARN"
+	statusFail status = "FAIL"
+)
+
+type report struct {
+	failed bool
+}
+
+func (r *report) add(s status, check string, detail string) {
+	if s == statusFail {
+		r.failed = true
+	}
+	fmt.Printf("[%-4s] %-22s %s\n", s, check, detail)
+}
+
+func main() {
+	timeout := flag.Duration("timeout", 10*time.Second, "timeout for each connectivity check")
+	flag.Parse()
+
+	cfg := config.LoadConfig()
+	r := &report{}
+
+	checkConfig(r, cfg)
+	checkJWTSecret(r, cfg.JWTSecret)
+	checkRedis(r, cfg, *timeout)
+	checkMongo(r, cfg, *timeout)
+
+	if r.failed {
+		fmt.Println("\nNot ready: fix the failed checks above")
+		os.Exit(1)
+	}
+	fmt.Println("\nReady")
+}
+
+func checkConfig(r *report, cfg *config.Config) {
+	if _, err := config.NewRuntime(config.EnvFile); err != nil {
+		r.add(statusFail, "runtime settings", err.Error())
+	} else {
+		r.add(statusOK, "runtime settings", "valid")
+	}
+
+	if cfg.EnableTLS {
+		for _, file := range []string{cfg.TLSCertFile, cfg.TLSKeyFile} {
+			if _, err := os.Stat(file); err != nil {
+				r.add(statusFail, "tls", fmt.Sprintf("cannot read %q: %v", file, err))
+				return
+			}
+		}
+		r.add(statusOK, "tls", "certificate and key found")
+	}
+
+	if len(cfg.AdminUserIDs) == 0 {
+		r.add(statusWarn, "admin users", "ADMIN_USER_IDS is empty, admin endpoints are unusable")
+	} else {
+		r.add(statusOK, "admin users", fmt.Sprintf("%d configured", len(cfg.AdminUserIDs)))
+	}
+
+	checkEmail(r, cfg)
+}
+
+func checkEmail(r *report, cfg *config.Config) {
+	switch cfg.EmailProvider {
+	case config.EmailProviderSMTP:
+		if cfg.SMTPHost == "" {
+			r.add(statusFail, "email", "EMAIL_PROVIDER=smtp but SMTP_HOST is empty")
+			return
+		}
+	case config.EmailProviderSendGrid:
+		if cfg.SendGridAPIKey == "" {
+			r.add(statusFail, "email", "EMAIL_PROVIDER=sendgrid but SENDGRID_API_KEY is empty")
+			return
+		}
+	case config.EmailProviderLog:
+		r.add(statusWarn, "email", "EMAIL_PROVIDER=log, email is only logged")
+		return
+	}
+	r.add(statusOK, "email", string(cfg.EmailProvider))
+}
+
+func checkJWTSecret(r *report, secret string) {
+	switch {
+	case secret == "default-secret-key":
+		r.add(statusFail, "jwt secret", "JWT_SECRET is unset and the built-in default is in use")
+	case len(secret) < minJWTSecretLength:
+		r.add(statusFail, "jwt secret", fmt.Sprintf("JWT_SECRET is %d bytes, use at least %d", len(secret), minJWTSecretLength))
+	case distinctBytes(secret) < 10:
+		r.add(statusFail, "jwt secret", "JWT_SECRET has too little variety to be random")
+	default:
+		r.add(statusOK, "jwt secret", fmt.Sprintf("%d bytes", len(secret)))
+	}
+}
+
+func distinctBytes(s string) int {
+	seen := make(map[byte]bool)
+	for i := 0; i < len(s); i++ {
+		seen[s[i]] = true
+	}
+	return len(seen)
+}
+
+func checkRedis(r *report, cfg *config.Config, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+	defer client.Close()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		r.add(statusFail, "redis", fmt.Sprintf("%s: %v", cfg.RedisAddr, err))
+		return
+	}
+	r.add(statusOK, "redis", cfg.RedisAddr)
+}
+
+func checkMongo(r *report, cfg *config.Config, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoURI).SetServerSelectionTimeout(timeout))
+	if err != nil {
+		r.add(statusFail, "mongodb", err.Error())
+		return
+	}
+	defer client.Disconnect(context.Background())
+
+	if err := client.Ping(ctx, nil); err != nil {
+		r.add(statusFail, "mongodb", err.Error())
+		return
+	}
+	r.add(statusOK, "mongodb", "connected")
+
+	db := client.Database(cfg.MongoDBName)
+	checkTransactions(ctx, r, client, db)
+
+	missing, err := repositories.MissingIndexes(ctx, db)
+	switch {
+	case err != nil:
+		r.add(statusFail, "indexes", err.Error())
+	case len(missing) > 0:
+		// The API creates them on startup, so a fresh database isn't a failure
+		r.add(statusWarn, "indexes", "missing (created when the API starts): "+strings.Join(missing, ", "))
+	default:
+		r.add(statusOK, "indexes", "all present")
+	}
+}
+
+// checkTransactions confirms the deployment is a replica set or sharded cluster, which expense
+// and settlement writes need for multi-document transactions, then runs a read-only transaction.
+func checkTransactions(ctx context.Context, r *report, client *mongo.Client, db *mongo.Database) {
+	var hello bson.M
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		r.add(statusFail, "transactions", fmt.Sprintf("hello failed: %v", err))
+		return
+	}
+	if _, ok := hello["setName"]; !ok && hello["msg"] != "isdbgrid" {
+		r.add(statusFail, "transactions", "MongoDB is a standalone server; run it as a replica set")
+		return
+	}
+
+	session, err := client.StartSession()
+	if err != nil {
+		r.add(statusFail, "transactions", err.Error())
+		return
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		err := db.Collection("users").FindOne(sessCtx, bson.M{}).Err()
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+		return nil, err
+	})
+	if err != nil {
+		r.add(statusFail, "transactions", err.Error())
+		return
+	}
+	r.add(statusOK, "transactions", "supported")
+}
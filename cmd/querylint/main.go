@@ -0,0 +1,437 @@
+// Command querylint runs the repositories' read queries against a scratch database that has only
+// the declared indexes, explains each command MongoDB receives and fails if any plan scans a whole
+// collection. Run it in CI against a disposable MongoDB so a query pattern that outgrows the
+// indexes is caught before the list endpoints slow down.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/event"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"divvydoo/backend/internal/config"
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+)
+
+const pageSize = 20
+
+// linted are the commands that read with a query plan; writes go through the same filters as the
+// reads listed below
+var linted = map[string]bool{
+	"find":      true,
+	"aggregate": true,
+	"count":     true,
+	"distinct":  true,
+}
+
+// query is one repository call; a call may send several commands
+type query struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// recorder keeps the commands sent while a query runs
+type recorder struct {
+	mu       sync.Mutex
+	active   bool
+	commands []bson.Raw
+}
+
+func (r *recorder) monitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			if !linted[e.CommandName] {
+				return
+			}
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.active {
+				r.commands = append(r.commands, append(bson.Raw(nil), e.Command...))
+			}
+		},
+	}
+}
+
+func (r *recorder) start() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active = true
+	r.commands = nil
+}
+
+func (r *recorder) stop() []bson.Raw {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.active = false
+	return r.commands
+}
+
+func main() {
+	timeout := flag.Duration("timeout", 30*time.Second, "timeout for the whole run")
+	flag.Parse()
+
+	os.Exit(run(config.LoadConfig(), *timeout))
+}
+
+// run returns the exit code, after the deferred cleanup has dropped the scratch database
+func run(cfg *config.Config, timeout time.Duration) int {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rec := &recorder{}
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoURI).SetMonitor(rec.monitor()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to connect to MongoDB: %v\n", err)
+		return 1
+	}
+	defer client.Disconnect(context.Background())
+
+	// A scratch database keeps real data and ad hoc indexes from changing the plans
+	db := client.Database(cfg.MongoDBName + "_querylint")
+	if err := db.Drop(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to reset %s: %v\n", db.Name(), err)
+		return 1
+	}
+	defer db.Drop(context.Background())
+
+	failed, err := lint(ctx, db, rec)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Query lint failed: %v\n", err)
+		return 1
+	}
+	if failed {
+		fmt.Println("\nCollection scans found: add an index or change the query")
+		return 1
+	}
+	fmt.Println("\nAll queries use an index")
+	return 0
+}
+
+func lint(ctx context.Context, db *mongo.Database, rec *recorder) (bool, error) {
+	userRepo := repositories.NewUserRepository(db)
+	groupRepo := repositories.NewGroupRepository(db)
+	balanceRepo := repositories.NewBalanceRepository(db)
+	balanceSnapshotRepo := repositories.NewBalanceSnapshotRepository(db)
+	balanceTaskRepo := repositories.NewBalanceTaskRepository(db)
+	outboxRepo := repositories.NewOutboxRepository(db)
+	expenseRepo := repositories.NewExpenseRepository(db)
+	settlementRepo := repositories.NewSettlementRepository(db)
+	nettingRepo := repositories.NewNettingRepository(db)
+	notificationRepo := repositories.NewNotificationRepository(db)
+	ledgerRepo := repositories.NewLedgerRepository(db)
+	suggestionRepo := repositories.NewSuggestionRepository(db)
+	budgetRepo := repositories.NewBudgetRepository(db)
+	shareLinkRepo := repositories.NewShareLinkRepository(db)
+	apiKeyRepo := repositories.NewAPIKeyRepository(db)
+	revisionRepo := repositories.NewExpenseRevisionRepository(db)
+	webhookRepo := repositories.NewGroupWebhookRepository(db)
+	friendshipRepo := repositories.NewFriendshipRepository(db)
+	jobRepo := repositories.NewJobRepository(db)
+
+	// The same set the API creates on startup
+	for name, repo := range map[string]interface{ EnsureIndexes(context.Context) error }{
+		"user":         userRepo,
+		"group":        groupRepo,
+		"balance":      balanceRepo,
+		"snapshot":     balanceSnapshotRepo,
+		"balance task": balanceTaskRepo,
+		"outbox":       outboxRepo,
+		"expense":      expenseRepo,
+		"settlement":   settlementRepo,
+		"netting":      nettingRepo,
+		"notification": notificationRepo,
+		"ledger":       ledgerRepo,
+		"suggestion":   suggestionRepo,
+		"budget":       budgetRepo,
+		"share link":   shareLinkRepo,
+		"API key":      apiKeyRepo,
+		"revision":     revisionRepo,
+		"webhook":      webhookRepo,
+		"friendship":   friendshipRepo,
+		"job":          jobRepo,
+	} {
+		if err := repo.EnsureIndexes(ctx); err != nil {
+			return false, fmt.Errorf("failed to ensure %s indexes: %v", name, err)
+		}
+	}
+
+	userID, groupID := "querylint-user", "querylint-group"
+	now := time.Now()
+	monthAgo := now.AddDate(0, -1, 0)
+	nextPage := &repositories.Cursor{CreatedAt: now, ID: primitive.NewObjectID()}
+	currency := "USD"
+	types := []models.BalanceChangeType{models.BalanceChangeExpense}
+	statuses := []models.SettlementStatus{models.SettlementPending}
+
+	queries := []query{
+		{"users.GetByID", func(ctx context.Context) error { _, err := userRepo.GetByID(ctx, userID); return err }},
+		{"users.GetByEmail", func(ctx context.Context) error { _, err := userRepo.GetByEmail(ctx, "lint@example.com"); return err }},
+		{"users.GetByIDs", func(ctx context.Context) error { _, err := userRepo.GetByIDs(ctx, []string{userID}); return err }},
+		{"users.GetPlaceholdersByClaimEmail", func(ctx context.Context) error {
+			_, err := userRepo.GetPlaceholdersByClaimEmail(ctx, "lint@example.com")
+			return err
+		}},
+		{"users.GetPlaceholdersByClaimToken", func(ctx context.Context) error {
+			_, err := userRepo.GetPlaceholdersByClaimToken(ctx, "lint")
+			return err
+		}},
+		{"users.ExistMultiple", func(ctx context.Context) error { _, err := userRepo.ExistMultiple(ctx, []string{userID}); return err }},
+		{"users.Search", func(ctx context.Context) error { _, err := userRepo.Search(ctx, "lint", pageSize, 0); return err }},
+
+		{"groups.GetByID", func(ctx context.Context) error { _, err := groupRepo.GetByID(ctx, groupID); return err }},
+		{"groups.GetByUserID", func(ctx context.Context) error { _, err := groupRepo.GetByUserID(ctx, userID); return err }},
+		{"groups.IsMember", func(ctx context.Context) error { _, err := groupRepo.IsMember(ctx, groupID, userID); return err }},
+		{"groups.SharedGroupIDs", func(ctx context.Context) error {
+			_, err := groupRepo.SharedGroupIDs(ctx, userID, userID)
+			return err
+		}},
+		{"groups.GetMembersWithDetails", func(ctx context.Context) error {
+			_, err := groupRepo.GetMembersWithDetails(ctx, groupID)
+			return err
+		}},
+
+		{"expenses.ListByGroupID", func(ctx context.Context) error {
+			_, _, err := expenseRepo.ListByGroupID(ctx, groupID, "", nil, pageSize, 0)
+			return err
+		}},
+		{"expenses.ListByGroupID next page", func(ctx context.Context) error {
+			_, _, err := expenseRepo.ListByGroupID(ctx, groupID, "", nextPage, pageSize, 0)
+			return err
+		}},
+		{"expenses.ListByGroupID query", func(ctx context.Context) error {
+			_, _, err := expenseRepo.ListByGroupID(ctx, groupID, "dinner", nil, pageSize, 0)
+			return err
+		}},
+		{"expenses.ListByUserID", func(ctx context.Context) error {
+			_, _, err := expenseRepo.ListByUserID(ctx, userID, nil, pageSize, 0)
+			return err
+		}},
+		{"expenses.Search", func(ctx context.Context) error {
+			_, _, err := expenseRepo.Search(ctx, repositories.ExpenseSearchFilter{
+				UserID:          userID,
+				VisibleGroupIDs: []string{groupID},
+				Currency:        &currency,
+				From:            &monthAgo,
+				Limit:           pageSize,
+			})
+			return err
+		}},
+		{"expenses.GetInPeriod", func(ctx context.Context) error {
+			_, err := expenseRepo.GetInPeriod(ctx, &groupID, nil, monthAgo, now)
+			return err
+		}},
+		{"expenses.SumByCategoryInPeriod", func(ctx context.Context) error {
+			_, err := expenseRepo.SumByCategoryInPeriod(ctx, groupID, monthAgo, now)
+			return err
+		}},
+		{"expenses.SumUserSharesByCategoryInPeriod", func(ctx context.Context) error {
+			_, err := expenseRepo.SumUserSharesByCategoryInPeriod(ctx, userID, monthAgo, now)
+			return err
+		}},
+		{"expenses.CountMatching", func(ctx context.Context) error {
+			_, err := expenseRepo.CountMatching(ctx, repositories.ExpenseMatch{GroupID: groupID, Title: "uber", From: &monthAgo})
+			return err
+		}},
+		{"expenses.SumPaidAndConsumedInPeriod", func(ctx context.Context) error {
+			_, err := expenseRepo.SumPaidAndConsumedInPeriod(ctx, groupID, monthAgo, now)
+			return err
+		}},
+		{"expenses.CountByGroupID", func(ctx context.Context) error { _, err := expenseRepo.CountByGroupID(ctx, groupID); return err }},
+
+		{"expense_revisions.ListByExpenseID", func(ctx context.Context) error {
+			_, err := revisionRepo.ListByExpenseID(ctx, "querylint-expense")
+			return err
+		}},
+
+		{"balances.GetByUserID", func(ctx context.Context) error { _, err := balanceRepo.GetByUserID(ctx, userID); return err }},
+		{"balances.GetByGroupID", func(ctx context.Context) error { _, err := balanceRepo.GetByGroupID(ctx, groupID); return err }},
+		{"balances.GetUserBalanceSummary", func(ctx context.Context) error {
+			_, err := balanceRepo.GetUserBalanceSummary(ctx, userID)
+			return err
+		}},
+		{"balances.ListBalanceHistory", func(ctx context.Context) error {
+			_, _, err := balanceRepo.ListBalanceHistory(ctx, userID, &groupID, types, nextPage, pageSize, 0)
+			return err
+		}},
+		{"balances.GetGroupIDs", func(ctx context.Context) error { _, err := balanceRepo.GetGroupIDs(ctx); return err }},
+		{"balance_history.SumGroupHistory", func(ctx context.Context) error {
+			_, err := balanceRepo.SumGroupHistory(ctx, groupID, []models.BalanceChangeType{models.BalanceChangeAdjustment, models.BalanceChangeNetting})
+			return err
+		}},
+		{"balance_snapshots.GetByUserID", func(ctx context.Context) error {
+			_, err := balanceSnapshotRepo.GetByUserID(ctx, userID, &groupID, "2026-01-01", "2026-12-31")
+			return err
+		}},
+		{"balance_tasks.CountPendingByGroupID", func(ctx context.Context) error {
+			_, err := balanceTaskRepo.CountPendingByGroupID(ctx, groupID)
+			return err
+		}},
+		{"direct_balances.GetDirectBalances", func(ctx context.Context) error {
+			_, err := balanceRepo.GetDirectBalances(ctx, userID, nil)
+			return err
+		}},
+		{"direct_balances.GetDirectBalances pair", func(ctx context.Context) error {
+			otherID := "querylint-friend"
+			_, err := balanceRepo.GetDirectBalances(ctx, userID, &otherID)
+			return err
+		}},
+
+		{"settlements.ListByUserID", func(ctx context.Context) error {
+			_, _, err := settlementRepo.ListByUserID(ctx, userID, nil, nil, pageSize, 0)
+			return err
+		}},
+		{"settlements.ListByUserID status", func(ctx context.Context) error {
+			_, _, err := settlementRepo.ListByUserID(ctx, userID, statuses, nextPage, pageSize, 0)
+			return err
+		}},
+		{"settlements.ListByGroupID", func(ctx context.Context) error {
+			_, _, err := settlementRepo.ListByGroupID(ctx, groupID, nil, nil, pageSize, 0)
+			return err
+		}},
+		{"settlements.GetPendingSettlements", func(ctx context.Context) error {
+			_, err := settlementRepo.GetPendingSettlements(ctx, userID)
+			return err
+		}},
+		{"settlements.GetDueForAutoConfirm", func(ctx context.Context) error {
+			_, err := settlementRepo.GetDueForAutoConfirm(ctx, now, pageSize)
+			return err
+		}},
+
+		{"notifications.ListByUserID", func(ctx context.Context) error {
+			_, _, err := notificationRepo.ListByUserID(ctx, userID, false, nil, pageSize, 0)
+			return err
+		}},
+		{"notifications.ListByUserID unread", func(ctx context.Context) error {
+			_, _, err := notificationRepo.ListByUserID(ctx, userID, true, nextPage, pageSize, 0)
+			return err
+		}},
+
+		{"nettings.ListByUserID", func(ctx context.Context) error { _, err := nettingRepo.ListByUserID(ctx, userID, pageSize); return err }},
+		{"ledger.GetTotalsByGroupID", func(ctx context.Context) error { _, err := ledgerRepo.GetTotalsByGroupID(ctx, groupID); return err }},
+		{"suggestions.GetByUserID", func(ctx context.Context) error { _, err := suggestionRepo.GetByUserID(ctx, userID); return err }},
+		{"budgets.GetByGroupID", func(ctx context.Context) error { _, err := budgetRepo.GetByGroupID(ctx, groupID); return err }},
+		{"share_links.GetByTokenHash", func(ctx context.Context) error { _, err := shareLinkRepo.GetByTokenHash(ctx, "0"); return err }},
+		{"share_links.ListActiveByGroupID", func(ctx context.Context) error {
+			_, err := shareLinkRepo.ListActiveByGroupID(ctx, groupID, now)
+			return err
+		}},
+		{"api_keys.GetBySecretHash", func(ctx context.Context) error { _, err := apiKeyRepo.GetBySecretHash(ctx, "0"); return err }},
+		{"api_keys.ListByUserID", func(ctx context.Context) error { _, err := apiKeyRepo.ListByUserID(ctx, userID); return err }},
+		{"group_webhooks.GetByGroupID", func(ctx context.Context) error { _, err := webhookRepo.GetByGroupID(ctx, groupID); return err }},
+		{"friendships.GetByID", func(ctx context.Context) error {
+			_, err := friendshipRepo.GetByID(ctx, "querylint-friendship")
+			return err
+		}},
+		{"friendships.GetBetween", func(ctx context.Context) error {
+			_, err := friendshipRepo.GetBetween(ctx, userID, "querylint-friend")
+			return err
+		}},
+		{"friendships.ListByUserID", func(ctx context.Context) error {
+			_, err := friendshipRepo.ListByUserID(ctx, userID, models.FriendshipPending)
+			return err
+		}},
+		{"friendships.GetFriendIDs", func(ctx context.Context) error { _, err := friendshipRepo.GetFriendIDs(ctx, userID); return err }},
+	}
+
+	failed := false
+	for _, q := range queries {
+		rec.start()
+		// The collections are empty, so not-found errors are expected; only the plans matter
+		runErr := q.run(ctx)
+		commands := rec.stop()
+		if ctx.Err() != nil {
+			return false, ctx.Err()
+		}
+		if len(commands) == 0 {
+			if runErr != nil {
+				return false, fmt.Errorf("%s: %v", q.name, runErr)
+			}
+			fmt.Printf("[%-4s] %-40s %s\n", "SKIP", q.name, "sent no read commands")
+			continue
+		}
+
+		var scans []string
+		for _, command := range commands {
+			collections, err := collectionScans(ctx, db, command)
+			if err != nil {
+				return false, fmt.Errorf("%s: %v", q.name, err)
+			}
+			scans = append(scans, collections...)
+		}
+		if len(scans) > 0 {
+			failed = true
+			fmt.Printf("[%-4s] %-40s COLLSCAN on %s\n", "FAIL", q.name, strings.Join(scans, ", "))
+			continue
+		}
+		fmt.Printf("[%-4s] %-40s %d command(s) use an index\n", "OK", q.name, len(commands))
+	}
+	return failed, nil
+}
+
+// collectionScans explains command and returns the namespaces its plan scans in full
+func collectionScans(ctx context.Context, db *mongo.Database, command bson.Raw) ([]string, error) {
+	var doc bson.D
+	if err := bson.Unmarshal(command, &doc); err != nil {
+		return nil, err
+	}
+
+	// Session, cluster time and other envelope fields aren't allowed inside explain
+	explained := make(bson.D, 0, len(doc))
+	for _, elem := range doc {
+		if strings.HasPrefix(elem.Key, "$") || elem.Key == "lsid" || elem.Key == "txnNumber" {
+			continue
+		}
+		explained = append(explained, elem)
+	}
+
+	var plan bson.M
+	err := db.RunCommand(ctx, bson.D{
+		{Key: "explain", Value: explained},
+		{Key: "verbosity", Value: "queryPlanner"},
+	}).Decode(&plan)
+	if err != nil {
+		return nil, fmt.Errorf("explain %s: %v", doc[0].Key, err)
+	}
+
+	found := make(map[string]bool)
+	findScans(plan, "", found)
+	scans := make([]string, 0, len(found))
+	for ns := range found {
+		scans = append(scans, ns)
+	}
+	sort.Strings(scans)
+	return scans, nil
+}
+
+// findScans walks an explain document for COLLSCAN stages. Plans nest differently per command and
+// query engine, so every sub-document is searched and the nearest namespace is reported.
+func findScans(value interface{}, ns string, found map[string]bool) {
+	switch v := value.(type) {
+	case bson.M:
+		if namespace, ok := v["namespace"].(string); ok {
+			ns = namespace
+		}
+		if v["stage"] == "COLLSCAN" {
+			found[ns] = true
+		}
+		for _, child := range v {
+			findScans(child, ns, found)
+		}
+	case bson.A:
+		for _, child := range v {
+			findScans(child, ns, found)
+		}
+	}
+}
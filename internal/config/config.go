@@ -3,45 +3,245 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	ServerPort         string
-	MongoURI           string
-	MongoDBName        string
-	JWTSecret          string
-	JWTExpiration      time.Duration
-	RedisAddr          string
-	RedisPassword      string
-	RedisDB            int
-	EnableTLS          bool
-	TLSCertFile        string
-	TLSKeyFile         string
-	WorkerPoolSize     int
-	MaxRequestSize     int64
-	RateLimitPerSecond int
+	ServerPort     string
+	MongoURI       string
+	MongoDBName    string
+	JWTSecret      string
+	JWTExpiration  time.Duration
+	RedisAddr      string
+	RedisPassword  string
+	RedisDB        int
+	EnableTLS      bool
+	TLSCertFile    string
+	TLSKeyFile     string
+	WorkerPoolSize int
+	MaxRequestSize int64
+	AdminUserIDs   []string
+	IdempotencyTTL time.Duration
+
+	// HTTPRedirectPort, when set along with EnableTLS, listens for plain HTTP and redirects it to
+	// HTTPS. The certificate in TLSCertFile and TLSKeyFile is re-read on SIGHUP.
+	HTTPRedirectPort string
+
+	// CORSAllowedOrigins are the browser origins allowed to call the API; "*" allows any origin,
+	// but without credentials. Empty allows none.
+	CORSAllowedOrigins   []string
+	CORSAllowedMethods   []string
+	CORSAllowedHeaders   []string
+	CORSAllowCredentials bool
+	// CORSMaxAge is how long browsers may cache preflight responses
+	CORSMaxAge time.Duration
+
+	SettlementAutoConfirmAfter    time.Duration
+	SettlementAutoConfirmInterval time.Duration
+	SettlementVoidRetention       time.Duration // 0 keeps voided settlements forever
+
+	ClientErrorSampleRate float64
+
+	// StatsCacheTTL is how long the admin dashboard stats are served from cache
+	StatsCacheTTL time.Duration
+
+	// CacheTTL is how long membership and user lookups are cached in process; zero disables the
+	// cache. Replicas evict each other's entries through CacheInvalidationChannel.
+	CacheTTL                 time.Duration
+	CacheInvalidationChannel string
+	// AggregateCacheTTL is how long balance summaries and member lists are cached in Redis;
+	// zero disables the cache. Changes evict them sooner.
+	AggregateCacheTTL time.Duration
+
+	// GroupSuggestionInterval is how often group suggestions are recomputed; zero disables them
+	GroupSuggestionInterval time.Duration
+
+	// BalanceReconcileInterval is how often every group's balances are recomputed from its
+	// expenses and settlements; zero disables it. Drift is only logged unless
+	// BalanceReconcileCorrect is set.
+	BalanceReconcileInterval time.Duration
+	BalanceReconcileCorrect  bool
+	// BalanceUpdatesAsync queues the balance updates of new and approved expenses for the balance
+	// worker, which polls the queue every BalanceQueueInterval, instead of applying them in the
+	// request
+	BalanceUpdatesAsync  bool
+	BalanceQueueInterval time.Duration
+	// BalanceSnapshotInterval is how often the day's balance snapshots are refreshed for the
+	// balance-over-time charts; zero disables snapshots
+	BalanceSnapshotInterval time.Duration
+	// OutboxPollInterval is how often the outbox relay looks for events and notifications it
+	// wasn't woken for: ones committed by another instance, or due for a retry
+	OutboxPollInterval time.Duration
+
+	// ExportDir holds the users' data export archives
+	ExportDir string
+
+	// StorageDir holds uploaded files such as avatars, which are linked as StorageBaseURL/<key>
+	StorageDir     string
+	StorageBaseURL string
+
+	// CategoriesFile replaces the built-in expense category catalog when set
+	CategoriesFile string
+
+	// RoundingDriftAlertThreshold is how far a group's expense shares may drift from the
+	// expense amounts in total before a warning is logged
+	RoundingDriftAlertThreshold float64
+
+	// ExpenseSoftLimits is the amount per currency above which expenses must be confirmed,
+	// unless a group sets its own
+	ExpenseSoftLimits map[string]float64
+
+	DocsAccess DocsAccess
+
+	// DocsStrict refuses to start when openapi.yaml and the registered routes disagree
+	DocsStrict bool
+
+	// MetricsToken, when set, is required as a bearer token to scrape /metrics
+	MetricsToken string
+
+	// BackupInterval is how often the database is backed up; zero disables scheduled backups
+	BackupInterval  time.Duration
+	BackupDir       string
+	BackupRetention int
+	// BackupVerify restores every scheduled backup into a scratch database and checks it
+	BackupVerify     bool
+	MongodumpPath    string
+	MongorestorePath string
+
+	// ReadModelURL is the HTTP interface of the ClickHouse server holding the reporting read
+	// model; empty turns the exporter off. ReadModelReports serves the reports from the read
+	// model, which should only be turned on once it has been backfilled.
+	ReadModelURL      string
+	ReadModelDatabase string
+	ReadModelUsername string
+	ReadModelPassword string
+	ReadModelReports  bool
+
+	// FXProvider is where exchange rates for converted totals come from; FXProviderNone leaves
+	// other currencies unconverted. Rates are refreshed every FXRefreshInterval and fetched on
+	// demand once older than FXMaxAge.
+	FXProvider             FXProvider
+	OpenExchangeRatesAppID string
+	FXRefreshInterval      time.Duration
+	FXMaxAge               time.Duration
+
+	// Payment provider webhooks are only accepted from providers with a signing secret
+	StripeWebhookSecret   string
+	RazorpayWebhookSecret string
+
+	EmailProvider  EmailProvider
+	EmailFrom      string
+	EmailFromName  string
+	SMTPHost       string
+	SMTPPort       int
+	SMTPUsername   string
+	SMTPPassword   string
+	SendGridAPIKey string
 }
 
+// EmailProvider selects how outgoing email is delivered
+type EmailProvider string
+
+const (
+	EmailProviderLog      EmailProvider = "log"
+	EmailProviderSMTP     EmailProvider = "smtp"
+	EmailProviderSendGrid EmailProvider = "sendgrid"
+)
+
+// FXProvider selects the exchange rate source
+type FXProvider string
+
+const (
+	FXProviderNone FXProvider = "none"
+	FXProviderECB  FXProvider = "ecb"
+	// FXProviderOpenExchangeRates falls back to the ECB when openexchangerates.org fails
+	FXProviderOpenExchangeRates FXProvider = "openexchangerates"
+)
+
+// DocsAccess controls who can reach the API docs
+type DocsAccess string
+
+const (
+	DocsPublic        DocsAccess = "public"
+	DocsAuthenticated DocsAccess = "authenticated"
+	DocsDisabled      DocsAccess = "disabled"
+)
+
+// EnvFile is read at startup and again whenever the runtime settings are reloaded
+const EnvFile = ".env"
+
 func LoadConfig() *Config {
 	// Load .env file if it exists (ignore error if not found)
-	_ = godotenv.Load()
+	_ = godotenv.Load(EnvFile)
 
 	cfg := &Config{
-		ServerPort:         getEnv("SERVER_PORT", "8080"),
-		MongoURI:           getEnv("MONGO_URI", "mongodb://127.0.0.1:27017/?replicaSet=rs0"),
-		MongoDBName:        getEnv("MONGO_DB_NAME", "divvydoo"),
-		JWTSecret:          getEnv("JWT_SECRET", "default-secret-key"),
-		RedisAddr:          getEnv("REDIS_ADDR", "localhost:6379"),
-		RedisPassword:      getEnv("REDIS_PASSWORD", ""),
-		EnableTLS:          getEnvAsBool("ENABLE_TLS", false),
-		TLSCertFile:        getEnv("TLS_CERT_FILE", ""),
-		TLSKeyFile:         getEnv("TLS_KEY_FILE", ""),
-		WorkerPoolSize:     getEnvAsInt("WORKER_POOL_SIZE", 10),
-		MaxRequestSize:     getEnvAsInt64("MAX_REQUEST_SIZE", 1048576), // 1MB
-		RateLimitPerSecond: getEnvAsInt("RATE_LIMIT_PER_SECOND", 100),
+		ServerPort:     getEnv("SERVER_PORT", "8080"),
+		MongoURI:       getEnv("MONGO_URI", "mongodb://127.0.0.1:27017/?replicaSet=rs0"),
+		MongoDBName:    getEnv("MONGO_DB_NAME", "divvydoo"),
+		JWTSecret:      getEnv("JWT_SECRET", "default-secret-key"),
+		RedisAddr:      getEnv("REDIS_ADDR", "localhost:6379"),
+		RedisPassword:  getEnv("REDIS_PASSWORD", ""),
+		EnableTLS:      getEnvAsBool("ENABLE_TLS", false),
+		TLSCertFile:    getEnv("TLS_CERT_FILE", ""),
+		TLSKeyFile:     getEnv("TLS_KEY_FILE", ""),
+		WorkerPoolSize: getEnvAsInt("WORKER_POOL_SIZE", 10),
+		MaxRequestSize: getEnvAsInt64("MAX_REQUEST_SIZE", 1048576), // 1MB
+		AdminUserIDs:   getEnvAsSlice("ADMIN_USER_IDS", nil),
+
+		HTTPRedirectPort: getEnv("HTTP_REDIRECT_PORT", ""),
+
+		CORSAllowedOrigins: getEnvAsSlice("CORS_ALLOWED_ORIGINS", nil),
+		CORSAllowedMethods: getEnvAsSlice("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
+		CORSAllowedHeaders: getEnvAsSlice("CORS_ALLOWED_HEADERS", []string{
+			"Origin", "Content-Type", "Accept", "Authorization", "X-Request-ID", "Idempotency-Key",
+			"Accept-Language", "X-Currency", "If-None-Match",
+		}),
+		CORSAllowCredentials: getEnvAsBool("CORS_ALLOW_CREDENTIALS", true),
+
+		ClientErrorSampleRate: getEnvAsFloat("CLIENT_ERROR_SAMPLE_RATE", 1.0),
+
+		RoundingDriftAlertThreshold: getEnvAsFloat("ROUNDING_DRIFT_ALERT_THRESHOLD", 0.05),
+
+		ExpenseSoftLimits: getEnvAsAmounts("EXPENSE_SOFT_LIMITS"),
+
+		MetricsToken: getEnv("METRICS_TOKEN", ""),
+
+		CacheInvalidationChannel: getEnv("CACHE_INVALIDATION_CHANNEL", "divvydoo:cache:invalidate"),
+
+		BackupDir:        getEnv("BACKUP_DIR", "backups"),
+		ExportDir:        getEnv("EXPORT_DIR", "exports"),
+		CategoriesFile:   getEnv("CATEGORIES_FILE", ""),
+		StorageDir:       getEnv("STORAGE_DIR", "uploads"),
+		StorageBaseURL:   getEnv("STORAGE_BASE_URL", "/media"),
+		BackupRetention:  getEnvAsInt("BACKUP_RETENTION", 7),
+		BackupVerify:     getEnvAsBool("BACKUP_VERIFY", true),
+		MongodumpPath:    getEnv("MONGODUMP_PATH", "mongodump"),
+		MongorestorePath: getEnv("MONGORESTORE_PATH", "mongorestore"),
+
+		BalanceReconcileCorrect: getEnvAsBool("BALANCE_RECONCILE_CORRECT", false),
+		BalanceUpdatesAsync:     strings.EqualFold(getEnv("BALANCE_UPDATE_MODE", "sync"), "async"),
+
+		ReadModelURL:      getEnv("READ_MODEL_URL", ""),
+		ReadModelDatabase: getEnv("READ_MODEL_DATABASE", "divvydoo"),
+		ReadModelUsername: getEnv("READ_MODEL_USERNAME", ""),
+		ReadModelPassword: getEnv("READ_MODEL_PASSWORD", ""),
+		ReadModelReports:  getEnvAsBool("READ_MODEL_REPORTS", false),
+
+		EmailFrom:      getEnv("EMAIL_FROM", "no-reply@divvydoo.app"),
+		EmailFromName:  getEnv("EMAIL_FROM_NAME", "DivvyDoo"),
+		SMTPHost:       getEnv("SMTP_HOST", ""),
+		SMTPPort:       getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername:   getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:   getEnv("SMTP_PASSWORD", ""),
+		SendGridAPIKey: getEnv("SENDGRID_API_KEY", ""),
+
+		OpenExchangeRatesAppID: getEnv("OPENEXCHANGERATES_APP_ID", ""),
+
+		StripeWebhookSecret:   getEnv("STRIPE_WEBHOOK_SECRET", ""),
+		RazorpayWebhookSecret: getEnv("RAZORPAY_WEBHOOK_SECRET", ""),
 	}
 
 	jwtExp := getEnvAsInt("JWT_EXPIRATION_HOURS", 24)
@@ -50,6 +250,92 @@ func LoadConfig() *Config {
 	redisDB := getEnvAsInt("REDIS_DB", 0)
 	cfg.RedisDB = redisDB
 
+	corsMaxAge := getEnvAsInt("CORS_MAX_AGE_SECONDS", 600)
+	cfg.CORSMaxAge = time.Duration(corsMaxAge) * time.Second
+
+	idempotencyTTL := getEnvAsInt("IDEMPOTENCY_TTL_HOURS", 24)
+	cfg.IdempotencyTTL = time.Duration(idempotencyTTL) * time.Hour
+
+	autoConfirmHours := getEnvAsInt("SETTLEMENT_AUTO_CONFIRM_HOURS", 72)
+	cfg.SettlementAutoConfirmAfter = time.Duration(autoConfirmHours) * time.Hour
+
+	autoConfirmInterval := getEnvAsInt("SETTLEMENT_AUTO_CONFIRM_INTERVAL_MINUTES", 5)
+	cfg.SettlementAutoConfirmInterval = time.Duration(autoConfirmInterval) * time.Minute
+
+	voidRetentionDays := getEnvAsInt("SETTLEMENT_VOID_RETENTION_DAYS", 30)
+	cfg.SettlementVoidRetention = time.Duration(voidRetentionDays) * 24 * time.Hour
+
+	statsCacheTTL := getEnvAsInt("STATS_CACHE_TTL_SECONDS", 300)
+	cfg.StatsCacheTTL = time.Duration(statsCacheTTL) * time.Second
+
+	cacheTTL := getEnvAsInt("CACHE_TTL_SECONDS", 60)
+	cfg.CacheTTL = time.Duration(cacheTTL) * time.Second
+
+	aggregateCacheTTL := getEnvAsInt("AGGREGATE_CACHE_TTL_SECONDS", 300)
+	cfg.AggregateCacheTTL = time.Duration(aggregateCacheTTL) * time.Second
+
+	suggestionInterval := getEnvAsInt("GROUP_SUGGESTION_INTERVAL_HOURS", 24)
+	cfg.GroupSuggestionInterval = time.Duration(suggestionInterval) * time.Hour
+
+	reconcileInterval := getEnvAsInt("BALANCE_RECONCILE_INTERVAL_HOURS", 0)
+	cfg.BalanceReconcileInterval = time.Duration(reconcileInterval) * time.Hour
+
+	queueInterval := getEnvAsInt("BALANCE_QUEUE_INTERVAL_MS", 1000)
+	if queueInterval <= 0 {
+		queueInterval = 1000
+	}
+	cfg.BalanceQueueInterval = time.Duration(queueInterval) * time.Millisecond
+
+	snapshotInterval := getEnvAsInt("BALANCE_SNAPSHOT_INTERVAL_MINUTES", 60)
+	cfg.BalanceSnapshotInterval = time.Duration(snapshotInterval) * time.Minute
+
+	outboxInterval := getEnvAsInt("OUTBOX_POLL_INTERVAL_MS", 500)
+	if outboxInterval <= 0 {
+		outboxInterval = 500
+	}
+	cfg.OutboxPollInterval = time.Duration(outboxInterval) * time.Millisecond
+
+	backupInterval := getEnvAsInt("BACKUP_INTERVAL_HOURS", 0)
+	cfg.BackupInterval = time.Duration(backupInterval) * time.Hour
+
+	fxRefreshInterval := getEnvAsInt("FX_REFRESH_INTERVAL_HOURS", 24)
+	if fxRefreshInterval <= 0 {
+		fxRefreshInterval = 24
+	}
+	cfg.FXRefreshInterval = time.Duration(fxRefreshInterval) * time.Hour
+
+	fxMaxAge := getEnvAsInt("FX_MAX_AGE_HOURS", 48)
+	cfg.FXMaxAge = time.Duration(fxMaxAge) * time.Hour
+
+	switch provider := FXProvider(strings.ToLower(getEnv("FX_PROVIDER", string(FXProviderECB)))); provider {
+	case FXProviderNone, FXProviderECB:
+		cfg.FXProvider = provider
+	case FXProviderOpenExchangeRates:
+		cfg.FXProvider = provider
+		if cfg.OpenExchangeRatesAppID == "" {
+			cfg.FXProvider = FXProviderECB
+		}
+	default:
+		cfg.FXProvider = FXProviderECB
+	}
+
+	// Anything unrecognised hides the docs rather than exposing them by accident
+	switch access := DocsAccess(strings.ToLower(getEnv("DOCS_ACCESS", string(DocsPublic)))); access {
+	case DocsPublic, DocsAuthenticated:
+		cfg.DocsAccess = access
+	default:
+		cfg.DocsAccess = DocsDisabled
+	}
+	cfg.DocsStrict = getEnvAsBool("DOCS_STRICT", false)
+
+	// Unknown providers only log, so a typo never sends mail through the wrong channel
+	switch provider := EmailProvider(strings.ToLower(getEnv("EMAIL_PROVIDER", string(EmailProviderLog)))); provider {
+	case EmailProviderSMTP, EmailProviderSendGrid:
+		cfg.EmailProvider = provider
+	default:
+		cfg.EmailProvider = EmailProviderLog
+	}
+
 	return cfg
 }
 
@@ -79,6 +365,15 @@ func getEnvAsInt64(key string, defaultValue int64) int64 {
 	return defaultValue
 }
 
+func getEnvAsFloat(key string, defaultValue float64) float64 {
+	if value := os.Getenv(key); value != "" {
+		if floatValue, err := strconv.ParseFloat(value, 64); err == nil {
+			return floatValue
+		}
+	}
+	return defaultValue
+}
+
 func getEnvAsBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		if boolValue, err := strconv.ParseBool(value); err == nil {
@@ -87,3 +382,33 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+func getEnvAsSlice(key string, defaultValue []string) []string {
+	if value := os.Getenv(key); value != "" {
+		var items []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+	return defaultValue
+}
+
+// getEnvAsAmounts parses comma-separated CURRENCY=amount pairs, skipping malformed ones
+func getEnvAsAmounts(key string) map[string]float64 {
+	amounts := make(map[string]float64)
+	for _, item := range getEnvAsSlice(key, nil) {
+		currency, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		amount, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil || amount < 0 {
+			continue
+		}
+		amounts[strings.ToUpper(strings.TrimSpace(currency))] = amount
+	}
+	return amounts
+}
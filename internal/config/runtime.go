@@ -0,0 +1,207 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/joho/godotenv"
+)
+
+// RuntimeSettings are the operational knobs that can change without a restart
+type RuntimeSettings struct {
+	RateLimitPerSecond         int             `json:"rate_limit_per_second"`
+	ClientErrorRateLimitPerSec int             `json:"client_error_rate_limit_per_second"`
+	UserReadRateLimitPerMin    int             `json:"user_read_rate_limit_per_minute"`
+	UserWriteRateLimitPerMin   int             `json:"user_write_rate_limit_per_minute"`
+	LogLevel                   string          `json:"log_level"`
+	MaintenanceMode            bool            `json:"maintenance_mode"`
+	AggregationTimeBudgetMs    int             `json:"aggregation_time_budget_ms"`
+	Features                   map[string]bool `json:"features"`
+}
+
+// FeatureEnabled reports whether the named feature flag is on; unknown flags are off
+func (s RuntimeSettings) FeatureEnabled(name string) bool {
+	return s.Features[name]
+}
+
+// FeatureEnabledFor reports whether the flag is on for one scope, such as a group ID. A
+// "name:scope" flag overrides the plain "name" flag, so a feature can be rolled out to a few
+// groups, or to all but a few.
+func (s RuntimeSettings) FeatureEnabledFor(name string, scope string) bool {
+	if enabled, ok := s.Features[name+":"+scope]; ok {
+		return enabled
+	}
+	return s.Features[name]
+}
+
+// Runtime holds the current RuntimeSettings and swaps them on reload. It is safe for concurrent use.
+type Runtime struct {
+	mu       sync.RWMutex
+	settings RuntimeSettings
+	envFile  string
+}
+
+// NewRuntime reads the settings from the environment, so call it after LoadConfig has loaded
+// the .env file. Reloads re-read envFile, whose values then take precedence over the process
+// environment.
+func NewRuntime(envFile string) (*Runtime, error) {
+	settings, err := runtimeSettings(os.Getenv)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &Runtime{envFile: envFile}
+	r.apply(settings)
+	return r, nil
+}
+
+// Current returns a snapshot of the settings. Reloads replace the Features map rather than
+// modify it, so the snapshot can be read without locking.
+func (r *Runtime) Current() RuntimeSettings {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.settings
+}
+
+// Reload re-reads the settings. Invalid values leave the current settings untouched.
+func (r *Runtime) Reload() (RuntimeSettings, error) {
+	fileValues, err := godotenv.Read(r.envFile)
+	if err != nil && !os.IsNotExist(err) {
+		return r.Current(), fmt.Errorf("failed to read %s: %w", r.envFile, err)
+	}
+
+	settings, err := runtimeSettings(func(key string) string {
+		if value, ok := fileValues[key]; ok {
+			return value
+		}
+		return os.Getenv(key)
+	})
+	if err != nil {
+		return r.Current(), err
+	}
+
+	r.apply(settings)
+	return settings, nil
+}
+
+// WatchSignals reloads the settings on every SIGHUP until ctx is done
+func (r *Runtime) WatchSignals(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			settings, err := r.Reload()
+			if err != nil {
+				log.Printf("Config reload failed: %v", err)
+				continue
+			}
+			log.Printf("Config reloaded: rate_limit=%d client_error_rate_limit=%d user_read_rate_limit=%d user_write_rate_limit=%d log_level=%s maintenance=%t aggregation_time_budget_ms=%d",
+				settings.RateLimitPerSecond, settings.ClientErrorRateLimitPerSec, settings.UserReadRateLimitPerMin,
+				settings.UserWriteRateLimitPerMin, settings.LogLevel, settings.MaintenanceMode,
+				settings.AggregationTimeBudgetMs)
+		}
+	}
+}
+
+func (r *Runtime) apply(settings RuntimeSettings) {
+	var level slog.Level
+	// runtimeSettings has already validated the level
+	_ = level.UnmarshalText([]byte(settings.LogLevel))
+	slog.SetLogLoggerLevel(level)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.settings = settings
+}
+
+// runtimeSettings parses the reloadable settings. Unlike LoadConfig, malformed values are
+// errors, so a typo in a reload can't silently reset a limit to its default.
+func runtimeSettings(lookup func(key string) string) (RuntimeSettings, error) {
+	settings := RuntimeSettings{
+		RateLimitPerSecond:         100,
+		ClientErrorRateLimitPerSec: 5,
+		UserReadRateLimitPerMin:    600,
+		UserWriteRateLimitPerMin:   120,
+		LogLevel:                   "info",
+		AggregationTimeBudgetMs:    2000,
+		Features:                   make(map[string]bool),
+	}
+
+	ints := []struct {
+		key    string
+		target *int
+	}{
+		{"RATE_LIMIT_PER_SECOND", &settings.RateLimitPerSecond},
+		{"CLIENT_ERROR_RATE_LIMIT_PER_SECOND", &settings.ClientErrorRateLimitPerSec},
+		{"USER_READ_RATE_LIMIT_PER_MINUTE", &settings.UserReadRateLimitPerMin},
+		{"USER_WRITE_RATE_LIMIT_PER_MINUTE", &settings.UserWriteRateLimitPerMin},
+	}
+	for _, setting := range ints {
+		value := lookup(setting.key)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 {
+			return settings, fmt.Errorf("%s must be a positive integer, got %q", setting.key, value)
+		}
+		*setting.target = n
+	}
+
+	if value := lookup("AGGREGATION_TIME_BUDGET_MS"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return settings, fmt.Errorf("AGGREGATION_TIME_BUDGET_MS must be 0 or a positive integer, got %q", value)
+		}
+		settings.AggregationTimeBudgetMs = n
+	}
+
+	if value := lookup("LOG_LEVEL"); value != "" {
+		var level slog.Level
+		if err := level.UnmarshalText([]byte(value)); err != nil {
+			return settings, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error, got %q", value)
+		}
+		settings.LogLevel = strings.ToLower(value)
+	}
+
+	if value := lookup("MAINTENANCE_MODE"); value != "" {
+		enabled, err := strconv.ParseBool(value)
+		if err != nil {
+			return settings, fmt.Errorf("MAINTENANCE_MODE must be true or false, got %q", value)
+		}
+		settings.MaintenanceMode = enabled
+	}
+
+	// FEATURE_FLAGS is a comma-separated list of names, each optionally followed by =true or =false.
+	// Names may be scoped as name:scope, see FeatureEnabledFor.
+	for _, flag := range strings.Split(lookup("FEATURE_FLAGS"), ",") {
+		flag = strings.TrimSpace(flag)
+		if flag == "" {
+			continue
+		}
+		name, value, hasValue := strings.Cut(flag, "=")
+		enabled := true
+		if hasValue {
+			var err error
+			if enabled, err = strconv.ParseBool(strings.TrimSpace(value)); err != nil {
+				return settings, fmt.Errorf("FEATURE_FLAGS entry %q must be name, name=true or name=false", flag)
+			}
+		}
+		settings.Features[strings.TrimSpace(name)] = enabled
+	}
+
+	return settings, nil
+}
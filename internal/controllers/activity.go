@@ -0,0 +1,59 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ActivityController struct {
+	activityService *services.ActivityService
+}
+
+func NewActivityController(activityService *services.ActivityService) *ActivityController {
+	return &ActivityController{activityService: activityService}
+}
+
+// ListUserActivity pages through the user's feed. The feed merges several collections,
+// so it is paged by cursor only and offset is ignored.
+func (c *ActivityController) ListUserActivity(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	page, err := utils.ParsePagination(ctx)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	page.Offset = 0
+
+	activities, nextCursor, err := c.activityService.ListUserActivity(ctx.Request.Context(), userID, page.Cursor, page.Limit)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) {
+			utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithList(ctx, http.StatusOK, activities, page.Meta(nextCursor))
+}
@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"divvydoo/backend/internal/config"
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AdminController struct {
+	maintenanceService *services.MaintenanceService
+	jobService         *services.JobService
+	statsService       *services.StatsService
+	runtimeConfig      *config.Runtime
+}
+
+func NewAdminController(maintenanceService *services.MaintenanceService, jobService *services.JobService, statsService *services.StatsService, runtimeConfig *config.Runtime) *AdminController {
+	return &AdminController{
+		maintenanceService: maintenanceService,
+		jobService:         jobService,
+		statsService:       statsService,
+		runtimeConfig:      runtimeConfig,
+	}
+}
+
+func (c *AdminController) StartMaintenance(ctx *gin.Context) {
+	operation := ctx.Param("operation")
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	job, err := c.maintenanceService.StartOperation(ctx.Request.Context(), operation, userID.(string))
+	if err != nil {
+		if errors.Is(err, services.ErrUnknownMaintenanceOperation) {
+			utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusAccepted, job)
+}
+
+func (c *AdminController) GetJob(ctx *gin.Context) {
+	jobID := ctx.Param("id")
+	if jobID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Job ID is required")
+		return
+	}
+
+	job, err := c.jobService.GetJob(ctx.Request.Context(), jobID)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, job)
+}
+
+func (c *AdminController) ListJobs(ctx *gin.Context) {
+	page, err := utils.ParsePagination(ctx)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	jobs, err := c.jobService.ListJobs(ctx.Request.Context(), ctx.Query("type"), page.Limit)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithLegacyList(ctx, http.StatusOK, jobs, utils.ListMeta{Limit: page.Limit})
+}
+
+// GetRuntimeConfig returns the settings that can be changed without a restart
+func (c *AdminController) GetRuntimeConfig(ctx *gin.Context) {
+	utils.RespondWithJSON(ctx, http.StatusOK, c.runtimeConfig.Current())
+}
+
+// ReloadRuntimeConfig re-reads the runtime settings, the same as sending the process SIGHUP
+func (c *AdminController) ReloadRuntimeConfig(ctx *gin.Context) {
+	settings, err := c.runtimeConfig.Reload()
+	if err != nil {
+		utils.RespondWithServiceError(ctx, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, settings)
+}
+
+// GetStats returns the operational dashboard figures for the last `days` days (default 14)
+func (c *AdminController) GetStats(ctx *gin.Context) {
+	days := services.DefaultStatsDays
+	if value := ctx.Query("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid days")
+			return
+		}
+	}
+
+	stats, err := c.statsService.GetAdminStats(ctx.Request.Context(), days)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidStatsWindow) {
+			utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, stats)
+}
@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type APIKeyController struct {
+	apiKeyService *services.APIKeyService
+}
+
+func NewAPIKeyController(apiKeyService *services.APIKeyService) *APIKeyController {
+	return &APIKeyController{apiKeyService: apiKeyService}
+}
+
+// CreateAPIKey issues a key acting as the caller. The key is only shown here.
+func (c *APIKeyController) CreateAPIKey(ctx *gin.Context) {
+	var req services.CreateAPIKeyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	key, err := c.apiKeyService.CreateAPIKey(ctx.Request.Context(), userID.(string), req)
+	if err != nil {
+		respondWithAPIKeyError(ctx, err)
+		return
+	}
+
+	// The response holds the secret, so keep it out of caches
+	ctx.Header("Cache-Control", "no-store")
+	utils.RespondWithJSON(ctx, http.StatusCreated, key)
+}
+
+func (c *APIKeyController) ListAPIKeys(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	keys, err := c.apiKeyService.ListAPIKeys(ctx.Request.Context(), userID.(string))
+	if err != nil {
+		respondWithAPIKeyError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, keys)
+}
+
+func (c *APIKeyController) RevokeAPIKey(ctx *gin.Context) {
+	keyID := ctx.Param("id")
+	if keyID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "API key ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if err := c.apiKeyService.RevokeAPIKey(ctx.Request.Context(), userID.(string), keyID); err != nil {
+		respondWithAPIKeyError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, gin.H{"message": "API key revoked successfully"})
+}
+
+func respondWithAPIKeyError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAPIKeyScopes), errors.Is(err, services.ErrInvalidAPIKeyTTL):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrTooManyAPIKeys):
+		utils.RespondWithServiceError(ctx, http.StatusConflict, err)
+	case errors.Is(err, services.ErrAPIKeyNotFound):
+		utils.RespondWithServiceError(ctx, http.StatusNotFound, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
+}
@@ -0,0 +1,212 @@
+package controllers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type AvatarController struct {
+	avatarService *services.AvatarService
+}
+
+func NewAvatarController(avatarService *services.AvatarService) *AvatarController {
+	return &AvatarController{avatarService: avatarService}
+}
+
+// UploadUserAvatar takes a multipart upload: the image in "file", plus optional "crop_x",
+// "crop_y" and "crop_size" selecting the square to use
+func (c *AvatarController) UploadUserAvatar(ctx *gin.Context) {
+	userID, ok := avatarOwner(ctx)
+	if !ok {
+		return
+	}
+
+	data, crop, ok := readAvatarUpload(ctx)
+	if !ok {
+		return
+	}
+
+	user, err := c.avatarService.SetUserAvatar(ctx.Request.Context(), userID, data, crop)
+	if err != nil {
+		respondWithAvatarError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, user)
+}
+
+// CropUserAvatar re-crops the current avatar from the original upload
+func (c *AvatarController) CropUserAvatar(ctx *gin.Context) {
+	userID, ok := avatarOwner(ctx)
+	if !ok {
+		return
+	}
+
+	var crop models.AvatarCrop
+	if err := ctx.ShouldBindJSON(&crop); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	user, err := c.avatarService.CropUserAvatar(ctx.Request.Context(), userID, crop)
+	if err != nil {
+		respondWithAvatarError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, user)
+}
+
+func (c *AvatarController) DeleteUserAvatar(ctx *gin.Context) {
+	userID, ok := avatarOwner(ctx)
+	if !ok {
+		return
+	}
+
+	user, err := c.avatarService.DeleteUserAvatar(ctx.Request.Context(), userID)
+	if err != nil {
+		respondWithAvatarError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, user)
+}
+
+// UploadGroupAvatar takes the same multipart upload as UploadUserAvatar; group admins only
+func (c *AvatarController) UploadGroupAvatar(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	data, crop, ok := readAvatarUpload(ctx)
+	if !ok {
+		return
+	}
+
+	group, err := c.avatarService.SetGroupAvatar(ctx.Request.Context(), groupID, userID.(string), data, crop)
+	if err != nil {
+		respondWithAvatarError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, group)
+}
+
+func (c *AvatarController) CropGroupAvatar(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var crop models.AvatarCrop
+	if err := ctx.ShouldBindJSON(&crop); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	group, err := c.avatarService.CropGroupAvatar(ctx.Request.Context(), groupID, userID.(string), crop)
+	if err != nil {
+		respondWithAvatarError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, group)
+}
+
+func (c *AvatarController) DeleteGroupAvatar(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	group, err := c.avatarService.DeleteGroupAvatar(ctx.Request.Context(), groupID, userID.(string))
+	if err != nil {
+		respondWithAvatarError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, group)
+}
+
+// avatarOwner returns the user ID from the path once it's confirmed to be the caller's own
+func avatarOwner(ctx *gin.Context) (string, bool) {
+	userID := ctx.Param("id")
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return "", false
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return "", false
+	}
+
+	return userID, true
+}
+
+// readAvatarUpload reads the uploaded image and the optional crop form fields
+func readAvatarUpload(ctx *gin.Context) ([]byte, *models.AvatarCrop, bool) {
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Image file is required")
+		return nil, nil, false
+	}
+
+	var crop *models.AvatarCrop
+	if ctx.PostForm("crop_size") != "" {
+		crop = &models.AvatarCrop{}
+		for field, target := range map[string]*int{"crop_x": &crop.X, "crop_y": &crop.Y, "crop_size": &crop.Size} {
+			value, err := strconv.Atoi(ctx.DefaultPostForm(field, "0"))
+			if err != nil {
+				utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid "+field)
+				return nil, nil, false
+			}
+			*target = value
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Could not read image file")
+		return nil, nil, false
+	}
+	defer file.Close()
+
+	// Read one byte past the limit so oversized files are rejected rather than truncated
+	data, err := io.ReadAll(io.LimitReader(file, services.MaxAvatarUploadBytes+1))
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Could not read image file")
+		return nil, nil, false
+	}
+
+	return data, crop, true
+}
+
+func respondWithAvatarError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidAvatar), errors.Is(err, services.ErrInvalidAvatarCrop):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrNoAvatar), errors.Is(err, services.ErrUserNotFound):
+		utils.RespondWithServiceError(ctx, http.StatusNotFound, err)
+	case errors.Is(err, services.ErrNotGroupAdmin):
+		utils.RespondWithServiceError(ctx, http.StatusForbidden, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
+}
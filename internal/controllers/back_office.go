@@ -0,0 +1,170 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+	"divvydoo/backend/pkg/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BackOfficeController serves the /admin/v1 API for support staff
+type BackOfficeController struct {
+	backOfficeService *services.BackOfficeService
+	authService       auth.JWTService
+	denylist          auth.TokenDenylist
+}
+
+func NewBackOfficeController(backOfficeService *services.BackOfficeService, authService auth.JWTService, denylist auth.TokenDenylist) *BackOfficeController {
+	return &BackOfficeController{
+		backOfficeService: backOfficeService,
+		authService:       authService,
+		denylist:          denylist,
+	}
+}
+
+type DisableUserRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+type SetUserRoleRequest struct {
+	Role models.UserRole `json:"role" binding:"required"`
+}
+
+type ForceCancelSettlementRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// SearchUsers finds users by ID, or by the start of their name or email (?q=)
+func (c *BackOfficeController) SearchUsers(ctx *gin.Context) {
+	query := strings.TrimSpace(ctx.Query("q"))
+	if query == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Query parameter 'q' is required")
+		return
+	}
+
+	page, err := utils.ParsePagination(ctx)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	users, err := c.backOfficeService.SearchUsers(ctx.Request.Context(), query, page.Limit, page.Offset)
+	if err != nil {
+		respondWithBackOfficeError(ctx, err)
+		return
+	}
+
+	utils.RespondWithList(ctx, http.StatusOK, users, utils.ListMeta{Limit: page.Limit, Offset: page.Offset})
+}
+
+// GetUser returns the account with its group memberships and balances
+func (c *BackOfficeController) GetUser(ctx *gin.Context) {
+	details, err := c.backOfficeService.GetAccount(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		respondWithBackOfficeError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, details)
+}
+
+// DisableUser stops the user from logging in and revokes every token issued to them
+func (c *BackOfficeController) DisableUser(ctx *gin.Context) {
+	var req DisableUserRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID := ctx.Param("id")
+	user, err := c.backOfficeService.DisableUser(ctx.Request.Context(), ctx.GetString("userID"), userID, req.Reason)
+	if err != nil {
+		respondWithBackOfficeError(ctx, err)
+		return
+	}
+
+	now := time.Now()
+	if err := c.denylist.RevokeUser(ctx.Request.Context(), userID, now, now.Add(c.authService.TokenLifetime())); err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to revoke tokens")
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, user)
+}
+
+// EnableUser lets a disabled user log in again
+func (c *BackOfficeController) EnableUser(ctx *gin.Context) {
+	user, err := c.backOfficeService.EnableUser(ctx.Request.Context(), ctx.GetString("userID"), ctx.Param("id"))
+	if err != nil {
+		respondWithBackOfficeError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, user)
+}
+
+// SetUserRole grants or removes the admin role
+func (c *BackOfficeController) SetUserRole(ctx *gin.Context) {
+	var req SetUserRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	user, err := c.backOfficeService.SetRole(ctx.Request.Context(), ctx.GetString("userID"), ctx.Param("id"), req.Role)
+	if err != nil {
+		respondWithBackOfficeError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, user)
+}
+
+// GetGroupBalances returns every balance in any group, regardless of its privacy settings
+func (c *BackOfficeController) GetGroupBalances(ctx *gin.Context) {
+	balances, err := c.backOfficeService.GetGroupBalances(ctx.Request.Context(), ctx.Param("id"))
+	if err != nil {
+		respondWithBackOfficeError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, balances)
+}
+
+// ForceCancelSettlement cancels any settlement that hasn't completed
+func (c *BackOfficeController) ForceCancelSettlement(ctx *gin.Context) {
+	var req ForceCancelSettlementRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	settlement, err := c.backOfficeService.ForceCancelSettlement(ctx.Request.Context(), ctx.GetString("userID"), ctx.Param("id"), req.Reason)
+	if err != nil {
+		respondWithBackOfficeError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, settlement)
+}
+
+func respondWithBackOfficeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAdminSelfChange):
+		utils.RespondWithServiceError(ctx, http.StatusConflict, err)
+	case errors.Is(err, services.ErrInvalidUserRole):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrSettlementCompleted), errors.Is(err, services.ErrSettlementStateChanged),
+		errors.Is(err, services.ErrSettlementNotCancellable):
+		utils.RespondWithServiceError(ctx, http.StatusConflict, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
+}
@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BackupController struct {
+	backupService *services.BackupService
+}
+
+func NewBackupController(backupService *services.BackupService) *BackupController {
+	return &BackupController{backupService: backupService}
+}
+
+func (c *BackupController) ListBackups(ctx *gin.Context) {
+	page, err := utils.ParsePagination(ctx)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	backups, err := c.backupService.ListBackups(ctx.Request.Context(), page.Limit)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithLegacyList(ctx, http.StatusOK, backups, utils.ListMeta{Limit: page.Limit})
+}
+
+func (c *BackupController) StartBackup(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	job, err := c.backupService.StartBackup(ctx.Request.Context(), userID.(string))
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusAccepted, job)
+}
+
+func (c *BackupController) VerifyBackup(ctx *gin.Context) {
+	backupID := ctx.Param("id")
+	if backupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Backup ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	job, err := c.backupService.StartVerification(ctx.Request.Context(), backupID, userID.(string))
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrBackupNotFound):
+			utils.RespondWithServiceError(ctx, http.StatusNotFound, err)
+		case errors.Is(err, services.ErrBackupNotAvailable):
+			utils.RespondWithServiceError(ctx, http.StatusConflict, err)
+		default:
+			utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		}
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusAccepted, job)
+}
@@ -1,20 +1,31 @@
 package controllers
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
+	"divvydoo/backend/internal/models"
 	"divvydoo/backend/internal/services"
 	"divvydoo/backend/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
+// balanceStreamHeartbeat keeps idle streams from being closed by proxies
+const balanceStreamHeartbeat = 30 * time.Second
+
 type BalanceController struct {
-	balanceService *services.BalanceService
+	balanceService  *services.BalanceService
+	snapshotService *services.BalanceSnapshotService
+	eventBus        *services.EventBus
 }
 
-func NewBalanceController(balanceService *services.BalanceService) *BalanceController {
-	return &BalanceController{balanceService: balanceService}
+func NewBalanceController(balanceService *services.BalanceService, snapshotService *services.BalanceSnapshotService, eventBus *services.EventBus) *BalanceController {
+	return &BalanceController{balanceService: balanceService, snapshotService: snapshotService, eventBus: eventBus}
 }
 
 func (c *BalanceController) GetUserBalances(ctx *gin.Context) {
@@ -38,13 +49,218 @@ func (c *BalanceController) GetUserBalances(ctx *gin.Context) {
 
 	balances, err := c.balanceService.GetUserBalances(ctx.Request.Context(), userID)
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+	if notModified(ctx, weakETag(balances)) {
 		return
 	}
 
 	utils.RespondWithJSON(ctx, http.StatusOK, balances)
 }
 
+// StreamUserBalances sends the user's balance summary as a Server-Sent Events stream: once on
+// connect, then again whenever a balance of theirs changes. Each message is a "balances" event
+// whose data is the UserBalanceSummary JSON.
+func (c *BalanceController) StreamUserBalances(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	// Subscribe before reading the first summary so no change in between is missed
+	sub := c.eventBus.Subscribe(userID, realtimeBufferSize)
+	defer sub.Close()
+
+	summary, fingerprint, err := c.balanceSummary(ctx, userID)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+	ctx.SSEvent("balances", summary)
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(balanceStreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.Events:
+			if !ok {
+				// Dropped for falling behind; the client reconnects and gets a fresh summary
+				return
+			}
+			if event.Type != services.EventBalancesChanged {
+				continue
+			}
+
+			// Events go to every group member, so only send summaries that actually changed
+			updated, updatedFingerprint, err := c.balanceSummary(ctx, userID)
+			if err != nil {
+				log.Printf("Balance stream for user %s failed: %v", userID, err)
+				return
+			}
+			if updatedFingerprint == fingerprint {
+				continue
+			}
+			fingerprint = updatedFingerprint
+			ctx.SSEvent("balances", updated)
+			ctx.Writer.Flush()
+		case <-heartbeat.C:
+			if _, err := ctx.Writer.WriteString(": keep-alive\n\n"); err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+		case <-ctx.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// balanceSummary returns the user's summary and a fingerprint of its balances for spotting changes.
+// LastUpdated is left out of the fingerprint since it defaults to the time of the request.
+func (c *BalanceController) balanceSummary(ctx *gin.Context, userID string) (*models.UserBalanceSummary, string, error) {
+	summary, err := c.balanceService.GetUserBalances(ctx.Request.Context(), userID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	balances := *summary
+	balances.LastUpdated = time.Time{}
+	data, err := json.Marshal(balances)
+	if err != nil {
+		return nil, "", err
+	}
+	return summary, string(data), nil
+}
+
+// ListBalanceHistory returns the user's balance changes, newest first, optionally limited to one
+// group (group_id) or to some change types (type, comma-separated or repeated)
+func (c *BalanceController) ListBalanceHistory(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	var groupID *string
+	if id := ctx.Query("group_id"); id != "" {
+		groupID = &id
+	}
+
+	var types []models.BalanceChangeType
+	for _, value := range ctx.QueryArray("type") {
+		for _, t := range strings.Split(value, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				types = append(types, models.BalanceChangeType(t))
+			}
+		}
+	}
+
+	page, err := utils.ParsePagination(ctx)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	history, nextCursor, err := c.balanceService.ListBalanceHistory(ctx.Request.Context(), userID, groupID, types, page.Cursor, page.Limit, page.Offset)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidCursor) || errors.Is(err, services.ErrInvalidBalanceChangeType) {
+			utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithList(ctx, http.StatusOK, history, page.Meta(nextCursor))
+}
+
+// GetBalancesHistory serves the balance timeline when a granularity is asked for and, for the
+// clients that used it before, the balance history otherwise
+func (c *BalanceController) GetBalancesHistory(ctx *gin.Context) {
+	if ctx.Query("granularity") == "" {
+		c.ListBalanceHistory(ctx)
+		return
+	}
+	c.GetBalanceTimeline(ctx)
+}
+
+// GetBalanceTimeline returns the user's balances over time from the daily snapshots
+func (c *BalanceController) GetBalanceTimeline(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	var from, to time.Time
+	if t, err := parseTimeQuery(ctx, "from"); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid from date")
+		return
+	} else if t != nil {
+		from = *t
+	}
+	if t, err := parseTimeQuery(ctx, "to"); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid to date")
+		return
+	} else if t != nil {
+		to = *t
+	}
+
+	timeline, err := c.snapshotService.GetTimeline(ctx.Request.Context(), userID, optionalQuery(ctx, "group_id"), ctx.Query("granularity"), from, to)
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidGranularity) || errors.Is(err, services.ErrInvalidTimelineSpan) {
+			utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, timeline)
+}
+
 func (c *BalanceController) GetGroupBalances(ctx *gin.Context) {
 	groupID := ctx.Param("id")
 	if groupID == "" {
@@ -52,11 +268,56 @@ func (c *BalanceController) GetGroupBalances(ctx *gin.Context) {
 		return
 	}
 
-	balances, err := c.balanceService.GetGroupBalances(ctx.Request.Context(), groupID)
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	balances, err := c.balanceService.GetGroupBalances(ctx.Request.Context(), groupID, userID.(string))
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		if errors.Is(err, services.ErrNotGroupMember) {
+			utils.RespondWithServiceError(ctx, http.StatusForbidden, err)
+			return
+		}
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
 		return
 	}
 
 	utils.RespondWithJSON(ctx, http.StatusOK, balances)
 }
+
+// GetFriendBalance returns what the user and another user owe each other outside groups, with
+// the payments that would settle it
+func (c *BalanceController) GetFriendBalance(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	friendID := ctx.Param("friendId")
+	if friendID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Friend ID is required")
+		return
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	balance, err := c.balanceService.GetFriendBalance(ctx.Request.Context(), userID, friendID)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, balance)
+}
@@ -0,0 +1,42 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BalanceReconciliationController struct {
+	reconciliationService *services.BalanceReconciliationService
+}
+
+func NewBalanceReconciliationController(reconciliationService *services.BalanceReconciliationService) *BalanceReconciliationController {
+	return &BalanceReconciliationController{reconciliationService: reconciliationService}
+}
+
+// RecomputeBalances recomputes the group's balances from its expenses and settlements and
+// corrects the ones that drifted; with dry_run=true it only reports them
+func (c *BalanceReconciliationController) RecomputeBalances(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	correct := ctx.Query("dry_run") != "true"
+	reconciliation, err := c.reconciliationService.ReconcileGroup(ctx.Request.Context(), groupID, correct)
+	if err != nil {
+		if errors.Is(err, services.ErrBalanceUpdatesPending) {
+			utils.RespondWithServiceError(ctx, http.StatusConflict, err)
+			return
+		}
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, reconciliation)
+}
@@ -0,0 +1,105 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type BudgetController struct {
+	budgetService *services.BudgetService
+}
+
+func NewBudgetController(budgetService *services.BudgetService) *BudgetController {
+	return &BudgetController{budgetService: budgetService}
+}
+
+// GetBudget shows the group's spending this month against its budget
+func (c *BudgetController) GetBudget(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	status, err := c.budgetService.GetBudgetStatus(ctx.Request.Context(), groupID, userID.(string))
+	if err != nil {
+		respondWithBudgetError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, status)
+}
+
+// SetBudget replaces the group's monthly budget
+func (c *BudgetController) SetBudget(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	var req services.SetBudgetRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	status, err := c.budgetService.SetBudget(ctx.Request.Context(), groupID, userID.(string), req)
+	if err != nil {
+		respondWithBudgetError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, status)
+}
+
+func (c *BudgetController) DeleteBudget(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if err := c.budgetService.DeleteBudget(ctx.Request.Context(), groupID, userID.(string)); err != nil {
+		respondWithBudgetError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, gin.H{"message": "Budget removed successfully"})
+}
+
+func respondWithBudgetError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotGroupAdmin), errors.Is(err, services.ErrNotGroupMember):
+		utils.RespondWithServiceError(ctx, http.StatusForbidden, err)
+	case errors.Is(err, services.ErrInvalidBudget):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrBudgetNotSet):
+		utils.RespondWithServiceError(ctx, http.StatusNotFound, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
+}
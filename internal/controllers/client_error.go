@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"net/http"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ClientErrorController struct {
+	clientErrorService *services.ClientErrorService
+}
+
+func NewClientErrorController(clientErrorService *services.ClientErrorService) *ClientErrorController {
+	return &ClientErrorController{clientErrorService: clientErrorService}
+}
+
+func (c *ClientErrorController) ReportError(ctx *gin.Context) {
+	var req models.ClientErrorReportRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	stored, err := c.clientErrorService.Report(ctx.Request.Context(), userID.(string), ctx.GetString("requestID"), req)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusAccepted, gin.H{"message": "Error report received", "stored": stored})
+}
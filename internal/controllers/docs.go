@@ -1,19 +1,23 @@
 package controllers
 
 import (
+	"fmt"
 	"net/http"
-	"os"
+	"net/url"
+	"sort"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
 )
 
 type DocsController struct {
-	specPath string
+	spec []byte
 }
 
-func NewDocsController() *DocsController {
+func NewDocsController(spec []byte) *DocsController {
 	return &DocsController{
-		specPath: "openapi.yaml",
+		spec: spec,
 	}
 }
 
@@ -62,17 +66,115 @@ func (dc *DocsController) GetOpenAPISpec(c *gin.Context) {
 }
 
 func (dc *DocsController) GetOpenAPIYAML(c *gin.Context) {
-	// Read the OpenAPI spec file
-	specData, err := os.ReadFile(dc.specPath)
+	// Set proper headers to display inline
+	c.Header("Content-Disposition", "inline")
+	c.Data(http.StatusOK, "application/x-yaml; charset=utf-8", dc.spec)
+}
+
+// specDocument is the part of openapi.yaml needed to compare it with the router
+type specDocument struct {
+	Servers []specServer              `yaml:"servers"`
+	Paths   map[string]map[string]any `yaml:"paths"`
+}
+
+type specServer struct {
+	URL string `yaml:"url"`
+}
+
+var specMethods = map[string]bool{
+	"get": true, "put": true, "post": true, "delete": true,
+	"options": true, "head": true, "patch": true, "trace": true,
+}
+
+// CheckRoutes compares the routes registered on the router with the operations in the spec, so
+// the hand-written spec can't silently drift from the controllers. It returns the routes the spec
+// doesn't describe and the operations no route serves, as "METHOD /path". Only routes in the
+// top-level sections the spec covers (/v1, and /admin for the back office) are compared, which
+// leaves out /health, /metrics and the docs themselves.
+func (dc *DocsController) CheckRoutes(routes gin.RoutesInfo) ([]string, []string, error) {
+	var doc specDocument
+	if err := yaml.Unmarshal(dc.spec, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse OpenAPI spec: %v", err)
+	}
+
+	defaultBase, err := serverBasePath(doc.Servers)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Failed to read OpenAPI specification",
-		})
-		return
+		return nil, nil, err
 	}
 
-	// Set proper headers to display inline
-	c.Header("Content-Type", "application/x-yaml; charset=utf-8")
-	c.Header("Content-Disposition", "inline")
-	c.String(http.StatusOK, string(specData))
+	documented := make(map[string]string)
+	sections := make(map[string]bool)
+	for path, item := range doc.Paths {
+		base := defaultBase
+		if raw, ok := item["servers"]; ok {
+			var servers []specServer
+			encoded, _ := yaml.Marshal(raw)
+			if err := yaml.Unmarshal(encoded, &servers); err != nil {
+				return nil, nil, fmt.Errorf("invalid servers for %s: %v", path, err)
+			}
+			if base, err = serverBasePath(servers); err != nil {
+				return nil, nil, fmt.Errorf("invalid servers for %s: %v", path, err)
+			}
+		}
+		sections[topSection(base+path)] = true
+		for method := range item {
+			if specMethods[method] {
+				operation := strings.ToUpper(method) + " " + base + path
+				documented[routeKey(operation)] = operation
+			}
+		}
+	}
+
+	var undocumented []string
+	for _, route := range routes {
+		if !sections[topSection(route.Path)] {
+			continue
+		}
+		operation := route.Method + " " + route.Path
+		key := routeKey(operation)
+		if _, ok := documented[key]; ok {
+			delete(documented, key)
+			continue
+		}
+		undocumented = append(undocumented, operation)
+	}
+
+	unrouted := make([]string, 0, len(documented))
+	for _, operation := range documented {
+		unrouted = append(unrouted, operation)
+	}
+	sort.Strings(undocumented)
+	sort.Strings(unrouted)
+	return undocumented, unrouted, nil
+}
+
+// serverBasePath is the path of the first server URL, e.g. /v1
+func serverBasePath(servers []specServer) (string, error) {
+	if len(servers) == 0 {
+		return "", nil
+	}
+	u, err := url.Parse(servers[0].URL)
+	if err != nil {
+		return "", fmt.Errorf("invalid server URL %q: %v", servers[0].URL, err)
+	}
+	return strings.TrimSuffix(u.Path, "/"), nil
+}
+
+// routeKey writes both gin's :id and the spec's {id} parameters as {}, since the two often name
+// the same parameter differently
+func routeKey(operation string) string {
+	segments := strings.Split(operation, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") ||
+			(strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")) {
+			segments[i] = "{}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// topSection is the first segment of a path, e.g. v1 for /v1/groups
+func topSection(path string) string {
+	section, _, _ := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return section
 }
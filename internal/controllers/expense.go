@@ -1,9 +1,16 @@
 package controllers
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
 	"divvydoo/backend/internal/services"
 	"divvydoo/backend/internal/utils"
 
@@ -19,9 +26,9 @@ func NewExpenseController(expenseService *services.ExpenseService) *ExpenseContr
 }
 
 func (c *ExpenseController) CreateExpense(ctx *gin.Context) {
-	var expense models.Expense
-	if err := ctx.ShouldBindJSON(&expense); err != nil {
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+	var req services.CreateExpenseRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
 		return
 	}
 
@@ -31,11 +38,11 @@ func (c *ExpenseController) CreateExpense(ctx *gin.Context) {
 		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
-	expense.CreatorID = userID.(string)
+	req.CreatorID = userID.(string)
 
-	createdExpense, err := c.expenseService.CreateExpense(ctx.Request.Context(), expense)
+	createdExpense, err := c.expenseService.CreateExpense(ctx.Request.Context(), req)
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		respondWithExpenseError(ctx, err)
 		return
 	}
 
@@ -57,7 +64,133 @@ func (c *ExpenseController) GetExpense(ctx *gin.Context) {
 
 	expense, err := c.expenseService.GetExpense(ctx.Request.Context(), expenseID, userID.(string))
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		respondWithExpenseError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, expense)
+}
+
+// GetExpenseHistory lists every recorded change to the expense, newest first
+func (c *ExpenseController) GetExpenseHistory(ctx *gin.Context) {
+	expenseID := ctx.Param("id")
+	if expenseID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Expense ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	revisions, err := c.expenseService.GetExpenseHistory(ctx.Request.Context(), expenseID, userID.(string))
+	if err != nil {
+		respondWithExpenseError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, revisions)
+}
+
+// ApproveExpense lets a group admin apply an expense pending approval to balances
+func (c *ExpenseController) ApproveExpense(ctx *gin.Context) {
+	expenseID := ctx.Param("id")
+	if expenseID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Expense ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	expense, err := c.expenseService.ApproveExpense(ctx.Request.Context(), expenseID, userID.(string))
+	if err != nil {
+		respondWithExpenseError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, expense)
+}
+
+// RejectExpense lets a group admin turn down an expense pending approval
+func (c *ExpenseController) RejectExpense(ctx *gin.Context) {
+	expenseID := ctx.Param("id")
+	if expenseID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Expense ID is required")
+		return
+	}
+
+	var req services.RejectExpenseRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	expense, err := c.expenseService.RejectExpense(ctx.Request.Context(), expenseID, userID.(string), req.Reason)
+	if err != nil {
+		respondWithExpenseError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, expense)
+}
+
+// BatchGetExpenses returns the requested expenses the caller can view, in request order
+func (c *ExpenseController) BatchGetExpenses(ctx *gin.Context) {
+	var req services.BatchGetExpensesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	expenses, err := c.expenseService.GetExpensesByIDs(ctx.Request.Context(), userID.(string), req.ExpenseIDs)
+	if err != nil {
+		respondWithExpenseError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, expenses)
+}
+
+func (c *ExpenseController) UpdateExpense(ctx *gin.Context) {
+	expenseID := ctx.Param("id")
+	if expenseID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Expense ID is required")
+		return
+	}
+
+	var req services.UpdateExpenseRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	expense, err := c.expenseService.UpdateExpense(ctx.Request.Context(), expenseID, userID.(string), req)
+	if err != nil {
+		respondWithExpenseError(ctx, err)
 		return
 	}
 
@@ -71,17 +204,29 @@ func (c *ExpenseController) ListGroupExpenses(ctx *gin.Context) {
 		return
 	}
 
-	// Default pagination
-	limit := int64(20)
-	offset := int64(0)
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
 
-	expenses, err := c.expenseService.GetGroupExpenses(ctx.Request.Context(), groupID, limit, offset)
+	page, err := utils.ParsePagination(ctx)
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
 		return
 	}
 
-	utils.RespondWithJSON(ctx, http.StatusOK, expenses)
+	expenses, nextCursor, err := c.expenseService.ListGroupExpenses(ctx.Request.Context(), groupID, userID.(string), strings.TrimSpace(ctx.Query("q")), page.Cursor, page.Limit, page.Offset)
+	if err != nil {
+		respondWithExpenseError(ctx, err)
+		return
+	}
+
+	meta := page.Meta(nextCursor)
+	if notModified(ctx, weakETag(expenses, meta)) {
+		return
+	}
+	utils.RespondWithList(ctx, http.StatusOK, expenses, meta)
 }
 
 func (c *ExpenseController) ListUserExpenses(ctx *gin.Context) {
@@ -103,15 +248,217 @@ func (c *ExpenseController) ListUserExpenses(ctx *gin.Context) {
 		return
 	}
 
-	// Default pagination
-	limit := int64(20)
-	offset := int64(0)
+	page, err := utils.ParsePagination(ctx)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+		return
+	}
 
-	expenses, err := c.expenseService.GetUserExpenses(ctx.Request.Context(), userID, limit, offset)
+	expenses, nextCursor, err := c.expenseService.ListUserExpenses(ctx.Request.Context(), userID, page.Cursor, page.Limit, page.Offset)
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		respondWithExpenseError(ctx, err)
 		return
 	}
 
-	utils.RespondWithJSON(ctx, http.StatusOK, expenses)
+	meta := page.Meta(nextCursor)
+	if notModified(ctx, weakETag(expenses, meta)) {
+		return
+	}
+	utils.RespondWithList(ctx, http.StatusOK, expenses, meta)
+}
+
+// ExportGroupExpenses streams every expense of the group as a CSV download
+func (c *ExpenseController) ExportGroupExpenses(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	if format := ctx.DefaultQuery("format", "csv"); format != "csv" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Unsupported export format")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	export, err := c.expenseService.PrepareGroupExpenseExport(ctx.Request.Context(), groupID, userID.(string))
+	if err != nil {
+		respondWithExpenseError(ctx, err)
+		return
+	}
+
+	filename := fmt.Sprintf("group-%s-expenses-%s.csv", export.GroupID, time.Now().UTC().Format("20060102"))
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Status(http.StatusOK)
+
+	// The status line is already sent, so a failure part-way can only be logged
+	if err := export.WriteCSV(ctx.Request.Context(), ctx.Writer); err != nil {
+		log.Printf("CSV export of group %s failed: %v", groupID, err)
+	}
+}
+
+// ImportGroupExpenses creates group expenses from an uploaded CSV, sent either as the "file"
+// field of a multipart form or as a text/csv request body.
+func (c *ExpenseController) ImportGroupExpenses(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	var data io.Reader = ctx.Request.Body
+	if strings.HasPrefix(ctx.ContentType(), "multipart/") {
+		fileHeader, err := ctx.FormFile("file")
+		if err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "CSV file is required")
+			return
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Could not read CSV file")
+			return
+		}
+		defer file.Close()
+		data = file
+	}
+
+	result, err := c.expenseService.ImportGroupExpenses(ctx.Request.Context(), groupID, userID.(string), data)
+	if err != nil {
+		respondWithExpenseError(ctx, err)
+		return
+	}
+
+	status := http.StatusOK
+	if result.Created > 0 {
+		status = http.StatusCreated
+	}
+	utils.RespondWithJSON(ctx, status, result)
+}
+
+// SearchExpenses filters the caller's visible expenses. Query parameters: group_id, paid_by,
+// currency, category, from/to on the expense date (RFC 3339 or YYYY-MM-DD), min_amount/max_amount, q (title or
+// description text) and sort (date, created_at or amount, prefixed with "-" for descending; default -date).
+func (c *ExpenseController) SearchExpenses(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	page, err := utils.ParsePagination(ctx)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	filter := repositories.ExpenseSearchFilter{
+		GroupID:  optionalQuery(ctx, "group_id"),
+		PaidBy:   optionalQuery(ctx, "paid_by"),
+		Currency: optionalQuery(ctx, "currency"),
+		Category: optionalQuery(ctx, "category"),
+		Status:   optionalQuery(ctx, "status"),
+		Query:    strings.TrimSpace(ctx.Query("q")),
+		Limit:    page.Limit,
+		Offset:   page.Offset,
+	}
+
+	if filter.From, err = parseTimeQuery(ctx, "from"); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid from date")
+		return
+	}
+	if filter.To, err = parseTimeQuery(ctx, "to"); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid to date")
+		return
+	}
+	if filter.MinAmount, err = parseFloatQuery(ctx, "min_amount"); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid min_amount")
+		return
+	}
+	if filter.MaxAmount, err = parseFloatQuery(ctx, "max_amount"); err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid max_amount")
+		return
+	}
+
+	sort, err := utils.ParseSort(ctx, "-date", "date", "created_at", "amount")
+	if err != nil {
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+		return
+	}
+	filter.SortField = sort.Field
+	filter.SortAsc = !sort.Desc
+
+	expenses, hasMore, err := c.expenseService.SearchExpenses(ctx.Request.Context(), userID.(string), filter)
+	if err != nil {
+		respondWithExpenseError(ctx, err)
+		return
+	}
+
+	meta := page.Meta("")
+	meta.HasMore = hasMore
+	utils.RespondWithList(ctx, http.StatusOK, expenses, meta)
+}
+
+func optionalQuery(ctx *gin.Context, key string) *string {
+	value := strings.TrimSpace(ctx.Query(key))
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// parseTimeQuery accepts an RFC 3339 timestamp or a plain date (midnight UTC)
+func parseTimeQuery(ctx *gin.Context, key string) (*time.Time, error) {
+	value := ctx.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		t, err = time.Parse(time.DateOnly, value)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &t, nil
+}
+
+func parseFloatQuery(ctx *gin.Context, key string) (*float64, error) {
+	value := ctx.Query(key)
+	if value == "" {
+		return nil, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	return &f, nil
+}
+
+func respondWithExpenseError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrExpenseAccessDenied), errors.Is(err, services.ErrExpenseEditDenied),
+		errors.Is(err, services.ErrNotGroupMember), errors.Is(err, services.ErrNotGroupAdmin):
+		utils.RespondWithServiceError(ctx, http.StatusForbidden, err)
+	case errors.Is(err, services.ErrInvalidCursor), errors.Is(err, services.ErrInvalidSearchFilter),
+		errors.Is(err, services.ErrInvalidImport), errors.Is(err, services.ErrCurrencyMismatch),
+		errors.Is(err, services.ErrInvalidExpense), errors.Is(err, services.ErrTooManyExpenseIDs):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrExpenseNeedsConfirmation):
+		utils.RespondWithServiceError(ctx, http.StatusUnprocessableEntity, err)
+	case errors.Is(err, services.ErrGroupArchived), errors.Is(err, services.ErrExpenseNotPendingApproval):
+		utils.RespondWithServiceError(ctx, http.StatusConflict, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
 }
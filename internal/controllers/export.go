@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ExportController struct {
+	exportService *services.ExportService
+}
+
+func NewExportController(exportService *services.ExportService) *ExportController {
+	return &ExportController{exportService: exportService}
+}
+
+// StartExport queues an export of all the caller's data and returns the job to poll
+func (c *ExportController) StartExport(ctx *gin.Context) {
+	userID, ok := exportOwner(ctx)
+	if !ok {
+		return
+	}
+
+	job, err := c.exportService.StartExport(ctx.Request.Context(), userID)
+	if err != nil {
+		respondWithExportError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusAccepted, job)
+}
+
+func (c *ExportController) GetExport(ctx *gin.Context) {
+	userID, ok := exportOwner(ctx)
+	if !ok {
+		return
+	}
+
+	job, err := c.exportService.GetExport(ctx.Request.Context(), userID, ctx.Param("jobId"))
+	if err != nil {
+		respondWithExportError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, job)
+}
+
+func (c *ExportController) DownloadExport(ctx *gin.Context) {
+	userID, ok := exportOwner(ctx)
+	if !ok {
+		return
+	}
+
+	path, err := c.exportService.OpenExport(ctx.Request.Context(), userID, ctx.Param("jobId"))
+	if err != nil {
+		respondWithExportError(ctx, err)
+		return
+	}
+
+	ctx.FileAttachment(path, fmt.Sprintf("divvydoo-export-%s.zip", userID))
+}
+
+// exportOwner returns the user ID from the path once it's confirmed to be the caller's own
+func exportOwner(ctx *gin.Context) (string, bool) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return "", false
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return "", false
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return "", false
+	}
+
+	return userID, true
+}
+
+func respondWithExportError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrExportNotFound), errors.Is(err, services.ErrUserNotFound):
+		utils.RespondWithServiceError(ctx, http.StatusNotFound, err)
+	case errors.Is(err, services.ErrExportNotReady):
+		utils.RespondWithServiceError(ctx, http.StatusConflict, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
+}
@@ -0,0 +1,61 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FairnessController struct {
+	fairnessService *services.FairnessService
+}
+
+func NewFairnessController(fairnessService *services.FairnessService) *FairnessController {
+	return &FairnessController{fairnessService: fairnessService}
+}
+
+// GetFairness compares what each member paid with what they consumed, optionally between from
+// and to (RFC 3339 or YYYY-MM-DD)
+func (c *FairnessController) GetFairness(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	from, err := parseTimeQuery(ctx, "from")
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid from date")
+		return
+	}
+	to, err := parseTimeQuery(ctx, "to")
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid to date")
+		return
+	}
+
+	report, err := c.fairnessService.GetFairnessReport(ctx.Request.Context(), groupID, userID.(string), from, to)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidFairnessPeriod):
+			utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+		case errors.Is(err, services.ErrNotGroupMember):
+			utils.RespondWithServiceError(ctx, http.StatusForbidden, err)
+		default:
+			utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		}
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, report)
+}
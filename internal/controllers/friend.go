@@ -0,0 +1,154 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FriendController struct {
+	friendService *services.FriendService
+}
+
+func NewFriendController(friendService *services.FriendService) *FriendController {
+	return &FriendController{friendService: friendService}
+}
+
+func (c *FriendController) ListFriends(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	friends, err := c.friendService.ListFriends(ctx.Request.Context(), userID.(string))
+	if err != nil {
+		respondWithFriendError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, friends)
+}
+
+func (c *FriendController) RemoveFriend(ctx *gin.Context) {
+	friendID := ctx.Param("userId")
+	if friendID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if err := c.friendService.RemoveFriend(ctx.Request.Context(), userID.(string), friendID); err != nil {
+		respondWithFriendError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, gin.H{"message": "Friend removed successfully"})
+}
+
+// SendRequest asks another user to be friends; if they already asked, their request is accepted
+func (c *FriendController) SendRequest(ctx *gin.Context) {
+	var req services.SendFriendRequestRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	friendship, err := c.friendService.SendRequest(ctx.Request.Context(), userID.(string), req)
+	if err != nil {
+		respondWithFriendError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusCreated, friendship)
+}
+
+func (c *FriendController) ListRequests(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	requests, err := c.friendService.ListRequests(ctx.Request.Context(), userID.(string))
+	if err != nil {
+		respondWithFriendError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, requests)
+}
+
+func (c *FriendController) AcceptRequest(ctx *gin.Context) {
+	friendshipID := ctx.Param("id")
+	if friendshipID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Request ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	friendship, err := c.friendService.AcceptRequest(ctx.Request.Context(), userID.(string), friendshipID)
+	if err != nil {
+		respondWithFriendError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, friendship)
+}
+
+// DeclineRequest turns down a received request or withdraws a sent one
+func (c *FriendController) DeclineRequest(ctx *gin.Context) {
+	friendshipID := ctx.Param("id")
+	if friendshipID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Request ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if err := c.friendService.DeclineRequest(ctx.Request.Context(), userID.(string), friendshipID); err != nil {
+		respondWithFriendError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, gin.H{"message": "Friend request declined"})
+}
+
+func respondWithFriendError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrCannotFriendSelf):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrNotFriendRecipient):
+		utils.RespondWithServiceError(ctx, http.StatusForbidden, err)
+	case errors.Is(err, services.ErrUserNotFound), errors.Is(err, services.ErrFriendRequestNotFound), errors.Is(err, services.ErrNotFriends):
+		utils.RespondWithServiceError(ctx, http.StatusNotFound, err)
+	case errors.Is(err, services.ErrAlreadyFriends):
+		utils.RespondWithServiceError(ctx, http.StatusConflict, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
+}
@@ -1,7 +1,9 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
 
 	"divvydoo/backend/internal/services"
 	"divvydoo/backend/internal/utils"
@@ -20,7 +22,7 @@ func NewGroupController(groupService *services.GroupService) *GroupController {
 func (c *GroupController) CreateGroup(ctx *gin.Context) {
 	var req services.CreateGroupRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		utils.RespondWithBindingError(ctx, err)
 		return
 	}
 
@@ -32,7 +34,7 @@ func (c *GroupController) CreateGroup(ctx *gin.Context) {
 
 	group, err := c.groupService.CreateGroup(ctx.Request.Context(), userID.(string), req)
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
 		return
 	}
 
@@ -54,7 +56,178 @@ func (c *GroupController) GetGroup(ctx *gin.Context) {
 
 	group, err := c.groupService.GetGroup(ctx.Request.Context(), groupID, userID.(string))
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+	if notModified(ctx, weakETag(group)) {
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, group)
+}
+
+func (c *GroupController) UpdateSettings(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	var req services.UpdateGroupSettingsRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	group, err := c.groupService.UpdateSettings(ctx.Request.Context(), groupID, userID.(string), req)
+	if err != nil {
+		respondWithGroupError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, group)
+}
+
+func (c *GroupController) UpdateGroup(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	var req services.CreateGroupRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	group, err := c.groupService.UpdateGroup(ctx.Request.Context(), groupID, userID.(string), req)
+	if err != nil {
+		respondWithGroupError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, group)
+}
+
+// ChangeCurrency moves the group to another currency, converting its balances or keeping them
+// in the old one
+func (c *GroupController) ChangeCurrency(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	var req services.ChangeCurrencyRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	group, err := c.groupService.ChangeCurrency(ctx.Request.Context(), groupID, userID.(string), req)
+	if err != nil {
+		respondWithGroupError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, group)
+}
+
+// ArchiveGroup hides the group from listings and stops new expenses; its history is kept.
+// force=true archives it even with outstanding balances. DELETE /groups/:id does the same.
+func (c *GroupController) ArchiveGroup(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	force := false
+	if value := ctx.Query("force"); value != "" {
+		var err error
+		if force, err = strconv.ParseBool(value); err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid force")
+			return
+		}
+	}
+
+	if err := c.groupService.ArchiveGroup(ctx.Request.Context(), groupID, userID.(string), force); err != nil {
+		respondWithGroupError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, gin.H{"message": "Group archived successfully"})
+}
+
+func (c *GroupController) UnarchiveGroup(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if err := c.groupService.UnarchiveGroup(ctx.Request.Context(), groupID, userID.(string)); err != nil {
+		respondWithGroupError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, gin.H{"message": "Group unarchived successfully"})
+}
+
+func (c *GroupController) UpdateMemberRole(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	memberID := ctx.Param("memberId")
+	if groupID == "" || memberID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID and Member ID are required")
+		return
+	}
+
+	var req services.UpdateMemberRoleRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	group, err := c.groupService.UpdateMemberRole(ctx.Request.Context(), groupID, userID.(string), memberID, req.Role)
+	if err != nil {
+		respondWithGroupError(ctx, err)
 		return
 	}
 
@@ -70,7 +243,7 @@ func (c *GroupController) AddMember(ctx *gin.Context) {
 
 	var req services.AddMemberRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		utils.RespondWithBindingError(ctx, err)
 		return
 	}
 
@@ -82,7 +255,7 @@ func (c *GroupController) AddMember(ctx *gin.Context) {
 
 	err := c.groupService.AddMember(ctx.Request.Context(), groupID, userID.(string), req)
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
 		return
 	}
 
@@ -103,9 +276,18 @@ func (c *GroupController) RemoveMember(ctx *gin.Context) {
 		return
 	}
 
-	err := c.groupService.RemoveMember(ctx.Request.Context(), groupID, userID.(string), memberID)
+	forgive := false
+	if value := ctx.Query("forgive"); value != "" {
+		var err error
+		if forgive, err = strconv.ParseBool(value); err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid forgive")
+			return
+		}
+	}
+
+	err := c.groupService.RemoveMember(ctx.Request.Context(), groupID, userID.(string), memberID, forgive)
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		respondWithGroupError(ctx, err)
 		return
 	}
 
@@ -125,9 +307,18 @@ func (c *GroupController) LeaveGroup(ctx *gin.Context) {
 		return
 	}
 
-	err := c.groupService.LeaveGroup(ctx.Request.Context(), groupID, userID.(string))
+	forgive := false
+	if value := ctx.Query("forgive"); value != "" {
+		var err error
+		if forgive, err = strconv.ParseBool(value); err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid forgive")
+			return
+		}
+	}
+
+	err := c.groupService.LeaveGroup(ctx.Request.Context(), groupID, userID.(string), forgive)
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		respondWithGroupError(ctx, err)
 		return
 	}
 
@@ -149,13 +340,15 @@ func (c *GroupController) GetMembers(ctx *gin.Context) {
 
 	members, err := c.groupService.GetMembers(ctx.Request.Context(), groupID, userID.(string))
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
 		return
 	}
 
 	utils.RespondWithJSON(ctx, http.StatusOK, members)
 }
 
+// GetUserGroups lists the caller's groups. With ?mine=true it returns summaries with
+// member counts, the caller's balance and last activity instead of full group documents.
 func (c *GroupController) GetUserGroups(ctx *gin.Context) {
 	userID, exists := ctx.Get("userID")
 	if !exists {
@@ -163,11 +356,62 @@ func (c *GroupController) GetUserGroups(ctx *gin.Context) {
 		return
 	}
 
+	if ctx.Query("mine") == "true" {
+		c.respondWithGroupSummaries(ctx, userID.(string))
+		return
+	}
+
 	groups, err := c.groupService.GetUserGroups(ctx.Request.Context(), userID.(string))
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
 		return
 	}
 
 	utils.RespondWithJSON(ctx, http.StatusOK, groups)
 }
+
+// ListUserGroups returns group summaries for the user in the path, who must be the caller
+func (c *GroupController) ListUserGroups(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	c.respondWithGroupSummaries(ctx, userID)
+}
+
+func (c *GroupController) respondWithGroupSummaries(ctx *gin.Context, userID string) {
+	summaries, err := c.groupService.GetUserGroupSummaries(ctx.Request.Context(), userID)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, summaries)
+}
+
+func respondWithGroupError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotGroupAdmin), errors.Is(err, services.ErrNotGroupMember):
+		utils.RespondWithServiceError(ctx, http.StatusForbidden, err)
+	case errors.Is(err, services.ErrInvalidGroupSettings), errors.Is(err, services.ErrInvalidMemberRole):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrLastGroupAdmin), errors.Is(err, services.ErrOutstandingBalance),
+		errors.Is(err, services.ErrCannotForgiveDebt), errors.Is(err, services.ErrGroupHasBalances):
+		utils.RespondWithServiceError(ctx, http.StatusConflict, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
+}
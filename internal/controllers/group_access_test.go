@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The fakes embed the repository interfaces, so only the methods the group lists call are
+// implemented; any other call panics and fails the test.
+
+type fakeGroupRepo struct {
+	repositories.GroupRepository
+	group *models.Group
+}
+
+func (r *fakeGroupRepo) GetByID(ctx context.Context, groupID string) (*models.Group, error) {
+	if groupID != r.group.GroupID {
+		return nil, repositories.ErrGroupNotFound
+	}
+	return r.group, nil
+}
+
+func (r *fakeGroupRepo) IsMember(ctx context.Context, groupID string, userID string) (bool, error) {
+	if groupID != r.group.GroupID {
+		return false, nil
+	}
+	for _, member := range r.group.Members {
+		if member.UserID == userID && member.IsActive {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+type fakeExpenseRepo struct {
+	repositories.ExpenseRepository
+}
+
+func (r *fakeExpenseRepo) ListByGroupID(ctx context.Context, groupID string, query string, cursor *repositories.Cursor, limit, offset int64) ([]*models.Expense, string, error) {
+	return []*models.Expense{}, "", nil
+}
+
+type fakeBalanceRepo struct {
+	repositories.BalanceRepository
+}
+
+func (r *fakeBalanceRepo) GetByGroupID(ctx context.Context, groupID string) ([]*models.Balance, error) {
+	return []*models.Balance{}, nil
+}
+
+type fakeSettlementRepo struct {
+	repositories.SettlementRepository
+}
+
+func (r *fakeSettlementRepo) ListByGroupID(ctx context.Context, groupID string, statuses []models.SettlementStatus, cursor *repositories.Cursor, limit, offset int64) ([]*models.Settlement, string, error) {
+	return []*models.Settlement{}, "", nil
+}
+
+func newGroupAccessRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	groupRepo := &fakeGroupRepo{group: &models.Group{
+		GroupID: "group-1",
+		Members: []models.GroupMember{
+			{UserID: "member", Role: models.RoleMember, IsActive: true},
+			{UserID: "former", Role: models.RoleMember, IsActive: false},
+		},
+	}}
+	expenseRepo := &fakeExpenseRepo{}
+	balanceRepo := &fakeBalanceRepo{}
+	settlementRepo := &fakeSettlementRepo{}
+
+	expenseService := services.NewExpenseService(expenseRepo, balanceRepo, groupRepo, nil, nil, nil, nil, nil, nil, nil, nil, nil, false)
+	balanceService := services.NewBalanceService(balanceRepo, expenseRepo, settlementRepo, nil, groupRepo, nil, nil)
+	settlementService := services.NewSettlementService(settlementRepo, nil, balanceRepo, nil, groupRepo, nil, nil, 0)
+
+	expenseController := NewExpenseController(expenseService)
+	balanceController := NewBalanceController(balanceService, nil, nil)
+	settlementController := NewSettlementController(settlementService)
+
+	router := gin.New()
+	router.Use(func(ctx *gin.Context) {
+		ctx.Set("userID", ctx.GetHeader("X-Test-User"))
+	})
+	router.GET("/groups/:id/expenses", expenseController.ListGroupExpenses)
+	router.GET("/groups/:id/balances", balanceController.GetGroupBalances)
+	router.GET("/groups/:id/settlements", settlementController.ListGroupSettlements)
+	return router
+}
+
+func TestGroupListsRequireMembership(t *testing.T) {
+	router := newGroupAccessRouter()
+
+	lists := []string{"expenses", "balances", "settlements"}
+	cases := []struct {
+		name    string
+		groupID string
+		userID  string
+		want    int
+	}{
+		{"member", "group-1", "member", http.StatusOK},
+		{"non-member", "group-1", "stranger", http.StatusForbidden},
+		{"former member", "group-1", "former", http.StatusForbidden},
+		// A group that doesn't exist looks the same as one the caller isn't in
+		{"unknown group", "group-2", "member", http.StatusForbidden},
+	}
+
+	for _, list := range lists {
+		for _, tc := range cases {
+			t.Run(list+"/"+tc.name, func(t *testing.T) {
+				path := fmt.Sprintf("/groups/%s/%s", tc.groupID, list)
+				req := httptest.NewRequest(http.MethodGet, path, nil)
+				req.Header.Set("X-Test-User", tc.userID)
+				rec := httptest.NewRecorder()
+
+				router.ServeHTTP(rec, req)
+
+				if rec.Code != tc.want {
+					t.Fatalf("GET %s as %s: status %d, want %d; body %s", path, tc.userID, rec.Code, tc.want, rec.Body.String())
+				}
+			})
+		}
+	}
+}
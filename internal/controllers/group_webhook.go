@@ -0,0 +1,128 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type GroupWebhookController struct {
+	groupWebhookService *services.GroupWebhookService
+}
+
+func NewGroupWebhookController(groupWebhookService *services.GroupWebhookService) *GroupWebhookController {
+	return &GroupWebhookController{groupWebhookService: groupWebhookService}
+}
+
+func (c *GroupWebhookController) GetWebhook(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	webhook, err := c.groupWebhookService.GetWebhook(ctx.Request.Context(), groupID, userID.(string))
+	if err != nil {
+		respondWithWebhookError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, webhook)
+}
+
+// SetWebhook creates or replaces the group's webhook. The signing secret is only shown when it is
+// created or rotated.
+func (c *GroupWebhookController) SetWebhook(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	var req services.SetGroupWebhookRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	webhook, err := c.groupWebhookService.SetWebhook(ctx.Request.Context(), groupID, userID.(string), req)
+	if err != nil {
+		respondWithWebhookError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, webhook)
+}
+
+func (c *GroupWebhookController) DeleteWebhook(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if err := c.groupWebhookService.DeleteWebhook(ctx.Request.Context(), groupID, userID.(string)); err != nil {
+		respondWithWebhookError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// TestWebhook sends a webhook.ping event to the group's webhook and reports how it went
+func (c *GroupWebhookController) TestWebhook(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	delivery, err := c.groupWebhookService.SendTest(ctx.Request.Context(), groupID, userID.(string))
+	if err != nil {
+		respondWithWebhookError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, delivery)
+}
+
+func respondWithWebhookError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotGroupAdmin):
+		utils.RespondWithServiceError(ctx, http.StatusForbidden, err)
+	case errors.Is(err, services.ErrInvalidWebhookURL):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrWebhookNotFound), errors.Is(err, services.ErrGroupNotFound):
+		utils.RespondWithServiceError(ctx, http.StatusNotFound, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
+}
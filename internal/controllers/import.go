@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ImportController struct {
+	importService *services.ImportService
+}
+
+func NewImportController(importService *services.ImportService) *ImportController {
+	return &ImportController{importService: importService}
+}
+
+// ImportSplitwise takes a multipart upload: the export in "file", plus optional "format"
+// (csv or json, defaults to the file extension), "group_name", "currency", "member_emails"
+// (a JSON object of member name to email) and "dry_run".
+func (c *ImportController) ImportSplitwise(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	fileHeader, err := ctx.FormFile("file")
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Export file is required")
+		return
+	}
+
+	req := services.SplitwiseImportRequest{
+		Format:    strings.ToLower(strings.TrimSpace(ctx.PostForm("format"))),
+		GroupName: ctx.PostForm("group_name"),
+		Currency:  ctx.PostForm("currency"),
+	}
+	if req.Format == "" {
+		req.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(fileHeader.Filename)), ".")
+	}
+
+	if dryRun := ctx.DefaultPostForm("dry_run", ctx.Query("dry_run")); dryRun != "" {
+		if req.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid dry_run")
+			return
+		}
+	}
+
+	if emails := ctx.PostForm("member_emails"); emails != "" {
+		if err := json.Unmarshal([]byte(emails), &req.MemberEmails); err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid member_emails")
+			return
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Could not read export file")
+		return
+	}
+	defer file.Close()
+
+	result, err := c.importService.ImportSplitwise(ctx.Request.Context(), userID.(string), file, req)
+	if err != nil {
+		respondWithImportError(ctx, err)
+		return
+	}
+
+	status := http.StatusCreated
+	if result.DryRun {
+		status = http.StatusOK
+	}
+	utils.RespondWithJSON(ctx, status, result)
+}
+
+func respondWithImportError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidImport), errors.Is(err, services.ErrUnsupportedImportFormat):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
+}
@@ -0,0 +1,35 @@
+package controllers
+
+import (
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LedgerController struct {
+	ledgerService *services.LedgerService
+}
+
+func NewLedgerController(ledgerService *services.LedgerService) *LedgerController {
+	return &LedgerController{ledgerService: ledgerService}
+}
+
+// StartComparison compares the shadow ledger with the balances; poll the returned job for the report
+func (c *LedgerController) StartComparison(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	job, err := c.ledgerService.StartComparison(ctx.Request.Context(), userID.(string))
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusAccepted, job)
+}
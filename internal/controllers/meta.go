@@ -0,0 +1,33 @@
+package controllers
+
+import (
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type MetaController struct {
+	categoryService *services.CategoryService
+}
+
+func NewMetaController(categoryService *services.CategoryService) *MetaController {
+	return &MetaController{categoryService: categoryService}
+}
+
+// GetCategories serves the expense category catalog. Clients should revalidate with
+// If-None-Match and get 304 until the catalog changes.
+func (c *MetaController) GetCategories(ctx *gin.Context) {
+	catalog, etag := c.categoryService.Catalog()
+
+	ctx.Header("ETag", etag)
+	ctx.Header("Cache-Control", "public, max-age=300")
+	if etagMatches(ctx.GetHeader("If-None-Match"), etag) {
+		ctx.Status(http.StatusNotModified)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, catalog)
+}
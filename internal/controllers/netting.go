@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NettingController struct {
+	nettingService *services.NettingService
+}
+
+func NewNettingController(nettingService *services.NettingService) *NettingController {
+	return &NettingController{nettingService: nettingService}
+}
+
+// PreviewNetting shows the adjustments a netting with the counterparty would make, without making them
+func (c *NettingController) PreviewNetting(ctx *gin.Context) {
+	var req models.NettingRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	netting, err := c.nettingService.PreviewNetting(ctx.Request.Context(), userID.(string), req)
+	if err != nil {
+		respondWithNettingError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, netting)
+}
+
+func (c *NettingController) CreateNetting(ctx *gin.Context) {
+	var req models.NettingRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	netting, err := c.nettingService.CreateNetting(ctx.Request.Context(), userID.(string), req)
+	if err != nil {
+		respondWithNettingError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusCreated, netting)
+}
+
+func (c *NettingController) ListNettings(ctx *gin.Context) {
+	page, err := utils.ParsePagination(ctx)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	nettings, err := c.nettingService.ListNettings(ctx.Request.Context(), userID.(string), page.Limit)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithLegacyList(ctx, http.StatusOK, nettings, utils.ListMeta{Limit: page.Limit})
+}
+
+func respondWithNettingError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidNetting):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrUserNotFound):
+		utils.RespondWithServiceError(ctx, http.StatusNotFound, err)
+	case errors.Is(err, services.ErrNothingToNet):
+		utils.RespondWithServiceError(ctx, http.StatusUnprocessableEntity, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
+}
@@ -0,0 +1,85 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NotificationController struct {
+	notificationService *services.NotificationService
+}
+
+func NewNotificationController(notificationService *services.NotificationService) *NotificationController {
+	return &NotificationController{notificationService: notificationService}
+}
+
+// ListNotifications returns the authenticated user's notifications; unread=true limits it to unread ones
+func (c *NotificationController) ListNotifications(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	unreadOnly := false
+	if unread := ctx.Query("unread"); unread != "" {
+		var err error
+		if unreadOnly, err = strconv.ParseBool(unread); err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid unread")
+			return
+		}
+	}
+
+	page, err := utils.ParsePagination(ctx)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	notifications, nextCursor, err := c.notificationService.ListNotifications(ctx.Request.Context(), userID.(string), unreadOnly, page.Cursor, page.Limit, page.Offset)
+	if err != nil {
+		respondWithNotificationError(ctx, err)
+		return
+	}
+
+	utils.RespondWithList(ctx, http.StatusOK, notifications, page.Meta(nextCursor))
+}
+
+func (c *NotificationController) MarkRead(ctx *gin.Context) {
+	notificationID := ctx.Param("id")
+	if notificationID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Notification ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	notification, err := c.notificationService.MarkRead(ctx.Request.Context(), notificationID, userID.(string))
+	if err != nil {
+		respondWithNotificationError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, notification)
+}
+
+func respondWithNotificationError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidCursor):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrNotificationNotFound):
+		utils.RespondWithServiceError(ctx, http.StatusNotFound, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
+}
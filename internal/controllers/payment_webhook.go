@@ -0,0 +1,37 @@
+package controllers
+
+import (
+	"io"
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type PaymentWebhookController struct {
+	paymentWebhookService *services.PaymentWebhookService
+}
+
+func NewPaymentWebhookController(paymentWebhookService *services.PaymentWebhookService) *PaymentWebhookController {
+	return &PaymentWebhookController{paymentWebhookService: paymentWebhookService}
+}
+
+// ReceiveWebhook takes a payment provider's webhook. The signature covers the exact bytes sent,
+// so the body is read raw rather than bound. Any 2xx tells the provider to stop resending the event.
+func (c *PaymentWebhookController) ReceiveWebhook(ctx *gin.Context) {
+	body, err := io.ReadAll(ctx.Request.Body)
+	if err != nil {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Could not read request body")
+		return
+	}
+
+	event, err := c.paymentWebhookService.HandleWebhook(ctx.Request.Context(), ctx.Param("provider"), ctx.Request.Header, body)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, event)
+}
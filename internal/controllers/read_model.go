@@ -0,0 +1,36 @@
+package controllers
+
+import (
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReadModelController struct {
+	readModelService *services.ReadModelService
+}
+
+func NewReadModelController(readModelService *services.ReadModelService) *ReadModelController {
+	return &ReadModelController{readModelService: readModelService}
+}
+
+// StartBackfill copies all expenses, settlements and balances into the reporting read model;
+// poll the returned job for the counts
+func (c *ReadModelController) StartBackfill(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	job, err := c.readModelService.StartBackfill(ctx.Request.Context(), userID.(string))
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusAccepted, job)
+}
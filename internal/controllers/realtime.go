@@ -0,0 +1,107 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	realtimeBufferSize   = 64
+	realtimeWriteTimeout = 10 * time.Second
+	realtimePongTimeout  = 60 * time.Second
+	realtimePingInterval = 30 * time.Second
+	realtimeMaxMessage   = 512
+)
+
+type RealtimeController struct {
+	eventBus *services.EventBus
+	upgrader websocket.Upgrader
+}
+
+func NewRealtimeController(eventBus *services.EventBus) *RealtimeController {
+	return &RealtimeController{
+		eventBus: eventBus,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			// Connections authenticate with a bearer token rather than cookies, so
+			// any origin is allowed, matching the CORS policy
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+	}
+}
+
+// Connect upgrades to a WebSocket that streams expense, settlement and balance events for the
+// authenticated user's groups and their own non-group activity. Clients only listen; anything
+// they send is discarded.
+func (c *RealtimeController) Connect(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	conn, err := c.upgrader.Upgrade(ctx.Writer, ctx.Request, nil)
+	if err != nil {
+		// The upgrader has already written an error response
+		return
+	}
+	defer conn.Close()
+
+	sub := c.eventBus.Subscribe(userID.(string), realtimeBufferSize)
+	defer sub.Close()
+
+	closed := make(chan struct{})
+	go readUntilClosed(conn, closed)
+
+	ping := time.NewTicker(realtimePingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case event, ok := <-sub.Events:
+			conn.SetWriteDeadline(time.Now().Add(realtimeWriteTimeout))
+			if !ok {
+				// Dropped for falling behind; the client should reconnect and refetch
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow"))
+				return
+			}
+			if err := conn.WriteJSON(event); err != nil {
+				log.Printf("Realtime write to user %s failed: %v", userID, err)
+				return
+			}
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(realtimeWriteTimeout))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// readUntilClosed drains incoming frames so pongs and close frames are processed, and
+// closes done once the connection goes away or stops answering pings
+func readUntilClosed(conn *websocket.Conn, done chan<- struct{}) {
+	defer close(done)
+
+	conn.SetReadLimit(realtimeMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(realtimePongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(realtimePongTimeout))
+	})
+
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
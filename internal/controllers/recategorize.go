@@ -0,0 +1,88 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type RecategorizeController struct {
+	recategorizeService *services.RecategorizeService
+}
+
+func NewRecategorizeController(recategorizeService *services.RecategorizeService) *RecategorizeController {
+	return &RecategorizeController{recategorizeService: recategorizeService}
+}
+
+// RecategorizeExpenses returns the number of matching expenses when preview is set, and otherwise
+// queues the change and returns the job to poll
+func (c *RecategorizeController) RecategorizeExpenses(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	var req services.RecategorizeExpensesRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if req.Preview {
+		preview, err := c.recategorizeService.Preview(ctx.Request.Context(), groupID, userID.(string), req)
+		if err != nil {
+			respondWithRecategorizeError(ctx, err)
+			return
+		}
+		utils.RespondWithJSON(ctx, http.StatusOK, preview)
+		return
+	}
+
+	job, err := c.recategorizeService.Start(ctx.Request.Context(), groupID, userID.(string), req)
+	if err != nil {
+		respondWithRecategorizeError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusAccepted, job)
+}
+
+func (c *RecategorizeController) GetRecategorization(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	job, err := c.recategorizeService.GetJob(ctx.Request.Context(), userID.(string), ctx.Param("jobId"))
+	if err != nil {
+		respondWithRecategorizeError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, job)
+}
+
+func respondWithRecategorizeError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotGroupAdmin):
+		utils.RespondWithServiceError(ctx, http.StatusForbidden, err)
+	case errors.Is(err, services.ErrInvalidRecategorization):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrRecategorizationNotFound), errors.Is(err, services.ErrGroupNotFound):
+		utils.RespondWithServiceError(ctx, http.StatusNotFound, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
+}
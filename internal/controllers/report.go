@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReportController struct {
+	reportService *services.ReportService
+}
+
+func NewReportController(reportService *services.ReportService) *ReportController {
+	return &ReportController{reportService: reportService}
+}
+
+// GetMonthlyReport returns the user's spending per category for ?month=YYYY-MM, the current month
+// by default, compared with the month before
+func (c *ReportController) GetMonthlyReport(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	report, err := c.reportService.MonthlyReport(ctx.Request.Context(), userID, ctx.Query("month"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidStatementMonth) {
+			utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+			return
+		}
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, report)
+}
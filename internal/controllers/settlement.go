@@ -1,7 +1,14 @@
 package controllers
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"divvydoo/backend/internal/models"
 	"divvydoo/backend/internal/services"
@@ -21,7 +28,7 @@ func NewSettlementController(settlementService *services.SettlementService) *Set
 func (c *SettlementController) CreateSettlement(ctx *gin.Context) {
 	var req models.SettlementRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		utils.RespondWithBindingError(ctx, err)
 		return
 	}
 
@@ -39,7 +46,31 @@ func (c *SettlementController) CreateSettlement(ctx *gin.Context) {
 
 	settlement, err := c.settlementService.CreateSettlement(ctx.Request.Context(), req)
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		respondWithSettlementError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusCreated, settlement)
+}
+
+// SettleUp creates a settlement paying the counterparty what the caller owes them, with the
+// amount worked out from their balances
+func (c *SettlementController) SettleUp(ctx *gin.Context) {
+	var req models.SettleUpRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	settlement, err := c.settlementService.SettleUp(ctx.Request.Context(), userID.(string), req)
+	if err != nil {
+		respondWithSettlementError(ctx, err)
 		return
 	}
 
@@ -61,17 +92,189 @@ func (c *SettlementController) GetSettlement(ctx *gin.Context) {
 
 	settlement, err := c.settlementService.GetSettlement(ctx.Request.Context(), settlementID, userID.(string))
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
 		return
 	}
 
 	utils.RespondWithJSON(ctx, http.StatusOK, settlement)
 }
 
+// GetSettlementReceipt downloads the PDF receipt of a completed settlement
+func (c *SettlementController) GetSettlementReceipt(ctx *gin.Context) {
+	settlementID := ctx.Param("id")
+	if settlementID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Settlement ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	receipt, err := c.settlementService.Receipt(ctx.Request.Context(), settlementID, userID.(string))
+	if err != nil {
+		respondWithSettlementError(ctx, err)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := services.RenderSettlementReceiptPDF(receipt, &buf); err != nil {
+		log.Printf("Failed to render receipt of settlement %s: %v", settlementID, err)
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to render receipt")
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="settlement-%s-receipt.pdf"`, settlementID))
+	ctx.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
 type CompleteSettlementRequest struct {
 	TransactionID *string `json:"transaction_id,omitempty"`
 }
 
+func (c *SettlementController) ListUserSettlements(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	page, err := utils.ParsePagination(ctx)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	settlements, nextCursor, err := c.settlementService.ListUserSettlements(ctx.Request.Context(), userID, settlementStatusesQuery(ctx), page.Cursor, page.Limit, page.Offset)
+	if err != nil {
+		respondWithSettlementError(ctx, err)
+		return
+	}
+
+	utils.RespondWithList(ctx, http.StatusOK, settlements, page.Meta(nextCursor))
+}
+
+// ExportUserSettlements downloads the caller's completed settlements of one year (default the
+// current one) as CSV, for tax records
+func (c *SettlementController) ExportUserSettlements(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	if format := ctx.DefaultQuery("format", "csv"); format != "csv" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Unsupported export format")
+		return
+	}
+
+	year := time.Now().UTC().Year()
+	if value := ctx.Query("year"); value != "" {
+		var err error
+		if year, err = strconv.Atoi(value); err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid year")
+			return
+		}
+	}
+
+	export, err := c.settlementService.PrepareYearlySettlementExport(ctx.Request.Context(), userID, year)
+	if err != nil {
+		respondWithSettlementError(ctx, err)
+		return
+	}
+
+	ctx.Header("Content-Type", "text/csv; charset=utf-8")
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="settlements-%d.csv"`, year))
+	ctx.Status(http.StatusOK)
+
+	// The status line is already sent, so a failure part-way can only be logged
+	if err := export.WriteCSV(ctx.Writer); err != nil {
+		log.Printf("Settlement export of user %s for %d failed: %v", userID, year, err)
+	}
+}
+
+func (c *SettlementController) ListGroupSettlements(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	page, err := utils.ParsePagination(ctx)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	settlements, nextCursor, err := c.settlementService.ListGroupSettlements(ctx.Request.Context(), groupID, userID.(string), settlementStatusesQuery(ctx), page.Cursor, page.Limit, page.Offset)
+	if err != nil {
+		respondWithSettlementError(ctx, err)
+		return
+	}
+
+	utils.RespondWithList(ctx, http.StatusOK, settlements, page.Meta(nextCursor))
+}
+
+// SettleAll creates the settlements that settle up a group: the caller's own payments, or every
+// member's for an admin
+func (c *SettlementController) SettleAll(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	var req models.SettleAllRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	settlements, err := c.settlementService.SettleAll(ctx.Request.Context(), groupID, userID.(string), req)
+	if err != nil {
+		respondWithSettlementError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusCreated, settlements)
+}
+
 func (c *SettlementController) CompleteSettlement(ctx *gin.Context) {
 	settlementID := ctx.Param("id")
 	if settlementID == "" {
@@ -88,13 +291,67 @@ func (c *SettlementController) CompleteSettlement(ctx *gin.Context) {
 	var req CompleteSettlementRequest
 	ctx.ShouldBindJSON(&req) // Optional body
 
-	err := c.settlementService.CompleteSettlement(ctx.Request.Context(), settlementID, userID.(string), req.TransactionID)
+	settlement, err := c.settlementService.CompleteSettlement(ctx.Request.Context(), settlementID, userID.(string), req.TransactionID)
+	if err != nil {
+		respondWithSettlementError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, settlement)
+}
+
+func (c *SettlementController) ConfirmSettlement(ctx *gin.Context) {
+	settlementID := ctx.Param("id")
+	if settlementID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Settlement ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	settlement, err := c.settlementService.ConfirmSettlement(ctx.Request.Context(), settlementID, userID.(string))
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		respondWithSettlementError(ctx, err)
 		return
 	}
 
-	utils.RespondWithJSON(ctx, http.StatusOK, gin.H{"message": "Settlement completed successfully"})
+	utils.RespondWithJSON(ctx, http.StatusOK, settlement)
+}
+
+type RejectSettlementRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+func (c *SettlementController) RejectSettlement(ctx *gin.Context) {
+	settlementID := ctx.Param("id")
+	if settlementID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Settlement ID is required")
+		return
+	}
+
+	var req RejectSettlementRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	settlement, err := c.settlementService.RejectSettlement(ctx.Request.Context(), settlementID, userID.(string), req.Reason)
+	if err != nil {
+		respondWithSettlementError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, settlement)
 }
 
 func (c *SettlementController) CancelSettlement(ctx *gin.Context) {
@@ -110,13 +367,46 @@ func (c *SettlementController) CancelSettlement(ctx *gin.Context) {
 		return
 	}
 
-	err := c.settlementService.CancelSettlement(ctx.Request.Context(), settlementID, userID.(string))
+	settlement, err := c.settlementService.CancelSettlement(ctx.Request.Context(), settlementID, userID.(string))
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		respondWithSettlementError(ctx, err)
 		return
 	}
 
-	utils.RespondWithJSON(ctx, http.StatusOK, gin.H{"message": "Settlement cancelled successfully"})
+	utils.RespondWithJSON(ctx, http.StatusOK, settlement)
+}
+
+type VoidSettlementRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// VoidSettlement withdraws a settlement recorded by mistake, keeping who voided it and why
+func (c *SettlementController) VoidSettlement(ctx *gin.Context) {
+	settlementID := ctx.Param("id")
+	if settlementID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Settlement ID is required")
+		return
+	}
+
+	var req VoidSettlementRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	settlement, err := c.settlementService.VoidSettlement(ctx.Request.Context(), settlementID, userID.(string), req.Reason)
+	if err != nil {
+		respondWithSettlementError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, settlement)
 }
 
 func (c *SettlementController) GetPendingSettlements(ctx *gin.Context) {
@@ -126,11 +416,120 @@ func (c *SettlementController) GetPendingSettlements(ctx *gin.Context) {
 		return
 	}
 
-	settlements, err := c.settlementService.GetPendingSettlements(ctx.Request.Context(), userID.(string))
+	page, err := utils.ParsePagination(ctx)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+		return
+	}
+
+	settlements, nextCursor, err := c.settlementService.ListOpenSettlements(ctx.Request.Context(), userID.(string), page.Cursor, page.Limit, page.Offset)
+	if err != nil {
+		respondWithSettlementError(ctx, err)
+		return
+	}
+
+	utils.RespondWithList(ctx, http.StatusOK, settlements, page.Meta(nextCursor))
+}
+
+// settlementStatusesQuery reads the status filter, given as a comma-separated list and/or repeated parameter
+func settlementStatusesQuery(ctx *gin.Context) []models.SettlementStatus {
+	var statuses []models.SettlementStatus
+	for _, value := range ctx.QueryArray("status") {
+		for _, status := range strings.Split(value, ",") {
+			if status = strings.TrimSpace(status); status != "" {
+				statuses = append(statuses, models.SettlementStatus(status))
+			}
+		}
+	}
+	return statuses
+}
+
+type AuthorizePayerRequest struct {
+	Methods []models.SettlementMethod `json:"methods" binding:"required,min=1"`
+}
+
+func (c *SettlementController) GetPayerAuthorizations(ctx *gin.Context) {
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	authorizations, err := c.settlementService.GetPayerAuthorizations(ctx.Request.Context(), userID.(string))
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, authorizations)
+}
+
+func (c *SettlementController) AuthorizePayer(ctx *gin.Context) {
+	payerID := ctx.Param("payerId")
+	if payerID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Payer ID is required")
+		return
+	}
+
+	var req AuthorizePayerRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	authorization, err := c.settlementService.AuthorizePayer(ctx.Request.Context(), userID.(string), payerID, req.Methods)
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		respondWithSettlementError(ctx, err)
 		return
 	}
 
-	utils.RespondWithJSON(ctx, http.StatusOK, settlements)
+	utils.RespondWithJSON(ctx, http.StatusOK, authorization)
+}
+
+func (c *SettlementController) RevokePayerAuthorization(ctx *gin.Context) {
+	payerID := ctx.Param("payerId")
+	if payerID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Payer ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	err := c.settlementService.RevokePayerAuthorization(ctx.Request.Context(), userID.(string), payerID)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, gin.H{"message": "Authorization revoked successfully"})
+}
+
+// respondWithSettlementError maps settlement workflow errors to HTTP status codes
+func respondWithSettlementError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotSettlementPayer), errors.Is(err, services.ErrNotSettlementPayee),
+		errors.Is(err, services.ErrNotGroupMember):
+		utils.RespondWithServiceError(ctx, http.StatusForbidden, err)
+	case errors.Is(err, services.ErrSettlementCompleted), errors.Is(err, services.ErrSettlementNotAwaiting),
+		errors.Is(err, services.ErrSettlementStateChanged), errors.Is(err, services.ErrSettlementNotPending),
+		errors.Is(err, services.ErrSettlementNotCancellable), errors.Is(err, services.ErrSettlementNotVoidable),
+		errors.Is(err, services.ErrSettlementNotCompleted):
+		utils.RespondWithServiceError(ctx, http.StatusConflict, err)
+	case errors.Is(err, services.ErrInvalidSettlementMethod), errors.Is(err, services.ErrSettlementAuthorizationSelf),
+		errors.Is(err, services.ErrInvalidCursor), errors.Is(err, services.ErrInvalidSettlementStatus),
+		errors.Is(err, services.ErrInvalidExportYear):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
 }
@@ -0,0 +1,127 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ShareLinkController struct {
+	shareLinkService *services.ShareLinkService
+}
+
+func NewShareLinkController(shareLinkService *services.ShareLinkService) *ShareLinkController {
+	return &ShareLinkController{shareLinkService: shareLinkService}
+}
+
+// CreateShareLink issues a token for the group's public summary. The token is only shown here.
+func (c *ShareLinkController) CreateShareLink(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	var req services.CreateShareLinkRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			utils.RespondWithBindingError(ctx, err)
+			return
+		}
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	link, err := c.shareLinkService.CreateShareLink(ctx.Request.Context(), groupID, userID.(string), req)
+	if err != nil {
+		respondWithShareLinkError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusCreated, link)
+}
+
+func (c *ShareLinkController) ListShareLinks(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	links, err := c.shareLinkService.ListShareLinks(ctx.Request.Context(), groupID, userID.(string))
+	if err != nil {
+		respondWithShareLinkError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, links)
+}
+
+func (c *ShareLinkController) RevokeShareLink(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	linkID := ctx.Param("linkId")
+	if groupID == "" || linkID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID and link ID are required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if err := c.shareLinkService.RevokeShareLink(ctx.Request.Context(), groupID, userID.(string), linkID); err != nil {
+		respondWithShareLinkError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, gin.H{"message": "Share link revoked successfully"})
+}
+
+// GetPublicSummary serves the read-only group summary behind a share link, without authentication
+func (c *ShareLinkController) GetPublicSummary(ctx *gin.Context) {
+	token := ctx.Param("token")
+	if token == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Token is required")
+		return
+	}
+
+	// The token is the credential, so keep the summary out of shared caches
+	ctx.Header("Cache-Control", "no-store")
+
+	summary, err := c.shareLinkService.GetPublicSummary(ctx.Request.Context(), token)
+	if err != nil {
+		respondWithShareLinkError(ctx, err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, summary)
+}
+
+func respondWithShareLinkError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotGroupAdmin):
+		utils.RespondWithServiceError(ctx, http.StatusForbidden, err)
+	case errors.Is(err, services.ErrInvalidShareLinkTTL):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrShareLinkUnavailable), errors.Is(err, services.ErrShareLinkNotFound):
+		utils.RespondWithServiceError(ctx, http.StatusNotFound, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
+}
@@ -0,0 +1,97 @@
+package controllers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type StatementController struct {
+	statementService *services.StatementService
+}
+
+func NewStatementController(statementService *services.StatementService) *StatementController {
+	return &StatementController{statementService: statementService}
+}
+
+func (c *StatementController) GetGroupStatement(ctx *gin.Context) {
+	groupID := ctx.Param("id")
+	if groupID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Group ID is required")
+		return
+	}
+
+	userID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	month := ctx.Param("month")
+	statement, err := c.statementService.GroupStatement(ctx.Request.Context(), groupID, userID.(string), month)
+	if err != nil {
+		respondWithStatementError(ctx, err)
+		return
+	}
+
+	respondWithStatementPDF(ctx, statement, fmt.Sprintf("group-%s-statement-%s.pdf", groupID, month))
+}
+
+func (c *StatementController) GetUserStatement(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	month := ctx.Param("month")
+	statement, err := c.statementService.UserStatement(ctx.Request.Context(), userID, month)
+	if err != nil {
+		respondWithStatementError(ctx, err)
+		return
+	}
+
+	respondWithStatementPDF(ctx, statement, fmt.Sprintf("statement-%s.pdf", month))
+}
+
+func respondWithStatementPDF(ctx *gin.Context, statement *models.Statement, filename string) {
+	var buf bytes.Buffer
+	if err := services.RenderStatementPDF(statement, &buf); err != nil {
+		log.Printf("Failed to render statement %s: %v", filename, err)
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to render statement")
+		return
+	}
+
+	ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	ctx.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+func respondWithStatementError(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidStatementMonth):
+		utils.RespondWithServiceError(ctx, http.StatusBadRequest, err)
+	case errors.Is(err, services.ErrNotGroupMember):
+		utils.RespondWithServiceError(ctx, http.StatusForbidden, err)
+	default:
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+	}
+}
@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"net/http"
+
+	"divvydoo/backend/internal/services"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SuggestionController struct {
+	suggestionService *services.SuggestionService
+}
+
+func NewSuggestionController(suggestionService *services.SuggestionService) *SuggestionController {
+	return &SuggestionController{suggestionService: suggestionService}
+}
+
+// GetGroupSuggestions lists the groups the caller could create with the people they keep
+// splitting non-group expenses with
+func (c *SuggestionController) GetGroupSuggestions(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	suggestions, err := c.suggestionService.GetGroupSuggestions(ctx.Request.Context(), userID)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, suggestions)
+}
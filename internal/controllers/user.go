@@ -1,7 +1,10 @@
 package controllers
 
 import (
+	"errors"
 	"net/http"
+	"strconv"
+	"time"
 
 	"divvydoo/backend/internal/services"
 	"divvydoo/backend/internal/utils"
@@ -13,25 +16,27 @@ import (
 type UserController struct {
 	userService *services.UserService
 	authService auth.JWTService
+	denylist    auth.TokenDenylist
 }
 
-func NewUserController(userService *services.UserService, authService auth.JWTService) *UserController {
+func NewUserController(userService *services.UserService, authService auth.JWTService, denylist auth.TokenDenylist) *UserController {
 	return &UserController{
 		userService: userService,
 		authService: authService,
+		denylist:    denylist,
 	}
 }
 
 func (c *UserController) CreateUser(ctx *gin.Context) {
 	var req services.CreateUserRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		utils.RespondWithBindingError(ctx, err)
 		return
 	}
 
 	user, err := c.userService.CreateUser(ctx.Request.Context(), req)
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
 		return
 	}
 
@@ -41,13 +46,17 @@ func (c *UserController) CreateUser(ctx *gin.Context) {
 func (c *UserController) Login(ctx *gin.Context) {
 	var req services.LoginRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		utils.RespondWithBindingError(ctx, err)
 		return
 	}
 
 	user, err := c.userService.ValidateCredentials(ctx.Request.Context(), req.Email, req.Password)
 	if err != nil {
-		utils.RespondWithError(ctx, http.StatusUnauthorized, err.Error())
+		if errors.Is(err, services.ErrAccountDisabled) {
+			utils.RespondWithServiceError(ctx, http.StatusForbidden, err)
+			return
+		}
+		utils.RespondWithServiceError(ctx, http.StatusUnauthorized, err)
 		return
 	}
 
@@ -65,6 +74,26 @@ func (c *UserController) Login(ctx *gin.Context) {
 	utils.RespondWithJSON(ctx, http.StatusOK, response)
 }
 
+func (c *UserController) Logout(ctx *gin.Context) {
+	tokenID := ctx.GetString("tokenID")
+	if tokenID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "Token does not support revocation")
+		return
+	}
+
+	expiresAt := ctx.GetTime("tokenExpiresAt")
+	if expiresAt.IsZero() {
+		expiresAt = time.Now().Add(24 * time.Hour)
+	}
+
+	if err := c.denylist.Revoke(ctx.Request.Context(), tokenID, expiresAt); err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to revoke token")
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, gin.H{"message": "Logged out successfully"})
+}
+
 func (c *UserController) GetUser(ctx *gin.Context) {
 	userID := ctx.Param("id")
 	if userID == "" {
@@ -87,7 +116,7 @@ func (c *UserController) GetUser(ctx *gin.Context) {
 
 	user, err := c.userService.GetUser(ctx.Request.Context(), userID)
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
 		return
 	}
 
@@ -115,19 +144,131 @@ func (c *UserController) UpdateUser(ctx *gin.Context) {
 
 	var req services.UpdateUserRequest
 	if err := ctx.ShouldBindJSON(&req); err != nil {
-		utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid request payload")
+		utils.RespondWithBindingError(ctx, err)
 		return
 	}
 
 	user, err := c.userService.UpdateUser(ctx.Request.Context(), userID, req)
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
 		return
 	}
 
 	utils.RespondWithJSON(ctx, http.StatusOK, user)
 }
 
+// DeleteUser deletes the caller's own account and revokes every token issued to them. Pass
+// force=true to delete an account that still has outstanding balances.
+func (c *UserController) DeleteUser(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	force := false
+	if value := ctx.Query("force"); value != "" {
+		var err error
+		if force, err = strconv.ParseBool(value); err != nil {
+			utils.RespondWithError(ctx, http.StatusBadRequest, "Invalid force")
+			return
+		}
+	}
+
+	if err := c.userService.DeleteAccount(ctx.Request.Context(), userID, force); err != nil {
+		switch {
+		case errors.Is(err, services.ErrUserHasBalances):
+			utils.RespondWithServiceError(ctx, http.StatusConflict, err)
+		case errors.Is(err, services.ErrUserNotFound):
+			utils.RespondWithServiceError(ctx, http.StatusNotFound, err)
+		default:
+			utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		}
+		return
+	}
+
+	now := time.Now()
+	if err := c.denylist.RevokeUser(ctx.Request.Context(), userID, now, now.Add(c.authService.TokenLifetime())); err != nil {
+		utils.RespondWithError(ctx, http.StatusInternalServerError, "Failed to revoke tokens")
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, gin.H{"message": "Account deleted successfully"})
+}
+
+// RequestPlaceholderClaim emails the caller a new code for claiming the placeholders imported under
+// their email. It answers the same whether or not there are any.
+func (c *UserController) RequestPlaceholderClaim(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	if err := c.userService.RequestPlaceholderClaim(ctx.Request.Context(), userID); err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusAccepted, gin.H{"message": "If members were imported under your email, a claim code is on its way"})
+}
+
+// ClaimPlaceholders hands the caller the placeholders the emailed code was issued for
+func (c *UserController) ClaimPlaceholders(ctx *gin.Context) {
+	userID := ctx.Param("id")
+	if userID == "" {
+		utils.RespondWithError(ctx, http.StatusBadRequest, "User ID is required")
+		return
+	}
+
+	requestingUserID, exists := ctx.Get("userID")
+	if !exists {
+		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
+		return
+	}
+
+	if requestingUserID.(string) != userID {
+		utils.RespondWithError(ctx, http.StatusForbidden, "Access denied")
+		return
+	}
+
+	var req services.ClaimPlaceholdersRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		utils.RespondWithBindingError(ctx, err)
+		return
+	}
+
+	claimed, err := c.userService.ClaimPlaceholders(ctx.Request.Context(), userID, req)
+	if err != nil {
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
+		return
+	}
+
+	utils.RespondWithJSON(ctx, http.StatusOK, claimed)
+}
+
 func (c *UserController) LookupUser(ctx *gin.Context) {
 	query := ctx.Query("q")
 	if query == "" {
@@ -136,15 +277,15 @@ func (c *UserController) LookupUser(ctx *gin.Context) {
 	}
 
 	// Verify user is authenticated
-	_, exists := ctx.Get("userID")
+	userID, exists := ctx.Get("userID")
 	if !exists {
 		utils.RespondWithError(ctx, http.StatusUnauthorized, "User not authenticated")
 		return
 	}
 
-	user, err := c.userService.LookupUser(ctx.Request.Context(), query)
+	user, err := c.userService.LookupUser(ctx.Request.Context(), userID.(string), query)
 	if err != nil {
-		utils.RespondWithError(ctx, utils.GetStatusCode(err), err.Error())
+		utils.RespondWithServiceError(ctx, utils.GetStatusCode(err), err)
 		return
 	}
 
@@ -0,0 +1,113 @@
+// Package metrics holds the Prometheus collectors for the API. Collectors are registered with
+// the default registry, which Handler serves alongside the Go runtime and process metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "divvydoo"
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	httpRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	httpRequestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_request_errors_total",
+		Help:      "HTTP requests answered with a 5xx status, by route and method.",
+	}, []string{"route", "method"})
+
+	workerRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "worker_runs_total",
+		Help:      "Background worker iterations by worker and outcome.",
+	}, []string{"worker", "outcome"})
+
+	workerRunDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "worker_run_duration_seconds",
+		Help:      "Time taken by one background worker iteration.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"worker"})
+
+	workerLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "worker_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful iteration of each background worker.",
+	}, []string{"worker"})
+
+	aggregationFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "aggregation_fallbacks_total",
+		Help:      "Requests answered with a stored result because the live aggregation exceeded its time budget.",
+	}, []string{"aggregation"})
+)
+
+func init() {
+	// Expose the expvar counters (panics, rate limiter, share rounding) to Prometheus as well
+	prometheus.MustRegister(collectors.NewExpvarCollector(map[string]*prometheus.Desc{
+		"panics_total": prometheus.NewDesc(namespace+"_panics_total",
+			"Handler panics recovered since startup.", nil, nil),
+		"rate_limit_tracked_keys": prometheus.NewDesc(namespace+"_rate_limit_tracked_keys",
+			"Client IPs or users currently tracked, per rate limiter.", []string{"limiter"}, nil),
+		"rate_limit_evictions": prometheus.NewDesc(namespace+"_rate_limit_evictions_total",
+			"Client IPs or users evicted because the rate limiter was full, per rate limiter.", []string{"limiter"}, nil),
+		"share_rounding_adjusted_expenses": prometheus.NewDesc(namespace+"_share_rounding_adjusted_expenses_total",
+			"Expenses whose shares were rounded away from the exact split.", nil, nil),
+		"share_rounding_adjustment_total": prometheus.NewDesc(namespace+"_share_rounding_adjustment_total",
+			"Total amount moved by rounding expense shares away from the exact split.", nil, nil),
+		"share_rounding_drift_total": prometheus.NewDesc(namespace+"_share_rounding_drift_total",
+			"Total amount by which expense shares missed their expense amounts.", nil, nil),
+		"share_rounding_drift_alerts": prometheus.NewDesc(namespace+"_share_rounding_drift_alerts_total",
+			"Groups whose accumulated share drift crossed the alert threshold.", nil, nil),
+	}))
+}
+
+// Handler serves every registered metric in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// ObserveRequest records one HTTP request. route is the route pattern, never the raw path,
+// so IDs in URLs don't create a series per resource.
+func ObserveRequest(route, method string, status int, duration time.Duration) {
+	httpRequests.WithLabelValues(route, method, strconv.Itoa(status)).Inc()
+	httpRequestDuration.WithLabelValues(route, method).Observe(duration.Seconds())
+	if status >= http.StatusInternalServerError {
+		httpRequestErrors.WithLabelValues(route, method).Inc()
+	}
+}
+
+// ObserveWorkerRun records one iteration of a background worker
+func ObserveWorkerRun(worker string, started time.Time, err error) {
+	workerRunDuration.WithLabelValues(worker).Observe(time.Since(started).Seconds())
+	if err != nil {
+		workerRuns.WithLabelValues(worker, "error").Inc()
+		return
+	}
+	workerRuns.WithLabelValues(worker, "success").Inc()
+	workerLastSuccess.WithLabelValues(worker).SetToCurrentTime()
+}
+
+// ObserveAggregationFallback records a request answered with a stale aggregation result
+func ObserveAggregationFallback(aggregation string) {
+	aggregationFallbacks.WithLabelValues(aggregation).Inc()
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	mongoCommands = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "mongo_commands_total",
+		Help:      "MongoDB commands by command name, collection and outcome.",
+	}, []string{"command", "collection", "outcome"})
+
+	mongoCommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mongo_command_duration_seconds",
+		Help:      "MongoDB command latency by command name and collection.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"command", "collection"})
+)
+
+// MongoMonitor returns a command monitor that records every command the repositories issue.
+// Pass it to the client with options.Client().SetMonitor.
+func MongoMonitor() *event.CommandMonitor {
+	// The collection is only known when a command starts, so it's kept until the command ends
+	var collections sync.Map // request ID -> collection name
+
+	finish := func(requestID int64, command string, seconds float64, outcome string) {
+		collection := ""
+		if value, ok := collections.LoadAndDelete(requestID); ok {
+			collection = value.(string)
+		}
+		mongoCommands.WithLabelValues(command, collection, outcome).Inc()
+		mongoCommandDuration.WithLabelValues(command, collection).Observe(seconds)
+	}
+
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			// The first element of a command document names the collection it targets
+			if value, err := e.Command.IndexErr(0); err == nil {
+				if collection, ok := value.Value().StringValueOK(); ok {
+					collections.Store(e.RequestID, collection)
+				}
+			}
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			finish(e.RequestID, e.CommandName, e.Duration.Seconds(), "success")
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			finish(e.RequestID, e.CommandName, e.Duration.Seconds(), "error")
+		},
+	}
+}
@@ -1,113 +1,201 @@
 package middleware
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"slices"
 	"strings"
-	"sync"
-	"time"
 
+	"divvydoo/backend/internal/utils"
 	"divvydoo/backend/pkg/auth"
 
 	"github.com/gin-gonic/gin"
 )
 
+// APIKeyHeader carries the API keys integrations use instead of a bearer token
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyAuthenticator checks the keys sent in APIKeyHeader
+type APIKeyAuthenticator interface {
+	// AuthenticateAPIKey returns the key's ID, the user it acts as and its scopes, or
+	// auth.ErrInvalidAPIKey
+	AuthenticateAPIKey(ctx context.Context, key string) (keyID string, userID string, scopes []string, err error)
+}
+
 type AuthMiddleware struct {
 	jwtService auth.JWTService
+	denylist   auth.TokenDenylist
+	apiKeys    APIKeyAuthenticator
 }
 
-func NewAuthMiddleware(jwtService auth.JWTService) *AuthMiddleware {
-	return &AuthMiddleware{jwtService: jwtService}
+func NewAuthMiddleware(jwtService auth.JWTService, denylist auth.TokenDenylist, apiKeys APIKeyAuthenticator) *AuthMiddleware {
+	return &AuthMiddleware{
+		jwtService: jwtService,
+		denylist:   denylist,
+		apiKeys:    apiKeys,
+	}
 }
 
 func (m *AuthMiddleware) Authenticate() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+		if key := c.GetHeader(APIKeyHeader); key != "" && authHeader == "" {
+			m.authenticateAPIKey(c, key)
+			return
+		}
 		if authHeader == "" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			utils.AbortWithError(c, http.StatusUnauthorized, utils.CodeUnauthorized, "Authorization header required")
 			return
 		}
 
 		parts := strings.SplitN(authHeader, " ", 2)
 		if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization header format"})
+			utils.AbortWithError(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid authorization header format")
 			return
 		}
 
 		claims, err := m.jwtService.ValidateToken(parts[1])
 		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			code := utils.CodeTokenInvalid
+			if errors.Is(err, auth.ErrExpiredToken) {
+				code = utils.CodeTokenExpired
+			}
+			utils.AbortWithError(c, http.StatusUnauthorized, code, err.Error())
 			return
 		}
 
+		// Reject tokens that were revoked via logout
+		if claims.ID != "" {
+			revoked, err := m.denylist.IsRevoked(c.Request.Context(), claims.ID)
+			if err != nil {
+				utils.AbortWithError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to verify token")
+				return
+			}
+			if revoked {
+				utils.AbortWithError(c, http.StatusUnauthorized, utils.CodeTokenRevoked, "token has been revoked")
+				return
+			}
+		}
+
+		// Reject tokens issued before all of the user's tokens were revoked, e.g. on account deletion
+		if claims.IssuedAt != nil {
+			revoked, err := m.denylist.IsUserRevoked(c.Request.Context(), claims.UserID, claims.IssuedAt.Time)
+			if err != nil {
+				utils.AbortWithError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to verify token")
+				return
+			}
+			if revoked {
+				utils.AbortWithError(c, http.StatusUnauthorized, utils.CodeTokenRevoked, "token has been revoked")
+				return
+			}
+		}
+
 		c.Set("userID", claims.UserID)
 		c.Set("email", claims.Email)
+		c.Set("tokenID", claims.ID)
+		if claims.ExpiresAt != nil {
+			c.Set("tokenExpiresAt", claims.ExpiresAt.Time)
+		}
 		c.Next()
 	}
 }
 
-func CORS() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Accept, Authorization")
-		c.Header("Access-Control-Expose-Headers", "Content-Length")
-		c.Header("Access-Control-Allow-Credentials", "true")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(http.StatusNoContent)
+// authenticateAPIKey lets an integration in as the user its key acts for. The key's scopes
+// decide which methods it may use: read for GET, HEAD and OPTIONS, write for the rest.
+func (m *AuthMiddleware) authenticateAPIKey(c *gin.Context, key string) {
+	if m.apiKeys == nil {
+		utils.AbortWithError(c, http.StatusUnauthorized, utils.CodeAPIKeyInvalid, "API keys are not accepted")
+		return
+	}
+
+	keyID, userID, scopes, err := m.apiKeys.AuthenticateAPIKey(c.Request.Context(), key)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidAPIKey) {
+			utils.AbortWithError(c, http.StatusUnauthorized, utils.CodeAPIKeyInvalid, err.Error())
 			return
 		}
+		utils.AbortWithError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to verify API key")
+		return
+	}
 
-		c.Next()
+	required := "write"
+	if isReadOnlyMethod(c.Request.Method) || readScopeRoutes[c.Request.Method+" "+c.FullPath()] {
+		required = "read"
+	}
+	if !slices.Contains(scopes, required) {
+		utils.AbortWithError(c, http.StatusForbidden, utils.CodeAPIKeyScope, "API key lacks the "+required+" scope")
+		return
 	}
+
+	c.Set("userID", userID)
+	c.Set("apiKeyID", keyID)
+	c.Next()
 }
 
-type rateLimiter struct {
-	requests map[string][]time.Time
-	mu       sync.Mutex
-	limit    int
-	window   time.Duration
+// readScopeRoutes are the routes that only read but aren't GET requests, as their input is too
+// large for a query string; the read scope covers them
+var readScopeRoutes = map[string]bool{
+	http.MethodPost + " /v1/expenses/batch-get": true,
+	http.MethodPost + " /v1/nettings/preview":   true,
 }
 
-func newRateLimiter(limit int) *rateLimiter {
-	return &rateLimiter{
-		requests: make(map[string][]time.Time),
-		limit:    limit,
-		window:   time.Second,
+// ReadScopeRoutes lists the routes the read scope covers beyond GET, HEAD and OPTIONS, as
+// "METHOD path"
+func ReadScopeRoutes() []string {
+	routes := make([]string, 0, len(readScopeRoutes))
+	for route := range readScopeRoutes {
+		routes = append(routes, route)
 	}
+	return routes
 }
 
-func (rl *rateLimiter) allow(ip string) bool {
-	rl.mu.Lock()
-	defer rl.mu.Unlock()
-
-	now := time.Now()
-	windowStart := now.Add(-rl.window)
-
-	// Clean old requests
-	var validRequests []time.Time
-	for _, t := range rl.requests[ip] {
-		if t.After(windowStart) {
-			validRequests = append(validRequests, t)
+// RequireUserToken keeps API keys out of routes that need the user themselves, such as
+// managing API keys, so a leaked key can't mint more. Must run after Authenticate.
+func RequireUserToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("apiKeyID") != "" {
+			utils.AbortWithError(c, http.StatusForbidden, utils.CodeUserTokenRequired, "This endpoint requires a user token, not an API key")
+			return
 		}
+		c.Next()
 	}
-	rl.requests[ip] = validRequests
+}
 
-	if len(rl.requests[ip]) >= rl.limit {
-		return false
+// RequireAdmin restricts a route group to the configured admin user IDs.
+// Must run after Authenticate.
+func RequireAdmin(adminUserIDs []string) gin.HandlerFunc {
+	admins := make(map[string]bool, len(adminUserIDs))
+	for _, id := range adminUserIDs {
+		admins[id] = true
 	}
 
-	rl.requests[ip] = append(rl.requests[ip], now)
-	return true
+	return func(c *gin.Context) {
+		if !admins[c.GetString("userID")] {
+			utils.AbortWithError(c, http.StatusForbidden, utils.CodeAdminRequired, "Admin access required")
+			return
+		}
+		c.Next()
+	}
 }
 
-func RateLimit(requestsPerSecond int) gin.HandlerFunc {
-	limiter := newRateLimiter(requestsPerSecond)
+// AdminChecker decides who may use the back office
+type AdminChecker interface {
+	IsAdmin(ctx context.Context, userID string) (bool, error)
+}
 
+// RequireAdminRole guards the back office. Unlike RequireAdmin it asks admins on every request,
+// so a user whose admin role is removed or whose account is disabled is locked out straight away.
+// Must run after Authenticate.
+func RequireAdminRole(admins AdminChecker) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ip := c.ClientIP()
-		if !limiter.allow(ip) {
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+		isAdmin, err := admins.IsAdmin(c.Request.Context(), c.GetString("userID"))
+		if err != nil {
+			utils.AbortWithError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to verify admin access")
+			return
+		}
+		if !isAdmin {
+			utils.AbortWithError(c, http.StatusForbidden, utils.CodeAdminRequired, "Admin access required")
 			return
 		}
 		c.Next()
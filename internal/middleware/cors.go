@@ -0,0 +1,85 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsExposedHeaders are the response headers browser clients may read
+var corsExposedHeaders = []string{
+	"Content-Length", "Content-Language", "ETag", "X-Request-ID", "Idempotent-Replayed", "X-Next-Cursor",
+	"X-Has-More", "X-RateLimit-Limit", "X-RateLimit-Remaining", "X-RateLimit-Reset", "Retry-After",
+}
+
+// CORSPolicy is which browser origins may call the API and how
+type CORSPolicy struct {
+	// AllowedOrigins are matched exactly against the Origin header; "*" allows any origin, but
+	// then without credentials, since browsers reject credentialed responses to a wildcard
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how many seconds browsers may cache a preflight response; zero leaves it to them
+	MaxAge int
+}
+
+// CORS answers cross-origin requests from the policy's allowed origins, echoing the origin back
+// rather than a wildcard. Requests from other origins get no CORS headers, so browsers block
+// them. Preflight requests are answered here and never reach the routes.
+func CORS(policy CORSPolicy) gin.HandlerFunc {
+	origins := make(map[string]bool, len(policy.AllowedOrigins))
+	anyOrigin := false
+	for _, origin := range policy.AllowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+			continue
+		}
+		origins[strings.TrimSuffix(origin, "/")] = true
+	}
+
+	methods := strings.Join(policy.AllowedMethods, ", ")
+	headers := strings.Join(policy.AllowedHeaders, ", ")
+	exposed := strings.Join(corsExposedHeaders, ", ")
+	maxAge := strconv.Itoa(policy.MaxAge)
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		preflight := c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != ""
+
+		if origin != "" {
+			// The response depends on the origin, so caches must keep them apart
+			c.Writer.Header().Add("Vary", "Origin")
+			switch {
+			case origins[origin]:
+				c.Header("Access-Control-Allow-Origin", origin)
+				if policy.AllowCredentials {
+					c.Header("Access-Control-Allow-Credentials", "true")
+				}
+			case anyOrigin:
+				c.Header("Access-Control-Allow-Origin", "*")
+			default:
+				origin = ""
+			}
+		}
+
+		if origin != "" {
+			c.Header("Access-Control-Expose-Headers", exposed)
+			if preflight {
+				c.Header("Access-Control-Allow-Methods", methods)
+				c.Header("Access-Control-Allow-Headers", headers)
+				if policy.MaxAge > 0 {
+					c.Header("Access-Control-Max-Age", maxAge)
+				}
+			}
+		}
+
+		if preflight {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,148 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+)
+
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+type idempotencyRecord struct {
+	Completed   bool   `json:"completed"`
+	Fingerprint string `json:"fingerprint"`
+	StatusCode  int    `json:"status_code,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotency replays the stored response when a request is retried with the same
+// Idempotency-Key header, so flaky networks don't create duplicate resources.
+// Keys are scoped per user and route. Must run after Authenticate.
+func Idempotency(client *redis.Client, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			utils.AbortWithError(c, http.StatusBadRequest, utils.CodeInvalidRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			utils.AbortWithError(c, http.StatusBadRequest, utils.CodeInvalidPayload, "Invalid request payload")
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(body)
+		fingerprint := hex.EncodeToString(sum[:])
+		redisKey := "idempotency:" + c.GetString("userID") + ":" + c.Request.Method + ":" + c.FullPath() + ":" + key
+		ctx := c.Request.Context()
+
+		pending, _ := json.Marshal(idempotencyRecord{Fingerprint: fingerprint})
+		acquired, err := client.SetNX(ctx, redisKey, pending, ttl).Result()
+		if err != nil {
+			// Idempotency is best-effort; don't fail the request if Redis is unavailable
+			log.Printf("Idempotency check failed for key %s: %v", key, err)
+			c.Next()
+			return
+		}
+
+		if !acquired {
+			replayStoredResponse(c, client, redisKey, fingerprint)
+			return
+		}
+
+		// Unless a response gets stored, release the key so the client can retry with it: after a
+		// 5xx, and also when the handler panics, in which case the panic carries on to the recovery
+		// middleware once the key is gone. Redis calls outlive the request's context, so a client
+		// that hangs up doesn't keep the key locked either.
+		storeCtx := context.WithoutCancel(ctx)
+		stored := false
+		defer func() {
+			if !stored {
+				client.Del(storeCtx, redisKey)
+			}
+		}()
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		c.Next()
+
+		status := recorder.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+
+		record, _ := json.Marshal(idempotencyRecord{
+			Completed:   true,
+			Fingerprint: fingerprint,
+			StatusCode:  status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err := client.Set(storeCtx, redisKey, record, ttl).Err(); err != nil {
+			log.Printf("Failed to store idempotent response for key %s: %v", key, err)
+			return
+		}
+		stored = true
+	}
+}
+
+func replayStoredResponse(c *gin.Context, client *redis.Client, redisKey string, fingerprint string) {
+	data, err := client.Get(c.Request.Context(), redisKey).Bytes()
+	if err != nil {
+		utils.AbortWithError(c, http.StatusConflict, utils.CodeIdempotencyInProgress, "A request with this Idempotency-Key is already being processed")
+		return
+	}
+
+	var record idempotencyRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		utils.AbortWithError(c, http.StatusInternalServerError, utils.CodeInternal, "Failed to read idempotent response")
+		return
+	}
+
+	if record.Fingerprint != fingerprint {
+		utils.AbortWithError(c, http.StatusUnprocessableEntity, utils.CodeIdempotencyKeyReused, "Idempotency-Key was already used with a different request payload")
+		return
+	}
+
+	if !record.Completed {
+		utils.AbortWithError(c, http.StatusConflict, utils.CodeIdempotencyInProgress, "A request with this Idempotency-Key is already being processed")
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(record.StatusCode, record.ContentType, record.Body)
+	c.Abort()
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"divvydoo/backend/internal/utils"
+	"divvydoo/backend/pkg/locale"
+
+	"github.com/gin-gonic/gin"
+)
+
+const CurrencyHeader = "X-Currency"
+
+// Locale negotiates the caller's language from Accept-Language and their display currency from
+// X-Currency, and stores both in the request context for services to read with locale.FromContext.
+// The chosen language is echoed in Content-Language.
+func Locale() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		l := locale.Locale{Language: locale.ParseAcceptLanguage(c.GetHeader("Accept-Language"))}
+
+		if currency := strings.ToUpper(strings.TrimSpace(c.GetHeader(CurrencyHeader))); currency != "" {
+			if !isCurrencyCode(currency) {
+				utils.AbortWithError(c, http.StatusBadRequest, utils.CodeInvalidCurrencyHeader, "X-Currency must be a 3-letter currency code")
+				return
+			}
+			l.Currency = currency
+		}
+
+		c.Request = c.Request.WithContext(locale.NewContext(c.Request.Context(), l))
+		c.Set("locale", l)
+		c.Header("Content-Language", l.Language)
+		c.Writer.Header().Add("Vary", "Accept-Language, "+CurrencyHeader)
+		c.Next()
+	}
+}
+
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
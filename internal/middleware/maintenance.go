@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maintenanceRetryAfter is the Retry-After hint, in seconds, sent while in maintenance mode
+const maintenanceRetryAfter = "120"
+
+// Maintenance rejects writes with 503 while enabled reports true, so data can't change during
+// an operation. Reads keep working, as do paths under exemptPrefixes (admin routes and login,
+// so an administrator can still switch maintenance mode off).
+func Maintenance(enabled func() bool, exemptPrefixes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !enabled() || isReadOnlyMethod(c.Request.Method) || hasAnyPrefix(c.Request.URL.Path, exemptPrefixes) {
+			c.Next()
+			return
+		}
+
+		c.Header("Retry-After", maintenanceRetryAfter)
+		utils.AbortWithError(c, http.StatusServiceUnavailable, utils.CodeMaintenanceMode, "Service is in maintenance mode")
+	}
+}
+
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func hasAnyPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+	"time"
+
+	"divvydoo/backend/internal/metrics"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// unmatchedRoute labels requests that didn't match any route, so scans of random paths share one series
+const unmatchedRoute = "unmatched"
+
+// Metrics records the count, latency and status of every request, labelled by route pattern
+func Metrics() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		metrics.ObserveRequest(route, c.Request.Method, c.Writer.Status(), time.Since(start))
+	}
+}
+
+// RequireToken guards an endpoint with a static bearer token, for scrapers that can't log in.
+// An empty token leaves the endpoint open.
+func RequireToken(token string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token == "" {
+			c.Next()
+			return
+		}
+
+		provided := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			utils.AbortWithError(c, http.StatusUnauthorized, utils.CodeTokenInvalid, "Invalid token")
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,275 @@
+package middleware
+
+import (
+	"container/list"
+	"expvar"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// rateLimiterMaxKeys caps how many client IPs or users a limiter tracks; the least recently seen are evicted first
+	rateLimiterMaxKeys = 100000
+	// rateLimiterSweepInterval is how often idle keys are dropped
+	rateLimiterSweepInterval = time.Minute
+)
+
+// rateLimitTrackedKeys and rateLimitEvictions are published per limiter name, so each limiter's
+// size and evictions can be read on their own
+var (
+	rateLimitTrackedKeys = expvar.NewMap("rate_limit_tracked_keys")
+	rateLimitEvictions   = expvar.NewMap("rate_limit_evictions")
+)
+
+type rateLimitEntry struct {
+	key      string
+	requests []time.Time
+	lastSeen time.Time
+}
+
+// rateLimitResult is a limiter's decision on one request, with what the X-RateLimit-*
+// headers report
+type rateLimitResult struct {
+	allowed   bool
+	limit     int
+	remaining int
+	reset     time.Time // when the oldest request in the window expires
+}
+
+// rateLimiter is a sliding window limiter keyed by client IP or user. Entries are kept in
+// least-recently-seen order so idle keys can be swept and the oldest evicted once maxKeys is reached.
+type rateLimiter struct {
+	name    string // labels the limiter's metrics
+	entries map[string]*list.Element
+	lru     *list.List // front is the most recently seen key
+	mu      sync.Mutex
+	limit   func() int
+	window  time.Duration
+	maxKeys int
+}
+
+func newRateLimiter(name string, limit func() int, window time.Duration) *rateLimiter {
+	rateLimitTrackedKeys.Add(name, 0)
+	rateLimitEvictions.Add(name, 0)
+	return &rateLimiter{
+		name:    name,
+		entries: make(map[string]*list.Element),
+		lru:     list.New(),
+		limit:   limit,
+		window:  window,
+		maxKeys: rateLimiterMaxKeys,
+	}
+}
+
+func (rl *rateLimiter) allow(key string) bool {
+	return rl.take(key, true).allowed
+}
+
+// take reports whether another request for key fits in the window and, if record is set and
+// it does, counts it
+func (rl *rateLimiter) take(key string, record bool) rateLimitResult {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	now := time.Now()
+	windowStart := now.Add(-rl.window)
+
+	element, ok := rl.entries[key]
+	if ok {
+		rl.lru.MoveToFront(element)
+	} else {
+		element = rl.lru.PushFront(&rateLimitEntry{key: key})
+		rl.entries[key] = element
+		rateLimitTrackedKeys.Add(rl.name, 1)
+		for rl.lru.Len() > rl.maxKeys {
+			rl.remove(rl.lru.Back())
+			rateLimitEvictions.Add(rl.name, 1)
+		}
+	}
+	entry := element.Value.(*rateLimitEntry)
+	entry.lastSeen = now
+
+	// Clean old requests
+	valid := entry.requests[:0]
+	for _, t := range entry.requests {
+		if t.After(windowStart) {
+			valid = append(valid, t)
+		}
+	}
+	entry.requests = valid
+
+	result := rateLimitResult{limit: rl.limit(), reset: now.Add(rl.window)}
+	if len(entry.requests) > 0 {
+		result.reset = entry.requests[0].Add(rl.window)
+	}
+	if len(entry.requests) >= result.limit {
+		return result
+	}
+
+	result.allowed = true
+	if record {
+		entry.requests = append(entry.requests, now)
+	}
+	result.remaining = result.limit - len(entry.requests)
+	return result
+}
+
+// sweep drops keys not seen within the window; their history no longer affects any decision
+func (rl *rateLimiter) sweep(now time.Time) {
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	windowStart := now.Add(-rl.window)
+	for element := rl.lru.Back(); element != nil; element = rl.lru.Back() {
+		if element.Value.(*rateLimitEntry).lastSeen.After(windowStart) {
+			return
+		}
+		rl.remove(element)
+	}
+}
+
+func (rl *rateLimiter) remove(element *list.Element) {
+	entry := rl.lru.Remove(element).(*rateLimitEntry)
+	delete(rl.entries, entry.key)
+	rateLimitTrackedKeys.Add(rl.name, -1)
+}
+
+func (rl *rateLimiter) sweepEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for now := range ticker.C {
+		rl.sweep(now)
+	}
+}
+
+// RateLimit limits each client IP to requestsPerSecond, which is read on every request so the
+// limit can be reloaded at runtime. Idle IPs are swept in the background and the number tracked
+// is capped, so memory stays bounded on public endpoints. name labels the limiter's metrics.
+func RateLimit(name string, requestsPerSecond func() int) gin.HandlerFunc {
+	limiter := newRateLimiter(name, requestsPerSecond, time.Second)
+	go limiter.sweepEvery(rateLimiterSweepInterval)
+
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		if !limiter.allow(ip) {
+			utils.AbortWithError(c, http.StatusTooManyRequests, utils.CodeRateLimited, "Rate limit exceeded")
+			return
+		}
+		c.Next()
+	}
+}
+
+// AnonymousRateLimit is RateLimit for requests nobody has authenticated yet. Requests with a
+// bearer token or API key are left to UserRateLimit, so users sharing an IP behind NAT don't
+// share a budget; those that end without Authenticate setting a user (rejected credentials, or
+// a public route that never checks them) count against the IP afterwards, and once it is used
+// up the IP's credentialed requests are turned away before authentication too. name labels the
+// limiter's metrics.
+func AnonymousRateLimit(name string, requestsPerSecond func() int) gin.HandlerFunc {
+	limiter := newRateLimiter(name, requestsPerSecond, time.Second)
+	go limiter.sweepEvery(rateLimiterSweepInterval)
+
+	return func(c *gin.Context) {
+		ip := c.ClientIP()
+		credentialed := strings.HasPrefix(strings.ToLower(c.GetHeader("Authorization")), "bearer ") || c.GetHeader(APIKeyHeader) != ""
+		if !credentialed {
+			result := limiter.take(ip, true)
+			setRateLimitHeaders(c, result)
+			if !result.allowed {
+				abortRateLimited(c, result)
+				return
+			}
+			c.Next()
+			return
+		}
+
+		if result := limiter.take(ip, false); !result.allowed {
+			setRateLimitHeaders(c, result)
+			abortRateLimited(c, result)
+			return
+		}
+		c.Next()
+		if c.GetString("userID") == "" {
+			limiter.take(ip, true)
+		}
+	}
+}
+
+// UserRateLimit limits each authenticated user, so it goes after Authenticate. Reads (GET, HEAD
+// and OPTIONS) and writes have separate per-minute budgets, read on every request so they can
+// be reloaded at runtime. Every response reports the budget it was counted against in the
+// X-RateLimit-* headers. The two limiters' metrics are labelled name_reads and name_writes.
+func UserRateLimit(name string, readsPerMinute, writesPerMinute func() int) gin.HandlerFunc {
+	reads := newRateLimiter(name+"_reads", readsPerMinute, time.Minute)
+	writes := newRateLimiter(name+"_writes", writesPerMinute, time.Minute)
+	go reads.sweepEvery(rateLimiterSweepInterval)
+	go writes.sweepEvery(rateLimiterSweepInterval)
+
+	return func(c *gin.Context) {
+		userID := c.GetString("userID")
+		if userID == "" {
+			c.Next()
+			return
+		}
+
+		limiter := writes
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			limiter = reads
+		}
+
+		result := limiter.take(userID, true)
+		setRateLimitHeaders(c, result)
+		if !result.allowed {
+			abortRateLimited(c, result)
+			return
+		}
+		c.Next()
+	}
+}
+
+// UserRouteRateLimit gives each authenticated user a per-second budget for the routes it guards,
+// on top of UserRateLimit, so it goes after Authenticate. The X-RateLimit-* headers keep reporting
+// the UserRateLimit budget unless this one turns the request away. name labels the limiter's metrics.
+func UserRouteRateLimit(name string, requestsPerSecond func() int) gin.HandlerFunc {
+	limiter := newRateLimiter(name, requestsPerSecond, time.Second)
+	go limiter.sweepEvery(rateLimiterSweepInterval)
+
+	return func(c *gin.Context) {
+		userID := c.GetString("userID")
+		if userID == "" {
+			c.Next()
+			return
+		}
+
+		if result := limiter.take(userID, true); !result.allowed {
+			setRateLimitHeaders(c, result)
+			abortRateLimited(c, result)
+			return
+		}
+		c.Next()
+	}
+}
+
+func setRateLimitHeaders(c *gin.Context, result rateLimitResult) {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(result.limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.remaining))
+	c.Header("X-RateLimit-Reset", strconv.FormatInt(result.reset.Unix(), 10))
+}
+
+func abortRateLimited(c *gin.Context, result rateLimitResult) {
+	retryAfter := int(math.Ceil(time.Until(result.reset).Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	utils.AbortWithError(c, http.StatusTooManyRequests, utils.CodeRateLimited, "Rate limit exceeded")
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"expvar"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+
+	"divvydoo/backend/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// panicsTotal counts recovered handler panics; it is published with the other expvar metrics
+var panicsTotal = expvar.NewInt("panics_total")
+
+// Recovery replaces gin.Recovery: a panicking handler gets the usual JSON error body instead of
+// an empty 500, and the panic is logged with its stack trace and request ID and counted in panics_total.
+// Requests whose client has already gone away (broken pipe) are still aborted silently by gin.
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(io.Discard, func(c *gin.Context, recovered any) {
+		panicsTotal.Add(1)
+
+		requestID, _ := c.Get("requestID")
+		slog.Error("panic recovered",
+			"request_id", requestID,
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"panic", fmt.Sprint(recovered),
+			"stack", string(debug.Stack()),
+		)
+
+		if c.Writer.Written() {
+			c.Abort()
+			return
+		}
+		utils.RespondWithError(c, http.StatusInternalServerError, "Internal server error")
+		c.Abort()
+	})
+}
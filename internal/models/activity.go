@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ActivityType string
+
+const (
+	ActivityExpense     ActivityType = "expense"
+	ActivitySettlement  ActivityType = "settlement"
+	ActivityGroupJoined ActivityType = "group_joined"
+)
+
+// Activity is one entry in a user's feed. Exactly one of Expense, Settlement or
+// Group is set, matching Type.
+type Activity struct {
+	ID         primitive.ObjectID `json:"-"` // source document ID, for cursor ordering
+	Type       ActivityType       `json:"type"`
+	OccurredAt time.Time          `json:"occurred_at"`
+	GroupID    *string            `json:"group_id,omitempty"`
+	GroupName  string             `json:"group_name,omitempty"`
+	Expense    *Expense           `json:"expense,omitempty"`
+	Settlement *Settlement        `json:"settlement,omitempty"`
+	Group      *GroupMember       `json:"group,omitempty"` // the user's membership when they joined
+}
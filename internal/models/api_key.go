@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// APIKey lets an integration, such as a webhook relay or a partner import, call the API as the
+// user who created it, through the X-API-Key header. Only a hash of the secret is stored; the
+// key itself is shown once, on creation.
+type APIKey struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	KeyID      string             `bson:"key_id" json:"key_id"`
+	UserID     string             `bson:"user_id" json:"user_id"`
+	Name       string             `bson:"name" json:"name"`
+	Prefix     string             `bson:"prefix" json:"prefix"` // start of the key, to tell keys apart
+	SecretHash string             `bson:"secret_hash" json:"-"`
+	Scopes     []APIKeyScope      `bson:"scopes" json:"scopes"`
+	CreatedAt  time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt  *time.Time         `bson:"expires_at,omitempty" json:"expires_at,omitempty"`
+	LastUsedAt *time.Time         `bson:"last_used_at,omitempty" json:"last_used_at,omitempty"`
+
+	Key string `bson:"-" json:"key,omitempty"`
+}
+
+// APIKeyScope limits what a key may do: read covers GET, HEAD and OPTIONS requests and the POST
+// routes that only read, write everything else
+type APIKeyScope string
+
+const (
+	APIKeyScopeRead  APIKeyScope = "read"
+	APIKeyScopeWrite APIKeyScope = "write"
+)
+
+func (s APIKeyScope) IsValid() bool {
+	return s == APIKeyScopeRead || s == APIKeyScopeWrite
+}
@@ -0,0 +1,18 @@
+package models
+
+// AvatarImage records where an avatar's files are stored. The original upload is kept so the
+// avatar can be cropped again without another upload.
+type AvatarImage struct {
+	Key        string     `bson:"key"`         // the cropped square clients are served
+	SourceKey  string     `bson:"source_key"`  // the original upload
+	SourceType string     `bson:"source_type"` // content type of the original upload
+	Crop       AvatarCrop `bson:"crop"`
+}
+
+// AvatarCrop is the square of the original image, in pixels from its top-left corner, that
+// becomes the avatar
+type AvatarCrop struct {
+	X    int `bson:"x" json:"x" binding:"min=0"`
+	Y    int `bson:"y" json:"y" binding:"min=0"`
+	Size int `bson:"size" json:"size" binding:"required,min=1"`
+}
@@ -0,0 +1,16 @@
+package models
+
+// AccountDetails is the back office's view of a user: the account, every group it belongs or
+// belonged to, and all of its balances
+type AccountDetails struct {
+	User     *User          `json:"user"`
+	Groups   []AccountGroup `json:"groups"`
+	Balances []*Balance     `json:"balances"`
+}
+
+type AccountGroup struct {
+	GroupID string   `json:"group_id"`
+	Name    string   `json:"name"`
+	Role    UserRole `json:"role"`
+	Active  bool     `json:"active"`
+}
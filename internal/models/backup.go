@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type BackupStatus string
+
+const (
+	BackupRunning   BackupStatus = "running"
+	BackupCompleted BackupStatus = "completed"
+	BackupFailed    BackupStatus = "failed"
+	// BackupExpired backups were removed by the retention policy; the record is kept for history
+	BackupExpired BackupStatus = "expired"
+)
+
+type Backup struct {
+	ID           primitive.ObjectID  `bson:"_id,omitempty" json:"-"`
+	BackupID     string              `bson:"backup_id" json:"backup_id"`
+	Status       BackupStatus        `bson:"status" json:"status"`
+	Path         string              `bson:"path" json:"path"`
+	SizeBytes    int64               `bson:"size_bytes" json:"size_bytes"`
+	Error        *string             `bson:"error,omitempty" json:"error,omitempty"`
+	StartedAt    time.Time           `bson:"started_at" json:"started_at"`
+	CompletedAt  *time.Time          `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	Verification *BackupVerification `bson:"verification,omitempty" json:"verification,omitempty"`
+}
+
+type BackupVerificationStatus string
+
+const (
+	BackupVerificationPassed BackupVerificationStatus = "passed"
+	BackupVerificationFailed BackupVerificationStatus = "failed"
+)
+
+// BackupVerification is the outcome of restoring a backup into a scratch database and checking it
+type BackupVerification struct {
+	Status      BackupVerificationStatus `bson:"status" json:"status"`
+	VerifiedAt  time.Time                `bson:"verified_at" json:"verified_at"`
+	Collections map[string]int64         `bson:"collections,omitempty" json:"collections,omitempty"` // documents restored per collection
+	Issues      []string                 `bson:"issues,omitempty" json:"issues,omitempty"`
+}
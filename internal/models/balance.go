@@ -14,6 +14,10 @@ type Balance struct {
 	Currency  string             `bson:"currency" json:"currency"`
 	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
 	Version   int                `bson:"version" json:"version"` // For optimistic concurrency
+
+	// GroupOutstanding is, in group balance lists, what the group's members owe in total in
+	// the currency
+	GroupOutstanding *float64 `bson:"-" json:"group_outstanding,omitempty"`
 }
 
 type BalanceHistory struct {
@@ -35,25 +39,163 @@ const (
 	BalanceChangeSettlement BalanceChangeType = "settlement"
 	BalanceChangeAdjustment BalanceChangeType = "adjustment"
 	BalanceChangeCorrection BalanceChangeType = "correction"
+	BalanceChangeNetting    BalanceChangeType = "netting"
 )
 
+func (t BalanceChangeType) IsValid() bool {
+	switch t {
+	case BalanceChangeExpense, BalanceChangeSettlement, BalanceChangeAdjustment, BalanceChangeCorrection, BalanceChangeNetting:
+		return true
+	}
+	return false
+}
+
+// BalanceHistoryEntry is a balance change together with what caused it
+type BalanceHistoryEntry struct {
+	BalanceHistory
+	Reference *BalanceHistoryReference `json:"reference,omitempty"`
+}
+
+// BalanceHistoryReference describes the expense or settlement behind a balance change.
+// It is nil when the referenced record no longer exists.
+type BalanceHistoryReference struct {
+	ExpenseTitle     string           `json:"expense_title,omitempty"`
+	SettlementStatus SettlementStatus `json:"settlement_status,omitempty"`
+	CounterpartID    string           `json:"counterpart_id,omitempty"` // the other party of a settlement
+	CounterpartName  string           `json:"counterpart_name,omitempty"`
+}
+
 type UserBalanceSummary struct {
-	UserID        string         `json:"user_id"`
-	TotalBalance  float64        `json:"total_balance"`
-	GroupBalances []GroupBalance `json:"group_balances"`
-	PeerBalances  []PeerBalance  `json:"peer_balances"`
-	Currency      string         `json:"currency"`
-	LastUpdated   time.Time      `json:"last_updated"`
+	UserID                string          `json:"user_id"`
+	TotalBalance          float64         `json:"total_balance"` // In Currency; excludes UnconvertedCurrencies
+	CurrencyTotals        []CurrencyTotal `json:"currency_totals"`
+	GroupBalances         []GroupBalance  `json:"group_balances"`
+	PeerBalances          []PeerBalance   `json:"peer_balances"`
+	Currency              string          `json:"currency"`
+	UnconvertedCurrencies []string        `json:"unconverted_currencies,omitempty"`
+	LastUpdated           time.Time       `json:"last_updated"`
+}
+
+type CurrencyTotal struct {
+	Currency string  `json:"currency"`
+	Balance  float64 `json:"balance"`
 }
 
 type GroupBalance struct {
 	GroupID   string  `json:"group_id"`
 	GroupName string  `json:"group_name"`
 	Balance   float64 `json:"balance"`
+	Currency  string  `json:"currency"`
 }
 
+// PeerBalance is what the user and another user owe each other outside groups, in one currency
 type PeerBalance struct {
 	PeerID   string  `json:"peer_id"`
 	PeerName string  `json:"peer_name"`
 	Balance  float64 `json:"balance"` // Positive: peer owes you, Negative: you owe peer
+	Currency string  `json:"currency"`
+}
+
+// DirectBalance is what two users owe each other from expenses and settlements outside any
+// group, in one currency. There is one per pair of users and currency; Balance is from the side
+// of the first of UserIDs, which are sorted.
+type DirectBalance struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	PairKey   string             `bson:"pair_key" json:"-"` // both user IDs, sorted
+	UserIDs   []string           `bson:"user_ids" json:"-"`
+	Currency  string             `bson:"currency" json:"currency"`
+	Balance   float64            `bson:"balance" json:"balance"` // Positive: the second user owes the first
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// For returns the balance from userID's side: positive when the other user owes userID
+func (b *DirectBalance) For(userID string) float64 {
+	if len(b.UserIDs) == 2 && b.UserIDs[1] == userID {
+		return -b.Balance
+	}
+	return b.Balance
+}
+
+// Counterpart returns the other user of the pair
+func (b *DirectBalance) Counterpart(userID string) string {
+	if len(b.UserIDs) != 2 {
+		return ""
+	}
+	if b.UserIDs[0] == userID {
+		return b.UserIDs[1]
+	}
+	return b.UserIDs[0]
+}
+
+// FriendBalance is what a user and another user owe each other outside groups, from the user's
+// side, with the payments that would settle it
+type FriendBalance struct {
+	UserID      string            `json:"user_id"`
+	FriendID    string            `json:"friend_id"`
+	FriendName  string            `json:"friend_name"`
+	Balances    []CurrencyTotal   `json:"balances"` // Positive: the friend owes the user
+	SettleUp    []SettleUpPayment `json:"settle_up"`
+	LastUpdated *time.Time        `json:"last_updated,omitempty"`
+}
+
+// SettleUpPayment is a suggested settlement: FromUserID pays ToUserID
+type SettleUpPayment struct {
+	FromUserID string  `json:"from_user_id"`
+	ToUserID   string  `json:"to_user_id"`
+	Amount     float64 `json:"amount"`
+	Currency   string  `json:"currency"`
+}
+
+// BalanceReconciliation compares a group's stored balances with the balances recomputed from
+// its expenses, completed settlements and recorded adjustments
+type BalanceReconciliation struct {
+	GroupID         string         `json:"group_id"`
+	BalancesChecked int            `json:"balances_checked"`
+	Drifts          []BalanceDrift `json:"drifts"`
+	Corrected       bool           `json:"corrected"` // the drifted balances were set to the recomputed ones
+	CheckedAt       time.Time      `json:"checked_at"`
+}
+
+// BalanceDrift is a stored balance that doesn't match the one recomputed from the ledger
+type BalanceDrift struct {
+	UserID     string  `json:"user_id"`
+	Currency   string  `json:"currency"`
+	Balance    float64 `json:"balance"` // as stored
+	Recomputed float64 `json:"recomputed"`
+	Drift      float64 `json:"drift"` // Balance - Recomputed
+}
+
+// BalanceSnapshot is a balance as it stood on a day. It is taken several times a day, each
+// replacing the last, so past days keep the balance from the last snapshot of the day.
+type BalanceSnapshot struct {
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	UserID   string             `bson:"user_id" json:"user_id"`
+	GroupID  *string            `bson:"group_id,omitempty" json:"group_id,omitempty"`
+	Currency string             `bson:"currency" json:"currency"`
+	Balance  float64            `bson:"balance" json:"balance"`
+	Date     string             `bson:"date" json:"date"` // YYYY-MM-DD, UTC
+	TakenAt  time.Time          `bson:"taken_at" json:"taken_at"`
+}
+
+// BalanceTimeline is a user's balances over time, one point per day, week or month
+type BalanceTimeline struct {
+	UserID      string          `json:"user_id"`
+	Granularity string          `json:"granularity"`
+	From        string          `json:"from"`
+	To          string          `json:"to"`
+	Totals      []BalanceSeries `json:"totals"` // per currency, all the user's balances added up
+	Series      []BalanceSeries `json:"series"` // per group, or personal balances, and currency
+}
+
+// BalanceSeries is one balance over time. Periods without a snapshot have no point.
+type BalanceSeries struct {
+	GroupID  *string        `json:"group_id,omitempty"`
+	Currency string         `json:"currency"`
+	Points   []BalancePoint `json:"points"`
+}
+
+// BalancePoint is the balance at the end of the period starting on Date
+type BalancePoint struct {
+	Date    string  `json:"date"`
+	Balance float64 `json:"balance"`
 }
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type BalanceTaskStatus string
+
+const (
+	BalanceTaskPending BalanceTaskStatus = "pending"
+	// BalanceTaskFailed tasks ran out of attempts; their expense never reached the balances
+	BalanceTaskFailed BalanceTaskStatus = "failed"
+)
+
+// BalanceTask is an expense's balance update queued for the balance worker, in async balance
+// mode. It is written in the same transaction as the expense and removed in the same one as the
+// balance update, so every update is applied exactly once.
+type BalanceTask struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	TaskID      string             `bson:"task_id" json:"task_id"`
+	GroupID     *string            `bson:"group_id,omitempty" json:"group_id,omitempty"`
+	Expense     Expense            `bson:"expense" json:"expense"` // as it was when it came to affect balances
+	Status      BalanceTaskStatus  `bson:"status" json:"status"`
+	Attempts    int                `bson:"attempts" json:"attempts"`
+	AvailableAt time.Time          `bson:"available_at" json:"available_at"` // not claimed again before then
+	LastError   string             `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
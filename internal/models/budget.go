@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Budget alert thresholds, in percent of a budget
+const (
+	BudgetWarningThreshold  = 80
+	BudgetExceededThreshold = 100
+)
+
+// GroupBudget is how much a group means to spend per calendar month (UTC), overall and per
+// expense category, in the group's currency. An amount of 0 means no overall budget.
+type GroupBudget struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	GroupID    string             `bson:"group_id" json:"group_id"`
+	Currency   string             `bson:"currency" json:"currency"`
+	Amount     float64            `bson:"amount,omitempty" json:"amount,omitempty"`
+	Categories map[string]float64 `bson:"categories,omitempty" json:"categories,omitempty"`
+	UpdatedBy  string             `bson:"updated_by" json:"updated_by"`
+	UpdatedAt  time.Time          `bson:"updated_at" json:"updated_at"`
+	// Alerts are the thresholds members were already told about, so each is announced once a month
+	Alerts []BudgetAlert `bson:"alerts,omitempty" json:"-"`
+}
+
+// BudgetAlert records that spending crossed a threshold of a budget in a month ("2006-01").
+// An empty Category is the overall budget.
+type BudgetAlert struct {
+	Period    string `bson:"period"`
+	Category  string `bson:"category,omitempty"`
+	Threshold int    `bson:"threshold"`
+}
+
+// BudgetStatus is a group's spending this month against its budget. Only expenses in the
+// budget's currency count; the currencies left out are listed in ExcludedCurrencies.
+type BudgetStatus struct {
+	GroupID            string       `json:"group_id"`
+	Month              string       `json:"month"`
+	Currency           string       `json:"currency"`
+	Spent              float64      `json:"spent"`
+	Total              *BudgetLine  `json:"total,omitempty"`
+	Categories         []BudgetLine `json:"categories"`
+	ExcludedCurrencies []string     `json:"excluded_currencies,omitempty"`
+	GeneratedAt        time.Time    `json:"generated_at"`
+	// Stale is set on a stored status served because a fresh one took too long
+	Stale bool `json:"stale,omitempty"`
+}
+
+// BudgetLine is one budget's use. PercentUsed is rounded to a whole percent.
+type BudgetLine struct {
+	Category    string  `json:"category,omitempty"`
+	Budget      float64 `json:"budget"`
+	Spent       float64 `json:"spent"`
+	Remaining   float64 `json:"remaining"`
+	PercentUsed int     `json:"percent_used"`
+}
@@ -0,0 +1,18 @@
+package models
+
+// CategoryCatalog is the canonical list of expense categories clients render. Version is bumped
+// whenever the catalog changes.
+type CategoryCatalog struct {
+	Version    int        `json:"version"`
+	Categories []Category `json:"categories"`
+}
+
+// Category describes how to show an expense category. Expenses store the ID; LabelKey is the
+// translation key and Label the English fallback.
+type Category struct {
+	ID       string `json:"id"`
+	LabelKey string `json:"label_key"`
+	Label    string `json:"label"`
+	Icon     string `json:"icon"`
+	Color    string `json:"color"`
+}
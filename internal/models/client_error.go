@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ClientErrorReport is an error captured by a mobile or web client and sent to us for triage
+type ClientErrorReport struct {
+	ID              primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	ReportID        string                 `bson:"report_id" json:"report_id"`
+	UserID          string                 `bson:"user_id" json:"user_id"`
+	RequestID       string                 `bson:"request_id,omitempty" json:"request_id,omitempty"` // X-Request-ID of the failing API call, if any
+	IntakeRequestID string                 `bson:"intake_request_id" json:"intake_request_id"`
+	Platform        string                 `bson:"platform" json:"platform"`
+	AppVersion      string                 `bson:"app_version" json:"app_version"`
+	Message         string                 `bson:"message" json:"message"`
+	Stack           string                 `bson:"stack,omitempty" json:"stack,omitempty"`
+	Context         map[string]interface{} `bson:"context,omitempty" json:"context,omitempty"`
+	OccurredAt      time.Time              `bson:"occurred_at" json:"occurred_at"`
+	ReceivedAt      time.Time              `bson:"received_at" json:"received_at"`
+}
+
+type ClientErrorReportRequest struct {
+	RequestID  string                 `json:"request_id,omitempty"`
+	Platform   string                 `json:"platform" binding:"required,oneof=ios android web"`
+	AppVersion string                 `json:"app_version" binding:"required"`
+	Message    string                 `json:"message" binding:"required,max=2000"`
+	Stack      string                 `json:"stack,omitempty" binding:"max=20000"`
+	Context    map[string]interface{} `json:"context,omitempty"`
+	OccurredAt *time.Time             `json:"occurred_at,omitempty"`
+}
@@ -15,19 +15,68 @@ const (
 	SplitShares     SplitType = "shares"
 )
 
+func (t SplitType) IsValid() bool {
+	switch t {
+	case SplitEqual, SplitExact, SplitPercentage, SplitShares:
+		return true
+	}
+	return false
+}
+
 type Expense struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	ExpenseID string             `bson:"expense_id" json:"expense_id"`
-	GroupID   *string            `bson:"group_id,omitempty" json:"group_id,omitempty"`
-	CreatorID string             `bson:"creator_id" json:"creator_id"`
-	Title     string             `bson:"title" json:"title"`
-	Amount    float64            `bson:"amount" json:"amount"`
-	Currency  string             `bson:"currency" json:"currency"`
-	PaidBy    []PaidBy           `bson:"paid_by" json:"paid_by"`
-	Split     SplitDetail        `bson:"split" json:"split"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
-	IsDeleted bool               `bson:"is_deleted" json:"is_deleted"`
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	ExpenseID   string             `bson:"expense_id" json:"expense_id"`
+	GroupID     *string            `bson:"group_id,omitempty" json:"group_id,omitempty"`
+	CreatorID   string             `bson:"creator_id" json:"creator_id"`
+	Title       string             `bson:"title" json:"title"`
+	Description string             `bson:"description,omitempty" json:"description,omitempty"`
+	Notes       string             `bson:"notes,omitempty" json:"notes,omitempty"`
+	Location    *ExpenseLocation   `bson:"location,omitempty" json:"location,omitempty"`
+	Amount      float64            `bson:"amount" json:"amount"`
+	Currency    string             `bson:"currency" json:"currency"`
+	Category    string             `bson:"category,omitempty" json:"category,omitempty"`
+	PaidBy      []PaidBy           `bson:"paid_by" json:"paid_by"`
+	Split       SplitDetail        `bson:"split" json:"split"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+	IsDeleted   bool               `bson:"is_deleted" json:"is_deleted"`
+	// Status is only set on expenses that went through approval
+	Status       ExpenseStatus `bson:"status,omitempty" json:"status,omitempty"`
+	ReviewedBy   *string       `bson:"reviewed_by,omitempty" json:"reviewed_by,omitempty"`
+	ReviewedAt   *time.Time    `bson:"reviewed_at,omitempty" json:"reviewed_at,omitempty"`
+	RejectReason *string       `bson:"reject_reason,omitempty" json:"reject_reason,omitempty"`
+	// Date is when the money was spent, as opposed to when the expense was added. Clients may
+	// set it; it defaults to CreatedAt. Listings are ordered and reports bucketed by it.
+	Date time.Time `bson:"date" json:"date"`
+}
+
+type ExpenseStatus string
+
+// Expense approval:
+//
+//	pending_approval --admin approves--> approved
+//	        |
+//	        +---------admin rejects----> rejected
+//
+// A group expense above the group's approval threshold that wasn't added by an admin starts out
+// pending_approval. Until an admin approves it, it moves no balance and counts towards no
+// totals; a rejected expense never does. Expenses that didn't need approval have no status.
+const (
+	ExpenseStatusPendingApproval ExpenseStatus = "pending_approval"
+	ExpenseStatusApproved        ExpenseStatus = "approved"
+	ExpenseStatusRejected        ExpenseStatus = "rejected"
+)
+
+// AffectsBalances reports whether the expense's split is applied to balances
+func (e *Expense) AffectsBalances() bool {
+	return e.Status == "" || e.Status == ExpenseStatusApproved
+}
+
+// ExpenseLocation is where an expense was made. The coordinates are optional but come as a pair.
+type ExpenseLocation struct {
+	Name      string   `bson:"name" json:"name"`
+	Latitude  *float64 `bson:"lat,omitempty" json:"lat,omitempty"`
+	Longitude *float64 `bson:"lng,omitempty" json:"lng,omitempty"`
 }
 
 type PaidBy struct {
@@ -43,4 +92,7 @@ type SplitDetail struct {
 type SplitShare struct {
 	UserID string  `bson:"user_id" json:"user_id"`
 	Value  float64 `bson:"value" json:"value"`
+	// Explanation shows how the server derived the share, e.g. "20% of 150.00 USD = 30.00 USD", in the
+	// language of the request that created it
+	Explanation string `bson:"explanation,omitempty" json:"explanation,omitempty"`
 }
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ExpenseRevisionAction string
+
+const (
+	ExpenseRevisionCreated       ExpenseRevisionAction = "created"
+	ExpenseRevisionImported      ExpenseRevisionAction = "imported"
+	ExpenseRevisionUpdated       ExpenseRevisionAction = "updated"
+	ExpenseRevisionRecategorized ExpenseRevisionAction = "recategorized"
+	ExpenseRevisionApproved      ExpenseRevisionAction = "approved"
+	ExpenseRevisionRejected      ExpenseRevisionAction = "rejected"
+)
+
+// ExpenseRevision records one change to an expense: who made it, when, and each changed field's
+// value before and after. Revisions of created and imported expenses have no changes.
+type ExpenseRevision struct {
+	ID         primitive.ObjectID    `bson:"_id,omitempty" json:"-"`
+	RevisionID string                `bson:"revision_id" json:"revision_id"`
+	ExpenseID  string                `bson:"expense_id" json:"expense_id"`
+	GroupID    *string               `bson:"group_id,omitempty" json:"group_id,omitempty"`
+	Action     ExpenseRevisionAction `bson:"action" json:"action"`
+	ChangedBy  string                `bson:"changed_by" json:"changed_by"`
+	ChangedAt  time.Time             `bson:"changed_at" json:"changed_at"`
+	Changes    []ExpenseFieldChange  `bson:"changes,omitempty" json:"changes,omitempty"`
+}
+
+// ExpenseFieldChange is a field's value before and after a change, as the expense's JSON shows it
+type ExpenseFieldChange struct {
+	Field  string      `bson:"field" json:"field"`
+	Before interface{} `bson:"before" json:"before"`
+	After  interface{} `bson:"after" json:"after"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import "time"
+
+// FairnessReport compares, per currency, what each member paid for with what they consumed over a
+// period. It shows who keeps fronting the money even when balances are settled up regularly.
+type FairnessReport struct {
+	GroupID     string               `json:"group_id"`
+	From        *time.Time           `json:"from,omitempty"` // absent when the period is the group's whole history
+	To          time.Time            `json:"to"`             // exclusive
+	Currencies  []FairnessByCurrency `json:"currencies"`
+	GeneratedAt time.Time            `json:"generated_at"`
+	// Stale is set on a stored report served because a fresh one took too long; GeneratedAt says
+	// how old it is
+	Stale bool `json:"stale,omitempty"`
+}
+
+// FairnessByCurrency is one currency's share of the report. FairnessIndex is 1 when every member
+// paid exactly what they consumed and falls towards 0 the more one-sided the paying is.
+type FairnessByCurrency struct {
+	Currency      string           `json:"currency"`
+	Total         float64          `json:"total"`
+	FairnessIndex float64          `json:"fairness_index"`
+	Members       []MemberFairness `json:"members"`
+}
+
+// MemberFairness is what a member paid and consumed. Covered is paid minus consumed: positive for
+// members who covered others. PaidRatio is nil for members who consumed nothing.
+type MemberFairness struct {
+	UserID    string   `json:"user_id"`
+	Name      string   `json:"name,omitempty"`
+	Paid      float64  `json:"paid"`
+	Consumed  float64  `json:"consumed"`
+	Covered   float64  `json:"covered"`
+	PaidRatio *float64 `json:"paid_ratio,omitempty"`
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type FriendshipStatus string
+
+// A friend request is pending until the addressee accepts it. Declined and withdrawn requests
+// and ended friendships are deleted, so either user can send a new request later.
+const (
+	FriendshipPending  FriendshipStatus = "pending"
+	FriendshipAccepted FriendshipStatus = "accepted"
+)
+
+// Friendship connects two users outside any group. There is at most one per pair of users,
+// whichever of them sent the request.
+type Friendship struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	FriendshipID string             `bson:"friendship_id" json:"friendship_id"`
+	RequesterID  string             `bson:"requester_id" json:"requester_id"`
+	AddresseeID  string             `bson:"addressee_id" json:"addressee_id"`
+	UserIDs      []string           `bson:"user_ids" json:"-"` // both users, for finding either's friendships
+	PairKey      string             `bson:"pair_key" json:"-"` // both user IDs, sorted, unique per pair
+	Status       FriendshipStatus   `bson:"status" json:"status"`
+	CreatedAt    time.Time          `bson:"created_at" json:"created_at"`
+	AcceptedAt   *time.Time         `bson:"accepted_at,omitempty" json:"accepted_at,omitempty"`
+}
+
+// Friend is one of a user's accepted friendships, from that user's side
+type Friend struct {
+	UserID       string    `json:"user_id"`
+	Name         string    `json:"name"`
+	Email        string    `json:"email"`
+	AvatarURL    string    `json:"avatar_url,omitempty"`
+	FriendshipID string    `json:"friendship_id"`
+	Since        time.Time `json:"since"`
+}
+
+// FriendRequest is a pending friendship from the viewer's side: UserID is the other user
+type FriendRequest struct {
+	FriendshipID string    `json:"friendship_id"`
+	UserID       string    `json:"user_id"`
+	Name         string    `json:"name"`
+	AvatarURL    string    `json:"avatar_url,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
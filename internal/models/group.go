@@ -7,14 +7,128 @@ import (
 )
 
 type Group struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	GroupID   string             `bson:"group_id" json:"group_id"`
-	Name      string             `bson:"name" json:"name"`
-	Members   []GroupMember      `bson:"members" json:"members"`
-	Currency  string             `bson:"currency" json:"currency"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
-	IsActive  bool               `bson:"is_active" json:"is_active"`
+	ID       primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	GroupID  string             `bson:"group_id" json:"group_id"`
+	Name     string             `bson:"name" json:"name"`
+	Members  []GroupMember      `bson:"members" json:"members"`
+	Currency string             `bson:"currency" json:"currency"`
+	Settings GroupSettings      `bson:"settings" json:"settings"`
+	// CurrencyChanges lists every change of the group's currency, oldest first
+	CurrencyChanges []CurrencyChange `bson:"currency_changes,omitempty" json:"currency_changes,omitempty"`
+	// SettlementVersion is bumped by transactions that create settlements in the group or net
+	// debts across it, only so that they conflict with each other and with currency conversions
+	SettlementVersion int64        `bson:"settlement_version,omitempty" json:"-"`
+	AvatarURL         string       `bson:"avatar_url,omitempty" json:"avatar_url,omitempty"`
+	Avatar            *AvatarImage `bson:"avatar,omitempty" json:"-"`
+	CreatedAt         time.Time    `bson:"created_at" json:"created_at"`
+	UpdatedAt         time.Time    `bson:"updated_at" json:"updated_at"`
+	IsActive          bool         `bson:"is_active" json:"is_active"`
+}
+
+// GroupSettings holds per-group policy. The zero value is the default policy,
+// so groups created before a setting existed behave sensibly without a migration.
+type GroupSettings struct {
+	SettlementConfirmation SettlementConfirmationPolicy `bson:"settlement_confirmation,omitempty" json:"settlement_confirmation"`
+	// AutoConfirmAfterHours completes settlements the payee hasn't confirmed or rejected
+	// within this many hours. Nil uses the server default; 0 disables auto-confirmation.
+	AutoConfirmAfterHours *int `bson:"auto_confirm_after_hours,omitempty" json:"auto_confirm_after_hours,omitempty"`
+	// MultiCurrency allows expenses in currencies other than the group's. Balances are
+	// tracked per currency, so these never get summed with the group currency.
+	MultiCurrency bool `bson:"multi_currency,omitempty" json:"multi_currency"`
+	// ExpenseSoftLimits maps a currency to the amount above which an expense must be explicitly
+	// confirmed. Currencies not listed use the server default; 0 turns the check off.
+	ExpenseSoftLimits map[string]float64 `bson:"expense_soft_limits,omitempty" json:"expense_soft_limits,omitempty"`
+	// CrossGroupNetting lets two members offset what they owe each other here against what
+	// they owe each other in another group that allows it too
+	CrossGroupNetting bool `bson:"cross_group_netting,omitempty" json:"cross_group_netting"`
+	// DefaultSplitType is used for expenses created without a split type. Empty means the
+	// split type must always be given.
+	DefaultSplitType SplitType `bson:"default_split_type,omitempty" json:"default_split_type,omitempty"`
+	// SimplifyDebts tells clients to suggest settling up with the fewest transfers across the
+	// group rather than member by member
+	SimplifyDebts bool `bson:"simplify_debts,omitempty" json:"simplify_debts"`
+	// AllowMemberInvites lets every member, not only admins, add members to the group
+	AllowMemberInvites bool `bson:"allow_member_invites,omitempty" json:"allow_member_invites"`
+	// ExpenseApprovalThreshold is the amount above which expenses added by members other than
+	// admins wait for an admin's approval before they count; 0 turns approval off. Amounts are
+	// compared as is, whatever their currency.
+	ExpenseApprovalThreshold float64 `bson:"expense_approval_threshold,omitempty" json:"expense_approval_threshold,omitempty"`
+	// PrivateBalances shows members other than admins only their own balance and the group's
+	// outstanding total in the group balances, and only their own balances on group statements
+	PrivateBalances bool `bson:"private_balances,omitempty" json:"private_balances"`
+}
+
+type SettlementConfirmationPolicy string
+
+const (
+	// SettlementConfirmationRequired makes the payee confirm receipt before balances change
+	SettlementConfirmationRequired SettlementConfirmationPolicy = "required"
+	// SettlementConfirmationNone lets the payer complete a settlement on their own
+	SettlementConfirmationNone SettlementConfirmationPolicy = "none"
+)
+
+func (p SettlementConfirmationPolicy) IsValid() bool {
+	return p == SettlementConfirmationRequired || p == SettlementConfirmationNone
+}
+
+// RequiresSettlementConfirmation reports whether settlements in the group wait for the payee
+func (s GroupSettings) RequiresSettlementConfirmation() bool {
+	return s.SettlementConfirmation != SettlementConfirmationNone
+}
+
+// CurrencyChange records an admin changing the group's currency from From to To
+type CurrencyChange struct {
+	From string             `bson:"from" json:"from"`
+	To   string             `bson:"to" json:"to"`
+	Mode CurrencyChangeMode `bson:"mode" json:"mode"`
+	// Rate is how many To one From was converted at; only set in convert mode
+	Rate      float64   `bson:"rate,omitempty" json:"rate,omitempty"`
+	ChangedBy string    `bson:"changed_by" json:"changed_by"`
+	ChangedAt time.Time `bson:"changed_at" json:"changed_at"`
+}
+
+// CurrencyChangeMode says what happens to the balances in the old currency when a group changes
+// currency. Expenses and settlements always keep the currency they were recorded in.
+type CurrencyChangeMode string
+
+const (
+	// CurrencyChangeConvert converts the balances in the old currency into the new one at the
+	// current exchange rate, recording an adjustment for each member
+	CurrencyChangeConvert CurrencyChangeMode = "convert"
+	// CurrencyChangeKeep leaves the balances in the old currency; the group's totals report them
+	// converted into the new one
+	CurrencyChangeKeep CurrencyChangeMode = "keep"
+)
+
+func (m CurrencyChangeMode) IsValid() bool {
+	return m == CurrencyChangeConvert || m == CurrencyChangeKeep
+}
+
+// KeptCurrencies are the group's former currencies whose balances were kept when it changed
+// currency, other than its current one
+func (g *Group) KeptCurrencies() []string {
+	var kept []string
+	seen := map[string]bool{g.Currency: true}
+	for _, change := range g.CurrencyChanges {
+		if change.Mode == CurrencyChangeKeep && !seen[change.From] {
+			seen[change.From] = true
+			kept = append(kept, change.From)
+		}
+	}
+	return kept
+}
+
+// UserGroupSummary is a group as seen from one member's group list
+type UserGroupSummary struct {
+	GroupID        string          `json:"group_id"`
+	Name           string          `json:"name"`
+	AvatarURL      string          `json:"avatar_url,omitempty"`
+	Currency       string          `json:"currency"`
+	Role           UserRole        `json:"role"`
+	MemberCount    int             `json:"member_count"`
+	Balance        float64         `json:"balance"`  // in the group's currency, including kept currencies converted into it
+	Balances       []CurrencyTotal `json:"balances"` // every currency the user has a balance in
+	LastActivityAt time.Time       `json:"last_activity_at"`
 }
 
 type GroupMember struct {
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GroupWebhook is a group's outbound webhook: the group's expense and settlement events are
+// POSTed to URL as they happen, so accounting tools and spreadsheets can stay in sync. Each
+// delivery is signed with Secret, which is shown once, when it is created or rotated.
+type GroupWebhook struct {
+	ID                  primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	GroupID             string             `bson:"group_id" json:"group_id"`
+	URL                 string             `bson:"url" json:"url"`
+	Secret              string             `bson:"secret" json:"-"`
+	CreatedBy           string             `bson:"created_by" json:"created_by"`
+	CreatedAt           time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt           time.Time          `bson:"updated_at" json:"updated_at"`
+	LastDelivery        *WebhookDelivery   `bson:"last_delivery,omitempty" json:"last_delivery,omitempty"`
+	ConsecutiveFailures int                `bson:"consecutive_failures" json:"consecutive_failures"`
+
+	SigningSecret string `bson:"-" json:"signing_secret,omitempty"`
+}
+
+// WebhookDelivery is the outcome of the last attempt to deliver an event. StatusCode is 0 when
+// no response was received.
+type WebhookDelivery struct {
+	DeliveryID  string    `bson:"delivery_id" json:"delivery_id"`
+	EventType   string    `bson:"event_type" json:"event_type"`
+	AttemptedAt time.Time `bson:"attempted_at" json:"attempted_at"`
+	Succeeded   bool      `bson:"succeeded" json:"succeeded"`
+	StatusCode  int       `bson:"status_code,omitempty" json:"status_code,omitempty"`
+	Error       string    `bson:"error,omitempty" json:"error,omitempty"`
+}
+
+// WebhookPayload is the JSON body of a delivery. Data is the expense or settlement as the API
+// returns it.
+type WebhookPayload struct {
+	DeliveryID string      `json:"delivery_id"`
+	Type       string      `json:"type"`
+	GroupID    string      `json:"group_id"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data,omitempty"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Job struct {
+	ID         primitive.ObjectID     `bson:"_id,omitempty" json:"id"`
+	JobID      string                 `bson:"job_id" json:"job_id"`
+	Type       string                 `bson:"type" json:"type"`
+	Status     JobStatus              `bson:"status" json:"status"`
+	Progress   int                    `bson:"progress" json:"progress"`
+	Total      int                    `bson:"total" json:"total"`
+	Result     map[string]interface{} `bson:"result,omitempty" json:"result,omitempty"`
+	Error      *string                `bson:"error,omitempty" json:"error,omitempty"`
+	CreatedBy  string                 `bson:"created_by" json:"created_by"`
+	CreatedAt  time.Time              `bson:"created_at" json:"created_at"`
+	StartedAt  *time.Time             `bson:"started_at,omitempty" json:"started_at,omitempty"`
+	FinishedAt *time.Time             `bson:"finished_at,omitempty" json:"finished_at,omitempty"`
+	// HeartbeatAt is refreshed while the job runs; a job that stops refreshing it was lost with
+	// the process running it
+	HeartbeatAt *time.Time `bson:"heartbeat_at,omitempty" json:"-"`
+	// Lock is set to the type of jobs that only run one at a time until they finish, and is
+	// unique among the jobs that have one
+	Lock string `bson:"lock,omitempty" json:"-"`
+}
+
+type JobStatus string
+
+const (
+	JobQueued    JobStatus = "queued"
+	JobRunning   JobStatus = "running"
+	JobSucceeded JobStatus = "succeeded"
+	JobFailed    JobStatus = "failed"
+)
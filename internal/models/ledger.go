@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LedgerEntry is one event of the append-only, integer-cents ledger that is replacing the float
+// balances. A user's balance in a group and currency is the sum of their entries.
+type LedgerEntry struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	EntryID     string             `bson:"entry_id" json:"entry_id"`
+	UserID      string             `bson:"user_id" json:"user_id"`
+	GroupID     string             `bson:"group_id" json:"group_id"`
+	Currency    string             `bson:"currency" json:"currency"`
+	AmountCents int64              `bson:"amount_cents" json:"amount_cents"`
+	Type        LedgerEntryType    `bson:"type" json:"type"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+}
+
+type LedgerEntryType string
+
+const (
+	// LedgerEntryOpening carries a balance over from the old balances when a group starts shadowing
+	LedgerEntryOpening LedgerEntryType = "opening"
+	// LedgerEntryChange mirrors one balance update
+	LedgerEntryChange LedgerEntryType = "change"
+)
+
+// LedgerDiscrepancy is a balance on which the old balances and the ledger disagree
+type LedgerDiscrepancy struct {
+	GroupID      string `bson:"group_id" json:"group_id"`
+	UserID       string `bson:"user_id" json:"user_id"`
+	Currency     string `bson:"currency" json:"currency"`
+	BalanceCents int64  `bson:"balance_cents" json:"balance_cents"` // the old balance, rounded to cents
+	LedgerCents  int64  `bson:"ledger_cents" json:"ledger_cents"`
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Netting cancels out what two users owe each other in different groups. Money moves in
+// neither direction: each adjustment records one user "paying" the other inside a group,
+// and the adjustments in each direction add up to the same Amount.
+type Netting struct {
+	ID             primitive.ObjectID  `bson:"_id,omitempty" json:"-"`
+	NettingID      string              `bson:"netting_id" json:"netting_id,omitempty"` // empty in a preview
+	InitiatorID    string              `bson:"initiator_id" json:"initiator_id"`
+	CounterpartyID string              `bson:"counterparty_id" json:"counterparty_id"`
+	Currency       string              `bson:"currency" json:"currency"`
+	Amount         float64             `bson:"amount" json:"amount"`
+	Adjustments    []NettingAdjustment `bson:"adjustments" json:"adjustments"`
+	CreatedAt      time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// NettingAdjustment moves Amount of FromUserID's debt to ToUserID within one group, as a
+// settlement between them would
+type NettingAdjustment struct {
+	GroupID    string  `bson:"group_id" json:"group_id"`
+	GroupName  string  `bson:"group_name" json:"group_name"`
+	FromUserID string  `bson:"from_user_id" json:"from_user_id"`
+	ToUserID   string  `bson:"to_user_id" json:"to_user_id"`
+	Amount     float64 `bson:"amount" json:"amount"`
+}
+
+type NettingRequest struct {
+	CounterpartyID string `json:"counterparty_id" binding:"required"`
+	Currency       string `json:"currency" binding:"required"`
+}
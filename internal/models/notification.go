@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type NotificationType string
+
+const (
+	NotificationExpenseAdded            NotificationType = "expense.added"
+	NotificationGroupInvited            NotificationType = "group.invited"
+	NotificationGroupMemberRemoved      NotificationType = "group.member_removed"
+	NotificationGroupMemberLeft         NotificationType = "group.member_left"
+	NotificationSettlementRequested     NotificationType = "settlement.requested"
+	NotificationSettlementMarkedPaid    NotificationType = "settlement.marked_paid"
+	NotificationSettlementConfirmed     NotificationType = "settlement.confirmed"
+	NotificationSettlementRejected      NotificationType = "settlement.rejected"
+	NotificationSettlementAutoConfirmed NotificationType = "settlement.auto_confirmed"
+	NotificationSettlementVoided        NotificationType = "settlement.voided"
+	NotificationSettlementCancelled     NotificationType = "settlement.cancelled"
+	NotificationSettlementPaid          NotificationType = "settlement.paid"
+	NotificationSettlementFailed        NotificationType = "settlement.failed"
+	NotificationNettingApplied          NotificationType = "netting.applied"
+	NotificationBudgetThreshold         NotificationType = "budget.threshold"
+	NotificationExpenseNeedsApproval    NotificationType = "expense.needs_approval"
+	NotificationExpenseApproved         NotificationType = "expense.approved"
+	NotificationExpenseRejected         NotificationType = "expense.rejected"
+	NotificationFriendRequested         NotificationType = "friend.requested"
+	NotificationFriendAccepted          NotificationType = "friend.accepted"
+)
+
+// Notification is an in-app notification shown in the recipient's inbox
+type Notification struct {
+	ID             primitive.ObjectID     `bson:"_id,omitempty" json:"-"`
+	NotificationID string                 `bson:"notification_id" json:"notification_id"`
+	UserID         string                 `bson:"user_id" json:"user_id"`
+	Type           NotificationType       `bson:"type" json:"type"`
+	Title          string                 `bson:"title" json:"title"`
+	Body           string                 `bson:"body" json:"body"`
+	Data           map[string]interface{} `bson:"data,omitempty" json:"data,omitempty"`
+	ReadAt         *time.Time             `bson:"read_at,omitempty" json:"read_at,omitempty"`
+	CreatedAt      time.Time              `bson:"created_at" json:"created_at"`
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	// OutboxFailed messages ran out of delivery attempts
+	OutboxFailed OutboxStatus = "failed"
+)
+
+// OutboxMessage is an event or a notification, written in the same transaction as the change
+// that caused it and delivered by the outbox relay once that transaction commits. Exactly one of
+// Event and Notification is set.
+type OutboxMessage struct {
+	ID           primitive.ObjectID  `bson:"_id,omitempty" json:"-"`
+	MessageID    string              `bson:"message_id" json:"message_id"`
+	Event        *OutboxEvent        `bson:"event,omitempty" json:"event,omitempty"`
+	Notification *OutboxNotification `bson:"notification,omitempty" json:"notification,omitempty"`
+	Status       OutboxStatus        `bson:"status" json:"status"`
+	Attempts     int                 `bson:"attempts" json:"attempts"`
+	AvailableAt  time.Time           `bson:"available_at" json:"available_at"` // not claimed again before then
+	LastError    string              `bson:"last_error,omitempty" json:"last_error,omitempty"`
+	CreatedAt    time.Time           `bson:"created_at" json:"created_at"`
+}
+
+// OutboxEvent is a real-time event with its recipients already resolved. Its data is an
+// expense, a settlement or a plain map, kept typed so subscribers get what was published.
+type OutboxEvent struct {
+	Type       string                 `bson:"type" json:"type"`
+	GroupID    *string                `bson:"group_id,omitempty" json:"group_id,omitempty"`
+	Recipients []string               `bson:"recipients" json:"recipients"`
+	Expense    *Expense               `bson:"expense,omitempty" json:"expense,omitempty"`
+	Settlement *Settlement            `bson:"settlement,omitempty" json:"settlement,omitempty"`
+	Data       map[string]interface{} `bson:"data,omitempty" json:"data,omitempty"`
+	OccurredAt time.Time              `bson:"occurred_at" json:"occurred_at"`
+}
+
+type OutboxNotification struct {
+	UserID string                 `bson:"user_id" json:"user_id"`
+	Type   NotificationType       `bson:"type" json:"type"`
+	Title  string                 `bson:"title" json:"title"`
+	Body   string                 `bson:"body" json:"body"`
+	Data   map[string]interface{} `bson:"data,omitempty" json:"data,omitempty"`
+
+	TitleArgs []interface{} `bson:"title_args,omitempty" json:"title_args,omitempty"`
+	BodyArgs  []interface{} `bson:"body_args,omitempty" json:"body_args,omitempty"`
+}
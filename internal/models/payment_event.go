@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PaymentEvent records a payment provider webhook event once it has been handled, so the
+// provider resending it changes nothing
+type PaymentEvent struct {
+	ID           primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	Provider     string             `bson:"provider" json:"provider"`
+	EventID      string             `bson:"event_id" json:"event_id"`
+	Type         string             `bson:"type" json:"type"`
+	SettlementID string             `bson:"settlement_id,omitempty" json:"settlement_id,omitempty"`
+	Result       PaymentEventResult `bson:"result" json:"result"`
+	// Note says why an event was ignored
+	Note       string    `bson:"note,omitempty" json:"note,omitempty"`
+	ReceivedAt time.Time `bson:"received_at" json:"received_at"`
+}
+
+type PaymentEventResult string
+
+const (
+	// PaymentEventApplied moved the settlement to completed or failed
+	PaymentEventApplied PaymentEventResult = "applied"
+	// PaymentEventIgnored changed nothing: the event wasn't about a settlement's payment, or
+	// didn't match the settlement's state or amount
+	PaymentEventIgnored PaymentEventResult = "ignored"
+)
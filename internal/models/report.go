@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// MonthlyReport is what a user's shares of expenses came to in a month, in total and per category,
+// next to the month before. Amounts are per currency and never converted.
+type MonthlyReport struct {
+	UserID        string       `json:"user_id"`
+	Month         string       `json:"month"`          // YYYY-MM
+	PreviousMonth string       `json:"previous_month"` // YYYY-MM
+	Totals        []ReportLine `json:"totals"`
+	Categories    []ReportLine `json:"categories"`
+	GeneratedAt   time.Time    `json:"generated_at"`
+}
+
+// ReportLine compares one currency's spending, overall or in one category, with the previous
+// month. Totals and uncategorised spending have no Category. ChangePercent is nil
+// when nothing was spent the previous month.
+type ReportLine struct {
+	Category       string   `json:"category,omitempty"`
+	Currency       string   `json:"currency"`
+	Amount         float64  `json:"amount"`
+	PreviousAmount float64  `json:"previous_amount"`
+	Change         float64  `json:"change"`
+	ChangePercent  *float64 `json:"change_percent,omitempty"`
+}
@@ -20,20 +20,63 @@ type Settlement struct {
 	TransactionID *string            `bson:"transaction_id,omitempty" json:"transaction_id,omitempty"`
 	CreatedAt     time.Time          `bson:"created_at" json:"created_at"`
 	UpdatedAt     time.Time          `bson:"updated_at" json:"updated_at"`
+	MarkedPaidAt  *time.Time         `bson:"marked_paid_at,omitempty" json:"marked_paid_at,omitempty"`
+	AutoConfirmAt *time.Time         `bson:"auto_confirm_at,omitempty" json:"auto_confirm_at,omitempty"`
 	CompletedAt   *time.Time         `bson:"completed_at,omitempty" json:"completed_at,omitempty"`
+	AutoCompleted bool               `bson:"auto_completed,omitempty" json:"auto_completed,omitempty"`
+	RejectReason  *string            `bson:"reject_reason,omitempty" json:"reject_reason,omitempty"`
 	FailedAt      *time.Time         `bson:"failed_at,omitempty" json:"failed_at,omitempty"`
 	FailureReason *string            `bson:"failure_reason,omitempty" json:"failure_reason,omitempty"`
+	VoidedAt      *time.Time         `bson:"voided_at,omitempty" json:"voided_at,omitempty"`
+	VoidedBy      *string            `bson:"voided_by,omitempty" json:"voided_by,omitempty"`
+	VoidReason    *string            `bson:"void_reason,omitempty" json:"void_reason,omitempty"`
+	// Set when an admin force-cancelled the settlement from the back office
+	CancelledAt  *time.Time `bson:"cancelled_at,omitempty" json:"cancelled_at,omitempty"`
+	CancelledBy  *string    `bson:"cancelled_by,omitempty" json:"cancelled_by,omitempty"`
+	CancelReason *string    `bson:"cancel_reason,omitempty" json:"cancel_reason,omitempty"`
 }
 
 type SettlementStatus string
 
+// Settlement lifecycle:
+//
+//	pending --payer marks paid--> awaiting_confirmation --payee confirms--> completed
+//	   ^                                  |
+//	   +---------- payee rejects ---------+
+//
+// If the payee has pre-authorized the payer for the settlement's method, or the
+// group doesn't require confirmation, marking it paid goes straight to completed.
+// A settlement left awaiting confirmation past its auto_confirm_at is completed
+// by the settlement worker.
+//
+// A settlement recorded by mistake can be voided from pending or awaiting_confirmation,
+// before it moved any balance. Voided settlements keep who voided them and why, are left
+// out of settlement lists unless asked for, and are purged after the retention period.
+//
+// A settlement paid through a payment provider is completed, or marked failed, when the
+// provider's webhook reports the payment's result. A failed payment the provider later retries
+// successfully completes the settlement.
+//
+// An admin can force-cancel a settlement that hasn't completed, whatever its other status,
+// from the back office.
 const (
-	SettlementPending   SettlementStatus = "pending"
-	SettlementCompleted SettlementStatus = "completed"
-	SettlementFailed    SettlementStatus = "failed"
-	SettlementCancelled SettlementStatus = "cancelled"
+	SettlementPending              SettlementStatus = "pending"
+	SettlementAwaitingConfirmation SettlementStatus = "awaiting_confirmation"
+	SettlementCompleted            SettlementStatus = "completed"
+	SettlementFailed               SettlementStatus = "failed"
+	SettlementCancelled            SettlementStatus = "cancelled"
+	SettlementVoided               SettlementStatus = "voided"
 )
 
+func (s SettlementStatus) IsValid() bool {
+	switch s {
+	case SettlementPending, SettlementAwaitingConfirmation, SettlementCompleted, SettlementFailed, SettlementCancelled,
+		SettlementVoided:
+		return true
+	}
+	return false
+}
+
 type SettlementMethod string
 
 const (
@@ -45,6 +88,36 @@ const (
 	SettlementMethodOther  SettlementMethod = "other"
 )
 
+func (m SettlementMethod) IsValid() bool {
+	switch m {
+	case SettlementMethodCash, SettlementMethodBank, SettlementMethodUPI,
+		SettlementMethodPayPal, SettlementMethodVenmo, SettlementMethodOther:
+		return true
+	}
+	return false
+}
+
+// SettlementAuthorization lets a payee skip confirming payments from a trusted payer
+// made with one of the listed methods.
+type SettlementAuthorization struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	PayeeID   string             `bson:"payee_id" json:"payee_id"`
+	PayerID   string             `bson:"payer_id" json:"payer_id"`
+	Methods   []SettlementMethod `bson:"methods" json:"methods"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
+}
+
+// SettlementReceipt is the proof of payment for a completed settlement, with the parties' and
+// group's names resolved for printing
+type SettlementReceipt struct {
+	Settlement  *Settlement `json:"settlement"`
+	PayerName   string      `json:"payer_name"`
+	PayeeName   string      `json:"payee_name"`
+	GroupName   string      `json:"group_name,omitempty"`
+	GeneratedAt time.Time   `json:"generated_at"`
+}
+
 type SettlementRequest struct {
 	FromUserID  string           `json:"from_user_id" binding:"required"`
 	ToUserID    string           `json:"to_user_id" binding:"required"`
@@ -55,6 +128,23 @@ type SettlementRequest struct {
 	Description string           `json:"description,omitempty"`
 }
 
+// SettleAllRequest settles up a group in one go. Every settlement in the batch uses Method and
+// Description.
+type SettleAllRequest struct {
+	Method      SettlementMethod `json:"method" binding:"required"`
+	Description string           `json:"description,omitempty"`
+}
+
+// SettleUpRequest pays a counterparty what the caller owes them, in the group when GroupID is
+// set and outside groups otherwise. Currency can be left out when the debt is in one currency.
+type SettleUpRequest struct {
+	CounterpartyID string           `json:"counterparty_id" binding:"required"`
+	GroupID        *string          `json:"group_id,omitempty"`
+	Currency       string           `json:"currency,omitempty"`
+	Method         SettlementMethod `json:"method" binding:"required"`
+	Description    string           `json:"description,omitempty"`
+}
+
 type SettlementResponse struct {
 	SettlementID string           `json:"settlement_id"`
 	Status       SettlementStatus `json:"status"`
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GroupShareLink lets anyone holding its token read the group's public summary until it expires
+// or is revoked. Only a hash of the token is stored; the token itself is shown once, on creation.
+type GroupShareLink struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	LinkID    string             `bson:"link_id" json:"link_id"`
+	GroupID   string             `bson:"group_id" json:"group_id"`
+	TokenHash string             `bson:"token_hash" json:"-"`
+	CreatedBy string             `bson:"created_by" json:"created_by"`
+	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
+	ExpiresAt time.Time          `bson:"expires_at" json:"expires_at"`
+
+	Token string `bson:"-" json:"token,omitempty"`
+}
+
+// PublicGroupSummary is what a share link shows: no user IDs or emails, only names
+type PublicGroupSummary struct {
+	GroupName    string                `json:"group_name"`
+	Currency     string                `json:"currency"`
+	MemberCount  int                   `json:"member_count"`
+	ExpenseCount int64                 `json:"expense_count"`
+	Totals       []PublicCurrencyTotal `json:"totals"`
+	Balances     []PublicMemberBalance `json:"balances"`
+	Transfers    []PublicTransfer      `json:"transfers"`
+	GeneratedAt  time.Time             `json:"generated_at"`
+	ExpiresAt    time.Time             `json:"expires_at"`
+	Stale        bool                  `json:"stale,omitempty"` // a stored summary served because a fresh one took too long
+}
+
+// PublicCurrencyTotal is what the group spent in one currency over its lifetime
+type PublicCurrencyTotal struct {
+	Currency string  `json:"currency"`
+	Spent    float64 `json:"spent"`
+}
+
+// PublicMemberBalance is positive when the member is owed money
+type PublicMemberBalance struct {
+	Name     string  `json:"name"`
+	Balance  float64 `json:"balance"`
+	Currency string  `json:"currency"`
+}
+
+// PublicTransfer is one payment that would settle the group: From pays To
+type PublicTransfer struct {
+	From     string  `json:"from"`
+	To       string  `json:"to"`
+	Amount   float64 `json:"amount"`
+	Currency string  `json:"currency"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// Statement is a monthly account of a group's or a user's expenses, settlements and balances
+type Statement struct {
+	Title       string    `json:"title"`
+	Month       string    `json:"month"` // YYYY-MM
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"` // exclusive
+	GeneratedAt time.Time `json:"generated_at"`
+
+	// ShareUserID is set on user statements: expense rows then show this user's share
+	ShareUserID string `json:"share_user_id,omitempty"`
+
+	Balances    []StatementBalance `json:"balances"`
+	Expenses    []*Expense         `json:"expenses"`
+	Settlements []*Settlement      `json:"settlements"`
+	UserNames   map[string]string  `json:"user_names"`
+}
+
+// StatementBalance is one user's balance in one currency at the start and end of the period
+type StatementBalance struct {
+	UserID   string  `json:"user_id"`
+	Currency string  `json:"currency"`
+	Opening  float64 `json:"opening"`
+	Closing  float64 `json:"closing"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// AdminStats is the data behind the operational dashboard, covering the last Days days
+type AdminStats struct {
+	Days             int             `json:"days"`
+	Since            time.Time       `json:"since"`
+	GeneratedAt      time.Time       `json:"generated_at"`
+	TotalUsers       int64           `json:"total_users"`
+	ActiveGroups     int64           `json:"active_groups"`
+	AverageGroupSize float64         `json:"average_group_size"`
+	NewUsersPerDay   []DailyCount    `json:"new_users_per_day"`
+	ExpensesPerDay   []DailyCount    `json:"expenses_per_day"`
+	Settlements      SettlementStats `json:"settlements"`
+	FailedJobs       int64           `json:"failed_jobs"`
+}
+
+// DailyCount is the number of things created on a UTC day (YYYY-MM-DD)
+type DailyCount struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+// SettlementStats covers the settlements created in the stats window. CompletionRate is the
+// share of them that have completed.
+type SettlementStats struct {
+	Created        int64                      `json:"created"`
+	Completed      int64                      `json:"completed"`
+	CompletionRate float64                    `json:"completion_rate"`
+	ByStatus       map[SettlementStatus]int64 `json:"by_status"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// GroupSuggestion proposes that a user creates a group with the people they keep splitting
+// expenses with outside of groups. Suggestions are recomputed periodically.
+type GroupSuggestion struct {
+	ID            primitive.ObjectID `bson:"_id,omitempty" json:"-"`
+	SuggestionID  string             `bson:"suggestion_id" json:"suggestion_id"`
+	UserID        string             `bson:"user_id" json:"user_id"`
+	MemberIDs     []string           `bson:"member_ids" json:"member_ids"` // everyone but the user
+	ExpenseCount  int                `bson:"expense_count" json:"expense_count"`
+	LastExpenseAt time.Time          `bson:"last_expense_at" json:"last_expense_at"`
+	ComputedAt    time.Time          `bson:"computed_at" json:"computed_at"`
+
+	Members []SuggestedMember `bson:"-" json:"members"`
+	Message string            `bson:"-" json:"message"`
+}
+
+type SuggestedMember struct {
+	UserID    string `json:"user_id"`
+	Name      string `json:"name"`
+	AvatarURL string `json:"avatar_url,omitempty"`
+}
@@ -14,16 +14,43 @@ const (
 )
 
 type User struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID    string             `bson:"user_id" json:"user_id"`
-	Name      string             `bson:"name" json:"name"`
-	Email     string             `bson:"email" json:"email"`
-	Phone     string             `bson:"phone,omitempty" json:"phone,omitempty"`
-	CreatedAt time.Time          `bson:"created_at" json:"created_at"`
-	UpdatedAt time.Time          `bson:"updated_at" json:"updated_at"`
-	Password  string             `bson:"password,omitempty" json:"-"`
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      string             `bson:"user_id" json:"user_id"`
+	Name        string             `bson:"name" json:"name"`
+	Email       string             `bson:"email" json:"email"`
+	Phone       string             `bson:"phone,omitempty" json:"phone,omitempty"`
+	Preferences UserPreferences    `bson:"preferences,omitempty" json:"preferences"`
+	AvatarURL   string             `bson:"avatar_url,omitempty" json:"avatar_url,omitempty"`
+	Avatar      *AvatarImage       `bson:"avatar,omitempty" json:"-"`
+	CreatedAt   time.Time          `bson:"created_at" json:"created_at"`
+	UpdatedAt   time.Time          `bson:"updated_at" json:"updated_at"`
+	Password    string             `bson:"password,omitempty" json:"-"`
+	Placeholder bool               `bson:"placeholder,omitempty" json:"placeholder,omitempty"` // Imported member without an account; can't log in
+	ClaimEmail  string             `bson:"claim_email,omitempty" json:"-"`                     // Real email of a placeholder; whoever signs up with it is emailed a token to claim the placeholder
+	DeletedAt   *time.Time         `bson:"deleted_at,omitempty" json:"deleted_at,omitempty"`   // Set once the account is deleted and its personal data removed
+	// Role is RoleAdmin for back-office operators; everyone else has none
+	Role           UserRole   `bson:"role,omitempty" json:"role,omitempty"`
+	DisabledAt     *time.Time `bson:"disabled_at,omitempty" json:"disabled_at,omitempty"` // Set while an admin has disabled the account; it can't log in
+	DisabledReason string     `bson:"disabled_reason,omitempty" json:"disabled_reason,omitempty"`
+	// ClaimTokenHash is the SHA-256 of the token last emailed to ClaimEmail; only placeholders have one
+	ClaimTokenHash      string     `bson:"claim_token_hash,omitempty" json:"-"`
+	ClaimTokenExpiresAt *time.Time `bson:"claim_token_expires_at,omitempty" json:"-"`
+	// SettlementVersion is bumped by transactions that create settlements the user pays outside
+	// any group, only so that they conflict with each other
+	SettlementVersion int64 `bson:"settlement_version,omitempty" json:"-"`
 }
 
 type UserPreferences struct {
 	DefaultCurrency string `bson:"default_currency,omitempty" json:"default_currency,omitempty"`
+	// LookupVisibility limits who can find the user by email or phone; empty means everyone
+	LookupVisibility LookupVisibility `bson:"lookup_visibility,omitempty" json:"lookup_visibility,omitempty"`
+	// Language is the language notifications and emails are written in; empty means English
+	Language string `bson:"language,omitempty" json:"language,omitempty"`
 }
+
+type LookupVisibility string
+
+const (
+	LookupVisibilityEveryone         LookupVisibility = "everyone"
+	LookupVisibilityFriendsOfFriends LookupVisibility = "friends_of_friends"
+)
@@ -0,0 +1,112 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrAPIKeyNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodeAPIKeyNotFound, "API key not found")
+
+type APIKeyRepository interface {
+	Create(ctx context.Context, key *models.APIKey) error
+	// GetBySecretHash returns the key whether or not it has expired; MongoDB only removes expired
+	// keys periodically, so callers must check ExpiresAt
+	GetBySecretHash(ctx context.Context, secretHash string) (*models.APIKey, error)
+	ListByUserID(ctx context.Context, userID string) ([]*models.APIKey, error)
+	// TouchLastUsed records that the key was used at, unless that was already recorded less
+	// than interval before
+	TouchLastUsed(ctx context.Context, keyID string, at time.Time, interval time.Duration) error
+	Delete(ctx context.Context, userID string, keyID string) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type apiKeyRepository struct {
+	collection *mongo.Collection
+}
+
+func NewAPIKeyRepository(db *mongo.Database) APIKeyRepository {
+	return &apiKeyRepository{
+		collection: db.Collection("api_keys"),
+	}
+}
+
+func (r *apiKeyRepository) Create(ctx context.Context, key *models.APIKey) error {
+	_, err := r.collection.InsertOne(ctx, key)
+	return err
+}
+
+func (r *apiKeyRepository) GetBySecretHash(ctx context.Context, secretHash string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.collection.FindOne(ctx, bson.M{"secret_hash": secretHash}).Decode(&key)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrAPIKeyNotFound
+		}
+		return nil, err
+	}
+	return &key, nil
+}
+
+// ListByUserID returns the user's keys, newest first
+func (r *apiKeyRepository) ListByUserID(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	keys := []*models.APIKey{}
+	if err := cursor.All(ctx, &keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+func (r *apiKeyRepository) TouchLastUsed(ctx context.Context, keyID string, at time.Time, interval time.Duration) error {
+	filter := bson.M{
+		"key_id": keyID,
+		"$or": []bson.M{
+			{"last_used_at": bson.M{"$exists": false}},
+			{"last_used_at": bson.M{"$lt": at.Add(-interval)}},
+		},
+	}
+	_, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": bson.M{"last_used_at": at}})
+	return err
+}
+
+// Delete revokes a key; deleted keys stop working immediately
+func (r *apiKeyRepository) Delete(ctx context.Context, userID string, keyID string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID, "key_id": keyID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// EnsureIndexes creates the indexes behind key lookups, listing a user's keys and removing
+// expired ones
+func (r *apiKeyRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, apiKeyIndexes())
+	return err
+}
+
+func apiKeyIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "secret_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "key_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+}
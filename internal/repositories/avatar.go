@@ -0,0 +1,36 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"divvydoo/backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// setAvatar sets or, for a nil avatar, unsets the avatar fields of the document matching filter
+// and returns the updated document
+func setAvatar[T any](ctx context.Context, collection *mongo.Collection, filter bson.M, url string, avatar *models.AvatarImage, notFound error) (*T, error) {
+	now := time.Now()
+	update := bson.M{"$set": bson.M{"avatar_url": url, "avatar": avatar, "updated_at": now}}
+	if avatar == nil {
+		update = bson.M{
+			"$set":   bson.M{"updated_at": now},
+			"$unset": bson.M{"avatar_url": "", "avatar": ""},
+		}
+	}
+
+	var updated T
+	err := collection.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&updated)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, notFound
+		}
+		return nil, err
+	}
+	return &updated, nil
+}
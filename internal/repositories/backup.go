@@ -0,0 +1,118 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrBackupNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodeBackupNotFound, "backup not found")
+)
+
+type BackupRepository interface {
+	Create(ctx context.Context, backup *models.Backup) error
+	GetByID(ctx context.Context, backupID string) (*models.Backup, error)
+	List(ctx context.Context, limit int64) ([]*models.Backup, error)
+	ListCompleted(ctx context.Context) ([]*models.Backup, error)
+	MarkCompleted(ctx context.Context, backupID string, sizeBytes int64) error
+	MarkFailed(ctx context.Context, backupID string, reason string) error
+	MarkExpired(ctx context.Context, backupID string) error
+	RecordVerification(ctx context.Context, backupID string, verification models.BackupVerification) error
+}
+
+type backupRepository struct {
+	collection *mongo.Collection
+}
+
+func NewBackupRepository(db *mongo.Database) BackupRepository {
+	return &backupRepository{
+		collection: db.Collection("backups"),
+	}
+}
+
+func (r *backupRepository) Create(ctx context.Context, backup *models.Backup) error {
+	_, err := r.collection.InsertOne(ctx, backup)
+	return err
+}
+
+func (r *backupRepository) GetByID(ctx context.Context, backupID string) (*models.Backup, error) {
+	var backup models.Backup
+	err := r.collection.FindOne(ctx, bson.M{"backup_id": backupID}).Decode(&backup)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrBackupNotFound
+		}
+		return nil, err
+	}
+	return &backup, nil
+}
+
+// List returns the most recent backups first, whatever their status
+func (r *backupRepository) List(ctx context.Context, limit int64) ([]*models.Backup, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}}).SetLimit(limit)
+	return r.find(ctx, bson.M{}, opts)
+}
+
+// ListCompleted returns the backups still on disk, newest first
+func (r *backupRepository) ListCompleted(ctx context.Context) ([]*models.Backup, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}})
+	return r.find(ctx, bson.M{"status": models.BackupCompleted}, opts)
+}
+
+func (r *backupRepository) find(ctx context.Context, filter bson.M, opts *options.FindOptions) ([]*models.Backup, error) {
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	backups := []*models.Backup{}
+	if err := cursor.All(ctx, &backups); err != nil {
+		return nil, err
+	}
+	return backups, nil
+}
+
+func (r *backupRepository) MarkCompleted(ctx context.Context, backupID string, sizeBytes int64) error {
+	return r.update(ctx, backupID, bson.M{
+		"status":       models.BackupCompleted,
+		"size_bytes":   sizeBytes,
+		"completed_at": time.Now(),
+	})
+}
+
+func (r *backupRepository) MarkFailed(ctx context.Context, backupID string, reason string) error {
+	return r.update(ctx, backupID, bson.M{
+		"status":       models.BackupFailed,
+		"error":        reason,
+		"completed_at": time.Now(),
+	})
+}
+
+func (r *backupRepository) MarkExpired(ctx context.Context, backupID string) error {
+	return r.update(ctx, backupID, bson.M{"status": models.BackupExpired})
+}
+
+func (r *backupRepository) RecordVerification(ctx context.Context, backupID string, verification models.BackupVerification) error {
+	return r.update(ctx, backupID, bson.M{"verification": verification})
+}
+
+func (r *backupRepository) update(ctx context.Context, backupID string, fields bson.M) error {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"backup_id": backupID}, bson.M{"$set": fields})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrBackupNotFound
+	}
+	return nil
+}
@@ -3,9 +3,12 @@ package repositories
 import (
 	"context"
 	"errors"
+	"math"
+	"net/http"
 	"time"
 
 	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,34 +17,68 @@ import (
 )
 
 var (
-	ErrBalanceNotFound       = errors.New("balance not found")
-	ErrOptimisticLockFailure = errors.New("optimistic lock failure: balance was modified")
+	ErrBalanceNotFound       = utils.NewCustomError(http.StatusNotFound, utils.CodeBalanceNotFound, "balance not found")
+	ErrOptimisticLockFailure = utils.NewCustomError(http.StatusConflict, utils.CodeBalanceConflict, "optimistic lock failure: balance was modified")
 )
 
 type BalanceRepository interface {
 	Create(ctx context.Context, balance *models.Balance) (*models.Balance, error)
 	GetByUserID(ctx context.Context, userID string) ([]*models.Balance, error)
 	GetByGroupID(ctx context.Context, groupID string) ([]*models.Balance, error)
+	// ForEach calls fn for every balance, in no particular order
+	ForEach(ctx context.Context, fn func(*models.Balance) error) error
 	GetByUserAndGroup(ctx context.Context, userID string, groupID *string) (*models.Balance, error)
-	UpdateBalance(ctx context.Context, userID string, groupID *string, amount float64) error
+	UpdateBalance(ctx context.Context, userID string, groupID *string, currency string, amount float64) error
 	UpdateBalanceWithVersion(ctx context.Context, balance *models.Balance) error
 	GetUserBalanceSummary(ctx context.Context, userID string) (*models.UserBalanceSummary, error)
 	CreateBalanceHistory(ctx context.Context, history *models.BalanceHistory) error
+	InsertBalanceHistory(ctx context.Context, entries []*models.BalanceHistory) error
 	GetBalanceHistory(ctx context.Context, userID string, groupID *string, limit, offset int64) ([]*models.BalanceHistory, error)
+	ListBalanceHistory(ctx context.Context, userID string, groupID *string, types []models.BalanceChangeType, cursor *Cursor, limit, offset int64) ([]*models.BalanceHistory, string, error)
+	GetLastActivityByGroupIDs(ctx context.Context, groupIDs []string) (map[string]time.Time, error)
+	SumHistoryBefore(ctx context.Context, userID *string, groupID *string, before time.Time) ([]HistoryTotal, error)
+	// SumGroupHistory adds up the group's balance changes of the given types per user and currency
+	SumGroupHistory(ctx context.Context, groupID string, types []models.BalanceChangeType) ([]HistoryTotal, error)
+	// GetGroupIDs returns the groups that have balances
+	GetGroupIDs(ctx context.Context) ([]string, error)
+	// UpdateDirectBalance records that debtorID owes creditorID amount more outside any group
+	UpdateDirectBalance(ctx context.Context, debtorID string, creditorID string, currency string, amount float64) error
+	// GetDirectBalances returns userID's direct balances, with otherUserID only when it is set
+	GetDirectBalances(ctx context.Context, userID string, otherUserID *string) ([]*models.DirectBalance, error)
+	// ReassignUser moves fromUserID's balances and balance history over to toUserID. A balance
+	// in a group and currency toUserID already has one in is added onto theirs.
+	ReassignUser(ctx context.Context, fromUserID string, toUserID string) error
+	EnsureIndexes(ctx context.Context) error
+	StartSession() (mongo.Session, error)
+}
+
+// HistoryTotal is the sum of a user's balance changes in one currency
+type HistoryTotal struct {
+	UserID   string  `bson:"user_id"`
+	Currency string  `bson:"currency"`
+	Total    float64 `bson:"total"`
 }
 
 type balanceRepository struct {
 	balanceCollection *mongo.Collection
 	historyCollection *mongo.Collection
+	directCollection  *mongo.Collection
+	client            *mongo.Client
 }
 
 func NewBalanceRepository(db *mongo.Database) BalanceRepository {
 	return &balanceRepository{
 		balanceCollection: db.Collection("balances"),
 		historyCollection: db.Collection("balance_history"),
+		directCollection:  db.Collection("direct_balances"),
+		client:            db.Client(),
 	}
 }
 
+func (r *balanceRepository) StartSession() (mongo.Session, error) {
+	return r.client.StartSession()
+}
+
 func (r *balanceRepository) Create(ctx context.Context, balance *models.Balance) (*models.Balance, error) {
 	balance.UpdatedAt = time.Now()
 	balance.Version = 1
@@ -93,6 +130,26 @@ func (r *balanceRepository) GetByGroupID(ctx context.Context, groupID string) ([
 	return balances, nil
 }
 
+func (r *balanceRepository) ForEach(ctx context.Context, fn func(*models.Balance) error) error {
+	cursor, err := r.balanceCollection.Find(ctx, bson.M{}, options.Find().SetBatchSize(500))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var balance models.Balance
+		if err := cursor.Decode(&balance); err != nil {
+			return err
+		}
+		if err := fn(&balance); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
 func (r *balanceRepository) GetByUserAndGroup(ctx context.Context, userID string, groupID *string) (*models.Balance, error) {
 	filter := bson.M{"user_id": userID}
 	if groupID != nil {
@@ -113,8 +170,13 @@ func (r *balanceRepository) GetByUserAndGroup(ctx context.Context, userID string
 	return &balance, nil
 }
 
-func (r *balanceRepository) UpdateBalance(ctx context.Context, userID string, groupID *string, amount float64) error {
-	filter := bson.M{"user_id": userID}
+// UpdateBalance adjusts a user's balance in the given currency. Balances are kept
+// per currency so amounts in different currencies are never summed together.
+func (r *balanceRepository) UpdateBalance(ctx context.Context, userID string, groupID *string, currency string, amount float64) error {
+	filter := bson.M{
+		"user_id":  userID,
+		"currency": currency,
+	}
 	if groupID != nil {
 		filter["group_id"] = *groupID
 	} else {
@@ -132,7 +194,7 @@ func (r *balanceRepository) UpdateBalance(ctx context.Context, userID string, gr
 		"$setOnInsert": bson.M{
 			"user_id":  userID,
 			"group_id": groupID,
-			"currency": "USD", // Default currency
+			"currency": currency,
 		},
 	}
 
@@ -147,8 +209,9 @@ func (r *balanceRepository) UpdateBalanceWithVersion(ctx context.Context, balanc
 	balance.UpdatedAt = time.Now()
 
 	filter := bson.M{
-		"user_id": balance.UserID,
-		"version": currentVersion,
+		"user_id":  balance.UserID,
+		"currency": balance.Currency,
+		"version":  currentVersion,
 	}
 	if balance.GroupID != nil {
 		filter["group_id"] = *balance.GroupID
@@ -176,6 +239,9 @@ func (r *balanceRepository) UpdateBalanceWithVersion(ctx context.Context, balanc
 	return nil
 }
 
+// GetUserBalanceSummary returns the user's balances broken down by currency and group.
+// TotalBalance and Currency are left for the caller to fill in, since producing a single
+// total requires choosing a display currency and converting into it.
 func (r *balanceRepository) GetUserBalanceSummary(ctx context.Context, userID string) (*models.UserBalanceSummary, error) {
 	balances, err := r.GetByUserID(ctx, userID)
 	if err != nil {
@@ -183,30 +249,58 @@ func (r *balanceRepository) GetUserBalanceSummary(ctx context.Context, userID st
 	}
 
 	summary := &models.UserBalanceSummary{
-		UserID:        userID,
-		TotalBalance:  0,
-		GroupBalances: []models.GroupBalance{},
-		PeerBalances:  []models.PeerBalance{},
-		Currency:      "USD",
-		LastUpdated:   time.Now(),
+		UserID:         userID,
+		CurrencyTotals: []models.CurrencyTotal{},
+		GroupBalances:  []models.GroupBalance{},
+		PeerBalances:   []models.PeerBalance{},
 	}
 
+	direct, err := r.GetDirectBalances(ctx, userID, nil)
+	if err != nil {
+		return nil, err
+	}
+	for _, balance := range direct {
+		if balance.UpdatedAt.After(summary.LastUpdated) {
+			summary.LastUpdated = balance.UpdatedAt
+		}
+		amount := balance.For(userID)
+		if math.Round(amount*100) == 0 {
+			continue
+		}
+		summary.PeerBalances = append(summary.PeerBalances, models.PeerBalance{
+			PeerID:   balance.Counterpart(userID),
+			Balance:  amount,
+			Currency: balance.Currency,
+		})
+	}
+
+	totals := make(map[string]float64)
+	var currencies []string
 	for _, balance := range balances {
-		summary.TotalBalance += balance.Balance
+		if _, seen := totals[balance.Currency]; !seen {
+			currencies = append(currencies, balance.Currency)
+		}
+		totals[balance.Currency] += balance.Balance
+
 		if balance.GroupID != nil {
 			summary.GroupBalances = append(summary.GroupBalances, models.GroupBalance{
-				GroupID: *balance.GroupID,
-				Balance: balance.Balance,
+				GroupID:  *balance.GroupID,
+				Balance:  balance.Balance,
+				Currency: balance.Currency,
 			})
 		}
-		if summary.Currency == "" {
-			summary.Currency = balance.Currency
-		}
 		if balance.UpdatedAt.After(summary.LastUpdated) {
 			summary.LastUpdated = balance.UpdatedAt
 		}
 	}
 
+	for _, currency := range currencies {
+		summary.CurrencyTotals = append(summary.CurrencyTotals, models.CurrencyTotal{
+			Currency: currency,
+			Balance:  totals[currency],
+		})
+	}
+
 	return summary, nil
 }
 
@@ -222,6 +316,21 @@ func (r *balanceRepository) CreateBalanceHistory(ctx context.Context, history *m
 	return nil
 }
 
+// InsertBalanceHistory stores history entries as given, keeping their timestamps; used by imports
+func (r *balanceRepository) InsertBalanceHistory(ctx context.Context, entries []*models.BalanceHistory) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		docs[i] = entry
+	}
+
+	_, err := r.historyCollection.InsertMany(ctx, docs)
+	return err
+}
+
 func (r *balanceRepository) GetBalanceHistory(ctx context.Context, userID string, groupID *string, limit, offset int64) ([]*models.BalanceHistory, error) {
 	filter := bson.M{"user_id": userID}
 	if groupID != nil {
@@ -249,3 +358,282 @@ func (r *balanceRepository) GetBalanceHistory(ctx context.Context, userID string
 
 	return history, nil
 }
+
+func (r *balanceRepository) ListBalanceHistory(ctx context.Context, userID string, groupID *string, types []models.BalanceChangeType, cursor *Cursor, limit, offset int64) ([]*models.BalanceHistory, string, error) {
+	filter := bson.M{"user_id": userID}
+	if groupID != nil {
+		filter["group_id"] = *groupID
+	}
+	if len(types) > 0 {
+		filter["type"] = bson.M{"$in": types}
+	}
+	applyCursor(filter, cursor)
+
+	mongoCursor, err := r.historyCollection.Find(ctx, filter, cursorFindOptions(limit, offset))
+	if err != nil {
+		return nil, "", err
+	}
+	defer mongoCursor.Close(ctx)
+
+	var history []*models.BalanceHistory
+	if err := mongoCursor.All(ctx, &history); err != nil {
+		return nil, "", err
+	}
+
+	history, next := pageOf(history, limit, func(h *models.BalanceHistory) Cursor {
+		return Cursor{CreatedAt: h.CreatedAt, ID: h.ID}
+	})
+	return history, next, nil
+}
+
+// GetLastActivityByGroupIDs returns, per group, when a balance last changed because of an
+// expense or settlement. Groups without any balance history are absent from the map.
+func (r *balanceRepository) GetLastActivityByGroupIDs(ctx context.Context, groupIDs []string) (map[string]time.Time, error) {
+	activity := make(map[string]time.Time, len(groupIDs))
+	if len(groupIDs) == 0 {
+		return activity, nil
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"group_id": bson.M{"$in": groupIDs}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           "$group_id",
+			"last_activity": bson.M{"$max": "$created_at"},
+		}}},
+	}
+
+	cursor, err := r.historyCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var result struct {
+			GroupID      string    `bson:"_id"`
+			LastActivity time.Time `bson:"last_activity"`
+		}
+		if err := cursor.Decode(&result); err != nil {
+			return nil, err
+		}
+		activity[result.GroupID] = result.LastActivity
+	}
+
+	return activity, cursor.Err()
+}
+
+func (r *balanceRepository) ReassignUser(ctx context.Context, fromUserID string, toUserID string) error {
+	filter := bson.M{"user_id": fromUserID}
+
+	cursor, err := r.balanceCollection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	var balances []*models.Balance
+	if err := cursor.All(ctx, &balances); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, balance := range balances {
+		// The unique index allows one balance per user, group and currency, so one toUserID
+		// already has absorbs this one
+		existing := bson.M{"user_id": toUserID, "group_id": balance.GroupID, "currency": balance.Currency}
+		if balance.GroupID == nil {
+			existing["group_id"] = bson.M{"$exists": false}
+		}
+		update := bson.M{
+			"$inc": bson.M{"balance": balance.Balance, "version": 1},
+			"$set": bson.M{"updated_at": now},
+		}
+		result, err := r.balanceCollection.UpdateOne(ctx, existing, update)
+		if err != nil {
+			return err
+		}
+
+		if result.MatchedCount > 0 {
+			_, err = r.balanceCollection.DeleteOne(ctx, bson.M{"_id": balance.ID})
+		} else {
+			_, err = r.balanceCollection.UpdateOne(ctx, bson.M{"_id": balance.ID}, bson.M{"$set": bson.M{"user_id": toUserID, "updated_at": now}})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = r.historyCollection.UpdateMany(ctx, filter, bson.M{"$set": bson.M{"user_id": toUserID}})
+	return err
+}
+
+// SumHistoryBefore adds up balance changes recorded before the given time, per user and
+// currency, which reconstructs balances as they stood at that moment.
+func (r *balanceRepository) SumHistoryBefore(ctx context.Context, userID *string, groupID *string, before time.Time) ([]HistoryTotal, error) {
+	match := bson.M{"created_at": bson.M{"$lt": before}}
+	if userID != nil {
+		match["user_id"] = *userID
+	}
+	if groupID != nil {
+		match["group_id"] = *groupID
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"user_id": "$user_id", "currency": "$currency"},
+			"total": bson.M{"$sum": "$amount"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":      0,
+			"user_id":  "$_id.user_id",
+			"currency": "$_id.currency",
+			"total":    1,
+		}}},
+	}
+
+	cursor, err := r.historyCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []HistoryTotal
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+func (r *balanceRepository) SumGroupHistory(ctx context.Context, groupID string, types []models.BalanceChangeType) ([]HistoryTotal, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"group_id": groupID, "type": bson.M{"$in": types}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"user_id": "$user_id", "currency": "$currency"},
+			"total": bson.M{"$sum": "$amount"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":      0,
+			"user_id":  "$_id.user_id",
+			"currency": "$_id.currency",
+			"total":    1,
+		}}},
+	}
+
+	cursor, err := r.historyCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []HistoryTotal
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+
+	return totals, nil
+}
+
+func (r *balanceRepository) GetGroupIDs(ctx context.Context) ([]string, error) {
+	values, err := r.balanceCollection.Distinct(ctx, "group_id", bson.M{"group_id": bson.M{"$ne": nil}})
+	if err != nil {
+		return nil, err
+	}
+
+	groupIDs := make([]string, 0, len(values))
+	for _, value := range values {
+		if groupID, ok := value.(string); ok {
+			groupIDs = append(groupIDs, groupID)
+		}
+	}
+	return groupIDs, nil
+}
+
+// UpdateDirectBalance keeps one balance per pair of users and currency, stored from the side
+// of the lower user ID
+func (r *balanceRepository) UpdateDirectBalance(ctx context.Context, debtorID string, creditorID string, currency string, amount float64) error {
+	userIDs := []string{creditorID, debtorID}
+	if debtorID < creditorID {
+		userIDs = []string{debtorID, creditorID}
+		amount = -amount
+	}
+	pairKey := FriendshipPairKey(debtorID, creditorID)
+
+	filter := bson.M{
+		"pair_key": pairKey,
+		"currency": currency,
+	}
+	update := bson.M{
+		"$inc": bson.M{"balance": amount},
+		"$set": bson.M{"updated_at": time.Now()},
+		"$setOnInsert": bson.M{
+			"pair_key": pairKey,
+			"user_ids": userIDs,
+			"currency": currency,
+		},
+	}
+
+	_, err := r.directCollection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *balanceRepository) GetDirectBalances(ctx context.Context, userID string, otherUserID *string) ([]*models.DirectBalance, error) {
+	filter := bson.M{"user_ids": userID}
+	if otherUserID != nil {
+		filter = bson.M{"pair_key": FriendshipPairKey(userID, *otherUserID)}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "pair_key", Value: 1}, {Key: "currency", Value: 1}})
+	cursor, err := r.directCollection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var balances []*models.DirectBalance
+	if err := cursor.All(ctx, &balances); err != nil {
+		return nil, err
+	}
+
+	return balances, nil
+}
+
+// EnsureIndexes creates the unique per user, group and currency balance index, which keeps
+// concurrent upserts in UpdateBalance from creating a second balance document, and likewise
+// the unique per pair and currency direct balance index
+func (r *balanceRepository) EnsureIndexes(ctx context.Context) error {
+	if _, err := r.balanceCollection.Indexes().CreateMany(ctx, balanceIndexes()); err != nil {
+		return err
+	}
+	if _, err := r.directCollection.Indexes().CreateMany(ctx, directBalanceIndexes()); err != nil {
+		return err
+	}
+	_, err := r.historyCollection.Indexes().CreateMany(ctx, balanceHistoryIndexes())
+	return err
+}
+
+func balanceIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "group_id", Value: 1}, {Key: "currency", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "group_id", Value: 1}}},
+	}
+}
+
+func balanceHistoryIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "type", Value: 1}}},
+	}
+}
+
+func directBalanceIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "pair_key", Value: 1}, {Key: "currency", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{Keys: bson.D{{Key: "user_ids", Value: 1}}},
+	}
+}
@@ -0,0 +1,94 @@
+package repositories
+
+import (
+	"context"
+
+	"divvydoo/backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type BalanceSnapshotRepository interface {
+	// UpsertMany stores the snapshots, replacing those taken earlier on the same day
+	UpsertMany(ctx context.Context, snapshots []*models.BalanceSnapshot) error
+	// GetByUserID returns the user's snapshots dated from and to inclusive, oldest first,
+	// limited to one group when groupID is given
+	GetByUserID(ctx context.Context, userID string, groupID *string, from, to string) ([]*models.BalanceSnapshot, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type balanceSnapshotRepository struct {
+	collection *mongo.Collection
+}
+
+func NewBalanceSnapshotRepository(db *mongo.Database) BalanceSnapshotRepository {
+	return &balanceSnapshotRepository{
+		collection: db.Collection("balance_snapshots"),
+	}
+}
+
+func (r *balanceSnapshotRepository) UpsertMany(ctx context.Context, snapshots []*models.BalanceSnapshot) error {
+	if len(snapshots) == 0 {
+		return nil
+	}
+
+	writes := make([]mongo.WriteModel, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		// A nil group ID matches the personal balance's snapshot, stored without one
+		filter := bson.M{
+			"user_id":  snapshot.UserID,
+			"group_id": snapshot.GroupID,
+			"currency": snapshot.Currency,
+			"date":     snapshot.Date,
+		}
+		writes = append(writes, mongo.NewReplaceOneModel().SetFilter(filter).SetReplacement(snapshot).SetUpsert(true))
+	}
+
+	_, err := r.collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false))
+	return err
+}
+
+func (r *balanceSnapshotRepository) GetByUserID(ctx context.Context, userID string, groupID *string, from, to string) ([]*models.BalanceSnapshot, error) {
+	filter := bson.M{
+		"user_id": userID,
+		"date":    bson.M{"$gte": from, "$lte": to},
+	}
+	if groupID != nil {
+		filter["group_id"] = *groupID
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var snapshots []*models.BalanceSnapshot
+	if err := cursor.All(ctx, &snapshots); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// EnsureIndexes creates the indexes behind replacing a day's snapshot and charting a user's
+func (r *balanceSnapshotRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, balanceSnapshotIndexes())
+	return err
+}
+
+func balanceSnapshotIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{
+			Keys: bson.D{
+				{Key: "user_id", Value: 1},
+				{Key: "date", Value: 1},
+				{Key: "group_id", Value: 1},
+				{Key: "currency", Value: 1},
+			},
+			Options: options.Index().SetUnique(true),
+		},
+	}
+}
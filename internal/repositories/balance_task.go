@@ -0,0 +1,129 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrBalanceTaskNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodeBalanceTaskNotFound, "balance task not found")
+
+type BalanceTaskRepository interface {
+	CreateMany(ctx context.Context, tasks []*models.BalanceTask) error
+	// Claim hands out up to limit pending tasks that are due, oldest first, and hides them from
+	// other claims for the lease. A task whose worker dies is claimed again once the lease ends.
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.BalanceTask, error)
+	// Complete removes a pending task; ErrBalanceTaskNotFound means another worker completed it
+	Complete(ctx context.Context, taskID string) error
+	// Retry makes the task due again at availableAt
+	Retry(ctx context.Context, taskID string, lastError string, availableAt time.Time) error
+	MarkFailed(ctx context.Context, taskID string, lastError string) error
+	CountPendingByGroupID(ctx context.Context, groupID string) (int64, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type balanceTaskRepository struct {
+	collection *mongo.Collection
+}
+
+func NewBalanceTaskRepository(db *mongo.Database) BalanceTaskRepository {
+	return &balanceTaskRepository{
+		collection: db.Collection("balance_tasks"),
+	}
+}
+
+func (r *balanceTaskRepository) CreateMany(ctx context.Context, tasks []*models.BalanceTask) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(tasks))
+	for i, task := range tasks {
+		docs[i] = task
+	}
+	_, err := r.collection.InsertMany(ctx, docs)
+	return err
+}
+
+func (r *balanceTaskRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.BalanceTask, error) {
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "available_at", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var tasks []*models.BalanceTask
+	for len(tasks) < limit {
+		now := time.Now()
+		filter := bson.M{
+			"status":       models.BalanceTaskPending,
+			"available_at": bson.M{"$lte": now},
+		}
+		update := bson.M{
+			"$set": bson.M{"available_at": now.Add(lease)},
+			"$inc": bson.M{"attempts": 1},
+		}
+
+		var task models.BalanceTask
+		err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&task)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return tasks, err
+		}
+		tasks = append(tasks, &task)
+	}
+	return tasks, nil
+}
+
+func (r *balanceTaskRepository) Complete(ctx context.Context, taskID string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"task_id": taskID, "status": models.BalanceTaskPending})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrBalanceTaskNotFound
+	}
+	return nil
+}
+
+func (r *balanceTaskRepository) Retry(ctx context.Context, taskID string, lastError string, availableAt time.Time) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"task_id": taskID, "status": models.BalanceTaskPending},
+		bson.M{"$set": bson.M{"available_at": availableAt, "last_error": lastError}},
+	)
+	return err
+}
+
+func (r *balanceTaskRepository) MarkFailed(ctx context.Context, taskID string, lastError string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"task_id": taskID, "status": models.BalanceTaskPending},
+		bson.M{"$set": bson.M{"status": models.BalanceTaskFailed, "last_error": lastError}},
+	)
+	return err
+}
+
+func (r *balanceTaskRepository) CountPendingByGroupID(ctx context.Context, groupID string) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"group_id": groupID, "status": models.BalanceTaskPending})
+}
+
+// EnsureIndexes creates the indexes behind claiming due tasks and checking a group for pending ones
+func (r *balanceTaskRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, balanceTaskIndexes())
+	return err
+}
+
+func balanceTaskIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "task_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "available_at", Value: 1}}},
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "status", Value: 1}}},
+	}
+}
@@ -0,0 +1,101 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrBudgetNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodeBudgetNotFound, "budget not found")
+
+type BudgetRepository interface {
+	GetByGroupID(ctx context.Context, groupID string) (*models.GroupBudget, error)
+	Upsert(ctx context.Context, budget *models.GroupBudget) error
+	Delete(ctx context.Context, groupID string) error
+	RecordAlert(ctx context.Context, groupID string, alert models.BudgetAlert) (bool, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type budgetRepository struct {
+	collection *mongo.Collection
+}
+
+func NewBudgetRepository(db *mongo.Database) BudgetRepository {
+	return &budgetRepository{
+		collection: db.Collection("group_budgets"),
+	}
+}
+
+func (r *budgetRepository) GetByGroupID(ctx context.Context, groupID string) (*models.GroupBudget, error) {
+	var budget models.GroupBudget
+	err := r.collection.FindOne(ctx, bson.M{"group_id": groupID}).Decode(&budget)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrBudgetNotFound
+		}
+		return nil, err
+	}
+	return &budget, nil
+}
+
+// Upsert replaces the group's budget, including the alerts already sent
+func (r *budgetRepository) Upsert(ctx context.Context, budget *models.GroupBudget) error {
+	filter := bson.M{"group_id": budget.GroupID}
+	_, err := r.collection.ReplaceOne(ctx, filter, budget, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *budgetRepository) Delete(ctx context.Context, groupID string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"group_id": groupID})
+	return err
+}
+
+// RecordAlert marks the alert as sent and reports whether it wasn't already, so concurrent
+// expenses crossing the same threshold announce it once. Alerts from earlier months are dropped.
+func (r *budgetRepository) RecordAlert(ctx context.Context, groupID string, alert models.BudgetAlert) (bool, error) {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"group_id": groupID},
+		bson.M{"$pull": bson.M{"alerts": bson.M{"period": bson.M{"$ne": alert.Period}}}},
+	)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"group_id": groupID, "alerts": bson.M{"$not": bson.M{"$elemMatch": alertFilter(alert)}}},
+		bson.M{"$push": bson.M{"alerts": alert}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+func alertFilter(alert models.BudgetAlert) bson.M {
+	filter := bson.M{"period": alert.Period, "threshold": alert.Threshold}
+	if alert.Category == "" {
+		filter["category"] = bson.M{"$exists": false}
+	} else {
+		filter["category"] = alert.Category
+	}
+	return filter
+}
+
+// EnsureIndexes creates the index behind looking up a group's budget
+func (r *budgetRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, budgetIndexes())
+	return err
+}
+
+func budgetIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "group_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+}
@@ -0,0 +1,149 @@
+package repositories
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"divvydoo/backend/pkg/cache"
+)
+
+// The cached repositories keep the answers to the hottest permission checks in process. Only
+// positive answers are cached: a user who just joined, or was just created, is never refused,
+// and the writes that can turn an answer negative evict it on every replica.
+
+type deferredInvalidationsKey struct{}
+
+type deferredInvalidations struct {
+	mu      sync.Mutex
+	pending []func(ctx context.Context)
+}
+
+// DeferInvalidation returns a context under which the cached repositories hold their
+// invalidations back until flush is called. A write made in a transaction must only evict once
+// it commits: a read in between would see the old answer and cache it again. Call flush after
+// WithTransaction returns, committed or not; evicting for a write that rolled back is harmless.
+func DeferInvalidation(ctx context.Context) (context.Context, func()) {
+	deferred := &deferredInvalidations{}
+	flush := func() {
+		deferred.mu.Lock()
+		pending := deferred.pending
+		deferred.pending = nil
+		deferred.mu.Unlock()
+
+		// The writes are done by now, so the invalidations must not be cancelled with the request
+		flushCtx := context.WithoutCancel(ctx)
+		for _, invalidate := range pending {
+			invalidate(flushCtx)
+		}
+	}
+	return context.WithValue(ctx, deferredInvalidationsKey{}, deferred), flush
+}
+
+// invalidate evicts key now, or once the caller flushes if ctx defers invalidation
+func invalidate(ctx context.Context, invalidator cache.Invalidator, entity string, key string) {
+	if deferred, ok := ctx.Value(deferredInvalidationsKey{}).(*deferredInvalidations); ok {
+		deferred.mu.Lock()
+		deferred.pending = append(deferred.pending, func(ctx context.Context) {
+			invalidator.Invalidate(ctx, entity, key)
+		})
+		deferred.mu.Unlock()
+		return
+	}
+	invalidator.Invalidate(ctx, entity, key)
+}
+
+type cachedGroupRepository struct {
+	GroupRepository
+	members     *cache.Local[bool]
+	invalidator cache.Invalidator
+}
+
+// NewCachedGroupRepository caches IsMember for ttl
+func NewCachedGroupRepository(repo GroupRepository, invalidator cache.Invalidator, ttl time.Duration) GroupRepository {
+	members := cache.NewLocal[bool](ttl)
+	invalidator.Register(cache.EntityMembership, members)
+
+	return &cachedGroupRepository{
+		GroupRepository: repo,
+		members:         members,
+		invalidator:     invalidator,
+	}
+}
+
+func (r *cachedGroupRepository) IsMember(ctx context.Context, groupID string, userID string) (bool, error) {
+	key := groupID + "/" + userID
+	if _, ok := r.members.Get(key); ok {
+		return true, nil
+	}
+
+	version := r.members.Version()
+	isMember, err := r.GroupRepository.IsMember(ctx, groupID, userID)
+	if err == nil && isMember {
+		r.members.SetAt(key, true, version)
+	}
+	return isMember, err
+}
+
+func (r *cachedGroupRepository) RemoveMember(ctx context.Context, groupID string, userID string) error {
+	err := r.GroupRepository.RemoveMember(ctx, groupID, userID)
+	invalidate(ctx, r.invalidator, cache.EntityMembership, groupID+"/"+userID)
+	return err
+}
+
+func (r *cachedGroupRepository) ReassignMember(ctx context.Context, fromUserID string, toUserID string) ([]string, error) {
+	groupIDs, err := r.GroupRepository.ReassignMember(ctx, fromUserID, toUserID)
+	for _, groupID := range groupIDs {
+		invalidate(ctx, r.invalidator, cache.EntityMembership, groupID+"/"+fromUserID)
+	}
+	return groupIDs, err
+}
+
+func (r *cachedGroupRepository) MergeMember(ctx context.Context, groupID string, fromUserID string, toUserID string) error {
+	err := r.GroupRepository.MergeMember(ctx, groupID, fromUserID, toUserID)
+	invalidate(ctx, r.invalidator, cache.EntityMembership, groupID+"/"+fromUserID)
+	return err
+}
+
+func (r *cachedGroupRepository) Delete(ctx context.Context, groupID string) error {
+	err := r.GroupRepository.Delete(ctx, groupID)
+	invalidate(ctx, r.invalidator, cache.EntityMembership, groupID)
+	return err
+}
+
+type cachedUserRepository struct {
+	UserRepository
+	existing    *cache.Local[bool]
+	invalidator cache.Invalidator
+}
+
+// NewCachedUserRepository caches Exists for ttl
+func NewCachedUserRepository(repo UserRepository, invalidator cache.Invalidator, ttl time.Duration) UserRepository {
+	existing := cache.NewLocal[bool](ttl)
+	invalidator.Register(cache.EntityUser, existing)
+
+	return &cachedUserRepository{
+		UserRepository: repo,
+		existing:       existing,
+		invalidator:    invalidator,
+	}
+}
+
+func (r *cachedUserRepository) Exists(ctx context.Context, userID string) (bool, error) {
+	if _, ok := r.existing.Get(userID); ok {
+		return true, nil
+	}
+
+	version := r.existing.Version()
+	exists, err := r.UserRepository.Exists(ctx, userID)
+	if err == nil && exists {
+		r.existing.SetAt(userID, true, version)
+	}
+	return exists, err
+}
+
+func (r *cachedUserRepository) Delete(ctx context.Context, userID string) error {
+	err := r.UserRepository.Delete(ctx, userID)
+	invalidate(ctx, r.invalidator, cache.EntityUser, userID)
+	return err
+}
@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"context"
+
+	"divvydoo/backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type ClientErrorRepository interface {
+	Create(ctx context.Context, report *models.ClientErrorReport) (*models.ClientErrorReport, error)
+}
+
+type clientErrorRepository struct {
+	collection *mongo.Collection
+}
+
+func NewClientErrorRepository(db *mongo.Database) ClientErrorRepository {
+	return &clientErrorRepository{
+		collection: db.Collection("client_errors"),
+	}
+}
+
+func (r *clientErrorRepository) Create(ctx context.Context, report *models.ClientErrorReport) (*models.ClientErrorReport, error) {
+	result, err := r.collection.InsertOne(ctx, report)
+	if err != nil {
+		return nil, err
+	}
+
+	report.ID = result.InsertedID.(primitive.ObjectID)
+	return report, nil
+}
@@ -0,0 +1,172 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	// consistencyTolerance absorbs float rounding when comparing money totals
+	consistencyTolerance = 0.01
+	// consistencyIssueLimit caps how many offending documents each check reports
+	consistencyIssueLimit = 20
+)
+
+// ConsistencyRepository checks the invariants of a whole database, normally a restored copy
+// of a backup, and can drop that copy afterwards. It works on any database of the client.
+type ConsistencyRepository interface {
+	Check(ctx context.Context, database string) (map[string]int64, []string, error)
+	DropDatabase(ctx context.Context, database string) error
+}
+
+type consistencyRepository struct {
+	client *mongo.Client
+}
+
+func NewConsistencyRepository(client *mongo.Client) ConsistencyRepository {
+	return &consistencyRepository{client: client}
+}
+
+// Check counts the documents of every collection and reports broken invariants: group balances
+// that don't net to zero, expenses whose shares don't add up to the amount, and expenses
+// pointing at groups that don't exist.
+func (r *consistencyRepository) Check(ctx context.Context, database string) (map[string]int64, []string, error) {
+	db := r.client.Database(database)
+
+	names, err := db.ListCollectionNames(ctx, bson.M{"type": "collection"})
+	if err != nil {
+		return nil, nil, err
+	}
+	counts := make(map[string]int64, len(names))
+	for _, name := range names {
+		if counts[name], err = db.Collection(name).CountDocuments(ctx, bson.M{}); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var issues []string
+	for _, check := range []func(context.Context, *mongo.Database) ([]string, error){
+		unbalancedGroups,
+		unevenExpenses,
+		orphanedExpenses,
+	} {
+		found, err := check(ctx, db)
+		if err != nil {
+			return nil, nil, err
+		}
+		issues = append(issues, found...)
+	}
+
+	return counts, issues, nil
+}
+
+func (r *consistencyRepository) DropDatabase(ctx context.Context, database string) error {
+	return r.client.Database(database).Drop(ctx)
+}
+
+// unbalancedGroups finds groups whose member balances in a currency don't sum to zero;
+// every expense and settlement moves money between members, so they always should
+func unbalancedGroups(ctx context.Context, db *mongo.Database) ([]string, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"group_id": bson.M{"$ne": nil}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"group_id": "$group_id", "currency": "$currency"},
+			"total": bson.M{"$sum": "$balance"},
+		}}},
+		{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"total": bson.M{"$gt": consistencyTolerance}},
+			bson.M{"total": bson.M{"$lt": -consistencyTolerance}},
+		}}}},
+		{{Key: "$limit", Value: consistencyIssueLimit}},
+	}
+
+	var results []struct {
+		ID struct {
+			GroupID  string `bson:"group_id"`
+			Currency string `bson:"currency"`
+		} `bson:"_id"`
+		Total float64 `bson:"total"`
+	}
+	if err := aggregateAll(ctx, db.Collection("balances"), pipeline, &results); err != nil {
+		return nil, err
+	}
+
+	issues := make([]string, 0, len(results))
+	for _, result := range results {
+		issues = append(issues, fmt.Sprintf("group %s balances in %s sum to %.2f instead of 0", result.ID.GroupID, result.ID.Currency, result.Total))
+	}
+	return issues, nil
+}
+
+// unevenExpenses finds live expenses whose split shares don't add up to the amount
+func unevenExpenses(ctx context.Context, db *mongo.Database) ([]string, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"is_deleted": bson.M{"$ne": true}}}},
+		{{Key: "$project", Value: bson.M{
+			"expense_id": 1,
+			"difference": bson.M{"$subtract": bson.A{"$amount", bson.M{"$sum": "$split.details.value"}}},
+		}}},
+		{{Key: "$match", Value: bson.M{"$or": bson.A{
+			bson.M{"difference": bson.M{"$gt": consistencyTolerance}},
+			bson.M{"difference": bson.M{"$lt": -consistencyTolerance}},
+		}}}},
+		{{Key: "$limit", Value: consistencyIssueLimit}},
+	}
+
+	var results []struct {
+		ExpenseID  string  `bson:"expense_id"`
+		Difference float64 `bson:"difference"`
+	}
+	if err := aggregateAll(ctx, db.Collection("expenses"), pipeline, &results); err != nil {
+		return nil, err
+	}
+
+	issues := make([]string, 0, len(results))
+	for _, result := range results {
+		issues = append(issues, fmt.Sprintf("expense %s shares miss its amount by %.2f", result.ExpenseID, result.Difference))
+	}
+	return issues, nil
+}
+
+// orphanedExpenses finds group expenses whose group no longer exists
+func orphanedExpenses(ctx context.Context, db *mongo.Database) ([]string, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"group_id": bson.M{"$ne": nil}, "is_deleted": bson.M{"$ne": true}}}},
+		{{Key: "$lookup", Value: bson.M{
+			"from":         "groups",
+			"localField":   "group_id",
+			"foreignField": "group_id",
+			"as":           "group",
+		}}},
+		{{Key: "$match", Value: bson.M{"group": bson.M{"$size": 0}}}},
+		{{Key: "$project", Value: bson.M{"expense_id": 1, "group_id": 1}}},
+		{{Key: "$limit", Value: consistencyIssueLimit}},
+	}
+
+	var results []struct {
+		ExpenseID string `bson:"expense_id"`
+		GroupID   string `bson:"group_id"`
+	}
+	if err := aggregateAll(ctx, db.Collection("expenses"), pipeline, &results); err != nil {
+		return nil, err
+	}
+
+	issues := make([]string, 0, len(results))
+	for _, result := range results {
+		issues = append(issues, fmt.Sprintf("expense %s belongs to missing group %s", result.ExpenseID, result.GroupID))
+	}
+	return issues, nil
+}
+
+func aggregateAll(ctx context.Context, coll *mongo.Collection, pipeline mongo.Pipeline, results interface{}) error {
+	cursor, err := coll.Aggregate(ctx, pipeline, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	return cursor.All(ctx, results)
+}
@@ -3,9 +3,11 @@ package repositories
 import (
 	"context"
 	"errors"
+	"net/http"
 	"time"
 
 	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,20 +16,66 @@ import (
 )
 
 var (
-	ErrExpenseNotFound = errors.New("expense not found")
+	ErrExpenseNotFound     = utils.NewCustomError(http.StatusNotFound, utils.CodeExpenseNotFound, "expense not found")
+	ErrExpenseStateChanged = utils.NewCustomError(http.StatusConflict, utils.CodeExpenseStateChanged, "expense status changed concurrently")
 )
 
+// affectsBalances matches the expenses whose split is applied to balances: those that never
+// needed approval and approved ones. Totals and statements leave the others out.
+var affectsBalances = bson.M{"$nin": bson.A{models.ExpenseStatusPendingApproval, models.ExpenseStatusRejected}}
+
 type ExpenseRepository interface {
 	StartSession() (mongo.Session, error)
 	CreateExpense(ctx context.Context, expense models.Expense) (*models.Expense, error)
+	InsertMany(ctx context.Context, expenses []models.Expense) error
 	GetByID(ctx context.Context, expenseID string) (*models.Expense, error)
+	GetByIDs(ctx context.Context, expenseIDs []string) ([]*models.Expense, error)
 	GetByGroupID(ctx context.Context, groupID string, limit, offset int64) ([]*models.Expense, error)
 	GetByUserID(ctx context.Context, userID string, limit, offset int64) ([]*models.Expense, error)
+	ListByGroupID(ctx context.Context, groupID string, query string, cursor *Cursor, limit, offset int64) ([]*models.Expense, string, error)
+	ListByUserID(ctx context.Context, userID string, cursor *Cursor, limit, offset int64) ([]*models.Expense, string, error)
 	Update(ctx context.Context, expense *models.Expense) (*models.Expense, error)
 	SoftDelete(ctx context.Context, expenseID string) error
 	HardDelete(ctx context.Context, expenseID string) error
 	CountByGroupID(ctx context.Context, groupID string) (int64, error)
 	CountByUserID(ctx context.Context, userID string) (int64, error)
+	Search(ctx context.Context, filter ExpenseSearchFilter) ([]*models.Expense, bool, error)
+	ForEachByGroupID(ctx context.Context, groupID string, fn func(*models.Expense) error) error
+	ForEachWithoutGroupSince(ctx context.Context, since time.Time, fn func(*models.Expense) error) error
+	// ForEach calls fn for every expense, deleted ones included, in no particular order
+	ForEach(ctx context.Context, fn func(*models.Expense) error) error
+	GetInPeriod(ctx context.Context, groupID *string, userID *string, from, to time.Time) ([]*models.Expense, error)
+	SumByCategoryInPeriod(ctx context.Context, groupID string, from, to time.Time) ([]CategoryTotal, error)
+	SumUserSharesByCategoryInPeriod(ctx context.Context, userID string, from, to time.Time) ([]CategoryTotal, error)
+	SumPaidAndConsumedInPeriod(ctx context.Context, groupID string, from, to time.Time) ([]ParticipantTotal, error)
+	CountMatching(ctx context.Context, m ExpenseMatch) (int64, error)
+	ListMatching(ctx context.Context, m ExpenseMatch) ([]ExpenseCategory, error)
+	SetCategory(ctx context.Context, groupID string, expenseIDs []string, category string) (int64, error)
+	MarkReviewed(ctx context.Context, expenseID string, status models.ExpenseStatus, reviewerID string, reason *string) error
+	// ReassignUser moves everything fromUserID created, paid, reviewed or has a share in,
+	// deleted expenses included, over to toUserID. Where both paid or share in an expense, what
+	// fromUserID paid or owes is added onto toUserID's.
+	ReassignUser(ctx context.Context, fromUserID string, toUserID string) error
+	EnsureIndexes(ctx context.Context) error
+	// BackfillDates dates the expenses stored before they had a date when they were created
+	BackfillDates(ctx context.Context) (int64, error)
+}
+
+// CategoryTotal is what a group spent, or a user's shares came to, in one category and currency.
+// Uncategorised expenses have an empty Category.
+type CategoryTotal struct {
+	Currency string  `bson:"currency"`
+	Category string  `bson:"category"`
+	Total    float64 `bson:"total"`
+}
+
+// ParticipantTotal is what a user paid towards a group's expenses, and what their shares of them
+// came to, in one currency
+type ParticipantTotal struct {
+	UserID   string
+	Currency string
+	Paid     float64
+	Consumed float64
 }
 
 type expenseRepository struct {
@@ -50,6 +98,9 @@ func (r *expenseRepository) CreateExpense(ctx context.Context, expense models.Ex
 	expense.CreatedAt = time.Now()
 	expense.UpdatedAt = expense.CreatedAt
 	expense.IsDeleted = false
+	if expense.Date.IsZero() {
+		expense.Date = expense.CreatedAt
+	}
 
 	result, err := r.collection.InsertOne(ctx, expense)
 	if err != nil {
@@ -60,6 +111,25 @@ func (r *expenseRepository) CreateExpense(ctx context.Context, expense models.Ex
 	return &expense, nil
 }
 
+// InsertMany stores expenses as given, keeping their timestamps; used by imports. Expenses
+// without a date are dated when they were created.
+func (r *expenseRepository) InsertMany(ctx context.Context, expenses []models.Expense) error {
+	if len(expenses) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(expenses))
+	for i := range expenses {
+		if expenses[i].Date.IsZero() {
+			expenses[i].Date = expenses[i].CreatedAt
+		}
+		docs[i] = expenses[i]
+	}
+
+	_, err := r.collection.InsertMany(ctx, docs)
+	return err
+}
+
 func (r *expenseRepository) GetByID(ctx context.Context, expenseID string) (*models.Expense, error) {
 	var expense models.Expense
 	filter := bson.M{
@@ -78,6 +148,27 @@ func (r *expenseRepository) GetByID(ctx context.Context, expenseID string) (*mod
 	return &expense, nil
 }
 
+// GetByIDs returns the non-deleted expenses among the given IDs, in no particular order
+func (r *expenseRepository) GetByIDs(ctx context.Context, expenseIDs []string) ([]*models.Expense, error) {
+	filter := bson.M{
+		"expense_id": bson.M{"$in": expenseIDs},
+		"is_deleted": false,
+	}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var expenses []*models.Expense
+	if err := cursor.All(ctx, &expenses); err != nil {
+		return nil, err
+	}
+
+	return expenses, nil
+}
+
 func (r *expenseRepository) GetByGroupID(ctx context.Context, groupID string, limit, offset int64) ([]*models.Expense, error) {
 	filter := bson.M{
 		"group_id":   groupID,
@@ -85,7 +176,7 @@ func (r *expenseRepository) GetByGroupID(ctx context.Context, groupID string, li
 	}
 
 	opts := options.Find().
-		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSort(bson.D{{Key: "date", Value: -1}, {Key: "_id", Value: -1}}).
 		SetSkip(offset)
 
 	if limit > 0 {
@@ -118,7 +209,7 @@ func (r *expenseRepository) GetByUserID(ctx context.Context, userID string, limi
 	}
 
 	opts := options.Find().
-		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetSort(bson.D{{Key: "date", Value: -1}, {Key: "_id", Value: -1}}).
 		SetSkip(offset)
 
 	if limit > 0 {
@@ -139,6 +230,312 @@ func (r *expenseRepository) GetByUserID(ctx context.Context, userID string, limi
 	return expenses, nil
 }
 
+// ListByGroupID lists a group's expenses, most recently spent first. A non-empty query restricts
+// the results to expenses whose title or description match it (full-text, by word).
+func (r *expenseRepository) ListByGroupID(ctx context.Context, groupID string, query string, cursor *Cursor, limit, offset int64) ([]*models.Expense, string, error) {
+	filter := bson.M{
+		"group_id":   groupID,
+		"is_deleted": false,
+	}
+	if query != "" {
+		filter["$text"] = bson.M{"$search": query}
+	}
+
+	return r.listPage(ctx, filter, cursor, limit, offset)
+}
+
+func (r *expenseRepository) ListByUserID(ctx context.Context, userID string, cursor *Cursor, limit, offset int64) ([]*models.Expense, string, error) {
+	filter := bson.M{
+		"is_deleted": false,
+		"$or": []bson.M{
+			{"creator_id": userID},
+			{"paid_by.user_id": userID},
+			{"split.details.user_id": userID},
+		},
+	}
+
+	return r.listPage(ctx, filter, cursor, limit, offset)
+}
+
+// listPage pages through expenses by date, so its cursors hold dates rather than created_at
+func (r *expenseRepository) listPage(ctx context.Context, filter bson.M, cursor *Cursor, limit, offset int64) ([]*models.Expense, string, error) {
+	applyCursorBy(filter, "date", cursor)
+
+	mongoCursor, err := r.collection.Find(ctx, filter, cursorFindOptionsBy("date", limit, offset))
+	if err != nil {
+		return nil, "", err
+	}
+	defer mongoCursor.Close(ctx)
+
+	var expenses []*models.Expense
+	if err := mongoCursor.All(ctx, &expenses); err != nil {
+		return nil, "", err
+	}
+
+	expenses, next := pageOf(expenses, limit, func(e *models.Expense) Cursor {
+		return Cursor{CreatedAt: e.Date, ID: e.ID}
+	})
+	return expenses, next, nil
+}
+
+// ForEachByGroupID calls fn for every expense of the group, earliest dated first, decoding one
+// document at a time so large groups can be processed without loading them into memory.
+func (r *expenseRepository) ForEachByGroupID(ctx context.Context, groupID string, fn func(*models.Expense) error) error {
+	filter := bson.M{
+		"group_id":   groupID,
+		"is_deleted": false,
+	}
+	opts := options.Find().
+		SetSort(bson.D{{Key: "date", Value: 1}, {Key: "_id", Value: 1}}).
+		SetBatchSize(500)
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var expense models.Expense
+		if err := cursor.Decode(&expense); err != nil {
+			return err
+		}
+		if err := fn(&expense); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// ForEachWithoutGroupSince calls fn for every expense created since the given time outside any
+// group. Only the fields that say who took part are decoded.
+func (r *expenseRepository) ForEachWithoutGroupSince(ctx context.Context, since time.Time, fn func(*models.Expense) error) error {
+	filter := bson.M{
+		"group_id":   nil,
+		"is_deleted": false,
+		"created_at": bson.M{"$gte": since},
+	}
+	opts := options.Find().
+		SetProjection(bson.M{"expense_id": 1, "creator_id": 1, "paid_by.user_id": 1, "split.details.user_id": 1, "created_at": 1}).
+		SetBatchSize(500)
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var expense models.Expense
+		if err := cursor.Decode(&expense); err != nil {
+			return err
+		}
+		if err := fn(&expense); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+func (r *expenseRepository) ForEach(ctx context.Context, fn func(*models.Expense) error) error {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetBatchSize(500))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var expense models.Expense
+		if err := cursor.Decode(&expense); err != nil {
+			return err
+		}
+		if err := fn(&expense); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
+
+// SumByCategoryInPeriod adds up the group's expenses dated in [from, to) per currency and category
+func (r *expenseRepository) SumByCategoryInPeriod(ctx context.Context, groupID string, from, to time.Time) ([]CategoryTotal, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"group_id":   groupID,
+			"is_deleted": false,
+			"status":     affectsBalances,
+			"date":       bson.M{"$gte": from, "$lt": to},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"currency": "$currency", "category": bson.M{"$ifNull": bson.A{"$category", ""}}},
+			"total": bson.M{"$sum": "$amount"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":      0,
+			"currency": "$_id.currency",
+			"category": "$_id.category",
+			"total":    1,
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []CategoryTotal
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// SumUserSharesByCategoryInPeriod adds up the user's shares of the expenses dated in [from, to),
+// across all groups, per currency and category
+func (r *expenseRepository) SumUserSharesByCategoryInPeriod(ctx context.Context, userID string, from, to time.Time) ([]CategoryTotal, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"split.details.user_id": userID,
+			"is_deleted":            false,
+			"status":                affectsBalances,
+			"date":                  bson.M{"$gte": from, "$lt": to},
+		}}},
+		{{Key: "$unwind", Value: "$split.details"}},
+		{{Key: "$match", Value: bson.M{"split.details.user_id": userID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"currency": "$currency", "category": bson.M{"$ifNull": bson.A{"$category", ""}}},
+			"total": bson.M{"$sum": "$split.details.value"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":      0,
+			"currency": "$_id.currency",
+			"category": "$_id.category",
+			"total":    1,
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []CategoryTotal
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// SumPaidAndConsumedInPeriod adds up, per user and currency, what was paid and what was owed across
+// the group's expenses dated in [from, to)
+func (r *expenseRepository) SumPaidAndConsumedInPeriod(ctx context.Context, groupID string, from, to time.Time) ([]ParticipantTotal, error) {
+	sumBy := func(array string, amount string) bson.A {
+		return bson.A{
+			bson.M{"$unwind": "$" + array},
+			bson.M{"$group": bson.M{
+				"_id":   bson.M{"user_id": "$" + array + ".user_id", "currency": "$currency"},
+				"total": bson.M{"$sum": "$" + array + "." + amount},
+			}},
+		}
+	}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{
+			"group_id":   groupID,
+			"is_deleted": false,
+			"status":     affectsBalances,
+			"date":       bson.M{"$gte": from, "$lt": to},
+		}}},
+		{{Key: "$facet", Value: bson.M{
+			"paid":     sumBy("paid_by", "amount"),
+			"consumed": sumBy("split.details", "value"),
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	type sum struct {
+		ID struct {
+			UserID   string `bson:"user_id"`
+			Currency string `bson:"currency"`
+		} `bson:"_id"`
+		Total float64 `bson:"total"`
+	}
+	var facets []struct {
+		Paid     []sum `bson:"paid"`
+		Consumed []sum `bson:"consumed"`
+	}
+	if err := cursor.All(ctx, &facets); err != nil {
+		return nil, err
+	}
+	if len(facets) == 0 {
+		return nil, nil
+	}
+
+	type key struct{ userID, currency string }
+	merged := make(map[key]*ParticipantTotal)
+	get := func(s sum) *ParticipantTotal {
+		k := key{s.ID.UserID, s.ID.Currency}
+		if merged[k] == nil {
+			merged[k] = &ParticipantTotal{UserID: s.ID.UserID, Currency: s.ID.Currency}
+		}
+		return merged[k]
+	}
+	for _, s := range facets[0].Paid {
+		get(s).Paid = s.Total
+	}
+	for _, s := range facets[0].Consumed {
+		get(s).Consumed = s.Total
+	}
+
+	totals := make([]ParticipantTotal, 0, len(merged))
+	for _, total := range merged {
+		totals = append(totals, *total)
+	}
+	return totals, nil
+}
+
+// GetInPeriod returns expenses dated in [from, to), oldest first, limited to a group,
+// to the expenses a user takes part in, or both when both are given.
+func (r *expenseRepository) GetInPeriod(ctx context.Context, groupID *string, userID *string, from, to time.Time) ([]*models.Expense, error) {
+	filter := bson.M{
+		"is_deleted": false,
+		"status":     affectsBalances,
+		"date":       bson.M{"$gte": from, "$lt": to},
+	}
+	if groupID != nil {
+		filter["group_id"] = *groupID
+	}
+	if userID != nil {
+		filter["$or"] = []bson.M{
+			{"creator_id": *userID},
+			{"paid_by.user_id": *userID},
+			{"split.details.user_id": *userID},
+		}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "date", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var expenses []*models.Expense
+	if err := cursor.All(ctx, &expenses); err != nil {
+		return nil, err
+	}
+
+	return expenses, nil
+}
+
 func (r *expenseRepository) Update(ctx context.Context, expense *models.Expense) (*models.Expense, error) {
 	expense.UpdatedAt = time.Now()
 
@@ -149,14 +546,23 @@ func (r *expenseRepository) Update(ctx context.Context, expense *models.Expense)
 
 	update := bson.M{
 		"$set": bson.M{
-			"title":      expense.Title,
-			"amount":     expense.Amount,
-			"currency":   expense.Currency,
-			"paid_by":    expense.PaidBy,
-			"split":      expense.Split,
-			"updated_at": expense.UpdatedAt,
+			"title":       expense.Title,
+			"description": expense.Description,
+			"notes":       expense.Notes,
+			"category":    expense.Category,
+			"amount":      expense.Amount,
+			"currency":    expense.Currency,
+			"paid_by":     expense.PaidBy,
+			"split":       expense.Split,
+			"date":        expense.Date,
+			"updated_at":  expense.UpdatedAt,
 		},
 	}
+	if expense.Location != nil {
+		update["$set"].(bson.M)["location"] = expense.Location
+	} else {
+		update["$unset"] = bson.M{"location": ""}
+	}
 
 	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
 	var updatedExpense models.Expense
@@ -172,6 +578,147 @@ func (r *expenseRepository) Update(ctx context.Context, expense *models.Expense)
 	return &updatedExpense, nil
 }
 
+// MarkReviewed records an admin's approval or rejection of an expense pending approval, so
+// concurrent reviews can't both take effect
+func (r *expenseRepository) MarkReviewed(ctx context.Context, expenseID string, status models.ExpenseStatus, reviewerID string, reason *string) error {
+	now := time.Now()
+	filter := bson.M{
+		"expense_id": expenseID,
+		"is_deleted": false,
+		"status":     models.ExpenseStatusPendingApproval,
+	}
+	set := bson.M{
+		"status":      status,
+		"reviewed_by": reviewerID,
+		"reviewed_at": now,
+		"updated_at":  now,
+	}
+	if reason != nil {
+		set["reject_reason"] = *reason
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrExpenseStateChanged
+	}
+
+	return nil
+}
+
+func (r *expenseRepository) ReassignUser(ctx context.Context, fromUserID string, toUserID string) error {
+	now := time.Now()
+
+	// A user can only be listed once per expense, so expenses listing both are merged first
+	for _, array := range []string{"paid_by", "split.details"} {
+		filter := bson.M{"$and": bson.A{
+			bson.M{array + ".user_id": fromUserID},
+			bson.M{array + ".user_id": toUserID},
+		}}
+		cursor, err := r.collection.Find(ctx, filter)
+		if err != nil {
+			return err
+		}
+		var expenses []*models.Expense
+		if err := cursor.All(ctx, &expenses); err != nil {
+			return err
+		}
+
+		for _, expense := range expenses {
+			mergeExpenseParticipant(expense, fromUserID, toUserID)
+			update := bson.M{"$set": bson.M{"paid_by": expense.PaidBy, "split": expense.Split, "updated_at": now}}
+			if _, err := r.collection.UpdateOne(ctx, bson.M{"expense_id": expense.ExpenseID}, update); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, field := range []string{"creator_id", "reviewed_by"} {
+		update := bson.M{"$set": bson.M{field: toUserID, "updated_at": now}}
+		if _, err := r.collection.UpdateMany(ctx, bson.M{field: fromUserID}, update); err != nil {
+			return err
+		}
+	}
+
+	for _, array := range []string{"paid_by", "split.details"} {
+		filter := bson.M{array + ".user_id": fromUserID}
+		update := bson.M{"$set": bson.M{array + ".$[entry].user_id": toUserID, "updated_at": now}}
+		opts := options.Update().SetArrayFilters(options.ArrayFilters{
+			Filters: []interface{}{bson.M{"entry.user_id": fromUserID}},
+		})
+		if _, err := r.collection.UpdateMany(ctx, filter, update, opts); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mergeExpenseParticipant folds fromUserID's payment and share of the expense into toUserID's.
+// An equal split both share in becomes a split by shares, one per participant, so toUserID owes
+// two.
+func mergeExpenseParticipant(expense *models.Expense, fromUserID string, toUserID string) {
+	paidBy := make([]models.PaidBy, 0, len(expense.PaidBy))
+	var paid float64
+	for _, pb := range expense.PaidBy {
+		if pb.UserID == fromUserID {
+			paid += pb.Amount
+			continue
+		}
+		paidBy = append(paidBy, pb)
+	}
+	for i := range paidBy {
+		if paidBy[i].UserID == toUserID {
+			paidBy[i].Amount += paid
+			paid = 0
+		}
+	}
+	if paid > 0 {
+		paidBy = append(paidBy, models.PaidBy{UserID: toUserID, Amount: paid})
+	}
+	expense.PaidBy = paidBy
+
+	sharing := 0
+	for _, detail := range expense.Split.Details {
+		if detail.UserID == fromUserID || detail.UserID == toUserID {
+			sharing++
+		}
+	}
+	if sharing == 2 && expense.Split.Type == models.SplitEqual {
+		expense.Split.Type = models.SplitShares
+		for i := range expense.Split.Details {
+			expense.Split.Details[i].Value = 1
+		}
+	}
+	details := make([]models.SplitShare, 0, len(expense.Split.Details))
+	var share *models.SplitShare
+	for _, detail := range expense.Split.Details {
+		if detail.UserID == fromUserID {
+			share = &detail
+			continue
+		}
+		details = append(details, detail)
+	}
+	if share != nil {
+		merged := false
+		for i := range details {
+			if details[i].UserID == toUserID {
+				details[i].Value += share.Value
+				details[i].Explanation = ""
+				merged = true
+			}
+		}
+		if !merged {
+			share.UserID = toUserID
+			details = append(details, *share)
+		}
+	}
+	expense.Split.Details = details
+}
+
 func (r *expenseRepository) SoftDelete(ctx context.Context, expenseID string) error {
 	filter := bson.M{"expense_id": expenseID}
 	update := bson.M{
@@ -0,0 +1,102 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExpenseMatch selects a group's expenses for a bulk change. Nil and empty fields are not
+// filtered on, except that a Category of "" matches uncategorised expenses.
+type ExpenseMatch struct {
+	GroupID  string
+	Title    string // contained in the title, case-insensitively
+	Category *string
+	From     *time.Time
+	To       *time.Time
+}
+
+func (m ExpenseMatch) filter() bson.M {
+	filter := bson.M{
+		"group_id":   m.GroupID,
+		"is_deleted": false,
+	}
+	if m.Title != "" {
+		filter["title"] = containsText(m.Title)
+	}
+	if m.Category != nil {
+		if *m.Category == "" {
+			filter["category"] = bson.M{"$in": bson.A{nil, ""}}
+		} else {
+			filter["category"] = *m.Category
+		}
+	}
+	if m.From != nil || m.To != nil {
+		date := bson.M{}
+		if m.From != nil {
+			date["$gte"] = *m.From
+		}
+		if m.To != nil {
+			date["$lt"] = *m.To
+		}
+		filter["date"] = date
+	}
+	return filter
+}
+
+// CountMatching counts the expenses a bulk change would apply to
+func (r *expenseRepository) CountMatching(ctx context.Context, m ExpenseMatch) (int64, error) {
+	return r.collection.CountDocuments(ctx, m.filter())
+}
+
+// ExpenseCategory is an expense's ID and current category
+type ExpenseCategory struct {
+	ExpenseID string `bson:"expense_id"`
+	Category  string `bson:"category"`
+}
+
+// ListMatching returns the IDs and categories of the matching expenses, oldest first
+func (r *expenseRepository) ListMatching(ctx context.Context, m ExpenseMatch) ([]ExpenseCategory, error) {
+	opts := options.Find().
+		SetProjection(bson.M{"expense_id": 1, "category": 1}).
+		SetSort(bson.D{{Key: "date", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err := r.collection.Find(ctx, m.filter(), opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	matches := []ExpenseCategory{}
+	if err := cursor.All(ctx, &matches); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// SetCategory changes the category of the listed expenses of a group, removing it when category
+// is empty, and returns how many changed. Expenses already in the category are left alone, so their
+// updated_at stays meaningful.
+func (r *expenseRepository) SetCategory(ctx context.Context, groupID string, expenseIDs []string, category string) (int64, error) {
+	filter := bson.M{
+		"group_id":   groupID,
+		"expense_id": bson.M{"$in": expenseIDs},
+		"is_deleted": false,
+		"category":   bson.M{"$ne": category},
+	}
+	update := bson.M{"$set": bson.M{"category": category, "updated_at": time.Now()}}
+	if category == "" {
+		filter["category"] = bson.M{"$exists": true}
+		update = bson.M{
+			"$set":   bson.M{"updated_at": time.Now()},
+			"$unset": bson.M{"category": ""},
+		}
+	}
+
+	result, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
@@ -0,0 +1,79 @@
+package repositories
+
+import (
+	"context"
+
+	"divvydoo/backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// maxExpenseRevisions caps how much of an expense's history is returned
+const maxExpenseRevisions = 500
+
+type ExpenseRevisionRepository interface {
+	Create(ctx context.Context, revision *models.ExpenseRevision) error
+	InsertMany(ctx context.Context, revisions []*models.ExpenseRevision) error
+	ListByExpenseID(ctx context.Context, expenseID string) ([]*models.ExpenseRevision, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type expenseRevisionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewExpenseRevisionRepository(db *mongo.Database) ExpenseRevisionRepository {
+	// Field values are stored untyped; decode documents as maps so they serialize as JSON objects
+	opts := options.Collection().SetBSONOptions(&options.BSONOptions{DefaultDocumentM: true})
+	return &expenseRevisionRepository{
+		collection: db.Collection("expense_revisions", opts),
+	}
+}
+
+func (r *expenseRevisionRepository) Create(ctx context.Context, revision *models.ExpenseRevision) error {
+	_, err := r.collection.InsertOne(ctx, revision)
+	return err
+}
+
+func (r *expenseRevisionRepository) InsertMany(ctx context.Context, revisions []*models.ExpenseRevision) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+	docs := make([]interface{}, len(revisions))
+	for i, revision := range revisions {
+		docs[i] = revision
+	}
+	_, err := r.collection.InsertMany(ctx, docs)
+	return err
+}
+
+// ListByExpenseID returns the expense's revisions, newest first
+func (r *expenseRevisionRepository) ListByExpenseID(ctx context.Context, expenseID string) ([]*models.ExpenseRevision, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "changed_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(maxExpenseRevisions)
+	cursor, err := r.collection.Find(ctx, bson.M{"expense_id": expenseID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	revisions := []*models.ExpenseRevision{}
+	if err := cursor.All(ctx, &revisions); err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+func (r *expenseRevisionRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, expenseRevisionIndexes())
+	return err
+}
+
+func expenseRevisionIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "expense_id", Value: 1}, {Key: "changed_at", Value: -1}}},
+	}
+}
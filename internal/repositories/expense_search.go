@@ -0,0 +1,178 @@
+package repositories
+
+import (
+	"context"
+	"regexp"
+	"time"
+
+	"divvydoo/backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ExpenseSearchFilter narrows an expense search. Nil and empty fields are not filtered on.
+// Results are always limited to expenses the user can see: ones they take part in, plus
+// every expense of the groups listed in VisibleGroupIDs.
+type ExpenseSearchFilter struct {
+	UserID          string
+	VisibleGroupIDs []string
+
+	GroupID   *string
+	PaidBy    *string
+	Currency  *string
+	Category  *string
+	Status    *string // approval status
+	From      *time.Time
+	To        *time.Time
+	MinAmount *float64
+	MaxAmount *float64
+	Query     string
+
+	SortField string // "date", "created_at" or "amount"; "date" by default
+	SortAsc   bool
+	Limit     int64
+	Offset    int64
+}
+
+// Search returns the matching expenses and whether more results follow this page
+func (r *expenseRepository) Search(ctx context.Context, f ExpenseSearchFilter) ([]*models.Expense, bool, error) {
+	visibility := []bson.M{
+		{"creator_id": f.UserID},
+		{"paid_by.user_id": f.UserID},
+		{"split.details.user_id": f.UserID},
+	}
+	if len(f.VisibleGroupIDs) > 0 {
+		visibility = append(visibility, bson.M{"group_id": bson.M{"$in": f.VisibleGroupIDs}})
+	}
+
+	filter := bson.M{
+		"is_deleted": false,
+		"$or":        visibility,
+	}
+
+	if f.GroupID != nil {
+		filter["group_id"] = *f.GroupID
+	}
+	if f.PaidBy != nil {
+		filter["paid_by.user_id"] = *f.PaidBy
+	}
+	if f.Currency != nil {
+		filter["currency"] = *f.Currency
+	}
+	if f.Category != nil {
+		filter["category"] = *f.Category
+	}
+	if f.Status != nil {
+		filter["status"] = *f.Status
+	}
+
+	if f.From != nil || f.To != nil {
+		date := bson.M{}
+		if f.From != nil {
+			date["$gte"] = *f.From
+		}
+		if f.To != nil {
+			date["$lt"] = *f.To
+		}
+		filter["date"] = date
+	}
+
+	if f.MinAmount != nil || f.MaxAmount != nil {
+		amount := bson.M{}
+		if f.MinAmount != nil {
+			amount["$gte"] = *f.MinAmount
+		}
+		if f.MaxAmount != nil {
+			amount["$lte"] = *f.MaxAmount
+		}
+		filter["amount"] = amount
+	}
+
+	if f.Query != "" {
+		filter["$and"] = []bson.M{{"$or": []bson.M{
+			{"title": containsText(f.Query)},
+			{"description": containsText(f.Query)},
+		}}}
+	}
+
+	sortField := f.SortField
+	if sortField == "" {
+		sortField = "date"
+	}
+	direction := -1
+	if f.SortAsc {
+		direction = 1
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: sortField, Value: direction}, {Key: "_id", Value: direction}}).
+		SetSkip(f.Offset).
+		SetLimit(f.Limit + 1)
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, false, err
+	}
+	defer cursor.Close(ctx)
+
+	var expenses []*models.Expense
+	if err := cursor.All(ctx, &expenses); err != nil {
+		return nil, false, err
+	}
+
+	if expenses == nil {
+		expenses = []*models.Expense{}
+	}
+
+	hasMore := int64(len(expenses)) > f.Limit
+	if hasMore {
+		expenses = expenses[:f.Limit]
+	}
+
+	return expenses, hasMore, nil
+}
+
+// EnsureIndexes creates the compound and text indexes backing expense listing and search.
+// Creating an index that already exists with the same definition is a no-op.
+func (r *expenseRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, expenseIndexes())
+	return err
+}
+
+// BackfillDates gives expenses stored before expenses had a date their created_at as date
+func (r *expenseRepository) BackfillDates(ctx context.Context) (int64, error) {
+	result, err := r.collection.UpdateMany(ctx,
+		bson.M{"date": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{"date": "$created_at"}}}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func expenseIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "is_deleted", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "is_deleted", Value: 1}, {Key: "date", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "category", Value: 1}, {Key: "date", Value: -1}}},
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "amount", Value: -1}}},
+		{Keys: bson.D{{Key: "creator_id", Value: 1}, {Key: "date", Value: -1}}},
+		{Keys: bson.D{{Key: "paid_by.user_id", Value: 1}, {Key: "date", Value: -1}}},
+		{Keys: bson.D{{Key: "split.details.user_id", Value: 1}, {Key: "date", Value: -1}}},
+		{Keys: bson.D{{Key: "date", Value: -1}}},
+		{
+			Keys: bson.D{{Key: "title", Value: "text"}, {Key: "description", Value: "text"}},
+			Options: options.Index().
+				SetName("expense_text").
+				SetWeights(bson.D{{Key: "title", Value: 3}, {Key: "description", Value: 1}}),
+		},
+	}
+}
+
+// containsText matches text anywhere in a field, case-insensitively, treating it literally
+func containsText(text string) bson.M {
+	return bson.M{"$regex": regexp.QuoteMeta(text), "$options": "i"}
+}
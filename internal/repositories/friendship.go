@@ -0,0 +1,163 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrFriendshipNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodeFriendshipNotFound, "friendship not found")
+	ErrFriendshipExists   = utils.NewCustomError(http.StatusConflict, utils.CodeAlreadyFriends, "friendship already exists")
+)
+
+type FriendshipRepository interface {
+	Create(ctx context.Context, friendship *models.Friendship) error
+	GetByID(ctx context.Context, friendshipID string) (*models.Friendship, error)
+	GetBetween(ctx context.Context, userID1, userID2 string) (*models.Friendship, error)
+	ListByUserID(ctx context.Context, userID string, status models.FriendshipStatus) ([]*models.Friendship, error)
+	// GetFriendIDs returns the users userID has an accepted friendship with
+	GetFriendIDs(ctx context.Context, userID string) ([]string, error)
+	MarkAccepted(ctx context.Context, friendshipID string, acceptedAt time.Time) error
+	Delete(ctx context.Context, friendshipID string) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type friendshipRepository struct {
+	collection *mongo.Collection
+}
+
+func NewFriendshipRepository(db *mongo.Database) FriendshipRepository {
+	return &friendshipRepository{
+		collection: db.Collection("friendships"),
+	}
+}
+
+// FriendshipPairKey identifies the pair of users whatever their order
+func FriendshipPairKey(userID1, userID2 string) string {
+	if userID2 < userID1 {
+		userID1, userID2 = userID2, userID1
+	}
+	return userID1 + "|" + userID2
+}
+
+func (r *friendshipRepository) Create(ctx context.Context, friendship *models.Friendship) error {
+	friendship.PairKey = FriendshipPairKey(friendship.RequesterID, friendship.AddresseeID)
+	friendship.UserIDs = []string{friendship.RequesterID, friendship.AddresseeID}
+
+	if _, err := r.collection.InsertOne(ctx, friendship); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrFriendshipExists
+		}
+		return err
+	}
+	return nil
+}
+
+func (r *friendshipRepository) GetByID(ctx context.Context, friendshipID string) (*models.Friendship, error) {
+	return r.findOne(ctx, bson.M{"friendship_id": friendshipID})
+}
+
+func (r *friendshipRepository) GetBetween(ctx context.Context, userID1, userID2 string) (*models.Friendship, error) {
+	return r.findOne(ctx, bson.M{"pair_key": FriendshipPairKey(userID1, userID2)})
+}
+
+func (r *friendshipRepository) findOne(ctx context.Context, filter bson.M) (*models.Friendship, error) {
+	var friendship models.Friendship
+	if err := r.collection.FindOne(ctx, filter).Decode(&friendship); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrFriendshipNotFound
+		}
+		return nil, err
+	}
+	return &friendship, nil
+}
+
+// ListByUserID returns the user's friendships in the status, sent and received, newest first
+func (r *friendshipRepository) ListByUserID(ctx context.Context, userID string, status models.FriendshipStatus) ([]*models.Friendship, error) {
+	filter := bson.M{"user_ids": userID, "status": status}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	friendships := []*models.Friendship{}
+	if err := cursor.All(ctx, &friendships); err != nil {
+		return nil, err
+	}
+	return friendships, nil
+}
+
+func (r *friendshipRepository) GetFriendIDs(ctx context.Context, userID string) ([]string, error) {
+	filter := bson.M{"user_ids": userID, "status": models.FriendshipAccepted}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetProjection(bson.M{"user_ids": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var friendships []models.Friendship
+	if err := cursor.All(ctx, &friendships); err != nil {
+		return nil, err
+	}
+
+	friendIDs := make([]string, 0, len(friendships))
+	for _, friendship := range friendships {
+		for _, id := range friendship.UserIDs {
+			if id != userID {
+				friendIDs = append(friendIDs, id)
+			}
+		}
+	}
+	return friendIDs, nil
+}
+
+// MarkAccepted accepts a pending request. ErrFriendshipNotFound means it isn't pending anymore.
+func (r *friendshipRepository) MarkAccepted(ctx context.Context, friendshipID string, acceptedAt time.Time) error {
+	filter := bson.M{"friendship_id": friendshipID, "status": models.FriendshipPending}
+	update := bson.M{"$set": bson.M{"status": models.FriendshipAccepted, "accepted_at": acceptedAt}}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrFriendshipNotFound
+	}
+	return nil
+}
+
+func (r *friendshipRepository) Delete(ctx context.Context, friendshipID string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"friendship_id": friendshipID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrFriendshipNotFound
+	}
+	return nil
+}
+
+// EnsureIndexes creates the unique pair index, which keeps two requests crossing each other from
+// creating two friendships, and the index behind listing a user's friendships
+func (r *friendshipRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, friendshipIndexes())
+	return err
+}
+
+func friendshipIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "friendship_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "pair_key", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_ids", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+	}
+}
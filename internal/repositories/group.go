@@ -3,9 +3,11 @@ package repositories
 import (
 	"context"
 	"errors"
+	"net/http"
 	"time"
 
 	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,20 +16,22 @@ import (
 )
 
 var (
-	ErrGroupNotFound        = errors.New("group not found")
-	ErrGroupAlreadyExists   = errors.New("group with this ID already exists")
-	ErrMemberNotInGroup     = errors.New("member not found in group")
-	ErrMemberAlreadyInGroup = errors.New("member already in group")
+	ErrGroupNotFound        = utils.NewCustomError(http.StatusNotFound, utils.CodeGroupNotFound, "group not found")
+	ErrGroupAlreadyExists   = utils.NewCustomError(http.StatusConflict, utils.CodeGroupAlreadyExists, "group with this ID already exists")
+	ErrMemberNotInGroup     = utils.NewCustomError(http.StatusNotFound, utils.CodeGroupMemberNotFound, "member not found in group")
+	ErrMemberAlreadyInGroup = utils.NewCustomError(http.StatusConflict, utils.CodeMemberAlreadyExists, "member already in group")
+	ErrGroupCurrencyChanged = utils.NewCustomError(http.StatusConflict, utils.CodeGroupCurrencyChanged, "the group's currency was changed meanwhile; try again")
 )
 
 // MemberWithUser contains member info joined with user details
 type MemberWithUser struct {
-	UserID   string          `bson:"user_id" json:"user_id"`
-	Role     models.UserRole `bson:"role" json:"role"`
-	JoinedAt time.Time       `bson:"joined_at" json:"joined_at"`
-	IsActive bool            `bson:"is_active" json:"is_active"`
-	Name     string          `bson:"name" json:"name"`
-	Email    string          `bson:"email" json:"email"`
+	UserID    string          `bson:"user_id" json:"user_id"`
+	Role      models.UserRole `bson:"role" json:"role"`
+	JoinedAt  time.Time       `bson:"joined_at" json:"joined_at"`
+	IsActive  bool            `bson:"is_active" json:"is_active"`
+	Name      string          `bson:"name" json:"name"`
+	Email     string          `bson:"email" json:"email"`
+	AvatarURL string          `bson:"avatar_url,omitempty" json:"avatar_url,omitempty"`
 }
 
 type GroupRepository interface {
@@ -35,15 +39,34 @@ type GroupRepository interface {
 	GetByID(ctx context.Context, groupID string) (*models.Group, error)
 	GetByUserID(ctx context.Context, userID string) ([]*models.Group, error)
 	Update(ctx context.Context, group *models.Group) (*models.Group, error)
+	UpdateSettings(ctx context.Context, groupID string, settings models.GroupSettings) (*models.Group, error)
+	// ChangeCurrency switches the group from change.From to change.To and records the change. It
+	// fails with ErrGroupCurrencyChanged if the group's currency is no longer change.From.
+	ChangeCurrency(ctx context.Context, groupID string, change models.CurrencyChange) (*models.Group, error)
+	// BumpSettlementVersion writes to the group within a transaction that creates settlements
+	// in it or nets debts across it, so it conflicts with another such transaction, or with one
+	// converting the group's currency, running at once
+	BumpSettlementVersion(ctx context.Context, groupID string) error
 	Delete(ctx context.Context, groupID string) error
 	AddMember(ctx context.Context, groupID string, member models.GroupMember) error
 	RemoveMember(ctx context.Context, groupID string, userID string) error
 	UpdateMemberRole(ctx context.Context, groupID string, userID string, role models.UserRole) error
+	// ReassignMember hands every membership of fromUserID, active or not and in archived groups
+	// too, over to toUserID and returns the IDs of the groups concerned
+	ReassignMember(ctx context.Context, fromUserID string, toUserID string) ([]string, error)
+	// MergeMember folds fromUserID's membership of the group into toUserID's, which is kept:
+	// active if either was, admin if either was, and joined when the first of them joined
+	MergeMember(ctx context.Context, groupID string, fromUserID string, toUserID string) error
+	// SharedGroupIDs returns the groups both users are or were members of
+	SharedGroupIDs(ctx context.Context, userID string, otherUserID string) ([]string, error)
 	IsMember(ctx context.Context, groupID string, userID string) (bool, error)
+	IsAdmin(ctx context.Context, groupID string, userID string) (bool, error)
 	GetNonMembers(ctx context.Context, groupID string, userIDs []string) ([]string, error) // Returns user IDs that are not members
 	GetMembers(ctx context.Context, groupID string) ([]models.GroupMember, error)
 	GetMembersWithDetails(ctx context.Context, groupID string) ([]MemberWithUser, error)
 	SetActive(ctx context.Context, groupID string, isActive bool) error
+	SetAvatar(ctx context.Context, groupID string, url string, avatar *models.AvatarImage) (*models.Group, error)
+	EnsureIndexes(ctx context.Context) error
 }
 
 type groupRepository struct {
@@ -139,6 +162,69 @@ func (r *groupRepository) Update(ctx context.Context, group *models.Group) (*mod
 	return &updatedGroup, nil
 }
 
+func (r *groupRepository) UpdateSettings(ctx context.Context, groupID string, settings models.GroupSettings) (*models.Group, error) {
+	filter := bson.M{"group_id": groupID}
+	update := bson.M{
+		"$set": bson.M{
+			"settings":   settings,
+			"updated_at": time.Now(),
+		},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updatedGroup models.Group
+
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedGroup)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, err
+	}
+
+	return &updatedGroup, nil
+}
+
+func (r *groupRepository) ChangeCurrency(ctx context.Context, groupID string, change models.CurrencyChange) (*models.Group, error) {
+	filter := bson.M{"group_id": groupID, "currency": change.From}
+	update := bson.M{
+		"$set": bson.M{
+			"currency":   change.To,
+			"updated_at": change.ChangedAt,
+		},
+		"$push": bson.M{"currency_changes": change},
+	}
+
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var updatedGroup models.Group
+
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updatedGroup)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrGroupCurrencyChanged
+		}
+		return nil, err
+	}
+
+	return &updatedGroup, nil
+}
+
+func (r *groupRepository) BumpSettlementVersion(ctx context.Context, groupID string) error {
+	filter := bson.M{"group_id": groupID}
+	update := bson.M{"$inc": bson.M{"settlement_version": 1}}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrGroupNotFound
+	}
+
+	return nil
+}
+
 func (r *groupRepository) Delete(ctx context.Context, groupID string) error {
 	filter := bson.M{"group_id": groupID}
 
@@ -233,6 +319,105 @@ func (r *groupRepository) UpdateMemberRole(ctx context.Context, groupID string,
 	return nil
 }
 
+func (r *groupRepository) ReassignMember(ctx context.Context, fromUserID string, toUserID string) ([]string, error) {
+	filter := bson.M{"members.user_id": fromUserID}
+
+	values, err := r.collection.Distinct(ctx, "group_id", filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	update := bson.M{
+		"$set": bson.M{
+			"members.$[member].user_id": toUserID,
+			"updated_at":                time.Now(),
+		},
+	}
+	opts := options.Update().SetArrayFilters(options.ArrayFilters{
+		Filters: []interface{}{bson.M{"member.user_id": fromUserID}},
+	})
+	if _, err := r.collection.UpdateMany(ctx, filter, update, opts); err != nil {
+		return nil, err
+	}
+
+	groupIDs := make([]string, 0, len(values))
+	for _, value := range values {
+		if groupID, ok := value.(string); ok {
+			groupIDs = append(groupIDs, groupID)
+		}
+	}
+	return groupIDs, nil
+}
+
+func (r *groupRepository) MergeMember(ctx context.Context, groupID string, fromUserID string, toUserID string) error {
+	group, err := r.GetByID(ctx, groupID)
+	if err != nil {
+		return err
+	}
+
+	var from *models.GroupMember
+	members := make([]models.GroupMember, 0, len(group.Members))
+	for i, member := range group.Members {
+		if member.UserID == fromUserID {
+			from = &group.Members[i]
+			continue
+		}
+		members = append(members, member)
+	}
+	if from == nil {
+		return ErrMemberNotInGroup
+	}
+
+	merged := false
+	for i := range members {
+		if members[i].UserID != toUserID {
+			continue
+		}
+		members[i].IsActive = members[i].IsActive || from.IsActive
+		if from.Role == models.RoleAdmin {
+			members[i].Role = models.RoleAdmin
+		}
+		if from.JoinedAt.Before(members[i].JoinedAt) {
+			members[i].JoinedAt = from.JoinedAt
+		}
+		merged = true
+	}
+	if !merged {
+		return ErrMemberNotInGroup
+	}
+
+	update := bson.M{"$set": bson.M{"members": members, "updated_at": time.Now()}}
+	if _, err := r.collection.UpdateOne(ctx, bson.M{"group_id": groupID}, update); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (r *groupRepository) SharedGroupIDs(ctx context.Context, userID string, otherUserID string) ([]string, error) {
+	filter := bson.M{
+		"$and": bson.A{
+			bson.M{"members.user_id": userID},
+			bson.M{"members.user_id": otherUserID},
+		},
+	}
+
+	values, err := r.collection.Distinct(ctx, "group_id", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	groupIDs := make([]string, 0, len(values))
+	for _, value := range values {
+		if groupID, ok := value.(string); ok {
+			groupIDs = append(groupIDs, groupID)
+		}
+	}
+	return groupIDs, nil
+}
+
 func (r *groupRepository) IsMember(ctx context.Context, groupID string, userID string) (bool, error) {
 	filter := bson.M{
 		"group_id": groupID,
@@ -252,6 +437,27 @@ func (r *groupRepository) IsMember(ctx context.Context, groupID string, userID s
 	return count > 0, nil
 }
 
+// IsAdmin reports whether the user is an active admin of the group
+func (r *groupRepository) IsAdmin(ctx context.Context, groupID string, userID string) (bool, error) {
+	filter := bson.M{
+		"group_id": groupID,
+		"members": bson.M{
+			"$elemMatch": bson.M{
+				"user_id":   userID,
+				"is_active": true,
+				"role":      models.RoleAdmin,
+			},
+		},
+	}
+
+	count, err := r.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
 // GetNonMembers returns user IDs from the provided list that are NOT active members of the group
 func (r *groupRepository) GetNonMembers(ctx context.Context, groupID string, userIDs []string) ([]string, error) {
 	if len(userIDs) == 0 {
@@ -340,13 +546,14 @@ func (r *groupRepository) GetMembersWithDetails(ctx context.Context, groupID str
 		}}},
 		// Project the final shape
 		{{Key: "$project", Value: bson.M{
-			"_id":       0,
-			"user_id":   "$members.user_id",
-			"role":      "$members.role",
-			"joined_at": "$members.joined_at",
-			"is_active": "$members.is_active",
-			"name":      "$user_info.name",
-			"email":     "$user_info.email",
+			"_id":        0,
+			"user_id":    "$members.user_id",
+			"role":       "$members.role",
+			"joined_at":  "$members.joined_at",
+			"is_active":  "$members.is_active",
+			"name":       "$user_info.name",
+			"email":      "$user_info.email",
+			"avatar_url": "$user_info.avatar_url",
 		}}},
 	}
 
@@ -368,6 +575,11 @@ func (r *groupRepository) GetMembersWithDetails(ctx context.Context, groupID str
 	return members, nil
 }
 
+// SetAvatar stores the group's avatar; a nil avatar removes it
+func (r *groupRepository) SetAvatar(ctx context.Context, groupID string, url string, avatar *models.AvatarImage) (*models.Group, error) {
+	return setAvatar[models.Group](ctx, r.collection, bson.M{"group_id": groupID}, url, avatar, ErrGroupNotFound)
+}
+
 func (r *groupRepository) SetActive(ctx context.Context, groupID string, isActive bool) error {
 	filter := bson.M{"group_id": groupID}
 	update := bson.M{
@@ -388,3 +600,16 @@ func (r *groupRepository) SetActive(ctx context.Context, groupID string, isActiv
 
 	return nil
 }
+
+// EnsureIndexes creates the unique group ID index and the index behind a user's group list
+func (r *groupRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, groupIndexes())
+	return err
+}
+
+func groupIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "group_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "members.user_id", Value: 1}}},
+	}
+}
@@ -0,0 +1,89 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrWebhookNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodeWebhookNotFound, "webhook not found")
+
+type GroupWebhookRepository interface {
+	GetByGroupID(ctx context.Context, groupID string) (*models.GroupWebhook, error)
+	Upsert(ctx context.Context, webhook *models.GroupWebhook) error
+	Delete(ctx context.Context, groupID string) error
+	RecordDelivery(ctx context.Context, groupID string, delivery models.WebhookDelivery) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type groupWebhookRepository struct {
+	collection *mongo.Collection
+}
+
+func NewGroupWebhookRepository(db *mongo.Database) GroupWebhookRepository {
+	return &groupWebhookRepository{
+		collection: db.Collection("group_webhooks"),
+	}
+}
+
+func (r *groupWebhookRepository) GetByGroupID(ctx context.Context, groupID string) (*models.GroupWebhook, error) {
+	var webhook models.GroupWebhook
+	err := r.collection.FindOne(ctx, bson.M{"group_id": groupID}).Decode(&webhook)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// Upsert replaces the group's webhook
+func (r *groupWebhookRepository) Upsert(ctx context.Context, webhook *models.GroupWebhook) error {
+	filter := bson.M{"group_id": webhook.GroupID}
+	_, err := r.collection.ReplaceOne(ctx, filter, webhook, options.Replace().SetUpsert(true))
+	return err
+}
+
+func (r *groupWebhookRepository) Delete(ctx context.Context, groupID string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"group_id": groupID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// RecordDelivery stores the outcome of a delivery and counts consecutive failures
+func (r *groupWebhookRepository) RecordDelivery(ctx context.Context, groupID string, delivery models.WebhookDelivery) error {
+	update := bson.M{"$set": bson.M{"last_delivery": delivery, "consecutive_failures": 0}}
+	if !delivery.Succeeded {
+		update = bson.M{
+			"$set": bson.M{"last_delivery": delivery},
+			"$inc": bson.M{"consecutive_failures": 1},
+		}
+	}
+	_, err := r.collection.UpdateOne(ctx, bson.M{"group_id": groupID}, update)
+	return err
+}
+
+// EnsureIndexes creates the index behind looking up a group's webhook
+func (r *groupWebhookRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, groupWebhookIndexes())
+	return err
+}
+
+func groupWebhookIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "group_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+}
@@ -0,0 +1,104 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// requiredIndexes lists, per collection, the indexes the repositories create with EnsureIndexes
+func requiredIndexes() map[string][]mongo.IndexModel {
+	return map[string][]mongo.IndexModel{
+		"users":             userIndexes(),
+		"groups":            groupIndexes(),
+		"balances":          balanceIndexes(),
+		"balance_history":   balanceHistoryIndexes(),
+		"direct_balances":   directBalanceIndexes(),
+		"balance_snapshots": balanceSnapshotIndexes(),
+		"balance_tasks":     balanceTaskIndexes(),
+		"expenses":          expenseIndexes(),
+		"settlements":       settlementIndexes(),
+		"nettings":          nettingIndexes(),
+		"notifications":     notificationIndexes(),
+		"ledger_entries":    ledgerIndexes(),
+		"group_suggestions": suggestionIndexes(),
+		"group_budgets":     budgetIndexes(),
+		"group_share_links": shareLinkIndexes(),
+		"expense_revisions": expenseRevisionIndexes(),
+		"group_webhooks":    groupWebhookIndexes(),
+		"friendships":       friendshipIndexes(),
+		"outbox":            outboxIndexes(),
+		"api_keys":          apiKeyIndexes(),
+		"payment_events":    paymentEventIndexes(),
+	}
+}
+
+// MissingIndexes reports the required indexes that don't exist in db, as "collection.index_name"
+func MissingIndexes(ctx context.Context, db *mongo.Database) ([]string, error) {
+	var missing []string
+	for collection, models := range requiredIndexes() {
+		existing, err := indexNames(ctx, db.Collection(collection))
+		if err != nil {
+			return nil, err
+		}
+		for _, model := range models {
+			name, err := indexName(model)
+			if err != nil {
+				return nil, err
+			}
+			if !existing[name] {
+				missing = append(missing, collection+"."+name)
+			}
+		}
+	}
+
+	sort.Strings(missing)
+	return missing, nil
+}
+
+func indexNames(ctx context.Context, coll *mongo.Collection) (map[string]bool, error) {
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		// Listing the indexes of a collection that doesn't exist yet fails with NamespaceNotFound
+		var cmdErr mongo.CommandError
+		if errors.As(err, &cmdErr) && cmdErr.Name == "NamespaceNotFound" {
+			return map[string]bool{}, nil
+		}
+		return nil, err
+	}
+
+	var specs []bson.M
+	if err := cursor.All(ctx, &specs); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		if name, ok := spec["name"].(string); ok {
+			names[name] = true
+		}
+	}
+	return names, nil
+}
+
+// indexName is the explicit name of the index or, like the server, its keys joined as field_direction
+func indexName(model mongo.IndexModel) (string, error) {
+	if model.Options != nil && model.Options.Name != nil {
+		return *model.Options.Name, nil
+	}
+
+	keys, ok := model.Keys.(bson.D)
+	if !ok {
+		return "", fmt.Errorf("index keys must be a bson.D, got %T", model.Keys)
+	}
+	parts := make([]string, 0, len(keys)*2)
+	for _, key := range keys {
+		parts = append(parts, key.Key, fmt.Sprint(key.Value))
+	}
+	return strings.Join(parts, "_"), nil
+}
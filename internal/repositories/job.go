@@ -0,0 +1,204 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrJobNotFound       = utils.NewCustomError(http.StatusNotFound, utils.CodeJobNotFound, "job not found")
+	ErrJobAlreadyRunning = utils.NewCustomError(http.StatusConflict, utils.CodeJobAlreadyRunning, "a job of this type is already running")
+)
+
+type JobRepository interface {
+	// Create stores a queued job. It fails with ErrJobAlreadyRunning if the job has a lock that
+	// an unfinished job holds.
+	Create(ctx context.Context, job *models.Job) (*models.Job, error)
+	GetByID(ctx context.Context, jobID string) (*models.Job, error)
+	List(ctx context.Context, jobType string, limit int64) ([]*models.Job, error)
+	MarkRunning(ctx context.Context, jobID string) error
+	UpdateProgress(ctx context.Context, jobID string, progress, total int) error
+	MarkSucceeded(ctx context.Context, jobID string, result map[string]interface{}) error
+	MarkFailed(ctx context.Context, jobID string, reason string) error
+	Heartbeat(ctx context.Context, jobID string) error
+	// FailStale fails the unfinished jobs whose last heartbeat, or creation if they never
+	// started, is before cutoff, and returns how many there were
+	FailStale(ctx context.Context, cutoff time.Time, reason string) (int64, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type jobRepository struct {
+	collection *mongo.Collection
+}
+
+func NewJobRepository(db *mongo.Database) JobRepository {
+	return &jobRepository{
+		collection: db.Collection("jobs"),
+	}
+}
+
+func (r *jobRepository) Create(ctx context.Context, job *models.Job) (*models.Job, error) {
+	job.CreatedAt = time.Now()
+	job.Status = models.JobQueued
+
+	result, err := r.collection.InsertOne(ctx, job)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil, ErrJobAlreadyRunning
+		}
+		return nil, err
+	}
+
+	job.ID = result.InsertedID.(primitive.ObjectID)
+	return job, nil
+}
+
+func (r *jobRepository) GetByID(ctx context.Context, jobID string) (*models.Job, error) {
+	var job models.Job
+	filter := bson.M{"job_id": jobID}
+
+	err := r.collection.FindOne(ctx, filter).Decode(&job)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+func (r *jobRepository) List(ctx context.Context, jobType string, limit int64) ([]*models.Job, error) {
+	filter := bson.M{}
+	if jobType != "" {
+		filter["type"] = jobType
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	if limit > 0 {
+		opts.SetLimit(limit)
+	}
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var jobs []*models.Job
+	if err := cursor.All(ctx, &jobs); err != nil {
+		return nil, err
+	}
+
+	return jobs, nil
+}
+
+func (r *jobRepository) MarkRunning(ctx context.Context, jobID string) error {
+	now := time.Now()
+	return r.update(ctx, jobID, bson.M{"$set": bson.M{
+		"status":       models.JobRunning,
+		"started_at":   now,
+		"heartbeat_at": now,
+	}})
+}
+
+func (r *jobRepository) UpdateProgress(ctx context.Context, jobID string, progress, total int) error {
+	return r.update(ctx, jobID, bson.M{"$set": bson.M{
+		"progress":     progress,
+		"total":        total,
+		"heartbeat_at": time.Now(),
+	}})
+}
+
+// MarkSucceeded and MarkFailed release the job's lock, if it has one
+
+func (r *jobRepository) MarkSucceeded(ctx context.Context, jobID string, result map[string]interface{}) error {
+	return r.update(ctx, jobID, bson.M{
+		"$set": bson.M{
+			"status":      models.JobSucceeded,
+			"result":      result,
+			"finished_at": time.Now(),
+		},
+		"$unset": bson.M{"lock": ""},
+	})
+}
+
+func (r *jobRepository) MarkFailed(ctx context.Context, jobID string, reason string) error {
+	return r.update(ctx, jobID, bson.M{
+		"$set": bson.M{
+			"status":      models.JobFailed,
+			"error":       reason,
+			"finished_at": time.Now(),
+		},
+		"$unset": bson.M{"lock": ""},
+	})
+}
+
+func (r *jobRepository) Heartbeat(ctx context.Context, jobID string) error {
+	return r.update(ctx, jobID, bson.M{"$set": bson.M{"heartbeat_at": time.Now()}})
+}
+
+func (r *jobRepository) FailStale(ctx context.Context, cutoff time.Time, reason string) (int64, error) {
+	filter := bson.M{
+		"status": bson.M{"$in": []models.JobStatus{models.JobQueued, models.JobRunning}},
+		"$or": []bson.M{
+			{"heartbeat_at": bson.M{"$lt": cutoff}},
+			{"heartbeat_at": bson.M{"$exists": false}, "created_at": bson.M{"$lt": cutoff}},
+		},
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"status":      models.JobFailed,
+			"error":       reason,
+			"finished_at": time.Now(),
+		},
+		"$unset": bson.M{"lock": ""},
+	}
+
+	result, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.ModifiedCount, nil
+}
+
+func (r *jobRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, jobIndexes())
+	return err
+}
+
+func jobIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		// Only unfinished jobs hold a lock, so this allows one of each locked type at a time
+		{
+			Keys:    bson.D{{Key: "lock", Value: 1}},
+			Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"lock": bson.M{"$exists": true}}),
+		},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "heartbeat_at", Value: 1}}},
+	}
+}
+
+func (r *jobRepository) update(ctx context.Context, jobID string, update bson.M) error {
+	filter := bson.M{"job_id": jobID}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrJobNotFound
+	}
+
+	return nil
+}
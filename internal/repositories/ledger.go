@@ -0,0 +1,123 @@
+package repositories
+
+import (
+	"context"
+
+	"divvydoo/backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LedgerRepository stores the entries of the integer-cents ledger, see models.LedgerEntry
+type LedgerRepository interface {
+	Append(ctx context.Context, entry *models.LedgerEntry) error
+	// AppendOnce inserts the entry unless one with the same entry ID exists, without failing
+	// a surrounding transaction on the duplicate
+	AppendOnce(ctx context.Context, entry *models.LedgerEntry) error
+	HasGroup(ctx context.Context, groupID string) (bool, error)
+	GetGroupIDs(ctx context.Context) ([]string, error)
+	GetTotalsByGroupID(ctx context.Context, groupID string) ([]LedgerTotal, error)
+	ReassignUser(ctx context.Context, fromUserID string, toUserID string) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+// LedgerTotal is a user's ledger balance in one currency
+type LedgerTotal struct {
+	UserID   string `bson:"user_id"`
+	Currency string `bson:"currency"`
+	Cents    int64  `bson:"cents"`
+}
+
+type ledgerRepository struct {
+	collection *mongo.Collection
+}
+
+func NewLedgerRepository(db *mongo.Database) LedgerRepository {
+	return &ledgerRepository{
+		collection: db.Collection("ledger_entries"),
+	}
+}
+
+func (r *ledgerRepository) Append(ctx context.Context, entry *models.LedgerEntry) error {
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
+
+func (r *ledgerRepository) AppendOnce(ctx context.Context, entry *models.LedgerEntry) error {
+	filter := bson.M{"entry_id": entry.EntryID}
+	update := bson.M{"$setOnInsert": entry}
+	_, err := r.collection.UpdateOne(ctx, filter, update, options.Update().SetUpsert(true))
+	return err
+}
+
+func (r *ledgerRepository) HasGroup(ctx context.Context, groupID string) (bool, error) {
+	count, err := r.collection.CountDocuments(ctx, bson.M{"group_id": groupID}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetGroupIDs returns the groups that have ledger entries, i.e. that have been shadowed
+func (r *ledgerRepository) GetGroupIDs(ctx context.Context) ([]string, error) {
+	values, err := r.collection.Distinct(ctx, "group_id", bson.M{})
+	if err != nil {
+		return nil, err
+	}
+
+	groupIDs := make([]string, 0, len(values))
+	for _, value := range values {
+		if groupID, ok := value.(string); ok {
+			groupIDs = append(groupIDs, groupID)
+		}
+	}
+	return groupIDs, nil
+}
+
+func (r *ledgerRepository) GetTotalsByGroupID(ctx context.Context, groupID string) ([]LedgerTotal, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"group_id": groupID}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"user_id": "$user_id", "currency": "$currency"},
+			"cents": bson.M{"$sum": "$amount_cents"},
+		}}},
+		{{Key: "$project", Value: bson.M{
+			"_id":      0,
+			"user_id":  "$_id.user_id",
+			"currency": "$_id.currency",
+			"cents":    1,
+		}}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var totals []LedgerTotal
+	if err := cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+func (r *ledgerRepository) ReassignUser(ctx context.Context, fromUserID string, toUserID string) error {
+	_, err := r.collection.UpdateMany(ctx, bson.M{"user_id": fromUserID}, bson.M{"$set": bson.M{"user_id": toUserID}})
+	return err
+}
+
+// EnsureIndexes creates the indexes behind de-duplicating entries and summing a group's ledger
+func (r *ledgerRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, ledgerIndexes())
+	return err
+}
+
+func ledgerIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "entry_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "user_id", Value: 1}, {Key: "currency", Value: 1}}},
+	}
+}
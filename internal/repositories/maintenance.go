@@ -0,0 +1,213 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MaintenanceRepository runs database-level maintenance commands that
+// would otherwise require shell access to MongoDB.
+type MaintenanceRepository interface {
+	ListCollections(ctx context.Context) ([]string, error)
+	RebuildIndexes(ctx context.Context, collection string) (*IndexRebuild, error)
+	Compact(ctx context.Context, collection string) error
+}
+
+// IndexRebuild reports what rebuilding a collection's indexes did
+type IndexRebuild struct {
+	Rebuilt int
+	// Skipped names the unique indexes, which are never rebuilt: while one is dropped, writes
+	// could insert the duplicates it exists to prevent, and it could then not be created again
+	Skipped []string
+	// StandIns names the stand-ins an interrupted rebuild left for an index that is still
+	// missing. They are kept so queries have an index until the API creates the missing one on
+	// startup; the next rebuild drops them.
+	StandIns []string
+}
+
+// standInSuffix ends the names of the stand-ins built while an index is rebuilt
+const standInSuffix = "_rebuild"
+
+type maintenanceRepository struct {
+	db *mongo.Database
+}
+
+func NewMaintenanceRepository(db *mongo.Database) MaintenanceRepository {
+	return &maintenanceRepository{db: db}
+}
+
+func (r *maintenanceRepository) ListCollections(ctx context.Context) ([]string, error) {
+	return r.db.ListCollectionNames(ctx, bson.M{"type": "collection"})
+}
+
+// RebuildIndexes recreates every secondary index of a collection from its current
+// specification. The _id index and unique indexes are left untouched.
+//
+// Queries keep an index while one is rebuilt: a stand-in with the same keys followed by _id is
+// built first, and dropped once the index is back. Text indexes can't have a stand-in, since a
+// collection has at most one, so text search fails while one is rebuilt. Stand-ins an interrupted
+// rebuild left behind are never rebuilt themselves; those whose index is back are dropped.
+func (r *maintenanceRepository) RebuildIndexes(ctx context.Context, collection string) (*IndexRebuild, error) {
+	coll := r.db.Collection(collection)
+
+	cursor, err := coll.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Specs are read as bson.D so compound keys keep their order
+	var specs []bson.D
+	if err := cursor.All(ctx, &specs); err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(specs))
+	for _, spec := range specs {
+		name, _ := specValue(spec, "name").(string)
+		names[name] = true
+	}
+
+	result := &IndexRebuild{}
+	for _, spec := range specs {
+		name, _ := specValue(spec, "name").(string)
+		if name == "" || name == "_id_" {
+			continue
+		}
+		if strings.HasSuffix(name, standInSuffix) {
+			if !names[strings.TrimSuffix(name, standInSuffix)] {
+				result.StandIns = append(result.StandIns, name)
+				continue
+			}
+			if _, err := coll.Indexes().DropOne(ctx, name); err != nil {
+				return result, fmt.Errorf("failed to drop the stand-in %s left by an earlier rebuild: %w", name, err)
+			}
+			continue
+		}
+		if unique, _ := specValue(spec, "unique").(bool); unique {
+			result.Skipped = append(result.Skipped, name)
+			continue
+		}
+
+		def := indexDefinition(spec)
+		standIn := standInDefinition(def, name+standInSuffix)
+		if standIn != nil {
+			if err := r.createIndex(ctx, collection, standIn); err != nil {
+				return result, fmt.Errorf("failed to build a stand-in for %s: %w", name, err)
+			}
+		}
+
+		if _, err := coll.Indexes().DropOne(ctx, name); err != nil {
+			return result, err
+		}
+		if err := r.createIndex(ctx, collection, def); err != nil {
+			// The stand-in, if any, stays so queries still have an index
+			return result, err
+		}
+
+		if standIn != nil {
+			if _, err := coll.Indexes().DropOne(ctx, name+standInSuffix); err != nil {
+				return result, err
+			}
+		}
+		result.Rebuilt++
+	}
+
+	return result, nil
+}
+
+func (r *maintenanceRepository) Compact(ctx context.Context, collection string) error {
+	return r.db.RunCommand(ctx, bson.D{{Key: "compact", Value: collection}}).Err()
+}
+
+func (r *maintenanceRepository) createIndex(ctx context.Context, collection string, def bson.D) error {
+	cmd := bson.D{
+		{Key: "createIndexes", Value: collection},
+		{Key: "indexes", Value: bson.A{def}},
+	}
+	return r.db.RunCommand(ctx, cmd).Err()
+}
+
+// indexDefinition converts a listIndexes document back into a createIndexes definition
+func indexDefinition(spec bson.D) bson.D {
+	def := bson.D{}
+	for _, elem := range spec {
+		switch elem.Key {
+		case "v", "ns", "textIndexVersion":
+			// Server-managed fields
+			continue
+		case "key":
+			def = append(def, bson.E{Key: "key", Value: indexKeys(spec)})
+			continue
+		}
+		def = append(def, elem)
+	}
+	return def
+}
+
+// indexKeys returns the index's key pattern. Text indexes list theirs with internal _fts and
+// _ftsx keys in place of the text fields, which are put back from the weights; the fields
+// indexed before and after them stay where they are.
+func indexKeys(spec bson.D) bson.D {
+	keys, _ := specValue(spec, "key").(bson.D)
+	weights, ok := specValue(spec, "weights").(bson.D)
+	if !ok {
+		return keys
+	}
+
+	rebuilt := bson.D{}
+	for _, key := range keys {
+		switch key.Key {
+		case "_fts":
+			for _, weight := range weights {
+				rebuilt = append(rebuilt, bson.E{Key: weight.Key, Value: "text"})
+			}
+		case "_ftsx":
+		default:
+			rebuilt = append(rebuilt, key)
+		}
+	}
+	return rebuilt
+}
+
+// standInDefinition is an index that serves the same queries as def while def is rebuilt: its
+// keys followed by _id, under another name. Text and wildcard indexes, and those that already
+// include _id, can't have one and get nil.
+func standInDefinition(def bson.D, name string) bson.D {
+	if specValue(def, "weights") != nil {
+		return nil
+	}
+	keys, _ := specValue(def, "key").(bson.D)
+	for _, key := range keys {
+		if key.Key == "_id" || strings.HasSuffix(key.Key, "$**") {
+			return nil
+		}
+	}
+
+	standIn := bson.D{}
+	for _, elem := range def {
+		switch elem.Key {
+		case "name":
+			elem.Value = name
+		case "key":
+			elem.Value = append(append(bson.D{}, keys...), bson.E{Key: "_id", Value: 1})
+		case "expireAfterSeconds":
+			// TTL indexes have a single field, so the compound stand-in can't expire documents
+			continue
+		}
+		standIn = append(standIn, elem)
+	}
+	return standIn
+}
+
+func specValue(spec bson.D, key string) interface{} {
+	for _, elem := range spec {
+		if elem.Key == key {
+			return elem.Value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package repositories
+
+import (
+	"context"
+
+	"divvydoo/backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type NettingRepository interface {
+	StartSession() (mongo.Session, error)
+	Create(ctx context.Context, netting *models.Netting) error
+	ListByUserID(ctx context.Context, userID string, limit int64) ([]*models.Netting, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type nettingRepository struct {
+	collection *mongo.Collection
+	client     *mongo.Client
+}
+
+func NewNettingRepository(db *mongo.Database) NettingRepository {
+	return &nettingRepository{
+		collection: db.Collection("nettings"),
+		client:     db.Client(),
+	}
+}
+
+func (r *nettingRepository) StartSession() (mongo.Session, error) {
+	return r.client.StartSession()
+}
+
+func (r *nettingRepository) Create(ctx context.Context, netting *models.Netting) error {
+	_, err := r.collection.InsertOne(ctx, netting)
+	return err
+}
+
+// ListByUserID returns the nettings the user initiated or was the counterparty of, newest first
+func (r *nettingRepository) ListByUserID(ctx context.Context, userID string, limit int64) ([]*models.Netting, error) {
+	filter := bson.M{
+		"$or": []bson.M{
+			{"initiator_id": userID},
+			{"counterparty_id": userID},
+		},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	nettings := []*models.Netting{}
+	if err := cursor.All(ctx, &nettings); err != nil {
+		return nil, err
+	}
+	return nettings, nil
+}
+
+// EnsureIndexes creates the indexes behind listing a user's nettings
+func (r *nettingRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, nettingIndexes())
+	return err
+}
+
+func nettingIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "netting_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "initiator_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "counterparty_id", Value: 1}, {Key: "created_at", Value: -1}}},
+	}
+}
@@ -0,0 +1,121 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrNotificationNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodeNotificationNotFound, "notification not found")
+)
+
+type NotificationRepository interface {
+	Create(ctx context.Context, notification *models.Notification) (*models.Notification, error)
+	ListByUserID(ctx context.Context, userID string, unreadOnly bool, cursor *Cursor, limit, offset int64) ([]*models.Notification, string, error)
+	MarkRead(ctx context.Context, notificationID string, userID string) (*models.Notification, error)
+	DeleteByUserID(ctx context.Context, userID string) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type notificationRepository struct {
+	collection *mongo.Collection
+}
+
+func NewNotificationRepository(db *mongo.Database) NotificationRepository {
+	return &notificationRepository{
+		collection: db.Collection("notifications"),
+	}
+}
+
+func (r *notificationRepository) Create(ctx context.Context, notification *models.Notification) (*models.Notification, error) {
+	notification.CreatedAt = time.Now()
+
+	result, err := r.collection.InsertOne(ctx, notification)
+	if err != nil {
+		return nil, err
+	}
+
+	notification.ID = result.InsertedID.(primitive.ObjectID)
+	return notification, nil
+}
+
+// ListByUserID lists the user's notifications, newest first
+func (r *notificationRepository) ListByUserID(ctx context.Context, userID string, unreadOnly bool, cursor *Cursor, limit, offset int64) ([]*models.Notification, string, error) {
+	filter := bson.M{"user_id": userID}
+	if unreadOnly {
+		filter["read_at"] = bson.M{"$exists": false}
+	}
+	applyCursor(filter, cursor)
+
+	mongoCursor, err := r.collection.Find(ctx, filter, cursorFindOptions(limit, offset))
+	if err != nil {
+		return nil, "", err
+	}
+	defer mongoCursor.Close(ctx)
+
+	var notifications []*models.Notification
+	if err := mongoCursor.All(ctx, &notifications); err != nil {
+		return nil, "", err
+	}
+
+	notifications, next := pageOf(notifications, limit, func(n *models.Notification) Cursor {
+		return Cursor{CreatedAt: n.CreatedAt, ID: n.ID}
+	})
+	return notifications, next, nil
+}
+
+// MarkRead sets read_at on the user's notification. Marking an already read notification
+// keeps its original read_at.
+func (r *notificationRepository) MarkRead(ctx context.Context, notificationID string, userID string) (*models.Notification, error) {
+	filter := bson.M{"notification_id": notificationID, "user_id": userID}
+
+	var notification models.Notification
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"notification_id": notificationID, "user_id": userID, "read_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"read_at": time.Now()}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&notification)
+	if err == nil {
+		return &notification, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+
+	if err := r.collection.FindOne(ctx, filter).Decode(&notification); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrNotificationNotFound
+		}
+		return nil, err
+	}
+	return &notification, nil
+}
+
+// DeleteByUserID removes the user's whole inbox
+func (r *notificationRepository) DeleteByUserID(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+// EnsureIndexes creates the index backing inbox listing
+func (r *notificationRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, notificationIndexes())
+	return err
+}
+
+func notificationIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "notification_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+	}
+}
@@ -0,0 +1,115 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrOutboxMessageNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodeOutboxMessageNotFound, "outbox message not found")
+
+type OutboxRepository interface {
+	Create(ctx context.Context, message *models.OutboxMessage) error
+	// Claim hands out up to limit pending messages that are due, in the order they were written,
+	// and hides them from other claims for the lease
+	Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxMessage, error)
+	// Complete removes a delivered message
+	Complete(ctx context.Context, messageID string) error
+	// Retry makes the message due again at availableAt
+	Retry(ctx context.Context, messageID string, lastError string, availableAt time.Time) error
+	MarkFailed(ctx context.Context, messageID string, lastError string) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type outboxRepository struct {
+	collection *mongo.Collection
+}
+
+func NewOutboxRepository(db *mongo.Database) OutboxRepository {
+	return &outboxRepository{
+		collection: db.Collection("outbox"),
+	}
+}
+
+func (r *outboxRepository) Create(ctx context.Context, message *models.OutboxMessage) error {
+	_, err := r.collection.InsertOne(ctx, message)
+	return err
+}
+
+func (r *outboxRepository) Claim(ctx context.Context, limit int, lease time.Duration) ([]*models.OutboxMessage, error) {
+	opts := options.FindOneAndUpdate().
+		SetSort(bson.D{{Key: "available_at", Value: 1}, {Key: "_id", Value: 1}}).
+		SetReturnDocument(options.After)
+
+	var messages []*models.OutboxMessage
+	for len(messages) < limit {
+		now := time.Now()
+		filter := bson.M{
+			"status":       models.OutboxPending,
+			"available_at": bson.M{"$lte": now},
+		}
+		update := bson.M{
+			"$set": bson.M{"available_at": now.Add(lease)},
+			"$inc": bson.M{"attempts": 1},
+		}
+
+		var message models.OutboxMessage
+		err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&message)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			break
+		}
+		if err != nil {
+			return messages, err
+		}
+		messages = append(messages, &message)
+	}
+	return messages, nil
+}
+
+func (r *outboxRepository) Complete(ctx context.Context, messageID string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"message_id": messageID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrOutboxMessageNotFound
+	}
+	return nil
+}
+
+func (r *outboxRepository) Retry(ctx context.Context, messageID string, lastError string, availableAt time.Time) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"message_id": messageID, "status": models.OutboxPending},
+		bson.M{"$set": bson.M{"available_at": availableAt, "last_error": lastError}},
+	)
+	return err
+}
+
+func (r *outboxRepository) MarkFailed(ctx context.Context, messageID string, lastError string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"message_id": messageID, "status": models.OutboxPending},
+		bson.M{"$set": bson.M{"status": models.OutboxFailed, "last_error": lastError}},
+	)
+	return err
+}
+
+// EnsureIndexes creates the indexes behind claiming due messages in order
+func (r *outboxRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, outboxIndexes())
+	return err
+}
+
+func outboxIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "message_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "available_at", Value: 1}, {Key: "_id", Value: 1}}},
+	}
+}
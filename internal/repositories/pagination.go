@@ -0,0 +1,119 @@
+package repositories
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrInvalidCursor = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidCursor, "invalid cursor")
+)
+
+// Cursor marks a position in a list ordered by created_at descending, then _id descending, or by
+// another time field for lists that say so; CreatedAt then holds that field.
+// Unlike skip/offset, seeking to a cursor stays cheap no matter how deep the page is.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        primitive.ObjectID
+}
+
+// Encode returns the opaque string form handed to clients as next_cursor
+func (c Cursor) Encode() string {
+	raw := strconv.FormatInt(c.CreatedAt.UnixMilli(), 10) + ":" + c.ID.Hex()
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// DecodeCursor parses a cursor produced by Encode. An empty string yields a nil cursor (first page).
+func DecodeCursor(encoded string) (*Cursor, error) {
+	if encoded == "" {
+		return nil, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), ":", 2)
+	if len(parts) != 2 {
+		return nil, ErrInvalidCursor
+	}
+
+	millis, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	id, err := primitive.ObjectIDFromHex(parts[1])
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	return &Cursor{CreatedAt: time.UnixMilli(millis).UTC(), ID: id}, nil
+}
+
+// applyCursor restricts filter to documents that come after the cursor position
+func applyCursor(filter bson.M, cursor *Cursor) {
+	applyCursorBy(filter, "created_at", cursor)
+}
+
+// applyCursorBy is applyCursor for a list ordered by field rather than created_at
+func applyCursorBy(filter bson.M, field string, cursor *Cursor) {
+	if cursor == nil {
+		return
+	}
+
+	after := bson.M{
+		"$or": []bson.M{
+			{field: bson.M{"$lt": cursor.CreatedAt}},
+			{field: cursor.CreatedAt, "_id": bson.M{"$lt": cursor.ID}},
+		},
+	}
+
+	if existing, ok := filter["$and"].([]bson.M); ok {
+		filter["$and"] = append(existing, after)
+	} else {
+		filter["$and"] = []bson.M{after}
+	}
+}
+
+// cursorFindOptions sorts in cursor order and fetches one extra document to detect a next page.
+// A non-zero offset skips that many documents past the cursor (or from the start).
+func cursorFindOptions(limit, offset int64) *options.FindOptions {
+	return cursorFindOptionsBy("created_at", limit, offset)
+}
+
+// cursorFindOptionsBy is cursorFindOptions for a list ordered by field rather than created_at
+func cursorFindOptionsBy(field string, limit, offset int64) *options.FindOptions {
+	opts := options.Find().
+		SetSort(bson.D{{Key: field, Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(limit + 1)
+	if offset > 0 {
+		opts.SetSkip(offset)
+	}
+	return opts
+}
+
+// pageOf trims the extra document fetched by cursorFindOptions and returns the
+// cursor for the next page, or an empty string when there are no more results.
+func pageOf[T any](items []T, limit int64, position func(T) Cursor) ([]T, string) {
+	if items == nil {
+		items = []T{}
+	}
+
+	if int64(len(items)) <= limit {
+		return items, ""
+	}
+
+	items = items[:limit]
+	return items, position(items[len(items)-1]).Encode()
+}
@@ -0,0 +1,53 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+
+	"divvydoo/backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrPaymentEventExists means the provider's event was already handled
+var ErrPaymentEventExists = errors.New("payment event already recorded")
+
+type PaymentEventRepository interface {
+	// Create records the event, or returns ErrPaymentEventExists if the provider's event ID is
+	// already recorded
+	Create(ctx context.Context, event *models.PaymentEvent) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type paymentEventRepository struct {
+	collection *mongo.Collection
+}
+
+func NewPaymentEventRepository(db *mongo.Database) PaymentEventRepository {
+	return &paymentEventRepository{
+		collection: db.Collection("payment_events"),
+	}
+}
+
+func (r *paymentEventRepository) Create(ctx context.Context, event *models.PaymentEvent) error {
+	_, err := r.collection.InsertOne(ctx, event)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrPaymentEventExists
+	}
+	return err
+}
+
+// EnsureIndexes creates the unique index on provider and event ID that makes handling an event idempotent
+func (r *paymentEventRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, paymentEventIndexes())
+	return err
+}
+
+func paymentEventIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "provider", Value: 1}, {Key: "event_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "settlement_id", Value: 1}}},
+	}
+}
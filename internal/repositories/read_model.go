@@ -0,0 +1,298 @@
+package repositories
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/pkg/clickhouse"
+)
+
+// ExpenseAggregations are the heavy expense aggregations behind the reports. ExpenseRepository
+// runs them on MongoDB; the reporting read model runs them on ClickHouse.
+type ExpenseAggregations interface {
+	SumUserSharesByCategoryInPeriod(ctx context.Context, userID string, from, to time.Time) ([]CategoryTotal, error)
+	SumPaidAndConsumedInPeriod(ctx context.Context, groupID string, from, to time.Time) ([]ParticipantTotal, error)
+}
+
+// ReadModelBatch is a set of records to store in the read model, each as it was at SyncedAt
+type ReadModelBatch struct {
+	Expenses    []*models.Expense
+	Settlements []*models.Settlement
+	Balances    []*models.Balance
+	SyncedAt    time.Time
+}
+
+func (b ReadModelBatch) Len() int {
+	return len(b.Expenses) + len(b.Settlements) + len(b.Balances)
+}
+
+// ReadModelRepository keeps the reporting read model: expenses, settlements and balances as
+// ClickHouse rows that reports and internal reporting query with SQL instead of aggregating on
+// the operational MongoDB. Every write appends a new version of each record; queries only see
+// the version with the latest synced_at.
+type ReadModelRepository interface {
+	ExpenseAggregations
+	Write(ctx context.Context, batch ReadModelBatch) error
+	// EnsureSchema creates the read model's tables
+	EnsureSchema(ctx context.Context) error
+}
+
+type readModelRepository struct {
+	client *clickhouse.Client
+}
+
+func NewReadModelRepository(client *clickhouse.Client) ReadModelRepository {
+	return &readModelRepository{client: client}
+}
+
+// The tables replace older versions of a record by synced_at when ClickHouse merges them, and
+// queries read them with FINAL so versions not merged yet are left out too. Expenses keep who
+// paid and who owes in parallel arrays, which ARRAY JOIN unrolls into one row per person.
+var readModelSchema = []string{
+	`CREATE TABLE IF NOT EXISTS expenses (
+		expense_id String,
+		group_id String,
+		creator_id String,
+		title String,
+		category String,
+		amount Float64,
+		currency LowCardinality(String),
+		status LowCardinality(String),
+		is_deleted Bool,
+		payer_ids Array(String),
+		paid_amounts Array(Float64),
+		share_user_ids Array(String),
+		share_values Array(Float64),
+		created_at DateTime64(3, 'UTC'),
+		updated_at DateTime64(3, 'UTC'),
+		synced_at DateTime64(6, 'UTC'),
+		date DateTime64(3, 'UTC') DEFAULT created_at
+	) ENGINE = ReplacingMergeTree(synced_at)
+	ORDER BY expense_id`,
+	// Tables created before expenses had a date; rows synced before then read their created_at
+	`ALTER TABLE expenses ADD COLUMN IF NOT EXISTS date DateTime64(3, 'UTC') DEFAULT created_at`,
+	`CREATE TABLE IF NOT EXISTS settlements (
+		settlement_id String,
+		group_id String,
+		from_user_id String,
+		to_user_id String,
+		amount Float64,
+		currency LowCardinality(String),
+		status LowCardinality(String),
+		method LowCardinality(String),
+		created_at DateTime64(3, 'UTC'),
+		completed_at Nullable(DateTime64(3, 'UTC')),
+		synced_at DateTime64(6, 'UTC')
+	) ENGINE = ReplacingMergeTree(synced_at)
+	ORDER BY settlement_id`,
+	`CREATE TABLE IF NOT EXISTS balances (
+		user_id String,
+		group_id String,
+		currency LowCardinality(String),
+		balance Float64,
+		updated_at DateTime64(3, 'UTC'),
+		synced_at DateTime64(6, 'UTC')
+	) ENGINE = ReplacingMergeTree(synced_at)
+	ORDER BY (user_id, group_id, currency)`,
+}
+
+func (r *readModelRepository) EnsureSchema(ctx context.Context) error {
+	for _, statement := range readModelSchema {
+		if err := r.client.Exec(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type expenseRow struct {
+	ExpenseID    string    `json:"expense_id"`
+	GroupID      string    `json:"group_id"` // empty outside groups
+	CreatorID    string    `json:"creator_id"`
+	Title        string    `json:"title"`
+	Category     string    `json:"category"`
+	Amount       float64   `json:"amount"`
+	Currency     string    `json:"currency"`
+	Status       string    `json:"status"`
+	IsDeleted    bool      `json:"is_deleted"`
+	PayerIDs     []string  `json:"payer_ids"`
+	PaidAmounts  []float64 `json:"paid_amounts"`
+	ShareUserIDs []string  `json:"share_user_ids"`
+	ShareValues  []float64 `json:"share_values"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+	SyncedAt     time.Time `json:"synced_at"`
+	Date         time.Time `json:"date"`
+}
+
+type settlementRow struct {
+	SettlementID string     `json:"settlement_id"`
+	GroupID      string     `json:"group_id"`
+	FromUserID   string     `json:"from_user_id"`
+	ToUserID     string     `json:"to_user_id"`
+	Amount       float64    `json:"amount"`
+	Currency     string     `json:"currency"`
+	Status       string     `json:"status"`
+	Method       string     `json:"method"`
+	CreatedAt    time.Time  `json:"created_at"`
+	CompletedAt  *time.Time `json:"completed_at"`
+	SyncedAt     time.Time  `json:"synced_at"`
+}
+
+type balanceRow struct {
+	UserID    string    `json:"user_id"`
+	GroupID   string    `json:"group_id"`
+	Currency  string    `json:"currency"`
+	Balance   float64   `json:"balance"`
+	UpdatedAt time.Time `json:"updated_at"`
+	SyncedAt  time.Time `json:"synced_at"`
+}
+
+func (r *readModelRepository) Write(ctx context.Context, batch ReadModelBatch) error {
+	syncedAt := batch.SyncedAt.UTC()
+
+	expenses := make([]interface{}, 0, len(batch.Expenses))
+	for _, expense := range batch.Expenses {
+		row := expenseRow{
+			ExpenseID:    expense.ExpenseID,
+			GroupID:      stringValue(expense.GroupID),
+			CreatorID:    expense.CreatorID,
+			Title:        expense.Title,
+			Category:     expense.Category,
+			Amount:       expense.Amount,
+			Currency:     expense.Currency,
+			Status:       string(expense.Status),
+			IsDeleted:    expense.IsDeleted,
+			PayerIDs:     []string{},
+			PaidAmounts:  []float64{},
+			ShareUserIDs: []string{},
+			ShareValues:  []float64{},
+			CreatedAt:    expense.CreatedAt.UTC(),
+			UpdatedAt:    expense.UpdatedAt.UTC(),
+			SyncedAt:     syncedAt,
+			Date:         expense.Date.UTC(),
+		}
+		for _, payer := range expense.PaidBy {
+			row.PayerIDs = append(row.PayerIDs, payer.UserID)
+			row.PaidAmounts = append(row.PaidAmounts, payer.Amount)
+		}
+		for _, share := range expense.Split.Details {
+			row.ShareUserIDs = append(row.ShareUserIDs, share.UserID)
+			row.ShareValues = append(row.ShareValues, share.Value)
+		}
+		expenses = append(expenses, row)
+	}
+	if err := r.client.Insert(ctx, "expenses", expenses); err != nil {
+		return err
+	}
+
+	settlements := make([]interface{}, 0, len(batch.Settlements))
+	for _, settlement := range batch.Settlements {
+		settlements = append(settlements, settlementRow{
+			SettlementID: settlement.SettlementID,
+			GroupID:      stringValue(settlement.GroupID),
+			FromUserID:   settlement.FromUserID,
+			ToUserID:     settlement.ToUserID,
+			Amount:       settlement.Amount,
+			Currency:     settlement.Currency,
+			Status:       string(settlement.Status),
+			Method:       string(settlement.Method),
+			CreatedAt:    settlement.CreatedAt.UTC(),
+			CompletedAt:  settlement.CompletedAt,
+			SyncedAt:     syncedAt,
+		})
+	}
+	if err := r.client.Insert(ctx, "settlements", settlements); err != nil {
+		return err
+	}
+
+	balances := make([]interface{}, 0, len(batch.Balances))
+	for _, balance := range batch.Balances {
+		balances = append(balances, balanceRow{
+			UserID:    balance.UserID,
+			GroupID:   stringValue(balance.GroupID),
+			Currency:  balance.Currency,
+			Balance:   balance.Balance,
+			UpdatedAt: balance.UpdatedAt.UTC(),
+			SyncedAt:  syncedAt,
+		})
+	}
+	return r.client.Insert(ctx, "balances", balances)
+}
+
+// countedExpenses matches, like affectsBalances, the expenses that count towards totals
+const countedExpenses = `NOT is_deleted AND status NOT IN ('pending_approval', 'rejected')
+	AND date >= {from:DateTime64(3, 'UTC')} AND date < {to:DateTime64(3, 'UTC')}`
+
+func (r *readModelRepository) SumUserSharesByCategoryInPeriod(ctx context.Context, userID string, from, to time.Time) ([]CategoryTotal, error) {
+	query := `SELECT currency, category, sum(share_value) AS total
+		FROM expenses FINAL
+		ARRAY JOIN share_user_ids AS share_user_id, share_values AS share_value
+		WHERE share_user_id = {user_id:String} AND ` + countedExpenses + `
+		GROUP BY currency, category`
+
+	var rows []struct {
+		Currency string  `json:"currency"`
+		Category string  `json:"category"`
+		Total    float64 `json:"total"`
+	}
+	if err := r.client.Select(ctx, query, periodArgs(from, to, "user_id", userID), &rows); err != nil {
+		return nil, fmt.Errorf("read model query failed: %v", err)
+	}
+
+	totals := make([]CategoryTotal, 0, len(rows))
+	for _, row := range rows {
+		totals = append(totals, CategoryTotal{Currency: row.Currency, Category: row.Category, Total: row.Total})
+	}
+	return totals, nil
+}
+
+func (r *readModelRepository) SumPaidAndConsumedInPeriod(ctx context.Context, groupID string, from, to time.Time) ([]ParticipantTotal, error) {
+	query := `SELECT user_id, currency, sum(paid) AS paid, sum(consumed) AS consumed
+		FROM (
+			SELECT payer_id AS user_id, currency, paid_amount AS paid, 0 AS consumed
+			FROM expenses FINAL
+			ARRAY JOIN payer_ids AS payer_id, paid_amounts AS paid_amount
+			WHERE group_id = {group_id:String} AND ` + countedExpenses + `
+			UNION ALL
+			SELECT share_user_id AS user_id, currency, 0 AS paid, share_value AS consumed
+			FROM expenses FINAL
+			ARRAY JOIN share_user_ids AS share_user_id, share_values AS share_value
+			WHERE group_id = {group_id:String} AND ` + countedExpenses + `
+		)
+		GROUP BY user_id, currency`
+
+	var rows []struct {
+		UserID   string  `json:"user_id"`
+		Currency string  `json:"currency"`
+		Paid     float64 `json:"paid"`
+		Consumed float64 `json:"consumed"`
+	}
+	if err := r.client.Select(ctx, query, periodArgs(from, to, "group_id", groupID), &rows); err != nil {
+		return nil, fmt.Errorf("read model query failed: %v", err)
+	}
+
+	totals := make([]ParticipantTotal, 0, len(rows))
+	for _, row := range rows {
+		totals = append(totals, ParticipantTotal{UserID: row.UserID, Currency: row.Currency, Paid: row.Paid, Consumed: row.Consumed})
+	}
+	return totals, nil
+}
+
+func periodArgs(from, to time.Time, name string, value string) map[string]string {
+	const layout = "2006-01-02 15:04:05.000"
+	return map[string]string{
+		"from": from.UTC().Format(layout),
+		"to":   to.UTC().Format(layout),
+		name:   value,
+	}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
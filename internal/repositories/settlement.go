@@ -3,9 +3,11 @@ package repositories
 import (
 	"context"
 	"errors"
+	"net/http"
 	"time"
 
 	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,21 +16,49 @@ import (
 )
 
 var (
-	ErrSettlementNotFound = errors.New("settlement not found")
+	ErrSettlementNotFound     = utils.NewCustomError(http.StatusNotFound, utils.CodeSettlementNotFound, "settlement not found")
+	ErrSettlementStateChanged = utils.NewCustomError(http.StatusConflict, utils.CodeSettlementStateChanged, "settlement status changed concurrently")
 )
 
 type SettlementRepository interface {
 	Create(ctx context.Context, settlement *models.Settlement) (*models.Settlement, error)
+	InsertMany(ctx context.Context, settlements []*models.Settlement) error
 	GetByID(ctx context.Context, settlementID string) (*models.Settlement, error)
+	GetByIDs(ctx context.Context, settlementIDs []string) ([]*models.Settlement, error)
 	GetByUserID(ctx context.Context, userID string, limit, offset int64) ([]*models.Settlement, error)
 	GetByGroupID(ctx context.Context, groupID string, limit, offset int64) ([]*models.Settlement, error)
+	ListByUserID(ctx context.Context, userID string, statuses []models.SettlementStatus, cursor *Cursor, limit, offset int64) ([]*models.Settlement, string, error)
+	ListByGroupID(ctx context.Context, groupID string, statuses []models.SettlementStatus, cursor *Cursor, limit, offset int64) ([]*models.Settlement, string, error)
 	GetBetweenUsers(ctx context.Context, userID1, userID2 string, limit, offset int64) ([]*models.Settlement, error)
 	UpdateStatus(ctx context.Context, settlementID string, status models.SettlementStatus) error
-	MarkCompleted(ctx context.Context, settlementID string, transactionID *string) error
-	MarkFailed(ctx context.Context, settlementID string, reason string) error
+	MarkAwaitingConfirmation(ctx context.Context, settlementID string, transactionID *string, autoConfirmAt *time.Time) error
+	MarkRejected(ctx context.Context, settlementID string, reason string) error
+	// MarkCompleted completes the settlement if it is still in one of the from statuses the caller
+	// read it in, and returns ErrSettlementStateChanged otherwise
+	MarkCompleted(ctx context.Context, settlementID string, from []models.SettlementStatus, transactionID *string, autoCompleted bool) error
+	// MarkFailed records that the payment for a settlement that hasn't completed failed
+	MarkFailed(ctx context.Context, settlementID string, transactionID *string, reason string) error
 	MarkCancelled(ctx context.Context, settlementID string) error
+	MarkVoided(ctx context.Context, settlementID string, userID string, reason string) error
+	MarkForceCancelled(ctx context.Context, settlementID string, adminID string, reason string) error
+	DeleteVoidedBefore(ctx context.Context, before time.Time) (int64, error)
 	GetPendingSettlements(ctx context.Context, userID string) ([]*models.Settlement, error)
+	GetDueForAutoConfirm(ctx context.Context, now time.Time, limit int64) ([]*models.Settlement, error)
+	GetCompletedInPeriod(ctx context.Context, groupID *string, userID *string, from, to time.Time) ([]*models.Settlement, error)
 	CountByUserID(ctx context.Context, userID string) (int64, error)
+	// CountOpenInGroup counts the group's settlements in currency that haven't moved balances yet
+	// but still can, i.e. pending or awaiting confirmation
+	CountOpenInGroup(ctx context.Context, groupID string, currency string) (int64, error)
+	// GetOpenInGroup returns the group's pending and awaiting-confirmation settlements
+	GetOpenInGroup(ctx context.Context, groupID string) ([]*models.Settlement, error)
+	// GetOpenBetweenUsers returns the pending and awaiting-confirmation settlements between the
+	// two users outside any group
+	GetOpenBetweenUsers(ctx context.Context, userID1, userID2 string) ([]*models.Settlement, error)
+	// ForEach calls fn for every settlement, in no particular order
+	ForEach(ctx context.Context, fn func(*models.Settlement) error) error
+	// ReassignUser moves the settlements fromUserID paid or received over to toUserID
+	ReassignUser(ctx context.Context, fromUserID string, toUserID string) error
+	EnsureIndexes(ctx context.Context) error
 	StartSession() (mongo.Session, error)
 }
 
@@ -62,6 +92,21 @@ func (r *settlementRepository) Create(ctx context.Context, settlement *models.Se
 	return settlement, nil
 }
 
+// InsertMany stores settlements as given, keeping their status and timestamps; used by imports
+func (r *settlementRepository) InsertMany(ctx context.Context, settlements []*models.Settlement) error {
+	if len(settlements) == 0 {
+		return nil
+	}
+
+	docs := make([]interface{}, len(settlements))
+	for i, settlement := range settlements {
+		docs[i] = settlement
+	}
+
+	_, err := r.collection.InsertMany(ctx, docs)
+	return err
+}
+
 func (r *settlementRepository) GetByID(ctx context.Context, settlementID string) (*models.Settlement, error) {
 	var settlement models.Settlement
 	filter := bson.M{"settlement_id": settlementID}
@@ -77,12 +122,30 @@ func (r *settlementRepository) GetByID(ctx context.Context, settlementID string)
 	return &settlement, nil
 }
 
+func (r *settlementRepository) GetByIDs(ctx context.Context, settlementIDs []string) ([]*models.Settlement, error) {
+	filter := bson.M{"settlement_id": bson.M{"$in": settlementIDs}}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var settlements []*models.Settlement
+	if err := cursor.All(ctx, &settlements); err != nil {
+		return nil, err
+	}
+
+	return settlements, nil
+}
+
 func (r *settlementRepository) GetByUserID(ctx context.Context, userID string, limit, offset int64) ([]*models.Settlement, error) {
 	filter := bson.M{
 		"$or": []bson.M{
 			{"from_user_id": userID},
 			{"to_user_id": userID},
 		},
+		"status": bson.M{"$ne": models.SettlementVoided},
 	}
 
 	opts := options.Find().
@@ -108,7 +171,7 @@ func (r *settlementRepository) GetByUserID(ctx context.Context, userID string, l
 }
 
 func (r *settlementRepository) GetByGroupID(ctx context.Context, groupID string, limit, offset int64) ([]*models.Settlement, error) {
-	filter := bson.M{"group_id": groupID}
+	filter := bson.M{"group_id": groupID, "status": bson.M{"$ne": models.SettlementVoided}}
 
 	opts := options.Find().
 		SetSort(bson.D{{Key: "created_at", Value: -1}}).
@@ -132,6 +195,51 @@ func (r *settlementRepository) GetByGroupID(ctx context.Context, groupID string,
 	return settlements, nil
 }
 
+// ListByUserID lists settlements the user paid or received, newest first. An empty statuses matches any
+// status but voided.
+func (r *settlementRepository) ListByUserID(ctx context.Context, userID string, statuses []models.SettlementStatus, cursor *Cursor, limit, offset int64) ([]*models.Settlement, string, error) {
+	filter := bson.M{
+		"$or": []bson.M{
+			{"from_user_id": userID},
+			{"to_user_id": userID},
+		},
+	}
+
+	return r.listPage(ctx, filter, statuses, cursor, limit, offset)
+}
+
+// ListByGroupID lists the group's settlements, newest first. An empty statuses matches any status but voided.
+func (r *settlementRepository) ListByGroupID(ctx context.Context, groupID string, statuses []models.SettlementStatus, cursor *Cursor, limit, offset int64) ([]*models.Settlement, string, error) {
+	filter := bson.M{"group_id": groupID}
+
+	return r.listPage(ctx, filter, statuses, cursor, limit, offset)
+}
+
+func (r *settlementRepository) listPage(ctx context.Context, filter bson.M, statuses []models.SettlementStatus, cursor *Cursor, limit, offset int64) ([]*models.Settlement, string, error) {
+	if len(statuses) > 0 {
+		filter["status"] = bson.M{"$in": statuses}
+	} else {
+		filter["status"] = bson.M{"$ne": models.SettlementVoided}
+	}
+	applyCursor(filter, cursor)
+
+	mongoCursor, err := r.collection.Find(ctx, filter, cursorFindOptions(limit, offset))
+	if err != nil {
+		return nil, "", err
+	}
+	defer mongoCursor.Close(ctx)
+
+	var settlements []*models.Settlement
+	if err := mongoCursor.All(ctx, &settlements); err != nil {
+		return nil, "", err
+	}
+
+	settlements, next := pageOf(settlements, limit, func(s *models.Settlement) Cursor {
+		return Cursor{CreatedAt: s.CreatedAt, ID: s.ID}
+	})
+	return settlements, next, nil
+}
+
 func (r *settlementRepository) GetBetweenUsers(ctx context.Context, userID1, userID2 string, limit, offset int64) ([]*models.Settlement, error) {
 	filter := bson.M{
 		"$or": []bson.M{
@@ -183,40 +291,65 @@ func (r *settlementRepository) UpdateStatus(ctx context.Context, settlementID st
 	return nil
 }
 
-func (r *settlementRepository) MarkCompleted(ctx context.Context, settlementID string, transactionID *string) error {
+// MarkAwaitingConfirmation records that the payer has paid. A nil autoConfirmAt means
+// the settlement waits for the payee indefinitely.
+func (r *settlementRepository) MarkAwaitingConfirmation(ctx context.Context, settlementID string, transactionID *string, autoConfirmAt *time.Time) error {
 	now := time.Now()
-	filter := bson.M{"settlement_id": settlementID}
-	update := bson.M{
-		"$set": bson.M{
-			"status":         models.SettlementCompleted,
-			"completed_at":   now,
-			"updated_at":     now,
-			"transaction_id": transactionID,
-		},
+	set := bson.M{
+		"status":         models.SettlementAwaitingConfirmation,
+		"marked_paid_at": now,
+		"updated_at":     now,
 	}
-
-	result, err := r.collection.UpdateOne(ctx, filter, update)
-	if err != nil {
-		return err
+	if transactionID != nil {
+		set["transaction_id"] = transactionID
 	}
 
-	if result.MatchedCount == 0 {
-		return ErrSettlementNotFound
+	unset := bson.M{"reject_reason": ""}
+	if autoConfirmAt != nil {
+		set["auto_confirm_at"] = *autoConfirmAt
+	} else {
+		unset["auto_confirm_at"] = ""
 	}
 
-	return nil
+	return r.transition(ctx, settlementID, []models.SettlementStatus{models.SettlementPending}, bson.M{
+		"$set":   set,
+		"$unset": unset,
+	})
 }
 
-func (r *settlementRepository) MarkFailed(ctx context.Context, settlementID string, reason string) error {
-	now := time.Now()
-	filter := bson.M{"settlement_id": settlementID}
-	update := bson.M{
+// MarkRejected returns a settlement awaiting confirmation to pending, recording why the payee rejected it
+func (r *settlementRepository) MarkRejected(ctx context.Context, settlementID string, reason string) error {
+	return r.transition(ctx, settlementID, []models.SettlementStatus{models.SettlementAwaitingConfirmation}, bson.M{
 		"$set": bson.M{
-			"status":         models.SettlementFailed,
-			"failed_at":      now,
-			"failure_reason": reason,
-			"updated_at":     now,
+			"status":        models.SettlementPending,
+			"reject_reason": reason,
+			"updated_at":    time.Now(),
 		},
+		"$unset": bson.M{"marked_paid_at": "", "auto_confirm_at": ""},
+	})
+}
+
+func (r *settlementRepository) MarkCompleted(ctx context.Context, settlementID string, from []models.SettlementStatus, transactionID *string, autoCompleted bool) error {
+	now := time.Now()
+	set := bson.M{
+		"status":         models.SettlementCompleted,
+		"completed_at":   now,
+		"updated_at":     now,
+		"auto_completed": autoCompleted,
+	}
+	if transactionID != nil {
+		set["transaction_id"] = transactionID
+	}
+
+	return r.transition(ctx, settlementID, from, bson.M{"$set": set})
+}
+
+// transition applies update only while the settlement is in one of the from statuses,
+// so concurrent requests can't move a settlement through the same step twice.
+func (r *settlementRepository) transition(ctx context.Context, settlementID string, from []models.SettlementStatus, update bson.M) error {
+	filter := bson.M{
+		"settlement_id": settlementID,
+		"status":        bson.M{"$in": from},
 	}
 
 	result, err := r.collection.UpdateOne(ctx, filter, update)
@@ -225,31 +358,88 @@ func (r *settlementRepository) MarkFailed(ctx context.Context, settlementID stri
 	}
 
 	if result.MatchedCount == 0 {
-		return ErrSettlementNotFound
+		return ErrSettlementStateChanged
 	}
 
 	return nil
 }
 
+func (r *settlementRepository) MarkFailed(ctx context.Context, settlementID string, transactionID *string, reason string) error {
+	now := time.Now()
+	set := bson.M{
+		"status":         models.SettlementFailed,
+		"failed_at":      now,
+		"failure_reason": reason,
+		"updated_at":     now,
+	}
+	if transactionID != nil {
+		set["transaction_id"] = transactionID
+	}
+
+	return r.transition(ctx, settlementID, []models.SettlementStatus{
+		models.SettlementPending,
+		models.SettlementAwaitingConfirmation,
+	}, bson.M{
+		"$set":   set,
+		"$unset": bson.M{"auto_confirm_at": ""},
+	})
+}
+
+// MarkCancelled cancels a settlement that hasn't been marked as paid yet
 func (r *settlementRepository) MarkCancelled(ctx context.Context, settlementID string) error {
-	filter := bson.M{"settlement_id": settlementID}
-	update := bson.M{
+	return r.transition(ctx, settlementID, []models.SettlementStatus{models.SettlementPending}, bson.M{
 		"$set": bson.M{
 			"status":     models.SettlementCancelled,
 			"updated_at": time.Now(),
 		},
-	}
+	})
+}
 
-	result, err := r.collection.UpdateOne(ctx, filter, update)
-	if err != nil {
-		return err
-	}
+// MarkVoided voids a settlement that hasn't moved any balance yet, recording who did it and why
+func (r *settlementRepository) MarkVoided(ctx context.Context, settlementID string, userID string, reason string) error {
+	now := time.Now()
+	return r.transition(ctx, settlementID, []models.SettlementStatus{models.SettlementPending, models.SettlementAwaitingConfirmation}, bson.M{
+		"$set": bson.M{
+			"status":      models.SettlementVoided,
+			"voided_at":   now,
+			"voided_by":   userID,
+			"void_reason": reason,
+			"updated_at":  now,
+		},
+		"$unset": bson.M{"auto_confirm_at": ""},
+	})
+}
 
-	if result.MatchedCount == 0 {
-		return ErrSettlementNotFound
-	}
+// MarkForceCancelled cancels a settlement that hasn't completed on an admin's behalf, recording
+// who did it and why
+func (r *settlementRepository) MarkForceCancelled(ctx context.Context, settlementID string, adminID string, reason string) error {
+	now := time.Now()
+	return r.transition(ctx, settlementID, []models.SettlementStatus{
+		models.SettlementPending,
+		models.SettlementAwaitingConfirmation,
+		models.SettlementFailed,
+	}, bson.M{
+		"$set": bson.M{
+			"status":        models.SettlementCancelled,
+			"cancelled_at":  now,
+			"cancelled_by":  adminID,
+			"cancel_reason": reason,
+			"updated_at":    now,
+		},
+		"$unset": bson.M{"auto_confirm_at": ""},
+	})
+}
 
-	return nil
+// DeleteVoidedBefore removes the settlements voided before the given time
+func (r *settlementRepository) DeleteVoidedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{
+		"status":    models.SettlementVoided,
+		"voided_at": bson.M{"$lt": before},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
 }
 
 func (r *settlementRepository) GetPendingSettlements(ctx context.Context, userID string) ([]*models.Settlement, error) {
@@ -277,6 +467,63 @@ func (r *settlementRepository) GetPendingSettlements(ctx context.Context, userID
 	return settlements, nil
 }
 
+// GetDueForAutoConfirm returns settlements still awaiting confirmation whose auto-confirm time has passed
+func (r *settlementRepository) GetDueForAutoConfirm(ctx context.Context, now time.Time, limit int64) ([]*models.Settlement, error) {
+	filter := bson.M{
+		"status":          models.SettlementAwaitingConfirmation,
+		"auto_confirm_at": bson.M{"$lte": now},
+	}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "auto_confirm_at", Value: 1}}).
+		SetLimit(limit)
+
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var settlements []*models.Settlement
+	if err := cursor.All(ctx, &settlements); err != nil {
+		return nil, err
+	}
+
+	return settlements, nil
+}
+
+// GetCompletedInPeriod returns settlements completed in [from, to), oldest first, limited to
+// a group, to the settlements a user paid or received, or both when both are given.
+func (r *settlementRepository) GetCompletedInPeriod(ctx context.Context, groupID *string, userID *string, from, to time.Time) ([]*models.Settlement, error) {
+	filter := bson.M{
+		"status":       models.SettlementCompleted,
+		"completed_at": bson.M{"$gte": from, "$lt": to},
+	}
+	if groupID != nil {
+		filter["group_id"] = *groupID
+	}
+	if userID != nil {
+		filter["$or"] = []bson.M{
+			{"from_user_id": *userID},
+			{"to_user_id": *userID},
+		}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "completed_at", Value: 1}})
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var settlements []*models.Settlement
+	if err := cursor.All(ctx, &settlements); err != nil {
+		return nil, err
+	}
+
+	return settlements, nil
+}
+
 func (r *settlementRepository) CountByUserID(ctx context.Context, userID string) (int64, error) {
 	filter := bson.M{
 		"$or": []bson.M{
@@ -287,3 +534,108 @@ func (r *settlementRepository) CountByUserID(ctx context.Context, userID string)
 
 	return r.collection.CountDocuments(ctx, filter)
 }
+
+func (r *settlementRepository) CountOpenInGroup(ctx context.Context, groupID string, currency string) (int64, error) {
+	filter := bson.M{
+		"group_id": groupID,
+		"currency": currency,
+		"status":   bson.M{"$in": []models.SettlementStatus{models.SettlementPending, models.SettlementAwaitingConfirmation}},
+	}
+
+	return r.collection.CountDocuments(ctx, filter)
+}
+
+func (r *settlementRepository) GetOpenInGroup(ctx context.Context, groupID string) ([]*models.Settlement, error) {
+	filter := bson.M{
+		"group_id": groupID,
+		"status":   bson.M{"$in": []models.SettlementStatus{models.SettlementPending, models.SettlementAwaitingConfirmation}},
+	}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var settlements []*models.Settlement
+	if err := cursor.All(ctx, &settlements); err != nil {
+		return nil, err
+	}
+
+	return settlements, nil
+}
+
+func (r *settlementRepository) GetOpenBetweenUsers(ctx context.Context, userID1, userID2 string) ([]*models.Settlement, error) {
+	filter := bson.M{
+		"$or": []bson.M{
+			{"from_user_id": userID1, "to_user_id": userID2},
+			{"from_user_id": userID2, "to_user_id": userID1},
+		},
+		"group_id": bson.M{"$exists": false},
+		"status":   bson.M{"$in": []models.SettlementStatus{models.SettlementPending, models.SettlementAwaitingConfirmation}},
+	}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var settlements []*models.Settlement
+	if err := cursor.All(ctx, &settlements); err != nil {
+		return nil, err
+	}
+
+	return settlements, nil
+}
+
+func (r *settlementRepository) ReassignUser(ctx context.Context, fromUserID string, toUserID string) error {
+	now := time.Now()
+
+	for _, field := range []string{"from_user_id", "to_user_id"} {
+		update := bson.M{"$set": bson.M{field: toUserID, "updated_at": now}}
+		if _, err := r.collection.UpdateMany(ctx, bson.M{field: fromUserID}, update); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EnsureIndexes creates the unique settlement ID index and the indexes behind listing a user's
+// or group's settlements by status and finding settlements due for auto-confirmation
+func (r *settlementRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, settlementIndexes())
+	return err
+}
+
+func settlementIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "settlement_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "from_user_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "to_user_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "status", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "auto_confirm_at", Value: 1}}},
+		{Keys: bson.D{{Key: "status", Value: 1}, {Key: "voided_at", Value: 1}}},
+	}
+}
+
+func (r *settlementRepository) ForEach(ctx context.Context, fn func(*models.Settlement) error) error {
+	cursor, err := r.collection.Find(ctx, bson.M{}, options.Find().SetBatchSize(500))
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var settlement models.Settlement
+		if err := cursor.Decode(&settlement); err != nil {
+			return err
+		}
+		if err := fn(&settlement); err != nil {
+			return err
+		}
+	}
+
+	return cursor.Err()
+}
@@ -0,0 +1,114 @@
+package repositories
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	ErrSettlementAuthorizationNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodeSettlementAuthorizationNotFound, "settlement authorization not found")
+)
+
+type SettlementAuthorizationRepository interface {
+	Upsert(ctx context.Context, authorization *models.SettlementAuthorization) (*models.SettlementAuthorization, error)
+	Delete(ctx context.Context, payeeID, payerID string) error
+	GetByPayeeID(ctx context.Context, payeeID string) ([]*models.SettlementAuthorization, error)
+	IsAuthorized(ctx context.Context, payeeID, payerID string, method models.SettlementMethod) (bool, error)
+}
+
+type settlementAuthorizationRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSettlementAuthorizationRepository(db *mongo.Database) SettlementAuthorizationRepository {
+	return &settlementAuthorizationRepository{
+		collection: db.Collection("settlement_authorizations"),
+	}
+}
+
+func (r *settlementAuthorizationRepository) Upsert(ctx context.Context, authorization *models.SettlementAuthorization) (*models.SettlementAuthorization, error) {
+	now := time.Now()
+	filter := bson.M{
+		"payee_id": authorization.PayeeID,
+		"payer_id": authorization.PayerID,
+	}
+	update := bson.M{
+		"$set": bson.M{
+			"methods":    authorization.Methods,
+			"updated_at": now,
+		},
+		"$setOnInsert": bson.M{
+			"payee_id":   authorization.PayeeID,
+			"payer_id":   authorization.PayerID,
+			"created_at": now,
+		},
+	}
+
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var updated models.SettlementAuthorization
+
+	err := r.collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&updated)
+	if err != nil {
+		return nil, err
+	}
+
+	return &updated, nil
+}
+
+func (r *settlementAuthorizationRepository) Delete(ctx context.Context, payeeID, payerID string) error {
+	filter := bson.M{
+		"payee_id": payeeID,
+		"payer_id": payerID,
+	}
+
+	result, err := r.collection.DeleteOne(ctx, filter)
+	if err != nil {
+		return err
+	}
+
+	if result.DeletedCount == 0 {
+		return ErrSettlementAuthorizationNotFound
+	}
+
+	return nil
+}
+
+func (r *settlementAuthorizationRepository) GetByPayeeID(ctx context.Context, payeeID string) ([]*models.SettlementAuthorization, error) {
+	filter := bson.M{"payee_id": payeeID}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var authorizations []*models.SettlementAuthorization
+	if err := cursor.All(ctx, &authorizations); err != nil {
+		return nil, err
+	}
+
+	return authorizations, nil
+}
+
+func (r *settlementAuthorizationRepository) IsAuthorized(ctx context.Context, payeeID, payerID string, method models.SettlementMethod) (bool, error) {
+	filter := bson.M{
+		"payee_id": payeeID,
+		"payer_id": payerID,
+		"methods":  method,
+	}
+
+	count, err := r.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,127 @@
+package repositories
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"divvydoo/backend/internal/models"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// shadowLedgerBalanceRepository mirrors every group balance update into the ledger while the
+// ledger is rolled out. The old balances stay authoritative: shadow writes share the caller's
+// context, and so its transaction, but their failures are only logged and surface as
+// discrepancies when the two are compared.
+type shadowLedgerBalanceRepository struct {
+	BalanceRepository
+	ledgerRepo LedgerRepository
+	enabled    func(groupID string) bool
+
+	// seeded remembers the groups known to have their opening entries. Seeding inside a
+	// transaction isn't remembered, as the transaction may still be rolled back.
+	seeded sync.Map
+}
+
+// NewShadowLedgerBalanceRepository shadows the updates of the groups for which enabled returns true
+func NewShadowLedgerBalanceRepository(repo BalanceRepository, ledgerRepo LedgerRepository, enabled func(groupID string) bool) BalanceRepository {
+	return &shadowLedgerBalanceRepository{
+		BalanceRepository: repo,
+		ledgerRepo:        ledgerRepo,
+		enabled:           enabled,
+	}
+}
+
+func (r *shadowLedgerBalanceRepository) UpdateBalance(ctx context.Context, userID string, groupID *string, currency string, amount float64) error {
+	shadow := groupID != nil && r.enabled(*groupID)
+	if shadow {
+		// The opening entries must be read before this update lands in the old balances
+		if err := r.seed(ctx, *groupID); err != nil {
+			log.Printf("Ledger shadow: failed to seed group %s: %v", *groupID, err)
+			shadow = false
+		}
+	}
+
+	if err := r.BalanceRepository.UpdateBalance(ctx, userID, groupID, currency, amount); err != nil {
+		return err
+	}
+
+	if shadow {
+		entry := &models.LedgerEntry{
+			EntryID:     uuid.New().String(),
+			UserID:      userID,
+			GroupID:     *groupID,
+			Currency:    currency,
+			AmountCents: toCents(amount),
+			Type:        models.LedgerEntryChange,
+			CreatedAt:   time.Now(),
+		}
+		if err := r.ledgerRepo.Append(ctx, entry); err != nil {
+			log.Printf("Ledger shadow: failed to record a %d cent %s change of user %s in group %s: %v", entry.AmountCents, currency, userID, *groupID, err)
+		}
+	}
+	return nil
+}
+
+// ReassignUser moves the user's ledger entries along with their balances, whether or not their
+// groups are shadowed right now, so both keep agreeing
+func (r *shadowLedgerBalanceRepository) ReassignUser(ctx context.Context, fromUserID string, toUserID string) error {
+	if err := r.BalanceRepository.ReassignUser(ctx, fromUserID, toUserID); err != nil {
+		return err
+	}
+	if err := r.ledgerRepo.ReassignUser(ctx, fromUserID, toUserID); err != nil {
+		log.Printf("Ledger shadow: failed to move the entries of user %s to user %s: %v", fromUserID, toUserID, err)
+	}
+	return nil
+}
+
+// seed carries the group's current balances over as opening entries the first time the group is
+// shadowed. Opening entries have fixed IDs, so concurrent seeding writes them once.
+func (r *shadowLedgerBalanceRepository) seed(ctx context.Context, groupID string) error {
+	if _, ok := r.seeded.Load(groupID); ok {
+		return nil
+	}
+
+	exists, err := r.ledgerRepo.HasGroup(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		balances, err := r.BalanceRepository.GetByGroupID(ctx, groupID)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		for _, balance := range balances {
+			cents := toCents(balance.Balance)
+			if cents == 0 {
+				continue
+			}
+			entry := &models.LedgerEntry{
+				EntryID:     "opening:" + groupID + ":" + balance.UserID + ":" + balance.Currency,
+				UserID:      balance.UserID,
+				GroupID:     groupID,
+				Currency:    balance.Currency,
+				AmountCents: cents,
+				Type:        models.LedgerEntryOpening,
+				CreatedAt:   now,
+			}
+			if err := r.ledgerRepo.AppendOnce(ctx, entry); err != nil {
+				return err
+			}
+		}
+	}
+
+	if mongo.SessionFromContext(ctx) == nil {
+		r.seeded.Store(groupID, struct{}{})
+	}
+	return nil
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
@@ -0,0 +1,97 @@
+package repositories
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrShareLinkNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodeShareLinkNotFound, "share link not found")
+
+type ShareLinkRepository interface {
+	Create(ctx context.Context, link *models.GroupShareLink) error
+	GetByTokenHash(ctx context.Context, tokenHash string) (*models.GroupShareLink, error)
+	ListActiveByGroupID(ctx context.Context, groupID string, now time.Time) ([]*models.GroupShareLink, error)
+	Delete(ctx context.Context, groupID string, linkID string) error
+	EnsureIndexes(ctx context.Context) error
+}
+
+type shareLinkRepository struct {
+	collection *mongo.Collection
+}
+
+func NewShareLinkRepository(db *mongo.Database) ShareLinkRepository {
+	return &shareLinkRepository{
+		collection: db.Collection("group_share_links"),
+	}
+}
+
+func (r *shareLinkRepository) Create(ctx context.Context, link *models.GroupShareLink) error {
+	_, err := r.collection.InsertOne(ctx, link)
+	return err
+}
+
+// GetByTokenHash returns the link whether or not it has expired; MongoDB only removes expired
+// links periodically, so callers must check ExpiresAt
+func (r *shareLinkRepository) GetByTokenHash(ctx context.Context, tokenHash string) (*models.GroupShareLink, error) {
+	var link models.GroupShareLink
+	err := r.collection.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&link)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrShareLinkNotFound
+		}
+		return nil, err
+	}
+	return &link, nil
+}
+
+// ListActiveByGroupID returns the group's unexpired links, newest first
+func (r *shareLinkRepository) ListActiveByGroupID(ctx context.Context, groupID string, now time.Time) ([]*models.GroupShareLink, error) {
+	filter := bson.M{"group_id": groupID, "expires_at": bson.M{"$gt": now}}
+	cursor, err := r.collection.Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	links := []*models.GroupShareLink{}
+	if err := cursor.All(ctx, &links); err != nil {
+		return nil, err
+	}
+	return links, nil
+}
+
+// Delete revokes a link; deleted links stop working immediately
+func (r *shareLinkRepository) Delete(ctx context.Context, groupID string, linkID string) error {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"group_id": groupID, "link_id": linkID})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount == 0 {
+		return ErrShareLinkNotFound
+	}
+	return nil
+}
+
+// EnsureIndexes creates the indexes behind token lookups, listing a group's links and removing
+// expired ones
+func (r *shareLinkRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, shareLinkIndexes())
+	return err
+}
+
+func shareLinkIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "token_hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "group_id", Value: 1}, {Key: "created_at", Value: -1}}},
+		{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+	}
+}
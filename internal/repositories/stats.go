@@ -0,0 +1,143 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"divvydoo/backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// StatsRepository aggregates counts across collections for the operational dashboard
+type StatsRepository interface {
+	CountUsers(ctx context.Context) (int64, error)
+	NewUsersByDay(ctx context.Context, since time.Time) (map[string]int64, error)
+	ExpensesByDay(ctx context.Context, since time.Time) (map[string]int64, error)
+	SettlementsByStatus(ctx context.Context, since time.Time) (map[models.SettlementStatus]int64, error)
+	GroupSizes(ctx context.Context) (groups int64, averageSize float64, err error)
+	CountFailedJobs(ctx context.Context, since time.Time) (int64, error)
+}
+
+type statsRepository struct {
+	db *mongo.Database
+}
+
+func NewStatsRepository(db *mongo.Database) StatsRepository {
+	return &statsRepository{db: db}
+}
+
+// CountUsers counts registered accounts, leaving out imported placeholders and deleted accounts
+func (r *statsRepository) CountUsers(ctx context.Context) (int64, error) {
+	return r.db.Collection("users").CountDocuments(ctx, bson.M{
+		"placeholder": bson.M{"$ne": true},
+		"deleted_at":  bson.M{"$exists": false},
+	})
+}
+
+func (r *statsRepository) NewUsersByDay(ctx context.Context, since time.Time) (map[string]int64, error) {
+	return r.countByDay(ctx, "users", bson.M{"placeholder": bson.M{"$ne": true}}, since)
+}
+
+func (r *statsRepository) ExpensesByDay(ctx context.Context, since time.Time) (map[string]int64, error) {
+	return r.countByDay(ctx, "expenses", bson.M{"is_deleted": false}, since)
+}
+
+// countByDay counts the collection's documents matching filter per UTC day of created_at
+func (r *statsRepository) countByDay(ctx context.Context, collection string, filter bson.M, since time.Time) (map[string]int64, error) {
+	match := bson.M{"created_at": bson.M{"$gte": since}}
+	for key, value := range filter {
+		match[key] = value
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"$dateToString": bson.M{"format": "%Y-%m-%d", "date": "$created_at"}},
+			"count": bson.M{"$sum": 1},
+		}}},
+	}
+
+	var rows []struct {
+		Day   string `bson:"_id"`
+		Count int64  `bson:"count"`
+	}
+	if err := r.aggregate(ctx, collection, pipeline, &rows); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Day] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *statsRepository) SettlementsByStatus(ctx context.Context, since time.Time) (map[models.SettlementStatus]int64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"created_at": bson.M{"$gte": since}}}},
+		{{Key: "$group", Value: bson.M{"_id": "$status", "count": bson.M{"$sum": 1}}}},
+	}
+
+	var rows []struct {
+		Status models.SettlementStatus `bson:"_id"`
+		Count  int64                   `bson:"count"`
+	}
+	if err := r.aggregate(ctx, "settlements", pipeline, &rows); err != nil {
+		return nil, err
+	}
+
+	counts := make(map[models.SettlementStatus]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// GroupSizes returns the number of active groups and their average number of active members
+func (r *statsRepository) GroupSizes(ctx context.Context) (int64, float64, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"is_active": true}}},
+		{{Key: "$project", Value: bson.M{
+			"size": bson.M{"$size": bson.M{"$filter": bson.M{
+				"input": bson.M{"$ifNull": bson.A{"$members", bson.A{}}},
+				"as":    "member",
+				"cond":  "$$member.is_active",
+			}}},
+		}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     nil,
+			"groups":  bson.M{"$sum": 1},
+			"average": bson.M{"$avg": "$size"},
+		}}},
+	}
+
+	var rows []struct {
+		Groups  int64   `bson:"groups"`
+		Average float64 `bson:"average"`
+	}
+	if err := r.aggregate(ctx, "groups", pipeline, &rows); err != nil {
+		return 0, 0, err
+	}
+	if len(rows) == 0 {
+		return 0, 0, nil
+	}
+	return rows[0].Groups, rows[0].Average, nil
+}
+
+func (r *statsRepository) CountFailedJobs(ctx context.Context, since time.Time) (int64, error) {
+	return r.db.Collection("jobs").CountDocuments(ctx, bson.M{
+		"status":     models.JobFailed,
+		"created_at": bson.M{"$gte": since},
+	})
+}
+
+func (r *statsRepository) aggregate(ctx context.Context, collection string, pipeline mongo.Pipeline, results interface{}) error {
+	cursor, err := r.db.Collection(collection).Aggregate(ctx, pipeline)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+	return cursor.All(ctx, results)
+}
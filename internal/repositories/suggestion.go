@@ -0,0 +1,74 @@
+package repositories
+
+import (
+	"context"
+	"time"
+
+	"divvydoo/backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+type SuggestionRepository interface {
+	Upsert(ctx context.Context, suggestion *models.GroupSuggestion) error
+	DeleteComputedBefore(ctx context.Context, before time.Time) (int64, error)
+	GetByUserID(ctx context.Context, userID string) ([]*models.GroupSuggestion, error)
+	EnsureIndexes(ctx context.Context) error
+}
+
+type suggestionRepository struct {
+	collection *mongo.Collection
+}
+
+func NewSuggestionRepository(db *mongo.Database) SuggestionRepository {
+	return &suggestionRepository{
+		collection: db.Collection("group_suggestions"),
+	}
+}
+
+// Upsert stores the suggestion under its ID, replacing the previous computation of the same one
+func (r *suggestionRepository) Upsert(ctx context.Context, suggestion *models.GroupSuggestion) error {
+	filter := bson.M{"suggestion_id": suggestion.SuggestionID}
+	_, err := r.collection.ReplaceOne(ctx, filter, suggestion, options.Replace().SetUpsert(true))
+	return err
+}
+
+// DeleteComputedBefore drops the suggestions a newer computation no longer produced
+func (r *suggestionRepository) DeleteComputedBefore(ctx context.Context, before time.Time) (int64, error) {
+	result, err := r.collection.DeleteMany(ctx, bson.M{"computed_at": bson.M{"$lt": before}})
+	if err != nil {
+		return 0, err
+	}
+	return result.DeletedCount, nil
+}
+
+// GetByUserID returns the user's suggestions, strongest first
+func (r *suggestionRepository) GetByUserID(ctx context.Context, userID string) ([]*models.GroupSuggestion, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "expense_count", Value: -1}, {Key: "last_expense_at", Value: -1}})
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var suggestions []*models.GroupSuggestion
+	if err := cursor.All(ctx, &suggestions); err != nil {
+		return nil, err
+	}
+	return suggestions, nil
+}
+
+// EnsureIndexes creates the indexes behind upserting suggestions and listing a user's
+func (r *suggestionRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, suggestionIndexes())
+	return err
+}
+
+func suggestionIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "suggestion_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "expense_count", Value: -1}}},
+	}
+}
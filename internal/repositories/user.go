@@ -3,9 +3,12 @@ package repositories
 import (
 	"context"
 	"errors"
+	"net/http"
+	"regexp"
 	"time"
 
 	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/utils"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,8 +17,8 @@ import (
 )
 
 var (
-	ErrUserNotFound      = errors.New("user not found")
-	ErrUserAlreadyExists = errors.New("user with this email already exists")
+	ErrUserNotFound      = utils.NewCustomError(http.StatusNotFound, utils.CodeUserNotFound, "user not found")
+	ErrUserAlreadyExists = utils.NewCustomError(http.StatusConflict, utils.CodeUserAlreadyExists, "user with this email already exists")
 )
 
 type UserRepository interface {
@@ -24,10 +27,28 @@ type UserRepository interface {
 	GetByEmail(ctx context.Context, email string) (*models.User, error)
 	GetByPhone(ctx context.Context, phone string) (*models.User, error)
 	GetByIDs(ctx context.Context, userIDs []string) ([]*models.User, error)
+	GetPlaceholdersByClaimEmail(ctx context.Context, email string) ([]*models.User, error)
+	// SetClaimToken stores the hash of a new claim token on every placeholder imported under
+	// email, replacing any earlier one, and returns how many there are
+	SetClaimToken(ctx context.Context, email string, tokenHash string, expiresAt time.Time) (int64, error)
+	// GetPlaceholdersByClaimToken returns the placeholders the token was issued for, whether or
+	// not it has expired
+	GetPlaceholdersByClaimToken(ctx context.Context, tokenHash string) ([]*models.User, error)
 	Update(ctx context.Context, user *models.User) (*models.User, error)
 	Delete(ctx context.Context, userID string) error
+	Anonymize(ctx context.Context, userID string, deletedAt time.Time) error
+	// Search finds users whose user ID is query, or whose name or email starts with it, ignoring case
+	Search(ctx context.Context, query string, limit, offset int64) ([]*models.User, error)
+	// SetDisabled disables the account at disabledAt, or enables it again when disabledAt is nil
+	SetDisabled(ctx context.Context, userID string, disabledAt *time.Time, reason string) (*models.User, error)
+	SetRole(ctx context.Context, userID string, role models.UserRole) (*models.User, error)
+	SetAvatar(ctx context.Context, userID string, url string, avatar *models.AvatarImage) (*models.User, error)
+	// BumpSettlementVersion writes to the user within a transaction that creates a settlement
+	// they pay outside any group, so it conflicts with another such transaction running at once
+	BumpSettlementVersion(ctx context.Context, userID string) error
 	Exists(ctx context.Context, userID string) (bool, error)
 	ExistMultiple(ctx context.Context, userIDs []string) ([]string, error) // Returns missing user IDs
+	EnsureIndexes(ctx context.Context) error
 }
 
 type userRepository struct {
@@ -118,16 +139,67 @@ func (r *userRepository) GetByIDs(ctx context.Context, userIDs []string) ([]*mod
 	return users, nil
 }
 
+func (r *userRepository) GetPlaceholdersByClaimEmail(ctx context.Context, email string) ([]*models.User, error) {
+	filter := bson.M{"claim_email": email, "placeholder": true}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []*models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+func (r *userRepository) SetClaimToken(ctx context.Context, email string, tokenHash string, expiresAt time.Time) (int64, error) {
+	filter := bson.M{"claim_email": email, "placeholder": true}
+	update := bson.M{
+		"$set": bson.M{
+			"claim_token_hash":       tokenHash,
+			"claim_token_expires_at": expiresAt,
+		},
+	}
+
+	result, err := r.collection.UpdateMany(ctx, filter, update)
+	if err != nil {
+		return 0, err
+	}
+	return result.MatchedCount, nil
+}
+
+func (r *userRepository) GetPlaceholdersByClaimToken(ctx context.Context, tokenHash string) ([]*models.User, error) {
+	filter := bson.M{"claim_token_hash": tokenHash, "placeholder": true}
+
+	cursor, err := r.collection.Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var users []*models.User
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
 func (r *userRepository) Update(ctx context.Context, user *models.User) (*models.User, error) {
 	user.UpdatedAt = time.Now()
 
 	filter := bson.M{"user_id": user.UserID}
 	update := bson.M{
 		"$set": bson.M{
-			"name":       user.Name,
-			"email":      user.Email,
-			"phone":      user.Phone,
-			"updated_at": user.UpdatedAt,
+			"name":        user.Name,
+			"email":       user.Email,
+			"phone":       user.Phone,
+			"preferences": user.Preferences,
+			"updated_at":  user.UpdatedAt,
 		},
 	}
 
@@ -160,6 +232,102 @@ func (r *userRepository) Delete(ctx context.Context, userID string) error {
 	return nil
 }
 
+// Anonymize removes the user's personal data but keeps the document, so the expenses, settlements
+// and balances that reference the user ID still add up. The email is replaced rather than cleared
+// to keep it unique.
+func (r *userRepository) Anonymize(ctx context.Context, userID string, deletedAt time.Time) error {
+	filter := bson.M{"user_id": userID}
+	update := bson.M{
+		"$set": bson.M{
+			"name":       "Deleted user",
+			"email":      "deleted-" + userID + "@deleted.invalid",
+			"deleted_at": deletedAt,
+			"updated_at": deletedAt,
+		},
+		"$unset": bson.M{
+			"phone":       "",
+			"password":    "",
+			"preferences": "",
+			"avatar_url":  "",
+			"avatar":      "",
+		},
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+func (r *userRepository) Search(ctx context.Context, query string, limit, offset int64) ([]*models.User, error) {
+	// Anchored, so the name and email indexes narrow the scan
+	prefix := primitive.Regex{Pattern: "^" + regexp.QuoteMeta(query), Options: "i"}
+	filter := bson.M{"$or": []bson.M{
+		{"user_id": query},
+		{"name": prefix},
+		{"email": prefix},
+	}}
+
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetLimit(limit).
+		SetSkip(offset)
+	cursor, err := r.collection.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	users := []*models.User{}
+	if err := cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepository) SetDisabled(ctx context.Context, userID string, disabledAt *time.Time, reason string) (*models.User, error) {
+	update := bson.M{"$set": bson.M{"disabled_at": disabledAt, "disabled_reason": reason, "updated_at": time.Now()}}
+	if disabledAt == nil {
+		update = bson.M{
+			"$set":   bson.M{"updated_at": time.Now()},
+			"$unset": bson.M{"disabled_at": "", "disabled_reason": ""},
+		}
+	}
+	return r.findOneAndUpdate(ctx, userID, update)
+}
+
+func (r *userRepository) SetRole(ctx context.Context, userID string, role models.UserRole) (*models.User, error) {
+	update := bson.M{"$set": bson.M{"role": role, "updated_at": time.Now()}}
+	if role == "" || role == models.RoleMember {
+		update = bson.M{"$set": bson.M{"updated_at": time.Now()}, "$unset": bson.M{"role": ""}}
+	}
+	return r.findOneAndUpdate(ctx, userID, update)
+}
+
+func (r *userRepository) findOneAndUpdate(ctx context.Context, userID string, update bson.M) (*models.User, error) {
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var user models.User
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"user_id": userID}, update, opts).Decode(&user)
+	if err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// SetAvatar stores the user's avatar; a nil avatar removes it
+func (r *userRepository) SetAvatar(ctx context.Context, userID string, url string, avatar *models.AvatarImage) (*models.User, error) {
+	return setAvatar[models.User](ctx, r.collection, bson.M{"user_id": userID}, url, avatar, ErrUserNotFound)
+}
+
 func (r *userRepository) Exists(ctx context.Context, userID string) (bool, error) {
 	filter := bson.M{"user_id": userID}
 	count, err := r.collection.CountDocuments(ctx, filter, options.Count().SetLimit(1))
@@ -169,6 +337,22 @@ func (r *userRepository) Exists(ctx context.Context, userID string) (bool, error
 	return count > 0, nil
 }
 
+func (r *userRepository) BumpSettlementVersion(ctx context.Context, userID string) error {
+	filter := bson.M{"user_id": userID}
+	update := bson.M{"$inc": bson.M{"settlement_version": 1}}
+
+	result, err := r.collection.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return err
+	}
+
+	if result.MatchedCount == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
 // ExistMultiple checks if all provided user IDs exist and returns the IDs that don't exist
 func (r *userRepository) ExistMultiple(ctx context.Context, userIDs []string) ([]string, error) {
 	if len(userIDs) == 0 {
@@ -205,3 +389,21 @@ func (r *userRepository) ExistMultiple(ctx context.Context, userIDs []string) ([
 
 	return missingIDs, nil
 }
+
+// EnsureIndexes creates the unique indexes that Create relies on to report duplicate users
+func (r *userRepository) EnsureIndexes(ctx context.Context) error {
+	_, err := r.collection.Indexes().CreateMany(ctx, userIndexes())
+	return err
+}
+
+func userIndexes() []mongo.IndexModel {
+	return []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true)},
+		{Keys: bson.D{{Key: "phone", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "claim_email", Value: 1}}, Options: options.Index().SetSparse(true)},
+		{Keys: bson.D{{Key: "claim_token_hash", Value: 1}}, Options: options.Index().SetSparse(true)},
+		// Back-office user search
+		{Keys: bson.D{{Key: "name", Value: 1}}},
+	}
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"context"
+	"sort"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+)
+
+type ActivityService struct {
+	expenseRepo    repositories.ExpenseRepository
+	settlementRepo repositories.SettlementRepository
+	groupRepo      repositories.GroupRepository
+}
+
+func NewActivityService(
+	expenseRepo repositories.ExpenseRepository,
+	settlementRepo repositories.SettlementRepository,
+	groupRepo repositories.GroupRepository,
+) *ActivityService {
+	return &ActivityService{
+		expenseRepo:    expenseRepo,
+		settlementRepo: settlementRepo,
+		groupRepo:      groupRepo,
+	}
+}
+
+// ListUserActivity returns a page of everything affecting the user, newest first: expenses they
+// created, paid or share in, settlements they pay or receive, and groups they joined. Each source
+// is read from the cursor position and the results are merged, so the cursor works across all three.
+func (s *ActivityService) ListUserActivity(ctx context.Context, userID string, cursor string, limit int64) ([]*models.Activity, string, error) {
+	position, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	groups, err := s.groupRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	groupNames := make(map[string]string, len(groups))
+	for _, group := range groups {
+		groupNames[group.GroupID] = group.Name
+	}
+
+	expenses, expensesNext, err := s.expenseRepo.ListByUserID(ctx, userID, position, limit, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	settlements, settlementsNext, err := s.settlementRepo.ListByUserID(ctx, userID, nil, position, limit, 0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	activities := make([]*models.Activity, 0, len(expenses)+len(settlements))
+	for _, expense := range expenses {
+		activities = append(activities, &models.Activity{
+			ID:         expense.ID,
+			Type:       models.ActivityExpense,
+			OccurredAt: expense.CreatedAt,
+			GroupID:    expense.GroupID,
+			Expense:    expense,
+		})
+	}
+	for _, settlement := range settlements {
+		activities = append(activities, &models.Activity{
+			ID:         settlement.ID,
+			Type:       models.ActivitySettlement,
+			OccurredAt: settlement.CreatedAt,
+			GroupID:    settlement.GroupID,
+			Settlement: settlement,
+		})
+	}
+	for _, group := range groups {
+		for i := range group.Members {
+			member := &group.Members[i]
+			if member.UserID != userID {
+				continue
+			}
+			activity := &models.Activity{
+				ID:         group.ID,
+				Type:       models.ActivityGroupJoined,
+				OccurredAt: member.JoinedAt,
+				GroupID:    &group.GroupID,
+				Group:      member,
+			}
+			if position == nil || activityBefore(activity, position) {
+				activities = append(activities, activity)
+			}
+		}
+	}
+
+	sort.Slice(activities, func(i, j int) bool {
+		return activityBefore(activities[j], &repositories.Cursor{CreatedAt: activities[i].OccurredAt, ID: activities[i].ID})
+	})
+
+	for _, activity := range activities {
+		if activity.GroupID != nil {
+			activity.GroupName = groupNames[*activity.GroupID]
+		}
+	}
+
+	hasMore := expensesNext != "" || settlementsNext != "" || int64(len(activities)) > limit
+	if int64(len(activities)) > limit {
+		activities = activities[:limit]
+	}
+
+	nextCursor := ""
+	if hasMore && len(activities) > 0 {
+		last := activities[len(activities)-1]
+		nextCursor = repositories.Cursor{CreatedAt: last.OccurredAt, ID: last.ID}.Encode()
+	}
+
+	return activities, nextCursor, nil
+}
+
+// activityBefore reports whether the activity comes after the cursor position in feed order
+// (occurred_at descending, then ID descending), matching how the repositories page.
+func activityBefore(activity *models.Activity, position *repositories.Cursor) bool {
+	occurred := activity.OccurredAt.Truncate(0)
+	if !occurred.Equal(position.CreatedAt) {
+		return occurred.Before(position.CreatedAt)
+	}
+	return activity.ID.Hex() < position.ID.Hex()
+}
@@ -0,0 +1,98 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/pkg/cache"
+)
+
+// AggregateCache keeps the results of the aggregations behind balance summaries and member
+// lists in Redis, shared by every replica. The services that move balances or change
+// memberships evict them once their change commits; the TTL bounds what they don't cover, such
+// as a member changing their name. Redis errors are logged and the aggregation runs as if
+// nothing was cached. A nil AggregateCache caches nothing.
+type AggregateCache struct {
+	cache *cache.Redis
+}
+
+func NewAggregateCache(cache *cache.Redis) *AggregateCache {
+	return &AggregateCache{cache: cache}
+}
+
+// UserBalanceSummary returns the user's cached summary, or loads and caches it
+func (c *AggregateCache) UserBalanceSummary(ctx context.Context, userID string, load func(ctx context.Context) (*models.UserBalanceSummary, error)) (*models.UserBalanceSummary, error) {
+	return cachedAggregate(ctx, c, balanceSummaryKey(userID), load)
+}
+
+// GroupMembers returns the group's cached active members with their details, or loads and
+// caches them
+func (c *AggregateCache) GroupMembers(ctx context.Context, groupID string, load func(ctx context.Context) ([]repositories.MemberWithUser, error)) ([]repositories.MemberWithUser, error) {
+	return cachedAggregate(ctx, c, groupMembersKey(groupID), load)
+}
+
+// EvictBalances drops the balance summaries of the users whose balances moved
+func (c *AggregateCache) EvictBalances(ctx context.Context, userIDs ...string) {
+	if c == nil || len(userIDs) == 0 {
+		return
+	}
+	seen := make(map[string]bool, len(userIDs))
+	keys := make([]string, 0, len(userIDs))
+	for _, userID := range userIDs {
+		if !seen[userID] {
+			seen[userID] = true
+			keys = append(keys, balanceSummaryKey(userID))
+		}
+	}
+	c.evict(ctx, keys)
+}
+
+// EvictGroupMembers drops the group's member list
+func (c *AggregateCache) EvictGroupMembers(ctx context.Context, groupID string) {
+	if c == nil {
+		return
+	}
+	c.evict(ctx, []string{groupMembersKey(groupID)})
+}
+
+func (c *AggregateCache) evict(ctx context.Context, keys []string) {
+	// The change has been written by now, so the eviction must not be cancelled with the request
+	if err := c.cache.Evict(context.WithoutCancel(ctx), keys...); err != nil {
+		log.Printf("Failed to evict cached %s: %v", strings.Join(keys, ", "), err)
+	}
+}
+
+func cachedAggregate[V any](ctx context.Context, c *AggregateCache, key string, load func(ctx context.Context) (V, error)) (V, error) {
+	if c == nil {
+		return load(ctx)
+	}
+
+	var value V
+	found, err := c.cache.Get(ctx, key, &value)
+	if err != nil {
+		log.Printf("Failed to read cached %s: %v", key, err)
+	} else if found {
+		return value, nil
+	}
+
+	version, versionErr := c.cache.Version(ctx, key)
+	value, err = load(ctx)
+	if err != nil || versionErr != nil {
+		return value, err
+	}
+	if err := c.cache.SetAt(ctx, key, value, version); err != nil {
+		log.Printf("Failed to cache %s: %v", key, err)
+	}
+	return value, nil
+}
+
+func balanceSummaryKey(userID string) string {
+	return "balance_summary/" + userID
+}
+
+func groupMembersKey(groupID string) string {
+	return "group_members/" + groupID
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"divvydoo/backend/internal/metrics"
+)
+
+const (
+	// aggregationRunTimeout bounds a run that carries on after its request was answered from cache
+	aggregationRunTimeout = 2 * time.Minute
+	// maxAggregationResults bounds how many results each fallback cache keeps; the oldest go first
+	maxAggregationResults = 1000
+)
+
+// AggregationBudget returns how long a live aggregation may take before the last result is served
+// instead; zero or less waits for the live result however long it takes
+type AggregationBudget func() time.Duration
+
+// aggregationFallback keeps the responses of a heavy aggregation responsive for huge groups. Each
+// key is computed by at most one run at a time; a request that outlasts the time budget gets the
+// last stored result for its key, marked stale, while the run finishes in the background and
+// stores a fresh result for the next request. Without a stored result the request keeps waiting.
+type aggregationFallback[T any] struct {
+	name   string // metrics label
+	budget AggregationBudget
+
+	mu      sync.Mutex
+	results map[string]aggregationResult[T]
+	running map[string]*aggregationRun[T]
+}
+
+type aggregationResult[T any] struct {
+	value    T
+	storedAt time.Time
+}
+
+type aggregationRun[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+}
+
+func newAggregationFallback[T any](name string, budget AggregationBudget) *aggregationFallback[T] {
+	return &aggregationFallback[T]{
+		name:    name,
+		budget:  budget,
+		results: make(map[string]aggregationResult[T]),
+		running: make(map[string]*aggregationRun[T]),
+	}
+}
+
+// get returns a live result of compute for key, or the last stored one and stale true when the
+// live run exceeds the time budget
+func (f *aggregationFallback[T]) get(ctx context.Context, key string, compute func(context.Context) (T, error)) (T, bool, error) {
+	var zero T
+	run := f.start(ctx, key, compute)
+
+	if budget := f.currentBudget(); budget > 0 {
+		timer := time.NewTimer(budget)
+		defer timer.Stop()
+
+		select {
+		case <-run.done:
+			return run.value, false, run.err
+		case <-ctx.Done():
+			return zero, false, ctx.Err()
+		case <-timer.C:
+			if last, ok := f.last(key); ok {
+				metrics.ObserveAggregationFallback(f.name)
+				return last, true, nil
+			}
+		}
+	}
+
+	select {
+	case <-run.done:
+		return run.value, false, run.err
+	case <-ctx.Done():
+		return zero, false, ctx.Err()
+	}
+}
+
+// start joins the run under way for key or starts one. Runs are detached from the request, so a
+// request answered from cache or abandoned by its client still refreshes the stored result.
+func (f *aggregationFallback[T]) start(ctx context.Context, key string, compute func(context.Context) (T, error)) *aggregationRun[T] {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if run, ok := f.running[key]; ok {
+		return run
+	}
+	run := &aggregationRun[T]{done: make(chan struct{})}
+	f.running[key] = run
+
+	runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), aggregationRunTimeout)
+	go func() {
+		defer cancel()
+		value, err := compute(runCtx)
+
+		f.mu.Lock()
+		delete(f.running, key)
+		if err == nil {
+			f.store(key, value)
+		}
+		f.mu.Unlock()
+
+		run.value, run.err = value, err
+		close(run.done)
+	}()
+	return run
+}
+
+func (f *aggregationFallback[T]) last(key string) (T, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	result, ok := f.results[key]
+	return result.value, ok
+}
+
+// store keeps value for key, evicting the oldest result when full. Callers hold f.mu.
+func (f *aggregationFallback[T]) store(key string, value T) {
+	if _, ok := f.results[key]; !ok && len(f.results) >= maxAggregationResults {
+		var oldestKey string
+		var oldest time.Time
+		for k, result := range f.results {
+			if oldestKey == "" || result.storedAt.Before(oldest) {
+				oldestKey, oldest = k, result.storedAt
+			}
+		}
+		delete(f.results, oldestKey)
+	}
+	f.results[key] = aggregationResult[T]{value: value, storedAt: time.Now()}
+}
+
+func (f *aggregationFallback[T]) currentBudget() time.Duration {
+	if f.budget == nil {
+		return 0
+	}
+	return f.budget()
+}
@@ -0,0 +1,175 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+	"divvydoo/backend/pkg/auth"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAPIKeyNotFound      = utils.NewCustomError(http.StatusNotFound, utils.CodeAPIKeyNotFound, "API key not found")
+	ErrInvalidAPIKeyScopes = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidAPIKeyScopes, "scopes must list read and/or write")
+	ErrInvalidAPIKeyTTL    = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidAPIKeyTTL, fmt.Sprintf("expires_in_days must be between 0 and %d", maxAPIKeyDays))
+	ErrTooManyAPIKeys      = utils.NewCustomError(http.StatusConflict, utils.CodeTooManyAPIKeys, fmt.Sprintf("a user can have at most %d API keys", maxAPIKeysPerUser))
+)
+
+const (
+	// apiKeyPrefix marks API keys, so leaked ones are easy to recognize
+	apiKeyPrefix      = "ddk_"
+	apiKeySecretBytes = 32
+	// apiKeyDisplayLength is how much of the key is kept to tell keys apart
+	apiKeyDisplayLength = len(apiKeyPrefix) + 8
+	maxAPIKeyDays       = 365
+	maxAPIKeysPerUser   = 25
+	// apiKeyTouchInterval limits how often a key's last use is written
+	apiKeyTouchInterval = time.Minute
+)
+
+// CreateAPIKeyRequest names the key and sets what it may do; 0 expires_in_days never expires
+type CreateAPIKeyRequest struct {
+	Name          string               `json:"name" binding:"required,max=100"`
+	Scopes        []models.APIKeyScope `json:"scopes" binding:"required"`
+	ExpiresInDays int                  `json:"expires_in_days,omitempty"`
+}
+
+// APIKeyService issues the keys integrations use instead of a user's token, and checks them
+type APIKeyService struct {
+	apiKeyRepo repositories.APIKeyRepository
+	userRepo   repositories.UserRepository
+}
+
+func NewAPIKeyService(apiKeyRepo repositories.APIKeyRepository, userRepo repositories.UserRepository) *APIKeyService {
+	return &APIKeyService{
+		apiKeyRepo: apiKeyRepo,
+		userRepo:   userRepo,
+	}
+}
+
+// CreateAPIKey issues a key acting as the user. The returned key carries the secret, which
+// can't be retrieved again.
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, userID string, req CreateAPIKeyRequest) (*models.APIKey, error) {
+	if len(req.Scopes) == 0 {
+		return nil, ErrInvalidAPIKeyScopes
+	}
+	for _, scope := range req.Scopes {
+		if !scope.IsValid() {
+			return nil, ErrInvalidAPIKeyScopes
+		}
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxAPIKeyDays {
+		return nil, ErrInvalidAPIKeyTTL
+	}
+
+	existing, err := s.apiKeyRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(existing) >= maxAPIKeysPerUser {
+		return nil, ErrTooManyAPIKeys
+	}
+
+	raw := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate API key: %v", err)
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	key := &models.APIKey{
+		KeyID:      uuid.New().String(),
+		UserID:     userID,
+		Name:       req.Name,
+		Prefix:     secret[:apiKeyDisplayLength],
+		SecretHash: hashAPIKey(secret),
+		Scopes:     req.Scopes,
+		CreatedAt:  now,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+	if err := s.apiKeyRepo.Create(ctx, key); err != nil {
+		return nil, err
+	}
+
+	key.Key = secret
+	return key, nil
+}
+
+// ListAPIKeys returns the user's keys without their secrets
+func (s *APIKeyService) ListAPIKeys(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	return s.apiKeyRepo.ListByUserID(ctx, userID)
+}
+
+// RevokeAPIKey stops one of the user's keys from working
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, userID string, keyID string) error {
+	if err := s.apiKeyRepo.Delete(ctx, userID, keyID); err != nil {
+		if errors.Is(err, repositories.ErrAPIKeyNotFound) {
+			return ErrAPIKeyNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// AuthenticateAPIKey checks a key sent in X-API-Key and returns the key's ID, the user it acts
+// as and its scopes. Keys stop working once they expire or their user is disabled or deleted;
+// those and unknown keys get auth.ErrInvalidAPIKey.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, secret string) (string, string, []string, error) {
+	if !strings.HasPrefix(secret, apiKeyPrefix) {
+		return "", "", nil, auth.ErrInvalidAPIKey
+	}
+	key, err := s.apiKeyRepo.GetBySecretHash(ctx, hashAPIKey(secret))
+	if err != nil {
+		if errors.Is(err, repositories.ErrAPIKeyNotFound) {
+			return "", "", nil, auth.ErrInvalidAPIKey
+		}
+		return "", "", nil, err
+	}
+
+	now := time.Now()
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return "", "", nil, auth.ErrInvalidAPIKey
+	}
+
+	user, err := s.userRepo.GetByID(ctx, key.UserID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return "", "", nil, auth.ErrInvalidAPIKey
+		}
+		return "", "", nil, err
+	}
+	if user.DisabledAt != nil || user.DeletedAt != nil {
+		return "", "", nil, auth.ErrInvalidAPIKey
+	}
+
+	if err := s.apiKeyRepo.TouchLastUsed(ctx, key.KeyID, now, apiKeyTouchInterval); err != nil {
+		log.Printf("Failed to record use of API key %s: %v", key.KeyID, err)
+	}
+
+	scopes := make([]string, len(key.Scopes))
+	for i, scope := range key.Scopes {
+		scopes[i] = string(scope)
+	}
+	return key.KeyID, key.UserID, scopes, nil
+}
+
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
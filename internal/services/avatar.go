@@ -0,0 +1,375 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/jpeg"
+	"log"
+	"net/http"
+
+	// Decoders for the accepted upload formats
+	_ "image/gif"
+	_ "image/png"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+	"divvydoo/backend/pkg/storage"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// AvatarSize is the width and height of served avatars in pixels
+	AvatarSize = 256
+	// MaxAvatarUploadBytes caps the size of an uploaded image
+	MaxAvatarUploadBytes = 5 << 20
+	// maxAvatarSourceSide caps the width and height of an uploaded image, so a small file can't
+	// decode into a huge bitmap
+	maxAvatarSourceSide = 4096
+	minAvatarCropSize   = 16
+)
+
+var (
+	ErrInvalidAvatar     = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidAvatar, "avatar must be a JPEG, PNG or GIF image")
+	ErrInvalidAvatarCrop = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidAvatarCrop, "invalid avatar crop")
+	ErrNoAvatar          = utils.NewCustomError(http.StatusNotFound, utils.CodeNoAvatar, "no avatar uploaded")
+)
+
+// avatarSourceTypes maps the accepted upload content types to the extension the original is stored under
+var avatarSourceTypes = map[string]string{
+	"image/jpeg": "jpg",
+	"image/png":  "png",
+	"image/gif":  "gif",
+}
+
+// AvatarService stores user and group avatars. Uploads are cropped to a square, scaled to
+// AvatarSize and served as JPEG; the original is kept so it can be cropped again later. Every
+// change gets a new file, so clients can cache avatar URLs forever.
+type AvatarService struct {
+	userRepo  repositories.UserRepository
+	groupRepo repositories.GroupRepository
+	store     storage.Store
+}
+
+func NewAvatarService(userRepo repositories.UserRepository, groupRepo repositories.GroupRepository, store storage.Store) *AvatarService {
+	return &AvatarService{
+		userRepo:  userRepo,
+		groupRepo: groupRepo,
+		store:     store,
+	}
+}
+
+// SetUserAvatar replaces the user's avatar with the uploaded image. Without a crop the largest
+// centered square is used.
+func (s *AvatarService) SetUserAvatar(ctx context.Context, userID string, data []byte, crop *models.AvatarCrop) (*models.User, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	url, avatar, err := s.upload(ctx, userAvatarPrefix(userID), data, crop)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.userRepo.SetAvatar(ctx, userID, url, avatar)
+	if err != nil {
+		s.removeFiles(ctx, avatar, nil)
+		return nil, err
+	}
+	s.removeFiles(ctx, user.Avatar, avatar)
+	return updated, nil
+}
+
+// CropUserAvatar re-crops the user's current avatar from its original upload
+func (s *AvatarService) CropUserAvatar(ctx context.Context, userID string, crop models.AvatarCrop) (*models.User, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	url, avatar, err := s.recrop(ctx, userAvatarPrefix(userID), user.Avatar, crop)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.userRepo.SetAvatar(ctx, userID, url, avatar)
+	if err != nil {
+		s.removeFiles(ctx, avatar, user.Avatar)
+		return nil, err
+	}
+	s.removeFiles(ctx, user.Avatar, avatar)
+	return updated, nil
+}
+
+func (s *AvatarService) DeleteUserAvatar(ctx context.Context, userID string) (*models.User, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.userRepo.SetAvatar(ctx, userID, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	s.removeFiles(ctx, user.Avatar, nil)
+	return updated, nil
+}
+
+// SetGroupAvatar replaces the group's avatar; only group admins may change it
+func (s *AvatarService) SetGroupAvatar(ctx context.Context, groupID string, adminUserID string, data []byte, crop *models.AvatarCrop) (*models.Group, error) {
+	group, err := s.getAdminGroup(ctx, groupID, adminUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	url, avatar, err := s.upload(ctx, groupAvatarPrefix(groupID), data, crop)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.groupRepo.SetAvatar(ctx, groupID, url, avatar)
+	if err != nil {
+		s.removeFiles(ctx, avatar, nil)
+		return nil, err
+	}
+	s.removeFiles(ctx, group.Avatar, avatar)
+	return updated, nil
+}
+
+func (s *AvatarService) CropGroupAvatar(ctx context.Context, groupID string, adminUserID string, crop models.AvatarCrop) (*models.Group, error) {
+	group, err := s.getAdminGroup(ctx, groupID, adminUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	url, avatar, err := s.recrop(ctx, groupAvatarPrefix(groupID), group.Avatar, crop)
+	if err != nil {
+		return nil, err
+	}
+	updated, err := s.groupRepo.SetAvatar(ctx, groupID, url, avatar)
+	if err != nil {
+		s.removeFiles(ctx, avatar, group.Avatar)
+		return nil, err
+	}
+	s.removeFiles(ctx, group.Avatar, avatar)
+	return updated, nil
+}
+
+func (s *AvatarService) DeleteGroupAvatar(ctx context.Context, groupID string, adminUserID string) (*models.Group, error) {
+	group, err := s.getAdminGroup(ctx, groupID, adminUserID)
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := s.groupRepo.SetAvatar(ctx, groupID, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	s.removeFiles(ctx, group.Avatar, nil)
+	return updated, nil
+}
+
+func (s *AvatarService) getUser(ctx context.Context, userID string) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+func (s *AvatarService) getAdminGroup(ctx context.Context, groupID string, userID string) (*models.Group, error) {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	member := activeMember(group, userID)
+	if member == nil || member.Role != models.RoleAdmin {
+		return nil, ErrNotGroupAdmin
+	}
+	return group, nil
+}
+
+// upload stores the original image and its cropped avatar under prefix
+func (s *AvatarService) upload(ctx context.Context, prefix string, data []byte, crop *models.AvatarCrop) (string, *models.AvatarImage, error) {
+	if len(data) > MaxAvatarUploadBytes {
+		return "", nil, fmt.Errorf("%w: image is larger than %d bytes", ErrInvalidAvatar, MaxAvatarUploadBytes)
+	}
+	contentType := http.DetectContentType(data)
+	ext, ok := avatarSourceTypes[contentType]
+	if !ok {
+		return "", nil, ErrInvalidAvatar
+	}
+
+	source, err := decodeAvatarSource(data)
+	if err != nil {
+		return "", nil, err
+	}
+	if crop == nil {
+		crop = centeredAvatarCrop(source.Bounds())
+	}
+
+	avatar := &models.AvatarImage{
+		SourceKey:  fmt.Sprintf("%s/%s-source.%s", prefix, uuid.New().String(), ext),
+		SourceType: contentType,
+	}
+	if _, err := s.store.Put(ctx, avatar.SourceKey, contentType, data); err != nil {
+		return "", nil, err
+	}
+
+	url, err := s.render(ctx, prefix, source, *crop, avatar)
+	if err != nil {
+		s.store.Delete(ctx, avatar.SourceKey)
+		return "", nil, err
+	}
+	return url, avatar, nil
+}
+
+// recrop renders a new avatar from the original upload of current, which keeps its source
+func (s *AvatarService) recrop(ctx context.Context, prefix string, current *models.AvatarImage, crop models.AvatarCrop) (string, *models.AvatarImage, error) {
+	if current == nil {
+		return "", nil, ErrNoAvatar
+	}
+
+	data, err := s.store.Get(ctx, current.SourceKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return "", nil, ErrNoAvatar
+		}
+		return "", nil, err
+	}
+	source, err := decodeAvatarSource(data)
+	if err != nil {
+		return "", nil, err
+	}
+
+	avatar := &models.AvatarImage{SourceKey: current.SourceKey, SourceType: current.SourceType}
+	url, err := s.render(ctx, prefix, source, crop, avatar)
+	if err != nil {
+		return "", nil, err
+	}
+	return url, avatar, nil
+}
+
+// render crops and scales source, stores the result and records it on avatar
+func (s *AvatarService) render(ctx context.Context, prefix string, source image.Image, crop models.AvatarCrop, avatar *models.AvatarImage) (string, error) {
+	bounds := source.Bounds()
+	if crop.Size < minAvatarCropSize || crop.X < 0 || crop.Y < 0 ||
+		crop.X+crop.Size > bounds.Dx() || crop.Y+crop.Size > bounds.Dy() {
+		return "", fmt.Errorf("%w: the square must be at least %d pixels and lie within the %dx%d image",
+			ErrInvalidAvatarCrop, minAvatarCropSize, bounds.Dx(), bounds.Dy())
+	}
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, cropAndScale(source, crop, AvatarSize), &jpeg.Options{Quality: 85}); err != nil {
+		return "", err
+	}
+
+	avatar.Key = fmt.Sprintf("%s/%s.jpg", prefix, uuid.New().String())
+	avatar.Crop = crop
+	return s.store.Put(ctx, avatar.Key, "image/jpeg", buf.Bytes())
+}
+
+// removeFiles deletes the files of old that replacement no longer uses. Failures only leave
+// orphaned files behind, so they are logged rather than returned.
+func (s *AvatarService) removeFiles(ctx context.Context, old *models.AvatarImage, replacement *models.AvatarImage) {
+	if old == nil {
+		return
+	}
+	keys := []string{old.Key}
+	if replacement == nil || replacement.SourceKey != old.SourceKey {
+		keys = append(keys, old.SourceKey)
+	}
+	for _, key := range keys {
+		if replacement != nil && key == replacement.Key {
+			continue
+		}
+		if err := s.store.Delete(ctx, key); err != nil {
+			log.Printf("Failed to delete avatar file %s: %v", key, err)
+		}
+	}
+}
+
+func userAvatarPrefix(userID string) string {
+	return "avatars/users/" + userID
+}
+
+func groupAvatarPrefix(groupID string) string {
+	return "avatars/groups/" + groupID
+}
+
+func decodeAvatarSource(data []byte) (image.Image, error) {
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidAvatar
+	}
+	if config.Width > maxAvatarSourceSide || config.Height > maxAvatarSourceSide {
+		return nil, fmt.Errorf("%w: image can be at most %dx%d pixels", ErrInvalidAvatar, maxAvatarSourceSide, maxAvatarSourceSide)
+	}
+
+	source, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidAvatar
+	}
+	return source, nil
+}
+
+// centeredAvatarCrop is the largest square in the middle of the image
+func centeredAvatarCrop(bounds image.Rectangle) *models.AvatarCrop {
+	size := min(bounds.Dx(), bounds.Dy())
+	return &models.AvatarCrop{
+		X:    (bounds.Dx() - size) / 2,
+		Y:    (bounds.Dy() - size) / 2,
+		Size: size,
+	}
+}
+
+// cropAndScale cuts the crop square out of source, flattens it onto white and resizes it to
+// size x size, averaging the source pixels that fall into each target pixel
+func cropAndScale(source image.Image, crop models.AvatarCrop, size int) *image.RGBA {
+	origin := source.Bounds().Min
+	square := image.NewRGBA(image.Rect(0, 0, crop.Size, crop.Size))
+	draw.Draw(square, square.Bounds(), image.White, image.Point{}, draw.Src)
+	draw.Draw(square, square.Bounds(), source, origin.Add(image.Pt(crop.X, crop.Y)), draw.Over)
+
+	scaled := image.NewRGBA(image.Rect(0, 0, size, size))
+	for ty := 0; ty < size; ty++ {
+		y0, y1 := sourceSpan(ty, size, crop.Size)
+		for tx := 0; tx < size; tx++ {
+			x0, x1 := sourceSpan(tx, size, crop.Size)
+
+			var r, g, b, count int
+			for y := y0; y < y1; y++ {
+				row := square.Pix[y*square.Stride:]
+				for x := x0; x < x1; x++ {
+					r += int(row[x*4])
+					g += int(row[x*4+1])
+					b += int(row[x*4+2])
+					count++
+				}
+			}
+
+			i := ty*scaled.Stride + tx*4
+			scaled.Pix[i] = uint8(r / count)
+			scaled.Pix[i+1] = uint8(g / count)
+			scaled.Pix[i+2] = uint8(b / count)
+			scaled.Pix[i+3] = 0xff
+		}
+	}
+	return scaled
+}
+
+// sourceSpan returns the source pixels [from, to) that target pixel i of n covers, at least one
+func sourceSpan(i, n, sourceSize int) (int, int) {
+	from := i * sourceSize / n
+	to := (i + 1) * sourceSize / n
+	if to <= from {
+		to = from + 1
+	}
+	return from, to
+}
@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+)
+
+var (
+	ErrAdminSelfChange = utils.NewCustomError(http.StatusConflict, utils.CodeAdminSelfChange, "admins can't disable their own account or remove their own admin role")
+	ErrInvalidUserRole = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidUserRole, "role must be admin or member")
+)
+
+// BackOfficeService backs the /admin/v1 API that support staff use to look after accounts,
+// groups and settlements on other users' behalf. Every change is logged with the admin who made it.
+type BackOfficeService struct {
+	userRepo          repositories.UserRepository
+	groupRepo         repositories.GroupRepository
+	balanceRepo       repositories.BalanceRepository
+	settlementService *SettlementService
+	// configuredAdmins are the ADMIN_USER_IDS, admins whatever their role, so the first admin
+	// role can be granted
+	configuredAdmins map[string]bool
+}
+
+func NewBackOfficeService(
+	userRepo repositories.UserRepository,
+	groupRepo repositories.GroupRepository,
+	balanceRepo repositories.BalanceRepository,
+	settlementService *SettlementService,
+	configuredAdmins []string,
+) *BackOfficeService {
+	admins := make(map[string]bool, len(configuredAdmins))
+	for _, id := range configuredAdmins {
+		admins[id] = true
+	}
+	return &BackOfficeService{
+		userRepo:          userRepo,
+		groupRepo:         groupRepo,
+		balanceRepo:       balanceRepo,
+		settlementService: settlementService,
+		configuredAdmins:  admins,
+	}
+}
+
+// IsAdmin reports whether the user may use the back office: a user listed in ADMIN_USER_IDS,
+// or an enabled account with the admin role. The role is read on every call, so revoking it
+// takes effect on the next request.
+func (s *BackOfficeService) IsAdmin(ctx context.Context, userID string) (bool, error) {
+	if s.configuredAdmins[userID] {
+		return true, nil
+	}
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return user.Role == models.RoleAdmin && user.DisabledAt == nil && user.DeletedAt == nil, nil
+}
+
+// SearchUsers finds users by user ID, or by the start of their name or email
+func (s *BackOfficeService) SearchUsers(ctx context.Context, query string, limit, offset int64) ([]*models.User, error) {
+	return s.userRepo.Search(ctx, query, limit, offset)
+}
+
+// GetAccount returns the user with their group memberships and balances
+func (s *BackOfficeService) GetAccount(ctx context.Context, userID string) (*models.AccountDetails, error) {
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := s.groupRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := s.balanceRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	details := &models.AccountDetails{User: user, Groups: []models.AccountGroup{}, Balances: balances}
+	for _, group := range groups {
+		for _, member := range group.Members {
+			if member.UserID == userID {
+				details.Groups = append(details.Groups, models.AccountGroup{
+					GroupID: group.GroupID,
+					Name:    group.Name,
+					Role:    member.Role,
+					Active:  member.IsActive,
+				})
+				break
+			}
+		}
+	}
+	return details, nil
+}
+
+// DisableUser stops the user from logging in until the account is enabled again. Revoking the
+// tokens they already hold is left to the caller.
+func (s *BackOfficeService) DisableUser(ctx context.Context, adminID string, userID string, reason string) (*models.User, error) {
+	if adminID == userID {
+		return nil, ErrAdminSelfChange
+	}
+	user, err := s.getUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.DisabledAt != nil {
+		return user, nil
+	}
+
+	now := time.Now()
+	user, err = s.userRepo.SetDisabled(ctx, userID, &now, reason)
+	if err != nil {
+		return nil, s.userError(err)
+	}
+	log.Printf("Admin %s disabled user %s: %s", adminID, userID, reason)
+	return user, nil
+}
+
+// EnableUser lets a disabled user log in again
+func (s *BackOfficeService) EnableUser(ctx context.Context, adminID string, userID string) (*models.User, error) {
+	user, err := s.userRepo.SetDisabled(ctx, userID, nil, "")
+	if err != nil {
+		return nil, s.userError(err)
+	}
+	log.Printf("Admin %s enabled user %s", adminID, userID)
+	return user, nil
+}
+
+// SetRole grants or removes the admin role
+func (s *BackOfficeService) SetRole(ctx context.Context, adminID string, userID string, role models.UserRole) (*models.User, error) {
+	if role != models.RoleAdmin && role != models.RoleMember {
+		return nil, ErrInvalidUserRole
+	}
+	if adminID == userID && role != models.RoleAdmin {
+		return nil, ErrAdminSelfChange
+	}
+
+	user, err := s.userRepo.SetRole(ctx, userID, role)
+	if err != nil {
+		return nil, s.userError(err)
+	}
+	log.Printf("Admin %s set the role of user %s to %s", adminID, userID, role)
+	return user, nil
+}
+
+// GetGroupBalances returns every balance in the group, whatever the group's privacy settings
+func (s *BackOfficeService) GetGroupBalances(ctx context.Context, groupID string) ([]*models.Balance, error) {
+	if _, err := s.groupRepo.GetByID(ctx, groupID); err != nil {
+		if errors.Is(err, repositories.ErrGroupNotFound) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, err
+	}
+	return s.balanceRepo.GetByGroupID(ctx, groupID)
+}
+
+// ForceCancelSettlement cancels a settlement that hasn't completed, whoever its parties are
+func (s *BackOfficeService) ForceCancelSettlement(ctx context.Context, adminID string, settlementID string, reason string) (*models.Settlement, error) {
+	settlement, err := s.settlementService.ForceCancelSettlement(ctx, settlementID, adminID, reason)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Admin %s force-cancelled settlement %s: %s", adminID, settlementID, reason)
+	return settlement, nil
+}
+
+func (s *BackOfficeService) getUser(ctx context.Context, userID string) (*models.User, error) {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, s.userError(err)
+	}
+	return user, nil
+}
+
+func (s *BackOfficeService) userError(err error) error {
+	if errors.Is(err, repositories.ErrUserNotFound) {
+		return ErrUserNotFound
+	}
+	return err
+}
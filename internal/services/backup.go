@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+	"divvydoo/backend/pkg/backup"
+
+	"github.com/google/uuid"
+)
+
+const (
+	JobBackup       = "backup"
+	JobBackupVerify = "backup:verify"
+)
+
+var (
+	ErrBackupNotFound     = utils.NewCustomError(http.StatusNotFound, utils.CodeBackupNotFound, "backup not found")
+	ErrBackupNotAvailable = utils.NewCustomError(http.StatusConflict, utils.CodeBackupNotAvailable, "backup is not available for verification")
+)
+
+type BackupConfig struct {
+	Dir       string
+	Retention int    // completed backups kept on disk; older ones are deleted
+	Database  string // the database being backed up; verification restores next to it
+}
+
+type BackupService struct {
+	backupRepo      repositories.BackupRepository
+	consistencyRepo repositories.ConsistencyRepository
+	tool            backup.Tool
+	jobService      *JobService
+	cfg             BackupConfig
+}
+
+func NewBackupService(
+	backupRepo repositories.BackupRepository,
+	consistencyRepo repositories.ConsistencyRepository,
+	tool backup.Tool,
+	jobService *JobService,
+	cfg BackupConfig,
+) *BackupService {
+	return &BackupService{
+		backupRepo:      backupRepo,
+		consistencyRepo: consistencyRepo,
+		tool:            tool,
+		jobService:      jobService,
+		cfg:             cfg,
+	}
+}
+
+func (s *BackupService) ListBackups(ctx context.Context, limit int64) ([]*models.Backup, error) {
+	return s.backupRepo.List(ctx, limit)
+}
+
+// StartBackup takes a backup in a background job
+func (s *BackupService) StartBackup(ctx context.Context, adminID string) (*models.Job, error) {
+	return s.jobService.StartExclusive(ctx, JobBackup, adminID, func(ctx context.Context, _ ProgressFunc) (map[string]interface{}, error) {
+		created, err := s.Backup(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"backup_id": created.BackupID, "size_bytes": created.SizeBytes}, nil
+	})
+}
+
+// StartVerification restores a completed backup and checks it in a background job
+func (s *BackupService) StartVerification(ctx context.Context, backupID string, adminID string) (*models.Job, error) {
+	if _, err := s.completedBackup(ctx, backupID); err != nil {
+		return nil, err
+	}
+
+	return s.jobService.StartExclusive(ctx, JobBackupVerify, adminID, func(ctx context.Context, _ ProgressFunc) (map[string]interface{}, error) {
+		verification, err := s.Verify(ctx, backupID)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"backup_id": backupID, "status": verification.Status, "issues": len(verification.Issues)}, nil
+	})
+}
+
+// Backup dumps the database into a new archive and then applies the retention policy
+func (s *BackupService) Backup(ctx context.Context) (*models.Backup, error) {
+	if err := os.MkdirAll(s.cfg.Dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	started := time.Now().UTC()
+	created := &models.Backup{
+		BackupID:  uuid.New().String(),
+		Status:    models.BackupRunning,
+		Path:      filepath.Join(s.cfg.Dir, fmt.Sprintf("%s-%s.archive.gz", s.cfg.Database, started.Format("20060102T150405Z"))),
+		StartedAt: started,
+	}
+	if err := s.backupRepo.Create(ctx, created); err != nil {
+		return nil, err
+	}
+
+	if err := s.tool.Dump(ctx, created.Path); err != nil {
+		s.markFailed(ctx, created.BackupID, err)
+		os.Remove(created.Path)
+		return nil, err
+	}
+
+	info, err := os.Stat(created.Path)
+	if err != nil {
+		s.markFailed(ctx, created.BackupID, err)
+		return nil, err
+	}
+	if err := s.backupRepo.MarkCompleted(ctx, created.BackupID, info.Size()); err != nil {
+		return nil, err
+	}
+	created.Status = models.BackupCompleted
+	created.SizeBytes = info.Size()
+
+	if err := s.applyRetention(ctx); err != nil {
+		// The new backup is fine; old ones will be retried on the next run
+		log.Printf("Failed to apply backup retention: %v", err)
+	}
+
+	return created, nil
+}
+
+// Verify restores the backup into a scratch database, checks its consistency, records the
+// outcome on the backup and drops the scratch database again
+func (s *BackupService) Verify(ctx context.Context, backupID string) (*models.BackupVerification, error) {
+	existing, err := s.completedBackup(ctx, backupID)
+	if err != nil {
+		return nil, err
+	}
+
+	scratch := s.cfg.Database + "_restore_check"
+	defer func() {
+		if err := s.consistencyRepo.DropDatabase(context.Background(), scratch); err != nil {
+			log.Printf("Failed to drop restore-check database %s: %v", scratch, err)
+		}
+	}()
+
+	verification := models.BackupVerification{Status: models.BackupVerificationPassed}
+	if err := s.tool.Restore(ctx, existing.Path, scratch); err != nil {
+		verification.Status = models.BackupVerificationFailed
+		verification.Issues = []string{fmt.Sprintf("restore failed: %v", err)}
+	} else {
+		counts, issues, err := s.consistencyRepo.Check(ctx, scratch)
+		if err != nil {
+			return nil, err
+		}
+		verification.Collections = counts
+		verification.Issues = issues
+		if len(issues) > 0 || len(counts) == 0 {
+			verification.Status = models.BackupVerificationFailed
+		}
+		if len(counts) == 0 {
+			verification.Issues = append(verification.Issues, "restored database is empty")
+		}
+	}
+	verification.VerifiedAt = time.Now()
+
+	if err := s.backupRepo.RecordVerification(ctx, backupID, verification); err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+func (s *BackupService) completedBackup(ctx context.Context, backupID string) (*models.Backup, error) {
+	existing, err := s.backupRepo.GetByID(ctx, backupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrBackupNotFound) {
+			return nil, ErrBackupNotFound
+		}
+		return nil, err
+	}
+	if existing.Status != models.BackupCompleted {
+		return nil, ErrBackupNotAvailable
+	}
+	return existing, nil
+}
+
+// applyRetention deletes the archives of completed backups beyond the newest Retention
+func (s *BackupService) applyRetention(ctx context.Context) error {
+	if s.cfg.Retention <= 0 {
+		return nil
+	}
+
+	completed, err := s.backupRepo.ListCompleted(ctx)
+	if err != nil {
+		return err
+	}
+	if len(completed) <= s.cfg.Retention {
+		return nil
+	}
+
+	for _, old := range completed[s.cfg.Retention:] {
+		if err := os.Remove(old.Path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err := s.backupRepo.MarkExpired(ctx, old.BackupID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *BackupService) markFailed(ctx context.Context, backupID string, cause error) {
+	if err := s.backupRepo.MarkFailed(ctx, backupID, cause.Error()); err != nil {
+		log.Printf("Failed to mark backup %s failed: %v", backupID, err)
+	}
+}
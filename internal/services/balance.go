@@ -3,54 +3,349 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"net/http"
 
 	"divvydoo/backend/internal/models"
 	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+	"divvydoo/backend/pkg/locale"
 )
 
 var (
-	ErrBalanceNotFound = errors.New("balance not found")
+	ErrBalanceNotFound          = utils.NewCustomError(http.StatusNotFound, utils.CodeBalanceNotFound, "balance not found")
+	ErrInvalidBalanceChangeType = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidBalanceChangeType, "invalid balance change type")
 )
 
+const defaultCurrency = "USD"
+
+// CurrencyConverter converts amounts between currencies
+type CurrencyConverter interface {
+	Convert(ctx context.Context, amount float64, from, to string) (float64, error)
+}
+
 type BalanceService struct {
-	balanceRepo repositories.BalanceRepository
-	expenseRepo repositories.ExpenseRepository
-	userRepo    repositories.UserRepository
+	balanceRepo    repositories.BalanceRepository
+	expenseRepo    repositories.ExpenseRepository
+	settlementRepo repositories.SettlementRepository
+	userRepo       repositories.UserRepository
+	groupRepo      repositories.GroupRepository
+	converter      CurrencyConverter
+	aggregates     *AggregateCache
 }
 
+// NewBalanceService creates a BalanceService. converter may be nil, in which case
+// summaries only total the balances already in the user's preferred currency. aggregates may be
+// nil too, in which case summaries aren't cached.
 func NewBalanceService(
 	balanceRepo repositories.BalanceRepository,
 	expenseRepo repositories.ExpenseRepository,
+	settlementRepo repositories.SettlementRepository,
 	userRepo repositories.UserRepository,
+	groupRepo repositories.GroupRepository,
+	converter CurrencyConverter,
+	aggregates *AggregateCache,
 ) *BalanceService {
 	return &BalanceService{
-		balanceRepo: balanceRepo,
-		expenseRepo: expenseRepo,
-		userRepo:    userRepo,
+		balanceRepo:    balanceRepo,
+		expenseRepo:    expenseRepo,
+		settlementRepo: settlementRepo,
+		userRepo:       userRepo,
+		groupRepo:      groupRepo,
+		converter:      converter,
+		aggregates:     aggregates,
 	}
 }
 
 func (s *BalanceService) GetUserBalances(ctx context.Context, userID string) (*models.UserBalanceSummary, error) {
-	// Verify user exists
-	exists, err := s.userRepo.Exists(ctx, userID)
+	user, err := s.userRepo.GetByID(ctx, userID)
 	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return nil, ErrUserNotFound
+		}
 		return nil, err
 	}
-	if !exists {
-		return nil, ErrUserNotFound
+
+	summary, err := s.aggregates.UserBalanceSummary(ctx, userID, func(ctx context.Context) (*models.UserBalanceSummary, error) {
+		return s.balanceRepo.GetUserBalanceSummary(ctx, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := s.applyPeerNames(ctx, summary); err != nil {
+		return nil, err
 	}
 
-	return s.balanceRepo.GetUserBalanceSummary(ctx, userID)
+	// A currency asked for with the request wins over the user's preference
+	currency := locale.FromContext(ctx).Currency
+	if currency == "" {
+		currency = user.Preferences.DefaultCurrency
+	}
+	s.applyTotal(ctx, summary, currency)
+	return summary, nil
 }
 
-func (s *BalanceService) GetGroupBalances(ctx context.Context, groupID string) ([]*models.Balance, error) {
-	return s.balanceRepo.GetByGroupID(ctx, groupID)
+func (s *BalanceService) applyPeerNames(ctx context.Context, summary *models.UserBalanceSummary) error {
+	if len(summary.PeerBalances) == 0 {
+		return nil
+	}
+	peerIDs := make([]string, 0, len(summary.PeerBalances))
+	for _, peer := range summary.PeerBalances {
+		peerIDs = append(peerIDs, peer.PeerID)
+	}
+	peers, err := s.userRepo.GetByIDs(ctx, peerIDs)
+	if err != nil {
+		return err
+	}
+	names := make(map[string]string, len(peers))
+	for _, peer := range peers {
+		names[peer.UserID] = peer.Name
+	}
+	for i := range summary.PeerBalances {
+		summary.PeerBalances[i].PeerName = names[summary.PeerBalances[i].PeerID]
+	}
+	return nil
+}
+
+// applyTotal sets the summary's display currency and total. Per-currency totals are
+// converted when a converter is available; otherwise they are listed as unconverted
+// rather than being summed into a misleading figure.
+func (s *BalanceService) applyTotal(ctx context.Context, summary *models.UserBalanceSummary, preferred string) {
+	currency := preferred
+	if currency == "" && len(summary.CurrencyTotals) == 1 {
+		currency = summary.CurrencyTotals[0].Currency
+	}
+	if currency == "" {
+		currency = defaultCurrency
+	}
+
+	summary.Currency = currency
+	summary.TotalBalance = 0
+	summary.UnconvertedCurrencies = nil
+
+	for _, total := range summary.CurrencyTotals {
+		if total.Currency == currency {
+			summary.TotalBalance += total.Balance
+			continue
+		}
+
+		if s.converter != nil {
+			converted, err := s.converter.Convert(ctx, total.Balance, total.Currency, currency)
+			if err == nil {
+				summary.TotalBalance += converted
+				continue
+			}
+		}
+
+		summary.UnconvertedCurrencies = append(summary.UnconvertedCurrencies, total.Currency)
+	}
+}
+
+// GetGroupBalances returns the group's balances to its members, each with the group's outstanding
+// total in its currency. When the group keeps balances private, members other than admins only
+// get their own balances, and a zero balance for each other currency so they still see its total.
+func (s *BalanceService) GetGroupBalances(ctx context.Context, groupID string, userID string) ([]*models.Balance, error) {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrGroupNotFound) {
+			return nil, ErrNotGroupMember
+		}
+		return nil, err
+	}
+	member := activeMember(group, userID)
+	if member == nil {
+		return nil, ErrNotGroupMember
+	}
+
+	balances, err := s.balanceRepo.GetByGroupID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	outstanding := make(map[string]float64)
+	var currencies []string
+	for _, balance := range balances {
+		if _, ok := outstanding[balance.Currency]; !ok {
+			currencies = append(currencies, balance.Currency)
+			outstanding[balance.Currency] = 0
+		}
+		if balance.Balance < 0 {
+			outstanding[balance.Currency] -= balance.Balance
+		}
+	}
+
+	if group.Settings.PrivateBalances && member.Role != models.RoleAdmin {
+		own := make([]*models.Balance, 0, len(currencies))
+		seen := make(map[string]bool, len(currencies))
+		for _, balance := range balances {
+			if balance.UserID == userID {
+				own = append(own, balance)
+				seen[balance.Currency] = true
+			}
+		}
+		for _, currency := range currencies {
+			if !seen[currency] {
+				own = append(own, &models.Balance{UserID: userID, GroupID: &group.GroupID, Currency: currency})
+			}
+		}
+		balances = own
+	}
+
+	for _, balance := range balances {
+		total := roundCents(outstanding[balance.Currency])
+		balance.GroupOutstanding = &total
+	}
+	return balances, nil
+}
+
+// GetFriendBalance returns what the user and another user owe each other from expenses and
+// settlements outside any group, per currency, and the payments that would settle it. The other
+// user doesn't have to be a friend any more: balances stay when a friendship ends.
+func (s *BalanceService) GetFriendBalance(ctx context.Context, userID string, friendID string) (*models.FriendBalance, error) {
+	friend, err := s.userRepo.GetByID(ctx, friendID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	direct, err := s.balanceRepo.GetDirectBalances(ctx, userID, &friendID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.FriendBalance{
+		UserID:     userID,
+		FriendID:   friendID,
+		FriendName: friend.Name,
+		Balances:   []models.CurrencyTotal{},
+	}
+	var sides []*models.Balance
+	for _, balance := range direct {
+		if result.LastUpdated == nil || balance.UpdatedAt.After(*result.LastUpdated) {
+			updatedAt := balance.UpdatedAt
+			result.LastUpdated = &updatedAt
+		}
+		amount := roundCents(balance.For(userID))
+		if amount == 0 {
+			continue
+		}
+		result.Balances = append(result.Balances, models.CurrencyTotal{Currency: balance.Currency, Balance: amount})
+		sides = append(sides,
+			&models.Balance{UserID: userID, Balance: amount, Currency: balance.Currency},
+			&models.Balance{UserID: friendID, Balance: -amount, Currency: balance.Currency},
+		)
+	}
+	result.SettleUp = settleUpPayments(sides)
+	return result, nil
 }
 
 func (s *BalanceService) GetBalanceHistory(ctx context.Context, userID string, groupID *string, limit, offset int64) ([]*models.BalanceHistory, error) {
 	return s.balanceRepo.GetBalanceHistory(ctx, userID, groupID, limit, offset)
 }
 
+// ListBalanceHistory returns a page of balance history, newest first, and the cursor for the next page.
+// Each entry carries the title of the expense or the other party of the settlement behind it.
+func (s *BalanceService) ListBalanceHistory(ctx context.Context, userID string, groupID *string, types []models.BalanceChangeType, cursor string, limit, offset int64) ([]*models.BalanceHistoryEntry, string, error) {
+	for _, t := range types {
+		if !t.IsValid() {
+			return nil, "", fmt.Errorf("%w: %q", ErrInvalidBalanceChangeType, t)
+		}
+	}
+
+	position, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	history, nextCursor, err := s.balanceRepo.ListBalanceHistory(ctx, userID, groupID, types, position, limit, offset)
+	if err != nil {
+		return nil, "", err
+	}
+
+	entries, err := s.withReferences(ctx, userID, history)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return entries, nextCursor, nil
+}
+
+// withReferences looks up the expenses, settlements and counterparts behind a page of
+// history with one query per collection.
+func (s *BalanceService) withReferences(ctx context.Context, userID string, history []*models.BalanceHistory) ([]*models.BalanceHistoryEntry, error) {
+	var expenseIDs, settlementIDs []string
+	for _, h := range history {
+		switch h.Type {
+		case models.BalanceChangeExpense:
+			expenseIDs = append(expenseIDs, h.ReferenceID)
+		case models.BalanceChangeSettlement:
+			settlementIDs = append(settlementIDs, h.ReferenceID)
+		}
+	}
+
+	expenses := make(map[string]*models.Expense)
+	if len(expenseIDs) > 0 {
+		found, err := s.expenseRepo.GetByIDs(ctx, expenseIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, expense := range found {
+			expenses[expense.ExpenseID] = expense
+		}
+	}
+
+	settlements := make(map[string]*models.Settlement)
+	counterpartNames := make(map[string]string)
+	if len(settlementIDs) > 0 {
+		found, err := s.settlementRepo.GetByIDs(ctx, settlementIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		var counterpartIDs []string
+		for _, settlement := range found {
+			settlements[settlement.SettlementID] = settlement
+			counterpartIDs = append(counterpartIDs, settlementCounterpart(settlement, userID))
+		}
+
+		users, err := s.userRepo.GetByIDs(ctx, counterpartIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			counterpartNames[user.UserID] = user.Name
+		}
+	}
+
+	entries := make([]*models.BalanceHistoryEntry, 0, len(history))
+	for _, h := range history {
+		entry := &models.BalanceHistoryEntry{BalanceHistory: *h}
+		if expense, ok := expenses[h.ReferenceID]; ok && h.Type == models.BalanceChangeExpense {
+			entry.Reference = &models.BalanceHistoryReference{ExpenseTitle: expense.Title}
+		}
+		if settlement, ok := settlements[h.ReferenceID]; ok && h.Type == models.BalanceChangeSettlement {
+			counterpartID := settlementCounterpart(settlement, userID)
+			entry.Reference = &models.BalanceHistoryReference{
+				SettlementStatus: settlement.Status,
+				CounterpartID:    counterpartID,
+				CounterpartName:  counterpartNames[counterpartID],
+			}
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+func settlementCounterpart(settlement *models.Settlement, userID string) string {
+	if settlement.FromUserID == userID {
+		return settlement.ToUserID
+	}
+	return settlement.FromUserID
+}
+
 func (s *BalanceService) GetUserBalanceInGroup(ctx context.Context, userID string, groupID string) (*models.Balance, error) {
 	balance, err := s.balanceRepo.GetByUserAndGroup(ctx, userID, &groupID)
 	if err != nil {
@@ -60,7 +355,7 @@ func (s *BalanceService) GetUserBalanceInGroup(ctx context.Context, userID strin
 				UserID:   userID,
 				GroupID:  &groupID,
 				Balance:  0,
-				Currency: "USD",
+				Currency: defaultCurrency,
 			}, nil
 		}
 		return nil, err
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	// balanceTaskLease is how long a claimed task is hidden from other workers
+	balanceTaskLease = time.Minute
+	// balanceTaskMaxAttempts is how often a task is tried before it is marked failed
+	balanceTaskMaxAttempts = 5
+	// balanceTaskMaxBackoff caps the wait between attempts
+	balanceTaskMaxBackoff = 5 * time.Minute
+)
+
+// applyBalances applies the expense to balances, or in async balance mode queues it for the
+// balance worker. Either way it belongs in the transaction that makes the expense affect balances.
+func (s *ExpenseService) applyBalances(ctx context.Context, expense models.Expense) error {
+	if !s.asyncBalances {
+		return s.updateBalances(ctx, expense)
+	}
+
+	task := &models.BalanceTask{
+		TaskID:      uuid.New().String(),
+		GroupID:     expense.GroupID,
+		Expense:     expense,
+		Status:      models.BalanceTaskPending,
+		AvailableAt: time.Now(),
+		CreatedAt:   time.Now(),
+	}
+	return s.balanceTasks.CreateMany(ctx, []*models.BalanceTask{task})
+}
+
+// ProcessBalanceTasks applies up to limit queued balance updates and returns how many it
+// claimed. A task that fails is retried with backoff, and marked failed once it runs out of
+// attempts; reconciling its group then brings the balances back in line.
+func (s *ExpenseService) ProcessBalanceTasks(ctx context.Context, limit int) (int, error) {
+	tasks, err := s.balanceTasks.Claim(ctx, limit, balanceTaskLease)
+	if err != nil {
+		return len(tasks), fmt.Errorf("failed to claim balance tasks: %v", err)
+	}
+
+	changedGroups := make(map[string]bool)
+	for _, task := range tasks {
+		// One event per group is enough for clients to refresh
+		publish := task.GroupID == nil || !changedGroups[*task.GroupID]
+		err := s.applyBalanceTask(ctx, task, publish)
+		if errors.Is(err, repositories.ErrBalanceTaskNotFound) {
+			continue // another worker got to it first
+		}
+		if err != nil {
+			s.retryBalanceTask(ctx, task, err)
+			continue
+		}
+		s.aggregates.EvictBalances(ctx, expenseParticipants(&task.Expense)...)
+		if task.GroupID != nil {
+			changedGroups[*task.GroupID] = true
+		}
+	}
+	if len(tasks) > 0 {
+		s.outbox.Wake()
+	}
+	return len(tasks), nil
+}
+
+// applyBalanceTask removes the task and applies its update in one transaction, along with the
+// balances changed event if publish is set
+func (s *ExpenseService) applyBalanceTask(ctx context.Context, task *models.BalanceTask, publish bool) error {
+	session, err := s.balanceRepo.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if err := s.balanceTasks.Complete(sessCtx, task.TaskID); err != nil {
+			return nil, err
+		}
+		if err := s.updateBalances(sessCtx, task.Expense); err != nil {
+			return nil, err
+		}
+		if !publish {
+			return nil, nil
+		}
+		out := s.outbox.Writer(sessCtx)
+		s.publishBalancesChanged(sessCtx, out, &task.Expense)
+		return nil, out.Err()
+	})
+	if err != nil && !errors.Is(err, repositories.ErrBalanceTaskNotFound) {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+	return err
+}
+
+func (s *ExpenseService) retryBalanceTask(ctx context.Context, task *models.BalanceTask, cause error) {
+	if task.Attempts >= balanceTaskMaxAttempts {
+		log.Printf("Balance update for expense %s failed %d times, giving up: %v", task.Expense.ExpenseID, task.Attempts, cause)
+		if err := s.balanceTasks.MarkFailed(ctx, task.TaskID, cause.Error()); err != nil {
+			log.Printf("Failed to mark balance task %s failed: %v", task.TaskID, err)
+		}
+		return
+	}
+
+	backoff := time.Second << task.Attempts
+	if backoff > balanceTaskMaxBackoff {
+		backoff = balanceTaskMaxBackoff
+	}
+	log.Printf("Balance update for expense %s failed, retrying in %s: %v", task.Expense.ExpenseID, backoff, cause)
+	if err := s.balanceTasks.Retry(ctx, task.TaskID, cause.Error(), time.Now().Add(backoff)); err != nil {
+		log.Printf("Failed to reschedule balance task %s: %v", task.TaskID, err)
+	}
+}
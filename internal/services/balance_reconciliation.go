@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+)
+
+// ErrBalanceUpdatesPending means queued balance updates haven't reached the group's balances yet,
+// so they can't be compared with the ledger
+var ErrBalanceUpdatesPending = utils.NewCustomError(http.StatusConflict, utils.CodeBalanceUpdatesPending, "balance updates are still pending for this group")
+
+// reconciledHistoryTypes are the balance changes recorded only in the history, with no expense or
+// settlement behind them, that the recomputed balances include. Corrections are left out: they
+// only bring the stored balances back in line with the rest.
+var reconciledHistoryTypes = []models.BalanceChangeType{models.BalanceChangeAdjustment, models.BalanceChangeNetting}
+
+// BalanceReconciliationService recomputes group balances from the ledger they are maintained
+// from: expenses that affect balances, completed settlements, and the adjustments and netting
+// recorded in the balance history. Stored balances that have drifted from it are reported and,
+// on request, corrected.
+type BalanceReconciliationService struct {
+	balanceRepo    repositories.BalanceRepository
+	expenseRepo    repositories.ExpenseRepository
+	settlementRepo repositories.SettlementRepository
+	groupRepo      repositories.GroupRepository
+	balanceTasks   repositories.BalanceTaskRepository
+	events         EventPublisher
+	aggregates     *AggregateCache
+}
+
+func NewBalanceReconciliationService(
+	balanceRepo repositories.BalanceRepository,
+	expenseRepo repositories.ExpenseRepository,
+	settlementRepo repositories.SettlementRepository,
+	groupRepo repositories.GroupRepository,
+	balanceTasks repositories.BalanceTaskRepository,
+	events EventPublisher,
+	aggregates *AggregateCache,
+) *BalanceReconciliationService {
+	return &BalanceReconciliationService{
+		balanceRepo:    balanceRepo,
+		expenseRepo:    expenseRepo,
+		settlementRepo: settlementRepo,
+		groupRepo:      groupRepo,
+		balanceTasks:   balanceTasks,
+		events:         events,
+		aggregates:     aggregates,
+	}
+}
+
+// ReconcileGroup recomputes the group's balances and, if correct is set, moves every drifted
+// balance to the recomputed one, recording the correction in the balance history
+func (s *BalanceReconciliationService) ReconcileGroup(ctx context.Context, groupID string, correct bool) (*models.BalanceReconciliation, error) {
+	if _, err := s.groupRepo.GetByID(ctx, groupID); err != nil {
+		return nil, err
+	}
+
+	checked, drifts, err := s.compareGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	reconciliation := &models.BalanceReconciliation{
+		GroupID:         groupID,
+		BalancesChecked: checked,
+		Drifts:          drifts,
+		CheckedAt:       time.Now(),
+	}
+	if !correct || len(drifts) == 0 {
+		return reconciliation, nil
+	}
+
+	if err := s.correct(ctx, groupID, drifts); err != nil {
+		return nil, err
+	}
+	reconciliation.Corrected = true
+
+	publishEvent(ctx, s.events, s.groupRepo, Event{
+		Type:    EventBalancesChanged,
+		GroupID: &groupID,
+		Data:    map[string]interface{}{"reconciled": true},
+	})
+
+	return reconciliation, nil
+}
+
+// ReconcileAll reconciles every group with balances and returns the groups that had drifted.
+// A group that fails is logged and skipped, so one bad group doesn't hold up the rest.
+func (s *BalanceReconciliationService) ReconcileAll(ctx context.Context, correct bool) ([]*models.BalanceReconciliation, error) {
+	groupIDs, err := s.balanceRepo.GetGroupIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var drifted []*models.BalanceReconciliation
+	for _, groupID := range groupIDs {
+		if ctx.Err() != nil {
+			return drifted, ctx.Err()
+		}
+		reconciliation, err := s.ReconcileGroup(ctx, groupID, correct)
+		if errors.Is(err, ErrBalanceUpdatesPending) {
+			continue // checked again next time
+		}
+		if err != nil {
+			log.Printf("Failed to reconcile balances of group %s: %v", groupID, err)
+			continue
+		}
+		if len(reconciliation.Drifts) > 0 {
+			drifted = append(drifted, reconciliation)
+		}
+	}
+	return drifted, nil
+}
+
+// compareGroup reads the group's balances and the ledger they come from in one snapshot, so
+// updates committed in between can't show up on one side only
+func (s *BalanceReconciliationService) compareGroup(ctx context.Context, groupID string) (int, []models.BalanceDrift, error) {
+	session, err := s.balanceRepo.StartSession()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer session.EndSession(ctx)
+
+	type balanceKey struct{ userID, currency string }
+	var (
+		balances   []*models.Balance
+		recomputed map[balanceKey]float64
+	)
+	txnOpts := options.Transaction().SetReadConcern(readconcern.Snapshot())
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		// The transaction may be retried, so start over each time
+		recomputed = make(map[balanceKey]float64)
+
+		// Queued updates are in the ledger but not in the balances yet
+		pending, err := s.balanceTasks.CountPendingByGroupID(sessCtx, groupID)
+		if err != nil {
+			return nil, err
+		}
+		if pending > 0 {
+			return nil, ErrBalanceUpdatesPending
+		}
+
+		err = s.expenseRepo.ForEachByGroupID(sessCtx, groupID, func(expense *models.Expense) error {
+			if !expense.AffectsBalances() {
+				return nil
+			}
+			for _, change := range expenseBalanceChanges(*expense) {
+				recomputed[balanceKey{change.userID, expense.Currency}] += change.amount
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		settlements, err := s.settlementRepo.GetCompletedInPeriod(sessCtx, &groupID, nil, time.Time{}, time.Now().AddDate(100, 0, 0))
+		if err != nil {
+			return nil, err
+		}
+		for _, settlement := range settlements {
+			recomputed[balanceKey{settlement.FromUserID, settlement.Currency}] += settlement.Amount
+			recomputed[balanceKey{settlement.ToUserID, settlement.Currency}] -= settlement.Amount
+		}
+
+		totals, err := s.balanceRepo.SumGroupHistory(sessCtx, groupID, reconciledHistoryTypes)
+		if err != nil {
+			return nil, err
+		}
+		for _, total := range totals {
+			recomputed[balanceKey{total.UserID, total.Currency}] += total.Total
+		}
+
+		balances, err = s.balanceRepo.GetByGroupID(sessCtx, groupID)
+		return nil, err
+	}, txnOpts)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	drifts := []models.BalanceDrift{}
+	for _, balance := range balances {
+		key := balanceKey{balance.UserID, balance.Currency}
+		expected := recomputed[key]
+		delete(recomputed, key)
+		if drift := balanceDrift(balance.UserID, balance.Currency, balance.Balance, expected); drift != nil {
+			drifts = append(drifts, *drift)
+		}
+	}
+	// Balances the ledger has but that were never stored
+	for key, expected := range recomputed {
+		if drift := balanceDrift(key.userID, key.currency, 0, expected); drift != nil {
+			drifts = append(drifts, *drift)
+		}
+	}
+
+	return len(balances), drifts, nil
+}
+
+// balanceDrift compares the balances in cents, so float rounding left by incremental updates
+// isn't reported as drift
+func balanceDrift(userID, currency string, balance, recomputed float64) *models.BalanceDrift {
+	balanceCents := int64(math.Round(balance * 100))
+	recomputedCents := int64(math.Round(recomputed * 100))
+	if balanceCents == recomputedCents {
+		return nil
+	}
+	return &models.BalanceDrift{
+		UserID:     userID,
+		Currency:   currency,
+		Balance:    balance,
+		Recomputed: float64(recomputedCents) / 100,
+		Drift:      float64(balanceCents-recomputedCents) / 100,
+	}
+}
+
+// correct moves each drifted balance by its drift. Balances are moved rather than overwritten,
+// so an expense or settlement recorded since the comparison is kept.
+func (s *BalanceReconciliationService) correct(ctx context.Context, groupID string, drifts []models.BalanceDrift) error {
+	session, err := s.balanceRepo.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		now := time.Now()
+		for _, drift := range drifts {
+			if err := s.balanceRepo.UpdateBalance(sessCtx, drift.UserID, &groupID, drift.Currency, -drift.Drift); err != nil {
+				return nil, err
+			}
+			history := &models.BalanceHistory{
+				UserID:      drift.UserID,
+				GroupID:     &groupID,
+				Amount:      -drift.Drift,
+				Currency:    drift.Currency,
+				Type:        models.BalanceChangeCorrection,
+				ReferenceID: groupID,
+				Description: "Balance corrected to match the group's expenses and settlements",
+				CreatedAt:   now,
+			}
+			if err := s.balanceRepo.CreateBalanceHistory(sessCtx, history); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+
+	userIDs := make([]string, 0, len(drifts))
+	for _, drift := range drifts {
+		userIDs = append(userIDs, drift.UserID)
+		log.Printf("Corrected %s balance of user %s in group %s by %.2f", drift.Currency, drift.UserID, groupID, -drift.Drift)
+	}
+	s.aggregates.EvictBalances(ctx, userIDs...)
+	return nil
+}
@@ -0,0 +1,215 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+)
+
+var (
+	ErrInvalidGranularity  = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidGranularity, "granularity must be daily, weekly or monthly")
+	ErrInvalidTimelineSpan = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidTimelineSpan, "invalid balance timeline period")
+)
+
+const (
+	GranularityDaily   = "daily"
+	GranularityWeekly  = "weekly"
+	GranularityMonthly = "monthly"
+
+	// balanceSnapshotBatchSize is how many snapshots are written at a time
+	balanceSnapshotBatchSize = 500
+	// maxBalanceTimelineDays caps how far apart from and to may be
+	maxBalanceTimelineDays = 731
+)
+
+// BalanceSnapshotService keeps a daily snapshot of every balance so users can chart their
+// balances over time
+type BalanceSnapshotService struct {
+	balanceRepo  repositories.BalanceRepository
+	snapshotRepo repositories.BalanceSnapshotRepository
+}
+
+func NewBalanceSnapshotService(balanceRepo repositories.BalanceRepository, snapshotRepo repositories.BalanceSnapshotRepository) *BalanceSnapshotService {
+	return &BalanceSnapshotService{
+		balanceRepo:  balanceRepo,
+		snapshotRepo: snapshotRepo,
+	}
+}
+
+// SnapshotBalances records every balance as today's snapshot, replacing one taken earlier today,
+// and returns how many it recorded
+func (s *BalanceSnapshotService) SnapshotBalances(ctx context.Context, now time.Time) (int, error) {
+	date := now.UTC().Format(time.DateOnly)
+
+	var batch []*models.BalanceSnapshot
+	taken := 0
+	flush := func() error {
+		if err := s.snapshotRepo.UpsertMany(ctx, batch); err != nil {
+			return err
+		}
+		taken += len(batch)
+		batch = batch[:0]
+		return nil
+	}
+
+	err := s.balanceRepo.ForEach(ctx, func(balance *models.Balance) error {
+		batch = append(batch, &models.BalanceSnapshot{
+			UserID:   balance.UserID,
+			GroupID:  balance.GroupID,
+			Currency: balance.Currency,
+			Balance:  balance.Balance,
+			Date:     date,
+			TakenAt:  now,
+		})
+		if len(batch) >= balanceSnapshotBatchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	return taken, err
+}
+
+// GetTimeline returns the user's balances from from to to, both dates inclusive, one point per
+// period: each day, each week starting on Monday, or each month. A point is the balance at the
+// end of its period, or at the last snapshot for the current one. Zero from and to default to
+// the last 30 days, 12 weeks or 12 months.
+func (s *BalanceSnapshotService) GetTimeline(ctx context.Context, userID string, groupID *string, granularity string, from, to time.Time) (*models.BalanceTimeline, error) {
+	if granularity == "" {
+		granularity = GranularityDaily
+	}
+	if granularity != GranularityDaily && granularity != GranularityWeekly && granularity != GranularityMonthly {
+		return nil, ErrInvalidGranularity
+	}
+
+	if to.IsZero() {
+		to = time.Now()
+	}
+	to = to.UTC().Truncate(24 * time.Hour)
+	if from.IsZero() {
+		switch granularity {
+		case GranularityDaily:
+			from = to.AddDate(0, 0, -29)
+		case GranularityWeekly:
+			from = periodStart(to, granularity).AddDate(0, 0, -7*11)
+		case GranularityMonthly:
+			from = periodStart(to, granularity).AddDate(0, -11, 0)
+		}
+	}
+	from = from.UTC().Truncate(24 * time.Hour)
+	if from.After(to) {
+		return nil, fmt.Errorf("%w: from must not be after to", ErrInvalidTimelineSpan)
+	}
+	if to.Sub(from) > maxBalanceTimelineDays*24*time.Hour {
+		return nil, fmt.Errorf("%w: at most %d days at a time", ErrInvalidTimelineSpan, maxBalanceTimelineDays)
+	}
+
+	snapshots, err := s.snapshotRepo.GetByUserID(ctx, userID, groupID, from.Format(time.DateOnly), to.Format(time.DateOnly))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.BalanceTimeline{
+		UserID:      userID,
+		Granularity: granularity,
+		From:        from.Format(time.DateOnly),
+		To:          to.Format(time.DateOnly),
+		Totals:      balanceTotals(snapshots, granularity),
+		Series:      balanceSeries(snapshots, granularity),
+	}, nil
+}
+
+// balanceSeries turns the snapshots, oldest first, into one series per balance. Within a
+// period, the latest snapshot wins.
+func balanceSeries(snapshots []*models.BalanceSnapshot, granularity string) []models.BalanceSeries {
+	type seriesKey struct{ groupID, currency string }
+	index := make(map[seriesKey]int)
+	series := []models.BalanceSeries{}
+
+	for _, snapshot := range snapshots {
+		var key seriesKey
+		if snapshot.GroupID != nil {
+			key.groupID = *snapshot.GroupID
+		}
+		key.currency = snapshot.Currency
+
+		i, ok := index[key]
+		if !ok {
+			i = len(series)
+			index[key] = i
+			series = append(series, models.BalanceSeries{GroupID: snapshot.GroupID, Currency: snapshot.Currency, Points: []models.BalancePoint{}})
+		}
+		series[i].Points = appendPoint(series[i].Points, snapshotPeriod(snapshot, granularity), snapshot.Balance)
+	}
+	return series
+}
+
+// balanceTotals adds up, per currency, the user's balances in each period. A period's total is
+// the sum of the balances on its last snapshot day, so it matches the series' points.
+func balanceTotals(snapshots []*models.BalanceSnapshot, granularity string) []models.BalanceSeries {
+	type dayKey struct{ currency, date string }
+	daily := make(map[dayKey]float64)
+	var keys []dayKey
+	for _, snapshot := range snapshots {
+		key := dayKey{snapshot.Currency, snapshot.Date}
+		if _, ok := daily[key]; !ok {
+			keys = append(keys, key)
+		}
+		daily[key] += snapshot.Balance
+	}
+	// Snapshots come oldest first, but dates of different currencies interleave
+	sort.SliceStable(keys, func(i, j int) bool { return keys[i].date < keys[j].date })
+
+	index := make(map[string]int)
+	totals := []models.BalanceSeries{}
+	for _, key := range keys {
+		i, ok := index[key.currency]
+		if !ok {
+			i = len(totals)
+			index[key.currency] = i
+			totals = append(totals, models.BalanceSeries{Currency: key.currency, Points: []models.BalancePoint{}})
+		}
+		date, _ := time.Parse(time.DateOnly, key.date)
+		period := periodStart(date, granularity).Format(time.DateOnly)
+		totals[i].Points = appendPoint(totals[i].Points, period, roundCents(daily[key]))
+	}
+	return totals
+}
+
+// appendPoint adds the balance for the period, replacing the previous point when it is for the
+// same period
+func appendPoint(points []models.BalancePoint, period string, balance float64) []models.BalancePoint {
+	if n := len(points); n > 0 && points[n-1].Date == period {
+		points[n-1].Balance = balance
+		return points
+	}
+	return append(points, models.BalancePoint{Date: period, Balance: balance})
+}
+
+func snapshotPeriod(snapshot *models.BalanceSnapshot, granularity string) string {
+	date, err := time.Parse(time.DateOnly, snapshot.Date)
+	if err != nil {
+		return snapshot.Date
+	}
+	return periodStart(date, granularity).Format(time.DateOnly)
+}
+
+// periodStart returns the first day of the period the date falls in
+func periodStart(date time.Time, granularity string) time.Time {
+	switch granularity {
+	case GranularityWeekly:
+		// Weeks start on Monday
+		return date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+	case GranularityMonthly:
+		return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+	return date
+}
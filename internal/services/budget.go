@@ -0,0 +1,359 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+)
+
+var (
+	ErrBudgetNotSet  = utils.NewCustomError(http.StatusNotFound, utils.CodeBudgetNotSet, "group has no budget")
+	ErrInvalidBudget = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidBudget, "invalid budget")
+)
+
+const (
+	maxBudgetCategories      = 50
+	maxBudgetCategoryLength  = 50
+	minBudgetAmount          = 0.01
+	budgetMonthFormat        = "2006-01"
+	budgetMonthDisplayFormat = "January 2006"
+)
+
+// budgetThresholds are announced highest first: crossing several at once sends one notification
+var budgetThresholds = []int{models.BudgetExceededThreshold, models.BudgetWarningThreshold}
+
+// BudgetTracker is told about new group expenses so it can alert members as budgets fill up
+type BudgetTracker interface {
+	ExpensesAdded(ctx context.Context, groupID string)
+}
+
+// SetBudgetRequest sets a group's monthly budget. Amount is the overall budget (0 for none) and
+// Categories the budget per expense category; at least one of them is required.
+type SetBudgetRequest struct {
+	Amount     float64            `json:"amount" binding:"gte=0"`
+	Categories map[string]float64 `json:"categories,omitempty"`
+}
+
+type BudgetService struct {
+	budgetRepo  repositories.BudgetRepository
+	expenseRepo repositories.ExpenseRepository
+	groupRepo   repositories.GroupRepository
+	notifier    Notifier
+	statuses    *aggregationFallback[*models.BudgetStatus]
+}
+
+func NewBudgetService(
+	budgetRepo repositories.BudgetRepository,
+	expenseRepo repositories.ExpenseRepository,
+	groupRepo repositories.GroupRepository,
+	notifier Notifier,
+	aggregationBudget AggregationBudget,
+) *BudgetService {
+	return &BudgetService{
+		budgetRepo:  budgetRepo,
+		expenseRepo: expenseRepo,
+		groupRepo:   groupRepo,
+		notifier:    notifier,
+		statuses:    newAggregationFallback[*models.BudgetStatus]("budget_status", aggregationBudget),
+	}
+}
+
+// GetBudgetStatus returns the group's spending this month against its budget
+func (s *BudgetService) GetBudgetStatus(ctx context.Context, groupID string, userID string) (*models.BudgetStatus, error) {
+	if err := requireGroupMember(ctx, s.groupRepo, groupID, userID); err != nil {
+		return nil, err
+	}
+
+	budget, err := s.budgetRepo.GetByGroupID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrBudgetNotFound) {
+			return nil, ErrBudgetNotSet
+		}
+		return nil, err
+	}
+
+	// A changed budget gets a new key, so a stored status never shows an old budget
+	now := time.Now()
+	key := groupID + "|" + now.UTC().Format(budgetMonthFormat) + "|" + budget.UpdatedAt.UTC().Format(time.RFC3339Nano)
+	status, stale, err := s.statuses.get(ctx, key, func(ctx context.Context) (*models.BudgetStatus, error) {
+		return s.status(ctx, budget, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stale {
+		staleStatus := *status
+		staleStatus.Stale = true
+		return &staleStatus, nil
+	}
+	return status, nil
+}
+
+// SetBudget replaces the group's budget, in the group's currency. Thresholds the month's spending
+// has already crossed are not announced again.
+func (s *BudgetService) SetBudget(ctx context.Context, groupID string, userID string, req SetBudgetRequest) (*models.BudgetStatus, error) {
+	group, err := requireGroupAdmin(ctx, s.groupRepo, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	categories, err := validateBudget(req)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	budget := &models.GroupBudget{
+		GroupID:    groupID,
+		Currency:   group.Currency,
+		Amount:     req.Amount,
+		Categories: categories,
+		UpdatedBy:  userID,
+		UpdatedAt:  now,
+	}
+
+	status, err := s.status(ctx, budget, now)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range budgetLines(status) {
+		for _, threshold := range budgetThresholds {
+			if crossed(line, threshold) {
+				budget.Alerts = append(budget.Alerts, models.BudgetAlert{Period: status.Month, Category: line.Category, Threshold: threshold})
+			}
+		}
+	}
+
+	if err := s.budgetRepo.Upsert(ctx, budget); err != nil {
+		return nil, err
+	}
+	return status, nil
+}
+
+// DeleteBudget removes the group's budget
+func (s *BudgetService) DeleteBudget(ctx context.Context, groupID string, userID string) error {
+	if _, err := requireGroupAdmin(ctx, s.groupRepo, groupID, userID); err != nil {
+		return err
+	}
+
+	return s.budgetRepo.Delete(ctx, groupID)
+}
+
+// ExpensesAdded notifies the group's members of the budget thresholds its new expenses crossed.
+// Failures are logged: the expenses are already saved.
+func (s *BudgetService) ExpensesAdded(ctx context.Context, groupID string) {
+	if err := s.checkThresholds(ctx, groupID); err != nil {
+		log.Printf("Failed to check budget of group %s: %v", groupID, err)
+	}
+}
+
+func (s *BudgetService) checkThresholds(ctx context.Context, groupID string) error {
+	budget, err := s.budgetRepo.GetByGroupID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrBudgetNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	status, err := s.status(ctx, budget, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var group *models.Group
+	for _, line := range budgetLines(status) {
+		announce := 0
+		for _, threshold := range budgetThresholds {
+			if !crossed(line, threshold) {
+				continue
+			}
+			// Lower thresholds are recorded too, so they aren't announced after a higher one
+			recorded, err := s.budgetRepo.RecordAlert(ctx, groupID, models.BudgetAlert{Period: status.Month, Category: line.Category, Threshold: threshold})
+			if err != nil {
+				return err
+			}
+			if recorded && announce == 0 {
+				announce = threshold
+			}
+		}
+		if announce == 0 {
+			continue
+		}
+
+		if group == nil {
+			if group, err = s.groupRepo.GetByID(ctx, groupID); err != nil {
+				return err
+			}
+		}
+		s.notifyThreshold(ctx, group, status, line, announce)
+	}
+	return nil
+}
+
+func (s *BudgetService) notifyThreshold(ctx context.Context, group *models.Group, status *models.BudgetStatus, line models.BudgetLine, threshold int) {
+	month, _ := time.Parse(budgetMonthFormat, status.Month)
+	monthName := month.Format(budgetMonthDisplayFormat)
+
+	// The category goes into its own format rather than the arguments, so translations can
+	// place it as their grammar needs
+	title := "%s has used %d%% of its budget"
+	titleArgs := []interface{}{group.Name, line.PercentUsed}
+	if threshold >= models.BudgetExceededThreshold {
+		title = "%s is over its budget"
+		titleArgs = []interface{}{group.Name}
+	}
+	body := "%.2f %s of the %.2f %s budget for %s is spent."
+	bodyArgs := []interface{}{line.Spent, status.Currency, line.Budget, status.Currency, monthName}
+	if line.Category != "" {
+		title = "%s has used %d%% of its %s budget"
+		titleArgs = []interface{}{group.Name, line.PercentUsed, line.Category}
+		if threshold >= models.BudgetExceededThreshold {
+			title = "%s is over its %s budget"
+			titleArgs = []interface{}{group.Name, line.Category}
+		}
+		body = "%.2f %s of the %.2f %s %s budget for %s is spent."
+		bodyArgs = []interface{}{line.Spent, status.Currency, line.Budget, status.Currency, line.Category, monthName}
+	}
+
+	data := map[string]interface{}{
+		"group_id":     group.GroupID,
+		"month":        status.Month,
+		"threshold":    threshold,
+		"percent_used": line.PercentUsed,
+	}
+	if line.Category != "" {
+		data["category"] = line.Category
+	}
+
+	for _, member := range group.Members {
+		if !member.IsActive {
+			continue
+		}
+		deliver(ctx, s.notifier, Notification{
+			UserID: member.UserID,
+			Type:   models.NotificationBudgetThreshold,
+			Title:  title,
+			Body:   body,
+			Data:   data,
+
+			TitleArgs: titleArgs,
+			BodyArgs:  bodyArgs,
+		})
+	}
+}
+
+// status adds up the group's expenses in the month of now (UTC) against the budget
+func (s *BudgetService) status(ctx context.Context, budget *models.GroupBudget, now time.Time) (*models.BudgetStatus, error) {
+	now = now.UTC()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	totals, err := s.expenseRepo.SumByCategoryInPeriod(ctx, budget.GroupID, from, from.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	status := &models.BudgetStatus{
+		GroupID:     budget.GroupID,
+		Month:       from.Format(budgetMonthFormat),
+		Currency:    budget.Currency,
+		Categories:  []models.BudgetLine{},
+		GeneratedAt: now,
+	}
+
+	spentByCategory := make(map[string]float64)
+	excluded := make(map[string]bool)
+	for _, total := range totals {
+		if total.Currency != budget.Currency {
+			excluded[total.Currency] = true
+			continue
+		}
+		status.Spent += total.Total
+		spentByCategory[total.Category] += total.Total
+	}
+	status.Spent = roundCents(status.Spent)
+	for currency := range excluded {
+		status.ExcludedCurrencies = append(status.ExcludedCurrencies, currency)
+	}
+	sort.Strings(status.ExcludedCurrencies)
+
+	if budget.Amount > 0 {
+		line := budgetLine("", budget.Amount, status.Spent)
+		status.Total = &line
+	}
+	for category, amount := range budget.Categories {
+		status.Categories = append(status.Categories, budgetLine(category, amount, spentByCategory[category]))
+	}
+	sort.Slice(status.Categories, func(i, j int) bool { return status.Categories[i].Category < status.Categories[j].Category })
+
+	return status, nil
+}
+
+func budgetLine(category string, budget float64, spent float64) models.BudgetLine {
+	spent = roundCents(spent)
+	// In whole cents, so the percentage agrees with crossed
+	spentCents, budgetCents := int64(math.Round(spent*100)), int64(math.Round(budget*100))
+	return models.BudgetLine{
+		Category:    category,
+		Budget:      budget,
+		Spent:       spent,
+		Remaining:   roundCents(budget - spent),
+		PercentUsed: int(spentCents * 100 / budgetCents),
+	}
+}
+
+// budgetLines lists the overall budget, if any, and the category budgets
+func budgetLines(status *models.BudgetStatus) []models.BudgetLine {
+	lines := status.Categories
+	if status.Total != nil {
+		lines = append([]models.BudgetLine{*status.Total}, lines...)
+	}
+	return lines
+}
+
+// crossed reports whether spending reached threshold percent of the budget, compared in cents
+func crossed(line models.BudgetLine, threshold int) bool {
+	return math.Round(line.Spent*100)*100 >= math.Round(line.Budget*100)*float64(threshold)
+}
+
+// validateBudget checks the amounts and returns the category budgets with trimmed names
+func validateBudget(req SetBudgetRequest) (map[string]float64, error) {
+	if req.Amount < 0 || (req.Amount > 0 && req.Amount < minBudgetAmount) {
+		return nil, fmt.Errorf("%w: amount must be 0 or at least %.2f", ErrInvalidBudget, minBudgetAmount)
+	}
+	if len(req.Categories) > maxBudgetCategories {
+		return nil, fmt.Errorf("%w: at most %d category budgets are allowed", ErrInvalidBudget, maxBudgetCategories)
+	}
+
+	categories := make(map[string]float64, len(req.Categories))
+	for category, amount := range req.Categories {
+		name := strings.TrimSpace(category)
+		if name == "" || len(name) > maxBudgetCategoryLength {
+			return nil, fmt.Errorf("%w: category names must be 1 to %d characters", ErrInvalidBudget, maxBudgetCategoryLength)
+		}
+		if amount < minBudgetAmount {
+			return nil, fmt.Errorf("%w: the %s budget must be at least %.2f", ErrInvalidBudget, name, minBudgetAmount)
+		}
+		if _, ok := categories[name]; ok {
+			return nil, fmt.Errorf("%w: category %s is listed more than once", ErrInvalidBudget, name)
+		}
+		categories[name] = amount
+	}
+
+	if req.Amount == 0 && len(categories) == 0 {
+		return nil, fmt.Errorf("%w: set an amount, category budgets or both", ErrInvalidBudget)
+	}
+	if len(categories) == 0 {
+		categories = nil
+	}
+	return categories, nil
+}
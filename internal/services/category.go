@@ -0,0 +1,62 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+
+	"divvydoo/backend/internal/models"
+)
+
+var ErrInvalidCategoryCatalog = errors.New("invalid category catalog")
+
+var categoryColorPattern = regexp.MustCompile(`^#[0-9A-Fa-f]{6}$`)
+
+// CategoryService serves the expense category catalog. The catalog is fixed for the life of the
+// process, so its ETag is computed once.
+type CategoryService struct {
+	catalog *models.CategoryCatalog
+	etag    string
+}
+
+// NewCategoryService parses and validates a catalog in the categories.json format
+func NewCategoryService(data []byte) (*CategoryService, error) {
+	var catalog models.CategoryCatalog
+	if err := json.Unmarshal(data, &catalog); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCategoryCatalog, err)
+	}
+
+	seen := make(map[string]bool, len(catalog.Categories))
+	for i, category := range catalog.Categories {
+		switch {
+		case category.ID == "" || category.LabelKey == "" || category.Icon == "":
+			return nil, fmt.Errorf("%w: category %d needs an id, label_key and icon", ErrInvalidCategoryCatalog, i+1)
+		case seen[category.ID]:
+			return nil, fmt.Errorf("%w: duplicate category %q", ErrInvalidCategoryCatalog, category.ID)
+		case !categoryColorPattern.MatchString(category.Color):
+			return nil, fmt.Errorf("%w: category %q color must be #RRGGBB", ErrInvalidCategoryCatalog, category.ID)
+		}
+		seen[category.ID] = true
+	}
+
+	// Hash the re-encoded catalog so formatting changes to the file don't change the ETag
+	canonical, err := json.Marshal(catalog)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(canonical)
+
+	return &CategoryService{
+		catalog: &catalog,
+		etag:    fmt.Sprintf(`W/"%d-%s"`, catalog.Version, hex.EncodeToString(sum[:8])),
+	}, nil
+}
+
+// Catalog returns the catalog and its ETag. The ETag is weak because the same catalog can be
+// sent raw or enveloped.
+func (s *CategoryService) Catalog() (*models.CategoryCatalog, string) {
+	return s.catalog, s.etag
+}
@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math/rand"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+type ClientErrorService struct {
+	clientErrorRepo repositories.ClientErrorRepository
+	sampleRate      float64
+}
+
+func NewClientErrorService(clientErrorRepo repositories.ClientErrorRepository, sampleRate float64) *ClientErrorService {
+	return &ClientErrorService{
+		clientErrorRepo: clientErrorRepo,
+		sampleRate:      sampleRate,
+	}
+}
+
+// Report stores a client error report, subject to sampling.
+// Returns false when the report was dropped by the sampler.
+func (s *ClientErrorService) Report(ctx context.Context, userID string, intakeRequestID string, req models.ClientErrorReportRequest) (bool, error) {
+	if s.sampleRate < 1 && rand.Float64() >= s.sampleRate {
+		return false, nil
+	}
+
+	now := time.Now()
+	occurredAt := now
+	if req.OccurredAt != nil && !req.OccurredAt.After(now) {
+		occurredAt = *req.OccurredAt
+	}
+
+	report := &models.ClientErrorReport{
+		ReportID:        uuid.New().String(),
+		UserID:          userID,
+		RequestID:       req.RequestID,
+		IntakeRequestID: intakeRequestID,
+		Platform:        req.Platform,
+		AppVersion:      req.AppVersion,
+		Message:         req.Message,
+		Stack:           req.Stack,
+		Context:         req.Context,
+		OccurredAt:      occurredAt,
+		ReceivedAt:      now,
+	}
+
+	if _, err := s.clientErrorRepo.Create(ctx, report); err != nil {
+		return false, err
+	}
+
+	log.Printf("Client error %s from user %s (%s %s) request_id=%s: %s",
+		report.ReportID, userID, req.Platform, req.AppVersion, req.RequestID, req.Message)
+
+	return true, nil
+}
@@ -0,0 +1,151 @@
+package services
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+)
+
+type EventType string
+
+const (
+	EventExpenseCreated    EventType = "expense.created"
+	EventExpenseUpdated    EventType = "expense.updated"
+	EventSettlementUpdated EventType = "settlement.updated"
+	EventBalancesChanged   EventType = "balances.changed"
+)
+
+// Event is a real-time update pushed to connected clients. Recipients are resolved when
+// the event is published: the group's members for group events, the people involved otherwise.
+type Event struct {
+	Type       EventType   `json:"type"`
+	GroupID    *string     `json:"group_id,omitempty"`
+	Data       interface{} `json:"data,omitempty"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Recipients []string    `json:"-"`
+}
+
+// EventPublisher receives events from the services. Publishing never blocks the caller.
+type EventPublisher interface {
+	Publish(event Event)
+}
+
+// Subscription receives the events addressed to one user until Close is called. If the
+// subscriber falls too far behind, the bus closes Events and drops the subscription.
+type Subscription struct {
+	Events <-chan Event
+	events chan Event
+	userID string
+	bus    *EventBus
+	once   sync.Once
+}
+
+func (s *Subscription) Close() {
+	s.bus.unsubscribe(s)
+}
+
+// EventBus fans events out to the subscriptions of their recipients, in process
+type EventBus struct {
+	mu          sync.RWMutex
+	subscribers map[string]map[*Subscription]struct{}
+}
+
+func NewEventBus() *EventBus {
+	return &EventBus{subscribers: make(map[string]map[*Subscription]struct{})}
+}
+
+// Subscribe registers for the user's events; buffer is how many undelivered events are kept
+func (b *EventBus) Subscribe(userID string, buffer int) *Subscription {
+	events := make(chan Event, buffer)
+	sub := &Subscription{Events: events, events: events, userID: userID, bus: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+	b.subscribers[userID][sub] = struct{}{}
+
+	return sub
+}
+
+func (b *EventBus) Publish(event Event) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	var slow []*Subscription
+	b.mu.RLock()
+	for _, userID := range event.Recipients {
+		for sub := range b.subscribers[userID] {
+			select {
+			case sub.events <- event:
+			default:
+				slow = append(slow, sub)
+			}
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range slow {
+		log.Printf("Dropping slow event subscriber for user %s", sub.userID)
+		b.unsubscribe(sub)
+	}
+}
+
+func (b *EventBus) unsubscribe(sub *Subscription) {
+	sub.once.Do(func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		delete(b.subscribers[sub.userID], sub)
+		if len(b.subscribers[sub.userID]) == 0 {
+			delete(b.subscribers, sub.userID)
+		}
+		close(sub.events)
+	})
+}
+
+// publishEvent resolves the event's recipients and publishes it. Group events go to the group's
+// active members; others go to the given users. Failures are logged, never returned.
+func publishEvent(ctx context.Context, publisher EventPublisher, groupRepo repositories.GroupRepository, event Event, involved ...string) {
+	if publisher == nil {
+		return
+	}
+
+	if event.GroupID != nil {
+		members, err := groupRepo.GetMembers(ctx, *event.GroupID)
+		if err != nil {
+			log.Printf("Failed to resolve recipients for %s event in group %s: %v", event.Type, *event.GroupID, err)
+			return
+		}
+		for _, member := range members {
+			event.Recipients = append(event.Recipients, member.UserID)
+		}
+	} else {
+		seen := make(map[string]bool, len(involved))
+		for _, userID := range involved {
+			if !seen[userID] {
+				seen[userID] = true
+				event.Recipients = append(event.Recipients, userID)
+			}
+		}
+	}
+
+	publisher.Publish(event)
+}
+
+// expenseParticipants lists the creator, payers and split participants of an expense
+func expenseParticipants(expense *models.Expense) []string {
+	users := []string{expense.CreatorID}
+	for _, payer := range expense.PaidBy {
+		users = append(users, payer.UserID)
+	}
+	for _, share := range expense.Split.Details {
+		users = append(users, share.UserID)
+	}
+	return users
+}
@@ -5,20 +5,61 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"divvydoo/backend/internal/models"
 	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+	"divvydoo/backend/pkg/locale"
 
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
+var (
+	ErrExpenseAccessDenied = utils.NewCustomError(http.StatusForbidden, utils.CodeExpenseAccessDenied, "user does not have access to this expense")
+	ErrExpenseEditDenied   = utils.NewCustomError(http.StatusForbidden, utils.CodeExpenseEditDenied, "only the creator, a payer or a group admin can edit this expense")
+	ErrInvalidSearchFilter = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidSearchFilter, "invalid search filter")
+	ErrCurrencyMismatch    = utils.NewCustomError(http.StatusBadRequest, utils.CodeCurrencyMismatch, "expense currency does not match the group currency")
+	ErrInvalidExpense      = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidExpense, "invalid expense")
+	// ErrExpenseNeedsConfirmation guards against typos like 10000 for 100.00
+	ErrExpenseNeedsConfirmation  = utils.NewCustomError(http.StatusUnprocessableEntity, utils.CodeExpenseNeedsConfirmation, "expense amount is above the soft limit")
+	ErrExpenseNotPendingApproval = utils.NewCustomError(http.StatusConflict, utils.CodeExpenseNotPendingApproval, "expense is not pending approval")
+	ErrTooManyExpenseIDs         = utils.NewCustomError(http.StatusBadRequest, utils.CodeTooManyExpenseIDs, fmt.Sprintf("at most %d expense IDs can be requested at once", MaxBatchExpenseIDs))
+)
+
+// MaxBatchExpenseIDs caps how many expenses GetExpensesByIDs loads in one call
+const MaxBatchExpenseIDs = 100
+
+const (
+	MaxExpenseDescriptionLength = 5000
+	MaxExpenseNotesLength       = 5000
+	maxLocationNameLength       = 200
+
+	// maxExpenseDateAhead is how far in the future an expense may be dated, so a client a time
+	// zone or two ahead of the server can still date an expense today
+	maxExpenseDateAhead = 24 * time.Hour
+)
+
 type ExpenseService struct {
-	expenseRepo repositories.ExpenseRepository
-	balanceRepo repositories.BalanceRepository
-	groupRepo   repositories.GroupRepository
-	userRepo    repositories.UserRepository
+	expenseRepo    repositories.ExpenseRepository
+	balanceRepo    repositories.BalanceRepository
+	groupRepo      repositories.GroupRepository
+	userRepo       repositories.UserRepository
+	revisionRepo   repositories.ExpenseRevisionRepository
+	friendshipRepo repositories.FriendshipRepository
+	outbox         *OutboxService
+	aggregates     *AggregateCache
+	rounding       *RoundingMonitor
+	softLimits     map[string]float64 // server default per currency, overridden by group settings
+	budgets        BudgetTracker
+	balanceTasks   repositories.BalanceTaskRepository
+	// asyncBalances queues balance updates for the balance worker instead of applying them
+	// with the expense
+	asyncBalances bool
 }
 
 func NewExpenseService(
@@ -26,18 +67,55 @@ func NewExpenseService(
 	balanceRepo repositories.BalanceRepository,
 	groupRepo repositories.GroupRepository,
 	userRepo repositories.UserRepository,
+	revisionRepo repositories.ExpenseRevisionRepository,
+	friendshipRepo repositories.FriendshipRepository,
+	outbox *OutboxService,
+	aggregates *AggregateCache,
+	rounding *RoundingMonitor,
+	softLimits map[string]float64,
+	budgets BudgetTracker,
+	balanceTasks repositories.BalanceTaskRepository,
+	asyncBalances bool,
 ) *ExpenseService {
 	return &ExpenseService{
-		expenseRepo: expenseRepo,
-		balanceRepo: balanceRepo,
-		groupRepo:   groupRepo,
-		userRepo:    userRepo,
+		expenseRepo:    expenseRepo,
+		balanceRepo:    balanceRepo,
+		groupRepo:      groupRepo,
+		userRepo:       userRepo,
+		revisionRepo:   revisionRepo,
+		friendshipRepo: friendshipRepo,
+		outbox:         outbox,
+		aggregates:     aggregates,
+		rounding:       rounding,
+		softLimits:     softLimits,
+		budgets:        budgets,
+		balanceTasks:   balanceTasks,
+		asyncBalances:  asyncBalances,
 	}
 }
 
-func (s *ExpenseService) CreateExpense(ctx context.Context, expense models.Expense) (*models.Expense, error) {
+// CreateExpenseRequest is an expense plus the flags that control whether it's accepted
+type CreateExpenseRequest struct {
+	models.Expense
+	// ConfirmLargeAmount accepts an amount above the group's or server's soft limit
+	ConfirmLargeAmount bool `json:"confirm_large_amount,omitempty"`
+}
+
+func (s *ExpenseService) CreateExpense(ctx context.Context, req CreateExpenseRequest) (*models.Expense, error) {
+	expense := req.Expense
+
+	// Fall back to the group's default split type
+	if expense.Split.Type == "" && expense.GroupID != nil {
+		if err := s.applyDefaultSplitType(ctx, &expense); err != nil {
+			return nil, err
+		}
+	}
+
 	// Validate the expense
-	if err := validateExpense(expense); err != nil {
+	if err := validateExpense(ctx, expense); err != nil {
+		return nil, err
+	}
+	if err := requireCreatorInvolved(expense); err != nil {
 		return nil, err
 	}
 
@@ -46,15 +124,39 @@ func (s *ExpenseService) CreateExpense(ctx context.Context, expense models.Expen
 		return nil, err
 	}
 
-	// Check group membership if it's a group expense
+	// Check group membership and currency if it's a group expense
+	var group *models.Group
 	if expense.GroupID != nil {
 		if err := s.validateGroupMembership(ctx, *expense.GroupID, expense); err != nil {
 			return nil, err
 		}
+
+		var err error
+		group, err = s.groupRepo.GetByID(ctx, *expense.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		if !group.IsActive {
+			return nil, ErrGroupArchived
+		}
+		if err := applyGroupCurrency(&expense, group); err != nil {
+			return nil, err
+		}
+		if needsApproval(expense, group) {
+			expense.Status = models.ExpenseStatusPendingApproval
+		}
+	} else if err := s.validateFriends(ctx, expense); err != nil {
+		return nil, err
+	}
+
+	if !req.ConfirmLargeAmount {
+		if err := s.checkSoftLimit(ctx, expense, group); err != nil {
+			return nil, err
+		}
 	}
 
 	// Calculate shares based on split type
-	shares, err := s.calculateShares(expense)
+	shares, err := s.calculateShares(ctx, expense)
 	if err != nil {
 		return nil, err
 	}
@@ -66,6 +168,9 @@ func (s *ExpenseService) CreateExpense(ctx context.Context, expense models.Expen
 	expense.ExpenseID = uuid.New().String()
 	expense.CreatedAt = time.Now()
 	expense.UpdatedAt = expense.CreatedAt
+	if expense.Date.IsZero() {
+		expense.Date = expense.CreatedAt
+	}
 
 	// Start MongoDB transaction
 	session, err := s.expenseRepo.StartSession()
@@ -81,215 +186,342 @@ func (s *ExpenseService) CreateExpense(ctx context.Context, expense models.Expen
 			return nil, err
 		}
 
-		// Update balances
-		if err := s.updateBalances(sessCtx, *createdExpense); err != nil {
+		// Update balances, unless the expense waits for approval
+		if createdExpense.AffectsBalances() {
+			if err := s.applyBalances(sessCtx, *createdExpense); err != nil {
+				return nil, err
+			}
+		}
+
+		if err := s.revisionRepo.Create(sessCtx, newExpenseRevision(createdExpense, models.ExpenseRevisionCreated, expense.CreatorID, nil)); err != nil {
 			return nil, err
 		}
 
-		return createdExpense, nil
+		out := s.outbox.Writer(sessCtx)
+		s.publishExpenseEvent(sessCtx, out, EventExpenseCreated, &expense)
+		if !expense.AffectsBalances() {
+			s.requestApproval(sessCtx, out, group, &expense)
+			return createdExpense, out.Err()
+		}
+		s.notifyParticipants(sessCtx, out, expense)
+		if !s.asyncBalances {
+			s.publishBalancesChanged(sessCtx, out, &expense)
+		}
+		return createdExpense, out.Err()
 	})
 
 	if err != nil {
 		return nil, fmt.Errorf("transaction failed: %v", err)
 	}
+	s.outbox.Wake()
+
+	if !expense.AffectsBalances() {
+		return &expense, nil
+	}
+	s.aggregates.EvictBalances(ctx, expenseParticipants(&expense)...)
+	if expense.GroupID != nil && s.budgets != nil {
+		s.budgets.ExpensesAdded(ctx, *expense.GroupID)
+	}
 
 	return &expense, nil
 }
 
-func validateExpense(expense models.Expense) error {
+// publishExpenseEvent pushes the expense to the group's members, or to its participants outside a group
+func (s *ExpenseService) publishExpenseEvent(ctx context.Context, publisher EventPublisher, eventType EventType, expense *models.Expense) {
+	publishEvent(ctx, publisher, s.groupRepo, Event{
+		Type:    eventType,
+		GroupID: expense.GroupID,
+		Data:    expense,
+	}, expenseParticipants(expense)...)
+}
+
+// publishBalancesChanged tells clients to refresh the balances the expense moved
+func (s *ExpenseService) publishBalancesChanged(ctx context.Context, publisher EventPublisher, expense *models.Expense) {
+	publishEvent(ctx, publisher, s.groupRepo, Event{
+		Type:    EventBalancesChanged,
+		GroupID: expense.GroupID,
+		Data:    map[string]interface{}{"expense_id": expense.ExpenseID},
+	}, expenseParticipants(expense)...)
+}
+
+// notifyParticipants tells everyone who paid for or shares in the expense, except its creator, that they were added
+func (s *ExpenseService) notifyParticipants(ctx context.Context, notifier Notifier, expense models.Expense) {
+	notified := map[string]bool{expense.CreatorID: true}
+	shares := make(map[string]float64, len(expense.Split.Details))
+	for _, share := range expense.Split.Details {
+		shares[share.UserID] += share.Value
+	}
+
+	participants := make([]string, 0, len(expense.PaidBy)+len(expense.Split.Details))
+	for _, payer := range expense.PaidBy {
+		participants = append(participants, payer.UserID)
+	}
+	for _, share := range expense.Split.Details {
+		participants = append(participants, share.UserID)
+	}
+
+	for _, userID := range participants {
+		if notified[userID] {
+			continue
+		}
+		notified[userID] = true
+
+		data := map[string]interface{}{
+			"expense_id": expense.ExpenseID,
+			"amount":     expense.Amount,
+			"currency":   expense.Currency,
+			"share":      shares[userID],
+		}
+		if expense.GroupID != nil {
+			data["group_id"] = *expense.GroupID
+		}
+
+		deliver(ctx, notifier, Notification{
+			UserID: userID,
+			Type:   models.NotificationExpenseAdded,
+			Title:  "You were added to an expense",
+			Body:   "%q (%.2f %s) includes you; your share is %.2f %s.",
+			Data:   data,
+
+			BodyArgs: []interface{}{expense.Title, expense.Amount, expense.Currency, shares[userID], expense.Currency},
+		})
+	}
+}
+
+// validateExpense checks the amounts, payers and split participants. Participants are always the
+// explicit split.details list; for equal splits their values are ignored. Errors wrap ErrInvalidExpense
+// and name the users at fault; amounts in them are written for the caller's locale.
+func validateExpense(ctx context.Context, expense models.Expense) error {
+	loc := locale.FromContext(ctx)
+
 	if expense.Amount <= 0 {
-		return errors.New("amount must be positive")
+		return invalidExpense("amount must be positive")
 	}
 
 	if len(expense.PaidBy) == 0 {
-		return errors.New("at least one payer must be specified")
+		return invalidExpense("at least one payer must be specified")
 	}
 
 	totalPaid := 0.0
+	payers := make(map[string]bool, len(expense.PaidBy))
+	var badPayers []string
 	for _, pb := range expense.PaidBy {
+		if pb.UserID == "" {
+			return invalidExpense("every payer needs a user_id")
+		}
+		if payers[pb.UserID] {
+			return invalidExpense("user %s is listed as a payer more than once", pb.UserID)
+		}
+		payers[pb.UserID] = true
 		if pb.Amount <= 0 {
-			return fmt.Errorf("invalid amount for user %s", pb.UserID)
+			badPayers = append(badPayers, pb.UserID)
 		}
 		totalPaid += pb.Amount
 	}
+	if len(badPayers) > 0 {
+		return invalidExpense("paid amounts must be positive for users %s", strings.Join(badPayers, ", "))
+	}
 
 	if math.Abs(totalPaid-expense.Amount) > 0.01 { // Allow for small floating point differences
-		return fmt.Errorf("total paid amount %.2f does not match expense amount %.2f", totalPaid, expense.Amount)
+		return utils.WithCode(invalidExpense("total paid amount %s does not match expense amount %s", loc.FormatAmount(totalPaid), loc.FormatAmount(expense.Amount)), utils.CodeExpensePaymentMismatch)
 	}
 
 	switch expense.Split.Type {
 	case models.SplitEqual, models.SplitExact, models.SplitPercentage, models.SplitShares:
 		// Valid types
 	default:
-		return fmt.Errorf("invalid split type: %s", expense.Split.Type)
+		return invalidExpense("invalid split type %q; use equal, exact, percentage or shares", expense.Split.Type)
 	}
 
-	return nil
-}
+	if len(expense.Split.Details) == 0 {
+		return invalidExpense("%s split needs at least one participant in split.details", expense.Split.Type)
+	}
+
+	participants := make(map[string]bool, len(expense.Split.Details))
+	total := 0.0
+	var badValues []string
+	for _, share := range expense.Split.Details {
+		if share.UserID == "" {
+			return invalidExpense("every split participant needs a user_id")
+		}
+		if participants[share.UserID] {
+			return invalidExpense("user %s appears more than once in split.details", share.UserID)
+		}
+		participants[share.UserID] = true
+
+		switch expense.Split.Type {
+		case models.SplitExact, models.SplitShares:
+			if share.Value <= 0 || math.IsNaN(share.Value) || math.IsInf(share.Value, 0) {
+				badValues = append(badValues, share.UserID)
+			}
+		case models.SplitPercentage:
+			if share.Value <= 0 || share.Value > 100 || math.IsNaN(share.Value) {
+				badValues = append(badValues, share.UserID)
+			}
+		}
+		total += share.Value
+	}
 
-func (s *ExpenseService) calculateShares(expense models.Expense) ([]models.SplitShare, error) {
 	switch expense.Split.Type {
-	case models.SplitEqual:
-		return s.calculateEqualShares(expense)
 	case models.SplitExact:
-		return s.calculateExactShares(expense)
+		if len(badValues) > 0 {
+			return invalidExpense("exact split amounts must be positive for users %s", strings.Join(badValues, ", "))
+		}
+		if math.Abs(total-expense.Amount) > 0.01 {
+			return utils.WithCode(invalidExpense("exact split amounts add up to %s but the expense amount is %s", loc.FormatAmount(total), loc.FormatAmount(expense.Amount)), utils.CodeExpenseSplitMismatch)
+		}
 	case models.SplitPercentage:
-		return s.calculatePercentageShares(expense)
+		if len(badValues) > 0 {
+			return invalidExpense("percentages must be between 0 and 100 for users %s", strings.Join(badValues, ", "))
+		}
+		if math.Abs(total-100.0) > 0.01 {
+			return utils.WithCode(invalidExpense("percentages add up to %s, not 100", loc.FormatAmount(total)), utils.CodeExpenseSplitMismatch)
+		}
 	case models.SplitShares:
-		return s.calculateShareBased(expense)
-	default:
-		return nil, fmt.Errorf("unsupported split type: %s", expense.Split.Type)
+		if len(badValues) > 0 {
+			return invalidExpense("share counts must be positive for users %s", strings.Join(badValues, ", "))
+		}
 	}
+
+	return validateExpenseDetails(expense)
 }
 
-func (s *ExpenseService) calculateEqualShares(expense models.Expense) ([]models.SplitShare, error) {
-	// Get all participants (unique user IDs from paid_by and split details)
-	participants := make(map[string]bool)
-	for _, pb := range expense.PaidBy {
-		participants[pb.UserID] = true
+// validateExpenseDetails checks the free-text fields, date and location, which can also be changed
+// after the expense is created
+func validateExpenseDetails(expense models.Expense) error {
+	if len(expense.Description) > MaxExpenseDescriptionLength {
+		return invalidExpense("description must be at most %d characters", MaxExpenseDescriptionLength)
 	}
-
-	// For equal split, we expect all users in the group to participate
-	// If it's a group expense, we need to get all group members
-	// For simplicity, we'll assume split.details contains all participating users
-	for _, share := range expense.Split.Details {
-		participants[share.UserID] = true
+	if len(expense.Notes) > MaxExpenseNotesLength {
+		return invalidExpense("notes must be at most %d characters", MaxExpenseNotesLength)
 	}
-
-	numParticipants := len(participants)
-	if numParticipants == 0 {
-		return nil, errors.New("no participants found for equal split")
+	if expense.Date.After(time.Now().Add(maxExpenseDateAhead)) {
+		return invalidExpense("date must not be in the future")
 	}
 
-	equalShare := expense.Amount / float64(numParticipants)
-
-	var shares []models.SplitShare
-	for userID := range participants {
-		shares = append(shares, models.SplitShare{
-			UserID: userID,
-			Value:  equalShare,
-		})
+	location := expense.Location
+	if location == nil {
+		return nil
 	}
-
-	// Handle rounding errors by adjusting the first user's share
-	if len(shares) > 0 {
-		total := equalShare * float64(numParticipants)
-		diff := expense.Amount - total
-		shares[0].Value += diff
+	if strings.TrimSpace(location.Name) == "" || len(location.Name) > maxLocationNameLength {
+		return invalidExpense("location name must be 1 to %d characters", maxLocationNameLength)
 	}
-
-	return shares, nil
+	if (location.Latitude == nil) != (location.Longitude == nil) {
+		return invalidExpense("location needs both lat and lng, or neither")
+	}
+	if location.Latitude != nil && (*location.Latitude < -90 || *location.Latitude > 90 || math.IsNaN(*location.Latitude)) {
+		return invalidExpense("location lat must be between -90 and 90")
+	}
+	if location.Longitude != nil && (*location.Longitude < -180 || *location.Longitude > 180 || math.IsNaN(*location.Longitude)) {
+		return invalidExpense("location lng must be between -180 and 180")
+	}
+	return nil
 }
 
-func (s *ExpenseService) calculateExactShares(expense models.Expense) ([]models.SplitShare, error) {
-	if len(expense.Split.Details) == 0 {
-		return nil, errors.New("exact split requires split details with specific amounts")
+// requireCreatorInvolved rejects expenses the creator neither paid for nor shares in
+func requireCreatorInvolved(expense models.Expense) error {
+	for _, pb := range expense.PaidBy {
+		if pb.UserID == expense.CreatorID {
+			return nil
+		}
 	}
-
-	// Validate that all specified amounts are positive
-	totalSpecified := 0.0
 	for _, share := range expense.Split.Details {
-		if share.Value <= 0 {
-			return nil, fmt.Errorf("invalid amount %.2f for user %s in exact split", share.Value, share.UserID)
+		if share.UserID == expense.CreatorID {
+			return nil
 		}
-		totalSpecified += share.Value
 	}
+	return invalidExpense("creator %s must be a payer or a split participant", expense.CreatorID)
+}
 
-	// Check if total specified amounts match the expense amount
-	if math.Abs(totalSpecified-expense.Amount) > 0.01 { // Allow for small floating point differences
-		return nil, fmt.Errorf("total specified amounts %.2f do not match expense amount %.2f", totalSpecified, expense.Amount)
-	}
+func invalidExpense(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidExpense, fmt.Sprintf(format, args...))
+}
 
-	// Return the exact shares as specified
+// calculateShares works out what each participant owes and records the rounding it applied. The
+// explanations are written for the caller's locale.
+func (s *ExpenseService) calculateShares(ctx context.Context, expense models.Expense) ([]models.SplitShare, error) {
 	var shares []models.SplitShare
-	for _, share := range expense.Split.Details {
-		shares = append(shares, models.SplitShare{
-			UserID: share.UserID,
-			Value:  share.Value,
-		})
+	var err error
+	switch expense.Split.Type {
+	case models.SplitEqual:
+		shares, err = s.calculateEqualShares(expense)
+	case models.SplitExact:
+		shares, err = s.calculateExactShares(expense)
+	case models.SplitPercentage:
+		shares, err = s.calculatePercentageShares(expense)
+	case models.SplitShares:
+		shares, err = s.calculateShareBased(expense)
+	default:
+		return nil, fmt.Errorf("unsupported split type: %s", expense.Split.Type)
+	}
+	if err != nil {
+		return nil, err
 	}
 
+	s.rounding.Record(expense, shares)
+	explainShares(locale.FromContext(ctx), expense, shares)
 	return shares, nil
 }
 
-func (s *ExpenseService) calculatePercentageShares(expense models.Expense) ([]models.SplitShare, error) {
-	if len(expense.Split.Details) == 0 {
-		return nil, errors.New("percentage split requires split details with percentages")
-	}
-
-	// Validate that all percentages are positive and sum to 100
-	totalPercentage := 0.0
-	for _, share := range expense.Split.Details {
-		if share.Value <= 0 || share.Value > 100 {
-			return nil, fmt.Errorf("invalid percentage %.2f for user %s", share.Value, share.UserID)
-		}
-		totalPercentage += share.Value
-	}
-
-	if math.Abs(totalPercentage-100.0) > 0.01 {
-		return nil, fmt.Errorf("total percentage %.2f does not equal 100", totalPercentage)
+// calculateEqualShares splits the amount evenly between the split participants. Cents that
+// don't divide evenly go to the first participants, one each.
+func (s *ExpenseService) calculateEqualShares(expense models.Expense) ([]models.SplitShare, error) {
+	count := len(expense.Split.Details)
+	if count == 0 {
+		return nil, invalidExpense("equal split needs at least one participant in split.details")
 	}
 
-	// Calculate actual amounts based on percentages
-	var shares []models.SplitShare
-	totalCalculated := 0.0
+	cents := int64(math.Round(expense.Amount * 100))
+	base, remainder := cents/int64(count), cents%int64(count)
 
+	shares := make([]models.SplitShare, count)
 	for i, share := range expense.Split.Details {
-		amount := (share.Value / 100.0) * expense.Amount
-
-		// Handle rounding for the last share to ensure total matches exactly
-		if i == len(expense.Split.Details)-1 {
-			amount = expense.Amount - totalCalculated
+		amount := base
+		if int64(i) < remainder {
+			amount++
 		}
-
-		shares = append(shares, models.SplitShare{
-			UserID: share.UserID,
-			Value:  amount,
-		})
-		totalCalculated += amount
+		shares[i] = models.SplitShare{UserID: share.UserID, Value: float64(amount) / 100}
 	}
 
 	return shares, nil
 }
 
-func (s *ExpenseService) calculateShareBased(expense models.Expense) ([]models.SplitShare, error) {
-	if len(expense.Split.Details) == 0 {
-		return nil, errors.New("share-based split requires split details with share counts")
-	}
+// calculateExactShares returns the amounts as given; validateExpense checked they add up
+func (s *ExpenseService) calculateExactShares(expense models.Expense) ([]models.SplitShare, error) {
+	shares := make([]models.SplitShare, len(expense.Split.Details))
+	copy(shares, expense.Split.Details)
+	return shares, nil
+}
 
-	// Calculate total shares
+func (s *ExpenseService) calculatePercentageShares(expense models.Expense) ([]models.SplitShare, error) {
+	return proportionalShares(expense, 100), nil
+}
+
+func (s *ExpenseService) calculateShareBased(expense models.Expense) ([]models.SplitShare, error) {
 	totalShares := 0.0
 	for _, share := range expense.Split.Details {
-		if share.Value <= 0 {
-			return nil, fmt.Errorf("invalid share count %.2f for user %s", share.Value, share.UserID)
-		}
 		totalShares += share.Value
 	}
-
 	if totalShares == 0 {
-		return nil, errors.New("total shares cannot be zero")
+		return nil, invalidExpense("total shares cannot be zero")
 	}
+	return proportionalShares(expense, totalShares), nil
+}
 
-	// Calculate actual amounts based on share ratios
-	var shares []models.SplitShare
-	totalCalculated := 0.0
-
+// proportionalShares splits the amount by each participant's value out of total. The last
+// participant absorbs rounding so the shares add up to the amount exactly.
+func proportionalShares(expense models.Expense, total float64) []models.SplitShare {
+	shares := make([]models.SplitShare, len(expense.Split.Details))
+	allocated := 0.0
 	for i, share := range expense.Split.Details {
-		amount := (share.Value / totalShares) * expense.Amount
-
-		// Handle rounding for the last share to ensure total matches exactly
+		amount := (share.Value / total) * expense.Amount
 		if i == len(expense.Split.Details)-1 {
-			amount = expense.Amount - totalCalculated
+			amount = expense.Amount - allocated
 		}
-
-		shares = append(shares, models.SplitShare{
-			UserID: share.UserID,
-			Value:  amount,
-		})
-		totalCalculated += amount
+		shares[i] = models.SplitShare{UserID: share.UserID, Value: amount}
+		allocated += amount
 	}
-
-	return shares, nil
+	return shares
 }
 
 func (s *ExpenseService) validateUsersExist(ctx context.Context, expense models.Expense) error {
@@ -315,7 +547,8 @@ func (s *ExpenseService) validateUsersExist(ctx context.Context, expense models.
 		return fmt.Errorf("failed to check user existence: %v", err)
 	}
 	if len(missingUsers) > 0 {
-		return fmt.Errorf("user %s does not exist", missingUsers[0])
+		sort.Strings(missingUsers)
+		return invalidExpense("users %s do not exist", strings.Join(missingUsers, ", "))
 	}
 
 	return nil
@@ -344,42 +577,341 @@ func (s *ExpenseService) validateGroupMembership(ctx context.Context, groupID st
 		return fmt.Errorf("failed to check group membership: %v", err)
 	}
 	if len(nonMembers) > 0 {
-		return fmt.Errorf("user %s is not a member of group %s", nonMembers[0], groupID)
+		sort.Strings(nonMembers)
+		return invalidExpense("users %s are not members of group %s", strings.Join(nonMembers, ", "), groupID)
 	}
 
 	return nil
 }
 
+// validateFriends requires everyone on an expense outside a group to be a friend of its creator
+func (s *ExpenseService) validateFriends(ctx context.Context, expense models.Expense) error {
+	friendIDs, err := s.friendshipRepo.GetFriendIDs(ctx, expense.CreatorID)
+	if err != nil {
+		return fmt.Errorf("failed to check friendships: %v", err)
+	}
+	friends := make(map[string]bool, len(friendIDs)+1)
+	friends[expense.CreatorID] = true
+	for _, id := range friendIDs {
+		friends[id] = true
+	}
+
+	strangerSet := make(map[string]bool)
+	for _, pb := range expense.PaidBy {
+		if !friends[pb.UserID] {
+			strangerSet[pb.UserID] = true
+		}
+	}
+	for _, share := range expense.Split.Details {
+		if !friends[share.UserID] {
+			strangerSet[share.UserID] = true
+		}
+	}
+	if len(strangerSet) > 0 {
+		strangers := make([]string, 0, len(strangerSet))
+		for id := range strangerSet {
+			strangers = append(strangers, id)
+		}
+		sort.Strings(strangers)
+		return invalidExpense("users %s are not your friends; expenses outside a group can only be shared with friends", strings.Join(strangers, ", "))
+	}
+
+	return nil
+}
+
+// applyGroupCurrency defaults the expense to the group's currency and rejects other currencies
+// unless the group has multi-currency mode on
+func applyGroupCurrency(expense *models.Expense, group *models.Group) error {
+	expense.Currency = strings.ToUpper(strings.TrimSpace(expense.Currency))
+	if expense.Currency == "" {
+		expense.Currency = group.Currency
+		return nil
+	}
+	if !strings.EqualFold(expense.Currency, group.Currency) && !group.Settings.MultiCurrency {
+		return fmt.Errorf("%w: expense is in %s but %s uses %s; enable multi-currency for the group to allow it",
+			ErrCurrencyMismatch, expense.Currency, group.Name, group.Currency)
+	}
+	return nil
+}
+
+// checkSoftLimit rejects amounts above the limit for the expense's currency, taking the
+// group's own limit over the server default. A limit of 0 turns the check off.
+func (s *ExpenseService) checkSoftLimit(ctx context.Context, expense models.Expense, group *models.Group) error {
+	currency := strings.ToUpper(strings.TrimSpace(expense.Currency))
+	limit := s.softLimits[currency]
+	if group != nil {
+		if groupLimit, ok := group.Settings.ExpenseSoftLimits[currency]; ok {
+			limit = groupLimit
+		}
+	}
+
+	if limit <= 0 || expense.Amount <= limit {
+		return nil
+	}
+	loc := locale.FromContext(ctx)
+	return fmt.Errorf("%w: %s is more than %s; resend with confirm_large_amount set to true if the amount is correct",
+		ErrExpenseNeedsConfirmation, loc.FormatMoney(expense.Amount, currency), loc.FormatMoney(limit, currency))
+}
+
 func (s *ExpenseService) GetExpense(ctx context.Context, expenseID string, userID string) (*models.Expense, error) {
 	expense, err := s.expenseRepo.GetByID(ctx, expenseID)
 	if err != nil {
 		return nil, err
 	}
 
-	// Check if user has access to this expense
-	hasAccess := expense.CreatorID == userID
-	if !hasAccess {
-		for _, pb := range expense.PaidBy {
-			if pb.UserID == userID {
-				hasAccess = true
-				break
-			}
+	canView, err := s.canViewExpense(ctx, expense, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canView {
+		return nil, ErrExpenseAccessDenied
+	}
+
+	return expense, nil
+}
+
+type BatchGetExpensesRequest struct {
+	ExpenseIDs []string `json:"expense_ids" binding:"required,min=1"`
+}
+
+// GetExpensesByIDs loads the requested expenses in request order, leaving out the ones that
+// don't exist and the ones the user can't view, without saying which was which
+func (s *ExpenseService) GetExpensesByIDs(ctx context.Context, userID string, expenseIDs []string) ([]*models.Expense, error) {
+	seen := make(map[string]bool, len(expenseIDs))
+	unique := make([]string, 0, len(expenseIDs))
+	for _, id := range expenseIDs {
+		if id != "" && !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
 		}
 	}
-	if !hasAccess {
-		for _, share := range expense.Split.Details {
-			if share.UserID == userID {
-				hasAccess = true
-				break
+	if len(unique) > MaxBatchExpenseIDs {
+		return nil, ErrTooManyExpenseIDs
+	}
+
+	found, err := s.expenseRepo.GetByIDs(ctx, unique)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Expense, len(found))
+	for _, expense := range found {
+		byID[expense.ExpenseID] = expense
+	}
+
+	// Most expenses in a feed share a few groups, so each membership is checked once
+	membership := make(map[string]bool)
+	expenses := make([]*models.Expense, 0, len(found))
+	for _, id := range unique {
+		expense, ok := byID[id]
+		if !ok {
+			continue
+		}
+
+		visible := isExpenseParticipant(expense, userID)
+		if !visible && expense.GroupID != nil {
+			member, checked := membership[*expense.GroupID]
+			if !checked {
+				if member, err = s.groupRepo.IsMember(ctx, *expense.GroupID, userID); err != nil {
+					return nil, err
+				}
+				membership[*expense.GroupID] = member
 			}
+			visible = member
+		}
+
+		if visible {
+			expenses = append(expenses, expense)
+		}
+	}
+
+	return expenses, nil
+}
+
+// UpdateExpenseRequest changes an expense's descriptive fields. Amounts and splits
+// can't be changed in place because the balances derived from them are already applied.
+// A location with an empty name removes the expense's location.
+type UpdateExpenseRequest struct {
+	Title       *string                 `json:"title,omitempty" binding:"omitempty,min=1,max=200"`
+	Description *string                 `json:"description,omitempty" binding:"omitempty,max=5000"`
+	Notes       *string                 `json:"notes,omitempty" binding:"omitempty,max=5000"`
+	Category    *string                 `json:"category,omitempty" binding:"omitempty,max=50"`
+	Location    *models.ExpenseLocation `json:"location,omitempty"`
+	Date        *time.Time              `json:"date,omitempty"`
+}
+
+func (s *ExpenseService) UpdateExpense(ctx context.Context, expenseID string, userID string, req UpdateExpenseRequest) (*models.Expense, error) {
+	expense, err := s.expenseRepo.GetByID(ctx, expenseID)
+	if err != nil {
+		return nil, err
+	}
+
+	canEdit, err := s.canEditExpense(ctx, expense, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !canEdit {
+		// Don't reveal the expense to users who can't see it either
+		canView, err := s.canViewExpense(ctx, expense, userID)
+		if err != nil {
+			return nil, err
+		}
+		if !canView {
+			return nil, ErrExpenseAccessDenied
+		}
+		return nil, ErrExpenseEditDenied
+	}
+
+	before := *expense
+	if req.Title != nil {
+		expense.Title = *req.Title
+	}
+	if req.Description != nil {
+		expense.Description = *req.Description
+	}
+	if req.Notes != nil {
+		expense.Notes = *req.Notes
+	}
+	if req.Category != nil {
+		expense.Category = *req.Category
+	}
+	if req.Date != nil {
+		// Stored with millisecond precision in UTC, like it comes back from the database
+		expense.Date = req.Date.UTC().Truncate(time.Millisecond)
+	}
+	if req.Location != nil {
+		expense.Location = req.Location
+		if req.Location.Name == "" && req.Location.Latitude == nil && req.Location.Longitude == nil {
+			expense.Location = nil
 		}
 	}
+	if err := validateExpenseDetails(*expense); err != nil {
+		return nil, err
+	}
 
-	if !hasAccess {
-		return nil, fmt.Errorf("user does not have access to this expense")
+	changes := expenseChanges(&before, expense)
+	if len(changes) == 0 {
+		return expense, nil
 	}
 
-	return expense, nil
+	session, err := s.expenseRepo.StartSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		updated, err := s.expenseRepo.Update(sessCtx, expense)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.revisionRepo.Create(sessCtx, newExpenseRevision(updated, models.ExpenseRevisionUpdated, userID, changes)); err != nil {
+			return nil, err
+		}
+
+		out := s.outbox.Writer(sessCtx)
+		s.publishExpenseEvent(sessCtx, out, EventExpenseUpdated, updated)
+		return updated, out.Err()
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrExpenseNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("transaction failed: %v", err)
+	}
+	s.outbox.Wake()
+
+	return result.(*models.Expense), nil
+}
+
+// SearchExpenses finds expenses visible to the user that match the filter. Searching
+// within a group requires membership of that group.
+func (s *ExpenseService) SearchExpenses(ctx context.Context, userID string, filter repositories.ExpenseSearchFilter) ([]*models.Expense, bool, error) {
+	switch filter.SortField {
+	case "", "date", "created_at", "amount":
+	default:
+		return nil, false, fmt.Errorf("%w: cannot sort by %q", ErrInvalidSearchFilter, filter.SortField)
+	}
+	if filter.From != nil && filter.To != nil && !filter.From.Before(*filter.To) {
+		return nil, false, fmt.Errorf("%w: from must be before to", ErrInvalidSearchFilter)
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return nil, false, fmt.Errorf("%w: min_amount must not exceed max_amount", ErrInvalidSearchFilter)
+	}
+	if filter.Status != nil {
+		switch models.ExpenseStatus(*filter.Status) {
+		case models.ExpenseStatusPendingApproval, models.ExpenseStatusApproved, models.ExpenseStatusRejected:
+		default:
+			return nil, false, fmt.Errorf("%w: status must be pending_approval, approved or rejected", ErrInvalidSearchFilter)
+		}
+	}
+
+	if filter.GroupID != nil {
+		isMember, err := s.groupRepo.IsMember(ctx, *filter.GroupID, userID)
+		if err != nil {
+			return nil, false, err
+		}
+		if !isMember {
+			return nil, false, ErrNotGroupMember
+		}
+	}
+
+	groups, err := s.groupRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, false, err
+	}
+
+	filter.UserID = userID
+	filter.VisibleGroupIDs = make([]string, 0, len(groups))
+	for _, group := range groups {
+		filter.VisibleGroupIDs = append(filter.VisibleGroupIDs, group.GroupID)
+	}
+
+	return s.expenseRepo.Search(ctx, filter)
+}
+
+// canViewExpense grants read access to the expense's participants and, for group
+// expenses, to every active member of the group.
+func (s *ExpenseService) canViewExpense(ctx context.Context, expense *models.Expense, userID string) (bool, error) {
+	if isExpenseParticipant(expense, userID) {
+		return true, nil
+	}
+	if expense.GroupID == nil {
+		return false, nil
+	}
+	return s.groupRepo.IsMember(ctx, *expense.GroupID, userID)
+}
+
+// canEditExpense limits changes to the creator, the payers and admins of the expense's group
+func (s *ExpenseService) canEditExpense(ctx context.Context, expense *models.Expense, userID string) (bool, error) {
+	if expense.CreatorID == userID {
+		return true, nil
+	}
+	for _, pb := range expense.PaidBy {
+		if pb.UserID == userID {
+			return true, nil
+		}
+	}
+	if expense.GroupID == nil {
+		return false, nil
+	}
+	return s.groupRepo.IsAdmin(ctx, *expense.GroupID, userID)
+}
+
+func isExpenseParticipant(expense *models.Expense, userID string) bool {
+	if expense.CreatorID == userID {
+		return true
+	}
+	for _, pb := range expense.PaidBy {
+		if pb.UserID == userID {
+			return true
+		}
+	}
+	for _, share := range expense.Split.Details {
+		if share.UserID == userID {
+			return true
+		}
+	}
+	return false
 }
 
 func (s *ExpenseService) GetGroupExpenses(ctx context.Context, groupID string, limit, offset int64) ([]*models.Expense, error) {
@@ -390,29 +922,108 @@ func (s *ExpenseService) GetUserExpenses(ctx context.Context, userID string, lim
 	return s.expenseRepo.GetByUserID(ctx, userID, limit, offset)
 }
 
+// ListGroupExpenses returns a page of group expenses, newest first, and the cursor for the next page.
+// A non-empty query does a full-text search over titles and descriptions.
+func (s *ExpenseService) ListGroupExpenses(ctx context.Context, groupID string, userID string, query string, cursor string, limit, offset int64) ([]*models.Expense, string, error) {
+	if err := requireGroupMember(ctx, s.groupRepo, groupID, userID); err != nil {
+		return nil, "", err
+	}
+
+	position, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.expenseRepo.ListByGroupID(ctx, groupID, query, position, limit, offset)
+}
+
+// ListUserExpenses returns a page of the user's expenses, newest first, and the cursor for the next page
+func (s *ExpenseService) ListUserExpenses(ctx context.Context, userID string, cursor string, limit, offset int64) ([]*models.Expense, string, error) {
+	position, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.expenseRepo.ListByUserID(ctx, userID, position, limit, offset)
+}
+
 func (s *ExpenseService) updateBalances(ctx context.Context, expense models.Expense) error {
-	// For each user in the split, update their balance
+	for _, change := range expenseBalanceChanges(expense) {
+		if err := s.balanceRepo.UpdateBalance(ctx, change.userID, expense.GroupID, expense.Currency, change.amount); err != nil {
+			return err
+		}
+	}
+	if expense.GroupID == nil {
+		return s.updateDirectBalances(ctx, expense)
+	}
+	return nil
+}
+
+type balanceChange struct {
+	userID string
+	amount float64
+}
+
+// expenseBalanceChanges is what the expense moves each participant's balance by: what they paid
+// less their share. Participants are listed payers first, in the order they appear.
+func expenseBalanceChanges(expense models.Expense) []balanceChange {
+	net := make(map[string]float64)
+	var order []string
+	add := func(userID string, amount float64) {
+		if _, ok := net[userID]; !ok {
+			order = append(order, userID)
+		}
+		net[userID] += amount
+	}
+	for _, pb := range expense.PaidBy {
+		add(pb.UserID, pb.Amount)
+	}
+	for _, share := range expense.Split.Details {
+		add(share.UserID, -share.Value)
+	}
+
+	changes := make([]balanceChange, 0, len(order))
+	for _, userID := range order {
+		if net[userID] != 0 {
+			changes = append(changes, balanceChange{userID: userID, amount: net[userID]})
+		}
+	}
+	return changes
+}
+
+// updateDirectBalances records what each participant of an expense outside any group owes each
+// payer, split between the payers in proportion to what they paid
+func (s *ExpenseService) updateDirectBalances(ctx context.Context, expense models.Expense) error {
+	var paid float64
+	for _, pb := range expense.PaidBy {
+		paid += pb.Amount
+	}
+	if paid <= 0 {
+		return nil
+	}
+
 	for _, share := range expense.Split.Details {
-		// For each payer, reduce what they owe by what they paid
 		for _, pb := range expense.PaidBy {
-			if pb.UserID == share.UserID {
-				// This user paid some amount and owes some amount
-				netChange := pb.Amount - share.Value
-				if err := s.balanceRepo.UpdateBalance(ctx, pb.UserID, expense.GroupID, netChange); err != nil {
-					return err
-				}
-			} else {
-				// Other users owe the payer
-				if share.Value > 0 {
-					if err := s.balanceRepo.UpdateBalance(ctx, share.UserID, expense.GroupID, -share.Value); err != nil {
-						return err
-					}
-					if err := s.balanceRepo.UpdateBalance(ctx, pb.UserID, expense.GroupID, share.Value); err != nil {
-						return err
-					}
-				}
+			if pb.UserID == share.UserID || share.Value <= 0 || pb.Amount <= 0 {
+				continue
 			}
+			owed := roundCents(share.Value * pb.Amount / paid)
+			if err := s.balanceRepo.UpdateDirectBalance(ctx, share.UserID, pb.UserID, expense.Currency, owed); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyDefaultSplitType sets the split type from the expense's group settings. Unknown groups
+// are left for validateGroupMembership to report.
+func (s *ExpenseService) applyDefaultSplitType(ctx context.Context, expense *models.Expense) error {
+	group, err := s.groupRepo.GetByID(ctx, *expense.GroupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrGroupNotFound) {
+			return nil
 		}
+		return err
 	}
+	expense.Split.Type = group.Settings.DefaultSplitType
 	return nil
 }
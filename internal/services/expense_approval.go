@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RejectExpenseRequest says why an admin rejected an expense
+type RejectExpenseRequest struct {
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// ApproveExpense applies an expense pending approval to balances. Only admins of the expense's
+// group can approve it; approving an approved expense returns it unchanged.
+func (s *ExpenseService) ApproveExpense(ctx context.Context, expenseID string, userID string) (*models.Expense, error) {
+	expense, group, err := s.expenseForReview(ctx, expenseID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch expense.Status {
+	case models.ExpenseStatusPendingApproval:
+	case models.ExpenseStatusApproved:
+		return expense, nil
+	default:
+		return nil, ErrExpenseNotPendingApproval
+	}
+	if !group.IsActive {
+		return nil, ErrGroupArchived
+	}
+
+	approved, err := s.review(ctx, expense, models.ExpenseStatusApproved, userID, nil, func(ctx context.Context, out *OutboxWriter, approved *models.Expense) {
+		if approved.CreatorID != userID {
+			deliver(ctx, out, Notification{
+				UserID: approved.CreatorID,
+				Type:   models.NotificationExpenseApproved,
+				Title:  "Expense approved",
+				Body:   "%q (%.2f %s) in %s was approved.",
+				Data:   approvalNotificationData(approved),
+
+				BodyArgs: []interface{}{approved.Title, approved.Amount, approved.Currency, group.Name},
+			})
+		}
+		s.notifyParticipants(ctx, out, *approved)
+		s.publishExpenseEvent(ctx, out, EventExpenseUpdated, approved)
+		if !s.asyncBalances {
+			s.publishBalancesChanged(ctx, out, approved)
+		}
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrExpenseStateChanged) {
+			return s.resolveReview(ctx, expenseID, models.ExpenseStatusApproved)
+		}
+		return nil, err
+	}
+
+	s.aggregates.EvictBalances(ctx, expenseParticipants(approved)...)
+	if s.budgets != nil {
+		s.budgets.ExpensesAdded(ctx, group.GroupID)
+	}
+
+	return approved, nil
+}
+
+// RejectExpense turns down an expense pending approval, so it never moves a balance. Only admins
+// of the expense's group can reject it; rejecting a rejected expense returns it unchanged.
+func (s *ExpenseService) RejectExpense(ctx context.Context, expenseID string, userID string, reason string) (*models.Expense, error) {
+	expense, group, err := s.expenseForReview(ctx, expenseID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch expense.Status {
+	case models.ExpenseStatusPendingApproval:
+	case models.ExpenseStatusRejected:
+		return expense, nil
+	default:
+		return nil, ErrExpenseNotPendingApproval
+	}
+
+	rejected, err := s.review(ctx, expense, models.ExpenseStatusRejected, userID, &reason, func(ctx context.Context, out *OutboxWriter, rejected *models.Expense) {
+		if rejected.CreatorID != userID {
+			data := approvalNotificationData(rejected)
+			data["reason"] = reason
+			deliver(ctx, out, Notification{
+				UserID: rejected.CreatorID,
+				Type:   models.NotificationExpenseRejected,
+				Title:  "Expense rejected",
+				Body:   "%q (%.2f %s) in %s was rejected: %s",
+				Data:   data,
+
+				BodyArgs: []interface{}{rejected.Title, rejected.Amount, rejected.Currency, group.Name, reason},
+			})
+		}
+		s.publishExpenseEvent(ctx, out, EventExpenseUpdated, rejected)
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrExpenseStateChanged) {
+			return s.resolveReview(ctx, expenseID, models.ExpenseStatusRejected)
+		}
+		return nil, err
+	}
+
+	return rejected, nil
+}
+
+// expenseForReview loads the expense and its group for an admin of that group. Other users who
+// can see the expense get ErrNotGroupAdmin.
+func (s *ExpenseService) expenseForReview(ctx context.Context, expenseID string, userID string) (*models.Expense, *models.Group, error) {
+	expense, err := s.GetExpense(ctx, expenseID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if expense.GroupID == nil {
+		return nil, nil, ErrExpenseNotPendingApproval
+	}
+
+	group, err := requireGroupAdmin(ctx, s.groupRepo, *expense.GroupID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return expense, group, nil
+}
+
+// review records the decision and its revision and, for approvals, applies the expense to
+// balances, all in one transaction. effects records the notifications and events for the
+// reviewed expense in the same transaction; the reviewed expense is returned.
+func (s *ExpenseService) review(ctx context.Context, expense *models.Expense, status models.ExpenseStatus, userID string, reason *string, effects func(ctx context.Context, out *OutboxWriter, reviewed *models.Expense)) (*models.Expense, error) {
+	reviewed := *expense
+	reviewed.Status = status
+	reviewed.RejectReason = reason
+	changes := expenseChanges(expense, &reviewed)
+
+	session, err := s.expenseRepo.StartSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	action := models.ExpenseRevisionApproved
+	if status == models.ExpenseStatusRejected {
+		action = models.ExpenseRevisionRejected
+	}
+
+	result, err := session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if err := s.expenseRepo.MarkReviewed(sessCtx, expense.ExpenseID, status, userID, reason); err != nil {
+			return nil, err
+		}
+		if reviewed.AffectsBalances() {
+			if err := s.applyBalances(sessCtx, reviewed); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.revisionRepo.Create(sessCtx, newExpenseRevision(&reviewed, action, userID, changes)); err != nil {
+			return nil, err
+		}
+
+		current, err := s.expenseRepo.GetByID(sessCtx, expense.ExpenseID)
+		if err != nil {
+			return nil, err
+		}
+		out := s.outbox.Writer(sessCtx)
+		effects(sessCtx, out, current)
+		return current, out.Err()
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrExpenseStateChanged) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("transaction failed: %v", err)
+	}
+	s.outbox.Wake()
+	return result.(*models.Expense), nil
+}
+
+// resolveReview handles a lost review race: when a concurrent request already made the same
+// decision, the current expense is returned
+func (s *ExpenseService) resolveReview(ctx context.Context, expenseID string, expected models.ExpenseStatus) (*models.Expense, error) {
+	current, err := s.expenseRepo.GetByID(ctx, expenseID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Status == expected {
+		return current, nil
+	}
+	return nil, ErrExpenseNotPendingApproval
+}
+
+// requestApproval asks the admins of the expense's group to review it
+func (s *ExpenseService) requestApproval(ctx context.Context, notifier Notifier, group *models.Group, expense *models.Expense) {
+	notifyGroupAdmins(ctx, notifier, group, Notification{
+		Type:  models.NotificationExpenseNeedsApproval,
+		Title: "An expense needs your approval",
+		Body:  "%q (%.2f %s) in %s is above the group's approval threshold of %.2f.",
+		Data:  approvalNotificationData(expense),
+
+		BodyArgs: []interface{}{expense.Title, expense.Amount, expense.Currency, group.Name, group.Settings.ExpenseApprovalThreshold},
+	})
+}
+
+// notifyGroupAdmins sends the notification to each of the group's active admins
+func notifyGroupAdmins(ctx context.Context, notifier Notifier, group *models.Group, notification Notification) {
+	for _, member := range group.Members {
+		if !member.IsActive || member.Role != models.RoleAdmin {
+			continue
+		}
+		notification.UserID = member.UserID
+		deliver(ctx, notifier, notification)
+	}
+}
+
+func approvalNotificationData(expense *models.Expense) map[string]interface{} {
+	data := map[string]interface{}{
+		"expense_id": expense.ExpenseID,
+		"amount":     expense.Amount,
+		"currency":   expense.Currency,
+	}
+	if expense.GroupID != nil {
+		data["group_id"] = *expense.GroupID
+	}
+	return data
+}
+
+// needsApproval reports whether the expense is above the group's approval threshold and its
+// creator isn't a group admin
+func needsApproval(expense models.Expense, group *models.Group) bool {
+	threshold := group.Settings.ExpenseApprovalThreshold
+	if threshold <= 0 || expense.Amount <= threshold {
+		return false
+	}
+	member := activeMember(group, expense.CreatorID)
+	return member == nil || member.Role != models.RoleAdmin
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+)
+
+// csvFlushEvery bounds how many rows are buffered before they are written to the client
+const csvFlushEvery = 200
+
+// ExpenseExport is a prepared export of a group's expenses. Preparing it checks access
+// and resolves the member columns up front, so errors can still be reported normally
+// before any output is written.
+type ExpenseExport struct {
+	GroupID   string
+	GroupName string
+
+	expenseRepo repositories.ExpenseRepository
+	members     []exportMember
+}
+
+type exportMember struct {
+	userID string
+	name   string
+}
+
+func (s *ExpenseService) PrepareGroupExpenseExport(ctx context.Context, groupID string, userID string) (*ExpenseExport, error) {
+	if err := requireGroupMember(ctx, s.groupRepo, groupID, userID); err != nil {
+		return nil, err
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrGroupNotFound) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, err
+	}
+
+	// Include former members: their shares are still part of the group's history
+	memberIDs := make([]string, 0, len(group.Members))
+	for _, member := range group.Members {
+		memberIDs = append(memberIDs, member.UserID)
+	}
+
+	users, err := s.userRepo.GetByIDs(ctx, memberIDs)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(users))
+	for _, user := range users {
+		names[user.UserID] = user.Name
+	}
+
+	members := make([]exportMember, 0, len(memberIDs))
+	for _, id := range memberIDs {
+		name := names[id]
+		if name == "" {
+			name = id
+		}
+		members = append(members, exportMember{userID: id, name: name})
+	}
+
+	return &ExpenseExport{
+		GroupID:     group.GroupID,
+		GroupName:   group.Name,
+		expenseRepo: s.expenseRepo,
+		members:     members,
+	}, nil
+}
+
+// WriteCSV streams the export as CSV: one row per expense with a share column per member
+func (e *ExpenseExport) WriteCSV(ctx context.Context, w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"date", "expense_id", "title", "description", "category", "amount", "currency", "paid_by", "split_type", "status"}
+	for _, member := range e.members {
+		header = append(header, member.name+" share")
+	}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	rows := 0
+	err := e.expenseRepo.ForEachByGroupID(ctx, e.GroupID, func(expense *models.Expense) error {
+		if err := writer.Write(e.row(expense)); err != nil {
+			return err
+		}
+
+		rows++
+		if rows%csvFlushEvery == 0 {
+			writer.Flush()
+			return writer.Error()
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to export expenses: %w", err)
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func (e *ExpenseExport) row(expense *models.Expense) []string {
+	payers := make([]string, 0, len(expense.PaidBy))
+	for _, pb := range expense.PaidBy {
+		payers = append(payers, fmt.Sprintf("%s:%s", e.memberName(pb.UserID), formatAmount(pb.Amount)))
+	}
+
+	shares := make(map[string]float64, len(expense.Split.Details))
+	for _, share := range expense.Split.Details {
+		shares[share.UserID] += share.Value
+	}
+
+	row := []string{
+		expense.Date.UTC().Format(time.RFC3339),
+		expense.ExpenseID,
+		expense.Title,
+		expense.Description,
+		expense.Category,
+		formatAmount(expense.Amount),
+		expense.Currency,
+		strings.Join(payers, "; "),
+		string(expense.Split.Type),
+		string(expense.Status),
+	}
+	for _, member := range e.members {
+		if share, ok := shares[member.userID]; ok {
+			row = append(row, formatAmount(share))
+		} else {
+			row = append(row, "")
+		}
+	}
+
+	return row
+}
+
+func (e *ExpenseExport) memberName(userID string) string {
+	for _, member := range e.members {
+		if member.userID == userID {
+			return member.name
+		}
+	}
+	return userID
+}
+
+func formatAmount(amount float64) string {
+	return strconv.FormatFloat(amount, 'f', 2, 64)
+}
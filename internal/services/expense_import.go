@@ -0,0 +1,307 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// MaxExpenseImportRows bounds a CSV import so it fits in a single transaction
+const MaxExpenseImportRows = 1000
+
+// expenseImportSplitPrefix marks split columns, e.g. "split:alice@example.com"
+const expenseImportSplitPrefix = "split:"
+
+type ExpenseImportRowError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// ExpenseImportResult reports the expenses created, how many of them wait for approval, and why
+// the other rows were skipped
+type ExpenseImportResult struct {
+	Created         int                     `json:"created"`
+	PendingApproval int                     `json:"pending_approval,omitempty"`
+	Expenses        []*models.Expense       `json:"expenses"`
+	Errors          []ExpenseImportRowError `json:"errors"`
+}
+
+// expenseImportColumns maps column names to their index in the CSV header
+type expenseImportColumns struct {
+	index map[string]int
+	split []expenseImportSplitColumn
+}
+
+type expenseImportSplitColumn struct {
+	index    int
+	memberID string
+}
+
+func (c expenseImportColumns) value(record []string, name string) string {
+	i, ok := c.index[name]
+	if !ok || i >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[i])
+}
+
+// ImportGroupExpenses creates group expenses from a CSV with title, amount and payer columns, optional
+// currency, date, category, description and split_type columns, and one "split:<member>" column per
+// participant, where members are given by email or user ID. Split values are amounts, percentages or
+// share counts depending on split_type; for equal splits (the default) any non-zero value marks a
+// participant, and rows without split values are split equally among all members. Rows that fail
+// validation are reported and skipped; the rest are created together in one transaction.
+func (s *ExpenseService) ImportGroupExpenses(ctx context.Context, groupID string, userID string, data io.Reader) (*ExpenseImportResult, error) {
+	if err := requireGroupMember(ctx, s.groupRepo, groupID, userID); err != nil {
+		return nil, err
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrGroupNotFound) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, err
+	}
+	if !group.IsActive {
+		return nil, ErrGroupArchived
+	}
+
+	members, err := s.groupRepo.GetMembersWithDetails(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	memberIDs := make(map[string]string, len(members)*2) // email or user ID -> user ID
+	allMembers := make([]string, 0, len(members))
+	for _, member := range members {
+		memberIDs[member.UserID] = member.UserID
+		if member.Email != "" {
+			memberIDs[strings.ToLower(member.Email)] = member.UserID
+		}
+		allMembers = append(allMembers, member.UserID)
+	}
+	resolveMember := func(ref string) (string, bool) {
+		id, ok := memberIDs[strings.ToLower(strings.TrimSpace(ref))]
+		return id, ok
+	}
+
+	reader := csv.NewReader(data)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing CSV header", ErrInvalidImport)
+	}
+	columns := expenseImportColumns{index: make(map[string]int)}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if strings.HasPrefix(name, expenseImportSplitPrefix) {
+			ref := strings.TrimPrefix(name, expenseImportSplitPrefix)
+			memberID, ok := resolveMember(ref)
+			if !ok {
+				return nil, fmt.Errorf("%w: split column %q is not a group member", ErrInvalidImport, ref)
+			}
+			columns.split = append(columns.split, expenseImportSplitColumn{index: i, memberID: memberID})
+			continue
+		}
+		columns.index[name] = i
+	}
+	for _, required := range []string{"title", "amount", "payer"} {
+		if _, ok := columns.index[required]; !ok {
+			return nil, fmt.Errorf("%w: missing %s column", ErrInvalidImport, required)
+		}
+	}
+
+	result := &ExpenseImportResult{Expenses: []*models.Expense{}, Errors: []ExpenseImportRowError{}}
+	var expenses []models.Expense
+
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: row %d: %v", ErrInvalidImport, row, err)
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+		if len(expenses)+len(result.Errors) >= MaxExpenseImportRows {
+			return nil, fmt.Errorf("%w: at most %d rows can be imported at once", ErrInvalidImport, MaxExpenseImportRows)
+		}
+
+		expense, err := s.importedExpenseRow(ctx, record, columns, group, userID, allMembers, resolveMember)
+		if err != nil {
+			result.Errors = append(result.Errors, ExpenseImportRowError{Row: row, Error: err.Error()})
+			continue
+		}
+		if needsApproval(expense, group) {
+			expense.Status = models.ExpenseStatusPendingApproval
+			result.PendingApproval++
+		}
+		expenses = append(expenses, expense)
+	}
+
+	if len(expenses) == 0 {
+		return result, nil
+	}
+
+	session, err := s.expenseRepo.StartSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		if err := s.expenseRepo.InsertMany(sessCtx, expenses); err != nil {
+			return nil, err
+		}
+		revisions := make([]*models.ExpenseRevision, 0, len(expenses))
+		for i, expense := range expenses {
+			if expense.AffectsBalances() {
+				if err := s.applyBalances(sessCtx, expense); err != nil {
+					return nil, err
+				}
+			}
+			revisions = append(revisions, newExpenseRevision(&expenses[i], models.ExpenseRevisionImported, userID, nil))
+		}
+		if err := s.revisionRepo.InsertMany(sessCtx, revisions); err != nil {
+			return nil, err
+		}
+
+		// The members are already known, so publish directly rather than looking them up per expense
+		out := s.outbox.Writer(sessCtx)
+		for i := range expenses {
+			out.Publish(Event{Type: EventExpenseCreated, GroupID: &groupID, Data: &expenses[i], Recipients: allMembers})
+		}
+		if !s.asyncBalances {
+			out.Publish(Event{
+				Type:       EventBalancesChanged,
+				GroupID:    &groupID,
+				Data:       map[string]interface{}{"imported": len(expenses)},
+				Recipients: allMembers,
+			})
+		}
+		if result.PendingApproval > 0 {
+			notifyGroupAdmins(sessCtx, out, group, Notification{
+				Type:  models.NotificationExpenseNeedsApproval,
+				Title: "Imported expenses need your approval",
+				Body:  "%d imported expenses in %s are above the group's approval threshold of %.2f.",
+				Data:  map[string]interface{}{"group_id": groupID, "count": result.PendingApproval},
+
+				BodyArgs: []interface{}{result.PendingApproval, group.Name, group.Settings.ExpenseApprovalThreshold},
+			})
+		}
+		return nil, out.Err()
+	})
+	if err != nil {
+		return nil, fmt.Errorf("transaction failed: %v", err)
+	}
+	s.outbox.Wake()
+
+	for i := range expenses {
+		result.Expenses = append(result.Expenses, &expenses[i])
+	}
+	result.Created = len(expenses)
+
+	s.aggregates.EvictBalances(ctx, allMembers...)
+	if s.budgets != nil {
+		s.budgets.ExpensesAdded(ctx, groupID)
+	}
+
+	return result, nil
+}
+
+// importedExpenseRow builds and validates one expense the same way CreateExpense does
+func (s *ExpenseService) importedExpenseRow(ctx context.Context, record []string, columns expenseImportColumns, group *models.Group, creatorID string, allMembers []string, resolveMember func(string) (string, bool)) (models.Expense, error) {
+	groupID := group.GroupID
+	expense := models.Expense{
+		ExpenseID:   uuid.New().String(),
+		GroupID:     &groupID,
+		CreatorID:   creatorID,
+		Title:       columns.value(record, "title"),
+		Description: columns.value(record, "description"),
+		Category:    columns.value(record, "category"),
+		Currency:    strings.ToUpper(columns.value(record, "currency")),
+		Split:       models.SplitDetail{Type: models.SplitType(strings.ToLower(columns.value(record, "split_type")))},
+	}
+
+	if expense.Title == "" {
+		return expense, errors.New("title is required")
+	}
+	if err := applyGroupCurrency(&expense, group); err != nil {
+		return expense, err
+	}
+	if expense.Split.Type == "" {
+		expense.Split.Type = models.SplitEqual
+	}
+
+	amount, err := parseImportAmount(columns.value(record, "amount"))
+	if err != nil {
+		return expense, err
+	}
+	expense.Amount = amount
+
+	payerID, ok := resolveMember(columns.value(record, "payer"))
+	if !ok {
+		return expense, fmt.Errorf("payer %q is not a group member", columns.value(record, "payer"))
+	}
+	expense.PaidBy = []models.PaidBy{{UserID: payerID, Amount: amount}}
+
+	expense.CreatedAt = time.Now()
+	expense.UpdatedAt = expense.CreatedAt
+	expense.Date = expense.CreatedAt
+	if date := columns.value(record, "date"); date != "" {
+		if expense.Date, err = parseImportDate(date); err != nil {
+			return expense, err
+		}
+	}
+
+	for _, column := range columns.split {
+		raw := ""
+		if column.index < len(record) {
+			raw = strings.TrimSpace(record[column.index])
+		}
+		if raw == "" {
+			continue
+		}
+		value := 1.0
+		if parsed, err := parseImportAmount(raw); err == nil {
+			value = parsed
+		} else if expense.Split.Type != models.SplitEqual {
+			return expense, fmt.Errorf("invalid split value %q", raw)
+		}
+		if value == 0 {
+			continue
+		}
+		expense.Split.Details = append(expense.Split.Details, models.SplitShare{UserID: column.memberID, Value: value})
+	}
+	if len(expense.Split.Details) == 0 && expense.Split.Type == models.SplitEqual {
+		for _, memberID := range allMembers {
+			expense.Split.Details = append(expense.Split.Details, models.SplitShare{UserID: memberID})
+		}
+	}
+
+	if err := validateExpense(ctx, expense); err != nil {
+		return expense, err
+	}
+
+	shares, err := s.calculateShares(ctx, expense)
+	if err != nil {
+		return expense, err
+	}
+	expense.Split.Details = shares
+
+	return expense, nil
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+const (
+	JobExpenseRecategorize = "expense_recategorize"
+
+	// recategorizeBatchSize is how many expenses are updated per write
+	recategorizeBatchSize = 500
+)
+
+var (
+	ErrInvalidRecategorization  = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidRecategorization, "invalid recategorization")
+	ErrRecategorizationNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodeRecategorizationNotFound, "recategorization not found")
+)
+
+// RecategorizeFilter picks a group's expenses by title, current category and creation date.
+// Expenses record no merchant, so a title pattern like the merchant's name stands in for one.
+type RecategorizeFilter struct {
+	Title    string     `json:"title,omitempty" binding:"max=100"`
+	Category *string    `json:"category,omitempty" binding:"omitempty,max=50"` // "" matches uncategorised expenses
+	From     *time.Time `json:"from,omitempty"`
+	To       *time.Time `json:"to,omitempty"` // exclusive
+}
+
+// RecategorizeExpensesRequest moves the matching expenses to Category, or makes them
+// uncategorised when it's empty. With Preview set nothing changes and only the count is returned.
+type RecategorizeExpensesRequest struct {
+	Filter   RecategorizeFilter `json:"filter"`
+	Category *string            `json:"category" binding:"required,max=50"`
+	Preview  bool               `json:"preview"`
+}
+
+// RecategorizePreview is how many expenses a recategorization would apply to
+type RecategorizePreview struct {
+	Matched int64 `json:"matched"`
+}
+
+// RecategorizeService changes the category of many of a group's expenses at once, in a
+// background job. Only group admins can use it.
+type RecategorizeService struct {
+	expenseRepo  repositories.ExpenseRepository
+	revisionRepo repositories.ExpenseRevisionRepository
+	groupRepo    repositories.GroupRepository
+	jobService   *JobService
+	budgets      BudgetTracker
+}
+
+func NewRecategorizeService(
+	expenseRepo repositories.ExpenseRepository,
+	revisionRepo repositories.ExpenseRevisionRepository,
+	groupRepo repositories.GroupRepository,
+	jobService *JobService,
+	budgets BudgetTracker,
+) *RecategorizeService {
+	return &RecategorizeService{
+		expenseRepo:  expenseRepo,
+		revisionRepo: revisionRepo,
+		groupRepo:    groupRepo,
+		jobService:   jobService,
+		budgets:      budgets,
+	}
+}
+
+// Preview counts the expenses the request would recategorize
+func (s *RecategorizeService) Preview(ctx context.Context, groupID string, userID string, req RecategorizeExpensesRequest) (*RecategorizePreview, error) {
+	match, _, err := s.prepare(ctx, groupID, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	matched, err := s.expenseRepo.CountMatching(ctx, match)
+	if err != nil {
+		return nil, err
+	}
+	return &RecategorizePreview{Matched: matched}, nil
+}
+
+// Start recategorizes the matching expenses in a background job and returns the job to poll.
+// Expenses are picked when the job starts; ones added later aren't included.
+func (s *RecategorizeService) Start(ctx context.Context, groupID string, userID string, req RecategorizeExpensesRequest) (*models.Job, error) {
+	match, category, err := s.prepare(ctx, groupID, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.jobService.Start(ctx, JobExpenseRecategorize, userID, func(ctx context.Context, progress ProgressFunc) (map[string]interface{}, error) {
+		matches, err := s.expenseRepo.ListMatching(ctx, match)
+		if err != nil {
+			return nil, err
+		}
+
+		var updated int64
+		progress(0, len(matches))
+		for start := 0; start < len(matches); start += recategorizeBatchSize {
+			end := start + recategorizeBatchSize
+			if end > len(matches) {
+				end = len(matches)
+			}
+			n, err := s.recategorizeBatch(ctx, groupID, userID, matches[start:end], category)
+			if err != nil {
+				return nil, fmt.Errorf("updated %d expenses before failing: %v", updated, err)
+			}
+			updated += n
+			progress(end, len(matches))
+		}
+
+		// Moving spending between categories can fill up a category budget
+		if updated > 0 {
+			s.budgets.ExpensesAdded(ctx, groupID)
+		}
+
+		return map[string]interface{}{
+			"group_id": groupID,
+			"category": category,
+			"matched":  len(matches),
+			"updated":  updated,
+		}, nil
+	})
+}
+
+// recategorizeBatch moves the expenses that aren't in category yet and records a revision for each
+func (s *RecategorizeService) recategorizeBatch(ctx context.Context, groupID string, userID string, batch []repositories.ExpenseCategory, category string) (int64, error) {
+	var ids []string
+	var revisions []*models.ExpenseRevision
+	for _, match := range batch {
+		if match.Category == category {
+			continue
+		}
+		ids = append(ids, match.ExpenseID)
+		change := models.ExpenseFieldChange{Field: "category", Before: match.Category, After: category}
+		expense := &models.Expense{ExpenseID: match.ExpenseID, GroupID: &groupID}
+		revisions = append(revisions, newExpenseRevision(expense, models.ExpenseRevisionRecategorized, userID, []models.ExpenseFieldChange{change}))
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	session, err := s.expenseRepo.StartSession()
+	if err != nil {
+		return 0, fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	updated, err := session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		n, err := s.expenseRepo.SetCategory(sessCtx, groupID, ids, category)
+		if err != nil {
+			return nil, err
+		}
+		return n, s.revisionRepo.InsertMany(sessCtx, revisions)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return updated.(int64), nil
+}
+
+// GetJob returns a recategorization job the user started
+func (s *RecategorizeService) GetJob(ctx context.Context, userID string, jobID string) (*models.Job, error) {
+	job, err := s.jobService.GetJob(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, ErrJobNotFound) {
+			return nil, ErrRecategorizationNotFound
+		}
+		return nil, err
+	}
+	if job.Type != JobExpenseRecategorize || job.CreatedBy != userID {
+		return nil, ErrRecategorizationNotFound
+	}
+	return job, nil
+}
+
+// prepare checks the caller and the request and returns the match and the new category
+func (s *RecategorizeService) prepare(ctx context.Context, groupID string, userID string, req RecategorizeExpensesRequest) (repositories.ExpenseMatch, string, error) {
+	if req.Category == nil {
+		return repositories.ExpenseMatch{}, "", fmt.Errorf("%w: category is required", ErrInvalidRecategorization)
+	}
+	f := req.Filter
+	if f.From != nil && f.To != nil && !f.From.Before(*f.To) {
+		return repositories.ExpenseMatch{}, "", fmt.Errorf("%w: from must be before to", ErrInvalidRecategorization)
+	}
+
+	if _, err := requireGroupAdmin(ctx, s.groupRepo, groupID, userID); err != nil {
+		return repositories.ExpenseMatch{}, "", err
+	}
+
+	match := repositories.ExpenseMatch{
+		GroupID: groupID,
+		Title:   strings.TrimSpace(f.Title),
+		From:    f.From,
+		To:      f.To,
+	}
+	if f.Category != nil {
+		current := strings.TrimSpace(*f.Category)
+		match.Category = &current
+	}
+	return match, strings.TrimSpace(*req.Category), nil
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+	"reflect"
+	"time"
+
+	"divvydoo/backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// GetExpenseHistory returns every recorded change to the expense, newest first, to users who
+// can view it
+func (s *ExpenseService) GetExpenseHistory(ctx context.Context, expenseID string, userID string) ([]*models.ExpenseRevision, error) {
+	if _, err := s.GetExpense(ctx, expenseID, userID); err != nil {
+		return nil, err
+	}
+	return s.revisionRepo.ListByExpenseID(ctx, expenseID)
+}
+
+func newExpenseRevision(expense *models.Expense, action models.ExpenseRevisionAction, userID string, changes []models.ExpenseFieldChange) *models.ExpenseRevision {
+	return &models.ExpenseRevision{
+		RevisionID: uuid.New().String(),
+		ExpenseID:  expense.ExpenseID,
+		GroupID:    expense.GroupID,
+		Action:     action,
+		ChangedBy:  userID,
+		ChangedAt:  time.Now(),
+		Changes:    changes,
+	}
+}
+
+// expenseChanges lists the fields, by their JSON names, that differ between two versions of an expense
+func expenseChanges(before, after *models.Expense) []models.ExpenseFieldChange {
+	fields := []struct {
+		name          string
+		before, after interface{}
+	}{
+		{"title", before.Title, after.Title},
+		{"description", before.Description, after.Description},
+		{"notes", before.Notes, after.Notes},
+		{"category", before.Category, after.Category},
+		{"location", before.Location, after.Location},
+		{"date", before.Date, after.Date},
+		{"amount", before.Amount, after.Amount},
+		{"currency", before.Currency, after.Currency},
+		{"paid_by", before.PaidBy, after.PaidBy},
+		{"split", before.Split, after.Split},
+		{"status", before.Status, after.Status},
+		{"reject_reason", before.RejectReason, after.RejectReason},
+	}
+
+	var changes []models.ExpenseFieldChange
+	for _, field := range fields {
+		if !reflect.DeepEqual(field.before, field.after) {
+			changes = append(changes, models.ExpenseFieldChange{Field: field.name, Before: field.before, After: field.after})
+		}
+	}
+	return changes
+}
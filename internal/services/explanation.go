@@ -0,0 +1,64 @@
+package services
+
+import (
+	"math"
+	"strconv"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/pkg/locale"
+)
+
+// explainShares fills in how each calculated share was derived from the request, so every client
+// shows the same arithmetic, written in loc's language. shares are in the same order as
+// expense.Split.Details.
+func explainShares(loc locale.Locale, expense models.Expense, shares []models.SplitShare) {
+	details := expense.Split.Details
+	exact := exactShares(expense)
+	total := loc.FormatMoney(expense.Amount, expense.Currency)
+
+	totalValue := 0.0
+	for _, detail := range details {
+		totalValue += detail.Value
+	}
+
+	for i := range shares {
+		if i >= len(details) {
+			break
+		}
+		share := loc.FormatMoney(shares[i].Value, expense.Currency)
+
+		var explanation string
+		switch expense.Split.Type {
+		case models.SplitEqual:
+			explanation = locale.Sprintf(loc.Language, "1 of %d equal parts of %s = %s", len(details), total, share)
+		case models.SplitExact:
+			explanation = locale.Sprintf(loc.Language, "exact amount of %s", share)
+		case models.SplitPercentage:
+			explanation = locale.Sprintf(loc.Language, "%s%% of %s = %s", formatNumber(details[i].Value), total, share)
+		case models.SplitShares:
+			explanation = locale.Sprintf(loc.Language, "%s of %s shares of %s = %s", formatNumber(details[i].Value), formatNumber(totalValue), total, share)
+		default:
+			continue
+		}
+
+		// Say so when the share isn't the exact amount rounded to the cent, so the difference
+		// doesn't look like a mistake
+		if i < len(exact) {
+			if adjustment := math.Round((shares[i].Value-math.Round(exact[i]*100)/100)*100) / 100; adjustment != 0 {
+				format := "%s (rounded up by %s so the shares add up to %s)"
+				if adjustment < 0 {
+					format = "%s (rounded down by %s so the shares add up to %s)"
+				}
+				explanation = locale.Sprintf(loc.Language, format,
+					explanation, loc.FormatMoney(math.Abs(adjustment), expense.Currency), total)
+			}
+		}
+
+		shares[i].Explanation = explanation
+	}
+}
+
+// formatNumber writes a percentage or share count without trailing zeros
+func formatNumber(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
@@ -0,0 +1,253 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+const (
+	JobUserExport = "user_export"
+
+	// exportPageSize is how many documents are read per query while collecting an export
+	exportPageSize = 500
+)
+
+var (
+	ErrExportNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodeExportNotFound, "export not found")
+	ErrExportNotReady = utils.NewCustomError(http.StatusConflict, utils.CodeExportNotReady, "export is not ready for download")
+)
+
+// ExportService assembles everything stored about a user into a ZIP of JSON files in a
+// background job. Only the newest export of each user is kept on disk.
+type ExportService struct {
+	userRepo       repositories.UserRepository
+	groupRepo      repositories.GroupRepository
+	expenseRepo    repositories.ExpenseRepository
+	settlementRepo repositories.SettlementRepository
+	balanceRepo    repositories.BalanceRepository
+	jobService     *JobService
+	dir            string
+}
+
+func NewExportService(
+	userRepo repositories.UserRepository,
+	groupRepo repositories.GroupRepository,
+	expenseRepo repositories.ExpenseRepository,
+	settlementRepo repositories.SettlementRepository,
+	balanceRepo repositories.BalanceRepository,
+	jobService *JobService,
+	dir string,
+) *ExportService {
+	return &ExportService{
+		userRepo:       userRepo,
+		groupRepo:      groupRepo,
+		expenseRepo:    expenseRepo,
+		settlementRepo: settlementRepo,
+		balanceRepo:    balanceRepo,
+		jobService:     jobService,
+		dir:            dir,
+	}
+}
+
+// StartExport assembles the user's export in a background job. Poll the job with GetExport
+// and fetch the archive with OpenExport once it has succeeded.
+func (s *ExportService) StartExport(ctx context.Context, userID string) (*models.Job, error) {
+	exists, err := s.userRepo.Exists(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+
+	exportID := uuid.New().String()
+	return s.jobService.Start(ctx, JobUserExport, userID, func(ctx context.Context, progress ProgressFunc) (map[string]interface{}, error) {
+		size, err := s.export(ctx, userID, exportID, progress)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"export_id": exportID, "size_bytes": size}, nil
+	})
+}
+
+// GetExport returns the export job, provided it belongs to the user
+func (s *ExportService) GetExport(ctx context.Context, userID string, jobID string) (*models.Job, error) {
+	job, err := s.jobService.GetJob(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, ErrJobNotFound) {
+			return nil, ErrExportNotFound
+		}
+		return nil, err
+	}
+	if job.Type != JobUserExport || job.CreatedBy != userID {
+		return nil, ErrExportNotFound
+	}
+	return job, nil
+}
+
+// OpenExport returns the path of a finished export's archive
+func (s *ExportService) OpenExport(ctx context.Context, userID string, jobID string) (string, error) {
+	job, err := s.GetExport(ctx, userID, jobID)
+	if err != nil {
+		return "", err
+	}
+	if job.Status != models.JobSucceeded {
+		return "", ErrExportNotReady
+	}
+
+	exportID, _ := job.Result["export_id"].(string)
+	path := s.archivePath(userID, exportID)
+	if _, err := os.Stat(path); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			// Replaced by a newer export
+			return "", ErrExportNotFound
+		}
+		return "", err
+	}
+	return path, nil
+}
+
+// export writes the archive and removes the user's older ones, returning its size
+func (s *ExportService) export(ctx context.Context, userID, exportID string, progress ProgressFunc) (int64, error) {
+	if err := os.MkdirAll(s.dir, 0o750); err != nil {
+		return 0, fmt.Errorf("failed to create export directory: %v", err)
+	}
+
+	path := s.archivePath(userID, exportID)
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := s.writeArchive(ctx, file, userID, progress); err != nil {
+		file.Close()
+		os.Remove(path)
+		return 0, err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return 0, err
+	}
+
+	previous, _ := filepath.Glob(filepath.Join(s.dir, userID+"-*.zip"))
+	for _, old := range previous {
+		if old == path {
+			continue
+		}
+		if err := os.Remove(old); err != nil {
+			log.Printf("Failed to remove old export %s: %v", old, err)
+		}
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (s *ExportService) writeArchive(ctx context.Context, file *os.File, userID string, progress ProgressFunc) error {
+	sections := []struct {
+		name    string
+		collect func(context.Context, string) (interface{}, error)
+	}{
+		{"profile.json", s.collectProfile},
+		{"groups.json", s.collectGroups},
+		{"expenses.json", s.collectExpenses},
+		{"settlements.json", s.collectSettlements},
+		{"balances.json", s.collectBalances},
+		{"balance_history.json", s.collectBalanceHistory},
+	}
+
+	archive := zip.NewWriter(file)
+	for i, section := range sections {
+		data, err := section.collect(ctx, userID)
+		if err != nil {
+			return fmt.Errorf("failed to collect %s: %w", section.name, err)
+		}
+
+		entry, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     section.name,
+			Method:   zip.Deflate,
+			Modified: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		encoder := json.NewEncoder(entry)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(data); err != nil {
+			return err
+		}
+
+		progress(i+1, len(sections))
+	}
+	return archive.Close()
+}
+
+func (s *ExportService) collectProfile(ctx context.Context, userID string) (interface{}, error) {
+	return s.userRepo.GetByID(ctx, userID)
+}
+
+func (s *ExportService) collectGroups(ctx context.Context, userID string) (interface{}, error) {
+	return s.groupRepo.GetByUserID(ctx, userID)
+}
+
+func (s *ExportService) collectExpenses(ctx context.Context, userID string) (interface{}, error) {
+	return collectPages(func(cursor *repositories.Cursor) ([]*models.Expense, string, error) {
+		return s.expenseRepo.ListByUserID(ctx, userID, cursor, exportPageSize, 0)
+	})
+}
+
+func (s *ExportService) collectSettlements(ctx context.Context, userID string) (interface{}, error) {
+	return collectPages(func(cursor *repositories.Cursor) ([]*models.Settlement, string, error) {
+		return s.settlementRepo.ListByUserID(ctx, userID, nil, cursor, exportPageSize, 0)
+	})
+}
+
+func (s *ExportService) collectBalances(ctx context.Context, userID string) (interface{}, error) {
+	return s.balanceRepo.GetByUserID(ctx, userID)
+}
+
+func (s *ExportService) collectBalanceHistory(ctx context.Context, userID string) (interface{}, error) {
+	return collectPages(func(cursor *repositories.Cursor) ([]*models.BalanceHistory, string, error) {
+		return s.balanceRepo.ListBalanceHistory(ctx, userID, nil, nil, cursor, exportPageSize, 0)
+	})
+}
+
+func (s *ExportService) archivePath(userID, exportID string) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s-%s.zip", userID, exportID))
+}
+
+// collectPages follows a cursor-paginated listing to the end
+func collectPages[T any](fetch func(cursor *repositories.Cursor) ([]T, string, error)) ([]T, error) {
+	all := make([]T, 0)
+	var cursor *repositories.Cursor
+	for {
+		page, next, err := fetch(cursor)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, page...)
+		if next == "" {
+			return all, nil
+		}
+		if cursor, err = repositories.DecodeCursor(next); err != nil {
+			return nil, err
+		}
+	}
+}
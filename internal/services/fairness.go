@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+)
+
+var ErrInvalidFairnessPeriod = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidFairnessPeriod, "from must be before to")
+
+type FairnessService struct {
+	aggregations repositories.ExpenseAggregations
+	groupRepo    repositories.GroupRepository
+	reports      *aggregationFallback[*models.FairnessReport]
+}
+
+// NewFairnessService creates a FairnessService that adds up expenses with aggregations: the
+// expense repository, or the reporting read model when reports are served from it
+func NewFairnessService(
+	aggregations repositories.ExpenseAggregations,
+	groupRepo repositories.GroupRepository,
+	budget AggregationBudget,
+) *FairnessService {
+	return &FairnessService{
+		aggregations: aggregations,
+		groupRepo:    groupRepo,
+		reports:      newAggregationFallback[*models.FairnessReport]("fairness", budget),
+	}
+}
+
+// GetFairnessReport compares what each member paid with what they consumed in the group's expenses
+// created in [from, to). A nil from covers the group's whole history and a nil to runs until now.
+func (s *FairnessService) GetFairnessReport(ctx context.Context, groupID string, userID string, from, to *time.Time) (*models.FairnessReport, error) {
+	now := time.Now()
+	end := now
+	if to != nil {
+		end = *to
+	}
+	var start time.Time
+	if from != nil {
+		start = *from
+	}
+	if !start.Before(end) {
+		return nil, ErrInvalidFairnessPeriod
+	}
+
+	if err := requireGroupMember(ctx, s.groupRepo, groupID, userID); err != nil {
+		return nil, err
+	}
+
+	// Keyed by the period as requested, so a report running until now can stand in for a later one
+	key := groupID + "|" + periodKey(from) + "|" + periodKey(to)
+	report, stale, err := s.reports.get(ctx, key, func(ctx context.Context) (*models.FairnessReport, error) {
+		totals, err := s.aggregations.SumPaidAndConsumedInPeriod(ctx, groupID, start, end)
+		if err != nil {
+			return nil, err
+		}
+		members, err := s.groupRepo.GetMembersWithDetails(ctx, groupID)
+		if err != nil {
+			return nil, err
+		}
+
+		return &models.FairnessReport{
+			GroupID:     groupID,
+			From:        from,
+			To:          end,
+			Currencies:  fairnessByCurrency(totals, members),
+			GeneratedAt: now,
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if stale {
+		staleReport := *report
+		staleReport.Stale = true
+		return &staleReport, nil
+	}
+	return report, nil
+}
+
+func periodKey(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+// fairnessByCurrency builds the per-currency figures. Active members who took no part are listed
+// with zeros; former members only when they did. Amounts are compared in whole cents.
+func fairnessByCurrency(totals []repositories.ParticipantTotal, members []repositories.MemberWithUser) []models.FairnessByCurrency {
+	type cents struct{ paid, consumed int64 }
+
+	byCurrency := make(map[string]map[string]*cents)
+	for _, total := range totals {
+		if byCurrency[total.Currency] == nil {
+			byCurrency[total.Currency] = make(map[string]*cents)
+		}
+		byCurrency[total.Currency][total.UserID] = &cents{
+			paid:     int64(math.Round(total.Paid * 100)),
+			consumed: int64(math.Round(total.Consumed * 100)),
+		}
+	}
+
+	names := make(map[string]string, len(members))
+	for _, member := range members {
+		names[member.UserID] = member.Name
+	}
+
+	result := make([]models.FairnessByCurrency, 0, len(byCurrency))
+	for currency, users := range byCurrency {
+		for _, member := range members {
+			if member.IsActive && users[member.UserID] == nil {
+				users[member.UserID] = &cents{}
+			}
+		}
+
+		var spent, imbalance int64
+		line := models.FairnessByCurrency{Currency: currency, Members: make([]models.MemberFairness, 0, len(users))}
+		for userID, c := range users {
+			spent += c.consumed
+			covered := c.paid - c.consumed
+			if covered < 0 {
+				imbalance -= covered
+			} else {
+				imbalance += covered
+			}
+
+			member := models.MemberFairness{
+				UserID:   userID,
+				Name:     names[userID],
+				Paid:     float64(c.paid) / 100,
+				Consumed: float64(c.consumed) / 100,
+				Covered:  float64(covered) / 100,
+			}
+			if c.consumed > 0 {
+				ratio := math.Round(float64(c.paid)*100/float64(c.consumed)) / 100
+				member.PaidRatio = &ratio
+			}
+			line.Members = append(line.Members, member)
+		}
+
+		line.Total = float64(spent) / 100
+		line.FairnessIndex = 1
+		if spent > 0 {
+			// Half the total mismatch is the money that went through the wrong hands
+			line.FairnessIndex = math.Max(0, math.Round((1-float64(imbalance)/float64(2*spent))*1000)/1000)
+		}
+		sort.Slice(line.Members, func(i, j int) bool {
+			a, b := line.Members[i], line.Members[j]
+			if a.Covered != b.Covered {
+				return a.Covered > b.Covered
+			}
+			return a.UserID < b.UserID
+		})
+		result = append(result, line)
+	}
+
+	sort.Slice(result, func(i, j int) bool { return result[i].Currency < result[j].Currency })
+	return result
+}
@@ -0,0 +1,345 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrFriendRequestNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodeFriendRequestNotFound, "friend request not found")
+	ErrNotFriendRecipient    = utils.NewCustomError(http.StatusForbidden, utils.CodeNotFriendRecipient, "only the recipient can accept a friend request")
+	ErrAlreadyFriends        = utils.NewCustomError(http.StatusConflict, utils.CodeAlreadyFriends, "you are already friends")
+	ErrCannotFriendSelf      = utils.NewCustomError(http.StatusBadRequest, utils.CodeCannotFriendSelf, "you can't send a friend request to yourself")
+	ErrNotFriends            = utils.NewCustomError(http.StatusNotFound, utils.CodeNotFriends, "you are not friends with this user")
+)
+
+type SendFriendRequestRequest struct {
+	UserID string `json:"user_id" binding:"required"`
+}
+
+// FriendRequests are a user's pending friend requests, received and sent, newest first
+type FriendRequests struct {
+	Incoming []models.FriendRequest `json:"incoming"`
+	Outgoing []models.FriendRequest `json:"outgoing"`
+}
+
+// FriendService connects users outside of groups. Expenses outside a group can only be shared
+// with friends, and users can limit who finds them by email or phone to friends of friends.
+type FriendService struct {
+	friendshipRepo repositories.FriendshipRepository
+	userRepo       repositories.UserRepository
+	notifier       Notifier
+}
+
+func NewFriendService(
+	friendshipRepo repositories.FriendshipRepository,
+	userRepo repositories.UserRepository,
+	notifier Notifier,
+) *FriendService {
+	return &FriendService{
+		friendshipRepo: friendshipRepo,
+		userRepo:       userRepo,
+		notifier:       notifier,
+	}
+}
+
+// SendRequest asks another user to be friends. Sending a request again returns the pending one;
+// sending one to a user who already asked accepts theirs.
+func (s *FriendService) SendRequest(ctx context.Context, userID string, req SendFriendRequestRequest) (*models.Friendship, error) {
+	if req.UserID == userID {
+		return nil, ErrCannotFriendSelf
+	}
+
+	users, err := s.userRepo.GetByIDs(ctx, []string{userID, req.UserID})
+	if err != nil {
+		return nil, err
+	}
+	var requester, addressee *models.User
+	for _, user := range users {
+		switch user.UserID {
+		case userID:
+			requester = user
+		case req.UserID:
+			addressee = user
+		}
+	}
+	if requester == nil || addressee == nil || addressee.Placeholder || addressee.DeletedAt != nil {
+		return nil, ErrUserNotFound
+	}
+
+	existing, err := s.friendshipRepo.GetBetween(ctx, userID, req.UserID)
+	if err == nil {
+		return s.resolveExistingRequest(ctx, existing, userID)
+	}
+	if !errors.Is(err, repositories.ErrFriendshipNotFound) {
+		return nil, err
+	}
+
+	friendship := &models.Friendship{
+		FriendshipID: uuid.New().String(),
+		RequesterID:  userID,
+		AddresseeID:  req.UserID,
+		Status:       models.FriendshipPending,
+		CreatedAt:    time.Now(),
+	}
+	if err := s.friendshipRepo.Create(ctx, friendship); err != nil {
+		if !errors.Is(err, repositories.ErrFriendshipExists) {
+			return nil, err
+		}
+		// The other user's request crossed ours
+		existing, err := s.friendshipRepo.GetBetween(ctx, userID, req.UserID)
+		if err != nil {
+			return nil, err
+		}
+		return s.resolveExistingRequest(ctx, existing, userID)
+	}
+
+	deliver(ctx, s.notifier, Notification{
+		UserID: addressee.UserID,
+		Type:   models.NotificationFriendRequested,
+		Title:  "New friend request",
+		Body:   "%s wants to add you as a friend.",
+		Data: map[string]interface{}{
+			"friendship_id": friendship.FriendshipID,
+			"user_id":       requester.UserID,
+		},
+
+		BodyArgs: []interface{}{requester.Name},
+	})
+
+	return friendship, nil
+}
+
+func (s *FriendService) resolveExistingRequest(ctx context.Context, existing *models.Friendship, userID string) (*models.Friendship, error) {
+	switch {
+	case existing.Status == models.FriendshipAccepted:
+		return nil, ErrAlreadyFriends
+	case existing.AddresseeID == userID:
+		return s.accept(ctx, existing)
+	default:
+		return existing, nil
+	}
+}
+
+// AcceptRequest accepts a friend request sent to the user
+func (s *FriendService) AcceptRequest(ctx context.Context, userID string, friendshipID string) (*models.Friendship, error) {
+	friendship, err := s.pendingRequest(ctx, friendshipID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if friendship.AddresseeID != userID {
+		return nil, ErrNotFriendRecipient
+	}
+	return s.accept(ctx, friendship)
+}
+
+func (s *FriendService) accept(ctx context.Context, friendship *models.Friendship) (*models.Friendship, error) {
+	now := time.Now()
+	if err := s.friendshipRepo.MarkAccepted(ctx, friendship.FriendshipID, now); err != nil {
+		if !errors.Is(err, repositories.ErrFriendshipNotFound) {
+			return nil, err
+		}
+		// Accepted by a concurrent request, or withdrawn in the meantime
+		current, err := s.friendshipRepo.GetByID(ctx, friendship.FriendshipID)
+		if err != nil || current.Status != models.FriendshipAccepted {
+			return nil, ErrFriendRequestNotFound
+		}
+		return current, nil
+	}
+	friendship.Status = models.FriendshipAccepted
+	friendship.AcceptedAt = &now
+
+	name := "Someone"
+	if addressee, err := s.userRepo.GetByID(ctx, friendship.AddresseeID); err == nil {
+		name = addressee.Name
+	}
+	deliver(ctx, s.notifier, Notification{
+		UserID: friendship.RequesterID,
+		Type:   models.NotificationFriendAccepted,
+		Title:  "Friend request accepted",
+		Body:   "%s accepted your friend request.",
+		Data: map[string]interface{}{
+			"friendship_id": friendship.FriendshipID,
+			"user_id":       friendship.AddresseeID,
+		},
+
+		BodyArgs: []interface{}{name},
+	})
+
+	return friendship, nil
+}
+
+// DeclineRequest turns down a friend request sent to the user, or withdraws one they sent
+func (s *FriendService) DeclineRequest(ctx context.Context, userID string, friendshipID string) error {
+	friendship, err := s.pendingRequest(ctx, friendshipID, userID)
+	if err != nil {
+		return err
+	}
+	if err := s.friendshipRepo.Delete(ctx, friendship.FriendshipID); err != nil {
+		if errors.Is(err, repositories.ErrFriendshipNotFound) {
+			return ErrFriendRequestNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// pendingRequest loads a pending request the user sent or received
+func (s *FriendService) pendingRequest(ctx context.Context, friendshipID string, userID string) (*models.Friendship, error) {
+	friendship, err := s.friendshipRepo.GetByID(ctx, friendshipID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrFriendshipNotFound) {
+			return nil, ErrFriendRequestNotFound
+		}
+		return nil, err
+	}
+	if friendship.Status != models.FriendshipPending ||
+		(friendship.RequesterID != userID && friendship.AddresseeID != userID) {
+		return nil, ErrFriendRequestNotFound
+	}
+	return friendship, nil
+}
+
+func (s *FriendService) ListRequests(ctx context.Context, userID string) (*FriendRequests, error) {
+	friendships, err := s.friendshipRepo.ListByUserID(ctx, userID, models.FriendshipPending)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.otherUsers(ctx, userID, friendships)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := &FriendRequests{Incoming: []models.FriendRequest{}, Outgoing: []models.FriendRequest{}}
+	for _, friendship := range friendships {
+		request := models.FriendRequest{FriendshipID: friendship.FriendshipID, CreatedAt: friendship.CreatedAt}
+		if friendship.AddresseeID == userID {
+			request.UserID = friendship.RequesterID
+		} else {
+			request.UserID = friendship.AddresseeID
+		}
+		if user, ok := users[request.UserID]; ok {
+			request.Name = user.Name
+			request.AvatarURL = user.AvatarURL
+		}
+
+		if friendship.AddresseeID == userID {
+			requests.Incoming = append(requests.Incoming, request)
+		} else {
+			requests.Outgoing = append(requests.Outgoing, request)
+		}
+	}
+	return requests, nil
+}
+
+// ListFriends returns the user's friends, most recently connected first
+func (s *FriendService) ListFriends(ctx context.Context, userID string) ([]models.Friend, error) {
+	friendships, err := s.friendshipRepo.ListByUserID(ctx, userID, models.FriendshipAccepted)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.otherUsers(ctx, userID, friendships)
+	if err != nil {
+		return nil, err
+	}
+
+	friends := make([]models.Friend, 0, len(friendships))
+	for _, friendship := range friendships {
+		friendID := friendship.RequesterID
+		if friendID == userID {
+			friendID = friendship.AddresseeID
+		}
+		friend := models.Friend{UserID: friendID, FriendshipID: friendship.FriendshipID, Since: friendship.CreatedAt}
+		if friendship.AcceptedAt != nil {
+			friend.Since = *friendship.AcceptedAt
+		}
+		if user, ok := users[friendID]; ok {
+			friend.Name = user.Name
+			friend.Email = user.Email
+			friend.AvatarURL = user.AvatarURL
+		}
+		friends = append(friends, friend)
+	}
+	return friends, nil
+}
+
+// RemoveFriend ends the friendship. Shared expenses and balances stay as they are.
+func (s *FriendService) RemoveFriend(ctx context.Context, userID string, friendID string) error {
+	friendship, err := s.friendshipRepo.GetBetween(ctx, userID, friendID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrFriendshipNotFound) {
+			return ErrNotFriends
+		}
+		return err
+	}
+	if friendship.Status != models.FriendshipAccepted {
+		return ErrNotFriends
+	}
+	if err := s.friendshipRepo.Delete(ctx, friendship.FriendshipID); err != nil {
+		if errors.Is(err, repositories.ErrFriendshipNotFound) {
+			return ErrNotFriends
+		}
+		return err
+	}
+	return nil
+}
+
+// otherUsers loads the other user of each friendship, by user ID
+func (s *FriendService) otherUsers(ctx context.Context, userID string, friendships []*models.Friendship) (map[string]*models.User, error) {
+	userIDs := make([]string, 0, len(friendships))
+	for _, friendship := range friendships {
+		if friendship.RequesterID == userID {
+			userIDs = append(userIDs, friendship.AddresseeID)
+		} else {
+			userIDs = append(userIDs, friendship.RequesterID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	users, err := s.userRepo.GetByIDs(ctx, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.User, len(users))
+	for _, user := range users {
+		byID[user.UserID] = user
+	}
+	return byID, nil
+}
+
+// withinFriendsOfFriends reports whether other is the user's friend or a friend of one of the
+// user's friends
+func withinFriendsOfFriends(ctx context.Context, friendshipRepo repositories.FriendshipRepository, userID string, otherID string) (bool, error) {
+	friendIDs, err := friendshipRepo.GetFriendIDs(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+	friends := make(map[string]bool, len(friendIDs))
+	for _, id := range friendIDs {
+		if id == otherID {
+			return true, nil
+		}
+		friends[id] = true
+	}
+
+	otherFriendIDs, err := friendshipRepo.GetFriendIDs(ctx, otherID)
+	if err != nil {
+		return false, err
+	}
+	for _, id := range otherFriendIDs {
+		if friends[id] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
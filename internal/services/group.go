@@ -3,30 +3,62 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
 	"time"
 
 	"divvydoo/backend/internal/models"
 	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+	"divvydoo/backend/pkg/email"
 
 	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var (
-	ErrGroupNotFound       = errors.New("group not found")
-	ErrNotGroupMember      = errors.New("user is not a member of this group")
-	ErrNotGroupAdmin       = errors.New("user is not an admin of this group")
-	ErrMemberAlreadyExists = errors.New("user is already a member of this group")
+	ErrGroupNotFound        = utils.NewCustomError(http.StatusNotFound, utils.CodeGroupNotFound, "group not found")
+	ErrNotGroupMember       = utils.NewCustomError(http.StatusForbidden, utils.CodeNotGroupMember, "user is not a member of this group")
+	ErrNotGroupAdmin        = utils.NewCustomError(http.StatusForbidden, utils.CodeNotGroupAdmin, "user is not an admin of this group")
+	ErrMemberAlreadyExists  = utils.NewCustomError(http.StatusConflict, utils.CodeMemberAlreadyExists, "user is already a member of this group")
+	ErrInvalidGroupSettings = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidGroupSettings, "invalid group settings")
+	ErrInvalidMemberRole    = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidMemberRole, "invalid member role")
+	ErrLastGroupAdmin       = utils.NewCustomError(http.StatusConflict, utils.CodeLastGroupAdmin, "a group must keep at least one admin")
+	ErrGroupMemberNotFound  = utils.NewCustomError(http.StatusNotFound, utils.CodeGroupMemberNotFound, "member not found in this group")
+	ErrOutstandingBalance   = utils.NewCustomError(http.StatusConflict, utils.CodeOutstandingBalance, "member has an outstanding balance in this group; settle up or forgive it first")
+	ErrGroupArchived        = utils.NewCustomError(http.StatusConflict, utils.CodeGroupArchived, "group is archived")
+	ErrGroupHasBalances     = utils.NewCustomError(http.StatusConflict, utils.CodeGroupHasBalances, "group still has outstanding balances; settle up or force the archive")
+	ErrCannotForgiveDebt    = utils.NewCustomError(http.StatusConflict, utils.CodeCannotForgiveDebt, "members can only forgive what they are owed; settle your debts before leaving")
+	ErrWriteOffUnbalanced   = utils.NewCustomError(http.StatusConflict, utils.CodeWriteOffUnbalanced, "no other member's balance can absorb the write-off; the group's balances need reconciling")
 )
 
 type GroupService struct {
-	groupRepo repositories.GroupRepository
-	userRepo  repositories.UserRepository
+	groupRepo      repositories.GroupRepository
+	userRepo       repositories.UserRepository
+	balanceRepo    repositories.BalanceRepository
+	settlementRepo repositories.SettlementRepository
+	converter      CurrencyConverter
+	notifier       Notifier
+	emailSender    email.EmailSender
+	aggregates     *AggregateCache
 }
 
-func NewGroupService(groupRepo repositories.GroupRepository, userRepo repositories.UserRepository) *GroupService {
+// NewGroupService creates a GroupService. converter may be nil, in which case groups can only
+// change currency keeping their balances, and those aren't included in converted totals.
+func NewGroupService(groupRepo repositories.GroupRepository, userRepo repositories.UserRepository, balanceRepo repositories.BalanceRepository, settlementRepo repositories.SettlementRepository, converter CurrencyConverter, notifier Notifier, emailSender email.EmailSender, aggregates *AggregateCache) *GroupService {
 	return &GroupService{
-		groupRepo: groupRepo,
-		userRepo:  userRepo,
+		groupRepo:      groupRepo,
+		userRepo:       userRepo,
+		balanceRepo:    balanceRepo,
+		settlementRepo: settlementRepo,
+		converter:      converter,
+		notifier:       notifier,
+		emailSender:    emailSender,
+		aggregates:     aggregates,
 	}
 }
 
@@ -35,12 +67,31 @@ type CreateGroupRequest struct {
 	Currency string `json:"currency" binding:"required"`
 }
 
+// UpdateGroupSettingsRequest changes only the settings that are present
+type UpdateGroupSettingsRequest struct {
+	SettlementConfirmation *models.SettlementConfirmationPolicy `json:"settlement_confirmation,omitempty"`
+	AutoConfirmAfterHours  *int                                 `json:"auto_confirm_after_hours,omitempty"`
+	MultiCurrency          *bool                                `json:"multi_currency,omitempty"`
+	// ExpenseSoftLimits replaces the group's limits; an empty object reverts to the server defaults
+	ExpenseSoftLimits map[string]float64 `json:"expense_soft_limits,omitempty"`
+	CrossGroupNetting *bool              `json:"cross_group_netting,omitempty"`
+	// DefaultSplitType set to "" removes the default
+	DefaultSplitType         *models.SplitType `json:"default_split_type,omitempty"`
+	SimplifyDebts            *bool             `json:"simplify_debts,omitempty"`
+	AllowMemberInvites       *bool             `json:"allow_member_invites,omitempty"`
+	ExpenseApprovalThreshold *float64          `json:"expense_approval_threshold,omitempty"`
+	PrivateBalances          *bool             `json:"private_balances,omitempty"`
+}
+
+type UpdateMemberRoleRequest struct {
+	Role models.UserRole `json:"role" binding:"required"`
+}
+
 type AddMemberRequest struct {
 	UserID string `json:"user_id" binding:"required"`
 	Role   string `json:"role,omitempty"`
 }
 
-
 func (s *GroupService) CreateGroup(ctx context.Context, creatorID string, req CreateGroupRequest) (*models.Group, error) {
 	// Verify creator exists
 	exists, err := s.userRepo.Exists(ctx, creatorID)
@@ -96,6 +147,84 @@ func (s *GroupService) GetUserGroups(ctx context.Context, userID string) ([]*mod
 	return s.groupRepo.GetByUserID(ctx, userID)
 }
 
+// GetUserGroupSummaries lists the user's groups, most recently active first, with the member
+// count, the user's balance in each group and when the group last saw activity.
+func (s *GroupService) GetUserGroupSummaries(ctx context.Context, userID string) ([]*models.UserGroupSummary, error) {
+	groups, err := s.groupRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	groupIDs := make([]string, 0, len(groups))
+	for _, group := range groups {
+		groupIDs = append(groupIDs, group.GroupID)
+	}
+
+	balances, err := s.balanceRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	balancesByGroup := make(map[string][]models.CurrencyTotal)
+	for _, balance := range balances {
+		if balance.GroupID == nil {
+			continue
+		}
+		balancesByGroup[*balance.GroupID] = append(balancesByGroup[*balance.GroupID], models.CurrencyTotal{
+			Currency: balance.Currency,
+			Balance:  balance.Balance,
+		})
+	}
+
+	lastActivity, err := s.balanceRepo.GetLastActivityByGroupIDs(ctx, groupIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]*models.UserGroupSummary, 0, len(groups))
+	for _, group := range groups {
+		summary := &models.UserGroupSummary{
+			GroupID:        group.GroupID,
+			Name:           group.Name,
+			AvatarURL:      group.AvatarURL,
+			Currency:       group.Currency,
+			Balances:       balancesByGroup[group.GroupID],
+			LastActivityAt: group.UpdatedAt,
+		}
+		if summary.Balances == nil {
+			summary.Balances = []models.CurrencyTotal{}
+		}
+
+		for _, member := range group.Members {
+			if !member.IsActive {
+				continue
+			}
+			summary.MemberCount++
+			if member.UserID == userID {
+				summary.Role = member.Role
+			}
+		}
+
+		for _, total := range summary.Balances {
+			if total.Currency == group.Currency {
+				summary.Balance += total.Balance
+			}
+		}
+		summary.Balance += s.keptBalance(ctx, group, summary.Balances)
+
+		if at, ok := lastActivity[group.GroupID]; ok && at.After(summary.LastActivityAt) {
+			summary.LastActivityAt = at
+		}
+
+		summaries = append(summaries, summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].LastActivityAt.After(summaries[j].LastActivityAt)
+	})
+
+	return summaries, nil
+}
+
 func (s *GroupService) UpdateGroup(ctx context.Context, groupID string, userID string, req CreateGroupRequest) (*models.Group, error) {
 	// Check if user is an admin
 	isAdmin, err := s.isGroupAdmin(ctx, groupID, userID)
@@ -111,20 +240,212 @@ func (s *GroupService) UpdateGroup(ctx context.Context, groupID string, userID s
 		return nil, err
 	}
 
+	// A new currency given here keeps the balances in the old one, as ChangeCurrency does in
+	// keep mode
+	if currency := strings.ToUpper(strings.TrimSpace(req.Currency)); currency != group.Currency {
+		group, err = s.ChangeCurrency(ctx, groupID, userID, ChangeCurrencyRequest{Currency: currency, Mode: models.CurrencyChangeKeep})
+		if err != nil {
+			return nil, err
+		}
+	}
 	group.Name = req.Name
-	group.Currency = req.Currency
 
 	return s.groupRepo.Update(ctx, group)
 }
 
+// ArchiveGroup hides the group from member group lists and stops new expenses, while its
+// expenses, settlements and balances stay viewable. Groups whose balances aren't settled can
+// only be archived with force. Archiving an archived group does nothing.
+func (s *GroupService) ArchiveGroup(ctx context.Context, groupID string, userID string, force bool) error {
+	group, err := s.adminGroup(ctx, groupID, userID)
+	if err != nil {
+		return err
+	}
+	if !group.IsActive {
+		return nil
+	}
+
+	if !force {
+		balances, err := s.balanceRepo.GetByGroupID(ctx, groupID)
+		if err != nil {
+			return err
+		}
+		for _, balance := range balances {
+			if math.Abs(balance.Balance) > 0.01 {
+				return ErrGroupHasBalances
+			}
+		}
+	}
+
+	return s.groupRepo.SetActive(ctx, groupID, false)
+}
+
+// UnarchiveGroup brings an archived group back into member group lists
+func (s *GroupService) UnarchiveGroup(ctx context.Context, groupID string, userID string) error {
+	group, err := s.adminGroup(ctx, groupID, userID)
+	if err != nil {
+		return err
+	}
+	if group.IsActive {
+		return nil
+	}
+
+	return s.groupRepo.SetActive(ctx, groupID, true)
+}
+
+// adminGroup loads the group on behalf of one of its admins
+func (s *GroupService) adminGroup(ctx context.Context, groupID string, userID string) (*models.Group, error) {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrGroupNotFound) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, err
+	}
+
+	member := activeMember(group, userID)
+	if member == nil || member.Role != models.RoleAdmin {
+		return nil, ErrNotGroupAdmin
+	}
+	return group, nil
+}
+
+// UpdateMemberRole promotes or demotes an active member. Demoting the last admin is refused
+// so the group can always be managed.
+func (s *GroupService) UpdateMemberRole(ctx context.Context, groupID string, adminUserID string, memberUserID string, role models.UserRole) (*models.Group, error) {
+	if role != models.RoleAdmin && role != models.RoleMember {
+		return nil, ErrInvalidMemberRole
+	}
+
+	isAdmin, err := s.isGroupAdmin(ctx, groupID, adminUserID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		return nil, ErrNotGroupAdmin
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	var target *models.GroupMember
+	admins := 0
+	for i, member := range group.Members {
+		if !member.IsActive {
+			continue
+		}
+		if member.Role == models.RoleAdmin {
+			admins++
+		}
+		if member.UserID == memberUserID {
+			target = &group.Members[i]
+		}
+	}
+	if target == nil {
+		return nil, ErrGroupMemberNotFound
+	}
+	if target.Role == role {
+		return group, nil
+	}
+	if target.Role == models.RoleAdmin && admins == 1 {
+		return nil, ErrLastGroupAdmin
+	}
+
+	if err := s.groupRepo.UpdateMemberRole(ctx, groupID, memberUserID, role); err != nil {
+		return nil, err
+	}
+	s.aggregates.EvictGroupMembers(ctx, groupID)
+
+	return s.groupRepo.GetByID(ctx, groupID)
+}
+
+func (s *GroupService) UpdateSettings(ctx context.Context, groupID string, userID string, req UpdateGroupSettingsRequest) (*models.Group, error) {
+	isAdmin, err := s.isGroupAdmin(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !isAdmin {
+		return nil, ErrNotGroupAdmin
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := group.Settings
+	if req.SettlementConfirmation != nil {
+		if !req.SettlementConfirmation.IsValid() {
+			return nil, ErrInvalidGroupSettings
+		}
+		settings.SettlementConfirmation = *req.SettlementConfirmation
+	}
+	if req.AutoConfirmAfterHours != nil {
+		if *req.AutoConfirmAfterHours < 0 {
+			return nil, ErrInvalidGroupSettings
+		}
+		settings.AutoConfirmAfterHours = req.AutoConfirmAfterHours
+	}
+	if req.MultiCurrency != nil {
+		settings.MultiCurrency = *req.MultiCurrency
+	}
+	if req.ExpenseSoftLimits != nil {
+		limits := make(map[string]float64, len(req.ExpenseSoftLimits))
+		for currency, limit := range req.ExpenseSoftLimits {
+			currency = strings.ToUpper(strings.TrimSpace(currency))
+			if len(currency) != 3 || limit < 0 {
+				return nil, ErrInvalidGroupSettings
+			}
+			limits[currency] = limit
+		}
+		settings.ExpenseSoftLimits = limits
+	}
+	if req.CrossGroupNetting != nil {
+		settings.CrossGroupNetting = *req.CrossGroupNetting
+	}
+	if req.DefaultSplitType != nil {
+		if *req.DefaultSplitType != "" && !req.DefaultSplitType.IsValid() {
+			return nil, ErrInvalidGroupSettings
+		}
+		settings.DefaultSplitType = *req.DefaultSplitType
+	}
+	if req.SimplifyDebts != nil {
+		settings.SimplifyDebts = *req.SimplifyDebts
+	}
+	if req.AllowMemberInvites != nil {
+		settings.AllowMemberInvites = *req.AllowMemberInvites
+	}
+	if req.ExpenseApprovalThreshold != nil {
+		if *req.ExpenseApprovalThreshold < 0 {
+			return nil, ErrInvalidGroupSettings
+		}
+		settings.ExpenseApprovalThreshold = *req.ExpenseApprovalThreshold
+	}
+	if req.PrivateBalances != nil {
+		settings.PrivateBalances = *req.PrivateBalances
+	}
+
+	return s.groupRepo.UpdateSettings(ctx, groupID, settings)
+}
+
+// AddMember adds a user to the group. Admins can add members or admins; when the group allows
+// member invites, any other active member can add members.
 func (s *GroupService) AddMember(ctx context.Context, groupID string, adminUserID string, req AddMemberRequest) error {
-	// Check if requester is an admin
 	isAdmin, err := s.isGroupAdmin(ctx, groupID, adminUserID)
 	if err != nil {
 		return err
 	}
 	if !isAdmin {
-		return ErrNotGroupAdmin
+		canInvite, err := s.canInviteMembers(ctx, groupID, adminUserID)
+		if err != nil {
+			return err
+		}
+		if !canInvite {
+			return ErrNotGroupAdmin
+		}
+		req.Role = string(models.RoleMember)
 	}
 
 	// Verify new member exists
@@ -155,11 +476,81 @@ func (s *GroupService) AddMember(ctx context.Context, groupID string, adminUserI
 		}
 		return err
 	}
+	s.aggregates.EvictGroupMembers(ctx, groupID)
+
+	s.notifyAdded(ctx, groupID, adminUserID, member)
 
 	return nil
 }
 
-func (s *GroupService) RemoveMember(ctx context.Context, groupID string, adminUserID string, memberUserID string) error {
+func (s *GroupService) notifyAdded(ctx context.Context, groupID string, adminUserID string, member models.GroupMember) {
+	notification := Notification{
+		UserID: member.UserID,
+		Type:   models.NotificationGroupInvited,
+		Title:  "You were added to a group",
+		Body:   "You were added to a group.",
+		Data: map[string]interface{}{
+			"group_id": groupID,
+			"role":     member.Role,
+		},
+	}
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err == nil {
+		notification.Title = "You were added to %s"
+		notification.Body = "You're now a member of %s and can see and add its expenses."
+		notification.TitleArgs = []interface{}{group.Name}
+		notification.BodyArgs = []interface{}{group.Name}
+	}
+
+	deliver(ctx, s.notifier, notification)
+
+	if group != nil {
+		s.sendInvitationEmail(ctx, group, adminUserID, member.UserID)
+	}
+}
+
+// sendInvitationEmail emails a newly added member. Placeholder members have no real address and are skipped.
+func (s *GroupService) sendInvitationEmail(ctx context.Context, group *models.Group, inviterID string, memberUserID string) {
+	if s.emailSender == nil {
+		return
+	}
+
+	users, err := s.userRepo.GetByIDs(ctx, []string{inviterID, memberUserID})
+	if err != nil {
+		log.Printf("Failed to load users for group %s invitation email: %v", group.GroupID, err)
+		return
+	}
+	var inviter, recipient *models.User
+	for _, user := range users {
+		switch user.UserID {
+		case inviterID:
+			inviter = user
+		case memberUserID:
+			recipient = user
+		}
+	}
+	if inviter == nil || recipient == nil || recipient.Placeholder {
+		return
+	}
+
+	msg, err := email.Render(email.TemplateInvitation, recipient.Preferences.Language, email.Address{Name: recipient.Name, Email: recipient.Email}, email.InvitationData{
+		RecipientName: recipient.Name,
+		InviterName:   inviter.Name,
+		GroupName:     group.Name,
+	})
+	if err != nil {
+		log.Printf("Failed to render invitation email: %v", err)
+		return
+	}
+	if err := s.emailSender.Send(ctx, msg); err != nil {
+		log.Printf("Failed to send invitation email to user %s: %v", memberUserID, err)
+	}
+}
+
+// RemoveMember removes a member on behalf of an admin. Members who still owe or are owed money in
+// the group can only be removed with forgive, which writes their balances off, and the last admin
+// can't be removed while others remain.
+func (s *GroupService) RemoveMember(ctx context.Context, groupID string, adminUserID string, memberUserID string, forgive bool) error {
 	// Check if requester is an admin
 	isAdmin, err := s.isGroupAdmin(ctx, groupID, adminUserID)
 	if err != nil {
@@ -169,11 +560,253 @@ func (s *GroupService) RemoveMember(ctx context.Context, groupID string, adminUs
 		return ErrNotGroupAdmin
 	}
 
-	return s.groupRepo.RemoveMember(ctx, groupID, memberUserID)
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if activeMember(group, memberUserID) == nil {
+		return ErrGroupMemberNotFound
+	}
+
+	scope := forgiveNone
+	if forgive {
+		scope = forgiveAll
+	}
+	if err := s.removeMember(ctx, group, memberUserID, scope); err != nil {
+		return err
+	}
+
+	s.notifyAdmins(ctx, group, adminUserID, memberUserID, Notification{
+		Type:  models.NotificationGroupMemberRemoved,
+		Title: "A member was removed from %s",
+		Body:  "An admin removed a member from %s.",
+
+		TitleArgs: []interface{}{group.Name},
+		BodyArgs:  []interface{}{group.Name},
+	})
+
+	return nil
+}
+
+// LeaveGroup removes the user from the group, with the same balance and last-admin checks as
+// RemoveMember. With forgive, what the user is still owed is written off; what they owe never is.
+func (s *GroupService) LeaveGroup(ctx context.Context, groupID string, userID string, forgive bool) error {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if activeMember(group, userID) == nil {
+		return ErrNotGroupMember
+	}
+
+	scope := forgiveNone
+	if forgive {
+		scope = forgiveCredits
+	}
+	if err := s.removeMember(ctx, group, userID, scope); err != nil {
+		return err
+	}
+
+	s.notifyAdmins(ctx, group, userID, userID, Notification{
+		Type:  models.NotificationGroupMemberLeft,
+		Title: "A member left %s",
+		Body:  "A member left %s.",
+
+		TitleArgs: []interface{}{group.Name},
+		BodyArgs:  []interface{}{group.Name},
+	})
+
+	return nil
+}
+
+// forgiveScope says which of a departing member's outstanding balances may be written off
+type forgiveScope int
+
+const (
+	forgiveNone forgiveScope = iota
+	// forgiveCredits writes off only what the member is owed, so nobody can walk away from a debt
+	forgiveCredits
+	// forgiveAll also writes off what the member owes, at the expense of the members they owe
+	forgiveAll
+)
+
+// removeMember deactivates an active member once their balances in the group are settled or
+// written off, unless they are the last admin of a group that still has other members
+func (s *GroupService) removeMember(ctx context.Context, group *models.Group, userID string, scope forgiveScope) error {
+	member := activeMember(group, userID)
+	if member.Role == models.RoleAdmin {
+		admins, others := 0, 0
+		for _, m := range group.Members {
+			if !m.IsActive || m.UserID == userID {
+				continue
+			}
+			others++
+			if m.Role == models.RoleAdmin {
+				admins++
+			}
+		}
+		if admins == 0 && others > 0 {
+			return ErrLastGroupAdmin
+		}
+	}
+
+	session, err := s.balanceRepo.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	// The balances are read in the transaction that writes them off and ends the membership, so
+	// what is checked is what the member leaves with. Their cached membership is only evicted
+	// once it commits.
+	txCtx, flushInvalidations := repositories.DeferInvalidation(ctx)
+	var userIDs []string
+	_, err = session.WithTransaction(txCtx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		userIDs = userIDs[:0]
+		balances, err := s.balanceRepo.GetByGroupID(sessCtx, group.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		var outstanding []*models.Balance
+		for _, balance := range balances {
+			// Anything that rounds to a cent is outstanding, as in the balance summaries
+			if balance.UserID == userID && math.Round(balance.Balance*100) != 0 {
+				outstanding = append(outstanding, balance)
+			}
+		}
+
+		if len(outstanding) > 0 {
+			if scope == forgiveNone {
+				return nil, ErrOutstandingBalance
+			}
+			for _, balance := range outstanding {
+				if balance.Balance < 0 && scope != forgiveAll {
+					return nil, ErrCannotForgiveDebt
+				}
+			}
+			if err := s.writeOffBalances(sessCtx, group.GroupID, userID, outstanding, balances); err != nil {
+				return nil, err
+			}
+			// The write-off moves the balances of the members owed or owing
+			for _, balance := range balances {
+				userIDs = append(userIDs, balance.UserID)
+			}
+		}
+
+		return nil, s.groupRepo.RemoveMember(sessCtx, group.GroupID, userID)
+	})
+	flushInvalidations()
+	if err != nil {
+		if errors.Is(err, ErrOutstandingBalance) || errors.Is(err, ErrCannotForgiveDebt) || errors.Is(err, ErrWriteOffUnbalanced) ||
+			errors.Is(err, repositories.ErrMemberNotInGroup) {
+			return err
+		}
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+
+	s.aggregates.EvictBalances(ctx, userIDs...)
+	s.aggregates.EvictGroupMembers(ctx, group.GroupID)
+	return nil
 }
 
-func (s *GroupService) LeaveGroup(ctx context.Context, groupID string, userID string) error {
-	return s.groupRepo.RemoveMember(ctx, groupID, userID)
+// writeOffBalances brings the member's outstanding balances to zero; ctx carries the caller's
+// transaction. The members on the other side absorb each amount in proportion to their own
+// balance in that currency: what the member was owed comes off the debtors' debts, and what they
+// owed comes off the creditors' credit. The group's balances keep netting to zero.
+func (s *GroupService) writeOffBalances(ctx context.Context, groupID string, userID string, outstanding []*models.Balance, balances []*models.Balance) error {
+	type change struct {
+		userID   string
+		currency string
+		amount   float64
+	}
+
+	var changes []change
+	for _, balance := range outstanding {
+		var counterparts []*models.Balance
+		total := 0.0
+		for _, other := range balances {
+			if other.UserID == userID || other.Currency != balance.Currency || other.Balance*balance.Balance >= 0 {
+				continue
+			}
+			counterparts = append(counterparts, other)
+			total += math.Abs(other.Balance)
+		}
+		if total == 0 {
+			return fmt.Errorf("%w: no balances in %s to write off %.2f against", ErrWriteOffUnbalanced, balance.Currency, balance.Balance)
+		}
+
+		// Split in cents; leftover cents go to the largest counterpart balances
+		sort.Slice(counterparts, func(i, j int) bool {
+			return math.Abs(counterparts[i].Balance) > math.Abs(counterparts[j].Balance)
+		})
+		totalCents := int64(math.Round(math.Abs(balance.Balance) * 100))
+		cents := make([]int64, len(counterparts))
+		allocated := int64(0)
+		for i, other := range counterparts {
+			cents[i] = int64(math.Floor(float64(totalCents) * math.Abs(other.Balance) / total))
+			allocated += cents[i]
+		}
+		for i := 0; allocated < totalCents; i = (i + 1) % len(counterparts) {
+			cents[i]++
+			allocated++
+		}
+
+		sign := 1.0
+		if balance.Balance < 0 {
+			sign = -1
+		}
+		changes = append(changes, change{userID: userID, currency: balance.Currency, amount: -balance.Balance})
+		for i, other := range counterparts {
+			if cents[i] > 0 {
+				changes = append(changes, change{userID: other.UserID, currency: balance.Currency, amount: sign * float64(cents[i]) / 100})
+			}
+		}
+	}
+
+	now := time.Now()
+	for _, c := range changes {
+		if err := s.balanceRepo.UpdateBalance(ctx, c.userID, &groupID, c.currency, c.amount); err != nil {
+			return err
+		}
+		history := &models.BalanceHistory{
+			UserID:      c.userID,
+			GroupID:     &groupID,
+			Amount:      c.amount,
+			Currency:    c.currency,
+			Type:        models.BalanceChangeAdjustment,
+			ReferenceID: userID,
+			Description: "Balance written off when a member left the group",
+			CreatedAt:   now,
+		}
+		if err := s.balanceRepo.CreateBalanceHistory(ctx, history); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyAdmins sends the notification to the group's remaining active admins other than the actor
+func (s *GroupService) notifyAdmins(ctx context.Context, group *models.Group, actorID string, memberUserID string, notification Notification) {
+	notification.Data = map[string]interface{}{
+		"group_id":  group.GroupID,
+		"member_id": memberUserID,
+	}
+	for _, member := range group.Members {
+		if !member.IsActive || member.Role != models.RoleAdmin || member.UserID == actorID || member.UserID == memberUserID {
+			continue
+		}
+		notification.UserID = member.UserID
+		deliver(ctx, s.notifier, notification)
+	}
+}
+
+func activeMember(group *models.Group, userID string) *models.GroupMember {
+	for i, member := range group.Members {
+		if member.IsActive && member.UserID == userID {
+			return &group.Members[i]
+		}
+	}
+	return nil
 }
 
 func (s *GroupService) GetMembers(ctx context.Context, groupID string, userID string) ([]repositories.MemberWithUser, error) {
@@ -186,7 +819,57 @@ func (s *GroupService) GetMembers(ctx context.Context, groupID string, userID st
 		return nil, ErrNotGroupMember
 	}
 
-	return s.groupRepo.GetMembersWithDetails(ctx, groupID)
+	return s.aggregates.GroupMembers(ctx, groupID, func(ctx context.Context) ([]repositories.MemberWithUser, error) {
+		return s.groupRepo.GetMembersWithDetails(ctx, groupID)
+	})
+}
+
+// requireGroupMember returns ErrNotGroupMember unless the user is an active member of the group.
+// A missing group is reported the same way so non-members can't probe for group IDs.
+func requireGroupMember(ctx context.Context, groupRepo repositories.GroupRepository, groupID string, userID string) error {
+	isMember, err := groupRepo.IsMember(ctx, groupID, userID)
+	if err != nil {
+		return err
+	}
+	if !isMember {
+		return ErrNotGroupMember
+	}
+	return nil
+}
+
+// canInviteMembers reports whether a non-admin member may add members to the group
+func (s *GroupService) canInviteMembers(ctx context.Context, groupID string, userID string) (bool, error) {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrGroupNotFound) {
+			return false, ErrGroupNotFound
+		}
+		return false, err
+	}
+	if !group.Settings.AllowMemberInvites {
+		return false, nil
+	}
+	for _, member := range group.Members {
+		if member.UserID == userID && member.IsActive {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// requireGroupAdmin loads the group and returns ErrNotGroupAdmin unless the user is one of its active admins
+func requireGroupAdmin(ctx context.Context, groupRepo repositories.GroupRepository, groupID string, userID string) (*models.Group, error) {
+	group, err := groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrGroupNotFound) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, err
+	}
+	if member := activeMember(group, userID); member == nil || member.Role != models.RoleAdmin {
+		return nil, ErrNotGroupAdmin
+	}
+	return group, nil
 }
 
 func (s *GroupService) isGroupAdmin(ctx context.Context, groupID string, userID string) (bool, error) {
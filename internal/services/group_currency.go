@@ -0,0 +1,218 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidCurrencyChange   = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidCurrencyChange, "currency must be a 3-letter code and mode either convert or keep")
+	ErrExchangeRateUnavailable = utils.NewCustomError(http.StatusUnprocessableEntity, utils.CodeExchangeRateUnavailable, "no exchange rate is available between these currencies; try again later or keep the balances in the old currency")
+	ErrSettlementsOpen         = utils.NewCustomError(http.StatusConflict, utils.CodeSettlementsOpen, "the group has settlements in its currency waiting to be completed; complete or cancel them before converting its balances")
+)
+
+// ChangeCurrencyRequest moves a group to another currency. Mode says what happens to the
+// balances in the old one.
+type ChangeCurrencyRequest struct {
+	Currency string                    `json:"currency" binding:"required,len=3"`
+	Mode     models.CurrencyChangeMode `json:"mode" binding:"required,oneof=convert keep"`
+}
+
+// ChangeCurrency switches the group to another currency on behalf of an admin, recording the
+// change on the group. New expenses then default to the new currency, while existing expenses
+// and settlements keep the one they were recorded in.
+//
+// In convert mode, every member's balance in the old currency is converted at the current rate,
+// in the same transaction as the change, with an adjustment in each currency in their history.
+// Settlements in the old currency that are still open would move balances that no longer exist,
+// so they must be completed or cancelled first. In keep mode the balances stay in the old
+// currency, and the group's totals report them converted into the new one.
+func (s *GroupService) ChangeCurrency(ctx context.Context, groupID string, userID string, req ChangeCurrencyRequest) (*models.Group, error) {
+	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if len(currency) != 3 || !req.Mode.IsValid() {
+		return nil, ErrInvalidCurrencyChange
+	}
+
+	group, err := s.adminGroup(ctx, groupID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !group.IsActive {
+		return nil, ErrGroupArchived
+	}
+	if currency == group.Currency {
+		return group, nil
+	}
+
+	change := models.CurrencyChange{
+		From:      group.Currency,
+		To:        currency,
+		Mode:      req.Mode,
+		ChangedBy: userID,
+		ChangedAt: time.Now(),
+	}
+	if req.Mode == models.CurrencyChangeKeep {
+		return s.groupRepo.ChangeCurrency(ctx, groupID, change)
+	}
+	return s.convertCurrency(ctx, group, change)
+}
+
+func (s *GroupService) convertCurrency(ctx context.Context, group *models.Group, change models.CurrencyChange) (*models.Group, error) {
+	if s.converter == nil {
+		return nil, ErrExchangeRateUnavailable
+	}
+	rate, err := s.converter.Convert(ctx, 1, change.From, change.To)
+	if err != nil {
+		log.Printf("No exchange rate to convert group %s from %s to %s: %v", group.GroupID, change.From, change.To, err)
+		return nil, ErrExchangeRateUnavailable
+	}
+	change.Rate = rate
+
+	session, err := s.balanceRepo.StartSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	groupID := group.GroupID
+	var userIDs []string
+	result, err := session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		// Settlements are created in a transaction that writes the group, as ChangeCurrency does
+		// below, so one created after this check makes the conversion conflict and retry
+		open, err := s.settlementRepo.CountOpenInGroup(sessCtx, groupID, change.From)
+		if err != nil {
+			return nil, err
+		}
+		if open > 0 {
+			return nil, ErrSettlementsOpen
+		}
+
+		balances, err := s.balanceRepo.GetByGroupID(sessCtx, groupID)
+		if err != nil {
+			return nil, err
+		}
+		var converting []*models.Balance
+		for _, balance := range balances {
+			if balance.Currency == change.From && balance.Balance != 0 {
+				converting = append(converting, balance)
+			}
+		}
+
+		userIDs = userIDs[:0]
+		description := fmt.Sprintf("Balance converted from %s to %s at %g when the group changed currency", change.From, change.To, rate)
+		for i, cents := range convertedCents(converting, rate) {
+			balance := converting[i]
+			userIDs = append(userIDs, balance.UserID)
+			adjustments := []struct {
+				currency string
+				amount   float64
+			}{
+				{change.From, -balance.Balance},
+				{change.To, float64(cents) / 100},
+			}
+			for _, adjustment := range adjustments {
+				if adjustment.amount == 0 {
+					continue
+				}
+				if err := s.balanceRepo.UpdateBalance(sessCtx, balance.UserID, &groupID, adjustment.currency, adjustment.amount); err != nil {
+					return nil, err
+				}
+				history := &models.BalanceHistory{
+					UserID:      balance.UserID,
+					GroupID:     &groupID,
+					Amount:      adjustment.amount,
+					Currency:    adjustment.currency,
+					Type:        models.BalanceChangeAdjustment,
+					ReferenceID: groupID,
+					Description: description,
+					CreatedAt:   change.ChangedAt,
+				}
+				if err := s.balanceRepo.CreateBalanceHistory(sessCtx, history); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return s.groupRepo.ChangeCurrency(sessCtx, groupID, change)
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrGroupCurrencyChanged) || errors.Is(err, ErrSettlementsOpen) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("transaction failed: %v", err)
+	}
+
+	s.aggregates.EvictBalances(ctx, userIDs...)
+	return result.(*models.Group), nil
+}
+
+// convertedCents converts the balances at rate into cents. Rounding each balance on its own can
+// leave the group not netting to what it did, so the difference is made up a cent at a time on
+// the largest balances.
+func convertedCents(balances []*models.Balance, rate float64) []int64 {
+	cents := make([]int64, len(balances))
+	total, target := int64(0), 0.0
+	for i, balance := range balances {
+		cents[i] = int64(math.Round(balance.Balance * rate * 100))
+		total += cents[i]
+		target += balance.Balance
+	}
+	diff := total - int64(math.Round(target*rate*100))
+	if diff == 0 {
+		return cents
+	}
+
+	order := make([]int, len(balances))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return math.Abs(balances[order[i]].Balance) > math.Abs(balances[order[j]].Balance)
+	})
+	for i := 0; diff != 0; i = (i + 1) % len(order) {
+		if diff > 0 {
+			cents[order[i]]--
+			diff--
+		} else {
+			cents[order[i]]++
+			diff++
+		}
+	}
+	return cents
+}
+
+// keptBalance is what the group's kept currencies in balances come to in its currency. Those
+// without an exchange rate are left out.
+func (s *GroupService) keptBalance(ctx context.Context, group *models.Group, balances []models.CurrencyTotal) float64 {
+	kept := group.KeptCurrencies()
+	if s.converter == nil || len(kept) == 0 {
+		return 0
+	}
+
+	total := 0.0
+	for _, balance := range balances {
+		for _, currency := range kept {
+			if balance.Currency != currency {
+				continue
+			}
+			converted, err := s.converter.Convert(ctx, balance.Balance, balance.Currency, group.Currency)
+			if err == nil {
+				total += math.Round(converted*100) / 100
+			}
+		}
+	}
+	return total
+}
@@ -0,0 +1,363 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrWebhookNotFound     = utils.NewCustomError(http.StatusNotFound, utils.CodeWebhookNotFound, "group has no webhook")
+	ErrInvalidWebhookURL   = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidWebhookURL, "webhook url must be an absolute https URL")
+	errWebhookAddressBlock = errors.New("webhook host resolves to a private or local address")
+)
+
+const (
+	// EventWebhookPing is sent by SendTest so an endpoint can be checked before real events arrive
+	EventWebhookPing EventType = "webhook.ping"
+
+	WebhookSignatureHeader = "X-DivvyDoo-Signature"
+	WebhookEventHeader     = "X-DivvyDoo-Event"
+	WebhookDeliveryHeader  = "X-DivvyDoo-Delivery"
+
+	maxWebhookURLLength = 2000
+	webhookSecretBytes  = 32
+	webhookTimeout      = 10 * time.Second
+	webhookWorkers      = 4
+	webhookQueueSize    = 1000
+)
+
+// webhookRetryDelays are the waits before the second and later attempts of a delivery
+var webhookRetryDelays = []time.Duration{time.Second, 5 * time.Second}
+
+// webhookEvents are the events groups receive on their webhook
+var webhookEvents = map[EventType]bool{
+	EventExpenseCreated:    true,
+	EventExpenseUpdated:    true,
+	EventSettlementUpdated: true,
+}
+
+// SetGroupWebhookRequest points the group's webhook at URL. The signing secret is kept when only
+// the URL changes, unless RotateSecret is set.
+type SetGroupWebhookRequest struct {
+	URL          string `json:"url" binding:"required"`
+	RotateSecret bool   `json:"rotate_secret,omitempty"`
+}
+
+// GroupWebhookService manages the groups' outbound webhooks and delivers their events. Events
+// are queued by the publisher from Publisher and delivered by Run, one group at a time per
+// worker so each group's events arrive in order.
+type GroupWebhookService struct {
+	webhookRepo repositories.GroupWebhookRepository
+	groupRepo   repositories.GroupRepository
+	client      *http.Client
+	queues      []chan Event
+}
+
+func NewGroupWebhookService(
+	webhookRepo repositories.GroupWebhookRepository,
+	groupRepo repositories.GroupRepository,
+) *GroupWebhookService {
+	queues := make([]chan Event, webhookWorkers)
+	for i := range queues {
+		queues[i] = make(chan Event, webhookQueueSize/webhookWorkers)
+	}
+	return &GroupWebhookService{
+		webhookRepo: webhookRepo,
+		groupRepo:   groupRepo,
+		client:      newWebhookClient(),
+		queues:      queues,
+	}
+}
+
+// GetWebhook returns the group's webhook, without its secret
+func (s *GroupWebhookService) GetWebhook(ctx context.Context, groupID string, userID string) (*models.GroupWebhook, error) {
+	if _, err := requireGroupAdmin(ctx, s.groupRepo, groupID, userID); err != nil {
+		return nil, err
+	}
+	return s.getWebhook(ctx, groupID)
+}
+
+// SetWebhook creates or updates the group's webhook. The returned webhook carries the signing
+// secret when it was created or rotated.
+func (s *GroupWebhookService) SetWebhook(ctx context.Context, groupID string, userID string, req SetGroupWebhookRequest) (*models.GroupWebhook, error) {
+	if _, err := requireGroupAdmin(ctx, s.groupRepo, groupID, userID); err != nil {
+		return nil, err
+	}
+	webhookURL, err := validateWebhookURL(req.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	webhook, err := s.webhookRepo.GetByGroupID(ctx, groupID)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrWebhookNotFound) {
+			return nil, err
+		}
+		webhook = &models.GroupWebhook{GroupID: groupID, CreatedBy: userID, CreatedAt: now}
+	}
+
+	if webhook.URL != webhookURL {
+		webhook.URL = webhookURL
+		webhook.LastDelivery = nil
+		webhook.ConsecutiveFailures = 0
+	}
+	if webhook.Secret == "" || req.RotateSecret {
+		raw := make([]byte, webhookSecretBytes)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, fmt.Errorf("failed to generate secret: %v", err)
+		}
+		webhook.Secret = "whsec_" + base64.RawURLEncoding.EncodeToString(raw)
+		webhook.SigningSecret = webhook.Secret
+	}
+	webhook.UpdatedAt = now
+
+	if err := s.webhookRepo.Upsert(ctx, webhook); err != nil {
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// DeleteWebhook stops the group's events from being sent
+func (s *GroupWebhookService) DeleteWebhook(ctx context.Context, groupID string, userID string) error {
+	if _, err := requireGroupAdmin(ctx, s.groupRepo, groupID, userID); err != nil {
+		return err
+	}
+	if err := s.webhookRepo.Delete(ctx, groupID); err != nil {
+		if errors.Is(err, repositories.ErrWebhookNotFound) {
+			return ErrWebhookNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// SendTest delivers a webhook.ping event right away, without retries, and returns the outcome
+func (s *GroupWebhookService) SendTest(ctx context.Context, groupID string, userID string) (*models.WebhookDelivery, error) {
+	if _, err := requireGroupAdmin(ctx, s.groupRepo, groupID, userID); err != nil {
+		return nil, err
+	}
+	webhook, err := s.getWebhook(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery := s.attempt(ctx, webhook, models.WebhookPayload{
+		DeliveryID: uuid.New().String(),
+		Type:       string(EventWebhookPing),
+		GroupID:    groupID,
+		OccurredAt: time.Now(),
+	})
+	if err := s.webhookRepo.RecordDelivery(ctx, groupID, delivery); err != nil {
+		log.Printf("Failed to record webhook delivery for group %s: %v", groupID, err)
+	}
+	return &delivery, nil
+}
+
+func (s *GroupWebhookService) getWebhook(ctx context.Context, groupID string) (*models.GroupWebhook, error) {
+	webhook, err := s.webhookRepo.GetByGroupID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrWebhookNotFound) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, err
+	}
+	return webhook, nil
+}
+
+// Publisher returns an EventPublisher that passes every event on to next and also queues the
+// group expense and settlement events for the groups' webhooks. When the queue is full the
+// event is dropped from the webhook and logged; next still gets it.
+func (s *GroupWebhookService) Publisher(next EventPublisher) EventPublisher {
+	return webhookPublisher{next: next, service: s}
+}
+
+type webhookPublisher struct {
+	next    EventPublisher
+	service *GroupWebhookService
+}
+
+func (p webhookPublisher) Publish(event Event) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	p.next.Publish(event)
+
+	if event.GroupID == nil || !webhookEvents[event.Type] {
+		return
+	}
+	select {
+	case p.service.queueFor(*event.GroupID) <- event:
+	default:
+		log.Printf("Webhook queue full, dropping %s event for group %s", event.Type, *event.GroupID)
+	}
+}
+
+func (s *GroupWebhookService) queueFor(groupID string) chan Event {
+	h := fnv.New32a()
+	h.Write([]byte(groupID))
+	return s.queues[h.Sum32()%uint32(len(s.queues))]
+}
+
+// Run delivers queued events until ctx is done
+func (s *GroupWebhookService) Run(ctx context.Context) {
+	for _, queue := range s.queues {
+		go func(queue chan Event) {
+			for {
+				select {
+				case event := <-queue:
+					s.deliver(ctx, event)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(queue)
+	}
+	<-ctx.Done()
+}
+
+// deliver sends the event to its group's webhook, if the group has one, retrying failed attempts
+func (s *GroupWebhookService) deliver(ctx context.Context, event Event) {
+	groupID := *event.GroupID
+	webhook, err := s.webhookRepo.GetByGroupID(ctx, groupID)
+	if err != nil {
+		if !errors.Is(err, repositories.ErrWebhookNotFound) {
+			log.Printf("Failed to load webhook of group %s: %v", groupID, err)
+		}
+		return
+	}
+
+	payload := models.WebhookPayload{
+		DeliveryID: uuid.New().String(),
+		Type:       string(event.Type),
+		GroupID:    groupID,
+		OccurredAt: event.OccurredAt,
+		Data:       event.Data,
+	}
+
+	var delivery models.WebhookDelivery
+	for attempt := 0; ; attempt++ {
+		delivery = s.attempt(ctx, webhook, payload)
+		if delivery.Succeeded || attempt >= len(webhookRetryDelays) {
+			break
+		}
+		select {
+		case <-time.After(webhookRetryDelays[attempt]):
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	if !delivery.Succeeded {
+		log.Printf("Webhook delivery %s of %s event to group %s failed: status %d %s",
+			payload.DeliveryID, event.Type, groupID, delivery.StatusCode, delivery.Error)
+	}
+	if err := s.webhookRepo.RecordDelivery(ctx, groupID, delivery); err != nil {
+		log.Printf("Failed to record webhook delivery for group %s: %v", groupID, err)
+	}
+}
+
+// attempt POSTs the payload once. Any 2xx response counts as delivered.
+func (s *GroupWebhookService) attempt(ctx context.Context, webhook *models.GroupWebhook, payload models.WebhookPayload) models.WebhookDelivery {
+	delivery := models.WebhookDelivery{
+		DeliveryID:  payload.DeliveryID,
+		EventType:   payload.Type,
+		AttemptedAt: time.Now(),
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		delivery.Error = fmt.Sprintf("failed to encode payload: %v", err)
+		return delivery
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "DivvyDoo-Webhook/1.0")
+	req.Header.Set(WebhookEventHeader, payload.Type)
+	req.Header.Set(WebhookDeliveryHeader, payload.DeliveryID)
+	req.Header.Set(WebhookSignatureHeader, signWebhookPayload(webhook.Secret, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		delivery.Error = err.Error()
+		return delivery
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	delivery.StatusCode = resp.StatusCode
+	delivery.Succeeded = resp.StatusCode >= 200 && resp.StatusCode < 300
+	return delivery
+}
+
+// signWebhookPayload returns "sha256=" and the hex HMAC-SHA256 of body keyed with secret
+func signWebhookPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func validateWebhookURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if len(raw) > maxWebhookURLLength {
+		return "", ErrInvalidWebhookURL
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "https" || parsed.Host == "" || parsed.User != nil {
+		return "", ErrInvalidWebhookURL
+	}
+	return parsed.String(), nil
+}
+
+// newWebhookClient returns a client that only connects to public addresses, so a webhook can't be
+// pointed at the server's own network. Redirects are followed, as Google Apps Script needs.
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+				ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+				return errWebhookAddressBlock
+			}
+			return nil
+		},
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: webhookTimeout, Transport: transport}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"divvydoo/backend/internal/metrics"
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrJobNotFound       = utils.NewCustomError(http.StatusNotFound, utils.CodeJobNotFound, "job not found")
+	ErrJobAlreadyRunning = utils.NewCustomError(http.StatusConflict, utils.CodeJobAlreadyRunning, "a job of this type is already running")
+)
+
+const (
+	// jobHeartbeatInterval is how often a running job records that it is still alive
+	jobHeartbeatInterval = 30 * time.Second
+	// jobStaleAfter is how long a job can go without a heartbeat before it is taken as lost with
+	// the process that ran it, and failed
+	jobStaleAfter = 5 * time.Minute
+)
+
+// ProgressFunc reports how many of the total units of work a job has completed
+type ProgressFunc func(progress, total int)
+
+// JobFunc is the body of a background job. The returned map is stored as the job result.
+type JobFunc func(ctx context.Context, progress ProgressFunc) (map[string]interface{}, error)
+
+type JobService struct {
+	jobRepo repositories.JobRepository
+}
+
+func NewJobService(jobRepo repositories.JobRepository) *JobService {
+	return &JobService{jobRepo: jobRepo}
+}
+
+// Start records a new job and runs fn in the background. The job keeps running
+// after the originating request finishes.
+func (s *JobService) Start(ctx context.Context, jobType string, createdBy string, fn JobFunc) (*models.Job, error) {
+	return s.start(ctx, &models.Job{Type: jobType, CreatedBy: createdBy}, fn)
+}
+
+// StartExclusive is Start for jobs that must not run alongside another of their type, on any
+// replica. It fails with ErrJobAlreadyRunning while one is unfinished.
+func (s *JobService) StartExclusive(ctx context.Context, jobType string, createdBy string, fn JobFunc) (*models.Job, error) {
+	return s.start(ctx, &models.Job{Type: jobType, CreatedBy: createdBy, Lock: jobType}, fn)
+}
+
+func (s *JobService) start(ctx context.Context, job *models.Job, fn JobFunc) (*models.Job, error) {
+	job.JobID = uuid.New().String()
+
+	job, err := s.jobRepo.Create(ctx, job)
+	if err != nil {
+		if errors.Is(err, repositories.ErrJobAlreadyRunning) {
+			return nil, ErrJobAlreadyRunning
+		}
+		return nil, err
+	}
+
+	go s.run(job.JobID, job.Type, fn)
+
+	return job, nil
+}
+
+func (s *JobService) run(jobID string, jobType string, fn JobFunc) {
+	ctx := context.Background()
+
+	// A panicking job fails on its own instead of taking the process down
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			log.Printf("Job %s (%s) panicked: %v\n%s", jobID, jobType, recovered, debug.Stack())
+			if err := s.jobRepo.MarkFailed(ctx, jobID, fmt.Sprintf("panic: %v", recovered)); err != nil {
+				log.Printf("Job %s (%s): failed to mark failed: %v", jobID, jobType, err)
+			}
+		}
+	}()
+
+	if err := s.jobRepo.MarkRunning(ctx, jobID); err != nil {
+		log.Printf("Job %s (%s): failed to mark running: %v", jobID, jobType, err)
+	}
+
+	heartbeatCtx, stopHeartbeat := context.WithCancel(ctx)
+	defer stopHeartbeat()
+	go s.heartbeat(heartbeatCtx, jobID, jobType)
+
+	progress := func(done, total int) {
+		if err := s.jobRepo.UpdateProgress(ctx, jobID, done, total); err != nil {
+			log.Printf("Job %s (%s): failed to update progress: %v", jobID, jobType, err)
+		}
+	}
+
+	started := time.Now()
+	result, err := fn(ctx, progress)
+	metrics.ObserveWorkerRun("job_"+jobType, started, err)
+	if err != nil {
+		log.Printf("Job %s (%s) failed: %v", jobID, jobType, err)
+		if err := s.jobRepo.MarkFailed(ctx, jobID, err.Error()); err != nil {
+			log.Printf("Job %s (%s): failed to mark failed: %v", jobID, jobType, err)
+		}
+		return
+	}
+
+	if err := s.jobRepo.MarkSucceeded(ctx, jobID, result); err != nil {
+		log.Printf("Job %s (%s): failed to mark succeeded: %v", jobID, jobType, err)
+	}
+}
+
+func (s *JobService) heartbeat(ctx context.Context, jobID string, jobType string) {
+	ticker := time.NewTicker(jobHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := s.jobRepo.Heartbeat(ctx, jobID); err != nil && ctx.Err() == nil {
+				log.Printf("Job %s (%s): failed to record heartbeat: %v", jobID, jobType, err)
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Run fails the jobs lost with the process that ran them, which would otherwise show as running
+// forever and hold their lock: on startup, then every jobStaleAfter until ctx is done.
+func (s *JobService) Run(ctx context.Context) {
+	ticker := time.NewTicker(jobStaleAfter)
+	defer ticker.Stop()
+	for {
+		failed, err := s.jobRepo.FailStale(ctx, time.Now().Add(-jobStaleAfter), "the job was interrupted")
+		if err != nil {
+			log.Printf("Failed to fail interrupted jobs: %v", err)
+		} else if failed > 0 {
+			log.Printf("Failed %d interrupted jobs", failed)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (s *JobService) GetJob(ctx context.Context, jobID string) (*models.Job, error) {
+	job, err := s.jobRepo.GetByID(ctx, jobID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrJobNotFound) {
+			return nil, ErrJobNotFound
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+func (s *JobService) ListJobs(ctx context.Context, jobType string, limit int64) ([]*models.Job, error) {
+	return s.jobRepo.List(ctx, jobType, limit)
+}
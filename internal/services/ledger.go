@@ -0,0 +1,146 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readconcern"
+)
+
+const (
+	// FeatureLedgerShadow mirrors balance updates into the integer-cents ledger. Enable it per
+	// group with the ledger_shadow:<group ID> feature flag, or for every group with ledger_shadow.
+	FeatureLedgerShadow = "ledger_shadow"
+
+	JobLedgerCompare = "ledger_compare"
+
+	// ledgerDiscrepancyLimit caps how many discrepancies a comparison stores in its job result
+	ledgerDiscrepancyLimit = 100
+)
+
+// LedgerService compares the shadow ledger with the balances it is meant to replace
+type LedgerService struct {
+	ledgerRepo  repositories.LedgerRepository
+	balanceRepo repositories.BalanceRepository
+	jobService  *JobService
+}
+
+func NewLedgerService(ledgerRepo repositories.LedgerRepository, balanceRepo repositories.BalanceRepository, jobService *JobService) *LedgerService {
+	return &LedgerService{
+		ledgerRepo:  ledgerRepo,
+		balanceRepo: balanceRepo,
+		jobService:  jobService,
+	}
+}
+
+// StartComparison checks every shadowed group in the background. A discrepancy seen once may be a
+// write that landed between the two reads; one that persists across runs is real.
+func (s *LedgerService) StartComparison(ctx context.Context, adminID string) (*models.Job, error) {
+	return s.jobService.StartExclusive(ctx, JobLedgerCompare, adminID, s.compare)
+}
+
+func (s *LedgerService) compare(ctx context.Context, progress ProgressFunc) (map[string]interface{}, error) {
+	groupIDs, err := s.ledgerRepo.GetGroupIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	checked := 0
+	discrepancies := []models.LedgerDiscrepancy{}
+	found := 0
+	for i, groupID := range groupIDs {
+		groupChecked, groupDiscrepancies, err := s.compareGroup(ctx, groupID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compare group %s: %w", groupID, err)
+		}
+
+		checked += groupChecked
+		found += len(groupDiscrepancies)
+		for _, d := range groupDiscrepancies {
+			log.Printf("Ledger discrepancy in group %s: user %s %s balance %d cents, ledger %d cents",
+				d.GroupID, d.UserID, d.Currency, d.BalanceCents, d.LedgerCents)
+			if len(discrepancies) < ledgerDiscrepancyLimit {
+				discrepancies = append(discrepancies, d)
+			}
+		}
+		progress(i+1, len(groupIDs))
+	}
+
+	return map[string]interface{}{
+		"groups_checked":     len(groupIDs),
+		"balances_checked":   checked,
+		"discrepancy_count":  found,
+		"discrepancies":      discrepancies,
+		"discrepancies_more": found > len(discrepancies),
+	}, nil
+}
+
+// compareGroup reads the group's balances and ledger totals from one snapshot, so updates
+// committed in between can't show up on one side only
+func (s *LedgerService) compareGroup(ctx context.Context, groupID string) (int, []models.LedgerDiscrepancy, error) {
+	session, err := s.balanceRepo.StartSession()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer session.EndSession(ctx)
+
+	var (
+		balances []*models.Balance
+		totals   []repositories.LedgerTotal
+	)
+	txnOpts := options.Transaction().SetReadConcern(readconcern.Snapshot())
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		var err error
+		if balances, err = s.balanceRepo.GetByGroupID(sessCtx, groupID); err != nil {
+			return nil, err
+		}
+		totals, err = s.ledgerRepo.GetTotalsByGroupID(sessCtx, groupID)
+		return nil, err
+	}, txnOpts)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	type balanceKey struct{ userID, currency string }
+	ledger := make(map[balanceKey]int64, len(totals))
+	for _, total := range totals {
+		ledger[balanceKey{total.UserID, total.Currency}] = total.Cents
+	}
+
+	var discrepancies []models.LedgerDiscrepancy
+	for _, balance := range balances {
+		key := balanceKey{balance.UserID, balance.Currency}
+		balanceCents := int64(math.Round(balance.Balance * 100))
+		ledgerCents := ledger[key]
+		delete(ledger, key)
+		if balanceCents != ledgerCents {
+			discrepancies = append(discrepancies, models.LedgerDiscrepancy{
+				GroupID:      groupID,
+				UserID:       balance.UserID,
+				Currency:     balance.Currency,
+				BalanceCents: balanceCents,
+				LedgerCents:  ledgerCents,
+			})
+		}
+	}
+	// Ledger balances without an old balance at all
+	for key, cents := range ledger {
+		if cents != 0 {
+			discrepancies = append(discrepancies, models.LedgerDiscrepancy{
+				GroupID:     groupID,
+				UserID:      key.userID,
+				Currency:    key.currency,
+				LedgerCents: cents,
+			})
+		}
+	}
+
+	return len(balances), discrepancies, nil
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+)
+
+const (
+	MaintenanceRebuildIndexes    = "rebuild-indexes"
+	MaintenanceCompactCollection = "compact-collections"
+)
+
+var (
+	ErrUnknownMaintenanceOperation = utils.NewCustomError(http.StatusBadRequest, utils.CodeUnknownMaintenanceOperation, "unknown maintenance operation")
+)
+
+type MaintenanceService struct {
+	maintenanceRepo repositories.MaintenanceRepository
+	jobService      *JobService
+}
+
+func NewMaintenanceService(maintenanceRepo repositories.MaintenanceRepository, jobService *JobService) *MaintenanceService {
+	return &MaintenanceService{
+		maintenanceRepo: maintenanceRepo,
+		jobService:      jobService,
+	}
+}
+
+// StartOperation launches a maintenance operation as a background job
+func (s *MaintenanceService) StartOperation(ctx context.Context, operation string, adminID string) (*models.Job, error) {
+	var fn JobFunc
+	switch operation {
+	case MaintenanceRebuildIndexes:
+		fn = s.rebuildIndexes
+	case MaintenanceCompactCollection:
+		fn = s.compactCollections
+	default:
+		return nil, ErrUnknownMaintenanceOperation
+	}
+
+	return s.jobService.StartExclusive(ctx, "maintenance:"+operation, adminID, fn)
+}
+
+func (s *MaintenanceService) rebuildIndexes(ctx context.Context, progress ProgressFunc) (map[string]interface{}, error) {
+	collections, err := s.maintenanceRepo.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rebuilt := map[string]interface{}{}
+	skipped := map[string]interface{}{}
+	standIns := map[string]interface{}{}
+	for i, name := range collections {
+		result, err := s.maintenanceRepo.RebuildIndexes(ctx, name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to rebuild indexes on %s: %v", name, err)
+		}
+		rebuilt[name] = result.Rebuilt
+		if len(result.Skipped) > 0 {
+			skipped[name] = result.Skipped
+		}
+		if len(result.StandIns) > 0 {
+			standIns[name] = result.StandIns
+		}
+		progress(i+1, len(collections))
+	}
+
+	// Unique indexes are left alone, and so are stand-ins still standing in; see IndexRebuild
+	return map[string]interface{}{"indexes_rebuilt": rebuilt, "unique_indexes_skipped": skipped, "stand_ins_kept": standIns}, nil
+}
+
+func (s *MaintenanceService) compactCollections(ctx context.Context, progress ProgressFunc) (map[string]interface{}, error) {
+	collections, err := s.maintenanceRepo.ListCollections(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, name := range collections {
+		if err := s.maintenanceRepo.Compact(ctx, name); err != nil {
+			return nil, fmt.Errorf("failed to compact %s: %v", name, err)
+		}
+		progress(i+1, len(collections))
+	}
+
+	return map[string]interface{}{"collections_compacted": collections}, nil
+}
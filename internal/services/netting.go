@@ -0,0 +1,353 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidNetting = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidNetting, "invalid netting request")
+	ErrNothingToNet   = utils.NewCustomError(http.StatusUnprocessableEntity, utils.CodeNothingToNet, "no offsetting debts between these users in groups that allow cross-group netting")
+)
+
+type NettingService struct {
+	nettingRepo    repositories.NettingRepository
+	balanceRepo    repositories.BalanceRepository
+	settlementRepo repositories.SettlementRepository
+	groupRepo      repositories.GroupRepository
+	userRepo       repositories.UserRepository
+	notifier       Notifier
+	events         EventPublisher
+	aggregates     *AggregateCache
+}
+
+func NewNettingService(
+	nettingRepo repositories.NettingRepository,
+	balanceRepo repositories.BalanceRepository,
+	settlementRepo repositories.SettlementRepository,
+	groupRepo repositories.GroupRepository,
+	userRepo repositories.UserRepository,
+	notifier Notifier,
+	events EventPublisher,
+	aggregates *AggregateCache,
+) *NettingService {
+	return &NettingService{
+		nettingRepo:    nettingRepo,
+		balanceRepo:    balanceRepo,
+		settlementRepo: settlementRepo,
+		groupRepo:      groupRepo,
+		userRepo:       userRepo,
+		notifier:       notifier,
+		events:         events,
+		aggregates:     aggregates,
+	}
+}
+
+// PreviewNetting works out the netting between the user and the counterparty without applying it
+func (s *NettingService) PreviewNetting(ctx context.Context, userID string, req models.NettingRequest) (*models.Netting, error) {
+	currency, groups, err := s.prepare(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	return s.plan(ctx, userID, req.CounterpartyID, currency, groups)
+}
+
+// CreateNetting offsets what the user and the counterparty owe each other across their shared
+// groups that allow it. Balances are re-read and adjusted in one transaction, so the netting
+// matches the balances it is applied to, and it conflicts with settlements created in those
+// groups at the same time.
+func (s *NettingService) CreateNetting(ctx context.Context, userID string, req models.NettingRequest) (*models.Netting, error) {
+	currency, groups, err := s.prepare(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := s.nettingRepo.StartSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		// Reading the open settlements alone wouldn't conflict with one created meanwhile, which
+		// would then pay a debt the netting clears
+		for _, group := range groups {
+			if err := s.groupRepo.BumpSettlementVersion(sessCtx, group.GroupID); err != nil {
+				return nil, err
+			}
+		}
+		netting, err := s.plan(sessCtx, userID, req.CounterpartyID, currency, groups)
+		if err != nil {
+			return nil, err
+		}
+		netting.NettingID = uuid.New().String()
+		netting.CreatedAt = time.Now()
+
+		for _, adjustment := range netting.Adjustments {
+			if err := s.applyAdjustment(sessCtx, netting, adjustment); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.nettingRepo.Create(sessCtx, netting); err != nil {
+			return nil, err
+		}
+		return netting, nil
+	})
+	if err != nil {
+		if errors.Is(err, ErrNothingToNet) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("transaction failed: %v", err)
+	}
+	netting := result.(*models.Netting)
+
+	s.aggregates.EvictBalances(ctx, userID, req.CounterpartyID)
+	s.announce(ctx, netting)
+	return netting, nil
+}
+
+func (s *NettingService) ListNettings(ctx context.Context, userID string, limit int64) ([]*models.Netting, error) {
+	return s.nettingRepo.ListByUserID(ctx, userID, limit)
+}
+
+// prepare validates the request and returns the groups both users are active members of that
+// allow cross-group netting
+func (s *NettingService) prepare(ctx context.Context, userID string, req models.NettingRequest) (string, []*models.Group, error) {
+	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if len(currency) != 3 {
+		return "", nil, fmt.Errorf("%w: currency must be a 3-letter code", ErrInvalidNetting)
+	}
+	if req.CounterpartyID == userID {
+		return "", nil, fmt.Errorf("%w: cannot net debts with yourself", ErrInvalidNetting)
+	}
+
+	exists, err := s.userRepo.Exists(ctx, req.CounterpartyID)
+	if err != nil {
+		return "", nil, err
+	}
+	if !exists {
+		return "", nil, ErrUserNotFound
+	}
+
+	groups, err := s.groupRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return "", nil, err
+	}
+
+	shared := make([]*models.Group, 0, len(groups))
+	for _, group := range groups {
+		if group.Settings.CrossGroupNetting && activeMember(group, userID) != nil && activeMember(group, req.CounterpartyID) != nil {
+			shared = append(shared, group)
+		}
+	}
+	// Netting needs a debt in each direction, which takes at least two groups
+	if len(shared) < 2 {
+		return "", nil, ErrNothingToNet
+	}
+
+	return currency, shared, nil
+}
+
+// plan reads both users' balances in each group and nets the debts running one way against the
+// debts running the other way. Within a group, one user owes the other as much as the smaller
+// of what the first owes the group and what the group owes the second. The netted amount is
+// spread over the groups on each side in proportion to their debt.
+//
+// Balances are taken as they will be once the group's open settlements complete, so a debt
+// already being paid isn't netted as well and completing that settlement can't overpay it.
+func (s *NettingService) plan(ctx context.Context, userID, counterpartyID, currency string, groups []*models.Group) (*models.Netting, error) {
+	var owedByUser, owedToUser []nettingDebt
+	for _, group := range groups {
+		balances, err := s.balanceRepo.GetByGroupID(ctx, group.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		open, err := s.settlementRepo.GetOpenInGroup(ctx, group.GroupID)
+		if err != nil {
+			return nil, err
+		}
+
+		var userBalance, counterpartyBalance float64
+		for _, balance := range projectedPositions(group.GroupID, balances, open) {
+			if balance.Currency != currency {
+				continue
+			}
+			switch balance.UserID {
+			case userID:
+				userBalance = balance.Balance
+			case counterpartyID:
+				counterpartyBalance = balance.Balance
+			}
+		}
+
+		if debt := math.Min(-userBalance, counterpartyBalance); debt >= 0.01 {
+			owedByUser = append(owedByUser, nettingDebt{group: group, amount: debt})
+		} else if debt := math.Min(-counterpartyBalance, userBalance); debt >= 0.01 {
+			owedToUser = append(owedToUser, nettingDebt{group: group, amount: debt})
+		}
+	}
+
+	amount := math.Min(totalDebt(owedByUser), totalDebt(owedToUser))
+	amount = math.Floor(amount*100+1e-6) / 100
+	if amount < 0.01 {
+		return nil, ErrNothingToNet
+	}
+
+	netting := &models.Netting{
+		InitiatorID:    userID,
+		CounterpartyID: counterpartyID,
+		Currency:       currency,
+		Amount:         amount,
+	}
+	netting.Adjustments = append(netting.Adjustments, spreadNetting(owedByUser, amount, userID, counterpartyID)...)
+	netting.Adjustments = append(netting.Adjustments, spreadNetting(owedToUser, amount, counterpartyID, userID)...)
+	return netting, nil
+}
+
+type nettingDebt struct {
+	group  *models.Group
+	amount float64
+}
+
+func totalDebt(debts []nettingDebt) float64 {
+	total := 0.0
+	for _, debt := range debts {
+		total += debt.amount
+	}
+	return total
+}
+
+// spreadNetting splits amount over the debts in proportion to their size, in whole cents. The
+// leftover cents go to the largest debts, which can always absorb them since amount never
+// exceeds the total.
+func spreadNetting(debts []nettingDebt, amount float64, fromUserID, toUserID string) []models.NettingAdjustment {
+	sort.Slice(debts, func(i, j int) bool {
+		if debts[i].amount != debts[j].amount {
+			return debts[i].amount > debts[j].amount
+		}
+		return debts[i].group.GroupID < debts[j].group.GroupID
+	})
+
+	total := totalDebt(debts)
+	totalCents := int64(math.Round(amount * 100))
+	cents := make([]int64, len(debts))
+	allocated := int64(0)
+	for i, debt := range debts {
+		cents[i] = int64(math.Floor(float64(totalCents) * debt.amount / total))
+		allocated += cents[i]
+	}
+	for i := 0; allocated < totalCents; i = (i + 1) % len(debts) {
+		cents[i]++
+		allocated++
+	}
+
+	adjustments := make([]models.NettingAdjustment, 0, len(debts))
+	for i, debt := range debts {
+		if cents[i] == 0 {
+			continue
+		}
+		adjustments = append(adjustments, models.NettingAdjustment{
+			GroupID:    debt.group.GroupID,
+			GroupName:  debt.group.Name,
+			FromUserID: fromUserID,
+			ToUserID:   toUserID,
+			Amount:     float64(cents[i]) / 100,
+		})
+	}
+	return adjustments
+}
+
+// applyAdjustment moves the balances within the group as a settlement from FromUserID to
+// ToUserID would, and records the change in both users' history
+func (s *NettingService) applyAdjustment(ctx context.Context, netting *models.Netting, adjustment models.NettingAdjustment) error {
+	groupID := adjustment.GroupID
+	if err := s.balanceRepo.UpdateBalance(ctx, adjustment.FromUserID, &groupID, netting.Currency, adjustment.Amount); err != nil {
+		return err
+	}
+	if err := s.balanceRepo.UpdateBalance(ctx, adjustment.ToUserID, &groupID, netting.Currency, -adjustment.Amount); err != nil {
+		return err
+	}
+
+	for _, entry := range []*models.BalanceHistory{
+		{
+			UserID:      adjustment.FromUserID,
+			Amount:      adjustment.Amount,
+			Description: fmt.Sprintf("Cross-group netting: debt offset against %s", otherGroupsDescription(netting, groupID)),
+		},
+		{
+			UserID:      adjustment.ToUserID,
+			Amount:      -adjustment.Amount,
+			Description: fmt.Sprintf("Cross-group netting: amount owed offset against %s", otherGroupsDescription(netting, groupID)),
+		},
+	} {
+		entry.GroupID = &groupID
+		entry.Currency = netting.Currency
+		entry.Type = models.BalanceChangeNetting
+		entry.ReferenceID = netting.NettingID
+		if err := s.balanceRepo.CreateBalanceHistory(ctx, entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// otherGroupsDescription names the groups on the other side of the netting from groupID
+func otherGroupsDescription(netting *models.Netting, groupID string) string {
+	var direction string
+	for _, adjustment := range netting.Adjustments {
+		if adjustment.GroupID == groupID {
+			direction = adjustment.FromUserID
+		}
+	}
+
+	var names []string
+	for _, adjustment := range netting.Adjustments {
+		if adjustment.FromUserID != direction {
+			names = append(names, adjustment.GroupName)
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func (s *NettingService) announce(ctx context.Context, netting *models.Netting) {
+	initiatorName := "Another member"
+	if initiator, err := s.userRepo.GetByID(ctx, netting.InitiatorID); err == nil {
+		initiatorName = initiator.Name
+	}
+
+	deliver(ctx, s.notifier, Notification{
+		UserID: netting.CounterpartyID,
+		Type:   models.NotificationNettingApplied,
+		Title:  "Debts netted across groups",
+		Body:   "%s offset %.2f %s that you owed each other in different groups. No money needs to change hands for it.",
+		Data: map[string]interface{}{
+			"netting_id": netting.NettingID,
+			"amount":     netting.Amount,
+			"currency":   netting.Currency,
+		},
+
+		BodyArgs: []interface{}{initiatorName, netting.Amount, netting.Currency},
+	})
+
+	for _, adjustment := range netting.Adjustments {
+		groupID := adjustment.GroupID
+		publishEvent(ctx, s.events, s.groupRepo, Event{
+			Type:    EventBalancesChanged,
+			GroupID: &groupID,
+			Data:    map[string]interface{}{"netting_id": netting.NettingID},
+		})
+	}
+}
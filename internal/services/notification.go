@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+	"divvydoo/backend/pkg/locale"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrNotificationNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodeNotificationNotFound, "notification not found")
+)
+
+// NotificationService stores notifications in the recipient's in-app inbox, written in their
+// preferred language. It implements Notifier.
+type NotificationService struct {
+	notificationRepo repositories.NotificationRepository
+	userRepo         repositories.UserRepository
+}
+
+func NewNotificationService(notificationRepo repositories.NotificationRepository, userRepo repositories.UserRepository) *NotificationService {
+	return &NotificationService{
+		notificationRepo: notificationRepo,
+		userRepo:         userRepo,
+	}
+}
+
+func (s *NotificationService) Notify(ctx context.Context, notification Notification) error {
+	title, body := notification.Render(s.userLanguage(ctx, notification.UserID))
+	_, err := s.notificationRepo.Create(ctx, &models.Notification{
+		NotificationID: uuid.New().String(),
+		UserID:         notification.UserID,
+		Type:           notification.Type,
+		Title:          title,
+		Body:           body,
+		Data:           notification.Data,
+	})
+	return err
+}
+
+// userLanguage is the language the user chose in their preferences, falling back to English
+// when they chose none or can't be loaded
+func (s *NotificationService) userLanguage(ctx context.Context, userID string) string {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil || user.Preferences.Language == "" {
+		return locale.DefaultLanguage
+	}
+	return user.Preferences.Language
+}
+
+// ListNotifications returns a page of the user's notifications, newest first, and the cursor for the next page
+func (s *NotificationService) ListNotifications(ctx context.Context, userID string, unreadOnly bool, cursor string, limit, offset int64) ([]*models.Notification, string, error) {
+	position, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.notificationRepo.ListByUserID(ctx, userID, unreadOnly, position, limit, offset)
+}
+
+// MarkRead marks one of the user's notifications as read. Other users' notifications are reported as not found.
+func (s *NotificationService) MarkRead(ctx context.Context, notificationID string, userID string) (*models.Notification, error) {
+	notification, err := s.notificationRepo.MarkRead(ctx, notificationID, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrNotificationNotFound) {
+			return nil, ErrNotificationNotFound
+		}
+		return nil, err
+	}
+	return notification, nil
+}
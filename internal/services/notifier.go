@@ -0,0 +1,65 @@
+package services
+
+import (
+	"context"
+	"log"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/pkg/locale"
+)
+
+// Notification is a message for a single user about something that happened to them. Title and
+// Body are English; with TitleArgs or BodyArgs they are formats for fmt, filled in once they have
+// been translated into the recipient's language.
+type Notification struct {
+	UserID string
+	Type   models.NotificationType
+	Title  string
+	Body   string
+	Data   map[string]interface{}
+
+	TitleArgs []interface{}
+	BodyArgs  []interface{}
+}
+
+// Render returns the title and body in language
+func (n Notification) Render(language string) (string, string) {
+	return renderText(language, n.Title, n.TitleArgs), renderText(language, n.Body, n.BodyArgs)
+}
+
+func renderText(language string, text string, args []interface{}) string {
+	if args == nil {
+		return locale.Translate(language, text)
+	}
+	return locale.Sprintf(language, text, args...)
+}
+
+// Notifier delivers notifications to users. Delivery is best effort: callers log
+// failures rather than failing the operation that triggered the notification.
+type Notifier interface {
+	Notify(ctx context.Context, notification Notification) error
+}
+
+// LogNotifier writes notifications to the server log. It is the fallback when no
+// delivery channel is configured.
+type LogNotifier struct{}
+
+func NewLogNotifier() *LogNotifier {
+	return &LogNotifier{}
+}
+
+func (n *LogNotifier) Notify(ctx context.Context, notification Notification) error {
+	title, _ := notification.Render(locale.DefaultLanguage)
+	log.Printf("notification user_id=%s type=%s title=%q", notification.UserID, notification.Type, title)
+	return nil
+}
+
+// deliver sends a notification if a notifier is configured, logging failures
+func deliver(ctx context.Context, notifier Notifier, notification Notification) {
+	if notifier == nil {
+		return
+	}
+	if err := notifier.Notify(ctx, notification); err != nil {
+		log.Printf("Failed to send %s notification to user %s: %v", notification.Type, notification.UserID, err)
+	}
+}
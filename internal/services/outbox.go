@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// outboxLease is how long a claimed message is hidden from other relays
+	outboxLease = time.Minute
+	// outboxMaxAttempts is how often a notification is tried before it is marked failed
+	outboxMaxAttempts = 10
+	// outboxMaxBackoff caps the wait between attempts
+	outboxMaxBackoff = 5 * time.Minute
+)
+
+// OutboxService keeps the side effects of expenses and settlements, their events and
+// notifications, in the outbox collection. They are written by an OutboxWriter in the same
+// transaction as the change behind them and delivered by the relay once it commits, so a crash
+// between the commit and the delivery delays them instead of losing them. Delivery is at least
+// once: a message is removed only after it was handed on.
+type OutboxService struct {
+	outboxRepo repositories.OutboxRepository
+	events     EventPublisher
+	notifier   Notifier
+	wake       chan struct{}
+}
+
+func NewOutboxService(outboxRepo repositories.OutboxRepository, events EventPublisher, notifier Notifier) *OutboxService {
+	return &OutboxService{
+		outboxRepo: outboxRepo,
+		events:     events,
+		notifier:   notifier,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Writer returns an OutboxWriter that writes with ctx, the session context of the transaction
+// the messages belong to. A writer is good for one attempt of the transaction.
+func (s *OutboxService) Writer(ctx context.Context) *OutboxWriter {
+	return &OutboxWriter{ctx: ctx, outboxRepo: s.outboxRepo}
+}
+
+// Wake tells the relay that messages were committed, so it delivers them without waiting for
+// its next poll
+func (s *OutboxService) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Wakeups receives a value after Wake is called
+func (s *OutboxService) Wakeups() <-chan struct{} {
+	return s.wake
+}
+
+// Relay delivers up to limit due messages and returns how many it claimed. Events go to the
+// event publishers; notifications that fail are retried with backoff and marked failed once
+// they run out of attempts.
+func (s *OutboxService) Relay(ctx context.Context, limit int) (int, error) {
+	messages, err := s.outboxRepo.Claim(ctx, limit, outboxLease)
+	if err != nil {
+		return len(messages), fmt.Errorf("failed to claim outbox messages: %v", err)
+	}
+
+	for _, message := range messages {
+		if err := s.deliver(ctx, message); err != nil {
+			s.retry(ctx, message, err)
+			continue
+		}
+		err := s.outboxRepo.Complete(ctx, message.MessageID)
+		if err != nil && !errors.Is(err, repositories.ErrOutboxMessageNotFound) {
+			log.Printf("Failed to remove delivered outbox message %s: %v", message.MessageID, err)
+		}
+	}
+	return len(messages), nil
+}
+
+func (s *OutboxService) deliver(ctx context.Context, message *models.OutboxMessage) error {
+	switch {
+	case message.Event != nil:
+		if s.events != nil {
+			s.events.Publish(outboxEventToEvent(message.Event))
+		}
+	case message.Notification != nil:
+		if s.notifier != nil {
+			n := message.Notification
+			return s.notifier.Notify(ctx, Notification{
+				UserID:    n.UserID,
+				Type:      n.Type,
+				Title:     n.Title,
+				Body:      n.Body,
+				Data:      n.Data,
+				TitleArgs: n.TitleArgs,
+				BodyArgs:  n.BodyArgs,
+			})
+		}
+	}
+	return nil
+}
+
+func (s *OutboxService) retry(ctx context.Context, message *models.OutboxMessage, cause error) {
+	if message.Attempts >= outboxMaxAttempts {
+		log.Printf("Outbox message %s failed %d times, giving up: %v", message.MessageID, message.Attempts, cause)
+		if err := s.outboxRepo.MarkFailed(ctx, message.MessageID, cause.Error()); err != nil {
+			log.Printf("Failed to mark outbox message %s failed: %v", message.MessageID, err)
+		}
+		return
+	}
+
+	backoff := time.Second << message.Attempts
+	if backoff > outboxMaxBackoff {
+		backoff = outboxMaxBackoff
+	}
+	log.Printf("Outbox message %s failed, retrying in %s: %v", message.MessageID, backoff, cause)
+	if err := s.outboxRepo.Retry(ctx, message.MessageID, cause.Error(), time.Now().Add(backoff)); err != nil {
+		log.Printf("Failed to reschedule outbox message %s: %v", message.MessageID, err)
+	}
+}
+
+// OutboxWriter is the EventPublisher and Notifier services use inside a transaction. It writes
+// to the outbox instead of delivering; the first write that fails is kept and returned by Err,
+// which the transaction must return so it doesn't commit without its side effects.
+type OutboxWriter struct {
+	ctx        context.Context
+	outboxRepo repositories.OutboxRepository
+	err        error
+}
+
+func (w *OutboxWriter) Publish(event Event) {
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+	outboxEvent := &models.OutboxEvent{
+		Type:       string(event.Type),
+		GroupID:    event.GroupID,
+		Recipients: event.Recipients,
+		OccurredAt: event.OccurredAt,
+	}
+	switch data := event.Data.(type) {
+	case *models.Expense:
+		outboxEvent.Expense = data
+	case models.Expense:
+		outboxEvent.Expense = &data
+	case *models.Settlement:
+		outboxEvent.Settlement = data
+	case map[string]interface{}:
+		outboxEvent.Data = data
+	case nil:
+	default:
+		w.fail(fmt.Errorf("unsupported %s event data %T", event.Type, event.Data))
+		return
+	}
+	w.write(&models.OutboxMessage{Event: outboxEvent})
+}
+
+// Notify records the notification with the writer's context; ctx is ignored
+func (w *OutboxWriter) Notify(ctx context.Context, notification Notification) error {
+	w.write(&models.OutboxMessage{Notification: &models.OutboxNotification{
+		UserID: notification.UserID,
+		Type:   notification.Type,
+		Title:  notification.Title,
+		Body:   notification.Body,
+		Data:   notification.Data,
+
+		TitleArgs: notification.TitleArgs,
+		BodyArgs:  notification.BodyArgs,
+	}})
+	return w.err
+}
+
+// Err returns the first write that failed
+func (w *OutboxWriter) Err() error {
+	return w.err
+}
+
+func (w *OutboxWriter) write(message *models.OutboxMessage) {
+	if w.err != nil {
+		return
+	}
+	now := time.Now()
+	message.MessageID = uuid.New().String()
+	message.Status = models.OutboxPending
+	message.AvailableAt = now
+	message.CreatedAt = now
+	if err := w.outboxRepo.Create(w.ctx, message); err != nil {
+		w.fail(fmt.Errorf("failed to write to the outbox: %v", err))
+	}
+}
+
+func (w *OutboxWriter) fail(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
+
+// outboxEventToEvent restores the event as it was published, with its data typed again
+func outboxEventToEvent(outboxEvent *models.OutboxEvent) Event {
+	event := Event{
+		Type:       EventType(outboxEvent.Type),
+		GroupID:    outboxEvent.GroupID,
+		OccurredAt: outboxEvent.OccurredAt,
+		Recipients: outboxEvent.Recipients,
+	}
+	switch {
+	case outboxEvent.Expense != nil:
+		event.Data = outboxEvent.Expense
+	case outboxEvent.Settlement != nil:
+		event.Data = outboxEvent.Settlement
+	case outboxEvent.Data != nil:
+		event.Data = outboxEvent.Data
+	}
+	return event
+}
@@ -0,0 +1,20 @@
+package services
+
+import (
+	"net/http"
+
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+)
+
+var (
+	ErrInvalidCursor = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidCursor, "invalid pagination cursor")
+)
+
+func decodeCursor(encoded string) (*repositories.Cursor, error) {
+	cursor, err := repositories.DecodeCursor(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return cursor, nil
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+	"divvydoo/backend/pkg/payments"
+)
+
+var (
+	ErrPaymentProviderNotFound = utils.NewCustomError(http.StatusNotFound, utils.CodePaymentProviderNotFound, "unknown payment provider")
+	ErrInvalidWebhookSignature = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidWebhookSignature, "invalid webhook signature")
+	ErrInvalidPaymentEvent     = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidPaymentEvent, "malformed payment event")
+)
+
+// PaymentWebhookService moves settlements paid through a payment provider to completed or
+// failed as the provider's webhooks report the payment's result
+type PaymentWebhookService struct {
+	providers   map[string]payments.Provider
+	settlements *SettlementService
+	eventRepo   repositories.PaymentEventRepository
+}
+
+func NewPaymentWebhookService(settlements *SettlementService, eventRepo repositories.PaymentEventRepository, providers ...payments.Provider) *PaymentWebhookService {
+	byName := make(map[string]payments.Provider, len(providers))
+	for _, provider := range providers {
+		byName[provider.Name()] = provider
+	}
+	return &PaymentWebhookService{
+		providers:   byName,
+		settlements: settlements,
+		eventRepo:   eventRepo,
+	}
+}
+
+// HandleWebhook verifies a webhook request from the named provider and applies its event.
+// Every event is recorded by its provider's event ID, in the same transaction as the settlement
+// change it causes, so an event the provider resends is acknowledged without being applied
+// again. Events that can't be applied, such as ones for unknown settlements or for a different
+// amount, are recorded as ignored and acknowledged too, since resending them wouldn't help.
+func (s *PaymentWebhookService) HandleWebhook(ctx context.Context, providerName string, header http.Header, body []byte) (*models.PaymentEvent, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, ErrPaymentProviderNotFound
+	}
+
+	event, err := provider.Parse(header, body)
+	if err != nil {
+		switch {
+		case errors.Is(err, payments.ErrInvalidSignature):
+			return nil, ErrInvalidWebhookSignature
+		case errors.Is(err, payments.ErrMalformedEvent):
+			return nil, ErrInvalidPaymentEvent
+		}
+		return nil, err
+	}
+
+	record := &models.PaymentEvent{
+		Provider:     providerName,
+		EventID:      event.ID,
+		Type:         event.Type,
+		SettlementID: event.SettlementID,
+		ReceivedAt:   time.Now(),
+	}
+	if event.Outcome == payments.OutcomeNone {
+		return s.ignore(ctx, record, "event does not report a payment result")
+	}
+	if event.SettlementID == "" {
+		return s.ignore(ctx, record, "payment has no settlement_id")
+	}
+
+	settlement, err := s.settlements.getSettlement(ctx, event.SettlementID)
+	if err != nil {
+		if errors.Is(err, ErrSettlementNotFound) {
+			return s.ignore(ctx, record, "settlement not found")
+		}
+		return nil, err
+	}
+
+	if event.Outcome == payments.OutcomeSucceeded {
+		return s.applySucceeded(ctx, record, event, settlement)
+	}
+	return s.applyFailed(ctx, record, event, settlement)
+}
+
+func (s *PaymentWebhookService) applySucceeded(ctx context.Context, record *models.PaymentEvent, event *payments.Event, settlement *models.Settlement) (*models.PaymentEvent, error) {
+	if event.Currency != settlement.Currency || math.Abs(event.Amount-settlement.Amount) > 0.005 {
+		log.Printf("Payment %s from %s for settlement %s is %.2f %s, not %.2f %s; left for review",
+			event.TransactionID, record.Provider, settlement.SettlementID, event.Amount, event.Currency, settlement.Amount, settlement.Currency)
+		return s.ignore(ctx, record, fmt.Sprintf("payment of %.2f %s does not match the settlement", event.Amount, event.Currency))
+	}
+
+	switch settlement.Status {
+	case models.SettlementPending, models.SettlementAwaitingConfirmation, models.SettlementFailed:
+	case models.SettlementCompleted:
+		return s.ignore(ctx, record, "settlement already completed")
+	default:
+		// The money arrived anyway, so someone has to sort it out with the parties
+		log.Printf("Payment %s from %s succeeded for %s settlement %s; left for review",
+			event.TransactionID, record.Provider, settlement.Status, settlement.SettlementID)
+		return s.ignore(ctx, record, fmt.Sprintf("settlement is %s", settlement.Status))
+	}
+
+	transactionID := event.TransactionID
+	return s.apply(ctx, record, settlement, func(ctx context.Context, out *OutboxWriter) error {
+		// A failed payment the provider retried successfully completes the settlement too
+		from := []models.SettlementStatus{models.SettlementPending, models.SettlementAwaitingConfirmation, models.SettlementFailed}
+		if err := s.settlements.applySettlement(ctx, out, settlement, from, &transactionID, true); err != nil {
+			return err
+		}
+		deliver(ctx, out, Notification{
+			UserID: settlement.FromUserID,
+			Type:   models.NotificationSettlementPaid,
+			Title:  "Payment sent",
+			Body:   "Your payment of %.2f %s went through and the settlement is complete.",
+			Data:   settlementNotificationData(settlement),
+
+			BodyArgs: []interface{}{settlement.Amount, settlement.Currency},
+		})
+		deliver(ctx, out, Notification{
+			UserID: settlement.ToUserID,
+			Type:   models.NotificationSettlementPaid,
+			Title:  "Payment received",
+			Body:   "You received a payment of %.2f %s and the settlement is complete.",
+			Data:   settlementNotificationData(settlement),
+
+			BodyArgs: []interface{}{settlement.Amount, settlement.Currency},
+		})
+		return nil
+	})
+}
+
+func (s *PaymentWebhookService) applyFailed(ctx context.Context, record *models.PaymentEvent, event *payments.Event, settlement *models.Settlement) (*models.PaymentEvent, error) {
+	switch settlement.Status {
+	case models.SettlementPending, models.SettlementAwaitingConfirmation:
+	default:
+		return s.ignore(ctx, record, fmt.Sprintf("settlement is %s", settlement.Status))
+	}
+
+	reason := event.FailureReason
+	if reason == "" {
+		reason = "the payment provider declined the payment"
+	}
+	transactionID := event.TransactionID
+	return s.apply(ctx, record, settlement, func(ctx context.Context, out *OutboxWriter) error {
+		if err := s.settlements.settlementRepo.MarkFailed(ctx, settlement.SettlementID, &transactionID, reason); err != nil {
+			return err
+		}
+		data := settlementNotificationData(settlement)
+		data["reason"] = reason
+		deliver(ctx, out, Notification{
+			UserID: settlement.FromUserID,
+			Type:   models.NotificationSettlementFailed,
+			Title:  "Payment failed",
+			Body:   "Your payment of %.2f %s failed: %s",
+			Data:   data,
+
+			BodyArgs: []interface{}{settlement.Amount, settlement.Currency, reason},
+		})
+		return nil
+	})
+}
+
+// apply records the event and makes the settlement change in one transaction. A settlement that
+// changed in the meantime fails the request, so the provider resends the event and it is
+// checked against the new state.
+func (s *PaymentWebhookService) apply(ctx context.Context, record *models.PaymentEvent, settlement *models.Settlement, change func(ctx context.Context, out *OutboxWriter) error) (*models.PaymentEvent, error) {
+	record.Result = models.PaymentEventApplied
+	_, err := s.settlements.transition(ctx, settlement.SettlementID, func(ctx context.Context, out *OutboxWriter) error {
+		if err := s.eventRepo.Create(ctx, record); err != nil {
+			return err
+		}
+		return change(ctx, out)
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrPaymentEventExists) {
+			return record, nil
+		}
+		if errors.Is(err, repositories.ErrSettlementStateChanged) {
+			return nil, ErrSettlementStateChanged
+		}
+		return nil, err
+	}
+	return record, nil
+}
+
+func (s *PaymentWebhookService) ignore(ctx context.Context, record *models.PaymentEvent, note string) (*models.PaymentEvent, error) {
+	record.Result = models.PaymentEventIgnored
+	record.Note = note
+	if err := s.eventRepo.Create(ctx, record); err != nil && !errors.Is(err, repositories.ErrPaymentEventExists) {
+		return nil, err
+	}
+	return record, nil
+}
@@ -0,0 +1,219 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+	"divvydoo/backend/pkg/email"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidClaimToken   = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidClaimToken, "this claim code is invalid or has expired")
+	ErrPlaceholderConflict = utils.NewCustomError(http.StatusConflict, utils.CodePlaceholderConflict, "you have an open settlement with the imported member; complete or cancel it first")
+)
+
+const (
+	placeholderClaimTTL = 48 * time.Hour
+	claimTokenBytes     = 32
+)
+
+// ClaimPlaceholdersRequest carries the code emailed to the user
+type ClaimPlaceholdersRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ClaimPlaceholdersResponse lists the groups the user took a placeholder's place in
+type ClaimPlaceholdersResponse struct {
+	GroupIDs []string `json:"group_ids"`
+}
+
+// offerPlaceholderClaims emails the user a code that claims the placeholders imported under their
+// email, if there are any. Signing up with an address doesn't prove it's yours; receiving the code
+// does. A new code replaces the one sent before.
+func (s *UserService) offerPlaceholderClaims(ctx context.Context, user *models.User) error {
+	if s.emailSender == nil {
+		return nil
+	}
+
+	raw := make([]byte, claimTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("failed to generate token: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	placeholders, err := s.userRepo.SetClaimToken(ctx, strings.ToLower(user.Email), hashClaimToken(token), time.Now().Add(placeholderClaimTTL))
+	if err != nil {
+		return err
+	}
+	if placeholders == 0 {
+		return nil
+	}
+
+	msg, err := email.Render(email.TemplatePlaceholderClaim, user.Preferences.Language, email.Address{Name: user.Name, Email: user.Email}, email.PlaceholderClaimData{
+		RecipientName: user.Name,
+		Token:         token,
+		ExpiresIn:     placeholderClaimTTL,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to render placeholder claim email: %w", err)
+	}
+	return s.emailSender.Send(ctx, msg)
+}
+
+// RequestPlaceholderClaim emails the user a new claim code, for when the one sent at sign-up
+// expired or got lost. Nothing is sent if no placeholder was imported under their email.
+func (s *UserService) RequestPlaceholderClaim(ctx context.Context, userID string) error {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.offerPlaceholderClaims(ctx, user)
+}
+
+// ClaimPlaceholders hands the user the placeholders a claim code was emailed for: their group
+// memberships, expenses, balances and settlements become the user's and the placeholders are
+// deleted, in one transaction. In groups the user is already in, the placeholder's membership,
+// payments, shares and balances are merged into theirs. The code only works once, and only for
+// an account that still has the email it was sent to.
+func (s *UserService) ClaimPlaceholders(ctx context.Context, userID string, req ClaimPlaceholdersRequest) (*ClaimPlaceholdersResponse, error) {
+	user, err := s.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	tokenHash := hashClaimToken(strings.TrimSpace(req.Token))
+
+	session, err := s.balanceRepo.StartSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	// The placeholders' cached memberships are only evicted once the transaction commits
+	txCtx, flushInvalidations := repositories.DeferInvalidation(ctx)
+	result, err := session.WithTransaction(txCtx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		// Read in the transaction, so a code used twice at once only claims the placeholders once
+		placeholders, err := s.userRepo.GetPlaceholdersByClaimToken(sessCtx, tokenHash)
+		if err != nil {
+			return nil, err
+		}
+		if !claimable(placeholders, user, time.Now()) {
+			return nil, ErrInvalidClaimToken
+		}
+
+		groupIDs := []string{}
+		for _, placeholder := range placeholders {
+			claimed, err := s.claimPlaceholder(sessCtx, placeholder.UserID, user.UserID)
+			if err != nil {
+				return nil, err
+			}
+			groupIDs = append(groupIDs, claimed...)
+			log.Printf("User %s claimed placeholder %s", user.UserID, placeholder.UserID)
+		}
+		return groupIDs, nil
+	})
+	flushInvalidations()
+	if err != nil {
+		if errors.Is(err, ErrInvalidClaimToken) || errors.Is(err, ErrPlaceholderConflict) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("transaction failed: %v", err)
+	}
+
+	groupIDs := result.([]string)
+	s.aggregates.EvictBalances(ctx, user.UserID)
+	for _, groupID := range groupIDs {
+		s.aggregates.EvictGroupMembers(ctx, groupID)
+	}
+	return &ClaimPlaceholdersResponse{GroupIDs: groupIDs}, nil
+}
+
+// claimable reports whether the placeholders a code was issued for can go to the user: the code
+// must not have expired, and must have been sent to the user's current email
+func claimable(placeholders []*models.User, user *models.User, now time.Time) bool {
+	if len(placeholders) == 0 {
+		return false
+	}
+	for _, placeholder := range placeholders {
+		if placeholder.ClaimEmail != strings.ToLower(user.Email) {
+			return false
+		}
+		if placeholder.ClaimTokenExpiresAt == nil || !now.Before(*placeholder.ClaimTokenExpiresAt) {
+			return false
+		}
+	}
+	return true
+}
+
+// claimPlaceholder moves everything recorded for the placeholder over to userID and returns the
+// groups concerned. In the groups they share, the placeholder's membership is merged into the
+// user's, and the repositories add its payments, shares and balances onto the user's. A settlement
+// still open between the two would become one the user pays themselves, so it must be finished
+// first.
+func (s *UserService) claimPlaceholder(ctx context.Context, placeholderID string, userID string) ([]string, error) {
+	open, err := s.settlementRepo.GetOpenBetweenUsers(ctx, placeholderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(open) > 0 {
+		return nil, fmt.Errorf("%w: settlement %s", ErrPlaceholderConflict, open[0].SettlementID)
+	}
+
+	shared, err := s.groupRepo.SharedGroupIDs(ctx, placeholderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, groupID := range shared {
+		settlements, err := s.settlementRepo.GetOpenInGroup(ctx, groupID)
+		if err != nil {
+			return nil, err
+		}
+		for _, settlement := range settlements {
+			if (settlement.FromUserID == placeholderID && settlement.ToUserID == userID) ||
+				(settlement.FromUserID == userID && settlement.ToUserID == placeholderID) {
+				return nil, fmt.Errorf("%w: settlement %s", ErrPlaceholderConflict, settlement.SettlementID)
+			}
+		}
+		if err := s.groupRepo.MergeMember(ctx, groupID, placeholderID, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	// The placeholder is no longer in the shared groups, so only its other memberships move
+	groupIDs, err := s.groupRepo.ReassignMember(ctx, placeholderID, userID)
+	if err != nil {
+		return nil, err
+	}
+	groupIDs = append(shared, groupIDs...)
+	if err := s.expenseRepo.ReassignUser(ctx, placeholderID, userID); err != nil {
+		return nil, err
+	}
+	if err := s.balanceRepo.ReassignUser(ctx, placeholderID, userID); err != nil {
+		return nil, err
+	}
+	if err := s.settlementRepo.ReassignUser(ctx, placeholderID, userID); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.Delete(ctx, placeholderID); err != nil {
+		return nil, err
+	}
+	return groupIDs, nil
+}
+
+func hashClaimToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
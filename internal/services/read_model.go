@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+)
+
+const (
+	JobReadModelBackfill = "read_model_backfill"
+
+	readModelQueueSize     = 10000
+	readModelFlushInterval = 5 * time.Second
+	// readModelBatchSize flushes early once this many records are waiting
+	readModelBatchSize = 1000
+	// readModelMaxPending drops what is waiting, once the read model has been failing long enough
+	// for this many records to pile up; a backfill brings it back in line
+	readModelMaxPending = 50000
+)
+
+// ReadModelService streams expenses, settlements and balances into the reporting read model as
+// they change. Events are queued by the publisher from Publisher and written in batches by Run;
+// records that changed without an event, and everything from before the exporter was turned
+// on, reach the read model with a backfill.
+type ReadModelService struct {
+	readModelRepo  repositories.ReadModelRepository
+	expenseRepo    repositories.ExpenseRepository
+	settlementRepo repositories.SettlementRepository
+	balanceRepo    repositories.BalanceRepository
+	jobService     *JobService
+	queue          chan Event
+}
+
+func NewReadModelService(
+	readModelRepo repositories.ReadModelRepository,
+	expenseRepo repositories.ExpenseRepository,
+	settlementRepo repositories.SettlementRepository,
+	balanceRepo repositories.BalanceRepository,
+	jobService *JobService,
+) *ReadModelService {
+	return &ReadModelService{
+		readModelRepo:  readModelRepo,
+		expenseRepo:    expenseRepo,
+		settlementRepo: settlementRepo,
+		balanceRepo:    balanceRepo,
+		jobService:     jobService,
+		queue:          make(chan Event, readModelQueueSize),
+	}
+}
+
+// Publisher returns an EventPublisher that passes every event on to next and also queues the
+// expense, settlement and balance events for the read model. When the queue is full the event
+// is dropped from the read model and logged; next still gets it.
+func (s *ReadModelService) Publisher(next EventPublisher) EventPublisher {
+	return readModelPublisher{next: next, service: s}
+}
+
+type readModelPublisher struct {
+	next    EventPublisher
+	service *ReadModelService
+}
+
+func (p readModelPublisher) Publish(event Event) {
+	p.next.Publish(event)
+
+	switch event.Type {
+	case EventExpenseCreated, EventExpenseUpdated, EventSettlementUpdated, EventBalancesChanged:
+	default:
+		return
+	}
+	select {
+	case p.service.queue <- event:
+	default:
+		log.Printf("Read model queue full, dropping %s event", event.Type)
+	}
+}
+
+// readModelPending is what is waiting to be written, latest version of each record only.
+// Balances are read when the batch is written, for the groups and users whose balances moved.
+type readModelPending struct {
+	expenses      map[string]*models.Expense
+	settlements   map[string]*models.Settlement
+	balanceGroups map[string]bool
+	balanceUsers  map[string]bool // personal balances, outside groups
+}
+
+func newReadModelPending() *readModelPending {
+	return &readModelPending{
+		expenses:      make(map[string]*models.Expense),
+		settlements:   make(map[string]*models.Settlement),
+		balanceGroups: make(map[string]bool),
+		balanceUsers:  make(map[string]bool),
+	}
+}
+
+func (p *readModelPending) len() int {
+	return len(p.expenses) + len(p.settlements) + len(p.balanceGroups) + len(p.balanceUsers)
+}
+
+func (p *readModelPending) add(event Event) {
+	switch data := event.Data.(type) {
+	case *models.Expense:
+		p.expenses[data.ExpenseID] = data
+	case models.Expense:
+		p.expenses[data.ExpenseID] = &data
+	case *models.Settlement:
+		p.settlements[data.SettlementID] = data
+	}
+
+	if event.Type != EventBalancesChanged {
+		return
+	}
+	if event.GroupID != nil {
+		p.balanceGroups[*event.GroupID] = true
+		return
+	}
+	for _, userID := range event.Recipients {
+		p.balanceUsers[userID] = true
+	}
+}
+
+// Run writes queued events to the read model until ctx is done. A batch that fails to write is
+// kept and retried with the next one.
+func (s *ReadModelService) Run(ctx context.Context) {
+	ticker := time.NewTicker(readModelFlushInterval)
+	defer ticker.Stop()
+
+	pending := newReadModelPending()
+	for {
+		select {
+		case event := <-s.queue:
+			pending.add(event)
+			if pending.len() < readModelBatchSize {
+				continue
+			}
+		case <-ticker.C:
+		case <-ctx.Done():
+			if pending.len() > 0 {
+				flushCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), readModelFlushInterval)
+				if err := s.write(flushCtx, pending); err != nil {
+					log.Printf("Failed to write to the read model on shutdown: %v", err)
+				}
+				cancel()
+			}
+			return
+		}
+
+		if pending.len() == 0 {
+			continue
+		}
+		if err := s.write(ctx, pending); err != nil {
+			log.Printf("Failed to write to the read model: %v", err)
+			if pending.len() >= readModelMaxPending {
+				log.Printf("Read model unavailable, dropping %d pending records; run a backfill once it is back", pending.len())
+				pending = newReadModelPending()
+			}
+			continue
+		}
+		pending = newReadModelPending()
+	}
+}
+
+func (s *ReadModelService) write(ctx context.Context, pending *readModelPending) error {
+	batch := repositories.ReadModelBatch{SyncedAt: time.Now()}
+	for _, expense := range pending.expenses {
+		batch.Expenses = append(batch.Expenses, expense)
+	}
+	for _, settlement := range pending.settlements {
+		batch.Settlements = append(batch.Settlements, settlement)
+	}
+
+	for groupID := range pending.balanceGroups {
+		balances, err := s.balanceRepo.GetByGroupID(ctx, groupID)
+		if err != nil {
+			return err
+		}
+		batch.Balances = append(batch.Balances, balances...)
+	}
+	for userID := range pending.balanceUsers {
+		balances, err := s.balanceRepo.GetByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, balance := range balances {
+			if balance.GroupID == nil {
+				batch.Balances = append(batch.Balances, balance)
+			}
+		}
+	}
+
+	return s.readModelRepo.Write(ctx, batch)
+}
+
+// StartBackfill copies every expense, settlement and balance into the read model in the
+// background. Live exports keep running meanwhile: each chunk is stamped with the time it was
+// read, so it never replaces a newer version exported while the backfill runs.
+func (s *ReadModelService) StartBackfill(ctx context.Context, adminID string) (*models.Job, error) {
+	return s.jobService.StartExclusive(ctx, JobReadModelBackfill, adminID, s.backfill)
+}
+
+func (s *ReadModelService) backfill(ctx context.Context, progress ProgressFunc) (map[string]interface{}, error) {
+	var batch repositories.ReadModelBatch
+	copied := map[string]int{}
+	flush := func(kind string) error {
+		if batch.Len() == 0 {
+			return nil
+		}
+		if err := s.readModelRepo.Write(ctx, batch); err != nil {
+			return fmt.Errorf("failed to write %s to the read model: %v", kind, err)
+		}
+		copied[kind] += batch.Len()
+		batch = repositories.ReadModelBatch{}
+		return nil
+	}
+	// startChunk stamps the chunk with the time its first record is read
+	startChunk := func() {
+		if batch.Len() == 0 {
+			batch.SyncedAt = time.Now()
+		}
+	}
+
+	err := s.expenseRepo.ForEach(ctx, func(expense *models.Expense) error {
+		startChunk()
+		batch.Expenses = append(batch.Expenses, expense)
+		if batch.Len() >= readModelBatchSize {
+			return flush("expenses")
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush("expenses")
+	}
+	if err != nil {
+		return nil, err
+	}
+	progress(1, 3)
+
+	err = s.settlementRepo.ForEach(ctx, func(settlement *models.Settlement) error {
+		startChunk()
+		batch.Settlements = append(batch.Settlements, settlement)
+		if batch.Len() >= readModelBatchSize {
+			return flush("settlements")
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush("settlements")
+	}
+	if err != nil {
+		return nil, err
+	}
+	progress(2, 3)
+
+	err = s.balanceRepo.ForEach(ctx, func(balance *models.Balance) error {
+		startChunk()
+		batch.Balances = append(batch.Balances, balance)
+		if batch.Len() >= readModelBatchSize {
+			return flush("balances")
+		}
+		return nil
+	})
+	if err == nil {
+		err = flush("balances")
+	}
+	if err != nil {
+		return nil, err
+	}
+	progress(3, 3)
+
+	return map[string]interface{}{
+		"expenses":    copied["expenses"],
+		"settlements": copied["settlements"],
+		"balances":    copied["balances"],
+	}, nil
+}
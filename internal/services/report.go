@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+)
+
+type ReportService struct {
+	aggregations repositories.ExpenseAggregations
+}
+
+// NewReportService creates a ReportService that adds up expenses with aggregations: the expense
+// repository, or the reporting read model when reports are served from it
+func NewReportService(aggregations repositories.ExpenseAggregations) *ReportService {
+	return &ReportService{aggregations: aggregations}
+}
+
+// MonthlyReport adds up the user's shares of the month's expenses across all their groups per
+// category and compares them with the previous month. An empty month means the current one.
+func (s *ReportService) MonthlyReport(ctx context.Context, userID string, month string) (*models.MonthlyReport, error) {
+	if month == "" {
+		month = time.Now().UTC().Format(budgetMonthFormat)
+	}
+	start, end, err := parseStatementMonth(month)
+	if err != nil {
+		return nil, err
+	}
+	previousStart := start.AddDate(0, -1, 0)
+
+	current, err := s.aggregations.SumUserSharesByCategoryInPeriod(ctx, userID, start, end)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := s.aggregations.SumUserSharesByCategoryInPeriod(ctx, userID, previousStart, start)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.MonthlyReport{
+		UserID:        userID,
+		Month:         month,
+		PreviousMonth: previousStart.Format(budgetMonthFormat),
+		Totals:        compareMonths(current, previous, false),
+		Categories:    compareMonths(current, previous, true),
+		GeneratedAt:   time.Now(),
+	}, nil
+}
+
+// compareMonths lines up two months of totals per currency, and per category when byCategory is
+// set. Categories spent on in either month are included. Amounts are added up in whole cents.
+func compareMonths(current, previous []repositories.CategoryTotal, byCategory bool) []models.ReportLine {
+	type key struct{ currency, category string }
+	type cents struct{ current, previous int64 }
+
+	sums := make(map[key]*cents)
+	add := func(totals []repositories.CategoryTotal, isCurrent bool) {
+		for _, total := range totals {
+			k := key{currency: total.Currency}
+			if byCategory {
+				k.category = total.Category
+			}
+			if sums[k] == nil {
+				sums[k] = &cents{}
+			}
+			amount := int64(math.Round(total.Total * 100))
+			if isCurrent {
+				sums[k].current += amount
+			} else {
+				sums[k].previous += amount
+			}
+		}
+	}
+	add(current, true)
+	add(previous, false)
+
+	lines := make([]models.ReportLine, 0, len(sums))
+	for k, sum := range sums {
+		line := models.ReportLine{
+			Category:       k.category,
+			Currency:       k.currency,
+			Amount:         float64(sum.current) / 100,
+			PreviousAmount: float64(sum.previous) / 100,
+			Change:         float64(sum.current-sum.previous) / 100,
+		}
+		if sum.previous != 0 {
+			percent := math.Round(float64(sum.current-sum.previous)*1000/float64(sum.previous)) / 10
+			line.ChangePercent = &percent
+		}
+		lines = append(lines, line)
+	}
+
+	// Per currency, biggest spending first
+	sort.Slice(lines, func(i, j int) bool {
+		a, b := lines[i], lines[j]
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		if a.Amount != b.Amount {
+			return a.Amount > b.Amount
+		}
+		return a.Category < b.Category
+	})
+	return lines
+}
@@ -0,0 +1,141 @@
+package services
+
+import (
+	"expvar"
+	"log/slog"
+	"math"
+	"sync"
+
+	"divvydoo/backend/internal/models"
+)
+
+// roundingEpsilon ignores float noise far below a cent
+const roundingEpsilon = 1e-9
+
+var (
+	// shareRoundingAdjusted counts expenses whose shares were nudged away from the exact split
+	shareRoundingAdjusted = expvar.NewInt("share_rounding_adjusted_expenses")
+	// shareRoundingAdjustment sums those nudges; equal splits hand out leftover cents this way
+	shareRoundingAdjustment = expvar.NewFloat("share_rounding_adjustment_total")
+	// shareRoundingDrift sums how far shares missed the expense amount, which should always be zero
+	shareRoundingDrift = expvar.NewFloat("share_rounding_drift_total")
+	// shareRoundingDriftAlerts counts groups whose accumulated drift crossed the alert threshold
+	shareRoundingDriftAlerts = expvar.NewInt("share_rounding_drift_alerts")
+)
+
+// RoundingMonitor records the rounding applied by share calculations and warns when a group's
+// accumulated drift (shares that don't add up to the expense amount) passes a threshold.
+// Rounding adjustments are expected; drift means the money math is wrong somewhere.
+type RoundingMonitor struct {
+	threshold float64
+
+	mu    sync.Mutex
+	drift map[string]float64 // group ID -> drift since the last alert
+}
+
+// NewRoundingMonitor alerts once a group's drift reaches threshold; zero or less disables alerting
+func NewRoundingMonitor(threshold float64) *RoundingMonitor {
+	return &RoundingMonitor{threshold: threshold, drift: make(map[string]float64)}
+}
+
+// Record compares the calculated shares with the exact split of the expense as submitted
+func (m *RoundingMonitor) Record(expense models.Expense, shares []models.SplitShare) {
+	if m == nil {
+		return
+	}
+
+	exact := exactShares(expense)
+	adjustment, total := 0.0, 0.0
+	for i, share := range shares {
+		if i < len(exact) {
+			adjustment += math.Abs(share.Value - exact[i])
+		}
+		total += share.Value
+	}
+	drift := math.Abs(total - expense.Amount)
+
+	if adjustment < roundingEpsilon && drift < roundingEpsilon {
+		return
+	}
+
+	groupID := ""
+	if expense.GroupID != nil {
+		groupID = *expense.GroupID
+	}
+
+	if adjustment >= roundingEpsilon {
+		shareRoundingAdjusted.Add(1)
+		shareRoundingAdjustment.Add(adjustment)
+	}
+	slog.Debug("share rounding applied",
+		"expense_id", expense.ExpenseID,
+		"group_id", groupID,
+		"split_type", expense.Split.Type,
+		"adjustment", adjustment,
+		"drift", drift,
+	)
+
+	if drift < roundingEpsilon {
+		return
+	}
+	shareRoundingDrift.Add(drift)
+
+	// Drift outside a group can't accumulate anywhere, so it is only counted
+	if groupID == "" || m.threshold <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	accumulated := m.drift[groupID] + drift
+	alert := accumulated >= m.threshold
+	if alert {
+		delete(m.drift, groupID)
+	} else {
+		m.drift[groupID] = accumulated
+	}
+	m.mu.Unlock()
+
+	if alert {
+		shareRoundingDriftAlerts.Add(1)
+		slog.Warn("share rounding drift exceeded threshold",
+			"group_id", groupID,
+			"drift", accumulated,
+			"threshold", m.threshold,
+			"expense_id", expense.ExpenseID,
+		)
+	}
+}
+
+// exactShares is the unrounded amount each split participant owes
+func exactShares(expense models.Expense) []float64 {
+	details := expense.Split.Details
+	exact := make([]float64, len(details))
+
+	switch expense.Split.Type {
+	case models.SplitEqual:
+		for i := range details {
+			exact[i] = expense.Amount / float64(len(details))
+		}
+	case models.SplitPercentage:
+		for i, share := range details {
+			exact[i] = share.Value / 100 * expense.Amount
+		}
+	case models.SplitShares:
+		total := 0.0
+		for _, share := range details {
+			total += share.Value
+		}
+		if total == 0 {
+			return nil
+		}
+		for i, share := range details {
+			exact[i] = share.Value / total * expense.Amount
+		}
+	default:
+		for i, share := range details {
+			exact[i] = share.Value
+		}
+	}
+
+	return exact
+}
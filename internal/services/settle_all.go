@@ -0,0 +1,173 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var ErrNothingToSettle = utils.NewCustomError(http.StatusUnprocessableEntity, utils.CodeNothingToSettle, "no debts left to settle in the group")
+
+// SettleAll creates, in one transaction, the pending settlements that would settle up the group:
+// the payments that clear its balances in the fewest transfers, per currency. Admins get every
+// payment, other members only the ones they make. Settlements already open in the group count
+// as paid, so calling it again doesn't ask anyone to pay twice. Concurrent calls conflict on the
+// group, so the one that retries sees the other's settlements. Each settlement then goes through
+// the usual mark-paid and confirm steps.
+func (s *SettlementService) SettleAll(ctx context.Context, groupID string, userID string, req models.SettleAllRequest) ([]*models.Settlement, error) {
+	if !req.Method.IsValid() {
+		return nil, ErrInvalidSettlementMethod
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrGroupNotFound) {
+			return nil, ErrNotGroupMember
+		}
+		return nil, err
+	}
+	member := activeMember(group, userID)
+	if member == nil {
+		return nil, ErrNotGroupMember
+	}
+	isAdmin := member.Role == models.RoleAdmin
+
+	session, err := s.settlementRepo.StartSession()
+	if err != nil {
+		return nil, fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	result, err := session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		// Inserting the settlements alone wouldn't conflict with another call doing the same
+		if err := s.groupRepo.BumpSettlementVersion(sessCtx, groupID); err != nil {
+			return nil, err
+		}
+		payments, err := s.outstandingPayments(sessCtx, groupID)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		var settlements []*models.Settlement
+		for _, payment := range payments {
+			if !isAdmin && payment.FromUserID != userID {
+				continue
+			}
+			settlements = append(settlements, &models.Settlement{
+				SettlementID: uuid.New().String(),
+				FromUserID:   payment.FromUserID,
+				ToUserID:     payment.ToUserID,
+				GroupID:      &group.GroupID,
+				Amount:       payment.Amount,
+				Currency:     payment.Currency,
+				Status:       models.SettlementPending,
+				Method:       req.Method,
+				Description:  req.Description,
+				CreatedAt:    now,
+				UpdatedAt:    now,
+			})
+		}
+		if len(settlements) == 0 {
+			return nil, ErrNothingToSettle
+		}
+
+		if err := s.settlementRepo.InsertMany(sessCtx, settlements); err != nil {
+			return nil, err
+		}
+
+		out := s.outbox.Writer(sessCtx)
+		for _, settlement := range settlements {
+			s.publishSettlement(sessCtx, out, settlement)
+			deliver(sessCtx, out, Notification{
+				UserID: settlement.ToUserID,
+				Type:   models.NotificationSettlementRequested,
+				Title:  "New settlement",
+				Body:   "A payment of %.2f %s to you was recorded. You'll be asked to confirm it once it's sent.",
+				Data:   settlementNotificationData(settlement),
+
+				BodyArgs: []interface{}{settlement.Amount, settlement.Currency},
+			})
+			if settlement.FromUserID != userID {
+				deliver(sessCtx, out, Notification{
+					UserID: settlement.FromUserID,
+					Type:   models.NotificationSettlementRequested,
+					Title:  "Settlement to pay",
+					Body:   "A group admin recorded a payment of %.2f %s you owe. Mark it as paid once you've sent it.",
+					Data:   settlementNotificationData(settlement),
+
+					BodyArgs: []interface{}{settlement.Amount, settlement.Currency},
+				})
+			}
+		}
+		return settlements, out.Err()
+	})
+	if err != nil {
+		if errors.Is(err, ErrNothingToSettle) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("transaction failed: %v", err)
+	}
+	s.outbox.Wake()
+
+	return result.([]*models.Settlement), nil
+}
+
+// outstandingPayments works out the payments that settle the group's balances, leaving out what
+// its open settlements will move once they complete
+func (s *SettlementService) outstandingPayments(ctx context.Context, groupID string) ([]models.SettleUpPayment, error) {
+	positions, err := s.groupPositions(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return settleUpPayments(positions), nil
+}
+
+// groupPositions returns each member's balances in the group as they will be once the group's
+// open settlements complete
+func (s *SettlementService) groupPositions(ctx context.Context, groupID string) ([]*models.Balance, error) {
+	balances, err := s.balanceRepo.GetByGroupID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	open, err := s.settlementRepo.GetOpenInGroup(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return projectedPositions(groupID, balances, open), nil
+}
+
+// projectedPositions applies the open settlements to the group's balances, as completing them
+// would
+func projectedPositions(groupID string, balances []*models.Balance, open []*models.Settlement) []*models.Balance {
+	type key struct{ userID, currency string }
+	net := make(map[key]*models.Balance, len(balances))
+	var positions []*models.Balance
+	position := func(userID, currency string) *models.Balance {
+		k := key{userID, currency}
+		if net[k] == nil {
+			net[k] = &models.Balance{UserID: userID, GroupID: &groupID, Currency: currency}
+			positions = append(positions, net[k])
+		}
+		return net[k]
+	}
+	for _, balance := range balances {
+		position(balance.UserID, balance.Currency).Balance += balance.Balance
+	}
+	// Completing a settlement raises the payer's balance and lowers the payee's
+	for _, settlement := range open {
+		position(settlement.FromUserID, settlement.Currency).Balance += settlement.Amount
+		position(settlement.ToUserID, settlement.Currency).Balance -= settlement.Amount
+	}
+
+	return positions
+}
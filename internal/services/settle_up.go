@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+var ErrNothingOwed = utils.NewCustomError(http.StatusUnprocessableEntity, utils.CodeNothingOwed, "you don't owe this user anything to settle")
+
+// SettleUp creates a settlement paying the counterparty exactly what the user owes them, so
+// clients don't have to work the amount out. Settlements still open between them count as paid.
+//
+// Outside groups that is the pair's direct balance. In a group, balances are only kept per member,
+// so it is as much of the user's debt as the counterparty is owed: paying it never leaves either
+// of them owing or owed more than before.
+//
+// The amount is worked out in the transaction that creates the settlement, so two settle-ups at
+// once conflict and the one retried counts the other's settlement as paid.
+func (s *SettlementService) SettleUp(ctx context.Context, userID string, req models.SettleUpRequest) (*models.Settlement, error) {
+	if req.CounterpartyID == userID {
+		return nil, fmt.Errorf("%w: cannot settle up with yourself", ErrInvalidSettlement)
+	}
+	if !req.Method.IsValid() {
+		return nil, ErrInvalidSettlementMethod
+	}
+
+	exists, err := s.userRepo.Exists(ctx, req.CounterpartyID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+
+	if req.GroupID != nil {
+		group, err := s.groupRepo.GetByID(ctx, *req.GroupID)
+		if err != nil {
+			if errors.Is(err, repositories.ErrGroupNotFound) {
+				return nil, ErrNotGroupMember
+			}
+			return nil, err
+		}
+		if activeMember(group, userID) == nil {
+			return nil, ErrNotGroupMember
+		}
+	}
+
+	now := time.Now()
+	settlement := &models.Settlement{
+		SettlementID: uuid.New().String(),
+		FromUserID:   userID,
+		ToUserID:     req.CounterpartyID,
+		GroupID:      req.GroupID,
+		Status:       models.SettlementPending,
+		Method:       req.Method,
+		Description:  req.Description,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	return s.createSettlement(ctx, settlement, func(ctx context.Context) error {
+		var owed map[string]float64
+		var err error
+		if req.GroupID != nil {
+			owed, err = s.owedInGroup(ctx, userID, req.CounterpartyID, *req.GroupID)
+		} else {
+			owed, err = s.owedDirectly(ctx, userID, req.CounterpartyID)
+		}
+		if err != nil {
+			return err
+		}
+
+		currency, err := settleUpCurrency(owed, req.Currency)
+		if err != nil {
+			return err
+		}
+		settlement.Amount = owed[currency]
+		settlement.Currency = currency
+		return nil
+	})
+}
+
+// owedInGroup returns, per currency, what userID can pay counterpartyID in the group
+func (s *SettlementService) owedInGroup(ctx context.Context, userID string, counterpartyID string, groupID string) (map[string]float64, error) {
+	positions, err := s.groupPositions(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	debts := make(map[string]float64)
+	credits := make(map[string]float64)
+	for _, position := range positions {
+		switch position.UserID {
+		case userID:
+			debts[position.Currency] = -position.Balance
+		case counterpartyID:
+			credits[position.Currency] = position.Balance
+		}
+	}
+
+	owed := make(map[string]float64)
+	for currency, debt := range debts {
+		if amount := roundCents(math.Min(debt, credits[currency])); amount > 0 {
+			owed[currency] = amount
+		}
+	}
+	return owed, nil
+}
+
+// owedDirectly returns, per currency, what userID owes counterpartyID outside groups
+func (s *SettlementService) owedDirectly(ctx context.Context, userID string, counterpartyID string) (map[string]float64, error) {
+	direct, err := s.balanceRepo.GetDirectBalances(ctx, userID, &counterpartyID)
+	if err != nil {
+		return nil, err
+	}
+	open, err := s.settlementRepo.GetOpenBetweenUsers(ctx, userID, counterpartyID)
+	if err != nil {
+		return nil, err
+	}
+
+	net := make(map[string]float64)
+	for _, balance := range direct {
+		net[balance.Currency] -= balance.For(userID)
+	}
+	for _, settlement := range open {
+		if settlement.FromUserID == userID {
+			net[settlement.Currency] -= settlement.Amount
+		} else {
+			net[settlement.Currency] += settlement.Amount
+		}
+	}
+
+	owed := make(map[string]float64)
+	for currency, amount := range net {
+		if amount = roundCents(amount); amount > 0 {
+			owed[currency] = amount
+		}
+	}
+	return owed, nil
+}
+
+// settleUpCurrency picks the currency to settle: the requested one, or the only one owed
+func settleUpCurrency(owed map[string]float64, requested string) (string, error) {
+	if requested != "" {
+		currency := strings.ToUpper(strings.TrimSpace(requested))
+		if owed[currency] <= 0 {
+			return "", ErrNothingOwed
+		}
+		return currency, nil
+	}
+
+	switch len(owed) {
+	case 0:
+		return "", ErrNothingOwed
+	case 1:
+		for currency := range owed {
+			return currency, nil
+		}
+	}
+	currencies := make([]string, 0, len(owed))
+	for currency := range owed {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return "", fmt.Errorf("%w: debts are in %s; give the currency to settle", ErrInvalidSettlement, strings.Join(currencies, ", "))
+}
@@ -4,36 +4,66 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"net/http"
 	"time"
 
 	"divvydoo/backend/internal/models"
 	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
 
 	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/mongo"
 )
 
 var (
-	ErrSettlementNotFound  = errors.New("settlement not found")
-	ErrInvalidSettlement   = errors.New("invalid settlement request")
-	ErrSettlementCompleted = errors.New("settlement is already completed")
+	ErrSettlementNotFound          = utils.NewCustomError(http.StatusNotFound, utils.CodeSettlementNotFound, "settlement not found")
+	ErrInvalidSettlement           = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidSettlement, "invalid settlement request")
+	ErrSettlementCompleted         = utils.NewCustomError(http.StatusConflict, utils.CodeSettlementCompleted, "settlement is already completed")
+	ErrNotSettlementPayer          = utils.NewCustomError(http.StatusForbidden, utils.CodeNotSettlementPayer, "only the payer can mark the settlement as paid")
+	ErrNotSettlementPayee          = utils.NewCustomError(http.StatusForbidden, utils.CodeNotSettlementPayee, "only the payee can confirm or reject the settlement")
+	ErrSettlementNotAwaiting       = utils.NewCustomError(http.StatusConflict, utils.CodeSettlementNotAwaiting, "settlement is not awaiting confirmation")
+	ErrSettlementStateChanged      = utils.NewCustomError(http.StatusConflict, utils.CodeSettlementStateChanged, "settlement was updated by another request, please retry")
+	ErrInvalidSettlementMethod     = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidSettlementMethod, "invalid settlement method")
+	ErrInvalidSettlementAmount     = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidSettlementAmount, "amount must be positive")
+	ErrSettlementAuthorizationSelf = utils.NewCustomError(http.StatusBadRequest, utils.CodeSettlementAuthorizationSelf, "cannot authorize yourself as a payer")
+	ErrInvalidSettlementStatus     = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidSettlementStatus, "invalid settlement status")
+	ErrSettlementNotPending        = utils.NewCustomError(http.StatusConflict, utils.CodeSettlementNotPending, "settlement is no longer pending")
+	ErrSettlementNotCancellable    = utils.NewCustomError(http.StatusConflict, utils.CodeSettlementNotCancellable, "only pending settlements can be cancelled")
+	ErrSettlementNotVoidable       = utils.NewCustomError(http.StatusConflict, utils.CodeSettlementNotVoidable, "only pending settlements or ones awaiting confirmation can be voided")
 )
 
 type SettlementService struct {
-	settlementRepo repositories.SettlementRepository
-	balanceRepo    repositories.BalanceRepository
-	userRepo       repositories.UserRepository
+	settlementRepo    repositories.SettlementRepository
+	authorizationRepo repositories.SettlementAuthorizationRepository
+	balanceRepo       repositories.BalanceRepository
+	userRepo          repositories.UserRepository
+	groupRepo         repositories.GroupRepository
+	outbox            *OutboxService
+	aggregates        *AggregateCache
+	// autoConfirmAfter applies to settlements outside a group and to groups that
+	// haven't chosen their own timeout. Zero disables auto-confirmation.
+	autoConfirmAfter time.Duration
 }
 
 func NewSettlementService(
 	settlementRepo repositories.SettlementRepository,
+	authorizationRepo repositories.SettlementAuthorizationRepository,
 	balanceRepo repositories.BalanceRepository,
 	userRepo repositories.UserRepository,
+	groupRepo repositories.GroupRepository,
+	outbox *OutboxService,
+	aggregates *AggregateCache,
+	autoConfirmAfter time.Duration,
 ) *SettlementService {
 	return &SettlementService{
-		settlementRepo: settlementRepo,
-		balanceRepo:    balanceRepo,
-		userRepo:       userRepo,
+		settlementRepo:    settlementRepo,
+		authorizationRepo: authorizationRepo,
+		balanceRepo:       balanceRepo,
+		userRepo:          userRepo,
+		groupRepo:         groupRepo,
+		outbox:            outbox,
+		aggregates:        aggregates,
+		autoConfirmAfter:  autoConfirmAfter,
 	}
 }
 
@@ -48,11 +78,15 @@ func (s *SettlementService) CreateSettlement(ctx context.Context, req models.Set
 		return nil, err
 	}
 	if len(missingUsers) > 0 {
-		return nil, fmt.Errorf("user %s does not exist", missingUsers[0])
+		return nil, fmt.Errorf("%w: %s", ErrUserNotFound, missingUsers[0])
 	}
 
 	if req.Amount <= 0 {
-		return nil, fmt.Errorf("amount must be positive")
+		return nil, ErrInvalidSettlementAmount
+	}
+
+	if !req.Method.IsValid() {
+		return nil, ErrInvalidSettlementMethod
 	}
 
 	settlement := &models.Settlement{
@@ -69,127 +103,673 @@ func (s *SettlementService) CreateSettlement(ctx context.Context, req models.Set
 		UpdatedAt:    time.Now(),
 	}
 
-	return s.settlementRepo.Create(ctx, settlement)
+	return s.createSettlement(ctx, settlement, nil)
+}
+
+// createSettlement stores the settlement and notifies the payee in one transaction. prepare, if
+// set, runs first in that transaction and may fill in the settlement from what it reads there.
+func (s *SettlementService) createSettlement(ctx context.Context, settlement *models.Settlement, prepare func(ctx context.Context) error) (*models.Settlement, error) {
+	return s.transition(ctx, settlement.SettlementID, func(ctx context.Context, out *OutboxWriter) error {
+		// A currency conversion refuses groups with open settlements; touching the group makes
+		// one running at once conflict with this settlement instead of missing it. Settlements
+		// outside groups touch the payer, so those they create at once conflict too.
+		if settlement.GroupID != nil {
+			if err := s.groupRepo.BumpSettlementVersion(ctx, *settlement.GroupID); err != nil {
+				return err
+			}
+		} else if err := s.userRepo.BumpSettlementVersion(ctx, settlement.FromUserID); err != nil {
+			return err
+		}
+		if prepare != nil {
+			if err := prepare(ctx); err != nil {
+				return err
+			}
+		}
+		if _, err := s.settlementRepo.Create(ctx, settlement); err != nil {
+			return err
+		}
+		deliver(ctx, out, Notification{
+			UserID: settlement.ToUserID,
+			Type:   models.NotificationSettlementRequested,
+			Title:  "New settlement",
+			Body:   "A payment of %.2f %s to you was recorded. You'll be asked to confirm it once it's sent.",
+			Data:   settlementNotificationData(settlement),
+
+			BodyArgs: []interface{}{settlement.Amount, settlement.Currency},
+		})
+		return nil
+	})
 }
 
 func (s *SettlementService) GetSettlement(ctx context.Context, settlementID string, userID string) (*models.Settlement, error) {
-	settlement, err := s.settlementRepo.GetByID(ctx, settlementID)
+	return s.getSettlementFor(ctx, settlementID, userID)
+}
+
+func (s *SettlementService) GetUserSettlements(ctx context.Context, userID string, limit, offset int64) ([]*models.Settlement, error) {
+	return s.settlementRepo.GetByUserID(ctx, userID, limit, offset)
+}
+
+func (s *SettlementService) GetGroupSettlements(ctx context.Context, groupID string, limit, offset int64) ([]*models.Settlement, error) {
+	return s.settlementRepo.GetByGroupID(ctx, groupID, limit, offset)
+}
+
+// ListUserSettlements returns a page of the user's settlements, newest first, and the cursor for the next page.
+// An empty statuses matches any status but voided.
+func (s *SettlementService) ListUserSettlements(ctx context.Context, userID string, statuses []models.SettlementStatus, cursor string, limit, offset int64) ([]*models.Settlement, string, error) {
+	if err := validateSettlementStatuses(statuses); err != nil {
+		return nil, "", err
+	}
+
+	position, err := decodeCursor(cursor)
 	if err != nil {
-		if errors.Is(err, repositories.ErrSettlementNotFound) {
-			return nil, ErrSettlementNotFound
+		return nil, "", err
+	}
+	return s.settlementRepo.ListByUserID(ctx, userID, statuses, position, limit, offset)
+}
+
+// ListGroupSettlements returns a page of group settlements, newest first, and the cursor for the next page.
+// An empty statuses matches any status but voided.
+func (s *SettlementService) ListGroupSettlements(ctx context.Context, groupID string, userID string, statuses []models.SettlementStatus, cursor string, limit, offset int64) ([]*models.Settlement, string, error) {
+	if err := validateSettlementStatuses(statuses); err != nil {
+		return nil, "", err
+	}
+
+	if err := requireGroupMember(ctx, s.groupRepo, groupID, userID); err != nil {
+		return nil, "", err
+	}
+
+	position, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	return s.settlementRepo.ListByGroupID(ctx, groupID, statuses, position, limit, offset)
+}
+
+// ListOpenSettlements returns the user's settlements that still need action from
+// someone: pending ones and ones awaiting the payee's confirmation.
+func (s *SettlementService) ListOpenSettlements(ctx context.Context, userID string, cursor string, limit, offset int64) ([]*models.Settlement, string, error) {
+	statuses := []models.SettlementStatus{models.SettlementPending, models.SettlementAwaitingConfirmation}
+	return s.ListUserSettlements(ctx, userID, statuses, cursor, limit, offset)
+}
+
+func validateSettlementStatuses(statuses []models.SettlementStatus) error {
+	for _, status := range statuses {
+		if !status.IsValid() {
+			return fmt.Errorf("%w: %q", ErrInvalidSettlementStatus, status)
 		}
+	}
+	return nil
+}
+
+// CompleteSettlement is called by the payer to mark a settlement as paid. If the payee
+// has pre-authorized this payer and method, or the settlement's group doesn't require
+// confirmation, the settlement completes immediately; otherwise it waits for the payee
+// to confirm receipt until the auto-confirm timeout, if any, passes.
+func (s *SettlementService) CompleteSettlement(ctx context.Context, settlementID string, userID string, transactionID *string) (*models.Settlement, error) {
+	settlement, err := s.getSettlementFor(ctx, settlementID, userID)
+	if err != nil {
 		return nil, err
 	}
 
-	// Check if user is involved in the settlement
-	if settlement.FromUserID != userID && settlement.ToUserID != userID {
-		return nil, ErrSettlementNotFound
+	// Only the person who owes money can mark it as paid
+	if settlement.FromUserID != userID {
+		return nil, ErrNotSettlementPayer
 	}
 
-	return settlement, nil
+	switch settlement.Status {
+	case models.SettlementPending:
+	case models.SettlementAwaitingConfirmation, models.SettlementCompleted:
+		// Already marked as paid, so a repeated submit just returns the current state
+		return settlement, nil
+	default:
+		return nil, ErrSettlementNotPending
+	}
+
+	settings, err := s.groupSettings(ctx, settlement.GroupID)
+	if err != nil {
+		return nil, err
+	}
+
+	skipConfirmation := !settings.RequiresSettlementConfirmation()
+	if !skipConfirmation {
+		skipConfirmation, err = s.authorizationRepo.IsAuthorized(ctx, settlement.ToUserID, settlement.FromUserID, settlement.Method)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	updated, err := s.transition(ctx, settlementID, func(ctx context.Context, out *OutboxWriter) error {
+		if skipConfirmation {
+			return s.applySettlement(ctx, out, settlement, []models.SettlementStatus{models.SettlementPending}, transactionID, true)
+		}
+		if err := s.settlementRepo.MarkAwaitingConfirmation(ctx, settlementID, transactionID, s.autoConfirmAt(settings)); err != nil {
+			return err
+		}
+		deliver(ctx, out, Notification{
+			UserID: settlement.ToUserID,
+			Type:   models.NotificationSettlementMarkedPaid,
+			Title:  "Payment marked as sent",
+			Body:   "A payment of %.2f %s was marked as sent to you. Confirm once you've received it.",
+			Data:   settlementNotificationData(settlement),
+
+			BodyArgs: []interface{}{settlement.Amount, settlement.Currency},
+		})
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrSettlementStateChanged) {
+			return s.resolveStateChange(ctx, settlementID, models.SettlementAwaitingConfirmation, models.SettlementCompleted)
+		}
+		return nil, err
+	}
+
+	return updated, nil
 }
 
-func (s *SettlementService) GetUserSettlements(ctx context.Context, userID string, limit, offset int64) ([]*models.Settlement, error) {
-	return s.settlementRepo.GetByUserID(ctx, userID, limit, offset)
+// AutoConfirmDue completes settlements whose payee neither confirmed nor rejected them
+// before their auto-confirm time, and returns how many were completed.
+func (s *SettlementService) AutoConfirmDue(ctx context.Context, batchSize int64) (int, error) {
+	due, err := s.settlementRepo.GetDueForAutoConfirm(ctx, time.Now(), batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	confirmed := 0
+	for _, settlement := range due {
+		_, err := s.transition(ctx, settlement.SettlementID, func(ctx context.Context, out *OutboxWriter) error {
+			if err := s.applySettlement(ctx, out, settlement, []models.SettlementStatus{models.SettlementAwaitingConfirmation}, nil, true); err != nil {
+				return err
+			}
+			for _, userID := range []string{settlement.FromUserID, settlement.ToUserID} {
+				deliver(ctx, out, Notification{
+					UserID: userID,
+					Type:   models.NotificationSettlementAutoConfirmed,
+					Title:  "Settlement confirmed automatically",
+					Body:   "The payment of %.2f %s was confirmed automatically because the recipient didn't respond in time.",
+					Data:   settlementNotificationData(settlement),
+
+					BodyArgs: []interface{}{settlement.Amount, settlement.Currency},
+				})
+			}
+			return nil
+		})
+		if err != nil {
+			// The payee confirmed or rejected it in the meantime; nothing to do
+			if errors.Is(err, repositories.ErrSettlementStateChanged) {
+				continue
+			}
+			return confirmed, err
+		}
+		confirmed++
+	}
+
+	return confirmed, nil
 }
 
-func (s *SettlementService) GetGroupSettlements(ctx context.Context, groupID string, limit, offset int64) ([]*models.Settlement, error) {
-	return s.settlementRepo.GetByGroupID(ctx, groupID, limit, offset)
+// groupSettings returns the settings of the settlement's group, or the defaults for settlements outside a group
+func (s *SettlementService) groupSettings(ctx context.Context, groupID *string) (models.GroupSettings, error) {
+	if groupID == nil {
+		return models.GroupSettings{}, nil
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, *groupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrGroupNotFound) {
+			return models.GroupSettings{}, nil
+		}
+		return models.GroupSettings{}, err
+	}
+
+	return group.Settings, nil
 }
 
-func (s *SettlementService) CompleteSettlement(ctx context.Context, settlementID string, userID string, transactionID *string) error {
-	settlement, err := s.settlementRepo.GetByID(ctx, settlementID)
+func (s *SettlementService) autoConfirmAt(settings models.GroupSettings) *time.Time {
+	after := s.autoConfirmAfter
+	if settings.AutoConfirmAfterHours != nil {
+		after = time.Duration(*settings.AutoConfirmAfterHours) * time.Hour
+	}
+	if after <= 0 {
+		return nil
+	}
+
+	at := time.Now().Add(after)
+	return &at
+}
+
+// publishSettlement pushes the settlement's new state to its group, or to both parties outside a group
+func (s *SettlementService) publishSettlement(ctx context.Context, publisher EventPublisher, settlement *models.Settlement) {
+	publishEvent(ctx, publisher, s.groupRepo, Event{
+		Type:    EventSettlementUpdated,
+		GroupID: settlement.GroupID,
+		Data:    settlement,
+	}, settlement.FromUserID, settlement.ToUserID)
+}
+
+func settlementNotificationData(settlement *models.Settlement) map[string]interface{} {
+	data := map[string]interface{}{
+		"settlement_id": settlement.SettlementID,
+		"amount":        settlement.Amount,
+		"currency":      settlement.Currency,
+	}
+	if settlement.GroupID != nil {
+		data["group_id"] = *settlement.GroupID
+	}
+	return data
+}
+
+// ConfirmSettlement is called by the payee to confirm receipt, which applies the balance changes
+func (s *SettlementService) ConfirmSettlement(ctx context.Context, settlementID string, userID string) (*models.Settlement, error) {
+	settlement, err := s.getSettlementFor(ctx, settlementID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if settlement.ToUserID != userID {
+		return nil, ErrNotSettlementPayee
+	}
+
+	switch settlement.Status {
+	case models.SettlementAwaitingConfirmation:
+	case models.SettlementCompleted:
+		return settlement, nil
+	default:
+		return nil, ErrSettlementNotAwaiting
+	}
+
+	confirmed, err := s.transition(ctx, settlementID, func(ctx context.Context, out *OutboxWriter) error {
+		if err := s.applySettlement(ctx, out, settlement, []models.SettlementStatus{models.SettlementAwaitingConfirmation}, nil, false); err != nil {
+			return err
+		}
+		deliver(ctx, out, Notification{
+			UserID: settlement.FromUserID,
+			Type:   models.NotificationSettlementConfirmed,
+			Title:  "Payment confirmed",
+			Body:   "Your payment of %.2f %s was confirmed by the recipient.",
+			Data:   settlementNotificationData(settlement),
+
+			BodyArgs: []interface{}{settlement.Amount, settlement.Currency},
+		})
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrSettlementStateChanged) {
+			return s.resolveStateChange(ctx, settlementID, models.SettlementCompleted)
+		}
+		return nil, err
+	}
+
+	return confirmed, nil
+}
+
+// RejectSettlement is called by the payee when the payment never arrived; the settlement goes back to pending
+func (s *SettlementService) RejectSettlement(ctx context.Context, settlementID string, userID string, reason string) (*models.Settlement, error) {
+	settlement, err := s.getSettlementFor(ctx, settlementID, userID)
 	if err != nil {
+		return nil, err
+	}
+
+	if settlement.ToUserID != userID {
+		return nil, ErrNotSettlementPayee
+	}
+
+	if settlement.Status != models.SettlementAwaitingConfirmation {
+		return nil, ErrSettlementNotAwaiting
+	}
+
+	rejected, err := s.transition(ctx, settlementID, func(ctx context.Context, out *OutboxWriter) error {
+		if err := s.settlementRepo.MarkRejected(ctx, settlementID, reason); err != nil {
+			return err
+		}
+		data := settlementNotificationData(settlement)
+		data["reason"] = reason
+		deliver(ctx, out, Notification{
+			UserID: settlement.FromUserID,
+			Type:   models.NotificationSettlementRejected,
+			Title:  "Payment not received",
+			Body:   "The recipient says your payment of %.2f %s hasn't arrived: %s",
+			Data:   data,
+
+			BodyArgs: []interface{}{settlement.Amount, settlement.Currency, reason},
+		})
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrSettlementStateChanged) {
+			return nil, ErrSettlementStateChanged
+		}
+		return nil, err
+	}
+
+	return rejected, nil
+}
+
+// applySettlement marks the settlement completed and moves the balances. It runs inside the
+// transaction of a transition, and fails with ErrSettlementStateChanged unless the settlement is
+// still in one of the from statuses, so a step racing another one can't complete it.
+func (s *SettlementService) applySettlement(ctx context.Context, out *OutboxWriter, settlement *models.Settlement, from []models.SettlementStatus, transactionID *string, autoCompleted bool) error {
+	settlementID := settlement.SettlementID
+
+	// Mark settlement as completed
+	if err := s.settlementRepo.MarkCompleted(ctx, settlementID, from, transactionID, autoCompleted); err != nil {
 		return err
 	}
 
-	// Only the person who owes money can mark it as complete
-	if settlement.FromUserID != userID {
-		return fmt.Errorf("only the payer can complete the settlement")
+	// Update balances: from_user pays to_user
+	// from_user's balance increases (they owe less)
+	if err := s.balanceRepo.UpdateBalance(ctx, settlement.FromUserID, settlement.GroupID, settlement.Currency, settlement.Amount); err != nil {
+		return err
+	}
+
+	// to_user's balance decreases (they are owed less)
+	if err := s.balanceRepo.UpdateBalance(ctx, settlement.ToUserID, settlement.GroupID, settlement.Currency, -settlement.Amount); err != nil {
+		return err
 	}
 
-	if settlement.Status != models.SettlementPending {
-		return ErrSettlementCompleted
+	// Outside groups, the payment also settles what the pair owes each other
+	if settlement.GroupID == nil {
+		if err := s.balanceRepo.UpdateDirectBalance(ctx, settlement.ToUserID, settlement.FromUserID, settlement.Currency, settlement.Amount); err != nil {
+			return err
+		}
+	}
+
+	// Record balance history
+	now := time.Now()
+	fromHistory := &models.BalanceHistory{
+		UserID:      settlement.FromUserID,
+		GroupID:     settlement.GroupID,
+		Amount:      settlement.Amount,
+		Currency:    settlement.Currency,
+		Type:        models.BalanceChangeSettlement,
+		ReferenceID: settlementID,
+		Description: fmt.Sprintf("Settlement payment to user"),
+		CreatedAt:   now,
+	}
+	if err := s.balanceRepo.CreateBalanceHistory(ctx, fromHistory); err != nil {
+		return err
 	}
 
-	// Start a transaction to update both settlement and balances
+	toHistory := &models.BalanceHistory{
+		UserID:      settlement.ToUserID,
+		GroupID:     settlement.GroupID,
+		Amount:      -settlement.Amount,
+		Currency:    settlement.Currency,
+		Type:        models.BalanceChangeSettlement,
+		ReferenceID: settlementID,
+		Description: fmt.Sprintf("Settlement received from user"),
+		CreatedAt:   now,
+	}
+	if err := s.balanceRepo.CreateBalanceHistory(ctx, toHistory); err != nil {
+		return err
+	}
+
+	publishEvent(ctx, out, s.groupRepo, Event{
+		Type:    EventBalancesChanged,
+		GroupID: settlement.GroupID,
+		Data:    map[string]interface{}{"settlement_id": settlementID},
+	}, settlement.FromUserID, settlement.ToUserID)
+
+	return nil
+}
+
+// transition runs change, then reloads the settlement and publishes its new state, in one
+// transaction. change records its notifications with out, so they commit with it. Once a
+// settlement completes, the cached balance summaries of both parties are evicted.
+func (s *SettlementService) transition(ctx context.Context, settlementID string, change func(ctx context.Context, out *OutboxWriter) error) (*models.Settlement, error) {
 	session, err := s.settlementRepo.StartSession()
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer session.EndSession(ctx)
 
-	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
-		// Mark settlement as completed
-		if err := s.settlementRepo.MarkCompleted(sessCtx, settlementID, transactionID); err != nil {
+	result, err := session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		out := s.outbox.Writer(sessCtx)
+		if err := change(sessCtx, out); err != nil {
 			return nil, err
 		}
-
-		// Update balances: from_user pays to_user
-		// from_user's balance increases (they owe less)
-		if err := s.balanceRepo.UpdateBalance(sessCtx, settlement.FromUserID, settlement.GroupID, settlement.Amount); err != nil {
+		settlement, err := s.settlementRepo.GetByID(sessCtx, settlementID)
+		if err != nil {
 			return nil, err
 		}
+		s.publishSettlement(sessCtx, out, settlement)
+		return settlement, out.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	s.outbox.Wake()
 
-		// to_user's balance decreases (they are owed less)
-		if err := s.balanceRepo.UpdateBalance(sessCtx, settlement.ToUserID, settlement.GroupID, -settlement.Amount); err != nil {
-			return nil, err
+	settlement := result.(*models.Settlement)
+	if settlement.Status == models.SettlementCompleted {
+		s.aggregates.EvictBalances(ctx, settlement.FromUserID, settlement.ToUserID)
+	}
+	return settlement, nil
+}
+
+func (s *SettlementService) getSettlement(ctx context.Context, settlementID string) (*models.Settlement, error) {
+	settlement, err := s.settlementRepo.GetByID(ctx, settlementID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrSettlementNotFound) {
+			return nil, ErrSettlementNotFound
 		}
+		return nil, err
+	}
+	return settlement, nil
+}
+
+// getSettlementFor loads a settlement for one of its parties; anyone else gets
+// ErrSettlementNotFound so settlement IDs can't be probed.
+func (s *SettlementService) getSettlementFor(ctx context.Context, settlementID string, userID string) (*models.Settlement, error) {
+	settlement, err := s.getSettlement(ctx, settlementID)
+	if err != nil {
+		return nil, err
+	}
+
+	if settlement.FromUserID != userID && settlement.ToUserID != userID {
+		return nil, ErrSettlementNotFound
+	}
+
+	return settlement, nil
+}
 
-		// Record balance history
-		now := time.Now()
-		fromHistory := &models.BalanceHistory{
-			UserID:      settlement.FromUserID,
-			GroupID:     settlement.GroupID,
-			Amount:      settlement.Amount,
-			Currency:    settlement.Currency,
-			Type:        models.BalanceChangeSettlement,
-			ReferenceID: settlementID,
-			Description: fmt.Sprintf("Settlement payment to user"),
-			CreatedAt:   now,
+// resolveStateChange handles a lost transition race: when a concurrent request already moved
+// the settlement into one of the expected statuses, the current settlement is returned.
+func (s *SettlementService) resolveStateChange(ctx context.Context, settlementID string, expected ...models.SettlementStatus) (*models.Settlement, error) {
+	current, err := s.getSettlement(ctx, settlementID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range expected {
+		if current.Status == status {
+			return current, nil
 		}
-		if err := s.balanceRepo.CreateBalanceHistory(sessCtx, fromHistory); err != nil {
-			return nil, err
+	}
+
+	return nil, ErrSettlementStateChanged
+}
+
+// CancelSettlement lets either party call off a settlement before it is marked as paid.
+// Cancelling an already cancelled settlement returns it unchanged.
+func (s *SettlementService) CancelSettlement(ctx context.Context, settlementID string, userID string) (*models.Settlement, error) {
+	settlement, err := s.getSettlementFor(ctx, settlementID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch settlement.Status {
+	case models.SettlementPending:
+	case models.SettlementCancelled:
+		return settlement, nil
+	case models.SettlementCompleted:
+		return nil, ErrSettlementCompleted
+	default:
+		return nil, ErrSettlementNotCancellable
+	}
+
+	cancelled, err := s.transition(ctx, settlementID, func(ctx context.Context, out *OutboxWriter) error {
+		return s.settlementRepo.MarkCancelled(ctx, settlementID)
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrSettlementStateChanged) {
+			return s.resolveStateChange(ctx, settlementID, models.SettlementCancelled)
 		}
+		return nil, err
+	}
+
+	return cancelled, nil
+}
 
-		toHistory := &models.BalanceHistory{
-			UserID:      settlement.ToUserID,
-			GroupID:     settlement.GroupID,
-			Amount:      -settlement.Amount,
-			Currency:    settlement.Currency,
-			Type:        models.BalanceChangeSettlement,
-			ReferenceID: settlementID,
-			Description: fmt.Sprintf("Settlement received from user"),
-			CreatedAt:   now,
+// VoidSettlement withdraws a settlement recorded by mistake. Either party, or an admin of the
+// settlement's group, can void it until it completes; no balance has moved by then. Voiding an
+// already voided settlement returns it unchanged.
+func (s *SettlementService) VoidSettlement(ctx context.Context, settlementID string, userID string, reason string) (*models.Settlement, error) {
+	settlement, err := s.getSettlement(ctx, settlementID)
+	if err != nil {
+		return nil, err
+	}
+	if settlement.FromUserID != userID && settlement.ToUserID != userID {
+		if settlement.GroupID == nil {
+			return nil, ErrSettlementNotFound
 		}
-		if err := s.balanceRepo.CreateBalanceHistory(sessCtx, toHistory); err != nil {
-			return nil, err
+		if _, err := requireGroupAdmin(ctx, s.groupRepo, *settlement.GroupID, userID); err != nil {
+			return nil, ErrSettlementNotFound
 		}
+	}
 
-		return nil, nil
+	switch settlement.Status {
+	case models.SettlementPending, models.SettlementAwaitingConfirmation:
+	case models.SettlementVoided:
+		return settlement, nil
+	case models.SettlementCompleted:
+		return nil, ErrSettlementCompleted
+	default:
+		return nil, ErrSettlementNotVoidable
+	}
+
+	voided, err := s.transition(ctx, settlementID, func(ctx context.Context, out *OutboxWriter) error {
+		if err := s.settlementRepo.MarkVoided(ctx, settlementID, userID, reason); err != nil {
+			return err
+		}
+		for _, partyID := range []string{settlement.FromUserID, settlement.ToUserID} {
+			if partyID == userID {
+				continue
+			}
+			deliver(ctx, out, Notification{
+				UserID: partyID,
+				Type:   models.NotificationSettlementVoided,
+				Title:  "Settlement voided",
+				Body:   "The payment of %.2f %s was voided: %s",
+				Data:   settlementNotificationData(settlement),
+
+				BodyArgs: []interface{}{settlement.Amount, settlement.Currency, reason},
+			})
+		}
+		return nil
 	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrSettlementStateChanged) {
+			return s.resolveStateChange(ctx, settlementID, models.SettlementVoided)
+		}
+		return nil, err
+	}
 
-	return err
+	return voided, nil
 }
 
-func (s *SettlementService) CancelSettlement(ctx context.Context, settlementID string, userID string) error {
-	settlement, err := s.settlementRepo.GetByID(ctx, settlementID)
+// ForceCancelSettlement is the back office's way out for a settlement stuck before completion:
+// an admin can cancel it whatever its other status and whoever its parties are. Both parties are
+// told why. Completed settlements moved balances and have to be undone with a new settlement.
+func (s *SettlementService) ForceCancelSettlement(ctx context.Context, settlementID string, adminID string, reason string) (*models.Settlement, error) {
+	settlement, err := s.getSettlement(ctx, settlementID)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// Only involved parties can cancel
-	if settlement.FromUserID != userID && settlement.ToUserID != userID {
-		return ErrSettlementNotFound
+	switch settlement.Status {
+	case models.SettlementPending, models.SettlementAwaitingConfirmation, models.SettlementFailed:
+	case models.SettlementCancelled:
+		return settlement, nil
+	case models.SettlementCompleted:
+		return nil, ErrSettlementCompleted
+	default:
+		return nil, ErrSettlementNotCancellable
 	}
 
-	if settlement.Status != models.SettlementPending {
-		return fmt.Errorf("can only cancel pending settlements")
+	cancelled, err := s.transition(ctx, settlementID, func(ctx context.Context, out *OutboxWriter) error {
+		if err := s.settlementRepo.MarkForceCancelled(ctx, settlementID, adminID, reason); err != nil {
+			return err
+		}
+		for _, partyID := range []string{settlement.FromUserID, settlement.ToUserID} {
+			deliver(ctx, out, Notification{
+				UserID: partyID,
+				Type:   models.NotificationSettlementCancelled,
+				Title:  "Settlement cancelled",
+				Body:   "The payment of %.2f %s was cancelled by support: %s",
+				Data:   settlementNotificationData(settlement),
+
+				BodyArgs: []interface{}{settlement.Amount, settlement.Currency, reason},
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, repositories.ErrSettlementStateChanged) {
+			return s.resolveStateChange(ctx, settlementID, models.SettlementCancelled)
+		}
+		return nil, err
 	}
 
-	return s.settlementRepo.MarkCancelled(ctx, settlementID)
+	return cancelled, nil
+}
+
+// PurgeVoided deletes the settlements voided longer ago than retention
+func (s *SettlementService) PurgeVoided(ctx context.Context, retention time.Duration) (int64, error) {
+	return s.settlementRepo.DeleteVoidedBefore(ctx, time.Now().Add(-retention))
 }
 
 func (s *SettlementService) GetPendingSettlements(ctx context.Context, userID string) ([]*models.Settlement, error) {
 	return s.settlementRepo.GetPendingSettlements(ctx, userID)
 }
+
+// AuthorizePayer lets the payee pre-authorize a trusted payer: settlements from that payer
+// using one of the given methods complete as soon as the payer marks them paid.
+func (s *SettlementService) AuthorizePayer(ctx context.Context, payeeID string, payerID string, methods []models.SettlementMethod) (*models.SettlementAuthorization, error) {
+	if payeeID == payerID {
+		return nil, ErrSettlementAuthorizationSelf
+	}
+
+	for _, method := range methods {
+		if !method.IsValid() {
+			return nil, ErrInvalidSettlementMethod
+		}
+	}
+
+	exists, err := s.userRepo.Exists(ctx, payerID)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, ErrUserNotFound
+	}
+
+	return s.authorizationRepo.Upsert(ctx, &models.SettlementAuthorization{
+		PayeeID: payeeID,
+		PayerID: payerID,
+		Methods: methods,
+	})
+}
+
+func (s *SettlementService) RevokePayerAuthorization(ctx context.Context, payeeID string, payerID string) error {
+	return s.authorizationRepo.Delete(ctx, payeeID, payerID)
+}
+
+func (s *SettlementService) GetPayerAuthorizations(ctx context.Context, payeeID string) ([]*models.SettlementAuthorization, error) {
+	authorizations, err := s.authorizationRepo.GetByPayeeID(ctx, payeeID)
+	if err != nil {
+		return nil, err
+	}
+	if authorizations == nil {
+		authorizations = []*models.SettlementAuthorization{}
+	}
+	return authorizations, nil
+}
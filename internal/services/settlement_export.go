@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+)
+
+var ErrInvalidExportYear = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidExportYear, "year must be a past or current year")
+
+// SettlementExport is a user's yearly record of completed settlements, with counterpart and
+// group names resolved so it can be handed to an accountant as is
+type SettlementExport struct {
+	UserID string
+	Year   int
+
+	settlements []*models.Settlement
+	userNames   map[string]string
+	groupNames  map[string]string
+}
+
+// PrepareYearlySettlementExport collects the settlements the user paid or received that completed
+// during the calendar year (UTC)
+func (s *SettlementService) PrepareYearlySettlementExport(ctx context.Context, userID string, year int) (*SettlementExport, error) {
+	if year < 2000 || year > time.Now().UTC().Year() {
+		return nil, ErrInvalidExportYear
+	}
+
+	from := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	settlements, err := s.settlementRepo.GetCompletedInPeriod(ctx, nil, &userID, from, from.AddDate(1, 0, 0))
+	if err != nil {
+		return nil, err
+	}
+
+	export := &SettlementExport{
+		UserID:      userID,
+		Year:        year,
+		settlements: settlements,
+		userNames:   make(map[string]string),
+		groupNames:  make(map[string]string),
+	}
+
+	var counterpartIDs []string
+	for _, settlement := range settlements {
+		counterpart := settlementCounterpart(settlement, userID)
+		if _, seen := export.userNames[counterpart]; !seen {
+			export.userNames[counterpart] = counterpart
+			counterpartIDs = append(counterpartIDs, counterpart)
+		}
+
+		if settlement.GroupID != nil {
+			if _, seen := export.groupNames[*settlement.GroupID]; seen {
+				continue
+			}
+			export.groupNames[*settlement.GroupID] = *settlement.GroupID
+			group, err := s.groupRepo.GetByID(ctx, *settlement.GroupID)
+			if err != nil && !errors.Is(err, repositories.ErrGroupNotFound) {
+				return nil, err
+			}
+			if group != nil {
+				export.groupNames[group.GroupID] = group.Name
+			}
+		}
+	}
+
+	if len(counterpartIDs) > 0 {
+		users, err := s.userRepo.GetByIDs(ctx, counterpartIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range users {
+			export.userNames[user.UserID] = user.Name
+		}
+	}
+
+	return export, nil
+}
+
+// WriteCSV writes one row per settlement, oldest first. Amounts are positive; direction tells
+// whether the user paid or received them.
+func (e *SettlementExport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"completed_at", "settlement_id", "direction", "counterpart", "group", "amount", "currency", "method", "transaction_id", "description"}
+	if err := writer.Write(header); err != nil {
+		return err
+	}
+
+	for _, settlement := range e.settlements {
+		direction := "paid"
+		if settlement.ToUserID == e.UserID {
+			direction = "received"
+		}
+
+		var completedAt, group, transactionID string
+		if settlement.CompletedAt != nil {
+			completedAt = settlement.CompletedAt.UTC().Format(time.RFC3339)
+		}
+		if settlement.GroupID != nil {
+			group = e.groupNames[*settlement.GroupID]
+		}
+		if settlement.TransactionID != nil {
+			transactionID = *settlement.TransactionID
+		}
+
+		if err := writer.Write([]string{
+			completedAt,
+			settlement.SettlementID,
+			direction,
+			e.userNames[settlementCounterpart(settlement, e.UserID)],
+			group,
+			formatAmount(settlement.Amount),
+			settlement.Currency,
+			string(settlement.Method),
+			transactionID,
+			settlement.Description,
+		}); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
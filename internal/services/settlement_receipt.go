@@ -0,0 +1,59 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+)
+
+var ErrSettlementNotCompleted = utils.NewCustomError(http.StatusConflict, utils.CodeSettlementNotCompleted, "a receipt is only available once the settlement is completed")
+
+// Receipt returns the receipt of a completed settlement to either of its parties. Completed
+// settlements don't change, so the receipt is built when asked for rather than stored.
+func (s *SettlementService) Receipt(ctx context.Context, settlementID string, userID string) (*models.SettlementReceipt, error) {
+	settlement, err := s.getSettlementFor(ctx, settlementID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if settlement.Status != models.SettlementCompleted {
+		return nil, ErrSettlementNotCompleted
+	}
+
+	receipt := &models.SettlementReceipt{
+		Settlement:  settlement,
+		PayerName:   settlement.FromUserID,
+		PayeeName:   settlement.ToUserID,
+		GeneratedAt: time.Now(),
+	}
+
+	users, err := s.userRepo.GetByIDs(ctx, []string{settlement.FromUserID, settlement.ToUserID})
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		switch user.UserID {
+		case settlement.FromUserID:
+			receipt.PayerName = user.Name
+		case settlement.ToUserID:
+			receipt.PayeeName = user.Name
+		}
+	}
+
+	if settlement.GroupID != nil {
+		receipt.GroupName = *settlement.GroupID
+		group, err := s.groupRepo.GetByID(ctx, *settlement.GroupID)
+		if err != nil && !errors.Is(err, repositories.ErrGroupNotFound) {
+			return nil, err
+		}
+		if group != nil {
+			receipt.GroupName = group.Name
+		}
+	}
+
+	return receipt, nil
+}
@@ -0,0 +1,78 @@
+package services
+
+import (
+	"fmt"
+	"io"
+
+	"divvydoo/backend/internal/models"
+
+	"github.com/go-pdf/fpdf"
+)
+
+// RenderSettlementReceiptPDF writes the receipt as a one-page A4 PDF
+func RenderSettlementReceiptPDF(receipt *models.SettlementReceipt, w io.Writer) error {
+	settlement := receipt.Settlement
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+
+	// Core fonts are cp1252; translate names and descriptions from UTF-8
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 9, "Settlement receipt", "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 6, fmt.Sprintf("Receipt no. %s", settlement.SettlementID), "", 1, "L", false, 0, "")
+	pdf.SetTextColor(110, 110, 110)
+	pdf.CellFormat(0, 6, fmt.Sprintf("Generated %s UTC", receipt.GeneratedAt.UTC().Format("2006-01-02 15:04")), "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.Ln(6)
+
+	pdf.SetFont("Helvetica", "B", 20)
+	pdf.CellFormat(0, 12, fmt.Sprintf("%s %s", formatAmount(settlement.Amount), settlement.Currency), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 6, "Paid in full", "", 1, "L", false, 0, "")
+	pdf.Ln(6)
+
+	pdfSectionTitle(pdf, "Details")
+	completed := "-"
+	if settlement.CompletedAt != nil {
+		completed = settlement.CompletedAt.UTC().Format("2006-01-02 15:04:05") + " UTC"
+	}
+	confirmation := "Confirmed by the payee"
+	if settlement.AutoCompleted {
+		confirmation = "Completed automatically"
+	}
+	transactionID := "-"
+	if settlement.TransactionID != nil && *settlement.TransactionID != "" {
+		transactionID = *settlement.TransactionID
+	}
+
+	rows := [][2]string{
+		{"Paid by", tr(receipt.PayerName)},
+		{"Paid to", tr(receipt.PayeeName)},
+		{"Amount", fmt.Sprintf("%s %s", formatAmount(settlement.Amount), settlement.Currency)},
+		{"Method", string(settlement.Method)},
+		{"Transaction ID", transactionID},
+		{"Completed", completed},
+		{"Confirmation", confirmation},
+	}
+	if receipt.GroupName != "" {
+		rows = append(rows, [2]string{"Group", tr(receipt.GroupName)})
+	}
+	if settlement.Description != "" {
+		rows = append(rows, [2]string{"Description", tr(settlement.Description)})
+	}
+
+	for _, row := range rows {
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(45, 7, row[0], "B", 0, "L", false, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(135, 7, pdfFit(pdf, row[1], 133), "B", 1, "L", false, 0, "")
+	}
+
+	return pdf.Output(w)
+}
@@ -0,0 +1,326 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrShareLinkNotFound    = utils.NewCustomError(http.StatusNotFound, utils.CodeShareLinkNotFound, "share link not found")
+	ErrInvalidShareLinkTTL  = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidShareLinkTTL, fmt.Sprintf("expires_in_hours must be between 1 and %d", maxShareLinkHours))
+	ErrShareLinkUnavailable = utils.NewCustomError(http.StatusNotFound, utils.CodeShareLinkUnavailable, "this link has expired or been revoked")
+)
+
+const (
+	defaultShareLinkHours = 7 * 24
+	maxShareLinkHours     = 30 * 24
+	shareTokenBytes       = 32
+)
+
+// CreateShareLinkRequest sets how long a share link works; 0 uses the default of a week
+type CreateShareLinkRequest struct {
+	ExpiresInHours int `json:"expires_in_hours,omitempty"`
+}
+
+// ShareLinkService issues the tokens behind public, read-only group summaries, for sharing a
+// group's state with people outside it such as a landlord or a tour organizer
+type ShareLinkService struct {
+	shareLinkRepo repositories.ShareLinkRepository
+	groupRepo     repositories.GroupRepository
+	balanceRepo   repositories.BalanceRepository
+	expenseRepo   repositories.ExpenseRepository
+	summaries     *aggregationFallback[*models.PublicGroupSummary]
+}
+
+func NewShareLinkService(
+	shareLinkRepo repositories.ShareLinkRepository,
+	groupRepo repositories.GroupRepository,
+	balanceRepo repositories.BalanceRepository,
+	expenseRepo repositories.ExpenseRepository,
+	aggregationBudget AggregationBudget,
+) *ShareLinkService {
+	return &ShareLinkService{
+		shareLinkRepo: shareLinkRepo,
+		groupRepo:     groupRepo,
+		balanceRepo:   balanceRepo,
+		expenseRepo:   expenseRepo,
+		summaries:     newAggregationFallback[*models.PublicGroupSummary]("public_group_summary", aggregationBudget),
+	}
+}
+
+// CreateShareLink issues a new link for the group. The returned link carries the token, which
+// can't be retrieved again.
+func (s *ShareLinkService) CreateShareLink(ctx context.Context, groupID string, userID string, req CreateShareLinkRequest) (*models.GroupShareLink, error) {
+	hours := req.ExpiresInHours
+	if hours == 0 {
+		hours = defaultShareLinkHours
+	}
+	if hours < 1 || hours > maxShareLinkHours {
+		return nil, ErrInvalidShareLinkTTL
+	}
+
+	if _, err := requireGroupAdmin(ctx, s.groupRepo, groupID, userID); err != nil {
+		return nil, err
+	}
+
+	raw := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate token: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	link := &models.GroupShareLink{
+		LinkID:    uuid.New().String(),
+		GroupID:   groupID,
+		TokenHash: hashShareToken(token),
+		CreatedBy: userID,
+		CreatedAt: now,
+		ExpiresAt: now.Add(time.Duration(hours) * time.Hour),
+	}
+	if err := s.shareLinkRepo.Create(ctx, link); err != nil {
+		return nil, err
+	}
+
+	link.Token = token
+	return link, nil
+}
+
+// ListShareLinks returns the group's links that still work, without their tokens
+func (s *ShareLinkService) ListShareLinks(ctx context.Context, groupID string, userID string) ([]*models.GroupShareLink, error) {
+	if _, err := requireGroupAdmin(ctx, s.groupRepo, groupID, userID); err != nil {
+		return nil, err
+	}
+	return s.shareLinkRepo.ListActiveByGroupID(ctx, groupID, time.Now())
+}
+
+// RevokeShareLink stops a link from working
+func (s *ShareLinkService) RevokeShareLink(ctx context.Context, groupID string, userID string, linkID string) error {
+	if _, err := requireGroupAdmin(ctx, s.groupRepo, groupID, userID); err != nil {
+		return err
+	}
+	if err := s.shareLinkRepo.Delete(ctx, groupID, linkID); err != nil {
+		if errors.Is(err, repositories.ErrShareLinkNotFound) {
+			return ErrShareLinkNotFound
+		}
+		return err
+	}
+	return nil
+}
+
+// GetPublicSummary returns the summary of the group a token was issued for. Unknown, expired and
+// revoked tokens all get ErrShareLinkUnavailable.
+func (s *ShareLinkService) GetPublicSummary(ctx context.Context, token string) (*models.PublicGroupSummary, error) {
+	link, err := s.shareLinkRepo.GetByTokenHash(ctx, hashShareToken(token))
+	if err != nil {
+		if errors.Is(err, repositories.ErrShareLinkNotFound) {
+			return nil, ErrShareLinkUnavailable
+		}
+		return nil, err
+	}
+	now := time.Now()
+	if !now.Before(link.ExpiresAt) {
+		return nil, ErrShareLinkUnavailable
+	}
+
+	// Every link of the group shares the stored summary; only the expiry differs
+	summary, stale, err := s.summaries.get(ctx, link.GroupID, func(ctx context.Context) (*models.PublicGroupSummary, error) {
+		return s.publicSummary(ctx, link.GroupID, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+	linkSummary := *summary
+	linkSummary.ExpiresAt = link.ExpiresAt
+	linkSummary.Stale = stale
+	return &linkSummary, nil
+}
+
+// publicSummary adds up the group's spending and balances as of now
+func (s *ShareLinkService) publicSummary(ctx context.Context, groupID string, now time.Time) (*models.PublicGroupSummary, error) {
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrGroupNotFound) {
+			return nil, ErrShareLinkUnavailable
+		}
+		return nil, err
+	}
+
+	members, err := s.groupRepo.GetMembersWithDetails(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(members))
+	memberCount := 0
+	for _, member := range members {
+		names[member.UserID] = member.Name
+		if member.IsActive {
+			memberCount++
+		}
+	}
+
+	expenseCount, err := s.expenseRepo.CountByGroupID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	// Without a lower bound the period covers the group's whole history
+	categoryTotals, err := s.expenseRepo.SumByCategoryInPeriod(ctx, groupID, time.Time{}, now)
+	if err != nil {
+		return nil, err
+	}
+	balances, err := s.balanceRepo.GetByGroupID(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	summary := &models.PublicGroupSummary{
+		GroupName:    group.Name,
+		Currency:     group.Currency,
+		MemberCount:  memberCount,
+		ExpenseCount: expenseCount,
+		Totals:       spentPerCurrency(categoryTotals),
+		Balances:     []models.PublicMemberBalance{},
+		Transfers:    []models.PublicTransfer{},
+		GeneratedAt:  now,
+	}
+
+	nameOf := func(userID string) string {
+		if name := names[userID]; name != "" {
+			return name
+		}
+		return "Former member"
+	}
+	for _, balance := range balances {
+		if math.Round(balance.Balance*100) == 0 {
+			continue
+		}
+		summary.Balances = append(summary.Balances, models.PublicMemberBalance{
+			Name:     nameOf(balance.UserID),
+			Balance:  roundCents(balance.Balance),
+			Currency: balance.Currency,
+		})
+	}
+	sort.SliceStable(summary.Balances, func(i, j int) bool {
+		a, b := summary.Balances[i], summary.Balances[j]
+		if a.Currency != b.Currency {
+			return a.Currency < b.Currency
+		}
+		return a.Balance > b.Balance
+	})
+
+	for _, payment := range settleUpPayments(balances) {
+		summary.Transfers = append(summary.Transfers, models.PublicTransfer{
+			From:     nameOf(payment.FromUserID),
+			To:       nameOf(payment.ToUserID),
+			Amount:   payment.Amount,
+			Currency: payment.Currency,
+		})
+	}
+	return summary, nil
+}
+
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// spentPerCurrency adds up category totals per currency
+func spentPerCurrency(totals []repositories.CategoryTotal) []models.PublicCurrencyTotal {
+	spent := make(map[string]float64)
+	for _, total := range totals {
+		spent[total.Currency] += total.Total
+	}
+
+	result := make([]models.PublicCurrencyTotal, 0, len(spent))
+	for currency, amount := range spent {
+		result = append(result, models.PublicCurrencyTotal{Currency: currency, Spent: roundCents(amount)})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Currency < result[j].Currency })
+	return result
+}
+
+// settleUpPayments works out, per currency, payments that would settle everyone's balance:
+// the largest debtor repeatedly pays the largest creditor, which needs at most one payment fewer
+// than there are members with a balance. Amounts are matched in whole cents.
+func settleUpPayments(balances []*models.Balance) []models.SettleUpPayment {
+	type party struct {
+		userID string
+		cents  int64
+	}
+
+	byCurrency := make(map[string][]party)
+	var currencies []string
+	for _, balance := range balances {
+		cents := int64(math.Round(balance.Balance * 100))
+		if cents == 0 {
+			continue
+		}
+		if _, ok := byCurrency[balance.Currency]; !ok {
+			currencies = append(currencies, balance.Currency)
+		}
+		byCurrency[balance.Currency] = append(byCurrency[balance.Currency], party{userID: balance.UserID, cents: cents})
+	}
+	sort.Strings(currencies)
+
+	payments := []models.SettleUpPayment{}
+	for _, currency := range currencies {
+		var debtors, creditors []party
+		for _, p := range byCurrency[currency] {
+			if p.cents < 0 {
+				debtors = append(debtors, party{userID: p.userID, cents: -p.cents})
+			} else {
+				creditors = append(creditors, p)
+			}
+		}
+		largestFirst := func(parties []party) {
+			sort.Slice(parties, func(i, j int) bool {
+				if parties[i].cents != parties[j].cents {
+					return parties[i].cents > parties[j].cents
+				}
+				return parties[i].userID < parties[j].userID
+			})
+		}
+		largestFirst(debtors)
+		largestFirst(creditors)
+
+		for len(debtors) > 0 && len(creditors) > 0 {
+			amount := debtors[0].cents
+			if creditors[0].cents < amount {
+				amount = creditors[0].cents
+			}
+			payments = append(payments, models.SettleUpPayment{
+				FromUserID: debtors[0].userID,
+				ToUserID:   creditors[0].userID,
+				Amount:     float64(amount) / 100,
+				Currency:   currency,
+			})
+
+			debtors[0].cents -= amount
+			creditors[0].cents -= amount
+			if debtors[0].cents == 0 {
+				debtors = debtors[1:]
+			}
+			if creditors[0].cents == 0 {
+				creditors = creditors[1:]
+			}
+			largestFirst(debtors)
+			largestFirst(creditors)
+		}
+	}
+	return payments
+}
@@ -0,0 +1,284 @@
+package services
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// splitwiseExport is a Splitwise export reduced to what the importer needs,
+// whichever format it was read from.
+type splitwiseExport struct {
+	GroupName string
+	Members   []splitwiseMember
+	Entries   []splitwiseEntry
+}
+
+type splitwiseMember struct {
+	Name  string
+	Email string
+}
+
+// splitwiseEntry is one expense or payment; shares are indexed like Members
+type splitwiseEntry struct {
+	Row         int
+	Date        time.Time
+	Description string
+	Details     string
+	Category    string
+	Cost        float64
+	Currency    string
+	Payment     bool
+	Shares      []splitwiseShare
+}
+
+type splitwiseShare struct {
+	Member int
+	Paid   float64
+	Owed   float64
+}
+
+const splitwisePaymentCategory = "Payment"
+
+// parseSplitwiseCSV reads the per-group CSV export: Date, Description, Category, Cost and
+// Currency columns followed by one column per member holding that member's net change.
+// The CSV only has net amounts, so paid and owed shares are reconstructed: members with a
+// positive net paid the cost and split whatever part of it others don't owe in proportion.
+func parseSplitwiseCSV(r io.Reader) (*splitwiseExport, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: missing CSV header", ErrInvalidImport)
+	}
+	if len(header) < 6 || !strings.EqualFold(strings.TrimPrefix(header[0], "\ufeff"), "Date") ||
+		!strings.EqualFold(header[3], "Cost") || !strings.EqualFold(header[4], "Currency") {
+		return nil, fmt.Errorf("%w: expected Date, Description, Category, Cost, Currency and member columns", ErrInvalidImport)
+	}
+
+	export := &splitwiseExport{}
+	for _, name := range header[5:] {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, fmt.Errorf("%w: empty member column name", ErrInvalidImport)
+		}
+		export.Members = append(export.Members, splitwiseMember{Name: name})
+	}
+
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: row %d: %v", ErrInvalidImport, row, err)
+		}
+		if isBlankRecord(record) {
+			continue
+		}
+		// The export ends with a "Total balance" summary row
+		if strings.TrimSpace(record[0]) == "" && strings.EqualFold(strings.TrimSpace(record[1]), "Total balance") {
+			continue
+		}
+		if len(record) != len(header) {
+			return nil, fmt.Errorf("%w: row %d has %d columns, expected %d", ErrInvalidImport, row, len(record), len(header))
+		}
+
+		entry, err := parseSplitwiseCSVRow(record, len(export.Members))
+		if err != nil {
+			return nil, fmt.Errorf("%w: row %d: %v", ErrInvalidImport, row, err)
+		}
+		entry.Row = row
+		export.Entries = append(export.Entries, entry)
+	}
+
+	return export, nil
+}
+
+func parseSplitwiseCSVRow(record []string, memberCount int) (splitwiseEntry, error) {
+	entry := splitwiseEntry{
+		Description: strings.TrimSpace(record[1]),
+		Category:    strings.TrimSpace(record[2]),
+		Currency:    strings.ToUpper(strings.TrimSpace(record[4])),
+	}
+	entry.Payment = strings.EqualFold(entry.Category, splitwisePaymentCategory)
+
+	var err error
+	if entry.Date, err = parseImportDate(record[0]); err != nil {
+		return entry, err
+	}
+	if entry.Cost, err = parseImportAmount(record[3]); err != nil {
+		return entry, fmt.Errorf("invalid cost: %v", err)
+	}
+
+	nets := make([]float64, memberCount)
+	positive := 0.0
+	for i := range nets {
+		if nets[i], err = parseImportAmount(record[5+i]); err != nil {
+			return entry, fmt.Errorf("invalid amount for member %d: %v", i+1, err)
+		}
+		if nets[i] > 0 {
+			positive += nets[i]
+		}
+	}
+	if positive == 0 {
+		return entry, errors.New("no member paid anything")
+	}
+
+	// Whatever part of the cost isn't owed by others was the payers' own share
+	ownShare := math.Max(entry.Cost-positive, 0)
+	for i, net := range nets {
+		switch {
+		case net > 0:
+			share := ownShare * net / positive
+			entry.Shares = append(entry.Shares, splitwiseShare{Member: i, Paid: net + share, Owed: share})
+		case net < 0:
+			entry.Shares = append(entry.Shares, splitwiseShare{Member: i, Owed: -net})
+		}
+	}
+
+	return entry, nil
+}
+
+// splitwiseJSON mirrors the group and expense objects of the Splitwise API, which is
+// what JSON exports contain. Amounts are decimal strings.
+type splitwiseJSON struct {
+	Group struct {
+		Name    string              `json:"name"`
+		Members []splitwiseJSONUser `json:"members"`
+	} `json:"group"`
+	Expenses []struct {
+		Description  string                 `json:"description"`
+		Details      *string                `json:"details"`
+		Cost         json.Number            `json:"cost"`
+		CurrencyCode string                 `json:"currency_code"`
+		Date         string                 `json:"date"`
+		Payment      bool                   `json:"payment"`
+		DeletedAt    *string                `json:"deleted_at"`
+		Category     *struct{ Name string } `json:"category"`
+		Users        []struct {
+			UserID    int64       `json:"user_id"`
+			PaidShare json.Number `json:"paid_share"`
+			OwedShare json.Number `json:"owed_share"`
+		} `json:"users"`
+	} `json:"expenses"`
+}
+
+type splitwiseJSONUser struct {
+	ID        int64  `json:"id"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Email     string `json:"email"`
+}
+
+func parseSplitwiseJSON(r io.Reader) (*splitwiseExport, error) {
+	var doc splitwiseJSON
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+	if len(doc.Group.Members) == 0 {
+		return nil, fmt.Errorf("%w: group has no members", ErrInvalidImport)
+	}
+
+	export := &splitwiseExport{GroupName: strings.TrimSpace(doc.Group.Name)}
+	memberIndex := make(map[int64]int, len(doc.Group.Members))
+	for i, user := range doc.Group.Members {
+		memberIndex[user.ID] = i
+		export.Members = append(export.Members, splitwiseMember{
+			Name:  strings.TrimSpace(user.FirstName + " " + user.LastName),
+			Email: strings.TrimSpace(user.Email),
+		})
+	}
+
+	for i, expense := range doc.Expenses {
+		// Deleted expenses stay in the API response but no longer count
+		if expense.DeletedAt != nil {
+			continue
+		}
+
+		entry := splitwiseEntry{
+			Row:         i + 1,
+			Description: strings.TrimSpace(expense.Description),
+			Currency:    strings.ToUpper(strings.TrimSpace(expense.CurrencyCode)),
+			Payment:     expense.Payment,
+		}
+		if expense.Details != nil {
+			entry.Details = strings.TrimSpace(*expense.Details)
+		}
+		if expense.Category != nil {
+			entry.Category = strings.TrimSpace(expense.Category.Name)
+		}
+
+		var err error
+		if entry.Date, err = parseImportDate(expense.Date); err != nil {
+			return nil, fmt.Errorf("%w: expense %d: %v", ErrInvalidImport, entry.Row, err)
+		}
+		if entry.Cost, err = parseImportAmount(expense.Cost.String()); err != nil {
+			return nil, fmt.Errorf("%w: expense %d: invalid cost: %v", ErrInvalidImport, entry.Row, err)
+		}
+
+		for _, user := range expense.Users {
+			member, ok := memberIndex[user.UserID]
+			if !ok {
+				return nil, fmt.Errorf("%w: expense %d: user %d is not a group member", ErrInvalidImport, entry.Row, user.UserID)
+			}
+			share := splitwiseShare{Member: member}
+			if share.Paid, err = parseImportAmount(user.PaidShare.String()); err != nil {
+				return nil, fmt.Errorf("%w: expense %d: invalid paid_share: %v", ErrInvalidImport, entry.Row, err)
+			}
+			if share.Owed, err = parseImportAmount(user.OwedShare.String()); err != nil {
+				return nil, fmt.Errorf("%w: expense %d: invalid owed_share: %v", ErrInvalidImport, entry.Row, err)
+			}
+			if share.Paid != 0 || share.Owed != 0 {
+				entry.Shares = append(entry.Shares, share)
+			}
+		}
+
+		export.Entries = append(export.Entries, entry)
+	}
+
+	return export, nil
+}
+
+func parseImportDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse(time.DateOnly, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q", value)
+	}
+	return t, nil
+}
+
+func parseImportAmount(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return 0, fmt.Errorf("invalid amount %q", value)
+	}
+	return amount, nil
+}
+
+func isBlankRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
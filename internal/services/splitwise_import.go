@@ -0,0 +1,479 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	ErrInvalidImport           = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidImport, "invalid import file")
+	ErrUnsupportedImportFormat = utils.NewCustomError(http.StatusBadRequest, utils.CodeUnsupportedImportFormat, "unsupported import format")
+)
+
+// MaxSplitwiseImportEntries bounds an import so it fits in a single transaction
+const MaxSplitwiseImportEntries = 5000
+
+type ImportService struct {
+	userRepo       repositories.UserRepository
+	groupRepo      repositories.GroupRepository
+	expenseRepo    repositories.ExpenseRepository
+	settlementRepo repositories.SettlementRepository
+	balanceRepo    repositories.BalanceRepository
+	revisionRepo   repositories.ExpenseRevisionRepository
+}
+
+func NewImportService(
+	userRepo repositories.UserRepository,
+	groupRepo repositories.GroupRepository,
+	expenseRepo repositories.ExpenseRepository,
+	settlementRepo repositories.SettlementRepository,
+	balanceRepo repositories.BalanceRepository,
+	revisionRepo repositories.ExpenseRevisionRepository,
+) *ImportService {
+	return &ImportService{
+		userRepo:       userRepo,
+		groupRepo:      groupRepo,
+		expenseRepo:    expenseRepo,
+		settlementRepo: settlementRepo,
+		balanceRepo:    balanceRepo,
+		revisionRepo:   revisionRepo,
+	}
+}
+
+type SplitwiseImportRequest struct {
+	Format       string            // "csv" or "json"
+	GroupName    string            // required for CSV exports, which don't carry the group name
+	Currency     string            // group currency; defaults to the export's most used currency
+	MemberEmails map[string]string // member name -> email, to match members to existing accounts
+	DryRun       bool
+}
+
+// SplitwiseImportResult describes the imported group. For a dry run nothing is stored
+// and the IDs are the ones that would have been used.
+type SplitwiseImportResult struct {
+	DryRun   bool              `json:"dry_run"`
+	Group    *models.Group     `json:"group"`
+	Members  []ImportedMember  `json:"members"`
+	Expenses int               `json:"expenses"`
+	Payments int               `json:"payments"`
+	Balances []ImportedBalance `json:"balances"`
+}
+
+type ImportedMember struct {
+	Name        string `json:"name"`
+	Email       string `json:"email,omitempty"`
+	UserID      string `json:"user_id"`
+	Placeholder bool   `json:"placeholder"`
+}
+
+type ImportedBalance struct {
+	UserID   string  `json:"user_id"`
+	Name     string  `json:"name"`
+	Currency string  `json:"currency"`
+	Balance  float64 `json:"balance"`
+}
+
+// splitwiseImport collects everything an import writes so it can be stored in one transaction
+type splitwiseImport struct {
+	placeholders []*models.User
+	group        *models.Group
+	expenses     []models.Expense
+	settlements  []*models.Settlement
+	history      []*models.BalanceHistory
+	balances     map[string]map[string]float64 // user ID -> currency -> balance
+}
+
+// ImportSplitwise creates a group from a Splitwise export with the importing user as admin.
+// Members are matched to existing accounts by email; anyone without one becomes a placeholder
+// user. Expenses, payments and the resulting balances are written in a single transaction.
+func (s *ImportService) ImportSplitwise(ctx context.Context, userID string, data io.Reader, req SplitwiseImportRequest) (*SplitwiseImportResult, error) {
+	importer, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return nil, ErrUserNotFound
+		}
+		return nil, err
+	}
+
+	var export *splitwiseExport
+	switch req.Format {
+	case "csv":
+		export, err = parseSplitwiseCSV(data)
+	case "json":
+		export, err = parseSplitwiseJSON(data)
+	default:
+		return nil, ErrUnsupportedImportFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(export.Entries) > MaxSplitwiseImportEntries {
+		return nil, fmt.Errorf("%w: at most %d expenses can be imported at once", ErrInvalidImport, MaxSplitwiseImportEntries)
+	}
+
+	groupName := strings.TrimSpace(req.GroupName)
+	if groupName == "" {
+		groupName = export.GroupName
+	}
+	if groupName == "" {
+		return nil, fmt.Errorf("%w: group name is required", ErrInvalidImport)
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if currency == "" {
+		currency = mostUsedCurrency(export.Entries, importer.Preferences.DefaultCurrency)
+	}
+
+	result := &SplitwiseImportResult{DryRun: req.DryRun}
+	plan := &splitwiseImport{balances: make(map[string]map[string]float64)}
+
+	memberIDs, err := s.mapSplitwiseMembers(ctx, export.Members, req.MemberEmails, result, plan)
+	if err != nil {
+		return nil, err
+	}
+
+	plan.group = newImportedGroup(groupName, currency, importer.UserID, memberIDs)
+	for _, entry := range export.Entries {
+		if err := plan.addEntry(entry, memberIDs, importer.UserID); err != nil {
+			return nil, err
+		}
+	}
+
+	result.Group = plan.group
+	result.Expenses = len(plan.expenses)
+	result.Payments = len(plan.settlements)
+	result.Balances = importedBalances(plan.balances, result.Members)
+
+	if req.DryRun {
+		return result, nil
+	}
+
+	if err := s.store(ctx, plan); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// mapSplitwiseMembers returns the DivvyDoo user ID for each Splitwise member, creating
+// placeholder users (added to plan) for members without a matching account.
+func (s *ImportService) mapSplitwiseMembers(ctx context.Context, members []splitwiseMember, emails map[string]string, result *SplitwiseImportResult, plan *splitwiseImport) ([]string, error) {
+	memberIDs := make([]string, len(members))
+	seen := make(map[string]bool, len(members))
+
+	for i, member := range members {
+		email := member.Email
+		if hint := strings.TrimSpace(emails[member.Name]); hint != "" {
+			email = hint
+		}
+		email = strings.ToLower(email)
+
+		imported := ImportedMember{Name: member.Name, Email: email}
+		if email != "" {
+			user, err := s.userRepo.GetByEmail(ctx, email)
+			if err != nil && !errors.Is(err, repositories.ErrUserNotFound) {
+				return nil, err
+			}
+			if user != nil && !user.Placeholder {
+				imported.UserID = user.UserID
+			}
+		}
+
+		if imported.UserID == "" {
+			placeholderID := uuid.New().String()
+			plan.placeholders = append(plan.placeholders, &models.User{
+				UserID: placeholderID,
+				Name:   member.Name,
+				// Emails are unique, so placeholders get an undeliverable one of their own
+				Email:       placeholderID + "@placeholder.invalid",
+				Placeholder: true,
+				// Whoever signs up with the member's real email takes the placeholder over
+				ClaimEmail: email,
+			})
+			imported.UserID = placeholderID
+			imported.Placeholder = true
+		}
+
+		if seen[imported.UserID] {
+			return nil, fmt.Errorf("%w: more than one member matches %s", ErrInvalidImport, email)
+		}
+		seen[imported.UserID] = true
+
+		memberIDs[i] = imported.UserID
+		result.Members = append(result.Members, imported)
+	}
+
+	return memberIDs, nil
+}
+
+func newImportedGroup(name, currency, importerID string, memberIDs []string) *models.Group {
+	now := time.Now()
+	group := &models.Group{
+		GroupID:  uuid.New().String(),
+		Name:     name,
+		Currency: currency,
+		Members: []models.GroupMember{
+			{UserID: importerID, Role: models.RoleAdmin, JoinedAt: now, IsActive: true},
+		},
+		IsActive:  true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	for _, memberID := range memberIDs {
+		if memberID != importerID {
+			group.Members = append(group.Members, models.GroupMember{UserID: memberID, Role: models.RoleMember, JoinedAt: now, IsActive: true})
+		}
+	}
+
+	return group
+}
+
+// addEntry converts one Splitwise expense or payment and records its balance changes
+func (p *splitwiseImport) addEntry(entry splitwiseEntry, memberIDs []string, creatorID string) error {
+	if entry.Cost <= 0 {
+		return fmt.Errorf("%w: row %d: cost must be positive", ErrInvalidImport, entry.Row)
+	}
+	if entry.Currency == "" {
+		return fmt.Errorf("%w: row %d: currency is required", ErrInvalidImport, entry.Row)
+	}
+
+	totalPaid, totalOwed := 0.0, 0.0
+	for _, share := range entry.Shares {
+		if share.Paid < 0 || share.Owed < 0 {
+			return fmt.Errorf("%w: row %d: shares must not be negative", ErrInvalidImport, entry.Row)
+		}
+		totalPaid += share.Paid
+		totalOwed += share.Owed
+	}
+	if math.Abs(totalPaid-entry.Cost) > 0.01 || math.Abs(totalOwed-entry.Cost) > 0.01 {
+		return fmt.Errorf("%w: row %d: shares don't add up to the cost %.2f", ErrInvalidImport, entry.Row, entry.Cost)
+	}
+
+	groupID := p.group.GroupID
+	changeType := models.BalanceChangeExpense
+	var referenceID string
+
+	if entry.Payment {
+		settlement, err := importedSettlement(entry, memberIDs, groupID)
+		if err != nil {
+			return err
+		}
+		p.settlements = append(p.settlements, settlement)
+		changeType = models.BalanceChangeSettlement
+		referenceID = settlement.SettlementID
+	} else {
+		expense := importedExpense(entry, memberIDs, groupID, creatorID)
+		p.expenses = append(p.expenses, expense)
+		referenceID = expense.ExpenseID
+	}
+
+	description := entry.Description
+	if description == "" {
+		description = "Imported from Splitwise"
+	}
+
+	for _, share := range entry.Shares {
+		net := roundCents(share.Paid - share.Owed)
+		if net == 0 {
+			continue
+		}
+
+		userID := memberIDs[share.Member]
+		if p.balances[userID] == nil {
+			p.balances[userID] = make(map[string]float64)
+		}
+		p.balances[userID][entry.Currency] = roundCents(p.balances[userID][entry.Currency] + net)
+
+		p.history = append(p.history, &models.BalanceHistory{
+			UserID:      userID,
+			GroupID:     &groupID,
+			Amount:      net,
+			Currency:    entry.Currency,
+			Type:        changeType,
+			ReferenceID: referenceID,
+			Description: description,
+			CreatedAt:   entry.Date,
+		})
+	}
+
+	return nil
+}
+
+func importedExpense(entry splitwiseEntry, memberIDs []string, groupID string, creatorID string) models.Expense {
+	title := entry.Description
+	if title == "" {
+		title = "Imported expense"
+	}
+
+	expense := models.Expense{
+		ExpenseID:   uuid.New().String(),
+		GroupID:     &groupID,
+		CreatorID:   creatorID,
+		Title:       title,
+		Description: entry.Details,
+		Amount:      entry.Cost,
+		Currency:    entry.Currency,
+		Category:    entry.Category,
+		Split:       models.SplitDetail{Type: models.SplitExact},
+		CreatedAt:   entry.Date,
+		UpdatedAt:   entry.Date,
+		Date:        entry.Date,
+	}
+
+	for _, share := range entry.Shares {
+		if share.Paid > 0 {
+			expense.PaidBy = append(expense.PaidBy, models.PaidBy{UserID: memberIDs[share.Member], Amount: roundCents(share.Paid)})
+		}
+		if share.Owed > 0 {
+			expense.Split.Details = append(expense.Split.Details, models.SplitShare{UserID: memberIDs[share.Member], Value: roundCents(share.Owed)})
+		}
+	}
+
+	return expense
+}
+
+// importedSettlement turns a Splitwise payment into a completed settlement. Splitwise records
+// the sender as having paid the amount and the recipient as owing it.
+func importedSettlement(entry splitwiseEntry, memberIDs []string, groupID string) (*models.Settlement, error) {
+	var from, to []string
+	for _, share := range entry.Shares {
+		if share.Paid > 0 {
+			from = append(from, memberIDs[share.Member])
+		}
+		if share.Owed > 0 {
+			to = append(to, memberIDs[share.Member])
+		}
+	}
+	if len(from) != 1 || len(to) != 1 || from[0] == to[0] {
+		return nil, fmt.Errorf("%w: row %d: a payment needs exactly one sender and one recipient", ErrInvalidImport, entry.Row)
+	}
+
+	completedAt := entry.Date
+	return &models.Settlement{
+		SettlementID: uuid.New().String(),
+		FromUserID:   from[0],
+		ToUserID:     to[0],
+		GroupID:      &groupID,
+		Amount:       entry.Cost,
+		Currency:     entry.Currency,
+		Status:       models.SettlementCompleted,
+		Method:       models.SettlementMethodOther,
+		Description:  entry.Description,
+		CreatedAt:    entry.Date,
+		UpdatedAt:    entry.Date,
+		MarkedPaidAt: &completedAt,
+		CompletedAt:  &completedAt,
+	}, nil
+}
+
+// store writes the whole import in one transaction so a failure leaves nothing behind
+func (s *ImportService) store(ctx context.Context, plan *splitwiseImport) error {
+	session, err := s.expenseRepo.StartSession()
+	if err != nil {
+		return fmt.Errorf("failed to start session: %v", err)
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		for _, user := range plan.placeholders {
+			if _, err := s.userRepo.Create(sessCtx, user); err != nil {
+				return nil, err
+			}
+		}
+
+		if _, err := s.groupRepo.Create(sessCtx, plan.group); err != nil {
+			return nil, err
+		}
+
+		if err := s.expenseRepo.InsertMany(sessCtx, plan.expenses); err != nil {
+			return nil, err
+		}
+
+		revisions := make([]*models.ExpenseRevision, len(plan.expenses))
+		for i := range plan.expenses {
+			revisions[i] = newExpenseRevision(&plan.expenses[i], models.ExpenseRevisionImported, plan.expenses[i].CreatorID, nil)
+		}
+		if err := s.revisionRepo.InsertMany(sessCtx, revisions); err != nil {
+			return nil, err
+		}
+
+		if err := s.settlementRepo.InsertMany(sessCtx, plan.settlements); err != nil {
+			return nil, err
+		}
+
+		if err := s.balanceRepo.InsertBalanceHistory(sessCtx, plan.history); err != nil {
+			return nil, err
+		}
+
+		groupID := plan.group.GroupID
+		for userID, currencies := range plan.balances {
+			for currency, balance := range currencies {
+				if balance == 0 {
+					continue
+				}
+				if err := s.balanceRepo.UpdateBalance(sessCtx, userID, &groupID, currency, balance); err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		return nil, nil
+	})
+	if err != nil {
+		return fmt.Errorf("transaction failed: %v", err)
+	}
+
+	return nil
+}
+
+func importedBalances(balances map[string]map[string]float64, members []ImportedMember) []ImportedBalance {
+	result := make([]ImportedBalance, 0, len(balances))
+	for _, member := range members {
+		for currency, balance := range balances[member.UserID] {
+			if balance != 0 {
+				result = append(result, ImportedBalance{UserID: member.UserID, Name: member.Name, Currency: currency, Balance: balance})
+			}
+		}
+	}
+
+	sort.SliceStable(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Currency < result[j].Currency
+	})
+
+	return result
+}
+
+func mostUsedCurrency(entries []splitwiseEntry, fallback string) string {
+	counts := make(map[string]int)
+	best := fallback
+	for _, entry := range entries {
+		counts[entry.Currency]++
+		if counts[entry.Currency] > counts[best] {
+			best = entry.Currency
+		}
+	}
+	return best
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+)
+
+var (
+	ErrInvalidStatementMonth = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidStatementMonth, "month must be a past or current month in YYYY-MM format")
+)
+
+type StatementService struct {
+	expenseRepo    repositories.ExpenseRepository
+	settlementRepo repositories.SettlementRepository
+	balanceRepo    repositories.BalanceRepository
+	groupRepo      repositories.GroupRepository
+	userRepo       repositories.UserRepository
+}
+
+func NewStatementService(
+	expenseRepo repositories.ExpenseRepository,
+	settlementRepo repositories.SettlementRepository,
+	balanceRepo repositories.BalanceRepository,
+	groupRepo repositories.GroupRepository,
+	userRepo repositories.UserRepository,
+) *StatementService {
+	return &StatementService{
+		expenseRepo:    expenseRepo,
+		settlementRepo: settlementRepo,
+		balanceRepo:    balanceRepo,
+		groupRepo:      groupRepo,
+		userRepo:       userRepo,
+	}
+}
+
+// GroupStatement builds the statement of a group for a month. The caller must be a member; in a
+// group with private balances, members other than admins only get their own balances on it.
+func (s *StatementService) GroupStatement(ctx context.Context, groupID string, userID string, month string) (*models.Statement, error) {
+	start, end, err := parseStatementMonth(month)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := requireGroupMember(ctx, s.groupRepo, groupID, userID); err != nil {
+		return nil, err
+	}
+
+	group, err := s.groupRepo.GetByID(ctx, groupID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrGroupNotFound) {
+			return nil, ErrGroupNotFound
+		}
+		return nil, err
+	}
+
+	statement := &models.Statement{
+		Title:       group.Name,
+		Month:       month,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		GeneratedAt: time.Now(),
+	}
+
+	if err := s.fill(ctx, statement, &groupID, nil); err != nil {
+		return nil, err
+	}
+
+	if member := activeMember(group, userID); group.Settings.PrivateBalances && (member == nil || member.Role != models.RoleAdmin) {
+		own := make([]models.StatementBalance, 0, len(statement.Balances))
+		for _, balance := range statement.Balances {
+			if balance.UserID == userID {
+				own = append(own, balance)
+			}
+		}
+		statement.Balances = own
+	}
+
+	return statement, nil
+}
+
+// UserStatement builds a user's own statement for a month across all their groups
+func (s *StatementService) UserStatement(ctx context.Context, userID string, month string) (*models.Statement, error) {
+	start, end, err := parseStatementMonth(month)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &models.Statement{
+		Title:       user.Name,
+		Month:       month,
+		PeriodStart: start,
+		PeriodEnd:   end,
+		GeneratedAt: time.Now(),
+		ShareUserID: userID,
+	}
+
+	if err := s.fill(ctx, statement, nil, &userID); err != nil {
+		return nil, err
+	}
+
+	return statement, nil
+}
+
+func (s *StatementService) fill(ctx context.Context, statement *models.Statement, groupID *string, userID *string) error {
+	expenses, err := s.expenseRepo.GetInPeriod(ctx, groupID, userID, statement.PeriodStart, statement.PeriodEnd)
+	if err != nil {
+		return err
+	}
+
+	settlements, err := s.settlementRepo.GetCompletedInPeriod(ctx, groupID, userID, statement.PeriodStart, statement.PeriodEnd)
+	if err != nil {
+		return err
+	}
+
+	opening, err := s.balanceRepo.SumHistoryBefore(ctx, userID, groupID, statement.PeriodStart)
+	if err != nil {
+		return err
+	}
+
+	closing, err := s.balanceRepo.SumHistoryBefore(ctx, userID, groupID, statement.PeriodEnd)
+	if err != nil {
+		return err
+	}
+
+	statement.Expenses = expenses
+	statement.Settlements = settlements
+	statement.Balances = mergeStatementBalances(opening, closing)
+
+	// Resolve the names of everyone who appears on the statement
+	userIDs := make(map[string]bool)
+	for _, expense := range expenses {
+		for _, pb := range expense.PaidBy {
+			userIDs[pb.UserID] = true
+		}
+	}
+	for _, settlement := range settlements {
+		userIDs[settlement.FromUserID] = true
+		userIDs[settlement.ToUserID] = true
+	}
+	for _, balance := range statement.Balances {
+		userIDs[balance.UserID] = true
+	}
+
+	ids := make([]string, 0, len(userIDs))
+	for id := range userIDs {
+		ids = append(ids, id)
+	}
+
+	users, err := s.userRepo.GetByIDs(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	statement.UserNames = make(map[string]string, len(users))
+	for _, user := range users {
+		statement.UserNames[user.UserID] = user.Name
+	}
+
+	return nil
+}
+
+func mergeStatementBalances(opening, closing []repositories.HistoryTotal) []models.StatementBalance {
+	type key struct{ userID, currency string }
+	merged := make(map[key]*models.StatementBalance)
+
+	get := func(total repositories.HistoryTotal) *models.StatementBalance {
+		k := key{total.UserID, total.Currency}
+		if merged[k] == nil {
+			merged[k] = &models.StatementBalance{UserID: total.UserID, Currency: total.Currency}
+		}
+		return merged[k]
+	}
+	for _, total := range opening {
+		get(total).Opening = total.Total
+	}
+	for _, total := range closing {
+		get(total).Closing = total.Total
+	}
+
+	balances := make([]models.StatementBalance, 0, len(merged))
+	for _, balance := range merged {
+		balances = append(balances, *balance)
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].UserID != balances[j].UserID {
+			return balances[i].UserID < balances[j].UserID
+		}
+		return balances[i].Currency < balances[j].Currency
+	})
+
+	return balances
+}
+
+// parseStatementMonth returns the UTC bounds of a YYYY-MM month, rejecting future months
+func parseStatementMonth(month string) (time.Time, time.Time, error) {
+	start, err := time.Parse("2006-01", month)
+	if err != nil {
+		return time.Time{}, time.Time{}, ErrInvalidStatementMonth
+	}
+	if start.After(time.Now()) {
+		return time.Time{}, time.Time{}, ErrInvalidStatementMonth
+	}
+	return start, start.AddDate(0, 1, 0), nil
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"fmt"
+	"io"
+
+	"divvydoo/backend/internal/models"
+
+	"github.com/go-pdf/fpdf"
+)
+
+type pdfColumn struct {
+	title string
+	width float64
+	align string
+}
+
+// RenderStatementPDF writes the statement as an A4 PDF
+func RenderStatementPDF(statement *models.Statement, w io.Writer) error {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-12)
+		pdf.SetFont("Helvetica", "I", 8)
+		pdf.SetTextColor(128, 128, 128)
+		pdf.CellFormat(0, 5, fmt.Sprintf("Page %d", pdf.PageNo()), "", 0, "C", false, 0, "")
+	})
+
+	// Core fonts are cp1252; translate names and titles from UTF-8
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	name := func(userID string) string {
+		if n := statement.UserNames[userID]; n != "" {
+			return tr(n)
+		}
+		return userID
+	}
+
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 9, tr(statement.Title), "", 1, "L", false, 0, "")
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.CellFormat(0, 6, fmt.Sprintf("Statement for %s", statement.PeriodStart.Format("January 2006")), "", 1, "L", false, 0, "")
+	pdf.SetTextColor(110, 110, 110)
+	pdf.CellFormat(0, 6, fmt.Sprintf("Generated %s UTC", statement.GeneratedAt.UTC().Format("2006-01-02 15:04")), "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+	pdf.Ln(4)
+
+	// Balances
+	pdfSectionTitle(pdf, "Balances")
+	balanceColumns := []pdfColumn{
+		{"Member", 60, "L"}, {"Currency", 25, "L"}, {"Opening", 32, "R"}, {"Change", 32, "R"}, {"Closing", 31, "R"},
+	}
+	pdfTableHeader(pdf, balanceColumns)
+	if len(statement.Balances) == 0 {
+		pdfEmptyRow(pdf, "No balances")
+	}
+	for _, balance := range statement.Balances {
+		pdfTableRow(pdf, balanceColumns, []string{
+			name(balance.UserID),
+			balance.Currency,
+			formatAmount(balance.Opening),
+			formatAmount(balance.Closing - balance.Opening),
+			formatAmount(balance.Closing),
+		})
+	}
+	pdf.Ln(6)
+
+	// Expenses
+	pdfSectionTitle(pdf, "Expenses")
+	expenseColumns := []pdfColumn{
+		{"Date", 22, "L"}, {"Title", 68, "L"}, {"Paid by", 45, "L"}, {"Amount", 25, "R"}, {"Cur.", 20, "L"},
+	}
+	if statement.ShareUserID != "" {
+		expenseColumns = []pdfColumn{
+			{"Date", 22, "L"}, {"Title", 52, "L"}, {"Paid by", 40, "L"}, {"Amount", 24, "R"}, {"Your share", 24, "R"}, {"Cur.", 18, "L"},
+		}
+	}
+	pdfTableHeader(pdf, expenseColumns)
+	if len(statement.Expenses) == 0 {
+		pdfEmptyRow(pdf, "No expenses this month")
+	}
+	for _, expense := range statement.Expenses {
+		payer := ""
+		if len(expense.PaidBy) > 0 {
+			payer = name(expense.PaidBy[0].UserID)
+			if len(expense.PaidBy) > 1 {
+				payer += fmt.Sprintf(" +%d", len(expense.PaidBy)-1)
+			}
+		}
+
+		row := []string{
+			expense.Date.UTC().Format("2006-01-02"),
+			tr(expense.Title),
+			payer,
+			formatAmount(expense.Amount),
+		}
+		if statement.ShareUserID != "" {
+			share := 0.0
+			for _, detail := range expense.Split.Details {
+				if detail.UserID == statement.ShareUserID {
+					share += detail.Value
+				}
+			}
+			row = append(row, formatAmount(share))
+		}
+		row = append(row, expense.Currency)
+
+		pdfTableRow(pdf, expenseColumns, row)
+	}
+	pdf.Ln(6)
+
+	// Settlements
+	pdfSectionTitle(pdf, "Settlements")
+	settlementColumns := []pdfColumn{
+		{"Date", 22, "L"}, {"From", 45, "L"}, {"To", 45, "L"}, {"Method", 28, "L"}, {"Amount", 25, "R"}, {"Cur.", 15, "L"},
+	}
+	pdfTableHeader(pdf, settlementColumns)
+	if len(statement.Settlements) == 0 {
+		pdfEmptyRow(pdf, "No settlements this month")
+	}
+	for _, settlement := range statement.Settlements {
+		date := settlement.CreatedAt
+		if settlement.CompletedAt != nil {
+			date = *settlement.CompletedAt
+		}
+		pdfTableRow(pdf, settlementColumns, []string{
+			date.UTC().Format("2006-01-02"),
+			name(settlement.FromUserID),
+			name(settlement.ToUserID),
+			string(settlement.Method),
+			formatAmount(settlement.Amount),
+			settlement.Currency,
+		})
+	}
+
+	return pdf.Output(w)
+}
+
+func pdfSectionTitle(pdf *fpdf.Fpdf, title string) {
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 8, title, "", 1, "L", false, 0, "")
+}
+
+func pdfTableHeader(pdf *fpdf.Fpdf, columns []pdfColumn) {
+	pdf.SetFont("Helvetica", "B", 9)
+	pdf.SetFillColor(235, 235, 235)
+	for _, column := range columns {
+		pdf.CellFormat(column.width, 7, column.title, "B", 0, column.align, true, 0, "")
+	}
+	pdf.Ln(-1)
+	pdf.SetFont("Helvetica", "", 9)
+}
+
+func pdfTableRow(pdf *fpdf.Fpdf, columns []pdfColumn, values []string) {
+	for i, column := range columns {
+		pdf.CellFormat(column.width, 6, pdfFit(pdf, values[i], column.width-2), "", 0, column.align, false, 0, "")
+	}
+	pdf.Ln(-1)
+}
+
+func pdfEmptyRow(pdf *fpdf.Fpdf, text string) {
+	pdf.SetTextColor(128, 128, 128)
+	pdf.CellFormat(0, 6, text, "", 1, "L", false, 0, "")
+	pdf.SetTextColor(0, 0, 0)
+}
+
+// pdfFit truncates text with an ellipsis so it fits in the given width
+func pdfFit(pdf *fpdf.Fpdf, text string, width float64) string {
+	if pdf.GetStringWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && pdf.GetStringWidth(string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "..."
+}
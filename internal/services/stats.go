@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+)
+
+const (
+	DefaultStatsDays = 14
+	MaxStatsDays     = 90
+)
+
+var ErrInvalidStatsWindow = utils.NewCustomError(http.StatusBadRequest, utils.CodeInvalidStatsWindow, "invalid stats window")
+
+// StatsService computes the operational dashboard figures. The aggregations scan whole
+// collections, so results are cached per window for cacheTTL.
+type StatsService struct {
+	statsRepo repositories.StatsRepository
+	cacheTTL  time.Duration
+
+	mu    sync.Mutex
+	cache map[int]*models.AdminStats // window in days -> last result
+}
+
+func NewStatsService(statsRepo repositories.StatsRepository, cacheTTL time.Duration) *StatsService {
+	return &StatsService{
+		statsRepo: statsRepo,
+		cacheTTL:  cacheTTL,
+		cache:     make(map[int]*models.AdminStats),
+	}
+}
+
+// GetAdminStats returns the figures for the last days days, today included
+func (s *StatsService) GetAdminStats(ctx context.Context, days int) (*models.AdminStats, error) {
+	if days < 1 || days > MaxStatsDays {
+		return nil, fmt.Errorf("%w: days must be between 1 and %d", ErrInvalidStatsWindow, MaxStatsDays)
+	}
+
+	s.mu.Lock()
+	cached := s.cache[days]
+	s.mu.Unlock()
+	if cached != nil && time.Since(cached.GeneratedAt) < s.cacheTTL {
+		return cached, nil
+	}
+
+	stats, err := s.compute(ctx, days)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.cache[days] = stats
+	s.mu.Unlock()
+	return stats, nil
+}
+
+func (s *StatsService) compute(ctx context.Context, days int) (*models.AdminStats, error) {
+	now := time.Now().UTC()
+	since := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).AddDate(0, 0, 1-days)
+
+	stats := &models.AdminStats{Days: days, Since: since, GeneratedAt: now}
+
+	var err error
+	if stats.TotalUsers, err = s.statsRepo.CountUsers(ctx); err != nil {
+		return nil, err
+	}
+	if stats.ActiveGroups, stats.AverageGroupSize, err = s.statsRepo.GroupSizes(ctx); err != nil {
+		return nil, err
+	}
+	stats.AverageGroupSize = math.Round(stats.AverageGroupSize*100) / 100
+
+	newUsers, err := s.statsRepo.NewUsersByDay(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	stats.NewUsersPerDay = dailySeries(newUsers, since, days)
+
+	expenses, err := s.statsRepo.ExpensesByDay(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	stats.ExpensesPerDay = dailySeries(expenses, since, days)
+
+	byStatus, err := s.statsRepo.SettlementsByStatus(ctx, since)
+	if err != nil {
+		return nil, err
+	}
+	stats.Settlements.ByStatus = byStatus
+	for _, count := range byStatus {
+		stats.Settlements.Created += count
+	}
+	stats.Settlements.Completed = byStatus[models.SettlementCompleted]
+	if stats.Settlements.Created > 0 {
+		rate := float64(stats.Settlements.Completed) / float64(stats.Settlements.Created)
+		stats.Settlements.CompletionRate = math.Round(rate*10000) / 10000
+	}
+
+	if stats.FailedJobs, err = s.statsRepo.CountFailedJobs(ctx, since); err != nil {
+		return nil, err
+	}
+
+	return stats, nil
+}
+
+// dailySeries lists a count for every day of the window, oldest first, so days without
+// activity show up as zero instead of gaps
+func dailySeries(counts map[string]int64, since time.Time, days int) []models.DailyCount {
+	series := make([]models.DailyCount, days)
+	for i := range series {
+		date := since.AddDate(0, 0, i).Format("2006-01-02")
+		series[i] = models.DailyCount{Date: date, Count: counts[date]}
+	}
+	return series
+}
@@ -0,0 +1,230 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/repositories"
+)
+
+const (
+	// suggestionLookback is how far back non-group expenses count towards suggestions
+	suggestionLookback = 180 * 24 * time.Hour
+	// minSuggestionExpenses is how many expenses the same people must have shared
+	minSuggestionExpenses = 3
+	// maxSuggestionParticipants leaves out expenses too large to be a circle of friends
+	maxSuggestionParticipants = 10
+	// maxSuggestionsPerUser keeps the strongest suggestions of each user
+	maxSuggestionsPerUser = 5
+)
+
+// SuggestionService suggests groups to users who keep splitting expenses with the same people
+// outside of groups
+type SuggestionService struct {
+	expenseRepo    repositories.ExpenseRepository
+	suggestionRepo repositories.SuggestionRepository
+	groupRepo      repositories.GroupRepository
+	userRepo       repositories.UserRepository
+}
+
+func NewSuggestionService(expenseRepo repositories.ExpenseRepository, suggestionRepo repositories.SuggestionRepository, groupRepo repositories.GroupRepository, userRepo repositories.UserRepository) *SuggestionService {
+	return &SuggestionService{
+		expenseRepo:    expenseRepo,
+		suggestionRepo: suggestionRepo,
+		groupRepo:      groupRepo,
+		userRepo:       userRepo,
+	}
+}
+
+// RefreshGroupSuggestions recomputes every user's suggestions from the recent non-group expenses.
+// Each distinct set of participants is a candidate group for each of its participants, once they
+// have shared at least minSuggestionExpenses expenses. It returns how many suggestions were stored.
+func (s *SuggestionService) RefreshGroupSuggestions(ctx context.Context) (int, error) {
+	computedAt := time.Now()
+
+	type circle struct {
+		participants []string
+		count        int
+		last         time.Time
+	}
+	circles := make(map[string]*circle)
+
+	err := s.expenseRepo.ForEachWithoutGroupSince(ctx, computedAt.Add(-suggestionLookback), func(expense *models.Expense) error {
+		participants := expenseCircle(expense)
+		if len(participants) < 2 || len(participants) > maxSuggestionParticipants {
+			return nil
+		}
+
+		key := strings.Join(participants, ",")
+		c := circles[key]
+		if c == nil {
+			c = &circle{participants: participants}
+			circles[key] = c
+		}
+		c.count++
+		if expense.CreatedAt.After(c.last) {
+			c.last = expense.CreatedAt
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan expenses: %w", err)
+	}
+
+	byUser := make(map[string][]*models.GroupSuggestion)
+	for key, c := range circles {
+		if c.count < minSuggestionExpenses {
+			continue
+		}
+		for _, userID := range c.participants {
+			members := make([]string, 0, len(c.participants)-1)
+			for _, other := range c.participants {
+				if other != userID {
+					members = append(members, other)
+				}
+			}
+			byUser[userID] = append(byUser[userID], &models.GroupSuggestion{
+				SuggestionID:  suggestionID(userID, key),
+				UserID:        userID,
+				MemberIDs:     members,
+				ExpenseCount:  c.count,
+				LastExpenseAt: c.last,
+				ComputedAt:    computedAt,
+			})
+		}
+	}
+
+	stored := 0
+	for _, suggestions := range byUser {
+		sort.Slice(suggestions, func(i, j int) bool {
+			if suggestions[i].ExpenseCount != suggestions[j].ExpenseCount {
+				return suggestions[i].ExpenseCount > suggestions[j].ExpenseCount
+			}
+			return suggestions[i].LastExpenseAt.After(suggestions[j].LastExpenseAt)
+		})
+		if len(suggestions) > maxSuggestionsPerUser {
+			suggestions = suggestions[:maxSuggestionsPerUser]
+		}
+		for _, suggestion := range suggestions {
+			if err := s.suggestionRepo.Upsert(ctx, suggestion); err != nil {
+				return stored, err
+			}
+			stored++
+		}
+	}
+
+	if _, err := s.suggestionRepo.DeleteComputedBefore(ctx, computedAt); err != nil {
+		return stored, err
+	}
+	return stored, nil
+}
+
+// GetGroupSuggestions returns the user's suggestions with the members' names, leaving out those
+// already covered by a group the user is in with all of the suggested members
+func (s *SuggestionService) GetGroupSuggestions(ctx context.Context, userID string) ([]*models.GroupSuggestion, error) {
+	suggestions, err := s.suggestionRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(suggestions) == 0 {
+		return []*models.GroupSuggestion{}, nil
+	}
+
+	groups, err := s.groupRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var memberIDs []string
+	result := make([]*models.GroupSuggestion, 0, len(suggestions))
+	for _, suggestion := range suggestions {
+		if coveredByGroup(groups, suggestion.MemberIDs) {
+			continue
+		}
+		result = append(result, suggestion)
+		memberIDs = append(memberIDs, suggestion.MemberIDs...)
+	}
+	if len(result) == 0 {
+		return result, nil
+	}
+
+	users, err := s.userRepo.GetByIDs(ctx, memberIDs)
+	if err != nil {
+		return nil, err
+	}
+	usersByID := make(map[string]*models.User, len(users))
+	for _, user := range users {
+		usersByID[user.UserID] = user
+	}
+
+	for _, suggestion := range result {
+		names := make([]string, 0, len(suggestion.MemberIDs))
+		suggestion.Members = make([]models.SuggestedMember, 0, len(suggestion.MemberIDs))
+		for _, memberID := range suggestion.MemberIDs {
+			member := models.SuggestedMember{UserID: memberID, Name: memberID}
+			if user := usersByID[memberID]; user != nil {
+				member.Name = user.Name
+				member.AvatarURL = user.AvatarURL
+			}
+			suggestion.Members = append(suggestion.Members, member)
+			names = append(names, member.Name)
+		}
+		suggestion.Message = fmt.Sprintf("You've split %d expenses with %s — create a group?", suggestion.ExpenseCount, joinNames(names))
+	}
+
+	return result, nil
+}
+
+// expenseCircle returns the sorted, distinct IDs of everyone involved in the expense
+func expenseCircle(expense *models.Expense) []string {
+	seen := make(map[string]bool)
+	var circle []string
+	for _, userID := range expenseParticipants(expense) {
+		if userID != "" && !seen[userID] {
+			seen[userID] = true
+			circle = append(circle, userID)
+		}
+	}
+
+	sort.Strings(circle)
+	return circle
+}
+
+// suggestionID is stable across computations, so recomputing replaces rather than duplicates
+func suggestionID(userID string, circleKey string) string {
+	sum := sha256.Sum256([]byte(userID + "|" + circleKey))
+	return hex.EncodeToString(sum[:16])
+}
+
+func coveredByGroup(groups []*models.Group, memberIDs []string) bool {
+	for _, group := range groups {
+		covered := true
+		for _, memberID := range memberIDs {
+			if activeMember(group, memberID) == nil {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			return true
+		}
+	}
+	return false
+}
+
+// joinNames lists names as "Sam", "Sam and Alex" or "Sam, Alex and Kim"
+func joinNames(names []string) string {
+	switch len(names) {
+	case 0:
+		return ""
+	case 1:
+		return names[0]
+	}
+	return strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1]
+}
@@ -3,27 +3,67 @@ package services
 import (
 	"context"
 	"errors"
+	"log"
+	"math"
+	"net/http"
 	"time"
 
 	"divvydoo/backend/internal/models"
 	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/utils"
+	"divvydoo/backend/pkg/email"
+	"divvydoo/backend/pkg/locale"
+	"divvydoo/backend/pkg/storage"
 
 	"github.com/google/uuid"
 	"golang.org/x/crypto/bcrypt"
 )
 
 var (
-	ErrInvalidCredentials  = errors.New("invalid email or password")
-	ErrUserNotFound        = errors.New("user not found")
-	ErrUserAlreadyExists   = errors.New("user with this email already exists")
+	ErrInvalidCredentials = utils.NewCustomError(http.StatusUnauthorized, utils.CodeInvalidCredentials, "invalid email or password")
+	ErrUserNotFound       = utils.NewCustomError(http.StatusNotFound, utils.CodeUserNotFound, "user not found")
+	ErrUserAlreadyExists  = utils.NewCustomError(http.StatusConflict, utils.CodeUserAlreadyExists, "user with this email already exists")
+	ErrUserHasBalances    = utils.NewCustomError(http.StatusConflict, utils.CodeUserHasBalances, "user still owes or is owed money; settle up first or force the deletion")
+	ErrAccountDisabled    = utils.NewCustomError(http.StatusForbidden, utils.CodeAccountDisabled, "account is disabled")
 )
 
 type UserService struct {
-	userRepo repositories.UserRepository
+	userRepo         repositories.UserRepository
+	groupRepo        repositories.GroupRepository
+	balanceRepo      repositories.BalanceRepository
+	expenseRepo      repositories.ExpenseRepository
+	settlementRepo   repositories.SettlementRepository
+	friendshipRepo   repositories.FriendshipRepository
+	notificationRepo repositories.NotificationRepository
+	store            storage.Store
+	emailSender      email.EmailSender
+	aggregates       *AggregateCache
 }
 
-func NewUserService(userRepo repositories.UserRepository) *UserService {
-	return &UserService{userRepo: userRepo}
+func NewUserService(
+	userRepo repositories.UserRepository,
+	groupRepo repositories.GroupRepository,
+	balanceRepo repositories.BalanceRepository,
+	expenseRepo repositories.ExpenseRepository,
+	settlementRepo repositories.SettlementRepository,
+	friendshipRepo repositories.FriendshipRepository,
+	notificationRepo repositories.NotificationRepository,
+	store storage.Store,
+	emailSender email.EmailSender,
+	aggregates *AggregateCache,
+) *UserService {
+	return &UserService{
+		userRepo:         userRepo,
+		groupRepo:        groupRepo,
+		balanceRepo:      balanceRepo,
+		expenseRepo:      expenseRepo,
+		settlementRepo:   settlementRepo,
+		friendshipRepo:   friendshipRepo,
+		notificationRepo: notificationRepo,
+		store:            store,
+		emailSender:      emailSender,
+		aggregates:       aggregates,
+	}
 }
 
 type CreateUserRequest struct {
@@ -34,9 +74,14 @@ type CreateUserRequest struct {
 }
 
 type UpdateUserRequest struct {
-	Name  string `json:"name,omitempty"`
-	Email string `json:"email,omitempty"`
-	Phone string `json:"phone,omitempty"`
+	Name            string `json:"name,omitempty"`
+	Email           string `json:"email,omitempty"`
+	Phone           string `json:"phone,omitempty"`
+	DefaultCurrency string `json:"default_currency,omitempty" binding:"omitempty,len=3,uppercase"`
+	// LookupVisibility is "everyone" or "friends_of_friends"
+	LookupVisibility string `json:"lookup_visibility,omitempty" binding:"omitempty,oneof=everyone friends_of_friends"`
+	// Language is the language notifications and emails are written in
+	Language string `json:"language,omitempty" binding:"omitempty,oneof=en de es fr hi it ja nl pt"`
 }
 
 type LoginRequest struct {
@@ -73,9 +118,20 @@ func (s *UserService) CreateUser(ctx context.Context, req CreateUserRequest) (*m
 		Password:  string(hashedPassword),
 		CreatedAt: time.Now(),
 		UpdatedAt: time.Now(),
+		// Until the user picks one, write to them in the language they signed up in
+		Preferences: models.UserPreferences{Language: locale.FromContext(ctx).Language},
+	}
+
+	created, err := s.userRepo.Create(ctx, user)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.userRepo.Create(ctx, user)
+	// Placeholders imported under the email only become the user's once they prove it's theirs
+	if err := s.offerPlaceholderClaims(ctx, created); err != nil {
+		log.Printf("Failed to offer user %s the placeholders imported under their email: %v", created.UserID, err)
+	}
+	return created, nil
 }
 
 func (s *UserService) GetUser(ctx context.Context, userID string) (*models.User, error) {
@@ -111,7 +167,27 @@ func (s *UserService) GetUserByPhone(ctx context.Context, phone string) (*models
 	return user, nil
 }
 
-func (s *UserService) LookupUser(ctx context.Context, query string) (*models.User, error) {
+// LookupUser finds a user by email or phone for the searching user. Users who limited lookups to
+// friends of friends aren't found by anyone further away.
+func (s *UserService) LookupUser(ctx context.Context, searcherID string, query string) (*models.User, error) {
+	user, err := s.lookupUser(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Preferences.LookupVisibility == models.LookupVisibilityFriendsOfFriends && user.UserID != searcherID {
+		visible, err := withinFriendsOfFriends(ctx, s.friendshipRepo, searcherID, user.UserID)
+		if err != nil {
+			return nil, err
+		}
+		if !visible {
+			return nil, ErrUserNotFound
+		}
+	}
+	return user, nil
+}
+
+func (s *UserService) lookupUser(ctx context.Context, query string) (*models.User, error) {
 	// Try email first
 	user, err := s.userRepo.GetByEmail(ctx, query)
 	if err == nil {
@@ -150,6 +226,15 @@ func (s *UserService) UpdateUser(ctx context.Context, userID string, req UpdateU
 	if req.Phone != "" {
 		user.Phone = req.Phone
 	}
+	if req.DefaultCurrency != "" {
+		user.Preferences.DefaultCurrency = req.DefaultCurrency
+	}
+	if req.LookupVisibility != "" {
+		user.Preferences.LookupVisibility = models.LookupVisibility(req.LookupVisibility)
+	}
+	if req.Language != "" {
+		user.Preferences.Language = req.Language
+	}
 
 	return s.userRepo.Update(ctx, user)
 }
@@ -158,6 +243,107 @@ func (s *UserService) DeleteUser(ctx context.Context, userID string) error {
 	return s.userRepo.Delete(ctx, userID)
 }
 
+// DeleteAccount anonymizes the user for data-protection requests. The user document and its ID
+// are kept so expenses, settlements and balances still add up for the other members, but the
+// personal data and avatar are removed, group memberships are ended and the inbox is cleared.
+// Users who still owe or are owed money, in a group or directly, can only be deleted with force,
+// in which case their balances stay as they are. Revoking the user's tokens is left to the caller.
+func (s *UserService) DeleteAccount(ctx context.Context, userID string, force bool) error {
+	user, err := s.userRepo.GetByID(ctx, userID)
+	if err != nil {
+		if errors.Is(err, repositories.ErrUserNotFound) {
+			return ErrUserNotFound
+		}
+		return err
+	}
+	if user.DeletedAt != nil {
+		return nil
+	}
+
+	if !force {
+		balances, err := s.balanceRepo.GetByUserID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, balance := range balances {
+			if math.Abs(balance.Balance) >= 0.01 {
+				return ErrUserHasBalances
+			}
+		}
+
+		direct, err := s.balanceRepo.GetDirectBalances(ctx, userID, nil)
+		if err != nil {
+			return err
+		}
+		for _, balance := range direct {
+			if math.Abs(balance.Balance) >= 0.01 {
+				return ErrUserHasBalances
+			}
+		}
+	}
+
+	groups, err := s.groupRepo.GetByUserID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	for _, group := range groups {
+		if err := s.leaveGroup(ctx, group, userID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.notificationRepo.DeleteByUserID(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := s.userRepo.Anonymize(ctx, userID, time.Now()); err != nil {
+		return err
+	}
+
+	if user.Avatar != nil {
+		for _, key := range []string{user.Avatar.Key, user.Avatar.SourceKey} {
+			if err := s.store.Delete(ctx, key); err != nil {
+				log.Printf("Failed to delete avatar file %s of deleted user %s: %v", key, userID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// leaveGroup ends the user's membership. If they were the group's last admin, the longest-standing
+// remaining member takes over so the group can still be managed.
+func (s *UserService) leaveGroup(ctx context.Context, group *models.Group, userID string) error {
+	member := activeMember(group, userID)
+	if member == nil {
+		return nil
+	}
+
+	if member.Role == models.RoleAdmin {
+		var successor *models.GroupMember
+		hasAdmin := false
+		for i := range group.Members {
+			m := &group.Members[i]
+			if !m.IsActive || m.UserID == userID {
+				continue
+			}
+			if m.Role == models.RoleAdmin {
+				hasAdmin = true
+				break
+			}
+			if successor == nil || m.JoinedAt.Before(successor.JoinedAt) {
+				successor = m
+			}
+		}
+		if !hasAdmin && successor != nil {
+			if err := s.groupRepo.UpdateMemberRole(ctx, group.GroupID, successor.UserID, models.RoleAdmin); err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.groupRepo.RemoveMember(ctx, group.GroupID, userID)
+}
+
 func (s *UserService) ValidateCredentials(ctx context.Context, email, password string) (*models.User, error) {
 	user, err := s.userRepo.GetByEmail(ctx, email)
 	if err != nil {
@@ -167,9 +353,18 @@ func (s *UserService) ValidateCredentials(ctx context.Context, email, password s
 		return nil, err
 	}
 
+	if user.DeletedAt != nil {
+		return nil, ErrInvalidCredentials
+	}
+
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)); err != nil {
 		return nil, ErrInvalidCredentials
 	}
 
+	// Checked after the password, so it doesn't reveal which emails have disabled accounts
+	if user.DisabledAt != nil {
+		return nil, ErrAccountDisabled
+	}
+
 	return user, nil
 }
@@ -0,0 +1,174 @@
+package utils
+
+import "net/http"
+
+// ErrorCode is the machine-readable "code" of an error response. Messages may be reworded or
+// translated; codes are stable, so clients should branch on them instead.
+type ErrorCode string
+
+// Generic codes, used for errors that have no more specific code
+const (
+	CodeInvalidRequest     ErrorCode = "INVALID_REQUEST"
+	CodeInvalidPayload     ErrorCode = "INVALID_PAYLOAD"
+	CodeValidationFailed   ErrorCode = "VALIDATION_FAILED"
+	CodeUnauthorized       ErrorCode = "UNAUTHORIZED"
+	CodeForbidden          ErrorCode = "FORBIDDEN"
+	CodeNotFound           ErrorCode = "NOT_FOUND"
+	CodeConflict           ErrorCode = "CONFLICT"
+	CodePayloadTooLarge    ErrorCode = "PAYLOAD_TOO_LARGE"
+	CodeUnprocessable      ErrorCode = "UNPROCESSABLE"
+	CodeRateLimited        ErrorCode = "RATE_LIMITED"
+	CodeInternal           ErrorCode = "INTERNAL_ERROR"
+	CodeServiceUnavailable ErrorCode = "SERVICE_UNAVAILABLE"
+)
+
+// Authentication and request handling
+const (
+	CodeTokenInvalid          ErrorCode = "TOKEN_INVALID"
+	CodeTokenExpired          ErrorCode = "TOKEN_EXPIRED"
+	CodeTokenRevoked          ErrorCode = "TOKEN_REVOKED"
+	CodeAPIKeyInvalid         ErrorCode = "API_KEY_INVALID"
+	CodeAPIKeyScope           ErrorCode = "API_KEY_SCOPE"
+	CodeUserTokenRequired     ErrorCode = "USER_TOKEN_REQUIRED"
+	CodeAdminRequired         ErrorCode = "ADMIN_REQUIRED"
+	CodeMaintenanceMode       ErrorCode = "MAINTENANCE_MODE"
+	CodeInvalidCurrencyHeader ErrorCode = "INVALID_CURRENCY_HEADER"
+	CodeIdempotencyInProgress ErrorCode = "IDEMPOTENCY_IN_PROGRESS"
+	CodeIdempotencyKeyReused  ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+)
+
+// Domain codes, carried by the services' and repositories' errors
+const (
+	CodeAPIKeyNotFound                  ErrorCode = "API_KEY_NOT_FOUND"
+	CodeAccountDisabled                 ErrorCode = "ACCOUNT_DISABLED"
+	CodeAdminSelfChange                 ErrorCode = "ADMIN_SELF_CHANGE"
+	CodeAlreadyFriends                  ErrorCode = "ALREADY_FRIENDS"
+	CodeBackupNotAvailable              ErrorCode = "BACKUP_NOT_AVAILABLE"
+	CodeBackupNotFound                  ErrorCode = "BACKUP_NOT_FOUND"
+	CodeBalanceConflict                 ErrorCode = "BALANCE_CONFLICT"
+	CodeBalanceNotFound                 ErrorCode = "BALANCE_NOT_FOUND"
+	CodeBalanceTaskNotFound             ErrorCode = "BALANCE_TASK_NOT_FOUND"
+	CodeBalanceUpdatesPending           ErrorCode = "BALANCE_UPDATES_PENDING"
+	CodeBudgetNotFound                  ErrorCode = "BUDGET_NOT_FOUND"
+	CodeBudgetNotSet                    ErrorCode = "BUDGET_NOT_SET"
+	CodeCannotForgiveDebt               ErrorCode = "CANNOT_FORGIVE_DEBT"
+	CodeCannotFriendSelf                ErrorCode = "CANNOT_FRIEND_SELF"
+	CodeCurrencyMismatch                ErrorCode = "CURRENCY_MISMATCH"
+	CodeExchangeRateUnavailable         ErrorCode = "EXCHANGE_RATE_UNAVAILABLE"
+	CodeExpenseAccessDenied             ErrorCode = "EXPENSE_ACCESS_DENIED"
+	CodeExpenseEditDenied               ErrorCode = "EXPENSE_EDIT_DENIED"
+	CodeExpenseNeedsConfirmation        ErrorCode = "EXPENSE_NEEDS_CONFIRMATION"
+	CodeExpenseNotFound                 ErrorCode = "EXPENSE_NOT_FOUND"
+	CodeExpenseNotPendingApproval       ErrorCode = "EXPENSE_NOT_PENDING_APPROVAL"
+	CodeExpensePaymentMismatch          ErrorCode = "EXPENSE_PAYMENT_MISMATCH"
+	CodeExpenseSplitMismatch            ErrorCode = "EXPENSE_SPLIT_MISMATCH"
+	CodeExpenseStateChanged             ErrorCode = "EXPENSE_STATE_CHANGED"
+	CodeExportNotFound                  ErrorCode = "EXPORT_NOT_FOUND"
+	CodeExportNotReady                  ErrorCode = "EXPORT_NOT_READY"
+	CodeFriendRequestNotFound           ErrorCode = "FRIEND_REQUEST_NOT_FOUND"
+	CodeFriendshipNotFound              ErrorCode = "FRIENDSHIP_NOT_FOUND"
+	CodeGroupAlreadyExists              ErrorCode = "GROUP_ALREADY_EXISTS"
+	CodeGroupArchived                   ErrorCode = "GROUP_ARCHIVED"
+	CodeGroupCurrencyChanged            ErrorCode = "GROUP_CURRENCY_CHANGED"
+	CodeGroupHasBalances                ErrorCode = "GROUP_HAS_BALANCES"
+	CodeGroupMemberNotFound             ErrorCode = "GROUP_MEMBER_NOT_FOUND"
+	CodeGroupNotFound                   ErrorCode = "GROUP_NOT_FOUND"
+	CodeInvalidAPIKeyScopes             ErrorCode = "INVALID_API_KEY_SCOPES"
+	CodeInvalidAPIKeyTTL                ErrorCode = "INVALID_API_KEY_TTL"
+	CodeInvalidAvatar                   ErrorCode = "INVALID_AVATAR"
+	CodeInvalidAvatarCrop               ErrorCode = "INVALID_AVATAR_CROP"
+	CodeInvalidBalanceChangeType        ErrorCode = "INVALID_BALANCE_CHANGE_TYPE"
+	CodeInvalidBudget                   ErrorCode = "INVALID_BUDGET"
+	CodeInvalidClaimToken               ErrorCode = "INVALID_CLAIM_TOKEN"
+	CodeInvalidCurrencyChange           ErrorCode = "INVALID_CURRENCY_CHANGE"
+	CodeInvalidCredentials              ErrorCode = "INVALID_CREDENTIALS"
+	CodeInvalidCursor                   ErrorCode = "INVALID_CURSOR"
+	CodeInvalidExpense                  ErrorCode = "INVALID_EXPENSE"
+	CodeInvalidExportYear               ErrorCode = "INVALID_EXPORT_YEAR"
+	CodeInvalidFairnessPeriod           ErrorCode = "INVALID_FAIRNESS_PERIOD"
+	CodeInvalidGranularity              ErrorCode = "INVALID_GRANULARITY"
+	CodeInvalidGroupSettings            ErrorCode = "INVALID_GROUP_SETTINGS"
+	CodeInvalidImport                   ErrorCode = "INVALID_IMPORT"
+	CodeInvalidMemberRole               ErrorCode = "INVALID_MEMBER_ROLE"
+	CodeInvalidNetting                  ErrorCode = "INVALID_NETTING"
+	CodeInvalidPaymentEvent             ErrorCode = "INVALID_PAYMENT_EVENT"
+	CodeInvalidPagination               ErrorCode = "INVALID_PAGINATION"
+	CodeInvalidRecategorization         ErrorCode = "INVALID_RECATEGORIZATION"
+	CodeInvalidSearchFilter             ErrorCode = "INVALID_SEARCH_FILTER"
+	CodeInvalidSettlement               ErrorCode = "INVALID_SETTLEMENT"
+	CodeInvalidSettlementAmount         ErrorCode = "INVALID_SETTLEMENT_AMOUNT"
+	CodeInvalidSettlementMethod         ErrorCode = "INVALID_SETTLEMENT_METHOD"
+	CodeInvalidSettlementStatus         ErrorCode = "INVALID_SETTLEMENT_STATUS"
+	CodeInvalidShareLinkTTL             ErrorCode = "INVALID_SHARE_LINK_TTL"
+	CodeInvalidStatementMonth           ErrorCode = "INVALID_STATEMENT_MONTH"
+	CodeInvalidStatsWindow              ErrorCode = "INVALID_STATS_WINDOW"
+	CodeInvalidTimelineSpan             ErrorCode = "INVALID_TIMELINE_SPAN"
+	CodeInvalidUserRole                 ErrorCode = "INVALID_USER_ROLE"
+	CodeInvalidWebhookSignature         ErrorCode = "INVALID_WEBHOOK_SIGNATURE"
+	CodeInvalidWebhookURL               ErrorCode = "INVALID_WEBHOOK_URL"
+	CodeJobAlreadyRunning               ErrorCode = "JOB_ALREADY_RUNNING"
+	CodeJobNotFound                     ErrorCode = "JOB_NOT_FOUND"
+	CodeLastGroupAdmin                  ErrorCode = "LAST_GROUP_ADMIN"
+	CodeMemberAlreadyExists             ErrorCode = "MEMBER_ALREADY_EXISTS"
+	CodeNoAvatar                        ErrorCode = "NO_AVATAR"
+	CodeNotFriendRecipient              ErrorCode = "NOT_FRIEND_RECIPIENT"
+	CodeNotFriends                      ErrorCode = "NOT_FRIENDS"
+	CodeNotGroupAdmin                   ErrorCode = "NOT_GROUP_ADMIN"
+	CodeNotGroupMember                  ErrorCode = "NOT_GROUP_MEMBER"
+	CodeNotSettlementPayee              ErrorCode = "NOT_SETTLEMENT_PAYEE"
+	CodeNotSettlementPayer              ErrorCode = "NOT_SETTLEMENT_PAYER"
+	CodeNothingOwed                     ErrorCode = "NOTHING_OWED"
+	CodeNothingToNet                    ErrorCode = "NOTHING_TO_NET"
+	CodeNothingToSettle                 ErrorCode = "NOTHING_TO_SETTLE"
+	CodeNotificationNotFound            ErrorCode = "NOTIFICATION_NOT_FOUND"
+	CodeOutboxMessageNotFound           ErrorCode = "OUTBOX_MESSAGE_NOT_FOUND"
+	CodeOutstandingBalance              ErrorCode = "OUTSTANDING_BALANCE"
+	CodePaymentProviderNotFound         ErrorCode = "PAYMENT_PROVIDER_NOT_FOUND"
+	CodePlaceholderConflict             ErrorCode = "PLACEHOLDER_CONFLICT"
+	CodeRecategorizationNotFound        ErrorCode = "RECATEGORIZATION_NOT_FOUND"
+	CodeSettlementAuthorizationNotFound ErrorCode = "SETTLEMENT_AUTHORIZATION_NOT_FOUND"
+	CodeSettlementAuthorizationSelf     ErrorCode = "SETTLEMENT_AUTHORIZATION_SELF"
+	CodeSettlementCompleted             ErrorCode = "SETTLEMENT_COMPLETED"
+	CodeSettlementNotAwaiting           ErrorCode = "SETTLEMENT_NOT_AWAITING"
+	CodeSettlementNotCancellable        ErrorCode = "SETTLEMENT_NOT_CANCELLABLE"
+	CodeSettlementNotCompleted          ErrorCode = "SETTLEMENT_NOT_COMPLETED"
+	CodeSettlementNotFound              ErrorCode = "SETTLEMENT_NOT_FOUND"
+	CodeSettlementNotPending            ErrorCode = "SETTLEMENT_NOT_PENDING"
+	CodeSettlementNotVoidable           ErrorCode = "SETTLEMENT_NOT_VOIDABLE"
+	CodeSettlementStateChanged          ErrorCode = "SETTLEMENT_STATE_CHANGED"
+	CodeSettlementsOpen                 ErrorCode = "SETTLEMENTS_OPEN"
+	CodeShareLinkNotFound               ErrorCode = "SHARE_LINK_NOT_FOUND"
+	CodeShareLinkUnavailable            ErrorCode = "SHARE_LINK_UNAVAILABLE"
+	CodeTooManyAPIKeys                  ErrorCode = "TOO_MANY_API_KEYS"
+	CodeTooManyExpenseIDs               ErrorCode = "TOO_MANY_EXPENSE_IDS"
+	CodeUnknownMaintenanceOperation     ErrorCode = "UNKNOWN_MAINTENANCE_OPERATION"
+	CodeUnsupportedImportFormat         ErrorCode = "UNSUPPORTED_IMPORT_FORMAT"
+	CodeUserAlreadyExists               ErrorCode = "USER_ALREADY_EXISTS"
+	CodeUserHasBalances                 ErrorCode = "USER_HAS_BALANCES"
+	CodeUserNotFound                    ErrorCode = "USER_NOT_FOUND"
+	CodeWebhookNotFound                 ErrorCode = "WEBHOOK_NOT_FOUND"
+	CodeWriteOffUnbalanced              ErrorCode = "WRITE_OFF_UNBALANCED"
+)
+
+var statusCodes = map[int]ErrorCode{
+	http.StatusBadRequest:            CodeInvalidRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnprocessableEntity:   CodeUnprocessable,
+	http.StatusTooManyRequests:       CodeRateLimited,
+	http.StatusServiceUnavailable:    CodeServiceUnavailable,
+}
+
+// CodeForStatus is the generic code for an HTTP status
+func CodeForStatus(statusCode int) ErrorCode {
+	if code, ok := statusCodes[statusCode]; ok {
+		return code
+	}
+	if statusCode >= 400 && statusCode < 500 {
+		return CodeInvalidRequest
+	}
+	return CodeInternal
+}
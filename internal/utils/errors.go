@@ -2,44 +2,99 @@
 package utils
 
 import (
+	"errors"
 	"net/http"
-	"strings"
+
+	"divvydoo/backend/pkg/locale"
 
 	"github.com/gin-gonic/gin"
 )
 
+// CustomError is an error the API reports with a fixed status and code. Services and
+// repositories declare their sentinel errors as CustomErrors, so wrapping one with %w keeps
+// its status and code.
 type CustomError struct {
 	StatusCode int
+	Code       ErrorCode
 	Message    string
+	cause      error
 }
 
 func (e *CustomError) Error() string {
 	return e.Message
 }
 
-func NewCustomError(statusCode int, message string) *CustomError {
+func (e *CustomError) Unwrap() error {
+	return e.cause
+}
+
+func NewCustomError(statusCode int, code ErrorCode, message string) *CustomError {
 	return &CustomError{
 		StatusCode: statusCode,
+		Code:       code,
 		Message:    message,
 	}
 }
 
+// WithCode gives err a more specific code, keeping its message and status. errors.Is still
+// matches the result against err.
+func WithCode(err error, code ErrorCode) error {
+	return &CustomError{
+		StatusCode: GetStatusCode(err),
+		Code:       code,
+		Message:    err.Error(),
+		cause:      err,
+	}
+}
+
+// ErrorResponse is the body of every error response
+type ErrorResponse struct {
+	Error string    `json:"error"`
+	Code  ErrorCode `json:"code"`
+}
+
+// RespondWithError sends message with the generic code for statusCode
 func RespondWithError(ctx *gin.Context, statusCode int, message string) {
-	ctx.JSON(statusCode, gin.H{"error": message})
+	RespondWithErrorCode(ctx, statusCode, CodeForStatus(statusCode), message)
+}
+
+// RespondWithErrorCode sends message, translated into the language the request negotiated, with
+// code. Clients that branch on errors should use the code, which is never translated.
+func RespondWithErrorCode(ctx *gin.Context, statusCode int, code ErrorCode, message string) {
+	ctx.JSON(statusCode, ErrorResponse{Error: translate(ctx, message), Code: code})
+}
+
+// RespondWithServiceError sends err with statusCode, using the code err carries, or the generic
+// one for statusCode if it carries none
+func RespondWithServiceError(ctx *gin.Context, statusCode int, err error) {
+	RespondWithErrorCode(ctx, statusCode, GetErrorCode(err, statusCode), err.Error())
+}
+
+// AbortWithError is RespondWithErrorCode for middleware, stopping the handler chain
+func AbortWithError(ctx *gin.Context, statusCode int, code ErrorCode, message string) {
+	ctx.AbortWithStatusJSON(statusCode, ErrorResponse{Error: translate(ctx, message), Code: code})
 }
 
+// translate puts message into the request's language. Messages without a translation, such as
+// wrapped errors with added context, stay in English.
+func translate(ctx *gin.Context, message string) string {
+	return locale.FromContext(ctx.Request.Context()).Translate(message)
+}
+
+// GetStatusCode is the status err carries, or 500 for errors the API didn't anticipate
 func GetStatusCode(err error) int {
-	switch err := err.(type) {
-	case *CustomError:
-		return err.StatusCode
-	default:
-		errMsg := err.Error()
-		if strings.Contains(errMsg, "not found") {
-			return http.StatusNotFound
-		}
-		if strings.Contains(errMsg, "already exists") {
-			return http.StatusConflict
-		}
-		return http.StatusInternalServerError
+	var customErr *CustomError
+	if errors.As(err, &customErr) {
+		return customErr.StatusCode
+	}
+	return http.StatusInternalServerError
+}
+
+// GetErrorCode is the code err carries, or the generic code for statusCode
+func GetErrorCode(err error, statusCode int) ErrorCode {
+	var customErr *CustomError
+	if errors.As(err, &customErr) && customErr.Code != "" {
+		return customErr.Code
 	}
+	return CodeForStatus(statusCode)
 }
@@ -0,0 +1,90 @@
+package utils
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultPageLimit int64 = 20
+	MaxPageLimit     int64 = 100
+	MaxPageOffset    int64 = 10000
+	MaxCursorLength        = 256
+)
+
+var ErrInvalidPagination = NewCustomError(http.StatusBadRequest, CodeInvalidPagination, "invalid pagination")
+
+// Pagination holds the list window requested through the limit, offset and cursor query parameters
+type Pagination struct {
+	Limit  int64
+	Offset int64
+	Cursor string
+}
+
+// ParsePagination reads limit, offset and cursor from the query string. Missing values use the
+// defaults; malformed or out-of-range values are rejected with ErrInvalidPagination so clients
+// learn about them instead of silently getting a different window. Offset only applies to the
+// first page and can't be combined with a cursor.
+func ParsePagination(ctx *gin.Context) (Pagination, error) {
+	page := Pagination{
+		Limit:  DefaultPageLimit,
+		Cursor: ctx.Query("cursor"),
+	}
+
+	if value := ctx.Query("limit"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit < 1 || limit > MaxPageLimit {
+			return page, fmt.Errorf("%w: limit must be an integer between 1 and %d", ErrInvalidPagination, MaxPageLimit)
+		}
+		page.Limit = limit
+	}
+
+	if value := ctx.Query("offset"); value != "" {
+		offset, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || offset < 0 || offset > MaxPageOffset {
+			return page, fmt.Errorf("%w: offset must be an integer between 0 and %d", ErrInvalidPagination, MaxPageOffset)
+		}
+		if offset > 0 && page.Cursor != "" {
+			return page, fmt.Errorf("%w: offset can't be combined with cursor", ErrInvalidPagination)
+		}
+		page.Offset = offset
+	}
+
+	if len(page.Cursor) > MaxCursorLength {
+		return page, fmt.Errorf("%w: cursor is too long", ErrInvalidPagination)
+	}
+
+	return page, nil
+}
+
+// Meta returns the list meta describing the applied window and the next page cursor
+func (p Pagination) Meta(nextCursor string) ListMeta {
+	return ListMeta{Limit: p.Limit, Offset: p.Offset, NextCursor: nextCursor}
+}
+
+// Sort is a sort order requested as sort=field (ascending) or sort=-field (descending)
+type Sort struct {
+	Field string
+	Desc  bool
+}
+
+// ParseSort reads the sort query parameter, falling back to defaultSort (in the same
+// "-field" form) when it's missing. Fields outside allowed are rejected with ErrInvalidPagination.
+func ParseSort(ctx *gin.Context, defaultSort string, allowed ...string) (Sort, error) {
+	value := strings.TrimSpace(ctx.Query("sort"))
+	if value == "" {
+		value = defaultSort
+	}
+
+	sort := Sort{Field: strings.TrimPrefix(value, "-"), Desc: strings.HasPrefix(value, "-")}
+	if !slices.Contains(allowed, sort.Field) {
+		return sort, fmt.Errorf("%w: sort must be one of %s, optionally prefixed with -", ErrInvalidPagination, strings.Join(allowed, ", "))
+	}
+
+	return sort, nil
+}
@@ -1,8 +1,102 @@
 // API response helpers
 package utils
 
-import "github.com/gin-gonic/gin"
+import (
+	"mime"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Clients choose the response shape with ?envelope=true|false or an Accept profile, e.g.
+// `Accept: application/json; profile="envelope"` or `profile="raw"`. The query parameter wins.
+// Enveloped responses are {data, meta}; raw list responses carry their pagination in headers.
+const (
+	EnvelopeProfile = "envelope"
+	RawProfile      = "raw"
+
+	NextCursorHeader = "X-Next-Cursor"
+	HasMoreHeader    = "X-Has-More"
+)
 
 func RespondWithJSON(ctx *gin.Context, statusCode int, data interface{}) {
+	ctx.Writer.Header().Add("Vary", "Accept")
+	if wantsEnvelope(ctx, false) {
+		ctx.JSON(statusCode, Envelope{Data: data})
+		return
+	}
 	ctx.JSON(statusCode, data)
 }
+
+// ListMeta carries pagination details for list responses
+type ListMeta struct {
+	Limit      int64  `json:"limit"`
+	Offset     int64  `json:"offset"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	HasMore    bool   `json:"has_more,omitempty"`
+}
+
+type ListResponse struct {
+	Data interface{} `json:"data"`
+	Meta ListMeta    `json:"meta"`
+}
+
+// Envelope wraps single entities and unpaginated results when a client asks for the envelope
+type Envelope struct {
+	Data interface{} `json:"data"`
+	Meta *ListMeta   `json:"meta,omitempty"`
+}
+
+// RespondWithList sends a page of results, enveloped unless the client asks for raw entities
+func RespondWithList(ctx *gin.Context, statusCode int, data interface{}, meta ListMeta) {
+	respondWithList(ctx, statusCode, data, meta, true)
+}
+
+// RespondWithLegacyList sends a page of results for lists that returned a bare array before the
+// envelope existed: raw unless the client asks for the envelope
+func RespondWithLegacyList(ctx *gin.Context, statusCode int, data interface{}, meta ListMeta) {
+	respondWithList(ctx, statusCode, data, meta, false)
+}
+
+func respondWithList(ctx *gin.Context, statusCode int, data interface{}, meta ListMeta, envelopeByDefault bool) {
+	ctx.Writer.Header().Add("Vary", "Accept")
+	if wantsEnvelope(ctx, envelopeByDefault) {
+		ctx.JSON(statusCode, ListResponse{Data: data, Meta: meta})
+		return
+	}
+
+	if meta.NextCursor != "" {
+		ctx.Header(NextCursorHeader, meta.NextCursor)
+	}
+	if meta.HasMore {
+		ctx.Header(HasMoreHeader, strconv.FormatBool(meta.HasMore))
+	}
+	ctx.JSON(statusCode, data)
+}
+
+// wantsEnvelope reports whether the client asked for the {data, meta} envelope, falling back to
+// fallback when it expressed no preference. Unrecognised values count as no preference.
+func wantsEnvelope(ctx *gin.Context, fallback bool) bool {
+	switch strings.ToLower(ctx.Query("envelope")) {
+	case "true", "1":
+		return true
+	case "false", "0":
+		return false
+	}
+
+	for _, accepted := range strings.Split(ctx.GetHeader("Accept"), ",") {
+		_, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		switch strings.ToLower(params["profile"]) {
+		case EnvelopeProfile:
+			return true
+		case RawProfile:
+			return false
+		}
+	}
+
+	return fallback
+}
@@ -0,0 +1,179 @@
+// Request validation errors
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// InvalidPayloadMessage is the error message for request bodies that fail to bind
+const InvalidPayloadMessage = "Invalid request payload"
+
+// FieldError describes one field that failed validation. Field is the JSON path, e.g.
+// splits[1].amount; Rule is the binding tag that failed, e.g. required or max.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// ValidationErrorResponse is the error envelope with the fields that failed, so clients can
+// show each message next to its input
+type ValidationErrorResponse struct {
+	Error   string       `json:"error"`
+	Code    ErrorCode    `json:"code"`
+	Details []FieldError `json:"details,omitempty"`
+}
+
+func init() {
+	// Report fields by their JSON names rather than the Go struct field names
+	if v, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		v.RegisterTagNameFunc(func(field reflect.StructField) string {
+			name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+			switch name {
+			case "-":
+				return ""
+			case "":
+				return field.Name
+			}
+			return name
+		})
+	}
+}
+
+// RespondWithBindingError answers a request whose body failed to bind with 400 and, where the
+// failure can be pinned to fields, their validation errors
+func RespondWithBindingError(ctx *gin.Context, err error) {
+	details := FieldErrors(err)
+	code := CodeValidationFailed
+	if len(details) == 0 {
+		code = CodeInvalidPayload
+	}
+	ctx.JSON(http.StatusBadRequest, ValidationErrorResponse{
+		Error:   translate(ctx, InvalidPayloadMessage),
+		Code:    code,
+		Details: details,
+	})
+}
+
+// FieldErrors translates a binding error into field errors. Malformed JSON has no field to
+// blame, so it yields none.
+func FieldErrors(err error) []FieldError {
+	var validationErrs validator.ValidationErrors
+	if errors.As(err, &validationErrs) {
+		fields := make([]FieldError, 0, len(validationErrs))
+		for _, fe := range validationErrs {
+			fields = append(fields, FieldError{
+				Field:   fieldPath(fe.Namespace()),
+				Rule:    fe.Tag(),
+				Message: fieldMessage(fe),
+			})
+		}
+		return fields
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return []FieldError{{
+			Field:   typeErr.Field,
+			Rule:    "type",
+			Message: fmt.Sprintf("%s must be %s", typeErr.Field, jsonTypeName(typeErr.Type)),
+		}}
+	}
+
+	return nil
+}
+
+// fieldPath drops the struct name the validator puts in front, e.g.
+// CreateExpenseRequest.splits[1].amount becomes splits[1].amount
+func fieldPath(namespace string) string {
+	if _, path, ok := strings.Cut(namespace, "."); ok {
+		return path
+	}
+	return namespace
+}
+
+func fieldMessage(fe validator.FieldError) string {
+	field := fe.Field()
+	kind := fe.Kind()
+	sized := kind == reflect.String || kind == reflect.Slice || kind == reflect.Map || kind == reflect.Array
+
+	switch fe.Tag() {
+	case "required":
+		return field + " is required"
+	case "email":
+		return field + " must be a valid email address"
+	case "uppercase":
+		return field + " must be uppercase"
+	case "oneof":
+		return fmt.Sprintf("%s must be one of: %s", field, strings.ReplaceAll(fe.Param(), " ", ", "))
+	case "len":
+		if kind == reflect.String {
+			return fmt.Sprintf("%s must be exactly %s characters", field, fe.Param())
+		}
+		if sized {
+			return fmt.Sprintf("%s must have exactly %s items", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be %s", field, fe.Param())
+	case "min":
+		if kind == reflect.String {
+			return fmt.Sprintf("%s must be at least %s characters", field, fe.Param())
+		}
+		if sized {
+			return fmt.Sprintf("%s must have at least %s items", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at least %s", field, fe.Param())
+	case "max":
+		if kind == reflect.String {
+			return fmt.Sprintf("%s must be at most %s characters", field, fe.Param())
+		}
+		if sized {
+			return fmt.Sprintf("%s must have at most %s items", field, fe.Param())
+		}
+		return fmt.Sprintf("%s must be at most %s", field, fe.Param())
+	case "gt":
+		return fmt.Sprintf("%s must be greater than %s", field, zeroParam(fe.Param()))
+	case "gte":
+		return fmt.Sprintf("%s must be at least %s", field, zeroParam(fe.Param()))
+	case "lt":
+		return fmt.Sprintf("%s must be less than %s", field, zeroParam(fe.Param()))
+	case "lte":
+		return fmt.Sprintf("%s must be at most %s", field, zeroParam(fe.Param()))
+	}
+	return fmt.Sprintf("%s failed the %s rule", field, fe.Tag())
+}
+
+// zeroParam fills in the 0 that gt, gte, lt and lte compare against when given no parameter
+func zeroParam(param string) string {
+	if param == "" {
+		return "0"
+	}
+	return param
+}
+
+func jsonTypeName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	case reflect.Map, reflect.Struct:
+		return "an object"
+	}
+	return "a " + t.String()
+}
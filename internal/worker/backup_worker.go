@@ -0,0 +1,67 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"divvydoo/backend/internal/metrics"
+	"divvydoo/backend/internal/models"
+	"divvydoo/backend/internal/services"
+)
+
+// BackupWorker takes a backup every interval and, if enabled, verifies it by restoring it
+type BackupWorker struct {
+	backupService *services.BackupService
+	interval      time.Duration
+	verify        bool
+}
+
+func NewBackupWorker(backupService *services.BackupService, interval time.Duration, verify bool) *BackupWorker {
+	return &BackupWorker{
+		backupService: backupService,
+		interval:      interval,
+		verify:        verify,
+	}
+}
+
+func (w *BackupWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.backup(ctx)
+		case <-ctx.Done():
+			log.Println("Backup worker stopped")
+			return
+		}
+	}
+}
+
+func (w *BackupWorker) backup(ctx context.Context) {
+	started := time.Now()
+	created, err := w.backupService.Backup(ctx)
+	metrics.ObserveWorkerRun("backup", started, err)
+	if err != nil {
+		log.Printf("Scheduled backup failed: %v", err)
+		return
+	}
+	log.Printf("Backup %s written to %s (%d bytes)", created.BackupID, created.Path, created.SizeBytes)
+
+	if !w.verify {
+		return
+	}
+
+	started = time.Now()
+	verification, err := w.backupService.Verify(ctx, created.BackupID)
+	metrics.ObserveWorkerRun("backup_verify", started, err)
+	if err != nil {
+		log.Printf("Verification of backup %s failed to run: %v", created.BackupID, err)
+		return
+	}
+	if verification.Status != models.BackupVerificationPassed {
+		log.Printf("Backup %s failed verification: %v", created.BackupID, verification.Issues)
+	}
+}
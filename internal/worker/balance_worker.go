@@ -5,35 +5,57 @@ import (
 	"log"
 	"time"
 
-	"divvydoo/backend/internal/repositories"
+	"divvydoo/backend/internal/metrics"
+	"divvydoo/backend/internal/services"
 )
 
+// balanceTaskBatchSize is how many queued balance updates are claimed at a time
+const balanceTaskBatchSize = 100
+
+// BalanceWorker applies the balance updates queued in async balance mode and snapshots every
+// balance each snapshot interval. Each snapshot run replaces the day's snapshots, so a day keeps
+// the balances from its last run.
 type BalanceWorker struct {
-	balanceRepo repositories.BalanceRepository
-	expenseRepo repositories.ExpenseRepository
-	interval    time.Duration
+	expenseService   *services.ExpenseService
+	snapshotService  *services.BalanceSnapshotService
+	queueInterval    time.Duration
+	snapshotInterval time.Duration // zero disables snapshots
 }
 
 func NewBalanceWorker(
-	balanceRepo repositories.BalanceRepository,
-	expenseRepo repositories.ExpenseRepository,
-	interval time.Duration,
+	expenseService *services.ExpenseService,
+	snapshotService *services.BalanceSnapshotService,
+	queueInterval time.Duration,
+	snapshotInterval time.Duration,
 ) *BalanceWorker {
 	return &BalanceWorker{
-		balanceRepo: balanceRepo,
-		expenseRepo: expenseRepo,
-		interval:    interval,
+		expenseService:   expenseService,
+		snapshotService:  snapshotService,
+		queueInterval:    queueInterval,
+		snapshotInterval: snapshotInterval,
 	}
 }
 
 func (w *BalanceWorker) Start(ctx context.Context) {
-	ticker := time.NewTicker(w.interval)
-	defer ticker.Stop()
+	queueTicker := time.NewTicker(w.queueInterval)
+	defer queueTicker.Stop()
+
+	var snapshots <-chan time.Time
+	if w.snapshotInterval > 0 {
+		snapshotTicker := time.NewTicker(w.snapshotInterval)
+		defer snapshotTicker.Stop()
+		snapshots = snapshotTicker.C
+
+		// Snapshot right away, so a day isn't missed when the process restarts often
+		w.snapshotBalances(ctx)
+	}
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-queueTicker.C:
 			w.processPendingBalances(ctx)
+		case <-snapshots:
+			w.snapshotBalances(ctx)
 		case <-ctx.Done():
 			log.Println("Balance worker stopped")
 			return
@@ -41,13 +63,34 @@ func (w *BalanceWorker) Start(ctx context.Context) {
 	}
 }
 
+// processPendingBalances works through the queue in batches until it is drained
 func (w *BalanceWorker) processPendingBalances(ctx context.Context) {
-	// In a real implementation, this would:
-	// 1. Get pending balance updates from a queue
-	// 2. Process them in batches
-	// 3. Update materialized balances
-	// 4. Handle retries for failures
-
-	log.Println("Processing pending balance updates...")
-	// Implementation would depend on your message queue system
+	started := time.Now()
+	processed := 0
+	var err error
+	for ctx.Err() == nil {
+		var claimed int
+		claimed, err = w.expenseService.ProcessBalanceTasks(ctx, balanceTaskBatchSize)
+		processed += claimed
+		if err != nil || claimed < balanceTaskBatchSize {
+			break
+		}
+	}
+	if processed == 0 && err == nil {
+		return
+	}
+
+	metrics.ObserveWorkerRun("balance_queue", started, err)
+	if err != nil {
+		log.Printf("Processing queued balance updates failed: %v", err)
+	}
+}
+
+func (w *BalanceWorker) snapshotBalances(ctx context.Context) {
+	started := time.Now()
+	taken, err := w.snapshotService.SnapshotBalances(ctx, started)
+	metrics.ObserveWorkerRun("balance_snapshot", started, err)
+	if err != nil {
+		log.Printf("Balance snapshot failed after %d balances: %v", taken, err)
+	}
 }
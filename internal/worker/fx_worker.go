@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"divvydoo/backend/internal/metrics"
+	"divvydoo/backend/pkg/fx"
+)
+
+// RateRefreshWorker fetches the latest exchange rates at startup and then every interval
+type RateRefreshWorker struct {
+	converter *fx.Converter
+	interval  time.Duration
+}
+
+func NewRateRefreshWorker(converter *fx.Converter, interval time.Duration) *RateRefreshWorker {
+	return &RateRefreshWorker{
+		converter: converter,
+		interval:  interval,
+	}
+}
+
+func (w *RateRefreshWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.refresh(ctx)
+	for {
+		select {
+		case <-ticker.C:
+			w.refresh(ctx)
+		case <-ctx.Done():
+			log.Println("Exchange rate worker stopped")
+			return
+		}
+	}
+}
+
+func (w *RateRefreshWorker) refresh(ctx context.Context) {
+	started := time.Now()
+	rates, err := w.converter.Refresh(ctx)
+	metrics.ObserveWorkerRun("exchange_rates", started, err)
+	if err != nil {
+		log.Printf("Failed to refresh exchange rates: %v", err)
+		return
+	}
+	log.Printf("Refreshed exchange rates: %d currencies against %s from %s, published %s", len(rates.Rates), rates.Base, rates.Source, rates.Date)
+}
@@ -0,0 +1,68 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"divvydoo/backend/internal/metrics"
+	"divvydoo/backend/internal/services"
+)
+
+// outboxBatchSize is how many outbox messages are claimed at a time
+const outboxBatchSize = 100
+
+// OutboxRelay delivers the events and notifications committed to the outbox. It runs as soon as
+// a request commits some and otherwise polls every interval, which picks up what other instances
+// committed, what was left over by a crash, and retries.
+type OutboxRelay struct {
+	outboxService *services.OutboxService
+	interval      time.Duration
+}
+
+func NewOutboxRelay(outboxService *services.OutboxService, interval time.Duration) *OutboxRelay {
+	return &OutboxRelay{
+		outboxService: outboxService,
+		interval:      interval,
+	}
+}
+
+func (r *OutboxRelay) Start(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.outboxService.Wakeups():
+			r.relay(ctx)
+		case <-ticker.C:
+			r.relay(ctx)
+		case <-ctx.Done():
+			log.Println("Outbox relay stopped")
+			return
+		}
+	}
+}
+
+// relay works through the outbox in batches until nothing is due
+func (r *OutboxRelay) relay(ctx context.Context) {
+	started := time.Now()
+	relayed := 0
+	var err error
+	for ctx.Err() == nil {
+		var claimed int
+		claimed, err = r.outboxService.Relay(ctx, outboxBatchSize)
+		relayed += claimed
+		if err != nil || claimed < outboxBatchSize {
+			break
+		}
+	}
+	if relayed == 0 && err == nil {
+		return
+	}
+
+	metrics.ObserveWorkerRun("outbox_relay", started, err)
+	if err != nil {
+		log.Printf("Relaying outbox messages failed: %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"divvydoo/backend/internal/metrics"
+	"divvydoo/backend/internal/services"
+)
+
+// ReconciliationWorker recomputes every group's balances each interval and reports the ones
+// that drifted, correcting them too if enabled
+type ReconciliationWorker struct {
+	reconciliationService *services.BalanceReconciliationService
+	interval              time.Duration
+	correct               bool
+}
+
+func NewReconciliationWorker(reconciliationService *services.BalanceReconciliationService, interval time.Duration, correct bool) *ReconciliationWorker {
+	return &ReconciliationWorker{
+		reconciliationService: reconciliationService,
+		interval:              interval,
+		correct:               correct,
+	}
+}
+
+func (w *ReconciliationWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.reconcile(ctx)
+		case <-ctx.Done():
+			log.Println("Reconciliation worker stopped")
+			return
+		}
+	}
+}
+
+func (w *ReconciliationWorker) reconcile(ctx context.Context) {
+	started := time.Now()
+	drifted, err := w.reconciliationService.ReconcileAll(ctx, w.correct)
+	metrics.ObserveWorkerRun("balance_reconcile", started, err)
+	if err != nil {
+		log.Printf("Balance reconciliation failed: %v", err)
+	}
+
+	for _, reconciliation := range drifted {
+		for _, drift := range reconciliation.Drifts {
+			log.Printf("Balance drift in group %s: user %s %s balance %.2f, recomputed %.2f",
+				reconciliation.GroupID, drift.UserID, drift.Currency, drift.Balance, drift.Recomputed)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"divvydoo/backend/internal/metrics"
+	"divvydoo/backend/internal/services"
+)
+
+// autoConfirmBatchSize bounds how many settlements are completed per tick
+const autoConfirmBatchSize = 100
+
+// SettlementWorker completes settlements whose payee didn't respond before the auto-confirm timeout,
+// and purges settlements voided longer ago than the retention period
+type SettlementWorker struct {
+	settlementService *services.SettlementService
+	interval          time.Duration
+	voidRetention     time.Duration // 0 keeps voided settlements
+}
+
+func NewSettlementWorker(settlementService *services.SettlementService, interval time.Duration, voidRetention time.Duration) *SettlementWorker {
+	return &SettlementWorker{
+		settlementService: settlementService,
+		interval:          interval,
+		voidRetention:     voidRetention,
+	}
+}
+
+func (w *SettlementWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			w.autoConfirm(ctx)
+			w.purgeVoided(ctx)
+		case <-ctx.Done():
+			log.Println("Settlement worker stopped")
+			return
+		}
+	}
+}
+
+func (w *SettlementWorker) autoConfirm(ctx context.Context) {
+	started := time.Now()
+	var err error
+	defer func() { metrics.ObserveWorkerRun("settlement_auto_confirm", started, err) }()
+
+	for {
+		var confirmed int
+		confirmed, err = w.settlementService.AutoConfirmDue(ctx, autoConfirmBatchSize)
+		if err != nil {
+			log.Printf("Failed to auto-confirm settlements: %v", err)
+			return
+		}
+		if confirmed > 0 {
+			log.Printf("Auto-confirmed %d settlements", confirmed)
+		}
+		if confirmed < autoConfirmBatchSize {
+			return
+		}
+	}
+}
+
+func (w *SettlementWorker) purgeVoided(ctx context.Context) {
+	if w.voidRetention <= 0 {
+		return
+	}
+
+	started := time.Now()
+	purged, err := w.settlementService.PurgeVoided(ctx, w.voidRetention)
+	metrics.ObserveWorkerRun("settlement_void_purge", started, err)
+	if err != nil {
+		log.Printf("Failed to purge voided settlements: %v", err)
+		return
+	}
+	if purged > 0 {
+		log.Printf("Purged %d voided settlements", purged)
+	}
+}
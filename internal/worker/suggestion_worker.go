@@ -0,0 +1,50 @@
+package worker
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"divvydoo/backend/internal/metrics"
+	"divvydoo/backend/internal/services"
+)
+
+// SuggestionWorker recomputes the group suggestions at startup and then every interval
+type SuggestionWorker struct {
+	suggestionService *services.SuggestionService
+	interval          time.Duration
+}
+
+func NewSuggestionWorker(suggestionService *services.SuggestionService, interval time.Duration) *SuggestionWorker {
+	return &SuggestionWorker{
+		suggestionService: suggestionService,
+		interval:          interval,
+	}
+}
+
+func (w *SuggestionWorker) Start(ctx context.Context) {
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	w.refresh(ctx)
+	for {
+		select {
+		case <-ticker.C:
+			w.refresh(ctx)
+		case <-ctx.Done():
+			log.Println("Suggestion worker stopped")
+			return
+		}
+	}
+}
+
+func (w *SuggestionWorker) refresh(ctx context.Context) {
+	started := time.Now()
+	stored, err := w.suggestionService.RefreshGroupSuggestions(ctx)
+	metrics.ObserveWorkerRun("group_suggestions", started, err)
+	if err != nil {
+		log.Printf("Failed to refresh group suggestions: %v", err)
+		return
+	}
+	log.Printf("Refreshed group suggestions: %d stored", stored)
+}
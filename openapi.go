@@ -0,0 +1,16 @@
+// Package backend holds files embedded from the repository root.
+package backend
+
+import _ "embed"
+
+// OpenAPISpec is openapi.yaml, compiled into the binary so the docs endpoint
+// doesn't depend on the working directory.
+//
+//go:embed openapi.yaml
+var OpenAPISpec []byte
+
+// DefaultCategories is categories.json, the expense category catalog served unless
+// CATEGORIES_FILE points at another one.
+//
+//go:embed categories.json
+var DefaultCategories []byte
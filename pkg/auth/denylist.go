@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TokenDenylist keeps track of revoked token IDs (jti) until the tokens expire. Revoking a user
+// rejects every token issued to them up to that moment.
+type TokenDenylist interface {
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+	RevokeUser(ctx context.Context, userID string, revokedAt, expiresAt time.Time) error
+	IsUserRevoked(ctx context.Context, userID string, issuedAt time.Time) (bool, error)
+}
+
+type redisDenylist struct {
+	client *redis.Client
+}
+
+func NewRedisDenylist(client *redis.Client) TokenDenylist {
+	return &redisDenylist{client: client}
+}
+
+func (d *redisDenylist) Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		// Token is already expired, nothing to revoke
+		return nil
+	}
+
+	return d.client.Set(ctx, denylistKey(tokenID), 1, ttl).Err()
+}
+
+func (d *redisDenylist) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	count, err := d.client.Exists(ctx, denylistKey(tokenID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// RevokeUser rejects the user's tokens issued at or before revokedAt. expiresAt should be the
+// latest expiry of those tokens, after which the marker is no longer needed.
+func (d *redisDenylist) RevokeUser(ctx context.Context, userID string, revokedAt, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	return d.client.Set(ctx, userDenylistKey(userID), revokedAt.Unix(), ttl).Err()
+}
+
+func (d *redisDenylist) IsUserRevoked(ctx context.Context, userID string, issuedAt time.Time) (bool, error) {
+	revokedAt, err := d.client.Get(ctx, userDenylistKey(userID)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return issuedAt.Unix() <= revokedAt, nil
+}
+
+func denylistKey(tokenID string) string {
+	return "auth:denylist:" + tokenID
+}
+
+func userDenylistKey(userID string) string {
+	return "auth:denylist:user:" + userID
+}
@@ -5,11 +5,15 @@ import (
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 var (
 	ErrInvalidToken = errors.New("invalid token")
 	ErrExpiredToken = errors.New("token has expired")
+	// ErrInvalidAPIKey is returned for API keys that are unknown, expired or belong to a
+	// disabled user
+	ErrInvalidAPIKey = errors.New("invalid or expired API key")
 )
 
 type Claims struct {
@@ -22,6 +26,7 @@ type JWTService interface {
 	GenerateToken(userID, email string) (string, error)
 	ValidateToken(tokenString string) (*Claims, error)
 	RefreshToken(tokenString string) (string, error)
+	TokenLifetime() time.Duration
 }
 
 type jwtService struct {
@@ -47,6 +52,7 @@ func (s *jwtService) GenerateToken(userID, email string) (string, error) {
 			NotBefore: jwt.NewNumericDate(now),
 			Issuer:    "divvydoo",
 			Subject:   userID,
+			ID:        uuid.New().String(),
 		},
 	}
 
@@ -54,6 +60,11 @@ func (s *jwtService) GenerateToken(userID, email string) (string, error) {
 	return token.SignedString(s.secretKey)
 }
 
+// TokenLifetime is how long newly issued tokens stay valid
+func (s *jwtService) TokenLifetime() time.Duration {
+	return s.expiration
+}
+
 func (s *jwtService) ValidateToken(tokenString string) (*Claims, error) {
 	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
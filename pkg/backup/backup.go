@@ -0,0 +1,78 @@
+// Package backup dumps and restores the database with the MongoDB Database Tools
+// (mongodump and mongorestore), which must be installed on the host.
+package backup
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Tool writes a database to a gzipped archive file and restores one into another database
+type Tool interface {
+	Dump(ctx context.Context, archivePath string) error
+	Restore(ctx context.Context, archivePath string, targetDatabase string) error
+}
+
+type MongoToolsConfig struct {
+	URI         string
+	Database    string
+	DumpPath    string // mongodump binary
+	RestorePath string // mongorestore binary
+}
+
+type mongoTools struct {
+	cfg MongoToolsConfig
+}
+
+func NewMongoTools(cfg MongoToolsConfig) Tool {
+	return &mongoTools{cfg: cfg}
+}
+
+func (t *mongoTools) Dump(ctx context.Context, archivePath string) error {
+	return t.run(ctx, t.cfg.DumpPath,
+		"--db="+t.cfg.Database,
+		"--archive="+archivePath,
+		"--gzip",
+	)
+}
+
+// Restore loads the archive into targetDatabase, replacing any collections already there
+func (t *mongoTools) Restore(ctx context.Context, archivePath string, targetDatabase string) error {
+	return t.run(ctx, t.cfg.RestorePath,
+		"--archive="+archivePath,
+		"--gzip",
+		"--nsFrom="+t.cfg.Database+".*",
+		"--nsTo="+targetDatabase+".*",
+		"--drop",
+	)
+}
+
+// run passes the connection string through a private config file rather than the command
+// line, where it (and any password in it) would be visible to other users of the host
+func (t *mongoTools) run(ctx context.Context, binary string, args ...string) error {
+	configFile, err := os.CreateTemp("", "mongotools-*.yaml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(configFile.Name())
+
+	_, err = fmt.Fprintf(configFile, "uri: %q\n", t.cfg.URI)
+	if closeErr := configFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, binary, append([]string{"--config=" + configFile.Name(), "--quiet"}, args...)...)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %v: %s", binary, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Entities whose cached state is shared between replicas
+const (
+	// EntityMembership keys are group IDs, optionally followed by "/<user ID>"
+	EntityMembership = "membership"
+	// EntityUser keys are user IDs
+	EntityUser = "user"
+)
+
+// Evictor drops cached state of one entity type
+type Evictor interface {
+	Evict(key string)
+	Purge()
+}
+
+// Invalidator keeps in-process caches consistent across API replicas: a change to an entity
+// evicts it here and is broadcast so every other replica evicts it too.
+type Invalidator interface {
+	Register(entity string, evictor Evictor)
+	Invalidate(ctx context.Context, entity string, key string)
+}
+
+// Invalidation is the message broadcast on the channel
+type Invalidation struct {
+	Entity string `json:"entity"`
+	Key    string `json:"key"`
+	Origin string `json:"origin"`
+}
+
+// invalidationRetryDelay is how long the subscriber waits after losing Redis before retrying
+const invalidationRetryDelay = time.Second
+
+// RedisInvalidator broadcasts invalidations over a Redis pub/sub channel. Pub/sub doesn't
+// queue messages for disconnected subscribers, so every (re)subscription purges all registered
+// caches; the caches' TTL bounds staleness if a broadcast fails to go out.
+type RedisInvalidator struct {
+	client  *redis.Client
+	channel string
+	origin  string
+
+	mu       sync.RWMutex
+	evictors map[string][]Evictor
+}
+
+func NewRedisInvalidator(client *redis.Client, channel string) *RedisInvalidator {
+	return &RedisInvalidator{
+		client:   client,
+		channel:  channel,
+		origin:   uuid.New().String(),
+		evictors: make(map[string][]Evictor),
+	}
+}
+
+func (i *RedisInvalidator) Register(entity string, evictor Evictor) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.evictors[entity] = append(i.evictors[entity], evictor)
+}
+
+// Invalidate evicts locally right away, then publishes. A failed publish is only logged, as the
+// change itself has already been written.
+func (i *RedisInvalidator) Invalidate(ctx context.Context, entity string, key string) {
+	i.evict(entity, key)
+
+	payload, err := json.Marshal(Invalidation{Entity: entity, Key: key, Origin: i.origin})
+	if err != nil {
+		log.Printf("Failed to encode cache invalidation: %v", err)
+		return
+	}
+	if err := i.client.Publish(context.WithoutCancel(ctx), i.channel, payload).Err(); err != nil {
+		log.Printf("Failed to broadcast cache invalidation of %s %s: %v", entity, key, err)
+	}
+}
+
+// Run applies the other replicas' invalidations until ctx is cancelled
+func (i *RedisInvalidator) Run(ctx context.Context) {
+	pubsub := i.client.Subscribe(ctx, i.channel)
+	defer pubsub.Close()
+
+	for {
+		msg, err := pubsub.Receive(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			if !errors.Is(err, redis.ErrClosed) {
+				log.Printf("Cache invalidation subscriber error: %v", err)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(invalidationRetryDelay):
+			}
+			continue
+		}
+
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			// Anything published while we were not subscribed is lost
+			if msg.Kind == "subscribe" {
+				i.purge()
+			}
+		case *redis.Message:
+			var invalidation Invalidation
+			if err := json.Unmarshal([]byte(msg.Payload), &invalidation); err != nil {
+				log.Printf("Ignoring malformed cache invalidation: %v", err)
+				continue
+			}
+			if invalidation.Origin != i.origin {
+				i.evict(invalidation.Entity, invalidation.Key)
+			}
+		}
+	}
+}
+
+func (i *RedisInvalidator) evict(entity string, key string) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for _, evictor := range i.evictors[entity] {
+		evictor.Evict(key)
+	}
+}
+
+func (i *RedisInvalidator) purge() {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	for _, evictors := range i.evictors {
+		for _, evictor := range evictors {
+			evictor.Purge()
+		}
+	}
+}
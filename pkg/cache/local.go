@@ -0,0 +1,96 @@
+package cache
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// localMaxEntries bounds a Local cache; when it fills up, expired entries are dropped and, if
+// that isn't enough, everything is
+const localMaxEntries = 100000
+
+// Local is an in-process cache whose entries expire after a fixed TTL. Keys are paths such as
+// "<group>/<user>", so evicting "<group>" also evicts everything under it.
+type Local[V any] struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]localEntry[V]
+	version uint64
+}
+
+type localEntry[V any] struct {
+	value     V
+	expiresAt time.Time
+}
+
+func NewLocal[V any](ttl time.Duration) *Local[V] {
+	return &Local[V]{ttl: ttl, entries: make(map[string]localEntry[V])}
+}
+
+func (c *Local[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		var zero V
+		return zero, false
+	}
+	return entry.value, true
+}
+
+// Version changes on every eviction. Read it before loading a value and pass it to SetAt, so a
+// value loaded while an eviction was on its way is not cached.
+func (c *Local[V]) Version() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
+
+// SetAt caches the value unless something was evicted since version was read
+func (c *Local[V]) SetAt(key string, value V, version uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.version != version {
+		return
+	}
+
+	now := time.Now()
+	if len(c.entries) >= localMaxEntries {
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= localMaxEntries {
+			c.entries = make(map[string]localEntry[V])
+		}
+	}
+	c.entries[key] = localEntry[V]{value: value, expiresAt: now.Add(c.ttl)}
+}
+
+// Evict drops the key and every key below it
+func (c *Local[V]) Evict(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	delete(c.entries, key)
+	prefix := key + "/"
+	for k := range c.entries {
+		if strings.HasPrefix(k, prefix) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// Purge drops everything
+func (c *Local[V]) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.version++
+	c.entries = make(map[string]localEntry[V])
+}
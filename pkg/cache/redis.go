@@ -0,0 +1,88 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// setIfVersion stores the value only while the key's version is still the one read before it
+// was loaded
+var setIfVersion = redis.NewScript(`
+if (redis.call("GET", KEYS[2]) or "0") == ARGV[2] then
+	redis.call("SET", KEYS[1], ARGV[1], "PX", ARGV[3])
+end
+return 0
+`)
+
+// Redis is a cache shared by every replica. Values are stored as JSON and expire after a fixed
+// TTL. Like Local, it counts evictions per key, so a value loaded while an eviction was on its
+// way is not cached.
+type Redis struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+func NewRedis(client *redis.Client, prefix string, ttl time.Duration) *Redis {
+	return &Redis{client: client, prefix: prefix, ttl: ttl}
+}
+
+// Get decodes the cached value into value and reports whether there was one
+func (c *Redis) Get(ctx context.Context, key string, value interface{}) (bool, error) {
+	data, err := c.client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := json.Unmarshal(data, value); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Version changes on every eviction of the key. Read it before loading a value and pass it to
+// SetAt.
+func (c *Redis) Version(ctx context.Context, key string) (string, error) {
+	version, err := c.client.Get(ctx, c.versionKey(key)).Result()
+	if errors.Is(err, redis.Nil) {
+		return "0", nil
+	}
+	return version, err
+}
+
+// SetAt caches the value unless the key was evicted since version was read
+func (c *Redis) SetAt(ctx context.Context, key string, value interface{}, version string) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	keys := []string{c.prefix + key, c.versionKey(key)}
+	return setIfVersion.Run(ctx, c.client, keys, data, version, c.ttl.Milliseconds()).Err()
+}
+
+// Evict drops the keys on every replica
+func (c *Redis) Evict(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	_, err := c.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, c.prefix+key)
+			pipe.Incr(ctx, c.versionKey(key))
+			// Outlives any load that read the old version
+			pipe.PExpire(ctx, c.versionKey(key), 2*c.ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (c *Redis) versionKey(key string) string {
+	return c.prefix + key + ":version"
+}
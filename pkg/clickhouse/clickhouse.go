@@ -0,0 +1,131 @@
+package clickhouse
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Config locates a ClickHouse server's HTTP interface, e.g. http://localhost:8123
+type Config struct {
+	URL      string
+	Database string
+	Username string
+	Password string
+}
+
+// Client runs statements against ClickHouse over its HTTP interface. Query parameters are sent
+// separately from the statement and referenced in it as {name:Type}, so values are never
+// spliced into SQL.
+type Client struct {
+	config Config
+	http   *http.Client
+}
+
+func NewClient(config Config) *Client {
+	config.URL = strings.TrimRight(config.URL, "/")
+	return &Client{
+		config: config,
+		http:   &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+// Exec runs a statement that returns no rows, such as CREATE TABLE
+func (c *Client) Exec(ctx context.Context, statement string) error {
+	resp, err := c.do(ctx, statement, nil, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Insert appends rows to table. Each row is encoded as a JSON object whose keys are column names.
+func (c *Client) Insert(ctx context.Context, table string, rows []interface{}) error {
+	if len(rows) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	encoder := json.NewEncoder(&body)
+	for _, row := range rows {
+		if err := encoder.Encode(row); err != nil {
+			return fmt.Errorf("failed to encode %s row: %v", table, err)
+		}
+	}
+
+	params := map[string]string{"date_time_input_format": "best_effort"}
+	resp, err := c.do(ctx, "INSERT INTO "+table+" FORMAT JSONEachRow", params, &body)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Select runs a query and decodes its rows into dest, a pointer to a slice of structs whose
+// json tags name the result columns
+func (c *Client) Select(ctx context.Context, query string, args map[string]string, dest interface{}) error {
+	params := map[string]string{"output_format_json_quote_64bit_integers": "0"}
+	for name, value := range args {
+		params["param_"+name] = value
+	}
+
+	resp, err := c.do(ctx, query+" FORMAT JSON", params, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("failed to decode ClickHouse response: %v", err)
+	}
+	return json.Unmarshal(result.Data, dest)
+}
+
+// do sends the statement in the query string, or ahead of the body when there is one, as the
+// HTTP interface expects for inserts
+func (c *Client) do(ctx context.Context, statement string, params map[string]string, body io.Reader) (*http.Response, error) {
+	values := url.Values{}
+	if c.config.Database != "" {
+		values.Set("database", c.config.Database)
+	}
+	for name, value := range params {
+		values.Set(name, value)
+	}
+
+	var reqBody io.Reader = strings.NewReader(statement)
+	if body != nil {
+		values.Set("query", statement)
+		reqBody = body
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.URL+"/?"+values.Encode(), reqBody)
+	if err != nil {
+		return nil, err
+	}
+	if c.config.Username != "" {
+		req.Header.Set("X-ClickHouse-User", c.config.Username)
+		req.Header.Set("X-ClickHouse-Key", c.config.Password)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 4<<10))
+		return nil, fmt.Errorf("clickhouse returned %d: %s", resp.StatusCode, strings.TrimSpace(string(message)))
+	}
+	return resp, nil
+}
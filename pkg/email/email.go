@@ -0,0 +1,47 @@
+package email
+
+import (
+	"context"
+	"log"
+	"net/mail"
+	"strings"
+)
+
+// Address is an email address with an optional display name
+type Address struct {
+	Name  string
+	Email string
+}
+
+func (a Address) String() string {
+	return (&mail.Address{Name: a.Name, Address: a.Email}).String()
+}
+
+// Message is a rendered email. HTML is optional; Text is always sent.
+type Message struct {
+	To      []Address
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// EmailSender delivers messages through an email provider
+type EmailSender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+type logSender struct{}
+
+// NewLogSender returns a sender that only logs messages. It is used when no provider is configured.
+func NewLogSender() EmailSender {
+	return &logSender{}
+}
+
+func (s *logSender) Send(ctx context.Context, msg Message) error {
+	recipients := make([]string, len(msg.To))
+	for i, to := range msg.To {
+		recipients[i] = to.Email
+	}
+	log.Printf("email to=%s subject=%q", strings.Join(recipients, ","), msg.Subject)
+	return nil
+}
@@ -0,0 +1,174 @@
+package email
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"math"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"divvydoo/backend/pkg/locale"
+)
+
+// Template names a message defined in templates/: <name>.txt holds the subject and
+// plain text body, <name>.html the HTML body.
+type Template string
+
+const (
+	TemplateInvitation         Template = "invitation"
+	TemplatePasswordReset      Template = "password_reset"
+	TemplatePlaceholderClaim   Template = "placeholder_claim"
+	TemplateSettlementReminder Template = "settlement_reminder"
+	TemplateWeeklyDigest       Template = "weekly_digest"
+)
+
+type InvitationData struct {
+	RecipientName string
+	InviterName   string
+	GroupName     string
+	URL           string // optional link to the group
+}
+
+type PasswordResetData struct {
+	RecipientName string
+	ResetURL      string
+	ExpiresIn     time.Duration
+}
+
+// PlaceholderClaimData carries the token that hands the recipient the group members imported
+// under their email
+type PlaceholderClaimData struct {
+	RecipientName string
+	Token         string
+	ExpiresIn     time.Duration
+}
+
+type SettlementReminderData struct {
+	RecipientName string
+	PayeeName     string
+	GroupName     string // empty for settlements outside a group
+	Amount        float64
+	Currency      string
+	URL           string // optional link to the settlement
+}
+
+type WeeklyDigestData struct {
+	RecipientName      string
+	WeekStart          time.Time
+	Groups             []DigestGroup
+	PendingSettlements int
+}
+
+// DigestGroup summarises a group's week. Balance is positive when the recipient is owed money.
+type DigestGroup struct {
+	Name        string
+	NewExpenses int
+	Balance     float64
+	Currency    string
+}
+
+// English templates live in templates/, translations in templates/<language>/ under the same
+// names. A translation may leave templates out; those are sent in English.
+//
+//go:embed templates
+var templateFS embed.FS
+
+type templateSet struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// dateLayouts are the date formats of languages that don't write dates the English way
+var dateLayouts = map[string]string{
+	"es": "2/1/2006",
+	"hi": "2/1/2006",
+}
+
+var templateSets = loadTemplateSets()
+
+func loadTemplateSets() map[string]templateSet {
+	sets := map[string]templateSet{locale.DefaultLanguage: parseTemplateSet(locale.DefaultLanguage, "templates")}
+
+	entries, err := templateFS.ReadDir("templates")
+	if err != nil {
+		panic(fmt.Sprintf("email: read templates: %v", err))
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if !locale.Supported(entry.Name()) {
+			panic(fmt.Sprintf("email: templates for unsupported language %q", entry.Name()))
+		}
+		sets[entry.Name()] = parseTemplateSet(entry.Name(), "templates/"+entry.Name())
+	}
+	return sets
+}
+
+func parseTemplateSet(language string, dir string) templateSet {
+	l := locale.Locale{Language: language}
+	dateLayout, ok := dateLayouts[language]
+	if !ok {
+		dateLayout = "2 Jan 2006"
+	}
+	funcs := map[string]any{
+		"money": l.FormatMoney,
+		"abs":   math.Abs,
+		"date":  func(t time.Time) string { return t.Format(dateLayout) },
+		"hours": func(d time.Duration) int { return int(math.Ceil(d.Hours())) },
+	}
+
+	// An empty set rather than a parse error when a translation has only text or only HTML
+	// templates; lookups then fall back to English
+	set := templateSet{
+		text: texttemplate.New("email").Funcs(funcs),
+		html: htmltemplate.New("email").Funcs(funcs),
+	}
+	if matches, _ := fs.Glob(templateFS, dir+"/*.txt"); len(matches) > 0 {
+		set.text = texttemplate.Must(set.text.ParseFS(templateFS, dir+"/*.txt"))
+	}
+	if matches, _ := fs.Glob(templateFS, dir+"/*.html"); len(matches) > 0 {
+		set.html = htmltemplate.Must(set.html.ParseFS(templateFS, dir+"/*.html"))
+	}
+	return set
+}
+
+// Render builds the message for a template in language, or in English when the template has no
+// translation into it. data must be the template's data type, e.g. InvitationData.
+func Render(tmpl Template, language string, to Address, data any) (Message, error) {
+	msg := Message{To: []Address{to}}
+	english := templateSets[locale.DefaultLanguage]
+	text, html := english.text, english.html
+	if set, ok := templateSets[language]; ok {
+		if set.text.Lookup(string(tmpl)+".subject") != nil {
+			text = set.text
+		}
+		if set.html.Lookup(string(tmpl)+".html") != nil {
+			html = set.html
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := text.ExecuteTemplate(&buf, string(tmpl)+".subject", data); err != nil {
+		return msg, fmt.Errorf("render %s subject: %w", tmpl, err)
+	}
+	msg.Subject = strings.TrimSpace(buf.String())
+
+	buf.Reset()
+	if err := text.ExecuteTemplate(&buf, string(tmpl)+".text", data); err != nil {
+		return msg, fmt.Errorf("render %s text: %w", tmpl, err)
+	}
+	msg.Text = strings.TrimSpace(buf.String()) + "\n"
+
+	buf.Reset()
+	if err := html.ExecuteTemplate(&buf, string(tmpl)+".html", data); err != nil {
+		return msg, fmt.Errorf("render %s html: %w", tmpl, err)
+	}
+	msg.HTML = buf.String()
+
+	return msg, nil
+}
@@ -0,0 +1,135 @@
+package fx
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// storeCheckInterval is how long the in-memory rates are used before the store is checked for
+// rates another replica fetched
+const storeCheckInterval = 5 * time.Minute
+
+// Converter converts amounts at the latest rates. It keeps them in memory and in the Store, and
+// only goes to the provider on Refresh or when the stored rates are older than maxAge. If the
+// provider fails, the last rates are used however old they are.
+type Converter struct {
+	provider RateProvider
+	store    Store
+	maxAge   time.Duration
+
+	// calls makes concurrent loads and refreshes share one trip to the store and the provider
+	calls singleflight.Group
+
+	// mu guards current and checkedAt; it is never held while the store or provider is called
+	mu        sync.Mutex
+	current   *Rates
+	checkedAt time.Time
+}
+
+func NewConverter(provider RateProvider, store Store, maxAge time.Duration) *Converter {
+	return &Converter{
+		provider: provider,
+		store:    store,
+		maxAge:   maxAge,
+	}
+}
+
+// Convert converts amount between two currencies at the latest rates
+func (c *Converter) Convert(ctx context.Context, amount float64, from, to string) (float64, error) {
+	if from == to {
+		return amount, nil
+	}
+	rates, err := c.Rates(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return rates.Convert(amount, from, to)
+}
+
+// Rates returns the latest rates available. Once there are rates in memory, newer ones are
+// looked for in the background and the current ones are returned meanwhile, so conversions never
+// wait on the provider; only calls made before any rates were loaded do.
+func (c *Converter) Rates(ctx context.Context) (*Rates, error) {
+	// The load is shared, so it must not be cancelled with the request that started it
+	ctx = context.WithoutCancel(ctx)
+
+	c.mu.Lock()
+	current := c.current
+	due := current != nil && time.Since(c.checkedAt) >= storeCheckInterval
+	if due {
+		// Claim the check, so the calls that follow don't start another
+		c.checkedAt = time.Now()
+	}
+	c.mu.Unlock()
+
+	if current != nil {
+		if due {
+			go c.share("load", func() (*Rates, error) { return c.load(ctx) })
+		}
+		return current, nil
+	}
+	return c.share("load", func() (*Rates, error) { return c.load(ctx) })
+}
+
+// Refresh fetches the latest rates from the provider and stores them
+func (c *Converter) Refresh(ctx context.Context) (*Rates, error) {
+	return c.share("fetch", func() (*Rates, error) { return c.fetch(ctx) })
+}
+
+func (c *Converter) share(key string, fn func() (*Rates, error)) (*Rates, error) {
+	rates, err, _ := c.calls.Do(key, func() (interface{}, error) { return fn() })
+	if err != nil {
+		return nil, err
+	}
+	return rates.(*Rates), nil
+}
+
+// load takes the stored rates, and fetches new ones if there are none or they are older than
+// maxAge. If the fetch fails, the rates there were are kept.
+func (c *Converter) load(ctx context.Context) (*Rates, error) {
+	stored, err := c.store.Load(ctx)
+
+	c.mu.Lock()
+	if err != nil {
+		log.Printf("Failed to load stored exchange rates: %v", err)
+	} else if stored != nil {
+		c.current = stored
+	}
+	c.checkedAt = time.Now()
+	current := c.current
+	c.mu.Unlock()
+
+	if current != nil && time.Since(current.FetchedAt) <= c.maxAge {
+		return current, nil
+	}
+	rates, err := c.fetch(ctx)
+	if err != nil {
+		if current == nil {
+			return nil, err
+		}
+		log.Printf("Using exchange rates of %s from %s: %v", current.Date, current.Source, err)
+		return current, nil
+	}
+	return rates, nil
+}
+
+func (c *Converter) fetch(ctx context.Context) (*Rates, error) {
+	rates, err := c.provider.Latest(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.store.Save(ctx, rates); err != nil {
+		// The rates are still good for this replica
+		log.Printf("Failed to store exchange rates: %v", err)
+	}
+
+	c.mu.Lock()
+	c.current = rates
+	c.checkedAt = time.Now()
+	c.mu.Unlock()
+	return rates, nil
+}
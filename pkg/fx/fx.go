@@ -0,0 +1,86 @@
+// Package fx provides exchange rates: HTTP rate providers, a Redis store shared by the API's
+// replicas and a Converter that keeps the latest rates in memory.
+package fx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrUnknownCurrency = errors.New("no exchange rate for currency")
+
+// Rates are the exchange rates a source published for one day. One unit of Base is worth
+// Rates[currency] units of currency.
+type Rates struct {
+	Base      string             `json:"base"`
+	Date      string             `json:"date"` // YYYY-MM-DD the rates were published for
+	Rates     map[string]float64 `json:"rates"`
+	Source    string             `json:"source"`
+	FetchedAt time.Time          `json:"fetched_at"`
+}
+
+func (r *Rates) rate(currency string) (float64, bool) {
+	if currency == r.Base {
+		return 1, true
+	}
+	rate, ok := r.Rates[currency]
+	return rate, ok && rate > 0
+}
+
+// Convert converts amount between two currencies, crossing through Base
+func (r *Rates) Convert(amount float64, from, to string) (float64, error) {
+	from, to = strings.ToUpper(from), strings.ToUpper(to)
+	if from == to {
+		return amount, nil
+	}
+	fromRate, ok := r.rate(from)
+	if !ok {
+		return 0, fmt.Errorf("%w %s", ErrUnknownCurrency, from)
+	}
+	toRate, ok := r.rate(to)
+	if !ok {
+		return 0, fmt.Errorf("%w %s", ErrUnknownCurrency, to)
+	}
+	return amount / fromRate * toRate, nil
+}
+
+// RateProvider fetches the latest rates from a rate source
+type RateProvider interface {
+	Name() string
+	Latest(ctx context.Context) (*Rates, error)
+}
+
+type fallbackProvider struct {
+	providers []RateProvider
+}
+
+// Fallback tries the providers in order and returns the rates of the first one that answers
+func Fallback(providers ...RateProvider) RateProvider {
+	if len(providers) == 1 {
+		return providers[0]
+	}
+	return &fallbackProvider{providers: providers}
+}
+
+func (p *fallbackProvider) Name() string {
+	names := make([]string, len(p.providers))
+	for i, provider := range p.providers {
+		names[i] = provider.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+func (p *fallbackProvider) Latest(ctx context.Context) (*Rates, error) {
+	var errs []error
+	for _, provider := range p.providers {
+		rates, err := provider.Latest(ctx)
+		if err == nil {
+			return rates, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+	}
+	return nil, errors.Join(errs...)
+}
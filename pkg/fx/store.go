@@ -0,0 +1,53 @@
+package fx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const ratesKey = "fx:rates"
+
+// Store keeps the last rates fetched, so replicas share them and a restart doesn't need the
+// provider
+type Store interface {
+	// Load returns the stored rates, or nil when there are none
+	Load(ctx context.Context) (*Rates, error)
+	Save(ctx context.Context, rates *Rates) error
+}
+
+type redisStore struct {
+	client *redis.Client
+}
+
+// NewRedisStore stores rates in Redis. They never expire there: old rates beat none when the
+// provider is down.
+func NewRedisStore(client *redis.Client) Store {
+	return &redisStore{client: client}
+}
+
+func (s *redisStore) Load(ctx context.Context) (*Rates, error) {
+	raw, err := s.client.Get(ctx, ratesKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var rates Rates
+	if err := json.Unmarshal(raw, &rates); err != nil {
+		return nil, err
+	}
+	return &rates, nil
+}
+
+func (s *redisStore) Save(ctx context.Context, rates *Rates) error {
+	raw, err := json.Marshal(rates)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, ratesKey, raw, 0).Err()
+}
@@ -0,0 +1,141 @@
+// Package locale carries the caller's language and display currency through a request
+package locale
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultLanguage is used when the caller accepts none of the supported languages
+const DefaultLanguage = "en"
+
+// numberFormat is how a language writes decimals and thousands
+type numberFormat struct {
+	decimal string
+	group   string
+}
+
+// languages are the supported languages and how they write amounts
+var languages = map[string]numberFormat{
+	"en": {decimal: ".", group: ","},
+	"de": {decimal: ",", group: "."},
+	"es": {decimal: ",", group: "."},
+	"fr": {decimal: ",", group: " "},
+	"hi": {decimal: ".", group: ","},
+	"it": {decimal: ",", group: "."},
+	"ja": {decimal: ".", group: ","},
+	"nl": {decimal: ",", group: "."},
+	"pt": {decimal: ",", group: "."},
+}
+
+// Locale is what the caller asked for. An empty Currency means no override: services fall back to
+// the user's preferred currency.
+type Locale struct {
+	Language string `json:"language"`
+	Currency string `json:"currency,omitempty"`
+}
+
+// Default is the locale of requests that didn't negotiate one
+var Default = Locale{Language: DefaultLanguage}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying l
+func NewContext(ctx context.Context, l Locale) context.Context {
+	return context.WithValue(ctx, contextKey{}, l)
+}
+
+// FromContext returns the locale stored in ctx, or Default
+func FromContext(ctx context.Context) Locale {
+	if l, ok := ctx.Value(contextKey{}).(Locale); ok {
+		return l
+	}
+	return Default
+}
+
+// Supported reports whether language is one of the supported languages
+func Supported(language string) bool {
+	_, ok := languages[language]
+	return ok
+}
+
+// ParseAcceptLanguage picks the supported language the caller prefers most from an
+// Accept-Language header, e.g. "fr-CH, fr;q=0.9, en;q=0.8". Region subtags are matched on their
+// language; ties keep the header order. It returns DefaultLanguage when nothing matches.
+func ParseAcceptLanguage(header string) string {
+	type candidate struct {
+		language string
+		quality  float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+
+		quality := 1.0
+		for _, param := range fields[1:] {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if !ok || strings.TrimSpace(name) != "q" {
+				continue
+			}
+			q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				q = 0
+			}
+			quality = q
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		language, _, _ := strings.Cut(tag, "-")
+		if language == "*" {
+			language = DefaultLanguage
+		}
+		if Supported(language) {
+			candidates = append(candidates, candidate{language: language, quality: quality})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].quality > candidates[j].quality })
+	if len(candidates) == 0 {
+		return DefaultLanguage
+	}
+	return candidates[0].language
+}
+
+// FormatAmount writes an amount with two decimals and the language's separators, e.g. 1,234.50
+// in English and 1.234,50 in German
+func (l Locale) FormatAmount(amount float64) string {
+	format, ok := languages[l.Language]
+	if !ok {
+		format = languages[DefaultLanguage]
+	}
+
+	digits := strconv.FormatFloat(amount, 'f', 2, 64)
+	sign := ""
+	if strings.HasPrefix(digits, "-") {
+		sign, digits = "-", digits[1:]
+	}
+	whole, fraction, _ := strings.Cut(digits, ".")
+
+	var grouped strings.Builder
+	for i, digit := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			grouped.WriteString(format.group)
+		}
+		grouped.WriteRune(digit)
+	}
+	return sign + grouped.String() + format.decimal + fraction
+}
+
+// FormatMoney writes an amount followed by its currency code
+func (l Locale) FormatMoney(amount float64, currency string) string {
+	return l.FormatAmount(amount) + " " + currency
+}
@@ -0,0 +1,63 @@
+package locale
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Messages are written in English in the code; messages/<language>.json maps each English text,
+// or format for fmt, to its translation. Texts missing from a catalog stay in English, so a
+// catalog can be partial.
+//
+//go:embed messages/*.json
+var catalogFS embed.FS
+
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	entries, err := catalogFS.ReadDir("messages")
+	if err != nil {
+		panic(fmt.Sprintf("locale: read message catalogs: %v", err))
+	}
+
+	loaded := make(map[string]map[string]string, len(entries))
+	for _, entry := range entries {
+		language := strings.TrimSuffix(entry.Name(), ".json")
+		if !Supported(language) {
+			panic(fmt.Sprintf("locale: message catalog for unsupported language %q", language))
+		}
+		raw, err := catalogFS.ReadFile(path.Join("messages", entry.Name()))
+		if err != nil {
+			panic(fmt.Sprintf("locale: read %s: %v", entry.Name(), err))
+		}
+		var catalog map[string]string
+		if err := json.Unmarshal(raw, &catalog); err != nil {
+			panic(fmt.Sprintf("locale: parse %s: %v", entry.Name(), err))
+		}
+		loaded[language] = catalog
+	}
+	return loaded
+}
+
+// Translate returns the translation of an English text into language, or the text itself when
+// there is none
+func Translate(language string, text string) string {
+	if translated, ok := catalogs[language][text]; ok {
+		return translated
+	}
+	return text
+}
+
+// Sprintf translates an English format into language and fills in args. Translations may reorder
+// the arguments with explicit indexes, e.g. %[2]s.
+func Sprintf(language string, format string, args ...any) string {
+	return fmt.Sprintf(Translate(language, format), args...)
+}
+
+// Translate returns the translation of an English text into l's language
+func (l Locale) Translate(text string) string {
+	return Translate(l.Language, text)
+}
@@ -0,0 +1,63 @@
+// Package payments verifies and reads the webhooks payment providers send when a payment they
+// processed succeeds or fails.
+package payments
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	ErrMalformedEvent   = errors.New("malformed webhook event")
+)
+
+// Outcome is what an event says happened to a payment
+type Outcome string
+
+const (
+	OutcomeSucceeded Outcome = "succeeded"
+	OutcomeFailed    Outcome = "failed"
+	// OutcomeNone is for events that say nothing about a payment's result
+	OutcomeNone Outcome = ""
+)
+
+// Event is a webhook event in a form common to every provider. Payments are tied to a settlement
+// by the settlement ID given to the provider when the payment was created, in the payment's
+// metadata (Stripe) or notes (Razorpay).
+type Event struct {
+	// ID is the provider's event ID; providers resend an event with the same ID until it is
+	// acknowledged
+	ID      string
+	Type    string
+	Outcome Outcome
+
+	SettlementID  string
+	TransactionID string // the provider's payment ID
+	Amount        float64
+	Currency      string // uppercase
+	FailureReason string
+}
+
+// Provider checks that a webhook request came from the payment provider and reads its event
+type Provider interface {
+	Name() string
+	// Parse returns ErrInvalidSignature if the request isn't signed with the webhook secret,
+	// and ErrMalformedEvent if its body can't be read as an event
+	Parse(header http.Header, body []byte) (*Event, error)
+}
+
+// zeroDecimalCurrencies have no minor unit, so providers give their amounts in whole units
+var zeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true, "KRW": true, "MGA": true,
+	"PYG": true, "RWF": true, "UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// fromMinorUnits turns an amount in the currency's smallest unit, e.g. cents, into units
+func fromMinorUnits(amount int64, currency string) float64 {
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return float64(amount)
+	}
+	return float64(amount) / 100
+}
@@ -0,0 +1,74 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+type razorpayProvider struct {
+	secret string
+}
+
+// NewRazorpayProvider reads Razorpay's payment.captured and payment.failed events, signed with
+// the webhook secret set in the Razorpay dashboard
+func NewRazorpayProvider(secret string) Provider {
+	return &razorpayProvider{secret: secret}
+}
+
+func (p *razorpayProvider) Name() string {
+	return "razorpay"
+}
+
+type razorpayEvent struct {
+	Event   string `json:"event"`
+	Payload struct {
+		Payment struct {
+			Entity struct {
+				ID               string            `json:"id"`
+				Amount           int64             `json:"amount"`
+				Currency         string            `json:"currency"`
+				Notes            map[string]string `json:"notes"`
+				ErrorDescription string            `json:"error_description"`
+			} `json:"entity"`
+		} `json:"payment"`
+	} `json:"payload"`
+}
+
+// Parse checks X-Razorpay-Signature, the hex HMAC-SHA256 of the body. Razorpay sends the event
+// ID in X-Razorpay-Event-Id rather than in the body.
+func (p *razorpayProvider) Parse(header http.Header, body []byte) (*Event, error) {
+	given, err := hex.DecodeString(header.Get("X-Razorpay-Signature"))
+	mac := hmac.New(sha256.New, []byte(p.secret))
+	mac.Write(body)
+	if err != nil || !hmac.Equal(given, mac.Sum(nil)) {
+		return nil, ErrInvalidSignature
+	}
+
+	id := header.Get("X-Razorpay-Event-Id")
+	var raw razorpayEvent
+	if err := json.Unmarshal(body, &raw); err != nil || id == "" {
+		return nil, ErrMalformedEvent
+	}
+
+	payment := raw.Payload.Payment.Entity
+	event := &Event{
+		ID:            id,
+		Type:          raw.Event,
+		SettlementID:  payment.Notes["settlement_id"],
+		TransactionID: payment.ID,
+		Amount:        fromMinorUnits(payment.Amount, payment.Currency),
+		Currency:      strings.ToUpper(payment.Currency),
+	}
+	switch raw.Event {
+	case "payment.captured":
+		event.Outcome = OutcomeSucceeded
+	case "payment.failed":
+		event.Outcome = OutcomeFailed
+		event.FailureReason = payment.ErrorDescription
+	}
+	return event, nil
+}
@@ -0,0 +1,60 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+const razorpayTestSecret = "razorpay_test"
+
+const razorpayTestBody = `{"event":"payment.captured","payload":{"payment":{"entity":{"id":"pay_1","amount":50000,"currency":"INR","notes":{"settlement_id":"settlement-1"}}}}}`
+
+// razorpaySignature signs body the way Razorpay does
+func razorpaySignature(secret string, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestRazorpayParseVerifiesSignature(t *testing.T) {
+	provider := NewRazorpayProvider(razorpayTestSecret)
+	valid := razorpaySignature(razorpayTestSecret, razorpayTestBody)
+
+	cases := []struct {
+		name      string
+		signature string
+		body      string
+		eventID   string
+		want      error
+	}{
+		{"valid", valid, razorpayTestBody, "evt_1", nil},
+		{"tampered body", valid, `{"event":"payment.captured","payload":{"payment":{"entity":{"id":"pay_1","amount":1,"currency":"INR"}}}}`, "evt_1", ErrInvalidSignature},
+		{"wrong secret", razorpaySignature("other", razorpayTestBody), razorpayTestBody, "evt_1", ErrInvalidSignature},
+		{"empty header", "", razorpayTestBody, "evt_1", ErrInvalidSignature},
+		{"non-hex signature", "not-hex", razorpayTestBody, "evt_1", ErrInvalidSignature},
+		{"truncated signature", valid[:len(valid)-2], razorpayTestBody, "evt_1", ErrInvalidSignature},
+		// The event ID is what replays are recognised by, so an event without one is refused
+		{"missing event ID", valid, razorpayTestBody, "", ErrMalformedEvent},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("X-Razorpay-Signature", tc.signature)
+			header.Set("X-Razorpay-Event-Id", tc.eventID)
+
+			event, err := provider.Parse(header, []byte(tc.body))
+
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("Parse with signature %q: error %v, want %v", tc.signature, err, tc.want)
+			}
+			if tc.want == nil && (event.ID != "evt_1" || event.Outcome != OutcomeSucceeded || event.Amount != 500 || event.SettlementID != "settlement-1") {
+				t.Fatalf("Parse with signature %q: event %+v", tc.signature, event)
+			}
+		})
+	}
+}
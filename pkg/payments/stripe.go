@@ -0,0 +1,118 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stripeTolerance is how old a signed Stripe request may be, so a captured one can't be replayed later
+const stripeTolerance = 5 * time.Minute
+
+type stripeProvider struct {
+	secret string
+	now    func() time.Time
+}
+
+// NewStripeProvider reads Stripe's payment_intent.succeeded and payment_intent.payment_failed
+// events, signed with the endpoint's signing secret (whsec_...)
+func NewStripeProvider(secret string) Provider {
+	return &stripeProvider{secret: secret, now: time.Now}
+}
+
+func (p *stripeProvider) Name() string {
+	return "stripe"
+}
+
+type stripeEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object struct {
+			ID               string            `json:"id"`
+			AmountReceived   int64             `json:"amount_received"`
+			Amount           int64             `json:"amount"`
+			Currency         string            `json:"currency"`
+			Metadata         map[string]string `json:"metadata"`
+			LastPaymentError *struct {
+				Message string `json:"message"`
+			} `json:"last_payment_error"`
+		} `json:"object"`
+	} `json:"data"`
+}
+
+func (p *stripeProvider) Parse(header http.Header, body []byte) (*Event, error) {
+	if !p.verify(header.Get("Stripe-Signature"), body) {
+		return nil, ErrInvalidSignature
+	}
+
+	var raw stripeEvent
+	if err := json.Unmarshal(body, &raw); err != nil || raw.ID == "" {
+		return nil, ErrMalformedEvent
+	}
+
+	object := raw.Data.Object
+	event := &Event{
+		ID:            raw.ID,
+		Type:          raw.Type,
+		SettlementID:  object.Metadata["settlement_id"],
+		TransactionID: object.ID,
+		Currency:      strings.ToUpper(object.Currency),
+	}
+	switch raw.Type {
+	case "payment_intent.succeeded":
+		event.Outcome = OutcomeSucceeded
+		event.Amount = fromMinorUnits(object.AmountReceived, object.Currency)
+	case "payment_intent.payment_failed":
+		event.Outcome = OutcomeFailed
+		event.Amount = fromMinorUnits(object.Amount, object.Currency)
+		if object.LastPaymentError != nil {
+			event.FailureReason = object.LastPaymentError.Message
+		}
+	}
+	return event, nil
+}
+
+// verify checks the Stripe-Signature header, "t=<unix time>,v1=<signature>[,v1=...]", where
+// each v1 is the hex HMAC-SHA256 of "<t>.<body>". Stripe sends several v1 while a secret is
+// being rolled, so any of them may match.
+func (p *stripeProvider) verify(signature string, body []byte) bool {
+	var timestamp string
+	var candidates []string
+	for _, part := range strings.Split(signature, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			candidates = append(candidates, value)
+		}
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || len(candidates) == 0 {
+		return false
+	}
+	if age := p.now().Sub(time.Unix(seconds, 0)); age > stripeTolerance || age < -stripeTolerance {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(p.secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+	for _, candidate := range candidates {
+		if given, err := hex.DecodeString(candidate); err == nil && hmac.Equal(given, expected) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,75 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const stripeTestSecret = "whsec_test"
+
+const stripeTestBody = `{"id":"evt_1","type":"payment_intent.succeeded","data":{"object":{"id":"pi_1","amount_received":1250,"currency":"usd","metadata":{"settlement_id":"settlement-1"}}}}`
+
+// stripeSignature signs body at timestamp the way Stripe does
+func stripeSignature(secret string, timestamp int64, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s", timestamp, body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestStripeParseVerifiesSignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	provider := &stripeProvider{secret: stripeTestSecret, now: func() time.Time { return now }}
+
+	signed := now.Unix()
+	valid := stripeSignature(stripeTestSecret, signed, stripeTestBody)
+	stale := now.Add(-stripeTolerance - time.Second).Unix()
+	future := now.Add(stripeTolerance + time.Second).Unix()
+
+	cases := []struct {
+		name      string
+		signature string
+		body      string
+		want      error
+	}{
+		{"valid", fmt.Sprintf("t=%d,v1=%s", signed, valid), stripeTestBody, nil},
+		// While a secret is being rolled Stripe signs with both, and either may match
+		{"valid among several", fmt.Sprintf("t=%d,v1=%s,v1=%s", signed, stripeSignature("whsec_old", signed, stripeTestBody), valid), stripeTestBody, nil},
+		{"just inside tolerance", fmt.Sprintf("t=%d,v1=%s", now.Add(-stripeTolerance).Unix(), stripeSignature(stripeTestSecret, now.Add(-stripeTolerance).Unix(), stripeTestBody)), stripeTestBody, nil},
+		{"tampered body", fmt.Sprintf("t=%d,v1=%s", signed, valid), `{"id":"evt_1","type":"payment_intent.succeeded","data":{"object":{"id":"pi_1","amount_received":999999,"currency":"usd"}}}`, ErrInvalidSignature},
+		{"wrong secret", fmt.Sprintf("t=%d,v1=%s", signed, stripeSignature("whsec_other", signed, stripeTestBody)), stripeTestBody, ErrInvalidSignature},
+		// A captured request replayed later carries its original timestamp
+		{"stale timestamp", fmt.Sprintf("t=%d,v1=%s", stale, stripeSignature(stripeTestSecret, stale, stripeTestBody)), stripeTestBody, ErrInvalidSignature},
+		{"future timestamp", fmt.Sprintf("t=%d,v1=%s", future, stripeSignature(stripeTestSecret, future, stripeTestBody)), stripeTestBody, ErrInvalidSignature},
+		// The timestamp is signed, so moving it forward to get inside the tolerance breaks the signature
+		{"replayed with a new timestamp", fmt.Sprintf("t=%d,v1=%s", signed, stripeSignature(stripeTestSecret, stale, stripeTestBody)), stripeTestBody, ErrInvalidSignature},
+		{"empty header", "", stripeTestBody, ErrInvalidSignature},
+		{"missing timestamp", "v1=" + valid, stripeTestBody, ErrInvalidSignature},
+		{"missing signature", fmt.Sprintf("t=%d", signed), stripeTestBody, ErrInvalidSignature},
+		{"non-numeric timestamp", "t=yesterday,v1=" + valid, stripeTestBody, ErrInvalidSignature},
+		{"non-hex signature", fmt.Sprintf("t=%d,v1=not-hex", signed), stripeTestBody, ErrInvalidSignature},
+		{"unknown scheme only", fmt.Sprintf("t=%d,v0=%s", signed, valid), stripeTestBody, ErrInvalidSignature},
+		{"no separators", fmt.Sprintf("t%dv1%s", signed, valid), stripeTestBody, ErrInvalidSignature},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			header := http.Header{}
+			header.Set("Stripe-Signature", tc.signature)
+
+			event, err := provider.Parse(header, []byte(tc.body))
+
+			if !errors.Is(err, tc.want) {
+				t.Fatalf("Parse with signature %q: error %v, want %v", tc.signature, err, tc.want)
+			}
+			if tc.want == nil && (event.ID != "evt_1" || event.Outcome != OutcomeSucceeded || event.Amount != 12.5 || event.SettlementID != "settlement-1") {
+				t.Fatalf("Parse with signature %q: event %+v", tc.signature, event)
+			}
+		})
+	}
+}
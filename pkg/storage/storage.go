@@ -0,0 +1,89 @@
+// Package storage keeps uploaded files, such as avatars, behind a provider-neutral interface.
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	ErrNotFound   = errors.New("file not found")
+	ErrInvalidKey = errors.New("invalid storage key")
+)
+
+// Store saves files under slash-separated keys and tells where clients can fetch them
+type Store interface {
+	// Put writes data under key, replacing any existing file, and returns its public URL
+	Put(ctx context.Context, key string, contentType string, data []byte) (string, error)
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type localStore struct {
+	dir     string
+	baseURL string
+}
+
+// NewLocalStore keeps files in dir. The server is expected to serve dir at baseURL.
+func NewLocalStore(dir, baseURL string) Store {
+	return &localStore{dir: dir, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+func (s *localStore) Put(ctx context.Context, key string, contentType string, data []byte) (string, error) {
+	filename, err := s.path(key)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return "", err
+	}
+
+	// Write to a temporary file first so readers never see a partial file
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, filename); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	return s.baseURL + "/" + key, nil
+}
+
+func (s *localStore) Get(ctx context.Context, key string) ([]byte, error) {
+	filename, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *localStore) Delete(ctx context.Context, key string) error {
+	filename, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// path maps a key to a file inside dir, rejecting keys that would escape it
+func (s *localStore) path(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "../") || key == ".." {
+		return "", ErrInvalidKey
+	}
+	return filepath.Join(s.dir, filepath.FromSlash(key)), nil
+}